@@ -0,0 +1,178 @@
+package alerts
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknownAlert is returned when acknowledging an alert that was not sent lately
+	ErrUnknownAlert = errors.New(constants.UnknownAlertError)
+	// ErrUnknownRule is returned when silencing a rule that is not configured
+	ErrUnknownRule = errors.New(constants.UnknownAlertRuleError)
+)
+
+// Alert is an alert that was sent, the repeats of its key are not sent while it is acknowledged
+type Alert struct {
+	ID   string    `json:"id"`
+	Key  string    `json:"key"`
+	Rule string    `json:"rule,omitempty"`
+	Text string    `json:"text"`
+	Time time.Time `json:"time"`
+	// AckedBy is who acknowledged the alert
+	AckedBy string    `json:"ackedBy,omitempty"`
+	AckedAt time.Time `json:"ackedAt,omitempty"`
+}
+
+// Silence is a rule that does not alert until it expires
+type Silence struct {
+	Rule  string    `json:"rule"`
+	Until time.Time `json:"until"`
+	By    string    `json:"by,omitempty"`
+}
+
+// Ack is used to acknowledge the alert of the default alerter, its repeats are not sent for the ack duration
+func Ack(id, by string) (Alert, error) {
+	mu.RLock()
+	a := alerter
+	mu.RUnlock()
+	if a == nil {
+		return Alert{}, fmt.Errorf("%w %s", ErrUnknownAlert, id)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	alert, ok := a.recent[id]
+	if !ok {
+		return Alert{}, fmt.Errorf("%w %s", ErrUnknownAlert, id)
+	}
+	now := time.Now()
+	alert.AckedBy, alert.AckedAt = by, now
+	a.acked[alert.Key] = now.Add(time.Duration(a.config.AckDurationInMinutes) * time.Minute)
+	return *alert, nil
+}
+
+// SilenceRule is used to stop the rule of the default alerter from alerting and creating tickets for the duration
+func SilenceRule(rule string, duration time.Duration, by string) (Silence, error) {
+	mu.RLock()
+	a := alerter
+	mu.RUnlock()
+	if a == nil || !a.hasRule(rule) {
+		return Silence{}, fmt.Errorf("%w %s", ErrUnknownRule, rule)
+	}
+	if duration <= 0 {
+		return Silence{}, fmt.Errorf("silence of rule %s needs a positive duration", rule)
+	}
+	silence := Silence{Rule: rule, Until: time.Now().Add(duration), By: by}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.silenced[rule] = silence
+	return silence, nil
+}
+
+// Unsilence is used to let the silenced rule of the default alerter alert again
+func Unsilence(rule string) error {
+	mu.RLock()
+	a := alerter
+	mu.RUnlock()
+	if a == nil || !a.hasRule(rule) {
+		return fmt.Errorf("%w %s", ErrUnknownRule, rule)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.silenced, rule)
+	return nil
+}
+
+// Silences is used to get the silenced rules of the default alerter, by rule
+func Silences() []Silence {
+	mu.RLock()
+	a := alerter
+	mu.RUnlock()
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	var silences []Silence
+	for rule, silence := range a.silenced {
+		if now.Before(silence.Until) {
+			silences = append(silences, silence)
+		} else {
+			delete(a.silenced, rule)
+		}
+	}
+	sort.Slice(silences, func(i, j int) bool {
+		return silences[i].Rule < silences[j].Rule
+	})
+	return silences
+}
+
+func (a *Alerter) hasRule(name string) bool {
+	for _, rule := range a.config.Rules {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isSilenced is used to check whether the rule is silenced
+func (a *Alerter) isSilenced(rule string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	silence, ok := a.silenced[rule]
+	return ok && time.Now().Before(silence.Until)
+}
+
+// acknowledged is used to check whether an alert of the key was acknowledged lately
+func (a *Alerter) acknowledged(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	until, ok := a.acked[key]
+	if ok && !time.Now().Before(until) {
+		delete(a.acked, key)
+		return false
+	}
+	return ok
+}
+
+// record is used to give the alert an id and keep it to be acknowledged, the oldest alerts are forgotten
+func (a *Alerter) record(alert *Alert) {
+	alert.ID = uuid.NewString()[:constants.AlertIDLength]
+	alert.Time = time.Now()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.ids) >= constants.MaxAlertKeys {
+		delete(a.recent, a.ids[0])
+		a.ids = a.ids[1:]
+	}
+	kept := *alert
+	a.recent[alert.ID] = &kept
+	a.ids = append(a.ids, alert.ID)
+}
+
+// blocks is used to get the slack blocks of the alert, its text and the buttons acknowledging it and silencing its
+// rule
+func blocks(alert Alert) []interface{} {
+	button := func(text, action, value string) map[string]interface{} {
+		return map[string]interface{}{"type": "button", "action_id": action, "value": value,
+			"text": map[string]string{"type": "plain_text", "text": text}}
+	}
+	buttons := []interface{}{button("Acknowledge", constants.AckAlertAction, alert.ID)}
+	if alert.Rule != "" {
+		buttons = append(buttons, button(fmt.Sprintf("Silence rule for %s", constants.DefaultSilenceDuration),
+			constants.SilenceRuleAction, alert.Rule))
+	}
+	return []interface{}{
+		map[string]interface{}{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": alert.Text}},
+		map[string]interface{}{"type": "context", "elements": []interface{}{
+			map[string]string{"type": "mrkdwn", "text": "alert " + alert.ID}}},
+		map[string]interface{}{"type": "actions", "elements": buttons},
+	}
+}
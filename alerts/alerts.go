@@ -0,0 +1,255 @@
+// Package alerts sends urgent notices, such as leaked credentials or entries matching the alert rules, to the
+// security channel webhook, and creates tickets of the alert rules in the issue trackers.
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/catalog"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+// Config is the configuration of the security channel
+type Config struct {
+	// URL is the incoming webhook of the channel, it receives {"text": "..."} as slack and mattermost expect
+	URL string `json:"-" mapstructure:"url"`
+	// TimeoutInMillis is the timeout of a webhook call
+	TimeoutInMillis int `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+	// IntervalInSeconds is the minimum time between alerts of the same key, so a noisy producer does not flood
+	IntervalInSeconds int `json:"intervalInSeconds" mapstructure:"intervalInSeconds"`
+	// Rules alert on the entries matching their filter
+	Rules []Rule `json:"rules" mapstructure:"rules"`
+	// RouteToOwners posts the alerts of the rules to the channel of the team owning the service of the entry, as
+	// found in the service catalog, the webhook has to accept the channel field as mattermost and legacy slack
+	// webhooks do. The owner is named in the alerts either way.
+	RouteToOwners bool `json:"routeToOwners" mapstructure:"routeToOwners"`
+	// Trackers are the issue trackers the rules create tickets in
+	Trackers []TrackerConfig `json:"trackers" mapstructure:"trackers"`
+	// Interactive adds the buttons acknowledging the alert and silencing its rule, for the slack app of the
+	// service to handle
+	Interactive bool `json:"interactive" mapstructure:"interactive"`
+	// AckDurationInMinutes is how long the repeats of an acknowledged alert are not sent
+	AckDurationInMinutes int `json:"ackDurationInMinutes" mapstructure:"ackDurationInMinutes"`
+}
+
+// Rule is an alert on the entries matching a filter expression, throttled per rule
+type Rule struct {
+	Name string `json:"name" mapstructure:"name"`
+	// Filter is the filter expression of the entries alerted on
+	Filter string `json:"filter" mapstructure:"filter"`
+	// Message is the text of the alert, the name of the rule and the type of the entry by default
+	Message string `json:"message" mapstructure:"message"`
+	// Ticket is the name of the tracker the rule creates a ticket in for each error group, the entries of a group
+	// update its ticket and reopen it once closed
+	Ticket string `json:"ticket" mapstructure:"ticket"`
+	// TicketOnly only creates the tickets, without posting the alerts to the webhook
+	TicketOnly bool `json:"ticketOnly" mapstructure:"ticketOnly"`
+	// GroupBy are the fields of the fingerprint of the error groups, the type and the message without its numbers
+	// and ids by default
+	GroupBy []string `json:"groupBy" mapstructure:"groupBy"`
+}
+
+// Alerter sends throttled alerts to the webhook
+type Alerter struct {
+	config   Config
+	filters  []*filter.Filter
+	trackers map[string]Tracker
+	mu       sync.Mutex
+	sent     map[string]time.Time
+	// recent are the alerts sent lately by id, to acknowledge them, and ids are their ids from the oldest
+	recent map[string]*Alert
+	ids    []string
+	// acked are the keys of the acknowledged alerts and silenced the silenced rules, until they expire
+	acked    map[string]time.Time
+	silenced map[string]Silence
+}
+
+var (
+	mu      sync.RWMutex
+	alerter *Alerter
+)
+
+// New is used to create the alerter of the config, applying the defaults
+func New(config Config) (*Alerter, error) {
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultAlertTimeoutInMillis
+	}
+	if config.IntervalInSeconds <= 0 {
+		config.IntervalInSeconds = constants.DefaultAlertIntervalInSeconds
+	}
+	if config.AckDurationInMinutes <= 0 {
+		config.AckDurationInMinutes = constants.DefaultAlertAckDurationInMinutes
+	}
+	a := &Alerter{config: config, trackers: make(map[string]Tracker), sent: make(map[string]time.Time),
+		recent: make(map[string]*Alert), acked: make(map[string]time.Time), silenced: make(map[string]Silence)}
+	for _, trackerConfig := range config.Trackers {
+		if _, ok := a.trackers[trackerConfig.Name]; ok || trackerConfig.Name == "" {
+			return nil, fmt.Errorf("trackers need a unique name, %q is not", trackerConfig.Name)
+		}
+		t, err := NewTracker(trackerConfig)
+		if err != nil {
+			return nil, err
+		}
+		a.trackers[trackerConfig.Name] = t
+	}
+	for _, rule := range config.Rules {
+		if rule.Name == "" || rule.Filter == "" {
+			return nil, fmt.Errorf("alert rules need a name and a filter")
+		}
+		if _, ok := a.trackers[rule.Ticket]; rule.Ticket != "" && !ok {
+			return nil, fmt.Errorf("alert rule %s has an unknown tracker %s", rule.Name, rule.Ticket)
+		}
+		if rule.TicketOnly && rule.Ticket == "" {
+			return nil, fmt.Errorf("alert rule %s only creates tickets, it needs a tracker", rule.Name)
+		}
+		f, err := filter.Parse(rule.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alert rule %s : %w", rule.Name, err)
+		}
+		a.filters = append(a.filters, f)
+	}
+	return a, nil
+}
+
+// Init is used to set the default alerter
+func Init(config Config) error {
+	a, err := New(config)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	alerter = a
+	return nil
+}
+
+// Send is used to alert on the default alerter in the background, nothing is sent without a webhook
+func Send(ctx context.Context, key, text string) {
+	SendTo(ctx, key, text, "")
+}
+
+// SendTo is used to alert a channel on the default alerter in the background, the channel of the webhook is used
+// when it is empty
+func SendTo(ctx context.Context, key, text, channel string) {
+	mu.RLock()
+	a := alerter
+	mu.RUnlock()
+	if a != nil {
+		a.send(ctx, Alert{Key: key, Text: text}, channel)
+	}
+}
+
+// send is used to post the alert in the background unless its key was alerted on within the interval or it was
+// acknowledged
+func (a *Alerter) send(ctx context.Context, alert Alert, channel string) {
+	if a.config.URL == "" || a.acknowledged(alert.Key) || !a.allow(alert.Key) {
+		return
+	}
+	a.record(&alert)
+	go func() {
+		if err := a.post(alert, channel); err != nil {
+			log.Error(ctx).Err(err).Msg("error sending alert")
+		}
+	}()
+}
+
+// Evaluate is used to alert on the rules the entry matches
+func Evaluate(ctx context.Context, entry models.LogEntry) {
+	mu.RLock()
+	a := alerter
+	mu.RUnlock()
+	if a == nil {
+		return
+	}
+	for i, rule := range a.config.Rules {
+		if a.isSilenced(rule.Name) || !a.filters[i].Match(entry) {
+			continue
+		}
+		text := rule.Message
+		if text == "" {
+			text = fmt.Sprintf("alert %s : %s entry of type %s", rule.Name, entry.Level, entry.Type)
+		}
+		if rule.Ticket != "" {
+			a.ticket(ctx, rule, entry, text)
+		}
+		if rule.TicketOnly {
+			continue
+		}
+		key, channel := constants.AlertRuleKeyPrefix+rule.Name, ""
+		if owner, ok := catalog.OwnerOf(entry); ok {
+			text = fmt.Sprintf("%s, owned by %s", text, owner)
+			if a.config.RouteToOwners && owner.Channel != "" {
+				// the alerts of a rule are throttled per channel, so a team is not kept from those of its services
+				key, channel = key+"/"+owner.Channel, owner.Channel
+			}
+		}
+		a.send(ctx, Alert{Key: key, Rule: rule.Name, Text: text}, channel)
+	}
+}
+
+// allow is used to check whether the key was not alerted within the interval and record it
+func (a *Alerter) allow(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	if last, ok := a.sent[key]; ok && now.Sub(last) < time.Duration(a.config.IntervalInSeconds)*time.Second {
+		return false
+	}
+	if len(a.sent) >= constants.MaxAlertKeys {
+		for k, last := range a.sent {
+			if now.Sub(last) >= time.Duration(a.config.IntervalInSeconds)*time.Second {
+				delete(a.sent, k)
+			}
+		}
+	}
+	a.sent[key] = now
+	return true
+}
+
+// Post is used to send the text to the webhook right away
+func (a *Alerter) Post(text string) error {
+	return a.PostTo(text, "")
+}
+
+// PostTo is used to send the text to a channel of the webhook right away, slack and mattermost webhooks post to
+// the channel of the body rather than their own when it is set
+func (a *Alerter) PostTo(text, channel string) error {
+	return a.post(Alert{Text: text}, channel)
+}
+
+// post is used to send the alert to the channel of the webhook, with its buttons when interactive
+func (a *Alerter) post(alert Alert, channel string) error {
+	payload := map[string]interface{}{"text": alert.Text}
+	if channel != "" {
+		payload["channel"] = channel
+	}
+	if a.config.Interactive && alert.ID != "" {
+		payload["blocks"] = blocks(alert)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	response, err := httpclient.POSTWithTimeout(a.config.URL, map[string]string{"Content-Type": constants.JSONMediaType},
+		bytes.NewReader(body), time.Duration(a.config.TimeoutInMillis)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("alert webhook responded %d", response.StatusCode)
+	}
+	return nil
+}
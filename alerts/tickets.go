@@ -0,0 +1,323 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/catalog"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+// TrackerConfig is an issue tracker the alert rules create tickets in, for the teams running ticket driven ops
+type TrackerConfig struct {
+	Name string `json:"name" mapstructure:"name"`
+	// Kind is the tracker, jira or servicenow
+	Kind string `json:"kind" mapstructure:"kind"`
+	// URL is the base url of the tracker, e.g. https://company.atlassian.net
+	URL string `json:"url" mapstructure:"url"`
+	// Username and Token are the basic auth credentials, the api token of jira or the password of servicenow.
+	// The token is sent as a bearer token without a username.
+	Username        string `json:"username" mapstructure:"username"`
+	Token           string `json:"-" mapstructure:"token"`
+	TimeoutInMillis int    `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+	// Project is the key of the jira project of the tickets
+	Project string `json:"project" mapstructure:"project"`
+	// IssueType is the jira issue type of the tickets, Bug by default
+	IssueType string `json:"issueType" mapstructure:"issueType"`
+	// ReopenTransition is the jira transition of the done tickets that recur, Reopen by default
+	ReopenTransition string `json:"reopenTransition" mapstructure:"reopenTransition"`
+	// Table is the servicenow table of the tickets, incident by default
+	Table string `json:"table" mapstructure:"table"`
+	// AssignmentGroup is the servicenow group the tickets are assigned to
+	AssignmentGroup string `json:"assignmentGroup" mapstructure:"assignmentGroup"`
+}
+
+// Ticket is the ticket of an error group, the entries with the same fingerprint update the same ticket
+type Ticket struct {
+	Fingerprint string
+	Summary     string
+	Description string
+}
+
+// Tracker creates and updates the tickets of the error groups
+type Tracker interface {
+	// Upsert is used to create the ticket of the fingerprint, or to comment on its open ticket and reopen its
+	// closed one, and get the key of the ticket along with what was done
+	Upsert(ticket Ticket) (string, string, error)
+}
+
+// variableParts are the parts of the messages that differ between the entries of an error group, e.g. ids
+var variableParts = regexp.MustCompile(`[0-9a-fA-F]{8}(-?[0-9a-fA-F]{4}){3}-?[0-9a-fA-F]{12}|0x[0-9a-fA-F]+|\d+`)
+
+// Fingerprint is used to get the error group of the entry, the values of the fields, or the type and the message
+// without its numbers and ids by default
+func Fingerprint(entry models.LogEntry, fields []string) string {
+	h := sha256.New()
+	if len(fields) == 0 {
+		h.Write([]byte(entry.Type))
+		h.Write([]byte{0})
+		if message, ok := entry.Data[constants.MessageField]; ok {
+			h.Write([]byte(variableParts.ReplaceAllString(fmt.Sprint(message), "#")))
+		}
+	}
+	for _, field := range fields {
+		if value, ok := filter.Lookup(entry, field); ok {
+			h.Write([]byte(fmt.Sprint(value)))
+		}
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:constants.FingerprintLength]
+}
+
+// NewTracker is used to create the tracker of the config, applying the defaults
+func NewTracker(config TrackerConfig) (Tracker, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("tracker %s needs a url", config.Name)
+	}
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultAlertTimeoutInMillis
+	}
+	t := tracker{url: strings.TrimSuffix(config.URL, "/"), timeout: time.Duration(config.TimeoutInMillis) *
+		time.Millisecond, headers: map[string]string{"Content-Type": constants.JSONMediaType,
+		"Accept": constants.JSONMediaType}}
+	if config.Username != "" {
+		t.headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(config.Username+":"+
+			config.Token))
+	} else if config.Token != "" {
+		t.headers["Authorization"] = "Bearer " + config.Token
+	}
+	switch config.Kind {
+	case constants.JiraTracker:
+		if config.Project == "" {
+			return nil, fmt.Errorf("tracker %s needs a project", config.Name)
+		}
+		if config.IssueType == "" {
+			config.IssueType = constants.DefaultJiraIssueType
+		}
+		if config.ReopenTransition == "" {
+			config.ReopenTransition = constants.DefaultJiraReopenTransition
+		}
+		return &jira{tracker: t, config: config}, nil
+	case constants.ServiceNowTracker:
+		if config.Table == "" {
+			config.Table = constants.DefaultServiceNowTable
+		}
+		return &serviceNow{tracker: t, config: config}, nil
+	}
+	return nil, fmt.Errorf("tracker %s kind has to be %s or %s", config.Name, constants.JiraTracker,
+		constants.ServiceNowTracker)
+}
+
+// ticket is used to create or update the ticket of the error group of the entry in the background, once per
+// interval for each group
+func (a *Alerter) ticket(ctx context.Context, rule Rule, entry models.LogEntry, text string) {
+	tracker, ok := a.trackers[rule.Ticket]
+	if !ok {
+		return
+	}
+	fingerprint := Fingerprint(entry, rule.GroupBy)
+	if !a.allow(constants.TicketKeyPrefix + rule.Name + "/" + fingerprint) {
+		return
+	}
+	var description strings.Builder
+	description.WriteString(text)
+	if owner, ok := catalog.OwnerOf(entry); ok {
+		fmt.Fprintf(&description, "\n\nOwner: %s", owner)
+	}
+	if sample, err := json.MarshalIndent(entry, "", "  "); err == nil {
+		fmt.Fprintf(&description, "\n\nLatest entry:\n%s", sample)
+	}
+	fmt.Fprintf(&description, "\n\nFingerprint: %s", fingerprint)
+	summary := fmt.Sprintf("[%s] %s", rule.Name, entry.Type)
+	if message, ok := entry.Data[constants.MessageField]; ok {
+		summary = fmt.Sprintf("%s : %v", summary, message)
+	}
+	if runes := []rune(summary); len(runes) > constants.MaxTicketSummaryLength {
+		summary = string(runes[:constants.MaxTicketSummaryLength])
+	}
+	go func() {
+		key, action, err := tracker.Upsert(Ticket{Fingerprint: fingerprint, Summary: summary,
+			Description: description.String()})
+		if err != nil {
+			log.Error(ctx).Err(err).Str(constants.IDKey, fingerprint).Msg("error creating ticket")
+			return
+		}
+		log.Info(ctx).Str(constants.IDKey, key).Str(constants.EventKey, action).Msg("alert ticket")
+	}()
+}
+
+// tracker is the api of an issue tracker
+type tracker struct {
+	url     string
+	timeout time.Duration
+	headers map[string]string
+}
+
+// call is used to send the request with the json of in, when not nil, and decode the json response into out
+func (t tracker) call(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		encoded, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(encoded)
+	}
+	var response *http.Response
+	var err error
+	switch method {
+	case http.MethodGet:
+		response, err = httpclient.GETWithTimeout(t.url+path, t.headers, t.timeout)
+	case http.MethodPatch:
+		response, err = httpclient.PATCHWithTimeout(t.url+path, t.headers, body, t.timeout)
+	default:
+		response, err = httpclient.POSTWithTimeout(t.url+path, t.headers, body, t.timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", constants.ExternalServiceFailureError, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+		return fmt.Errorf("tracker responded %d to %s %s : %s", response.StatusCode, method, path,
+			strings.TrimSpace(string(message)))
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// jira tracks the error groups with a label of their fingerprint on the issues
+type jira struct {
+	tracker
+	config TrackerConfig
+}
+
+func (j *jira) Upsert(ticket Ticket) (string, string, error) {
+	label := constants.TicketLabelPrefix + ticket.Fingerprint
+	var found struct {
+		Issues []struct {
+			Key    string `json:"key"`
+			Fields struct {
+				Status struct {
+					StatusCategory struct {
+						Key string `json:"key"`
+					} `json:"statusCategory"`
+				} `json:"status"`
+			} `json:"fields"`
+		} `json:"issues"`
+	}
+	query := url.Values{"jql": {fmt.Sprintf("project = %q AND labels = %q ORDER BY created DESC", j.config.Project,
+		label)}, "maxResults": {"1"}, "fields": {"status"}}
+	if err := j.call(http.MethodGet, "/rest/api/2/search?"+query.Encode(), nil, &found); err != nil {
+		return "", "", err
+	}
+	if len(found.Issues) == 0 {
+		var created struct {
+			Key string `json:"key"`
+		}
+		err := j.call(http.MethodPost, "/rest/api/2/issue", map[string]interface{}{"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.config.Project},
+			"issuetype":   map[string]string{"name": j.config.IssueType},
+			"summary":     ticket.Summary,
+			"description": ticket.Description,
+			"labels":      []string{label},
+		}}, &created)
+		return created.Key, constants.TicketCreated, err
+	}
+
+	issue := found.Issues[0]
+	action := constants.TicketUpdated
+	if issue.Fields.Status.StatusCategory.Key == constants.JiraDoneCategory {
+		if err := j.reopen(issue.Key); err != nil {
+			return issue.Key, "", err
+		}
+		action = constants.TicketReopened
+	}
+	err := j.call(http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(issue.Key)+"/comment",
+		map[string]string{"body": "Recurred.\n\n" + ticket.Description}, nil)
+	return issue.Key, action, err
+}
+
+// reopen is used to apply the reopen transition to the issue
+func (j *jira) reopen(key string) error {
+	path := "/rest/api/2/issue/" + url.PathEscape(key) + "/transitions"
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	if err := j.call(http.MethodGet, path, nil, &available); err != nil {
+		return err
+	}
+	for _, transition := range available.Transitions {
+		if strings.EqualFold(transition.Name, j.config.ReopenTransition) {
+			return j.call(http.MethodPost, path, map[string]interface{}{
+				"transition": map[string]string{"id": transition.ID}}, nil)
+		}
+	}
+	return fmt.Errorf("issue %s has no transition %s", key, j.config.ReopenTransition)
+}
+
+// serviceNow tracks the error groups with their fingerprint as the correlation id of the records
+type serviceNow struct {
+	tracker
+	config TrackerConfig
+}
+
+func (s *serviceNow) Upsert(ticket Ticket) (string, string, error) {
+	path := "/api/now/table/" + url.PathEscape(s.config.Table)
+	var found struct {
+		Result []struct {
+			ID     string `json:"sys_id"`
+			Number string `json:"number"`
+			State  string `json:"state"`
+		} `json:"result"`
+	}
+	query := url.Values{"sysparm_query": {"correlation_id=" + ticket.Fingerprint + "^ORDERBYDESCsys_created_on"},
+		"sysparm_limit": {"1"}, "sysparm_fields": {"sys_id,number,state"}}
+	if err := s.call(http.MethodGet, path+"?"+query.Encode(), nil, &found); err != nil {
+		return "", "", err
+	}
+	if len(found.Result) == 0 {
+		var created struct {
+			Result struct {
+				Number string `json:"number"`
+			} `json:"result"`
+		}
+		record := map[string]string{"short_description": ticket.Summary, "description": ticket.Description,
+			"correlation_id": ticket.Fingerprint}
+		if s.config.AssignmentGroup != "" {
+			record["assignment_group"] = s.config.AssignmentGroup
+		}
+		err := s.call(http.MethodPost, path, record, &created)
+		return created.Result.Number, constants.TicketCreated, err
+	}
+
+	record := found.Result[0]
+	update := map[string]string{"work_notes": "Recurred.\n\n" + ticket.Description}
+	action := constants.TicketUpdated
+	if record.State == constants.ServiceNowResolvedState || record.State == constants.ServiceNowClosedState {
+		update["state"] = constants.ServiceNowNewState
+		action = constants.TicketReopened
+	}
+	return record.Number, action, s.call(http.MethodPatch, path+"/"+url.PathEscape(record.ID), update, nil)
+}
@@ -0,0 +1,120 @@
+package alerts_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/alerts"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	entry := func(message string) models.LogEntry {
+		return models.LogEntry{Type: "payment", Data: map[string]interface{}{"message": message, "code": "E42"}}
+	}
+	// the ids and numbers of the messages do not split the error groups
+	assert.Equal(t, alerts.Fingerprint(entry("order 1234 failed after 3 tries"), nil),
+		alerts.Fingerprint(entry("order 98 failed after 5 tries"), nil))
+	assert.NotEqual(t, alerts.Fingerprint(entry("order 1234 failed"), nil),
+		alerts.Fingerprint(entry("order 1234 declined"), nil))
+	assert.Equal(t, alerts.Fingerprint(entry("order failed"), []string{"type", "data.code"}),
+		alerts.Fingerprint(entry("order declined"), []string{"type", "data.code"}))
+	assert.Len(t, alerts.Fingerprint(entry(""), nil), 16)
+}
+
+func TestJiraTracker(t *testing.T) {
+	issues := map[string]string{}
+	var comments, transitions int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Basic Ym90OnRva2Vu", r.Header.Get("Authorization"))
+		switch {
+		case r.URL.Path == "/rest/api/2/search":
+			assert.Contains(t, r.URL.Query().Get("jql"), `labels = "log-group-0123456789abcdef"`)
+			status, ok := issues["OPS-1"]
+			if !ok {
+				_, _ = fmt.Fprint(w, `{"issues":[]}`)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"issues":[{"key":"OPS-1","fields":{"status":{"statusCategory":{"key":%q}}}}]}`,
+				status)
+		case r.URL.Path == "/rest/api/2/issue":
+			var body map[string]map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			assert.Equal(t, map[string]interface{}{"key": "OPS"}, body["fields"]["project"])
+			issues["OPS-1"] = "new"
+			_, _ = fmt.Fprint(w, `{"key":"OPS-1"}`)
+		case strings.HasSuffix(r.URL.Path, "/comment"):
+			comments++
+		case strings.HasSuffix(r.URL.Path, "/transitions") && r.Method == http.MethodGet:
+			_, _ = fmt.Fprint(w, `{"transitions":[{"id":"11","name":"Done"},{"id":"21","name":"reopen"}]}`)
+		case strings.HasSuffix(r.URL.Path, "/transitions"):
+			transitions++
+			issues["OPS-1"] = "indeterminate"
+		}
+	}))
+	defer server.Close()
+	tracker, err := alerts.NewTracker(alerts.TrackerConfig{Name: "ops", Kind: "jira", URL: server.URL + "/",
+		Username: "bot", Token: "token", Project: "OPS"})
+	assert.NoError(t, err)
+	ticket := alerts.Ticket{Fingerprint: "0123456789abcdef", Summary: "[payments] payment", Description: "failed"}
+
+	for _, expected := range []string{"created", "updated"} {
+		key, action, err := tracker.Upsert(ticket)
+		assert.NoError(t, err)
+		assert.Equal(t, "OPS-1", key)
+		assert.Equal(t, expected, action)
+	}
+	issues["OPS-1"] = "done"
+	_, action, err := tracker.Upsert(ticket)
+	assert.NoError(t, err)
+	assert.Equal(t, "reopened", action)
+	assert.Equal(t, 2, comments)
+	assert.Equal(t, 1, transitions)
+
+	_, err = alerts.NewTracker(alerts.TrackerConfig{Name: "ops", Kind: "jira", URL: server.URL})
+	assert.Error(t, err)
+	_, err = alerts.New(alerts.Config{Rules: []alerts.Rule{{Name: "r", Filter: "level = error", Ticket: "ops"}}})
+	assert.Error(t, err)
+}
+
+func TestServiceNowTracker(t *testing.T) {
+	var state string
+	var update map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/now/table/incident", strings.TrimSuffix(r.URL.Path, "/abc"))
+		switch r.Method {
+		case http.MethodGet:
+			assert.Contains(t, r.URL.Query().Get("sysparm_query"), "correlation_id=0123456789abcdef")
+			if state == "" {
+				_, _ = fmt.Fprint(w, `{"result":[]}`)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"result":[{"sys_id":"abc","number":"INC001","state":%q}]}`, state)
+		case http.MethodPost:
+			state = "1"
+			_, _ = fmt.Fprint(w, `{"result":{"number":"INC001"}}`)
+		case http.MethodPatch:
+			_ = json.NewDecoder(r.Body).Decode(&update)
+		}
+	}))
+	defer server.Close()
+	tracker, err := alerts.NewTracker(alerts.TrackerConfig{Name: "itsm", Kind: "servicenow", URL: server.URL})
+	assert.NoError(t, err)
+	ticket := alerts.Ticket{Fingerprint: "0123456789abcdef", Summary: "[payments] payment", Description: "failed"}
+
+	key, action, err := tracker.Upsert(ticket)
+	assert.NoError(t, err)
+	assert.Equal(t, "INC001", key)
+	assert.Equal(t, "created", action)
+	state = "7"
+	_, action, err = tracker.Upsert(ticket)
+	assert.NoError(t, err)
+	assert.Equal(t, "reopened", action)
+	assert.Equal(t, "1", update["state"])
+	assert.Contains(t, update["work_notes"], "failed")
+}
@@ -1,21 +1,77 @@
 package api
 
 import (
+	"net/http"
+	"runtime/pprof"
+
 	"github.com/angel-one/nbu-logger-service/constants"
-	"github.com/angel-one/nbu-logger-service/utils/flags"
+	"github.com/angel-one/nbu-logger-service/dedup"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/queue"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/angel-one/nbu-logger-service/utils/buildinfo"
+	"github.com/angel-one/nbu-logger-service/utils/configs"
 	"github.com/gin-gonic/gin"
 	goActuator "github.com/sinhashubham95/go-actuator"
 )
 
-var (
-	actuatorHandler = goActuator.GetActuatorHandler(&goActuator.Config{
-		Env:     flags.Env(),
+// actuator is used to get the actuator handler reporting the environment and the port of the process.
+// The build, features, config and goroutines endpoints are served here, the rest by go-actuator.
+func actuator(config RouterConfig) gin.HandlerFunc {
+	build := buildinfo.Get()
+	if goActuator.GitCommitID == "" {
+		goActuator.GitCommitID = build.Commit
+	}
+	if goActuator.BuildStamp == "" {
+		goActuator.BuildStamp = build.BuildTime
+	}
+	handler := goActuator.GetActuatorHandler(&goActuator.Config{
+		Env:     config.Env,
 		Name:    constants.ApplicationName,
-		Port:    flags.Port(),
-		Version: "",
+		Port:    config.Port,
+		Version: build.Version,
 	})
-)
+	return func(ctx *gin.Context) {
+		if ctx.Request.Method == http.MethodGet {
+			switch ctx.Param(constants.ActuatorEndpointParam) {
+			case constants.ActuatorBuildEndpoint:
+				ctx.JSON(http.StatusOK, build)
+				return
+			case constants.ActuatorFeaturesEndpoint:
+				ctx.JSON(http.StatusOK, features(config))
+				return
+			case constants.ActuatorConfigEndpoint:
+				ctx.JSON(http.StatusOK, gin.H{"checksums": configs.Checksums()})
+				return
+			case constants.ActuatorGoroutinesEndpoint:
+				goroutines(ctx)
+				return
+			}
+		}
+		handler(ctx.Writer, ctx.Request)
+	}
+}
+
+// features is used to get the state of the optional features of the process
+func features(config RouterConfig) gin.H {
+	applied := pipeline.Applied()
+	return gin.H{
+		"mode":           config.Mode,
+		"queue":          queue.Enabled(),
+		"region":         dedup.Region(),
+		"sinks":          sinks.Names(),
+		"minLevel":       applied.MinLevel,
+		"sensitive":      applied.Sensitive.Enabled,
+		"classification": len(applied.Classification.Keywords) > 0 || applied.Classification.Endpoint.URL != "",
+	}
+}
 
-func actuator(ctx *gin.Context) {
-	actuatorHandler(ctx.Writer, ctx.Request)
+// goroutines is used to write the full stacks of all the goroutines, in the format of an unrecovered panic.
+// The threadDump endpoint of go-actuator only has their counts by stack.
+func goroutines(ctx *gin.Context) {
+	ctx.Header("Content-Type", "text/plain; charset=utf-8")
+	ctx.Status(http.StatusOK)
+	if err := pprof.Lookup("goroutine").WriteTo(ctx.Writer, 2); err != nil {
+		ctx.Status(http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,360 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// adminKinds are the admin resource kinds exposed under /admin/{kind}
+var adminKinds = []string{
+	constants.RulesResourceKind,
+	constants.SchemasResourceKind,
+	constants.TypesResourceKind,
+	constants.TenantsResourceKind,
+	constants.KeysResourceKind,
+}
+
+// SetupAdminRoutes is used to set up the routes managing admin resources.
+// Reads carry an ETag and honor If-None-Match. Changes to existing resources need If-Match or the
+// version query param, they fail with 428 when both are missing and with 412 when they are stale.
+// To stay friendly to declarative tools, putting an unchanged spec and deleting a missing resource
+// succeed without a precondition, and POST on a collection creates a resource with a generated id.
+func SetupAdminRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	admin := router.Group(constants.AdminRoute, auth, limitBody)
+	for _, kind := range adminKinds {
+		collection := "/" + kind
+		item := collection + "/:" + constants.IDPathParam
+		admin.GET(collection, listResourcesHandler(kind))
+		admin.POST(collection, createResourceHandler(kind))
+		admin.GET(item, getResourceHandler(kind))
+		admin.PUT(item, putResourceHandler(kind))
+		admin.DELETE(item, deleteResourceHandler(kind))
+	}
+	admin.GET(constants.ConfigHistoryRoute, configHistoryHandler)
+	admin.POST(constants.ConfigRollbackRoute, configRollbackHandler)
+	admin.GET(constants.SensitiveProducersRoute, sensitiveProducersHandler)
+	admin.POST(constants.RulesSimulateRoute, simulateRulesHandler)
+	admin.POST(constants.RulesTestRoute, testRulesHandler)
+	admin.GET(constants.ValidationFailuresRoute, validationFailuresHandler)
+	admin.GET(constants.SamplingEscalationRoute, samplingEscalationHandler)
+	admin.GET(constants.StatsRoute, statsHandler)
+	admin.GET(constants.RejectedRequestsRoute, capturedRequestsHandler)
+	admin.GET(constants.CanariesRoute, canariesHandler)
+	admin.GET(constants.DeletedLogsRoute, deletedLogsHandler)
+	admin.POST(constants.DeleteLogsRoute, deleteLogsHandler)
+	admin.POST(constants.RestoreLogsRoute, restoreLogsHandler)
+	admin.GET(constants.DLQRoute, deadLettersHandler)
+	admin.POST(constants.DLQRequeueRoute, requeueDeadLettersHandler)
+	admin.POST(constants.DLQPurgeRoute, purgeDeadLettersHandler)
+	admin.GET(constants.ReportsRoute, reportsHandler)
+	admin.GET(constants.ReportRoute, previewReportHandler)
+	admin.POST(constants.ReportRunRoute, runReportHandler)
+	admin.GET(constants.SinksFairShareRoute, fairShareHandler)
+	admin.GET(constants.WALRoute, walHandler)
+	admin.GET(constants.ReplicationRoute, replicationStatusHandler)
+	admin.POST(constants.ReplicationPromoteRoute, promoteHandler)
+	admin.GET(constants.PanicsRoute, panicsHandler)
+	admin.GET(constants.InputCheckpointsRoute, checkpointsHandler)
+	admin.PUT(constants.InputCheckpointRoute, resetCheckpointHandler)
+}
+
+// testRulesHandler runs the sample entries through the applied pipeline with the candidate rules sections,
+// the response is 200 with passed set to false when a case fails and 422 when the candidate rules are invalid
+func testRulesHandler(c *gin.Context) {
+	var test pipeline.RulesTest
+	if err := c.ShouldBindJSON(&test); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	report, err := pipeline.RunRulesTest(c, pipeline.Applied(), test)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("%s: %s",
+			constants.RequestBodyValidationError, err)})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// simulateRulesHandler returns the impact the rules would have on recent traffic without applying them
+func simulateRulesHandler(c *gin.Context) {
+	spec, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	impact, err := pipeline.SimulateRules(c, c.Param(constants.IDPathParam), spec)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("%s: %s",
+			constants.RequestBodyValidationError, err)})
+		return
+	}
+	if impact == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("%s: no simulation for rules %s",
+			constants.ResourceNotFoundError, c.Param(constants.IDPathParam))})
+		return
+	}
+	c.JSON(http.StatusOK, impact)
+}
+
+// sensitiveProducersHandler returns the producers that sent unredacted sensitive data, the most offending first
+func sensitiveProducersHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"producers": pipeline.SensitiveReport()})
+}
+
+// configHistoryHandler returns the applied changes newest first, filtered by kind and id
+// and paged with the before param set to the smallest revision of the previous page
+func configHistoryHandler(c *gin.Context) {
+	query := registry.HistoryQuery{
+		Kind:  c.Query(constants.KindQueryParam),
+		ID:    c.Query(constants.IDQueryParam),
+		Limit: constants.DefaultQueryLimit,
+	}
+	if before := c.Query(constants.BeforeQueryParam); before != "" {
+		b, err := strconv.ParseInt(before, 10, 64)
+		if err != nil || b <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: before has to be a positive integer",
+				constants.QueryParamValidationError)})
+			return
+		}
+		query.Before = b
+	}
+	if limit := c.Query(constants.LimitQueryParam); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 || l > constants.MaxQueryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: limit has to be between 1 and %d",
+				constants.QueryParamValidationError, constants.MaxQueryLimit)})
+			return
+		}
+		query.Limit = l
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"revision": registry.Get().Revision(),
+		"changes":  registry.Get().History(query),
+	})
+}
+
+// configRollbackHandler restores every admin resource to its state right after the revision
+func configRollbackHandler(c *gin.Context) {
+	revision, err := strconv.ParseInt(c.Param(constants.VersionPathParam), 10, 64)
+	if err != nil || revision < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: version has to be a revision number",
+			constants.RequestValidationError)})
+		return
+	}
+	changes, err := registry.Get().Rollback(revision, c.GetHeader(constants.AuthorHeader))
+	if err != nil {
+		respondRegistryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"revision": registry.Get().Revision(), "changes": changes})
+}
+
+// adminAuth is the middleware letting through the requests bearing the admin token, the admin api is
+// forbidden when there is none
+func adminAuth(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": constants.AdminDisabledError})
+			return
+		}
+		if !hasToken(c, token) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": constants.UnauthorizedError})
+			return
+		}
+		c.Next()
+	}
+}
+
+// hasToken is used to check whether the request bears the token, never when the token is empty
+func hasToken(c *gin.Context, token string) bool {
+	presented := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return token != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+// listResourcesHandler returns the resources ordered by id, paged with the after param
+// set to the next id of the previous page
+func listResourcesHandler(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := constants.DefaultQueryLimit
+		if l := c.Query(constants.LimitQueryParam); l != "" {
+			var err error
+			limit, err = strconv.Atoi(l)
+			if err != nil || limit <= 0 || limit > constants.MaxQueryLimit {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: limit has to be between 1 and %d",
+					constants.QueryParamValidationError, constants.MaxQueryLimit)})
+				return
+			}
+		}
+		resources, next, err := registry.Get().ListPage(kind, c.Query(constants.AfterQueryParam), limit)
+		if err != nil {
+			respondRegistryError(c, err)
+			return
+		}
+		// the page tag changes whenever any of its resources changes
+		hash := sha256.New()
+		for _, resource := range resources {
+			hash.Write([]byte(resource.ID))
+			hash.Write([]byte(resource.ETag()))
+		}
+		hash.Write([]byte(next))
+		etag := fmt.Sprintf(`"%s"`, hex.EncodeToString(hash.Sum(nil)[:8]))
+		if notModified(c, etag) {
+			return
+		}
+		response := gin.H{"resources": resources}
+		if next != "" {
+			response["next"] = next
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// createResourceHandler creates a resource with a generated id, which is returned in the id field
+func createResourceHandler(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		spec, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+			return
+		}
+		resource, err := registry.Get().Put(kind, uuid.NewString(), spec, registry.Precondition{IfNoneMatch: "*"},
+			c.GetHeader(constants.AuthorHeader))
+		if err != nil {
+			respondRegistryError(c, err)
+			return
+		}
+		c.Header(constants.ETagHeader, resource.ETag())
+		c.Header(constants.LocationHeader, c.Request.URL.Path+"/"+resource.ID)
+		c.JSON(http.StatusCreated, resource)
+	}
+}
+
+func getResourceHandler(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resource, err := registry.Get().Get(kind, c.Param(constants.IDPathParam))
+		if err != nil {
+			respondRegistryError(c, err)
+			return
+		}
+		if notModified(c, resource.ETag()) {
+			return
+		}
+		c.JSON(http.StatusOK, resource)
+	}
+}
+
+func putResourceHandler(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		spec, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+			return
+		}
+		precondition, err := getPrecondition(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		id := c.Param(constants.IDPathParam)
+		if kind == constants.RulesResourceKind && c.Query(constants.OverrideQueryParam) != "true" {
+			// rules with a large impact on recent traffic are usually a bad pattern, they need an override
+			impact, err := pipeline.SimulateRules(c, id, spec)
+			if err != nil {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("%s: %s",
+					constants.RequestBodyValidationError, err)})
+				return
+			}
+			if impact != nil && impact.Blocked {
+				c.JSON(http.StatusConflict, gin.H{"error": constants.RedactionImpactError, "impact": impact})
+				return
+			}
+		}
+		_, err = registry.Get().Get(kind, id)
+		existed := err == nil
+		resource, err := registry.Get().Put(kind, id, spec, precondition, c.GetHeader(constants.AuthorHeader))
+		if err != nil {
+			respondRegistryError(c, err)
+			return
+		}
+		c.Header(constants.ETagHeader, resource.ETag())
+		status := http.StatusOK
+		if !existed && resource.Version == 1 {
+			status = http.StatusCreated
+		}
+		c.JSON(status, resource)
+	}
+}
+
+func deleteResourceHandler(kind string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		precondition, err := getPrecondition(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		err = registry.Get().Delete(kind, c.Param(constants.IDPathParam), precondition,
+			c.GetHeader(constants.AuthorHeader))
+		if err != nil && !errors.Is(err, registry.ErrNotFound) {
+			respondRegistryError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// getPrecondition is used to read the If-Match, If-None-Match and version precondition of a change
+func getPrecondition(c *gin.Context) (registry.Precondition, error) {
+	precondition := registry.Precondition{
+		IfMatch:     strings.TrimSpace(c.GetHeader(constants.IfMatchHeader)),
+		IfNoneMatch: strings.TrimSpace(c.GetHeader(constants.IfNoneMatchHeader)),
+	}
+	if version := c.Query(constants.VersionQueryParam); version != "" {
+		v, err := strconv.ParseInt(version, 10, 64)
+		if err != nil || v <= 0 {
+			return precondition, fmt.Errorf("%s: version has to be a positive integer",
+				constants.QueryParamValidationError)
+		}
+		precondition.Version = v
+	}
+	return precondition, nil
+}
+
+// notModified is used to set the ETag and respond with 304 if the client already has this version
+func notModified(c *gin.Context, etag string) bool {
+	c.Header(constants.ETagHeader, etag)
+	for _, tag := range strings.Split(c.GetHeader(constants.IfNoneMatchHeader), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+func respondRegistryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, registry.ErrNotFound), errors.Is(err, registry.ErrUnknownKind):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, registry.ErrPreconditionFailed):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+	case errors.Is(err, registry.ErrPreconditionRequired):
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": fmt.Sprintf("%s: %s",
+			constants.RequestBodyValidationError, err)})
+	}
+}
@@ -0,0 +1,154 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/limits"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuth(t *testing.T) {
+	serve := func(router http.Handler, method, path, token string) int {
+		request := httptest.NewRequest(method, path, strings.NewReader(`{}`))
+		request.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response.Code
+	}
+	registry.Get().RegisterKind(registry.Kind{Name: constants.TypesResourceKind, Validate: registry.ValidateObject})
+	collection := constants.AdminRoute + "/" + constants.TypesResourceKind
+	onboarding := constants.AdminRoute + constants.OnboardingRoute
+
+	// the admin api is forbidden without an admin token
+	router := api.GetRouter(api.RouterConfig{})
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, collection, ""))
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodPost, onboarding, "secret"))
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, collection, "secret"))
+
+	router = api.GetRouter(api.RouterConfig{AdminToken: "secret"})
+	assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodGet, collection, ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodGet, collection, "guess"))
+	assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodPut, collection+"/auth-order", ""))
+	assert.Equal(t, http.StatusOK, serve(router, http.MethodGet, collection, "secret"))
+	assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodPost, onboarding, ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodGet,
+		constants.AdminRoute+constants.GitOpsStatusRoute, ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(router, http.MethodPost,
+		constants.AdminRoute+constants.GitOpsSyncRoute, ""))
+	// the admin token does not read the stored entries
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, constants.LogsRoute, "secret"))
+	assert.Equal(t, http.StatusForbidden, serve(router, http.MethodGet, "/"+constants.APIVersionV1+constants.LogsRoute,
+		"secret"))
+}
+
+func TestAdminResources(t *testing.T) {
+	registry.Get().RegisterKind(registry.Kind{Name: constants.TypesResourceKind, Validate: registry.ValidateObject})
+	router := api.GetRouter(api.RouterConfig{AdminToken: "secret"})
+	serve := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "Bearer secret")
+		request.Header.Set(constants.AuthorHeader, "alice")
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+	decode := func(response *httptest.ResponseRecorder) registry.Resource {
+		var resource registry.Resource
+		assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &resource))
+		return resource
+	}
+	collection := constants.AdminRoute + "/" + constants.TypesResourceKind
+	item := collection + "/crud-order"
+
+	response := serve(http.MethodPut, item, `{"owner":"payments"}`, nil)
+	assert.Equal(t, http.StatusCreated, response.Code)
+	created := decode(response)
+	assert.Equal(t, int64(1), created.Version)
+	assert.Equal(t, "alice", created.UpdatedBy)
+	assert.Equal(t, created.ETag(), response.Header().Get(constants.ETagHeader))
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPut, collection+"/crud-bad", `[1]`, nil).Code)
+
+	// reads carry the tag and answer 304 when the client already has it
+	response = serve(http.MethodGet, item, "", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, created.ETag(), response.Header().Get(constants.ETagHeader))
+	response = serve(http.MethodGet, item, "", map[string]string{constants.IfNoneMatchHeader: created.ETag()})
+	assert.Equal(t, http.StatusNotModified, response.Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, collection+"/crud-missing", "", nil).Code)
+
+	// changes need the current version and fail once it is stale
+	updated := `{"owner":"refunds"}`
+	assert.Equal(t, http.StatusPreconditionRequired, serve(http.MethodPut, item, updated, nil).Code)
+	response = serve(http.MethodPut, item, updated, map[string]string{constants.IfMatchHeader: `"9-stale"`})
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+	response = serve(http.MethodPut, item, updated, map[string]string{constants.IfMatchHeader: created.ETag()})
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, int64(2), decode(response).Version)
+	// the writer that read the first version loses
+	response = serve(http.MethodPut, item, `{"owner":"orders"}`,
+		map[string]string{constants.IfMatchHeader: created.ETag()})
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+	response = serve(http.MethodPut, item+"?version=1", `{"owner":"orders"}`, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, response.Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPut, item+"?version=first", updated, nil).Code)
+	// resending the same spec is a no-op that needs no precondition
+	response = serve(http.MethodPut, item, updated, nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, int64(2), decode(response).Version)
+
+	// the collection creates resources with a generated id, which are paged in id order
+	response = serve(http.MethodPost, collection, `{"owner":"risk"}`, nil)
+	assert.Equal(t, http.StatusCreated, response.Code)
+	generated := decode(response)
+	assert.NotEmpty(t, generated.ID)
+	assert.Equal(t, collection+"/"+generated.ID, response.Header().Get(constants.LocationHeader))
+	var page struct {
+		Resources []registry.Resource `json:"resources"`
+		Next      string              `json:"next"`
+	}
+	response = serve(http.MethodGet, collection+"?limit=1", "", nil)
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &page))
+	assert.Len(t, page.Resources, 1)
+	assert.NotEmpty(t, page.Next)
+	response = serve(http.MethodGet, collection, "", map[string]string{
+		constants.IfNoneMatchHeader: serve(http.MethodGet, collection, "", nil).Header().Get(constants.ETagHeader)})
+	assert.Equal(t, http.StatusNotModified, response.Code)
+
+	// deletes follow the same preconditions and are idempotent
+	assert.Equal(t, http.StatusPreconditionRequired, serve(http.MethodDelete, item, "", nil).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, serve(http.MethodDelete, item+"?version=1", "", nil).Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, item+"?version=2", "", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, item, "", nil).Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, item, "", nil).Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, collection+"/"+generated.ID, "",
+		map[string]string{constants.IfMatchHeader: "*"}).Code)
+}
+
+func TestAdminBodyLimit(t *testing.T) {
+	limits.Init(limits.Config{MaxBodyBytes: 16})
+	defer limits.Init(limits.Config{})
+	router := api.GetRouter(api.RouterConfig{AdminToken: "secret"})
+	for _, path := range []string{constants.AdminRoute + constants.OnboardingRoute,
+		constants.AdminRoute + constants.RulesTestRoute, constants.LogsDecryptRoute} {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"name":"`+strings.Repeat("a", 64)+`"}`))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("Authorization", "Bearer secret")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, response.Code, path)
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/api/grpc"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/limits"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// loggerBatchHandler ingests a json array of entries, or the entries of a BatchLogRequest message of logger.proto
+// for protobuf bodies. Every entry is validated and ingested on its own, so an invalid entry is reported with its
+// index and does not fail the others.
+func loggerBatchHandler(c *gin.Context) {
+	batch, bind, err := bindBatch(c)
+	if err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	if max := limits.Get().MaxBatchEntries(); len(batch) > max {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%s: %d, at most %d",
+			constants.BatchTooLargeError, len(batch), max)})
+		return
+	}
+	accepted, rejected, duplicates := 0, 0, 0
+	var failures []string
+	tenant, key := idempotencyKey(c)
+	for i, body := range batch {
+		entry, err := bind(body)
+		if err != nil {
+			rejected++
+			failures = append(failures, fmt.Sprintf("entry %d: %s: %s", i, constants.RequestBodyBindError, err))
+			continue
+		}
+		duplicate, err := ingestPart(c, tenant, key, i, &entry)
+		if duplicate {
+			duplicates++
+			continue
+		}
+		if err != nil {
+			rejected++
+			failures = append(failures, fmt.Sprintf("entry %d: %s", i, err))
+			continue
+		}
+		accepted++
+	}
+	c.JSON(http.StatusOK, gin.H{"accepted": accepted, "rejected": rejected, "duplicates": duplicates,
+		"errors": failures})
+}
+
+// bindBatch is used to read the encoded entries of the batch and get the function decoding each of them
+func bindBatch(c *gin.Context) ([][]byte, func([]byte) (models.LogEntry, error), error) {
+	if c.ContentType() == constants.ProtobufMediaType {
+		body, err := c.GetRawData()
+		if err != nil {
+			return nil, nil, err
+		}
+		batch, err := grpc.DecodeBatch(body)
+		return batch, decodeProtobufEntry, err
+	}
+	var raw []json.RawMessage
+	if err := c.ShouldBindJSON(&raw); err != nil {
+		return nil, nil, err
+	}
+	batch := make([][]byte, len(raw))
+	for i, body := range raw {
+		batch[i] = body
+	}
+	return batch, func(body []byte) (models.LogEntry, error) {
+		var entry models.LogEntry
+		err := binding.JSON.BindBody(body, &entry)
+		return entry, err
+	}, nil
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/canary"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/gin-gonic/gin"
+)
+
+// withCanary is used to get the handler sending the weight of the canary to the candidate,
+// the figures of both implementations are kept for comparison
+func withCanary(name string, primary, candidate gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		implementation, handler := constants.CanaryPrimary, primary
+		if canary.Candidate(name) {
+			implementation, handler = constants.CanaryCandidate, candidate
+		}
+		start := time.Now()
+		handler(c)
+		canary.Record(name, implementation, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// canariesHandler returns the figures of the implementations of every canary
+func canariesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"canaries": canary.Reports()})
+}
+
+var bodyBuffers = sync.Pool{New: func() interface{} {
+	return new(bytes.Buffer)
+}}
+
+// loggerPooledHandler is the candidate of loggerHandler reading json bodies into pooled buffers instead of
+// allocating one per request, the other bodies are handled by loggerHandler
+func loggerPooledHandler(c *gin.Context) {
+	if c.ContentType() != constants.JSONMediaType || formats.IsCloudEventBinary(c.Request.Header) {
+		loggerHandler(c)
+		return
+	}
+	ingestLogEntry(c, func(c *gin.Context) (models.LogEntry, error) {
+		buffer := bodyBuffers.Get().(*bytes.Buffer)
+		buffer.Reset()
+		defer func() {
+			// buffers grown by large bodies are left to the garbage collector
+			if buffer.Cap() <= constants.MaxPooledBodyBytes {
+				bodyBuffers.Put(buffer)
+			}
+		}()
+		if _, err := io.Copy(buffer, c.Request.Body); err != nil {
+			return models.LogEntry{}, err
+		}
+		return decodeJSONEntry(c, buffer.Bytes())
+	})
+}
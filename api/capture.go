@@ -0,0 +1,76 @@
+package api
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/capture"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/gin-gonic/gin"
+)
+
+// limitedBuffer keeps the first bytes written to it
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(data []byte) (int, error) {
+	if room := b.limit - b.Len(); room < len(data) {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(data[:room])
+		}
+		return len(data), nil
+	}
+	return b.Buffer.Write(data)
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type captureWriter struct {
+	gin.ResponseWriter
+	body *limitedBuffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	_, _ = w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	_, _ = w.body.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// captureRejected is the middleware keeping the requests answered with a client error along with the response,
+// so that the reason can be looked up later
+func captureRejected(c *gin.Context) {
+	body := &limitedBuffer{limit: constants.MaxCaptureBodyBytes}
+	c.Request.Body = teeReadCloser{Reader: io.TeeReader(c.Request.Body, body), Closer: c.Request.Body}
+	writer := &captureWriter{ResponseWriter: c.Writer, body: &limitedBuffer{limit: constants.MaxCaptureBodyBytes}}
+	c.Writer = writer
+	c.Next()
+	status := writer.Status()
+	if status < http.StatusBadRequest || status >= http.StatusInternalServerError {
+		return
+	}
+	tenant, _ := tenants.FromContext(c)
+	capture.Get().Add(capture.Request{
+		Time: time.Now(), Method: c.Request.Method, Path: c.Request.URL.Path, Query: c.Request.URL.RawQuery,
+		Tenant: tenant, Status: status, Truncated: body.truncated,
+	}, c.Request.Header, body.Bytes(), writer.body.Bytes())
+}
+
+// capturedRequestsHandler returns the latest rejected requests, like the rest of the admin api it needs the
+// admin token
+func capturedRequestsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"requests": capture.Get().List()})
+}
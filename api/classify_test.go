@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerClassification(t *testing.T) {
+	model := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		if strings.Contains(request.Text, "declined") {
+			_ = json.NewEncoder(w).Encode(map[string][]string{"categories": {"business-error", "timeout"}})
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer model.Close()
+	assert.NoError(t, pipeline.Init(pipeline.Config{Classification: pipeline.ClassificationConfig{
+		Keywords:     []pipeline.KeywordRule{{Category: "timeout", Keywords: []string{"timed out"}}},
+		Endpoint:     pipeline.EndpointClassifierConfig{URL: model.URL},
+		DetectScript: true,
+	}}))
+	defer func() { _ = pipeline.Init(pipeline.Config{}) }()
+	store.Init(store.NewMemory(100))
+	router := api.GetRouter(api.RouterConfig{})
+	post := func(message string) (int, models.LogEntry) {
+		body, _ := json.Marshal(models.LogEntry{Type: "payment", Data: map[string]interface{}{"message": message}})
+		request := httptest.NewRequest(http.MethodPost, constants.LoggerRoute, strings.NewReader(string(body)))
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		var entry models.LogEntry
+		assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &entry))
+		return response.Code, entry
+	}
+
+	// the categories of the keywords and of the model are merged without duplicates
+	status, entry := post("card declined, gateway timed out")
+	assert.Equal(t, http.StatusOK, status)
+	categories, _ := pipeline.GetMeta(&entry, constants.MetaCategoriesKey)
+	assert.Equal(t, []interface{}{"business-error", "timeout"}, categories)
+	script, _ := pipeline.GetMeta(&entry, constants.MetaScriptKey)
+	assert.Equal(t, "Latin", script)
+
+	// the model failing does not reject the entry, the keywords still classify it
+	status, entry = post("upstream timed out")
+	assert.Equal(t, http.StatusOK, status)
+	categories, _ = pipeline.GetMeta(&entry, constants.MetaCategoriesKey)
+	assert.Equal(t, []interface{}{"timeout"}, categories)
+
+	status, entry = post("भुगतान सफल")
+	assert.Equal(t, http.StatusOK, status)
+	_, ok := pipeline.GetMeta(&entry, constants.MetaCategoriesKey)
+	assert.False(t, ok)
+	script, _ = pipeline.GetMeta(&entry, constants.MetaScriptKey)
+	assert.Equal(t, "Devanagari", script)
+}
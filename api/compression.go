@@ -0,0 +1,183 @@
+package api
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// supportedEncodings are in the order of preference when the client weighs them equally
+var supportedEncodings = []string{constants.GzipEncoding, constants.ZstdEncoding, constants.DeflateEncoding}
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	}}
+	flateWriters = sync.Pool{New: func() interface{} {
+		w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+		return w
+	}}
+	// a response is written from a single goroutine, so the encoders do not need their own
+	zstdWriters = sync.Pool{New: func() interface{} {
+		w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+		return w
+	}}
+)
+
+// compressWriter holds the start of the body back until it reaches the minimum size, so the small responses are
+// sent as they are, and compresses the rest of it
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	minSize  int
+	buffer   []byte
+	// writer is the compressor, nil until the body is compressed
+	writer interface {
+		io.WriteCloser
+		Flush() error
+		Reset(io.Writer)
+	}
+	// passthrough is set when the handler encoded the body itself, or sent the headers before it
+	passthrough bool
+}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.writer != nil {
+		return w.writer.Write(data)
+	}
+	if w.passthrough || w.Header().Get("Content-Encoding") != "" {
+		w.passthrough = true
+		return w.ResponseWriter.Write(data)
+	}
+	w.buffer = append(w.buffer, data...)
+	if len(w.buffer) >= w.minSize {
+		if err := w.compress(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	w.ResponseWriter.WriteHeaderNow()
+	// the headers sent before the body can not announce the encoding anymore, so the body is sent as it is
+	if w.writer == nil && !w.passthrough {
+		w.passthrough = true
+		buffered := w.buffer
+		w.buffer = nil
+		_, _ = w.ResponseWriter.Write(buffered)
+	}
+}
+
+func (w *compressWriter) Flush() {
+	// streamed bodies are compressed as they are flushed, whatever their size
+	if w.writer == nil && len(w.buffer) > 0 {
+		_ = w.compress()
+	}
+	if w.writer != nil {
+		_ = w.writer.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// compress is used to start compressing the body with the buffered start of it
+func (w *compressWriter) compress() error {
+	switch w.encoding {
+	case constants.GzipEncoding:
+		w.writer = gzipWriters.Get().(*gzip.Writer)
+	case constants.DeflateEncoding:
+		w.writer = flateWriters.Get().(*flate.Writer)
+	case constants.ZstdEncoding:
+		w.writer = zstdWriters.Get().(*zstd.Encoder)
+	}
+	w.writer.Reset(w.ResponseWriter)
+	w.Header().Set("Content-Encoding", w.encoding)
+	// the length of the compressed body is not known upfront
+	w.Header().Del("Content-Length")
+	buffered := w.buffer
+	w.buffer = nil
+	_, err := w.writer.Write(buffered)
+	return err
+}
+
+// close is used to end the compressed body, or to send the body held back as it is when it stayed small
+func (w *compressWriter) close() {
+	if w.writer == nil {
+		if len(w.buffer) > 0 {
+			_, _ = w.ResponseWriter.Write(w.buffer)
+		}
+		return
+	}
+	_ = w.writer.Close()
+	switch writer := w.writer.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(writer)
+	case *flate.Writer:
+		flateWriters.Put(writer)
+	case *zstd.Encoder:
+		zstdWriters.Put(writer)
+	}
+}
+
+// compression is the middleware compressing the responses of at least minSize bytes with the encoding negotiated
+// via Accept-Encoding
+func compression(minSize int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(c.GetHeader("Accept-Encoding"))
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+		writer := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, minSize: minSize}
+		c.Writer = writer
+		defer writer.close()
+		c.Next()
+	}
+}
+
+// negotiateEncoding is used to pick the supported encoding with the highest weight, empty means no compression
+func negotiateEncoding(header string) string {
+	weights := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		weight := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil {
+				weight = parsed
+			}
+		}
+		weights[name] = weight
+	}
+	best, bestWeight := "", 0.0
+	for _, encoding := range supportedEncodings {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight, ok = weights["*"]
+		}
+		if ok && weight > bestWeight {
+			best, bestWeight = encoding, weight
+		}
+	}
+	if identity, ok := weights[constants.IdentityEncoding]; ok && identity > bestWeight {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,119 @@
+package api_test
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryCompression(t *testing.T) {
+	s := store.NewMemory(100)
+	store.Init(s)
+	for i := 0; i < 20; i++ {
+		entryType := "order"
+		if i%4 == 0 {
+			entryType = "refund"
+		}
+		_, err := s.Add(context.Background(), models.LogEntry{Type: entryType,
+			Data: map[string]interface{}{"message": "order placed", "sequence": i}})
+		assert.NoError(t, err)
+	}
+	router := api.GetRouter(api.RouterConfig{ReaderToken: "reader"})
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.Header.Set("Authorization", "Bearer reader")
+		if acceptEncoding != "" {
+			request.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+	decode := func(response *httptest.ResponseRecorder) []byte {
+		var reader io.Reader = response.Body
+		switch response.Header().Get("Content-Encoding") {
+		case constants.GzipEncoding:
+			gz, err := gzip.NewReader(reader)
+			assert.NoError(t, err)
+			reader = gz
+		case constants.DeflateEncoding:
+			reader = flate.NewReader(reader)
+		case constants.ZstdEncoding:
+			zr, err := zstd.NewReader(reader)
+			assert.NoError(t, err)
+			defer zr.Close()
+			reader = zr
+		}
+		body, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		return body
+	}
+
+	// the encoding is negotiated by weight
+	for acceptEncoding, expected := range map[string]string{
+		"":                          "",
+		"gzip":                      constants.GzipEncoding,
+		"deflate":                   constants.DeflateEncoding,
+		"zstd":                      constants.ZstdEncoding,
+		"gzip;q=0.5, deflate":       constants.DeflateEncoding,
+		"gzip, deflate":             constants.GzipEncoding,
+		"gzip;q=0.9, zstd":          constants.ZstdEncoding,
+		"deflate, zstd":             constants.ZstdEncoding,
+		"*":                         constants.GzipEncoding,
+		"gzip;q=0":                  "",
+		"br":                        "",
+		"identity, gzip;q=0.5":      "",
+		"GZIP;q=0.8, identity;q=.1": constants.GzipEncoding,
+	} {
+		response := get(constants.LogsRoute, acceptEncoding)
+		assert.Equal(t, http.StatusOK, response.Code, acceptEncoding)
+		assert.Equal(t, expected, response.Header().Get("Content-Encoding"), acceptEncoding)
+		assert.Equal(t, "Accept-Encoding", response.Header().Get("Vary"), acceptEncoding)
+		var page struct {
+			Entries []store.Record `json:"entries"`
+		}
+		assert.NoError(t, json.Unmarshal(decode(response), &page), acceptEncoding)
+		assert.Len(t, page.Entries, 20, acceptEncoding)
+	}
+
+	// the stats are aggregated over the matching records
+	var stats store.Stats
+	response := get(constants.LogsStatsRoute+"?type=refund", "zstd")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.NoError(t, json.Unmarshal(decode(response), &stats))
+	assert.Equal(t, store.Stats{Total: 5, ByType: map[string]int{"refund": 5}}, stats)
+	response = get(constants.LogsStatsRoute, "")
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &stats))
+	assert.Equal(t, store.Stats{Total: 20, ByType: map[string]int{"order": 15, "refund": 5}}, stats)
+
+	// the export streams a record a line, compressed as it is flushed
+	response = get(constants.LogsExportRoute+"?type=order", "gzip")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, constants.GzipEncoding, response.Header().Get("Content-Encoding"))
+	assert.Equal(t, constants.NDJSONMediaType, response.Header().Get("Content-Type"))
+	lines := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(decode(response))))
+	for scanner.Scan() {
+		var record store.Record
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		assert.Equal(t, "order", record.Entry.Type)
+		lines++
+	}
+	assert.Equal(t, 15, lines)
+	response = get(constants.LogsExportRoute, "")
+	assert.Equal(t, 20, strings.Count(response.Body.String(), "\n"))
+}
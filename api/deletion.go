@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/gin-gonic/gin"
+)
+
+// deletionRequest selects the entries to soft delete or restore, at least one filter is required
+type deletionRequest struct {
+	IDs    []uint64          `json:"ids"`
+	Type   string            `json:"type"`
+	Labels map[string]string `json:"labels"`
+	// Filter is a filter expression the entries have to match
+	Filter string `json:"filter"`
+}
+
+// deletedLogsHandler returns the soft deleted entries matching the filters, they can still be restored
+func deletedLogsHandler(c *gin.Context) {
+	query, err := getStoreQuery(c, constants.DefaultQueryLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	query.Deleted = true
+	records, err := store.Get().Query(c, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": records})
+}
+
+// deleteLogsHandler soft deletes the matching entries, they are hidden from queries and purged after the grace
+// period unless restored
+func deleteLogsHandler(c *gin.Context) {
+	query, ok := getDeletionQuery(c)
+	if !ok {
+		return
+	}
+	deleted, err := store.Get().Delete(c, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Info(c).Int("deleted", deleted).Str("type", query.Type).Msg("soft deleted entries")
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted})
+}
+
+// restoreLogsHandler restores the matching soft deleted entries that were not purged yet
+func restoreLogsHandler(c *gin.Context) {
+	query, ok := getDeletionQuery(c)
+	if !ok {
+		return
+	}
+	restored, err := store.Get().Restore(c, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	log.Info(c).Int("restored", restored).Str("type", query.Type).Msg("restored entries")
+	c.JSON(http.StatusOK, gin.H{"restored": restored})
+}
+
+// getDeletionQuery is used to get the store query of the deletion request, the response is written when invalid
+func getDeletionQuery(c *gin.Context) (store.Query, bool) {
+	var request deletionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return store.Query{}, false
+	}
+	if len(request.IDs) == 0 && request.Type == "" && len(request.Labels) == 0 && request.Filter == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyValidationError,
+			constants.UnfilteredDeletionError)})
+		return store.Query{}, false
+	}
+	f, err := filter.Parse(request.Filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyValidationError, err)})
+		return store.Query{}, false
+	}
+	return store.Query{IDs: request.IDs, Type: request.Type, Labels: request.Labels, Filter: f}, true
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/gin-gonic/gin"
+)
+
+// deliveryClass is the middleware handling the entries of the request with the delivery class of the
+// X-Delivery-Class header, so cheap debug entries skip the write ahead log and audit entries are only
+// acknowledged once durable. The class applied is told in the response.
+func deliveryClass(c *gin.Context) {
+	class := c.GetHeader(constants.DeliveryClassHeader)
+	if class == "" {
+		c.Next()
+		return
+	}
+	if !ingest.ValidDeliveryClass(class) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": constants.InvalidDeliveryClassError,
+			"deliveryClass": class})
+		return
+	}
+	c.Request = c.Request.WithContext(ingest.WithDeliveryClass(c.Request.Context(), class))
+	c.Header(constants.DeliveryClassHeader, class)
+	c.Next()
+}
@@ -0,0 +1,111 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/dlq"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/angel-one/nbu-logger-service/wal"
+	"github.com/gin-gonic/gin"
+)
+
+// deadLettersRequest selects the dead letters to requeue or purge, by their ids, their sink or both
+type deadLettersRequest struct {
+	IDs  []string `json:"ids"`
+	Sink string   `json:"sink"`
+}
+
+// deadLettersHandler returns the latest entries the sinks failed to deliver, optionally of one sink
+func deadLettersHandler(c *gin.Context) {
+	filter := dlq.Filter{Sink: c.Query(constants.SinkQueryParam), Limit: constants.DefaultQueryLimit}
+	if limit := c.Query(constants.LimitQueryParam); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 || l > constants.MaxQueryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: limit has to be between 1 and %d",
+				constants.QueryParamValidationError, constants.MaxQueryLimit)})
+			return
+		}
+		filter.Limit = l
+	}
+	letters, err := dlq.Get().List(c, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"letters": letters})
+}
+
+// requeueDeadLettersHandler queues the selected dead letters for their sinks again, the letters are dropped
+// once queued and the ones that could not be queued are kept and reported
+func requeueDeadLettersHandler(c *gin.Context) {
+	letters, ok := getDeadLetters(c)
+	if !ok {
+		return
+	}
+	var requeued []string
+	failed := make(map[string]string)
+	for _, letter := range letters {
+		if err := sinks.Requeue(c, letter.Sink, letter.Entry); err != nil {
+			failed[letter.ID] = err.Error()
+			continue
+		}
+		requeued = append(requeued, letter.ID)
+	}
+	if _, err := dlq.Get().Remove(c, requeued); err != nil {
+		// the entries are queued, so the letters would only be delivered twice if requeued again
+		log.Error(c).Err(err).Msg("error dropping requeued dead letters")
+	}
+	c.JSON(http.StatusOK, gin.H{"requeued": len(requeued), "failed": failed})
+}
+
+// purgeDeadLettersHandler drops the selected dead letters for good
+func purgeDeadLettersHandler(c *gin.Context) {
+	letters, ok := getDeadLetters(c)
+	if !ok {
+		return
+	}
+	ids := make([]string, 0, len(letters))
+	for _, letter := range letters {
+		ids = append(ids, letter.ID)
+	}
+	purged, err := dlq.Get().Remove(c, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": purged})
+}
+
+// getDeadLetters is used to get the dead letters the request selects, the response is written when it fails
+func getDeadLetters(c *gin.Context) ([]dlq.Letter, bool) {
+	var request deadLettersRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return nil, false
+	}
+	if len(request.IDs) == 0 && request.Sink == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyValidationError,
+			constants.UnfilteredDLQError)})
+		return nil, false
+	}
+	letters, err := dlq.Get().List(c, dlq.Filter{IDs: request.IDs, Sink: request.Sink})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return letters, true
+}
+
+// fairShareHandler returns how every tenant is getting through the buffer of every sink, to spot the starved ones
+func fairShareHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"sinks": sinks.FairShare()})
+}
+
+// walHandler returns the counters of the write ahead log, a growing size tells the entries are not delivered
+func walHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, wal.GetStats())
+}
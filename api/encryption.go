@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/encryption"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupDecryptRoutes is used to set up the route decrypting stored entries. It is not behind the reader token, as
+// the readers of the keys authenticate with their own tokens, but the reads are still scoped to a tenant.
+func SetupDecryptRoutes(router *gin.Engine, scope gin.HandlerFunc) {
+	router.POST(constants.LogsDecryptRoute, scope, limitBody, decryptLogsHandler)
+}
+
+// decryptionRequest selects the encrypted entries to decrypt with the key, the key is only held for the request
+type decryptionRequest struct {
+	KeyID string `json:"keyId" binding:"required"`
+	// Key is the base64 encoded key the entries were encrypted with
+	Key []byte   `json:"key" binding:"required"`
+	IDs []uint64 `json:"ids" binding:"required"`
+}
+
+// decryptLogsHandler returns the entries of the ids decrypted with the key, for a reader of the key presenting
+// its bearer token. The entries that can not be decrypted are listed in errors by id, and every decryption is
+// logged for the audit.
+func decryptLogsHandler(c *gin.Context) {
+	var request decryptionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	enc := encryption.Get()
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !enc.Reader(request.KeyID, token) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": constants.UnauthorizedError})
+		return
+	}
+	records, err := store.Get().Query(c, store.Query{IDs: request.IDs, Tenant: tenants.Scope(c),
+		Limit: len(request.IDs)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	decrypted := make([]store.Record, 0, len(records))
+	failed := make(map[string]string)
+	for _, id := range request.IDs {
+		failed[strconv.FormatUint(id, 10)] = constants.ResourceNotFoundError
+	}
+	for _, record := range records {
+		id := strconv.FormatUint(record.ID, 10)
+		delete(failed, id)
+		if record.Entry.Encrypted == nil || record.Entry.Encrypted.KeyID != request.KeyID {
+			failed[id] = fmt.Sprintf("%s: not encrypted with the key", encryption.ErrDecryption)
+			continue
+		}
+		if record.Entry, err = enc.Decrypt(record.Entry, token, request.Key); err != nil {
+			failed[id] = err.Error()
+			continue
+		}
+		decrypted = append(decrypted, record)
+	}
+	log.Info(c).Str(constants.KeyIDKey, request.KeyID).Int("requested", len(request.IDs)).
+		Int("decrypted", len(decrypted)).Msg("decrypted entries")
+	// the plaintext must not be kept by the proxies on the way
+	c.Header("Cache-Control", "no-store, max-age=0")
+	c.JSON(http.StatusOK, gin.H{"entries": decrypted, "errors": failed})
+}
@@ -0,0 +1,89 @@
+package api_test
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/encryption"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogsDecrypt(t *testing.T) {
+	assert.NoError(t, encryption.Init(encryption.Config{
+		Keys: []encryption.KeyConfig{{ID: "cards", Readers: []string{"auditor"}}}}))
+	defer func() { _ = encryption.Init(encryption.Config{}) }()
+	key := make([]byte, constants.EnvelopeKeySize)
+	_, _ = rand.Read(key)
+	block, err := aes.NewCipher(key)
+	assert.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	_, _ = rand.Read(nonce)
+
+	s := store.NewMemory(100)
+	store.Init(s)
+	encrypted, err := s.Add(context.Background(), models.LogEntry{Type: "payment", Level: constants.InfoLevel,
+		Data: map[string]interface{}{"region": "south"},
+		Encrypted: &models.Envelope{KeyID: "cards", Algorithm: constants.EnvelopeAlgorithm, Nonce: nonce,
+			Ciphertext: gcm.Seal(nil, nonce, []byte(`{"card":"4111"}`), []byte("payment"))}})
+	assert.NoError(t, err)
+	plaintext, err := s.Add(context.Background(), models.LogEntry{Type: "app", Data: map[string]interface{}{"a": 1}})
+	assert.NoError(t, err)
+	router := api.GetRouter(api.RouterConfig{})
+	decrypt := func(token string, key []byte, ids ...uint64) (*httptest.ResponseRecorder, map[string]interface{}) {
+		body, _ := json.Marshal(map[string]interface{}{"keyId": "cards", "key": key, "ids": ids})
+		request := httptest.NewRequest(http.MethodPost, constants.LogsDecryptRoute, strings.NewReader(string(body)))
+		request.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		var result map[string]interface{}
+		assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+		return response, result
+	}
+
+	// only the readers of the key can decrypt
+	response, _ := decrypt("", key, encrypted.ID)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response, _ = decrypt("someone", key, encrypted.ID)
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	response, result := decrypt("auditor", key, encrypted.ID, plaintext.ID, 999)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "no-store, max-age=0", response.Header().Get("Cache-Control"))
+	var entries []store.Record
+	raw, _ := json.Marshal(result["entries"])
+	assert.NoError(t, json.Unmarshal(raw, &entries))
+	if assert.Len(t, entries, 1) {
+		assert.Nil(t, entries[0].Entry.Encrypted)
+		assert.Equal(t, map[string]interface{}{"card": "4111", "region": "south"}, entries[0].Entry.Data)
+	}
+	failed := result["errors"].(map[string]interface{})
+	assert.Len(t, failed, 2)
+	assert.Contains(t, failed[strconv.FormatUint(plaintext.ID, 10)], "not encrypted with the key")
+	assert.Equal(t, constants.ResourceNotFoundError, failed["999"])
+
+	// a wrong key is reported per entry
+	response, result = decrypt("auditor", make([]byte, constants.EnvelopeKeySize), encrypted.ID)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Empty(t, result["entries"])
+	assert.Contains(t, result["errors"], strconv.FormatUint(encrypted.ID, 10))
+
+	response, _ = decrypt("auditor", key)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+}
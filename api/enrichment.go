@@ -0,0 +1,18 @@
+package api
+
+import (
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/gin-gonic/gin"
+)
+
+// enrichClient is the middleware telling the pipeline the client ip and the name of the api key of the request,
+// which the enrichment adds to the entries
+func enrichClient(c *gin.Context) {
+	client := pipeline.Client{IP: c.ClientIP()}
+	if key := apiKey(c); key != "" {
+		client.KeyName, _ = tenants.KeyName(key)
+	}
+	c.Request = c.Request.WithContext(pipeline.WithClient(c.Request.Context(), client))
+	c.Next()
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/gitops"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupGitOpsRoutes is used to set up the routes reporting and triggering the git sync. With a webhook secret
+// the sync is triggered by the signed push webhooks of the config repository, which can not bear the admin token.
+func SetupGitOpsRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	admin := router.Group(constants.AdminRoute, limitBody)
+	admin.GET(constants.GitOpsStatusRoute, auth, gitOpsStatusHandler)
+	if gitops.Get().WebhookSecret() != "" {
+		admin.POST(constants.GitOpsSyncRoute, gitOpsSyncHandler)
+		return
+	}
+	admin.POST(constants.GitOpsSyncRoute, auth, gitOpsSyncHandler)
+}
+
+func gitOpsStatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gitops.Get().Status())
+}
+
+// gitOpsSyncHandler triggers a sync, it can be used as a push webhook of the config repository.
+// When a webhook secret is configured the body has to be signed as in X-Hub-Signature-256.
+func gitOpsSyncHandler(c *gin.Context) {
+	syncer := gitops.Get()
+	if secret := syncer.WebhookSecret(); secret != "" {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil || !validSignature(secret, body, c.GetHeader(constants.GitHubSignatureHeader)) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": constants.InvalidWebhookSignatureError})
+			return
+		}
+	}
+	if err := syncer.Trigger(); err != nil {
+		if errors.Is(err, gitops.ErrDisabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, syncer.Status())
+}
+
+func validSignature(secret string, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, constants.GitHubSignaturePrefix) {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, constants.GitHubSignaturePrefix))
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/grafana"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupGrafanaRoutes is used to set up the routes of the grafana simple json datasource, behind the auth
+// middlewares of the query routes
+func SetupGrafanaRoutes(router *gin.Engine, auth ...gin.HandlerFunc) {
+	grafana := router.Group("", append(auth, limitBody)...)
+	grafana.GET(constants.GrafanaRoute, grafanaTestHandler)
+	grafana.POST(constants.GrafanaSearchRoute, grafanaSearchHandler)
+	grafana.POST(constants.GrafanaQueryRoute, grafanaQueryHandler)
+	grafana.POST(constants.GrafanaAnnotationsRoute, grafanaAnnotationsHandler)
+	grafana.POST(constants.GrafanaTagKeysRoute, grafanaTagKeysHandler)
+	grafana.POST(constants.GrafanaTagValuesRoute, grafanaTagValuesHandler)
+}
+
+// grafanaTestHandler answers the connection test of the datasource
+func grafanaTestHandler(c *gin.Context) {
+	c.Status(http.StatusOK)
+}
+
+// grafanaSearchHandler returns the targets suggested in the query editor, a filter by type of the stored entries
+func grafanaSearchHandler(c *gin.Context) {
+	var request struct {
+		Target string `json:"target"`
+	}
+	// grafana posts an empty body when nothing was typed yet
+	_ = c.ShouldBindJSON(&request)
+	targets, err := grafana.Search(c, request.Target)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, targets)
+}
+
+// grafanaQueryHandler returns the counts of the entries matching the filter expressions of the targets by
+// interval, or the latest entries for the table targets, along with the ad hoc filters of the dashboard
+func grafanaQueryHandler(c *gin.Context) {
+	var request grafana.QueryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	results, err := grafana.Query(c, request)
+	if err != nil {
+		grafanaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// grafanaAnnotationsHandler returns the entries matching the filter expression of the annotation as annotations
+func grafanaAnnotationsHandler(c *gin.Context) {
+	var request grafana.AnnotationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	annotations, err := grafana.Annotations(c, request)
+	if err != nil {
+		grafanaError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, annotations)
+}
+
+// grafanaTagKeysHandler returns the fields the ad hoc filters of the dashboards can filter on
+func grafanaTagKeysHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, grafana.TagKeys())
+}
+
+// grafanaTagValuesHandler returns the values of the field of the ad hoc filter among the latest entries
+func grafanaTagValuesHandler(c *gin.Context) {
+	var request struct {
+		Key string `json:"key" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	values, err := grafana.TagValues(c, request.Key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, values)
+}
+
+// grafanaError is used to respond with the error, the invalid targets being the fault of the request
+func grafanaError(c *gin.Context, err error) {
+	if errors.Is(err, grafana.ErrInvalidTarget) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
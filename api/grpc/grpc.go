@@ -0,0 +1,189 @@
+// Package grpc serves the LogService of logger.proto, the grpc counterpart of the http ingestion api for the
+// services that prefer protobuf over json. Entries are validated like the http ones and go through the same
+// pipeline and sinks.
+package grpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/limits"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/angel-one/nbu-logger-service/utils/grpcwire"
+	"github.com/angel-one/nbu-logger-service/wal"
+	"github.com/gin-gonic/gin/binding"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Config is the configuration of the grpc ingestion api
+type Config struct {
+	// Address is the address of the plaintext http2 listener, e.g. :9090, the api is disabled when empty
+	Address string `json:"address" mapstructure:"address"`
+}
+
+// Server serves the LogService
+type Server struct {
+	config   Config
+	listener net.Listener
+}
+
+// status is the grpc status an rpc ends with
+type status struct {
+	code    int
+	message string
+}
+
+// NewServer is used to create the server for the config
+func NewServer(config Config) *Server {
+	return &Server{config: config}
+}
+
+// Start is used to serve the api in the background until the context is done
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	server := &http.Server{
+		Handler:           h2c.NewHandler(http.HandlerFunc(s.serveHTTP), &http2.Server{}),
+		ReadHeaderTimeout: constants.GRPCReadHeaderTimeoutInSeconds * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(ctx).Err(err).Msg("error serving grpc ingestion api")
+		}
+	}()
+	return nil
+}
+
+// Addr is used to get the address the server listens on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// serveHTTP is used to dispatch the rpc and answer with its response and grpc status
+func (s *Server) serveHTTP(w http.ResponseWriter, request *http.Request) {
+	grpcwire.Prepare(w)
+	reader := bufio.NewReader(request.Body)
+	encoding := request.Header.Get("Grpc-Encoding")
+	ctx := request.Context()
+	// the delivery class is sent as metadata, like the http header
+	if class := request.Header.Get(constants.DeliveryClassHeader); class != "" {
+		if !ingest.ValidDeliveryClass(class) {
+			w.WriteHeader(http.StatusOK)
+			grpcwire.Finish(w, grpcwire.InvalidArgument, constants.InvalidDeliveryClassError)
+			return
+		}
+		ctx = ingest.WithDeliveryClass(ctx, class)
+	}
+	// the client is told to the pipeline like the http api does, for the enrichment
+	client := pipeline.Client{}
+	if host, _, err := net.SplitHostPort(request.RemoteAddr); err == nil {
+		client.IP = host
+	}
+	if key := request.Header.Get(constants.APIKeyHeader); key != "" {
+		client.KeyName, _ = tenants.KeyName(key)
+	}
+	ctx = pipeline.WithClient(ctx, client)
+	var response []byte
+	result := status{code: grpcwire.OK}
+	switch request.URL.Path {
+	case constants.GRPCLogMethod:
+		response, result = s.log(ctx, reader, encoding)
+	case constants.GRPCBatchLogMethod:
+		response, result = s.batchLog(ctx, reader, encoding)
+	case constants.GRPCStreamLogsMethod:
+		response, result = s.streamLogs(ctx, reader, encoding)
+	default:
+		result = status{code: grpcwire.Unimplemented, message: "unknown method " + request.URL.Path}
+	}
+	w.WriteHeader(http.StatusOK)
+	if result.code == grpcwire.OK {
+		if err := grpcwire.WriteMessage(w, response); err != nil {
+			log.Warn(request.Context()).Err(err).Msg("error writing grpc response")
+		}
+	}
+	grpcwire.Finish(w, result.code, result.message)
+}
+
+// log is the unary Log rpc, a rejected entry fails the rpc
+func (s *Server) log(ctx context.Context, reader io.Reader, encoding string) ([]byte, status) {
+	data, err := grpcwire.ReadMessage(reader, encoding)
+	if err != nil {
+		return nil, status{code: grpcwire.InvalidArgument, message: err.Error()}
+	}
+	entry, err := DecodeEntry(data)
+	if err != nil {
+		return nil, status{code: grpcwire.InvalidArgument, message: err.Error()}
+	}
+	if err = ingestEntry(ctx, &entry); errors.Is(err, ingest.ErrNotDurable) || errors.Is(err, wal.ErrFull) ||
+		errors.Is(err, ingest.ErrNotDelivered) {
+		// the entry was valid, the client can send it again
+		return nil, status{code: grpcwire.Unavailable, message: err.Error()}
+	} else if err != nil {
+		return nil, status{code: grpcwire.InvalidArgument, message: err.Error()}
+	}
+	return nil, status{code: grpcwire.OK}
+}
+
+// batchLog is the unary BatchLog rpc, the entries failing are reported without failing the rest
+func (s *Server) batchLog(ctx context.Context, reader io.Reader, encoding string) ([]byte, status) {
+	data, err := grpcwire.ReadMessage(reader, encoding)
+	if err != nil {
+		return nil, status{code: grpcwire.InvalidArgument, message: err.Error()}
+	}
+	entries, err := DecodeBatch(data)
+	if err != nil {
+		return nil, status{code: grpcwire.InvalidArgument, message: err.Error()}
+	}
+	if max := limits.Get().MaxBatchEntries(); len(entries) > max {
+		return nil, status{code: grpcwire.ResourceExhausted,
+			message: fmt.Sprintf("a batch has at most %d entries", max)}
+	}
+	var report batchReport
+	for index, data := range entries {
+		report.add(ctx, index, data)
+	}
+	return report.encode(), status{code: grpcwire.OK}
+}
+
+// streamLogs is the client streaming StreamLogs rpc, the entries are ingested as they arrive and reported like
+// a batch once the client ends the stream
+func (s *Server) streamLogs(ctx context.Context, reader io.Reader, encoding string) ([]byte, status) {
+	var report batchReport
+	for index := 0; ; index++ {
+		data, err := grpcwire.ReadMessage(reader, encoding)
+		if errors.Is(err, io.EOF) {
+			return report.encode(), status{code: grpcwire.OK}
+		}
+		if err != nil {
+			return nil, status{code: grpcwire.InvalidArgument, message: err.Error()}
+		}
+		report.add(ctx, index, data)
+	}
+}
+
+// ingestEntry is used to validate the entry like the http api does and ingest it
+func ingestEntry(ctx context.Context, entry *models.LogEntry) error {
+	if err := binding.Validator.ValidateStruct(entry); err != nil {
+		return fmt.Errorf("%s: %w", constants.RequestValidationError, err)
+	}
+	return ingest.Entry(ctx, entry)
+}
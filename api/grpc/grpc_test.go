@@ -0,0 +1,97 @@
+package grpc_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api/grpc"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func field(number protowire.Number, value interface{}) []byte {
+	var b []byte
+	switch v := value.(type) {
+	case uint64:
+		b = protowire.AppendTag(b, number, protowire.VarintType)
+		return protowire.AppendVarint(b, v)
+	case string:
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendString(b, v)
+	default:
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendBytes(b, v.([]byte))
+	}
+}
+
+func frame(messages ...[]byte) []byte {
+	var b []byte
+	for _, message := range messages {
+		header := make([]byte, 5)
+		binary.BigEndian.PutUint32(header[1:], uint32(len(message)))
+		b = append(append(b, header...), message...)
+	}
+	return b
+}
+
+func call(t *testing.T, address, method string, body []byte) ([]byte, string) {
+	client := http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	request, err := http.NewRequest(http.MethodPost, "http://"+address+method, bytes.NewReader(body))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", "application/grpc")
+	response, err := client.Do(request)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	data, _ := io.ReadAll(response.Body)
+	return data, response.Trailer.Get("Grpc-Status")
+}
+
+func TestLogService(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.Init(store.NewMemory(10))
+	server := grpc.NewServer(grpc.Config{Address: "127.0.0.1:0"})
+	assert.NoError(t, server.Start(ctx))
+	address := server.Addr().String()
+
+	entry := bytes.Join([][]byte{
+		field(1, "orders"), field(2, "error"),
+		field(3, bytes.Join([][]byte{field(1, "env"), field(2, "prod")}, nil)),
+		field(4, `{"message":"failed"}`),
+		field(5, field(1, uint64(1700000000))),
+	}, nil)
+	_, code := call(t, address, "/logger.v1.LogService/Log", frame(entry))
+	assert.Equal(t, "0", code)
+	_, code = call(t, address, "/logger.v1.LogService/Log", frame(field(2, "verbose")))
+	assert.Equal(t, "3", code)
+
+	invalid := field(1, "orders")
+	batch := bytes.Join([][]byte{field(1, entry), field(1, field(2, "info")), field(1, invalid)}, nil)
+	response, code := call(t, address, "/logger.v1.LogService/BatchLog", frame(batch))
+	assert.Equal(t, "0", code)
+	assert.Equal(t, byte(0x08), response[5])
+	assert.Equal(t, byte(2), response[6])
+
+	_, code = call(t, address, "/logger.v1.LogService/StreamLogs", frame(entry, invalid))
+	assert.Equal(t, "0", code)
+
+	records, err := store.Get().Query(ctx, store.Query{Type: "orders", Labels: map[string]string{"env": "prod"}})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, "failed", records[0].Entry.Data["message"])
+		assert.Equal(t, int64(1700000000), records[0].Entry.Timestamp.Unix())
+	}
+}
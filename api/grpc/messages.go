@@ -0,0 +1,169 @@
+package grpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// field numbers of the messages of logger.proto
+const (
+	entryType      = 1
+	entryLevel     = 2
+	entryLabels    = 3
+	entryData      = 4
+	entryTimestamp = 5
+
+	batchEntries = 1
+
+	reportAccepted = 1
+	reportErrors   = 2
+	errorIndex     = 1
+	errorMessage   = 2
+)
+
+// batchReport is the BatchLogResponse of the entries of a batch or a stream
+type batchReport struct {
+	accepted int
+	errors   []entryError
+}
+
+type entryError struct {
+	index   int
+	message string
+}
+
+// add is used to ingest the encoded entry at the index and report the outcome
+func (r *batchReport) add(ctx context.Context, index int, data []byte) {
+	entry, err := DecodeEntry(data)
+	if err == nil {
+		err = ingestEntry(ctx, &entry)
+	}
+	if err != nil {
+		r.errors = append(r.errors, entryError{index: index, message: err.Error()})
+		return
+	}
+	r.accepted++
+}
+
+func (r *batchReport) encode() []byte {
+	var b []byte
+	if r.accepted > 0 {
+		b = protowire.AppendTag(b, reportAccepted, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.accepted))
+	}
+	for _, failed := range r.errors {
+		var e []byte
+		e = protowire.AppendTag(e, errorIndex, protowire.VarintType)
+		e = protowire.AppendVarint(e, uint64(failed.index))
+		e = protowire.AppendTag(e, errorMessage, protowire.BytesType)
+		e = protowire.AppendString(e, failed.message)
+		b = protowire.AppendTag(b, reportErrors, protowire.BytesType)
+		b = protowire.AppendBytes(b, e)
+	}
+	return b
+}
+
+// DecodeBatch is used to get the encoded entries of a BatchLogRequest, each is decoded with DecodeEntry
+func DecodeBatch(data []byte) ([][]byte, error) {
+	var entries [][]byte
+	err := eachField(data, func(number protowire.Number, _ uint64, value []byte) error {
+		if number == batchEntries {
+			entries = append(entries, value)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// DecodeEntry is used to decode a LogEntry, it is also the protobuf body of the http ingestion api
+func DecodeEntry(data []byte) (models.LogEntry, error) {
+	var entry models.LogEntry
+	err := eachField(data, func(number protowire.Number, _ uint64, value []byte) error {
+		switch number {
+		case entryType:
+			entry.Type = string(value)
+		case entryLevel:
+			entry.Level = string(value)
+		case entryLabels:
+			var key, label string
+			err := eachField(value, func(number protowire.Number, _ uint64, value []byte) error {
+				if number == 1 {
+					key = string(value)
+				} else if number == 2 {
+					label = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if entry.Labels == nil {
+				entry.Labels = make(map[string]string)
+			}
+			entry.Labels[key] = label
+		case entryData:
+			if len(value) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(value, &entry.Data); err != nil {
+				return fmt.Errorf("data has to be a json object : %w", err)
+			}
+		case entryTimestamp:
+			var seconds, nanos uint64
+			err := eachField(value, func(number protowire.Number, varint uint64, _ []byte) error {
+				if number == 1 {
+					seconds = varint
+				} else if number == 2 {
+					nanos = varint
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			entry.Timestamp = &models.Timestamp{Time: time.Unix(int64(seconds), int64(int32(nanos)))}
+		}
+		return nil
+	})
+	if err != nil {
+		return entry, fmt.Errorf("invalid log entry : %w", err)
+	}
+	return entry, nil
+}
+
+// eachField is used to walk the varint and length delimited fields of a message, the others are skipped
+func eachField(data []byte, f func(number protowire.Number, varint uint64, value []byte) error) error {
+	for len(data) > 0 {
+		number, kind, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		var varint uint64
+		var value []byte
+		switch kind {
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(data)
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(number, kind, data)
+		}
+		if n < 0 {
+			return fmt.Errorf("field %d : %w", number, protowire.ParseError(n))
+		}
+		data = data[n:]
+		if kind != protowire.VarintType && kind != protowire.BytesType {
+			continue
+		}
+		if err := f(number, varint, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
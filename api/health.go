@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/health"
+	"github.com/gin-gonic/gin"
+)
+
+// livenessHandler answers the liveness probe, the process is up as long as it answers
+func livenessHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, health.Live())
+}
+
+// readinessHandler answers the readiness probe with the state of every dependency, 503 when one is down
+func readinessHandler(c *gin.Context) {
+	report := health.Ready(c)
+	status := http.StatusOK
+	if report.Status != constants.UpStatus {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/inputs"
+	"github.com/gin-gonic/gin"
+)
+
+// checkpointResetRequest is the position a source resumes from, the message or row id or else the time
+type checkpointResetRequest struct {
+	Position string    `json:"position"`
+	Time     time.Time `json:"time"`
+}
+
+// checkpointsHandler returns the checkpoints of the sources of the pull based inputs, by input
+func checkpointsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"checkpoints": inputs.Checkpoints()})
+}
+
+// resetCheckpointHandler moves the checkpoint of a source deliberately, back to replay its messages or ahead
+// to skip them, the reads in flight do not move it again
+func resetCheckpointHandler(c *gin.Context) {
+	var request checkpointResetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	err := inputs.ResetCheckpoint(c.Param(constants.InputPathParam), c.Param(constants.SourcePathParam),
+		request.Position, request.Time)
+	switch {
+	case errors.Is(err, inputs.ErrUnknownCheckpoint):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, inputs.ErrInvalidPosition):
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyValidationError, err)})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{"checkpoints": inputs.Checkpoints()[c.Param(constants.InputPathParam)]})
+	}
+}
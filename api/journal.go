@@ -0,0 +1,44 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/gin-gonic/gin"
+)
+
+// loggerJournalHandler ingests a systemd journal export format stream.
+// systemd-journal-upload posts to {url}/upload, so the url is either the service or /logger/journal.
+func loggerJournalHandler(c *gin.Context) {
+	if c.ContentType() != constants.JournalMediaType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": fmt.Sprintf("%s: content type has to be %s",
+			constants.RequestBodyBindError, constants.JournalMediaType)})
+		return
+	}
+	accepted, rejected := 0, 0
+	var failures []string
+	reader := formats.NewJournalReader(c.Request.Body)
+	for {
+		entry, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err),
+				"accepted": accepted, "rejected": rejected})
+			return
+		}
+		if err = ingest.Entry(c, &entry); err != nil {
+			rejected++
+			failures = append(failures, err.Error())
+			continue
+		}
+		accepted++
+	}
+	c.JSON(http.StatusAccepted, gin.H{"accepted": accepted, "rejected": rejected, "errors": failures})
+}
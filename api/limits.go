@@ -0,0 +1,30 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/limits"
+	"github.com/gin-gonic/gin"
+)
+
+// limitBody is the middleware rejecting the bodies over the size limit with 413, right away when their length is
+// known and else once the limit is read. Every route reading a body is limited, the admin ones included.
+func limitBody(c *gin.Context) {
+	l := limits.Get()
+	if err := l.BodyError(c.Request.ContentLength); err != nil {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	c.Request.Body = l.Body(c.Request.Body)
+	c.Next()
+}
+
+// bodyStatus is used to get the status of a body that could not be read, 413 when it is over a size limit
+func bodyStatus(err error) int {
+	if errors.Is(err, limits.ErrBodyTooLarge) || errors.Is(err, formats.ErrBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
@@ -2,30 +2,182 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/angel-one/go-utils/log"
 	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/dedup"
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/ingest"
 	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/schemas"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/angel-one/nbu-logger-service/wal"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 )
 
 func SetupLoggerRoutes(router *gin.Engine) {
-	// Define your logger-related routes here
-	router.POST(constants.LoggerRoute, loggerHandler)
+	// Define your logger-related routes in the api versions, the bytes they receive are counted against their
+	// tenant and the rejected requests are kept for debugging
+	setupVersionedRoutes(router)
 }
 
 // SetupRoutes initializes and sets up the routes for the logger API.
 func loggerHandler(c *gin.Context) {
-	var logEntry models.LogEntry
-
+	if c.ContentType() == constants.NDJSONMediaType {
+		loggerNDJSONHandler(c)
+		return
+	}
+	if c.ContentType() == constants.ProtobufMediaType {
+		// parsing json dominates the cost of ingestion, so high volume producers can send the LogEntry message
+		ingestLogEntry(c, bindProtobufEntry)
+		return
+	}
 	// Parse the JSON request body into a LogEntry struct
-	if err := c.ShouldBindJSON(&logEntry); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	ingestLogEntry(c, bindLogEntry)
+}
+
+// ingestLogEntry is used to ingest the entry read from the request with the bind function and respond
+func ingestLogEntry(c *gin.Context, bind func(c *gin.Context) (models.LogEntry, error)) {
+	logEntry, err := bind(c)
+	if err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": err.Error()})
 		return
 	}
-	messageJson, _ := json.Marshal(logEntry)
-	log.Info(c).Msg(string(messageJson))
+	// A resent entry, e.g. after failing over to the other region, is acknowledged without ingesting it again
+	// once it is accepted, and is retried while it is still being ingested
+	tenant, key := idempotencyKey(c)
+	if key != "" {
+		switch dedup.Claim(c, tenant, key) {
+		case dedup.Duplicate:
+			c.JSON(http.StatusOK, gin.H{"duplicate": true, "idempotencyKey": key})
+			return
+		case dedup.InFlight:
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusConflict, gin.H{"error": constants.IdempotencyKeyInFlightError, "idempotencyKey": key})
+			return
+		}
+	}
+	if err = ingest.Entry(c, &logEntry); err != nil {
+		if key != "" {
+			dedup.Release(c, tenant, key)
+		}
+		if errors.Is(err, tenants.ErrQuotaExceeded) {
+			_, wait := tenants.Exhausted(c, tenant, time.Now())
+			quotaExceeded(c, wait, err.Error())
+			return
+		}
+		if errors.Is(err, wal.ErrFull) || errors.Is(err, ingest.ErrNotDurable) ||
+			errors.Is(err, ingest.ErrNotDelivered) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		var invalid *schemas.ValidationError
+		if errors.As(err, &invalid) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "fields": invalid.Fields})
+			return
+		}
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if key != "" {
+		dedup.Commit(c, tenant, key)
+	}
 	// Respond with the logged entry and a status code of 200 (Created)
 	c.JSON(http.StatusOK, logEntry)
 }
+
+// idempotencyKey is used to get the idempotency key of the request and the tenant it is scoped to
+func idempotencyKey(c *gin.Context) (string, string) {
+	tenant, _ := tenants.FromContext(c)
+	return tenant, c.GetHeader(constants.IdempotencyKeyHeader)
+}
+
+// bindLogEntry is used to read the entry from the body. CloudEvents, xml and form bodies are picked by the content type,
+// binary mode CloudEvents by their ce-specversion header.
+// The json shapes of common logging libraries, such as logstash-logback-encoder, pino and winston, are mapped
+// onto the entry when the format query param names one or when the body is not an entry but has one of those shapes.
+func bindLogEntry(c *gin.Context) (models.LogEntry, error) {
+	var logEntry models.LogEntry
+	if formats.IsCloudEventBinary(c.Request.Header) || c.ContentType() == constants.CloudEventsMediaType {
+		body, err := c.GetRawData()
+		if err != nil {
+			return logEntry, err
+		}
+		if c.ContentType() == constants.CloudEventsMediaType {
+			logEntry, err = formats.DecodeCloudEvent(body)
+		} else {
+			logEntry, err = formats.DecodeCloudEventBinary(c.Request.Header, body)
+		}
+		if err != nil {
+			return logEntry, fmt.Errorf("%s: %s", constants.RequestBodyBindError, err)
+		}
+		return logEntry, nil
+	}
+	if contentType := c.ContentType(); contentType == constants.XMLMediaType ||
+		contentType == constants.TextXMLMediaType {
+		logEntry, err := formats.DecodeXML(c.Request.Body, formats.XML(), c.Query(constants.TypeQueryParam))
+		if err != nil {
+			return logEntry, fmt.Errorf("%s: %w", constants.RequestBodyBindError, err)
+		}
+		return logEntry, nil
+	}
+	if c.ContentType() == constants.FormMediaType {
+		if err := c.Request.ParseForm(); err != nil {
+			return logEntry, fmt.Errorf("%s: %s", constants.RequestBodyBindError, err)
+		}
+		logEntry, err := formats.DecodeForm(c.Request.PostForm)
+		if err != nil {
+			return logEntry, fmt.Errorf("%s: %s", constants.RequestBodyBindError, err)
+		}
+		return logEntry, nil
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		return logEntry, err
+	}
+	return decodeJSONEntry(c, body)
+}
+
+// decodeJSONEntry is used to read the entry from a json body, either an entry or one of the shapes of the formats
+func decodeJSONEntry(c *gin.Context, body []byte) (models.LogEntry, error) {
+	var logEntry models.LogEntry
+	var err error
+	name := c.Query(constants.FormatQueryParam)
+	if name == "" {
+		if err = binding.JSON.BindBody(body, &logEntry); err == nil {
+			return logEntry, nil
+		}
+	}
+	var fields map[string]interface{}
+	if jsonErr := json.Unmarshal(body, &fields); jsonErr != nil || fields == nil {
+		if err == nil {
+			err = fmt.Errorf("%s: body has to be a json object", constants.RequestBodyBindError)
+		}
+		return logEntry, err
+	}
+	format, ok := formats.Get(name)
+	if name == "" {
+		if _, hasType := fields[constants.TypeField]; hasType {
+			return logEntry, err
+		}
+		if format, ok = formats.Detect(fields); !ok {
+			return logEntry, err
+		}
+	} else if !ok {
+		return logEntry, fmt.Errorf("%s: format has to be one of %s", constants.QueryParamValidationError,
+			strings.Join(formats.Names(), ", "))
+	}
+	logEntry = format.Convert(fields)
+	if entryType := c.Query(constants.TypeQueryParam); entryType != "" {
+		logEntry.Type = entryType
+	}
+	if logEntry.Type == "" {
+		logEntry.Type = format.Name()
+	}
+	return logEntry, nil
+}
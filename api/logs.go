@@ -0,0 +1,352 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/archive"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/rollup"
+	"github.com/angel-one/nbu-logger-service/sessions"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupLogsRoutes is used to set up the routes for querying stored entries, behind the auth middlewares
+func SetupLogsRoutes(router *gin.Engine, auth ...gin.HandlerFunc) {
+	logs := router.Group("", auth...)
+	logs.GET(constants.LogsRoute, logsHandler)
+	// the query api is part of the public api from v1 on
+	v1 := router.Group("/"+constants.APIVersionV1, auth...)
+	v1.Use(apiVersion(constants.APIVersionV1))
+	v1.GET(constants.LogsRoute, logsHandler)
+	logs.GET(constants.LogsStatsRoute, logsStatsHandler)
+	logs.GET(constants.LogsExportRoute, logsExportHandler)
+	logs.GET(constants.LogsRollupRoute, logsRollupHandler)
+	logs.GET(constants.LogsSessionRoute, logsSessionHandler)
+}
+
+// readerAuth is the middleware letting through the requests bearing the reader token or the admin token, the
+// stored entries can not be read when there is no reader token
+func readerAuth(token, adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": constants.QueryDisabledError})
+			return
+		}
+		if !hasToken(c, token) && !hasToken(c, adminToken) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": constants.UnauthorizedError})
+			return
+		}
+		c.Next()
+	}
+}
+
+// logsHandler returns the recent entries matching the type, level, label, text, filter expression and time
+// filters, a page of limit entries at a time. Text is looked for in the data of the entries, and a full page
+// comes with the nextCursor to pass as cursor for the next one.
+// Labels are passed as repeated label=key:value params and all of them have to match, and q is an expression
+// of the filter language, e.g. q=level = error and amount > 1000. With syntax=logql or syntax=lucene, q is a
+// logql or lucene query translated into the filter language, e.g. q={level="error"} | json | amount > 1000.
+// highlight=true returns the values q matched with the matches between <em> tags, and context=N the N entries
+// logged before and after every hit in the same stream, the entries of the same type unless contextBy lists
+// the fields they share, e.g. contextBy=type,data.sessionId.
+// from and to bound the ingestion time of the entries, or the time they were logged with timeAxis=event.
+// With archives configured, a page of a range starting with from that the hot store cannot fill is completed
+// with the archived entries logged before, their number in archived.
+func logsHandler(c *gin.Context) {
+	query, err := getStoreQuery(c, constants.DefaultQueryLimit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	highlight, surrounding, err := getSearchOptions(c, query.Axis)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	records, err := store.Get().Query(c, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// the older entries of the range that the hot store no longer holds are read from the archives
+	records, archived, err := archive.Complete(c, query, records)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	response := gin.H{"entries": records}
+	if archived > 0 {
+		response["archived"] = archived
+	}
+	if cursor := store.NextCursor(records, query); cursor != nil {
+		response["nextCursor"] = cursor.String()
+	}
+	if !highlight && surrounding.Before == 0 {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	hits := make([]hit, 0, len(records))
+	for _, record := range records {
+		h := hit{Record: record}
+		if highlight {
+			h.Highlights = query.Filter.Highlight(record.Entry)
+		}
+		if surrounding.Before > 0 {
+			if h.Before, h.After, err = store.Get().Around(c, record, surrounding); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		hits = append(hits, h)
+	}
+	response["entries"] = hits
+	c.JSON(http.StatusOK, response)
+}
+
+// hit is a matching record along with the parts of it the filter matched and the records surrounding it
+type hit struct {
+	store.Record
+	Highlights map[string]string `json:"highlights,omitempty"`
+	Before     []store.Record    `json:"before,omitempty"`
+	After      []store.Record    `json:"after,omitempty"`
+}
+
+// getSearchOptions is used to get whether the matches are highlighted and the records surrounding the hits
+// returned, none when context is not given
+func getSearchOptions(c *gin.Context, axis store.TimeAxis) (bool, store.Surrounding, error) {
+	var (
+		highlight   bool
+		surrounding = store.Surrounding{Fields: []string{constants.TypeField}, Axis: axis}
+		err         error
+	)
+	if value := c.Query(constants.HighlightQueryParam); value != "" {
+		if highlight, err = strconv.ParseBool(value); err != nil {
+			return false, surrounding, fmt.Errorf("%s: highlight has to be true or false",
+				constants.QueryParamValidationError)
+		}
+	}
+	if value := c.Query(constants.ContextQueryParam); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 || n > constants.MaxContextEntries {
+			return false, surrounding, fmt.Errorf("%s: context has to be between 0 and %d",
+				constants.QueryParamValidationError, constants.MaxContextEntries)
+		}
+		surrounding.Before, surrounding.After = n, n
+	}
+	if value := c.Query(constants.ContextByQueryParam); value != "" {
+		surrounding.Fields = strings.Split(value, ",")
+		for i, field := range surrounding.Fields {
+			if surrounding.Fields[i] = strings.TrimSpace(field); surrounding.Fields[i] == "" {
+				return false, surrounding, fmt.Errorf("%s: contextBy has to be a list of fields",
+					constants.QueryParamValidationError)
+			}
+		}
+	}
+	return highlight, surrounding, nil
+}
+
+// logsStatsHandler returns the aggregates of the entries matching the filters, anonymized with
+// aggregation=anonymized or when the privacy config enforces it
+func logsStatsHandler(c *gin.Context) {
+	query, err := getStoreQuery(c, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	anonymize, err := anonymized(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	stats, err := store.Get().Stats(c, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if anonymize {
+		c.JSON(http.StatusOK, anonymizeStats(stats))
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// logsExportHandler streams all the entries matching the filters as newline delimited json.
+// With format=cloudevents every line is a structured mode CloudEvent instead of a record.
+func logsExportHandler(c *gin.Context) {
+	query, err := getStoreQuery(c, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	format := c.Query(constants.FormatQueryParam)
+	if format != "" && format != constants.CloudEventsFormat {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: format has to be %s",
+			constants.QueryParamValidationError, constants.CloudEventsFormat)})
+		return
+	}
+	records, err := store.Get().Query(c, query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Type", constants.NDJSONMediaType)
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	for _, record := range records {
+		var line interface{} = record
+		if format == constants.CloudEventsFormat {
+			line = formats.NewCloudEvent(record.Entry, strconv.FormatUint(record.ID, 10), record.IngestedAt)
+		}
+		if err = encoder.Encode(line); err != nil {
+			log.Error(c).Err(err).Msg("error exporting entries")
+			return
+		}
+	}
+}
+
+// logsRollupHandler returns the counts of the entries by the time they were logged, in the buckets overlapping
+// from and to. Late entries are counted in the bucket they were logged in, and buckets the watermark passed are
+// closed, so only late entries change them. The counts are anonymized like the ones of the stats. The buckets
+// are only counted by type, so the other filters are refused with 400.
+func logsRollupHandler(c *gin.Context) {
+	query, err := getStoreQuery(c, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// the buckets are counted across the tenants, so they are not served to the reads scoped to a tenant
+	if query.Tenant != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": constants.RollupTenantError})
+		return
+	}
+	// the buckets are only counted by type, so the filters they can not apply are refused rather than ignored
+	if query.Level != "" || query.Text != "" || query.Filter != nil || len(query.Labels) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": constants.RollupFilterError})
+		return
+	}
+	anonymize, err := anonymized(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	r := rollup.Get()
+	if anonymize {
+		c.JSON(http.StatusOK, anonymizeRollup(r.Watermark(), r.TooLate(), r.Buckets(query.Type, query.From, query.To)))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"watermark": r.Watermark(),
+		"tooLate":   r.TooLate(),
+		"buckets":   r.Buckets(query.Type, query.From, query.To),
+	})
+}
+
+// logsSessionHandler returns the entries of every type logged with the id of the session, ordered by the time
+// they were logged, without duplicates and with the silences longer than gapInSeconds annotated. The id is
+// looked for in the fields listed by by, sessionId and deviceId by default.
+func logsSessionHandler(c *gin.Context) {
+	id := c.Param(constants.IDPathParam)
+	fields := strings.Split(c.DefaultQuery(constants.ByQueryParam, constants.DefaultSessionFields), ",")
+	for i := range fields {
+		if fields[i] = strings.TrimSpace(fields[i]); fields[i] == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: by has to be a list of fields",
+				constants.QueryParamValidationError)})
+			return
+		}
+	}
+	gap := constants.DefaultSessionGapInSeconds
+	if value := c.Query(constants.GapQueryParam); value != "" {
+		g, err := strconv.Atoi(value)
+		if err != nil || g <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: gapInSeconds has to be positive",
+				constants.QueryParamValidationError)})
+			return
+		}
+		gap = g
+	}
+	// the text narrows the records down to the ones having the id somewhere, the fields are checked after
+	records, err := store.Get().Query(c, store.Query{Text: id, Tenant: tenants.Scope(c), Axis: store.EventTime})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessions.Reconstruct(id, fields, records, time.Duration(gap)*time.Second))
+}
+
+// getStoreQuery is used to build the store query from the query params, 0 default limit means no limit.
+// The queries of the reads scoped to a tenant only match the entries of the tenant.
+func getStoreQuery(c *gin.Context, defaultLimit int) (store.Query, error) {
+	query := store.Query{
+		Type:   c.Query(constants.TypeQueryParam),
+		Level:  c.Query(constants.LevelQueryParam),
+		Text:   c.Query(constants.TextQueryParam),
+		Tenant: tenants.Scope(c),
+		Limit:  defaultLimit,
+	}
+	if token := c.Query(constants.CursorQueryParam); token != "" {
+		cursor, err := store.ParseCursor(token)
+		if err != nil {
+			return query, fmt.Errorf("%s: %s", constants.QueryParamValidationError, err)
+		}
+		query.Cursor = cursor
+	}
+	if limit := c.Query(constants.LimitQueryParam); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 || l > constants.MaxQueryLimit {
+			return query, fmt.Errorf("%s: limit has to be between 1 and %d", constants.QueryParamValidationError,
+				constants.MaxQueryLimit)
+		}
+		query.Limit = l
+	}
+	if expression := c.Query(constants.FilterQueryParam); expression != "" {
+		expression, err := filter.Translate(c.Query(constants.SyntaxQueryParam), expression)
+		if err != nil {
+			return query, fmt.Errorf("%s: %s", constants.QueryParamValidationError, err)
+		}
+		f, err := filter.Parse(expression)
+		if err != nil {
+			return query, fmt.Errorf("%s: %s", constants.QueryParamValidationError, err)
+		}
+		query.Filter = f
+	}
+	switch axis := store.TimeAxis(c.Query(constants.TimeAxisQueryParam)); axis {
+	case "", store.IngestionTime, store.EventTime:
+		query.Axis = axis
+	default:
+		return query, fmt.Errorf("%s: timeAxis has to be %s or %s", constants.QueryParamValidationError,
+			store.IngestionTime, store.EventTime)
+	}
+	for param, bound := range map[string]*time.Time{constants.FromQueryParam: &query.From,
+		constants.ToQueryParam: &query.To} {
+		if value := c.Query(param); value != "" {
+			at, err := models.ParseTimestamp(value)
+			if err != nil {
+				return query, fmt.Errorf("%s: %s %s", constants.QueryParamValidationError, param, err)
+			}
+			*bound = at.Time
+		}
+	}
+	for _, label := range c.QueryArray(constants.LabelQueryParam) {
+		key, value, ok := strings.Cut(label, ":")
+		if !ok || key == "" {
+			return query, fmt.Errorf("%s: label %q has to be of the form key:value",
+				constants.QueryParamValidationError, label)
+		}
+		if query.Labels == nil {
+			query.Labels = make(map[string]string)
+		}
+		query.Labels[key] = value
+	}
+	return query, nil
+}
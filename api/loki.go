@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/limits"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/angel-one/nbu-logger-service/utils/snappy"
+	"github.com/angel-one/nbu-logger-service/wal"
+	"github.com/gin-gonic/gin"
+)
+
+// lokiPushHandler ingests the streams of a loki push request, protobuf compressed with snappy as sent by
+// promtail or json, so that the loki clients ship to the service as they are. Every line is an entry typed and
+// labelled by its stream. Like loki it answers 204 once every entry was accepted, and 429 or 503 when some
+// could not be accepted for now, as the clients only send a batch again on those.
+func lokiPushHandler(c *gin.Context) {
+	entries, err := bindLokiPush(c)
+	if err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	if max := limits.Get().MaxBatchEntries(); len(entries) > max {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%s: %d, at most %d",
+			constants.BatchTooLargeError, len(entries), max)})
+		return
+	}
+	accepted, rejected, status := 0, 0, http.StatusBadRequest
+	var failures []string
+	for i := range entries {
+		if err = ingest.Entry(c, &entries[i]); err != nil {
+			rejected++
+			failures = append(failures, fmt.Sprintf("entry %d: %s", i, err))
+			switch {
+			case errors.Is(err, tenants.ErrQuotaExceeded):
+				status = http.StatusTooManyRequests
+			case errors.Is(err, wal.ErrFull) || errors.Is(err, ingest.ErrNotDurable),
+				errors.Is(err, ingest.ErrNotDelivered):
+				status = http.StatusServiceUnavailable
+			}
+			continue
+		}
+		accepted++
+	}
+	if rejected == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(status, gin.H{"error": failures[0], "accepted": accepted, "rejected": rejected, "errors": failures})
+}
+
+// bindLokiPush is used to read the entries of the push request, decompressing the protobuf ones and the json
+// ones sent with gzip
+func bindLokiPush(c *gin.Context) ([]models.LogEntry, error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		return nil, err
+	}
+	if c.ContentType() == constants.ProtobufMediaType {
+		// the size of the decompressed body is bounded like the one of any body
+		length, err := snappy.DecodedLen(body)
+		if err != nil {
+			return nil, err
+		}
+		if err = limits.Get().BodyError(int64(length)); err != nil {
+			return nil, err
+		}
+		if body, err = snappy.Decode(body); err != nil {
+			return nil, err
+		}
+		return formats.DecodeLokiProtobuf(body)
+	}
+	if c.GetHeader("Content-Encoding") == constants.GzipEncoding {
+		reader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(limits.Get().Body(reader)); err != nil {
+			return nil, err
+		}
+	}
+	return formats.DecodeLokiJSON(body)
+}
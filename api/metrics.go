@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/metrics"
+	"github.com/angel-one/nbu-logger-service/violations"
+	"github.com/gin-gonic/gin"
+)
+
+// metricsHandler exposes the metrics derived from the entries and the counters of the service in the prometheus
+// text format, or in the OpenMetrics one with exemplars when the scraper accepts it
+func metricsHandler(c *gin.Context) {
+	mediaType := c.NegotiateFormat(constants.PrometheusMediaType, constants.OpenMetricsMediaType)
+	if mediaType == "" {
+		mediaType = constants.PrometheusMediaType
+	}
+	c.Header("Content-Type", mediaType)
+	c.Status(http.StatusOK)
+	write := metrics.Get().Write
+	openMetrics := mediaType == constants.OpenMetricsMediaType
+	if openMetrics {
+		write = metrics.Get().WriteOpenMetrics
+	}
+	// the counters of the service come first, as the OpenMetrics exposition ends with the derived metrics
+	if err := violations.Get().WriteMetrics(c.Writer, openMetrics); err != nil {
+		log.Warn(c).Err(err).Msg("error writing metrics")
+	}
+	if err := write(c.Writer); err != nil {
+		log.Warn(c).Err(err).Msg("error writing metrics")
+	}
+}
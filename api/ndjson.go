@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/dedup"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// loggerNDJSONHandler ingests a stream of entries, one json entry per line, as they are decoded.
+// A malformed line stops the stream with its line number, the entries before it are kept.
+// With an idempotency key each line is deduplicated on its own, so a resent stream only adds the missing lines.
+func loggerNDJSONHandler(c *gin.Context) {
+	accepted, rejected, duplicates := 0, 0, 0
+	tenant, key := idempotencyKey(c)
+	var failures []string
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), constants.MaxTextLineBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		body := bytes.TrimSpace(scanner.Bytes())
+		if len(body) == 0 {
+			continue
+		}
+		var entry models.LogEntry
+		if err := binding.JSON.BindBody(body, &entry); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: line %d: %s",
+				constants.RequestBodyBindError, line, err), "accepted": accepted, "rejected": rejected,
+				"duplicates": duplicates, "errors": failures})
+			return
+		}
+		duplicate, err := ingestPart(c, tenant, key, line, &entry)
+		if duplicate {
+			duplicates++
+			continue
+		}
+		if err != nil {
+			rejected++
+			failures = append(failures, fmt.Sprintf("line %d: %s", line, err))
+			continue
+		}
+		accepted++
+	}
+	if err := scanner.Err(); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: line %d: %s", constants.RequestBodyBindError,
+			line+1, err), "accepted": accepted, "rejected": rejected, "duplicates": duplicates, "errors": failures})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"accepted": accepted, "rejected": rejected, "duplicates": duplicates, "errors": failures})
+}
+
+// ingestPart is used to ingest an entry of a multi entry request. With an idempotency key the entry is
+// deduplicated by the key and its position, so a resent request only adds the entries that were missing.
+// An entry whose key is being ingested by another request is failed, the request has to be resent for it.
+func ingestPart(c *gin.Context, tenant, key string, position int, entry *models.LogEntry) (bool, error) {
+	partKey := ""
+	if key != "" {
+		partKey = fmt.Sprintf("%s:%d", key, position)
+		switch dedup.Claim(c, tenant, partKey) {
+		case dedup.Duplicate:
+			return true, nil
+		case dedup.InFlight:
+			return false, errors.New(constants.IdempotencyKeyInFlightError)
+		}
+	}
+	if err := ingest.Entry(c, entry); err != nil {
+		if partKey != "" {
+			dedup.Release(c, tenant, partKey)
+		}
+		return false, err
+	}
+	if partKey != "" {
+		dedup.Commit(c, tenant, partKey)
+	}
+	return false, nil
+}
@@ -0,0 +1,62 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// ndjsonResponse is the summary of an ndjson request
+type ndjsonResponse struct {
+	Error    string   `json:"error"`
+	Accepted int      `json:"accepted"`
+	Rejected int      `json:"rejected"`
+	Errors   []string `json:"errors"`
+}
+
+func TestLoggerNDJSON(t *testing.T) {
+	store.Init(store.NewMemory(100))
+	router := api.GetRouter(api.RouterConfig{})
+	post := func(body string) (int, ndjsonResponse) {
+		request := httptest.NewRequest(http.MethodPost, constants.LoggerRoute, strings.NewReader(body))
+		request.Header.Set("Content-Type", constants.NDJSONMediaType)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		var summary ndjsonResponse
+		assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &summary))
+		return response.Code, summary
+	}
+
+	// the blank lines are skipped and the entries failing validation are rejected with their line number
+	status, summary := post("{\"type\":\"order\"}\n\n{\"type\":\"invalid\",\"labels\":{\"bad key\":\"x\"}}\n" +
+		"{\"type\":\"invoice\"}\n")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 2, summary.Accepted)
+	assert.Equal(t, 1, summary.Rejected)
+	if assert.Len(t, summary.Errors, 1) {
+		assert.True(t, strings.HasPrefix(summary.Errors[0], "line 3: "), summary.Errors[0])
+	}
+
+	// a malformed line stops the stream, the entries before it are kept
+	status, summary = post("{\"type\":\"order\"}\n{\"type\":\"invoice\"\n{\"type\":\"refund\"}\n")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, summary.Error, "line 2")
+	assert.Equal(t, 1, summary.Accepted)
+	// and so does a last line cut short
+	status, summary = post("{\"type\":\"order\"}\n{\"type\":\"inv")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, summary.Error, "line 2")
+	assert.Equal(t, 1, summary.Accepted)
+	stats, err := store.Get().Stats(context.Background(), store.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"order": 3, "invoice": 1}, stats.ByType)
+
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupOnboardingRoutes is used to set up the route onboarding new tenants
+func SetupOnboardingRoutes(router *gin.Engine, auth gin.HandlerFunc) {
+	admin := router.Group(constants.AdminRoute, auth, limitBody)
+	admin.POST(constants.OnboardingRoute, onboardingHandler)
+}
+
+// onboardingHandler creates the tenant with its keys, quotas and routing and responds with the bootstrap bundle
+func onboardingHandler(c *gin.Context) {
+	var request tenants.OnboardingRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	bundle, err := tenants.Onboard(registry.Get(), request, c.GetHeader(constants.AuthorHeader))
+	if errors.Is(err, registry.ErrPreconditionFailed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		respondRegistryError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, bundle)
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/gin-gonic/gin"
+)
+
+// pixel is a transparent 1x1 gif
+var pixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// loggerPixelHandler ingests the entry in the query, e.g. /logger/pixel?type=email-open&data={"id":"1"},
+// for clients such as emails and set-top boxes that can only load an image.
+// It always responds with the image, the status tells whether the entry was accepted.
+func loggerPixelHandler(c *gin.Context) {
+	c.Header("Cache-Control", "no-store, max-age=0")
+	status := http.StatusOK
+	entry, err := formats.DecodeForm(c.Request.URL.Query())
+	if err == nil {
+		if err = ingest.Entry(c, &entry); err != nil {
+			status = http.StatusUnprocessableEntity
+		}
+	} else {
+		status = http.StatusBadRequest
+	}
+	c.Data(status, constants.GIFMediaType, pixel)
+}
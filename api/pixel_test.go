@@ -0,0 +1,72 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"image/gif"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggerForm(t *testing.T) {
+	store.Init(store.NewMemory(100))
+	router := api.GetRouter(api.RouterConfig{})
+	form := url.Values{"type": {"signup"}, "plan": {"pro"}, "label.source": {"landing"}}
+	request := httptest.NewRequest(http.MethodPost, constants.LoggerRoute, strings.NewReader(form.Encode()))
+	request.Header.Set("Content-Type", constants.FormMediaType)
+	response := httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusOK, response.Code)
+	records, err := store.Get().Query(context.Background(), store.Query{Type: "signup"})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "pro", records[0].Entry.Data["plan"])
+		assert.Equal(t, "landing", records[0].Entry.Labels["source"])
+	}
+
+	request = httptest.NewRequest(http.MethodPost, constants.LoggerRoute, strings.NewReader("plan=pro"))
+	request.Header.Set("Content-Type", constants.FormMediaType)
+	response = httptest.NewRecorder()
+	router.ServeHTTP(response, request)
+	assert.Equal(t, http.StatusBadRequest, response.Code)
+}
+
+func TestLoggerPixel(t *testing.T) {
+	store.Init(store.NewMemory(100))
+	router := api.GetRouter(api.RouterConfig{})
+	get := func(query string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, constants.LoggerPixelRoute+"?"+query, nil)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		// every response is an uncached 1x1 image, whatever happened to the entry
+		assert.Equal(t, constants.GIFMediaType, response.Header().Get("Content-Type"), query)
+		assert.Contains(t, response.Header().Get("Cache-Control"), "no-store", query)
+		image, err := gif.Decode(bytes.NewReader(response.Body.Bytes()))
+		if assert.NoError(t, err, query) {
+			assert.Equal(t, 1, image.Bounds().Dx(), query)
+			assert.Equal(t, 1, image.Bounds().Dy(), query)
+		}
+		return response
+	}
+
+	response := get(url.Values{"type": {"email-open"}, "data": {`{"campaign":"diwali"}`}, "id": {"7"}}.Encode())
+	assert.Equal(t, http.StatusOK, response.Code)
+	records, err := store.Get().Query(context.Background(), store.Query{Type: "email-open"})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "diwali", records[0].Entry.Data["campaign"])
+		assert.Equal(t, "7", records[0].Entry.Data["id"])
+	}
+
+	// the status reports the entries that were not accepted
+	assert.Equal(t, http.StatusBadRequest, get("id=7").Code)
+	assert.Equal(t, http.StatusBadRequest, get("type=email-open&data=oops").Code)
+}
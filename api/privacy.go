@@ -0,0 +1,45 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/privacy"
+	"github.com/angel-one/nbu-logger-service/rollup"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/gin-gonic/gin"
+)
+
+// anonymized is used to check whether the counts of the request are anonymized, either asked for with the
+// aggregation query param or enforced for every request
+func anonymized(c *gin.Context) (bool, error) {
+	aggregation := c.Query(constants.AggregationQueryParam)
+	if aggregation != "" && aggregation != constants.AnonymizedAggregation {
+		return false, fmt.Errorf("%s: aggregation has to be %s", constants.QueryParamValidationError,
+			constants.AnonymizedAggregation)
+	}
+	return aggregation != "" || privacy.Get().Enforced(), nil
+}
+
+// anonymizeStats is used to get the stats with the types counted less than k times suppressed
+func anonymizeStats(stats store.Stats) gin.H {
+	anonymizer := privacy.Get()
+	byType, suppressed := anonymizer.Counts(stats.ByType)
+	total, _ := anonymizer.Count(stats.Total)
+	return gin.H{"total": total, "byType": byType, "suppressed": suppressed, "k": anonymizer.K()}
+}
+
+// anonymizeRollup is used to get the rollup with the counts of the buckets below k suppressed
+func anonymizeRollup(watermark time.Time, tooLate int, buckets []rollup.Bucket) gin.H {
+	anonymizer := privacy.Get()
+	suppressed := 0
+	for i := range buckets {
+		counts, n := anonymizer.Counts(buckets[i].Counts)
+		buckets[i].Counts, suppressed = counts, suppressed+n
+		buckets[i].Late, _ = anonymizer.Count(buckets[i].Late)
+	}
+	tooLate, _ = anonymizer.Count(tooLate)
+	return gin.H{"watermark": watermark, "tooLate": tooLate, "buckets": buckets, "suppressed": suppressed,
+		"k": anonymizer.K()}
+}
@@ -0,0 +1,53 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnonymizedAggregation(t *testing.T) {
+	s := store.NewMemory(100)
+	store.Init(s)
+	for i := 0; i < constants.DefaultAnonymityThreshold+1; i++ {
+		_, err := s.Add(context.Background(), models.LogEntry{Type: "order", Level: constants.InfoLevel})
+		assert.NoError(t, err)
+	}
+	_, err := s.Add(context.Background(), models.LogEntry{Type: "refund", Level: constants.ErrorLevel})
+	assert.NoError(t, err)
+	router := api.GetRouter(api.RouterConfig{ReaderToken: "reader"})
+	get := func(path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.Header.Set("Authorization", "Bearer reader")
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	// the types counted less than k times are suppressed
+	response := get(constants.LogsStatsRoute + "?aggregation=anonymized")
+	assert.Equal(t, http.StatusOK, response.Code)
+	var stats struct {
+		Total      int            `json:"total"`
+		ByType     map[string]int `json:"byType"`
+		Suppressed int            `json:"suppressed"`
+	}
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &stats))
+	assert.Equal(t, map[string]int{"order": constants.DefaultAnonymityThreshold + 1}, stats.ByType)
+	assert.Equal(t, 1, stats.Suppressed)
+	assert.Equal(t, http.StatusBadRequest, get(constants.LogsStatsRoute+"?aggregation=raw").Code)
+
+	// the rollup refuses the filters it does not count by rather than ignoring them
+	assert.Equal(t, http.StatusOK, get(constants.LogsRollupRoute+"?aggregation=anonymized&type=order").Code)
+	assert.Equal(t, http.StatusBadRequest, get(constants.LogsRollupRoute+"?level=error").Code)
+	assert.Equal(t, http.StatusBadRequest, get(constants.LogsRollupRoute+"?label=env:prod").Code)
+	assert.Equal(t, http.StatusBadRequest, get(constants.LogsRollupRoute+"?q=level%20%3D%20error").Code)
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/angel-one/nbu-logger-service/api/grpc"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// bindProtobufEntry is used to read the entry from a LogEntry message of logger.proto
+func bindProtobufEntry(c *gin.Context) (models.LogEntry, error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		return models.LogEntry{}, err
+	}
+	entry, err := decodeProtobufEntry(body)
+	if err != nil {
+		return entry, fmt.Errorf("%s: %s", constants.RequestBodyBindError, err)
+	}
+	return entry, nil
+}
+
+// decodeProtobufEntry is used to decode a LogEntry message and validate it like the json entries
+func decodeProtobufEntry(body []byte) (models.LogEntry, error) {
+	entry, err := grpc.DecodeEntry(body)
+	if err != nil {
+		return entry, err
+	}
+	return entry, binding.Validator.ValidateStruct(&entry)
+}
@@ -0,0 +1,67 @@
+package api_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// message is used to encode the fields of a message of logger.proto
+func message(fields ...[]byte) []byte {
+	return bytes.Join(fields, nil)
+}
+
+func field(number protowire.Number, value []byte) []byte {
+	b := protowire.AppendTag(nil, number, protowire.BytesType)
+	return protowire.AppendBytes(b, value)
+}
+
+func TestLoggerProtobuf(t *testing.T) {
+	store.Init(store.NewMemory(100))
+	router := api.GetRouter(api.RouterConfig{})
+	post := func(path string, body []byte) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/v1"+path, bytes.NewReader(body))
+		request.Header.Set("Content-Type", constants.ProtobufMediaType)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+	order := message(field(1, []byte("order")), field(2, []byte("warn")),
+		field(3, message(field(1, []byte("region")), field(2, []byte("north")))),
+		field(4, []byte(`{"orderId":7}`)))
+
+	response := post(constants.LoggerRoute, order)
+	assert.Equal(t, http.StatusOK, response.Code)
+	records, err := store.Get().Query(context.Background(), store.Query{Type: "order"})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "warn", records[0].Entry.Level)
+		assert.Equal(t, "north", records[0].Entry.Labels["region"])
+		assert.Equal(t, float64(7), records[0].Entry.Data["orderId"])
+	}
+	// the entries are validated like the json entries
+	assert.Equal(t, http.StatusBadRequest, post(constants.LoggerRoute, message(field(2, []byte("info")))).Code)
+	assert.Equal(t, http.StatusBadRequest, post(constants.LoggerRoute, []byte{0xff, 0xff}).Code)
+
+	// every entry of a batch is reported on its own
+	batch := message(field(1, order), field(1, message(field(1, []byte("order")), field(2, []byte("loud")))),
+		field(1, message(field(1, []byte("refund")))))
+	response = post(constants.LoggerBatchRoute, batch)
+	assert.Equal(t, http.StatusOK, response.Code)
+	var summary ndjsonResponse
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &summary))
+	assert.Equal(t, 2, summary.Accepted)
+	assert.Equal(t, 1, summary.Rejected)
+	if assert.Len(t, summary.Errors, 1) {
+		assert.Contains(t, summary.Errors[0], "entry 1")
+	}
+}
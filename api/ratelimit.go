@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ratelimit"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimit is the middleware answering the clients over their rate with 429 and when to retry,
+// the actuator and the probes are never limited so that health checks keep working, and neither is the usage
+// of the client.
+// The RateLimit headers tell limited clients about the limit closest to running out.
+func rateLimit(c *gin.Context) {
+	path := c.Request.URL.Path
+	if strings.HasPrefix(path, constants.ActuatorPrefix) || strings.HasSuffix(path, constants.RateLimitRoute) ||
+		path == constants.LivenessRoute || path == constants.ReadinessRoute {
+		c.Next()
+		return
+	}
+	allowed, wait, usage := ratelimit.Get().Take(clientKey(c), time.Now())
+	setRateLimitHeaders(c, usage)
+	if !allowed {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": constants.RateLimitedError})
+		return
+	}
+	c.Next()
+}
+
+// setRateLimitHeaders is used to tell the client its limits, the requests it has left of the one closest to
+// running out and when that one is reset
+func setRateLimitHeaders(c *gin.Context, usage ratelimit.Usage) {
+	if !usage.Limited {
+		return
+	}
+	var policies []string
+	limit, reset := int64(usage.Burst), usage.RefillInSeconds
+	if usage.RequestsPerSecond > 0 {
+		window := int(math.Max(1, math.Ceil(float64(usage.Burst)/usage.RequestsPerSecond)))
+		policies = append(policies, fmt.Sprintf("%d;w=%d", usage.Burst, window))
+	}
+	if usage.RequestsPerDay > 0 {
+		policies = append(policies, fmt.Sprintf("%d;w=%d", usage.RequestsPerDay, constants.SecondsPerDay))
+		// the remaining requests are the ones of the daily cap when it runs out before the bucket
+		if usage.RequestsPerSecond <= 0 || int64(usage.Remaining) == usage.RemainingToday {
+			limit, reset = usage.RequestsPerDay, usage.ResetInSeconds
+		}
+	}
+	c.Header(constants.RateLimitLimitHeader, strconv.FormatInt(limit, 10))
+	c.Header(constants.RateLimitRemainingHeader, strconv.Itoa(usage.Remaining))
+	c.Header(constants.RateLimitResetHeader, strconv.Itoa(reset))
+	c.Header(constants.RateLimitPolicyHeader, strings.Join(policies, ", "))
+}
+
+// rateLimitHandler is used to get the limits of the client and how much of them it used
+func rateLimitHandler(c *gin.Context) {
+	usage := ratelimit.Get().Usage(clientKey(c), time.Now())
+	setRateLimitHeaders(c, usage)
+	c.JSON(http.StatusOK, usage)
+}
+
+// clientKey is used to identify the client by the hash of its api key, or by its ip address without a known one,
+// so that clients can not get fresh buckets by making up keys
+func clientKey(c *gin.Context) string {
+	if key := apiKey(c); key != "" {
+		if _, ok := tenants.KeyName(key); ok {
+			return constants.APIKeyClientPrefix + tenants.HashSecret(key)
+		}
+	}
+	return c.ClientIP()
+}
+
+// apiKey is used to get the api key of the request, from the X-API-Key header or as a bearer token
+func apiKey(c *gin.Context) string {
+	if key := c.GetHeader(constants.APIKeyHeader); key != "" {
+		return key
+	}
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
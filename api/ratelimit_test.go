@@ -0,0 +1,46 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitClientKey(t *testing.T) {
+	ratelimit.Init(ratelimit.Config{RequestsPerSecond: 0.001, Burst: 2})
+	defer ratelimit.Init(ratelimit.Config{})
+	get := func(router http.Handler, remote, key, forwarded string) int {
+		request := httptest.NewRequest(http.MethodGet, constants.LogsRoute, nil)
+		request.RemoteAddr = remote + ":1234"
+		if key != "" {
+			request.Header.Set(constants.APIKeyHeader, key)
+		}
+		if forwarded != "" {
+			request.Header.Set("X-Forwarded-For", forwarded)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	// made up keys do not get buckets of their own, the client is limited by its ip address
+	router := api.GetRouter(api.RouterConfig{})
+	assert.NotEqual(t, http.StatusTooManyRequests, get(router, "10.0.0.1", "made-up-1", ""))
+	assert.NotEqual(t, http.StatusTooManyRequests, get(router, "10.0.0.1", "made-up-2", ""))
+	assert.Equal(t, http.StatusTooManyRequests, get(router, "10.0.0.1", "made-up-3", ""))
+	// and neither do forwarded headers of untrusted proxies
+	assert.Equal(t, http.StatusTooManyRequests, get(router, "10.0.0.1", "", "192.168.0.7"))
+	assert.NotEqual(t, http.StatusTooManyRequests, get(router, "10.0.0.2", "", ""))
+
+	// the forwarded headers of the trusted proxies tell the clients apart
+	router = api.GetRouter(api.RouterConfig{TrustedProxies: []string{"10.0.1.0/24"}})
+	assert.NotEqual(t, http.StatusTooManyRequests, get(router, "10.0.1.1", "", "192.168.1.1"))
+	assert.NotEqual(t, http.StatusTooManyRequests, get(router, "10.0.1.1", "", "192.168.1.1"))
+	assert.Equal(t, http.StatusTooManyRequests, get(router, "10.0.1.1", "", "192.168.1.1"))
+	assert.NotEqual(t, http.StatusTooManyRequests, get(router, "10.0.1.1", "", "192.168.1.2"))
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/crashes"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/angel-one/nbu-logger-service/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+// recovery is the middleware answering the requests that panicked with a 500, the panic is recorded with its
+// stack and the request so that it is reported as an entry
+func recovery(c *gin.Context) {
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+		if recovered == http.ErrAbortHandler {
+			// the handler aborted the response on purpose, the server drops the connection
+			panic(recovered)
+		}
+		p := crashes.Panic{Value: fmt.Sprint(recovered), Stack: string(debug.Stack()), Method: c.Request.Method,
+			Path: c.Request.URL.Path, Route: c.FullPath(), Query: c.Request.URL.RawQuery, Client: c.ClientIP()}
+		p.Tenant, _ = tenants.FromContext(c)
+		if span, ok := tracing.FromContext(c.Request.Context()); ok {
+			p.TraceID = span.TraceID
+		}
+		p = crashes.Get().Record(c.Request.Context(), p)
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": constants.InternalServerError, "id": p.ID})
+	}()
+	c.Next()
+}
+
+// panicsHandler returns the latest panics the service recovered from
+func panicsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"panics": crashes.Get().List()})
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/replication"
+	"github.com/gin-gonic/gin"
+)
+
+// promoteRequest names the lost instance whose snapshot is promoted
+type promoteRequest struct {
+	Instance string `json:"instance" binding:"required"`
+}
+
+func replicationStatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, replication.Get().Status())
+}
+
+// promoteHandler delivers the replicated snapshot of a lost instance, it is meant to be called once on an
+// instance of the surviving region after the instance or its region was lost
+func promoteHandler(c *gin.Context) {
+	var request promoteRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+	promotion, err := replication.Get().Promote(c, request.Instance)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, promotion)
+	case errors.Is(err, replication.ErrDisabled), errors.Is(err, replication.ErrPromoted):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, replication.ErrOwnSnapshot):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, replication.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
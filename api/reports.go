@@ -0,0 +1,46 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/reports"
+	"github.com/gin-gonic/gin"
+)
+
+// reportsHandler returns the names of the scheduled reports
+func reportsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"reports": reports.Names()})
+}
+
+// previewReportHandler renders the report as it would be delivered now, in its format or the one of the format
+// query param, without delivering it
+func previewReportHandler(c *gin.Context) {
+	content, mediaType, err := reports.Preview(c, c.Param(constants.IDPathParam),
+		c.Query(constants.FormatQueryParam), time.Now())
+	if err != nil {
+		reportError(c, err)
+		return
+	}
+	c.Data(http.StatusOK, mediaType, content)
+}
+
+// runReportHandler builds the report up to now and delivers it right away, off schedule
+func runReportHandler(c *gin.Context) {
+	result, err := reports.Run(c, c.Param(constants.IDPathParam), time.Now())
+	if err != nil {
+		reportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func reportError(c *gin.Context, err error) {
+	if errors.Is(err, reports.ErrUnknownReport) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
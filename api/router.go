@@ -1,6 +1,8 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/angel-one/nbu-logger-service/constants"
 	"github.com/gin-gonic/gin"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -11,20 +13,71 @@ func init() {
 	gin.SetMode(gin.ReleaseMode)
 }
 
+// RouterConfig is the configuration of the process the router reports
+type RouterConfig struct {
+	Env  string
+	Port int
+	Mode string
+	// ReaderToken is the bearer token the stored entries are read with, they can not be read without one
+	ReaderToken string
+	// AdminToken is the bearer token of the admin api, it can not be used without one
+	AdminToken string
+	// TrustedProxies are the addresses and the cidr ranges of the proxies whose forwarded headers tell the
+	// ip address of the client, none by default
+	TrustedProxies []string
+	// CompressionMinSizeInBytes is the size from which the responses are compressed when the client allows it
+	CompressionMinSizeInBytes int
+}
+
 // GetRouter is used to get the router configured with the middlewares and the routes
-func GetRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
+func GetRouter(config RouterConfig, middlewares ...gin.HandlerFunc) http.Handler {
 	router := gin.New()
+	// let handlers passing the gin context on see the values of the request context, e.g. the tenant
+	router.ContextWithFallback = true
+	// the ip addresses the clients are limited by are only taken from the headers of the trusted proxies
+	if err := router.SetTrustedProxies(config.TrustedProxies); err != nil {
+		_ = router.SetTrustedProxies(nil)
+	}
 	router.Use(middlewares...)
-	router.Use(gin.Recovery())
+	// responses such as the query results are large, so they are compressed when the client allows it
+	router.Use(compression(config.CompressionMinSizeInBytes))
+	router.Use(recovery)
+	router.Use(tenantResolver(config.AdminToken))
+	router.Use(apiKeyName)
+	router.Use(rateLimit)
+	router.NoRoute(notFound)
 
 	// configure swagger
 	router.GET(constants.SwaggerRoute, ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	// configure actuator
-	router.GET(constants.ActuatorRoute, actuator)
+	router.GET(constants.ActuatorRoute, actuator(config))
+
+	// configure the metrics derived from the entries and the metrics of the service
+	router.GET(constants.MetricsRoute, metricsHandler)
+
+	// configure the kubernetes probes
+	router.GET(constants.LivenessRoute, livenessHandler)
+	router.GET(constants.ReadinessRoute, readinessHandler)
 
 	// Configure Logger routes
 	SetupLoggerRoutes(router)
 
-	return router
+	// Configure query routes, they need the reader token
+	read := []gin.HandlerFunc{readerAuth(config.ReaderToken, config.AdminToken), readScope(config.AdminToken)}
+	SetupLogsRoutes(router, read...)
+	SetupGrafanaRoutes(router, read...)
+	SetupDecryptRoutes(router, readScope(config.AdminToken))
+
+	// Configure admin routes, they need the admin token
+	auth := adminAuth(config.AdminToken)
+	SetupAdminRoutes(router, auth)
+	SetupGitOpsRoutes(router, auth)
+	SetupOnboardingRoutes(router, auth)
+
+	// Configure the slack app routes
+	SetupSlackRoutes(router)
+
+	// the requests of the path prefixes of the tenants are routed without the prefix
+	return tenantPathPrefix(router)
 }
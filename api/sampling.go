@@ -0,0 +1,25 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/escalation"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/gin-gonic/gin"
+)
+
+// samplingEscalationHandler returns the step of the escalation ladder applied and the signals it was reached on
+func samplingEscalationHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, escalation.Get().Status())
+}
+
+// forceSample is the middleware keeping every entry of the requests with X-Force-Sample set to true, whatever
+// the sampling of their types. The minimum level still applies.
+func forceSample(c *gin.Context) {
+	if force, _ := strconv.ParseBool(c.GetHeader(constants.ForceSampleHeader)); force {
+		c.Request = c.Request.WithContext(pipeline.WithForceSample(c.Request.Context()))
+	}
+	c.Next()
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/slack"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/gin-gonic/gin"
+)
+
+// SetupSlackRoutes is used to set up the request urls of the slash commands and the interactivity of the slack app
+func SetupSlackRoutes(router *gin.Engine) {
+	group := router.Group("", verifySlack)
+	group.POST(constants.SlackCommandsRoute, slackCommandHandler)
+	group.POST(constants.SlackActionsRoute, slackActionHandler)
+}
+
+// verifySlack only lets the requests signed by slack through, the body is kept for the handlers to read. The
+// requests only read the entries of the tenant of the app, as the tenants of their hosts and path prefixes can be
+// sent by anyone.
+func verifySlack(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err == nil {
+		err = slack.Verify(body, c.GetHeader(constants.SlackTimestampHeader),
+			c.GetHeader(constants.SlackSignatureHeader), time.Now())
+	}
+	if errors.Is(err, slack.ErrDisabled) {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": constants.InvalidSlackSignatureError})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request = c.Request.WithContext(tenants.WithScope(c.Request.Context(), slack.Tenant()))
+	c.Next()
+}
+
+// slackCommandHandler runs a slash command, slack shows the response to the user or the channel
+func slackCommandHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, slack.Run(c, slack.Command{TeamID: c.PostForm("team_id"),
+		UserName: c.PostForm("user_name"), Text: c.PostForm("text")}, time.Now()))
+}
+
+// slackActionHandler handles the buttons of the alerts, the response is sent to the response url of the action
+// as slack does not show the responses of the interactivity requests
+func slackActionHandler(c *gin.Context) {
+	response, url, err := slack.Act([]byte(c.PostForm(constants.SlackPayloadField)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if url != "" {
+		ctx := c.Copy()
+		go func() {
+			if err := slack.Respond(url, response); err != nil {
+				log.Error(ctx).Err(err).Msg("error responding to slack action")
+			}
+		}()
+	}
+	c.Status(http.StatusOK)
+}
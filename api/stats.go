@@ -0,0 +1,45 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/stats"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/gin-gonic/gin"
+)
+
+// countedBody counts the bytes read from the body of a request
+type countedBody struct {
+	io.ReadCloser
+	read int64
+}
+
+func (b *countedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+// countBytes is the middleware counting the bytes of the bodies of the ingestion requests against their tenant,
+// as they were received, before they are decompressed
+func countBytes(c *gin.Context) {
+	body := &countedBody{ReadCloser: c.Request.Body}
+	c.Request.Body = body
+	c.Next()
+	tenant, _ := tenants.FromContext(c.Request.Context())
+	stats.Get().Received(tenant, body.read)
+}
+
+// statsHandler returns the latest snapshot of the process and the aggregate of the ones of all the instances,
+// the snapshot of the process is returned along with the error when the ones of the others can not be read
+func statsHandler(c *gin.Context) {
+	collector := stats.Get()
+	instance := collector.Latest()
+	cluster, err := collector.Cluster(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "instance": instance})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"instance": instance, "cluster": cluster})
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/gin-gonic/gin"
+)
+
+// tenantResolver resolves the tenant of the request, from its path prefix, its api key, its X-Tenant-ID header,
+// or the X-Scope-OrgID one of the loki clients, or its Host header, the first one found. The tenant headers are
+// only honored along with an api key of the tenant they name, or the admin token, as they would otherwise let any
+// client write as any tenant.
+func tenantResolver(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant, ok := tenants.FromContext(c.Request.Context())
+		if !ok {
+			if key := apiKey(c); key != "" {
+				tenant, ok = tenants.ByKey(key)
+			}
+			header := c.GetHeader(constants.TenantIDHeader)
+			if header == "" {
+				header = c.GetHeader(constants.ScopeOrgIDHeader)
+			}
+			if header != "" {
+				switch {
+				case ok && header != tenant:
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": constants.TenantMismatchError})
+					return
+				case !ok && !hasToken(c, adminToken):
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": constants.TenantKeyRequiredError})
+					return
+				case !ok && !tenants.Exists(header):
+					c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": constants.UnknownTenantError})
+					return
+				}
+				tenant, ok = header, true
+			}
+			if !ok {
+				tenant, ok = tenants.ByHost(c.Request.Host)
+			}
+			if ok {
+				c.Request = c.Request.WithContext(tenants.WithTenant(c.Request.Context(), tenant))
+			}
+		}
+		if ok && tenants.Disabled(tenant) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": constants.TenantDisabledError})
+			return
+		}
+		c.Next()
+	}
+}
+
+// readScope is the middleware scoping the reads to the tenant of the api key of the request. Only the admin token
+// reads the entries of every tenant, or of the one its X-Tenant-ID names. The tenants of the hosts and the path
+// prefixes are never read as, unlike the keys, anyone can send them. Without registered tenants every entry is
+// read, as in single tenant deployments.
+func readScope(adminToken string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		if tenant, ok := tenants.ByKey(c.GetHeader(constants.APIKeyHeader)); ok {
+			c.Request = c.Request.WithContext(tenants.WithScope(ctx, tenant))
+		} else if hasToken(c, adminToken) {
+			c.Request = c.Request.WithContext(tenants.WithScope(ctx, c.GetHeader(constants.TenantIDHeader)))
+		} else if len(tenants.IDs()) > 0 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": constants.ReadTenantRequiredError})
+			return
+		}
+		c.Next()
+	}
+}
+
+// tenantQuota is the middleware answering the tenants that used up their daily quota with 429 until it is
+// reset, before their entries are read
+func tenantQuota(c *gin.Context) {
+	if tenant, ok := tenants.FromContext(c.Request.Context()); ok {
+		if exhausted, wait := tenants.Exhausted(c, tenant, time.Now()); exhausted {
+			quotaExceeded(c, wait, constants.QuotaExceededError)
+			c.Abort()
+			return
+		}
+	}
+	c.Next()
+}
+
+// quotaExceeded is used to answer with 429 and when the quota is reset
+func quotaExceeded(c *gin.Context, wait time.Duration, message string) {
+	if wait <= 0 {
+		wait = time.Second
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message})
+}
+
+// apiKeyName resolves the name of the api key the request is sent with, so that the entries it carries can be
+// told apart by producer
+func apiKeyName(c *gin.Context) {
+	if secret := c.GetHeader(constants.APIKeyHeader); secret != "" {
+		if name, ok := tenants.KeyName(secret); ok {
+			c.Request = c.Request.WithContext(tenants.WithKeyName(c.Request.Context(), name))
+		}
+	}
+	c.Next()
+}
+
+// tenantPathPrefix routes the requests starting with the path prefix of a tenant to the ingestion route without
+// the prefix, e.g. /tenant-a/logger to /logger or /tenant-a/v1/logger to /v1/logger. The path is rewritten before
+// the request is routed, as routing it again would run the middlewares twice.
+func tenantPathPrefix(router http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, path, ok := tenants.ByPathPrefix(r.URL.Path)
+		if ok && (strings.HasPrefix(path, constants.LoggerRoute) ||
+			strings.HasPrefix(path, "/"+constants.APIVersionV1+constants.LoggerRoute)) {
+			r = r.WithContext(tenants.WithTenant(r.Context(), tenant))
+			rewritten := *r.URL
+			rewritten.Path, rewritten.RawPath = path, ""
+			r.URL = &rewritten
+		}
+		router.ServeHTTP(w, r)
+	})
+}
+
+// notFound answers the requests that match no route
+func notFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, gin.H{"error": http.StatusText(http.StatusNotFound)})
+}
@@ -0,0 +1,166 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantPathPrefix(t *testing.T) {
+	reg := registry.New()
+	tenants.Register(reg)
+	_, err := reg.Put(constants.TenantsResourceKind, "acme", json.RawMessage(`{"name":"Acme","pathPrefix":"acme",`+
+		`"hosts":["logs.acme.internal"]}`), registry.Precondition{}, "alice")
+	assert.NoError(t, err)
+	store.Init(store.NewMemory(100))
+	calls := 0
+	router := api.GetRouter(api.RouterConfig{}, func(c *gin.Context) {
+		calls++
+		c.Next()
+	})
+	post := func(host, path string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"type":"order"}`))
+		request.Header.Set("Content-Type", "application/json")
+		if host != "" {
+			request.Host = host
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	// the requests of the prefix and the host are tagged with the tenant, going through the middlewares once
+	assert.Equal(t, http.StatusOK, post("", "/acme"+constants.LoggerRoute).Code)
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, http.StatusOK, post("logs.acme.internal", constants.LoggerRoute).Code)
+	assert.Equal(t, http.StatusOK, post("", constants.LoggerRoute).Code)
+	records, err := store.Get().Query(context.Background(), store.Query{})
+	assert.NoError(t, err)
+	tagged := 0
+	for _, record := range records {
+		if meta, ok := record.Entry.Data[constants.MetaNamespace].(map[string]interface{}); ok &&
+			meta[constants.MetaTenantKey] == "acme" {
+			tagged++
+		}
+	}
+	assert.Equal(t, 2, tagged)
+
+	assert.Equal(t, http.StatusNotFound, post("", "/unknown"+constants.LoggerRoute).Code)
+	assert.Equal(t, http.StatusNotFound, post("", "/acme/unknown").Code)
+
+	// the requests of disabled tenants are rejected
+	_, err = reg.Put(constants.TenantsResourceKind, "acme", json.RawMessage(`{"name":"Acme","pathPrefix":"acme",`+
+		`"hosts":["logs.acme.internal"],"disabled":true}`), registry.Precondition{Version: 1}, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, post("", "/acme"+constants.LoggerRoute).Code)
+	assert.Equal(t, http.StatusForbidden, post("logs.acme.internal", constants.LoggerRoute).Code)
+}
+
+func TestTenantScope(t *testing.T) {
+	reg := registry.New()
+	tenants.Register(reg)
+	resources := []struct{ kind, id, spec string }{
+		{constants.TenantsResourceKind, "acme", `{"name":"Acme","hosts":["logs.acme.internal"]}`},
+		{constants.TenantsResourceKind, "globex", `{"name":"Globex"}`},
+		{constants.KeysResourceKind, "acme-key", `{"tenant":"acme","secret":"acme-secret"}`},
+		{constants.KeysResourceKind, "globex-key", `{"tenant":"globex","secret":"globex-secret"}`},
+	}
+	for _, resource := range resources {
+		_, err := reg.Put(resource.kind, resource.id, json.RawMessage(resource.spec), registry.Precondition{}, "alice")
+		assert.NoError(t, err)
+	}
+	// the tenants are dropped afterwards, so that the other tests read as in single tenant deployments
+	t.Cleanup(func() {
+		for i := len(resources) - 1; i >= 0; i-- {
+			_ = reg.Delete(resources[i].kind, resources[i].id, registry.Precondition{}, "alice")
+		}
+	})
+	store.Init(store.NewMemory(100))
+	router := api.GetRouter(api.RouterConfig{ReaderToken: "reader", AdminToken: "admin"})
+	serve := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			if name == "Host" {
+				request.Host = value
+			}
+			request.Header.Set(name, value)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+	reader := func(headers map[string]string) map[string]string {
+		headers["Authorization"] = "Bearer reader"
+		return headers
+	}
+	acme := map[string]string{constants.APIKeyHeader: "acme-secret"}
+	globex := map[string]string{constants.APIKeyHeader: "globex-secret"}
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, constants.LoggerRoute, `{"type":"order"}`, acme).Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, constants.LoggerRoute, `{"type":"invoice"}`, globex).Code)
+
+	// the tenant header is only honored along with an api key of the tenant or the admin token
+	response := serve(http.MethodPost, constants.LoggerRoute, `{"type":"forged"}`,
+		map[string]string{constants.TenantIDHeader: "acme"})
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = serve(http.MethodGet, constants.LogsRoute, "", reader(map[string]string{constants.TenantIDHeader: "acme"}))
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = serve(http.MethodGet, constants.LogsRoute, "",
+		reader(map[string]string{constants.APIKeyHeader: "globex-secret", constants.TenantIDHeader: "acme"}))
+	assert.Equal(t, http.StatusForbidden, response.Code)
+
+	// the reads need a key of a tenant, the host of a tenant does not scope them
+	response = serve(http.MethodGet, constants.LogsStatsRoute, "", reader(map[string]string{}))
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = serve(http.MethodGet, constants.LogsStatsRoute, "", reader(map[string]string{"Host": "logs.acme.internal"}))
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+
+	// the reads of a tenant only see its entries
+	var page struct {
+		Entries []store.Record `json:"entries"`
+	}
+	response = serve(http.MethodGet, constants.LogsRoute, "", reader(acme))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &page))
+	if assert.Len(t, page.Entries, 1) {
+		assert.Equal(t, "order", page.Entries[0].Entry.Type)
+	}
+	var stats store.Stats
+	response = serve(http.MethodGet, constants.LogsStatsRoute, "", reader(globex))
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &stats))
+	assert.Equal(t, map[string]int{"invoice": 1}, stats.ByType)
+	response = serve(http.MethodGet, constants.LogsExportRoute, "", reader(globex))
+	assert.Equal(t, 1, strings.Count(response.Body.String(), "\n"))
+	assert.Contains(t, response.Body.String(), "invoice")
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, constants.LogsRollupRoute, "", reader(globex)).Code)
+	// and so do the grafana dashboards
+	response = serve(http.MethodPost, constants.GrafanaSearchRoute, "{}",
+		map[string]string{constants.APIKeyHeader: "globex-secret"})
+	assert.Equal(t, http.StatusUnauthorized, response.Code)
+	response = serve(http.MethodPost, constants.GrafanaSearchRoute, "{}", reader(globex))
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `["type = \"invoice\""]`, response.Body.String())
+
+	// the admin token reads the entries of every tenant, or of the one it names
+	admin := map[string]string{"Authorization": "Bearer admin"}
+	response = serve(http.MethodGet, constants.LogsStatsRoute, "", admin)
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &stats))
+	assert.Equal(t, 2, stats.Total)
+	admin[constants.TenantIDHeader] = "acme"
+	stats = store.Stats{}
+	response = serve(http.MethodGet, constants.LogsStatsRoute, "", admin)
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &stats))
+	assert.Equal(t, map[string]int{"order": 1}, stats.ByType)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/gin-gonic/gin"
+)
+
+// loggerTextHandler ingests a raw text body, e.g. an imported log file, where every message is one or more lines.
+// Lines are reassembled into messages using the multiline rules of the type passed in the query.
+func loggerTextHandler(c *gin.Context) {
+	entryType := c.Query(constants.TypeQueryParam)
+	if entryType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: type is required",
+			constants.QueryParamValidationError)})
+		return
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(c.Request.Body)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), constants.MaxTextLineBytes)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		c.JSON(bodyStatus(err), gin.H{"error": fmt.Sprintf("%s: %s", constants.RequestBodyBindError, err)})
+		return
+	}
+
+	accepted, rejected := 0, 0
+	var failures []string
+	for _, message := range pipeline.Get().Reassembler().Reassemble(entryType, lines) {
+		entry := models.LogEntry{
+			Type: entryType,
+			Data: map[string]interface{}{constants.MessageField: message},
+		}
+		if err := ingest.Entry(c, &entry); err != nil {
+			rejected++
+			failures = append(failures, err.Error())
+			continue
+		}
+		accepted++
+	}
+	c.JSON(http.StatusOK, gin.H{"accepted": accepted, "rejected": rejected, "errors": failures})
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+// traced is the middleware continuing the trace of the caller from its traceparent and tracestate headers, the
+// request is a server span of it, or of a new trace without them
+func traced(c *gin.Context) {
+	ctx := c.Request.Context()
+	if remote, ok := tracing.Extract(c.Request.Header); ok {
+		ctx = tracing.ContextWithRemote(ctx, remote)
+	}
+	ctx, span := tracing.Start(ctx, c.Request.Method+" "+c.FullPath(), tracing.KindServer)
+	span.SetAttribute(constants.MethodAttribute, c.Request.Method)
+	span.SetAttribute(constants.RouteAttribute, c.FullPath())
+	c.Request = c.Request.WithContext(ctx)
+	c.Next()
+	span.SetAttribute(constants.StatusCodeAttribute, c.Writer.Status())
+	var err error
+	if c.Writer.Status() >= http.StatusInternalServerError {
+		err = fmt.Errorf("request failed with %d", c.Writer.Status())
+	}
+	span.End(err)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/gin-gonic/gin"
+)
+
+// versionedHandlers are the handlers of the routes of the api versions by method and path, the routes of each
+// version are listed in constants.APIVersions
+var versionedHandlers = map[string]gin.HandlerFunc{
+	http.MethodPost + constants.LoggerRoute: withCanary(constants.LoggerCanary, loggerHandler,
+		loggerPooledHandler),
+	http.MethodPost + constants.LoggerBatchRoute:   loggerBatchHandler,
+	http.MethodPost + constants.LoggerTextRoute:    loggerTextHandler,
+	http.MethodGet + constants.LoggerPixelRoute:    loggerPixelHandler,
+	http.MethodPost + constants.LoggerJournalRoute: loggerJournalHandler,
+	http.MethodPost + constants.JournalUploadRoute: loggerJournalHandler,
+	http.MethodPost + constants.LokiPushRoute:      lokiPushHandler,
+	http.MethodGet + constants.RateLimitRoute:      rateLimitHandler,
+}
+
+// setupVersionedRoutes is used to mount the routes of every api version.
+// The unversioned routes of the first version stay as deprecated aliases for the clients predating versioning.
+func setupVersionedRoutes(router *gin.Engine) {
+	for version, routes := range constants.APIVersions {
+		group := router.Group("/"+version, countBytes, traced, limitBody, apiVersion(version), captureRejected,
+			tenantQuota, forceSample, deliveryClass, enrichClient)
+		for _, r := range routes {
+			group.Handle(r.Method, r.Path, versionedHandlers[r.Method+r.Path])
+		}
+	}
+	legacy := router.Group("", countBytes, traced, limitBody, apiVersion(constants.APIVersionV1),
+		deprecated(constants.APIVersionV1), captureRejected, tenantQuota, forceSample, deliveryClass, enrichClient)
+	for _, r := range constants.APIVersions[constants.APIVersionV1] {
+		if !r.VersionedOnly {
+			legacy.Handle(r.Method, r.Path, versionedHandlers[r.Method+r.Path])
+		}
+	}
+}
+
+// apiVersion is the middleware rejecting requests asking for another version with 406 and telling the
+// version that served the request
+func apiVersion(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if requested := c.GetHeader(constants.AcceptVersionHeader); requested != "" && requested != version {
+			supported := make([]string, 0, len(constants.APIVersions))
+			for v := range constants.APIVersions {
+				supported = append(supported, v)
+			}
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{"error": constants.UnsupportedAPIVersionError,
+				"requested": requested, "served": version, "supported": supported})
+			return
+		}
+		c.Header(constants.APIVersionHeader, version)
+		c.Next()
+	}
+}
+
+// deprecated is the middleware pointing the clients of an unversioned route to its successor
+func deprecated(version string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header(constants.DeprecationHeader, "true")
+		c.Header("Link", "</"+version+c.FullPath()+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package api_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIVersions(t *testing.T) {
+	store.Init(store.NewMemory(100))
+	router := api.GetRouter(api.RouterConfig{})
+	serve := func(method, path string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(`{"type":"order"}`))
+		request.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	// every route of a version is mounted under it
+	for version, routes := range constants.APIVersions {
+		for _, route := range routes {
+			response := serve(route.Method, "/"+version+route.Path, nil)
+			assert.NotEqual(t, http.StatusNotFound, response.Code, route.Path)
+			assert.Equal(t, version, response.Header().Get(constants.APIVersionHeader), route.Path)
+			assert.Empty(t, response.Header().Get(constants.DeprecationHeader), route.Path)
+		}
+	}
+
+	// the unversioned aliases point to their successor
+	response := serve(http.MethodPost, constants.LoggerRoute, nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "true", response.Header().Get(constants.DeprecationHeader))
+	assert.Equal(t, `</v1/logger>; rel="successor-version"`, response.Header().Get("Link"))
+	assert.Equal(t, constants.APIVersionV1, response.Header().Get(constants.APIVersionHeader))
+	// the routes added after versioning have no alias
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, constants.LoggerBatchRoute, nil).Code)
+
+	response = serve(http.MethodPost, "/v1"+constants.LoggerRoute, map[string]string{constants.AcceptVersionHeader: "v2"})
+	assert.Equal(t, http.StatusNotAcceptable, response.Code)
+	assert.Contains(t, response.Body.String(), constants.UnsupportedAPIVersionError)
+	response = serve(http.MethodPost, "/v1"+constants.LoggerRoute, map[string]string{constants.AcceptVersionHeader: "v1"})
+	assert.Equal(t, http.StatusOK, response.Code)
+}
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/violations"
+	"github.com/gin-gonic/gin"
+)
+
+// validationFailuresHandler returns the entries rejected by validation grouped by api key, type and violated
+// constraint, the groups with the most failures first, optionally of one api key, type or constraint
+func validationFailuresHandler(c *gin.Context) {
+	filter := violations.Filter{
+		APIKey:     c.Query(constants.APIKeyQueryParam),
+		Type:       c.Query(constants.TypeQueryParam),
+		Constraint: c.Query(constants.ConstraintQueryParam),
+		Limit:      constants.DefaultQueryLimit,
+	}
+	if limit := c.Query(constants.LimitQueryParam); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l <= 0 || l > constants.MaxQueryLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: limit has to be between 1 and %d",
+				constants.QueryParamValidationError, constants.MaxQueryLimit)})
+			return
+		}
+		filter.Limit = l
+	}
+	c.JSON(http.StatusOK, gin.H{"groups": violations.Get().List(filter)})
+}
@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/angel-one/nbu-logger-service/violations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationFailures(t *testing.T) {
+	reg := registry.New()
+	tenants.Register(reg)
+	_, err := reg.Put(constants.TenantsResourceKind, "acme", json.RawMessage(`{"name":"Acme"}`),
+		registry.Precondition{}, "alice")
+	assert.NoError(t, err)
+	_, err = reg.Put(constants.KeysResourceKind, "acme-checkout", json.RawMessage(`{"name":"checkout",`+
+		`"tenant":"acme","secret":"s3cret"}`), registry.Precondition{}, "alice")
+	assert.NoError(t, err)
+	store.Init(store.NewMemory(100))
+	violations.Init(violations.Config{})
+	router := api.GetRouter(api.RouterConfig{AdminToken: "admin"})
+	serve := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		for name, value := range headers {
+			request.Header.Set(name, value)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	// the entries are counted by the name of their api key, or as anonymous with an unknown one
+	invalid := `{"type":"payment","labels":{"bad key":"x"}}`
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, constants.LoggerRoute, invalid,
+		map[string]string{constants.APIKeyHeader: "s3cret"}).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, serve(http.MethodPost, constants.LoggerRoute, invalid,
+		map[string]string{constants.APIKeyHeader: "guess"}).Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, constants.LoggerRoute, `{"type":"payment"}`,
+		map[string]string{constants.APIKeyHeader: "s3cret"}).Code)
+
+	response := serve(http.MethodGet, constants.AdminRoute+constants.ValidationFailuresRoute+"?type=payment", "",
+		map[string]string{"Authorization": "Bearer admin"})
+	assert.Equal(t, http.StatusOK, response.Code)
+	var report struct {
+		Groups []violations.Group `json:"groups"`
+	}
+	assert.NoError(t, json.Unmarshal(response.Body.Bytes(), &report))
+	if assert.Len(t, report.Groups, 2) {
+		keys := []string{report.Groups[0].APIKey, report.Groups[1].APIKey}
+		assert.ElementsMatch(t, []string{"checkout", constants.AnonymousAPIKey}, keys)
+		assert.Equal(t, "pipeline.labels", report.Groups[0].Constraint)
+		assert.Equal(t, int64(1), report.Groups[0].Count)
+	}
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodGet, constants.AdminRoute+
+		constants.ValidationFailuresRoute+"?limit=0", "", map[string]string{"Authorization": "Bearer admin"}).Code)
+
+	response = serve(http.MethodGet, constants.MetricsRoute, "", nil)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), constants.ValidationFailuresMetric+
+		`{api_key="checkout",type="payment",constraint="pipeline.labels"} 1`)
+}
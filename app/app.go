@@ -0,0 +1,204 @@
+// Package app wires the components of the service together. The api process, the worker process and tests
+// all start the service through App, so they share the same wiring and only differ in the dependencies given.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/queue"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/angel-one/nbu-logger-service/wal"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+)
+
+// Config is the configuration of the process
+type Config struct {
+	// Env is the runtime environment
+	Env string
+	// Port is the port the http api listens on, a free port is picked when 0
+	Port int
+	// Mode is the role of the process when entries go through the queue, api, worker or both when empty
+	Mode string
+}
+
+// Dependencies are the components the application is wired from
+type Dependencies struct {
+	// Configs is used to get a configuration by its name
+	Configs func(name string) (*viper.Viper, error)
+	// Middlewares are run before the routes, e.g. the request logger
+	Middlewares []gin.HandlerFunc
+	// Sinks are emitted to along with the configured sinks
+	Sinks []sinks.Sink
+	// Persist is used to persist the processed entries, ingest.Persist when not given
+	Persist queue.Handler
+}
+
+// serverConfig is the tuning of the http server of the api
+type serverConfig struct {
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+	// compressionMinSize is the size from which the responses are compressed
+	compressionMinSize int
+}
+
+// App is the service wired from its dependencies
+type App struct {
+	config       Config
+	dependencies Dependencies
+	serverConfig serverConfig
+	router       http.Handler
+	server       *http.Server
+	listener     net.Listener
+	cancel       context.CancelFunc
+	done         chan error
+	stopOnce     sync.Once
+}
+
+// New is used to create the application, nothing is started until Start
+func New(config Config, dependencies Dependencies) (*App, error) {
+	if config.Mode != "" && config.Mode != constants.APIMode && config.Mode != constants.WorkerMode {
+		return nil, fmt.Errorf("mode %s has to be %s or %s", config.Mode, constants.APIMode, constants.WorkerMode)
+	}
+	if dependencies.Configs == nil {
+		return nil, errors.New("configs are required")
+	}
+	if dependencies.Persist == nil {
+		dependencies.Persist = ingest.Persist
+	}
+	return &App{config: config, dependencies: dependencies, done: make(chan error, 1)}, nil
+}
+
+// Start is used to set up the components and start serving in the background.
+// The components stop with the context or with Stop, whichever comes first.
+func (a *App) Start(ctx context.Context) error {
+	ctx, a.cancel = context.WithCancel(ctx)
+	if err := a.setup(ctx); err != nil {
+		a.cancel()
+		return err
+	}
+	if a.config.Mode == constants.WorkerMode {
+		log.Info(ctx).Msg("persisting queued log entries")
+		if err := queue.Start(a.dependencies.Persist); err != nil {
+			a.cancel()
+			return fmt.Errorf("error running ingestion workers : %w", err)
+		}
+		return nil
+	}
+	trustedProxies, err := a.trustedProxies()
+	if err != nil {
+		a.cancel()
+		return err
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", a.config.Port))
+	if err != nil {
+		a.cancel()
+		return fmt.Errorf("error listening on port %d : %w", a.config.Port, err)
+	}
+	a.listener = listener
+	a.router = api.GetRouter(api.RouterConfig{Env: a.config.Env, Port: a.config.Port, Mode: a.config.Mode,
+		ReaderToken: a.readerToken(), AdminToken: a.adminToken(), TrustedProxies: trustedProxies,
+		CompressionMinSizeInBytes: a.serverConfig.compressionMinSize}, a.dependencies.Middlewares...)
+	a.server = &http.Server{
+		Handler:           a.router,
+		ReadTimeout:       a.serverConfig.readTimeout,
+		ReadHeaderTimeout: a.serverConfig.readHeaderTimeout,
+		WriteTimeout:      a.serverConfig.writeTimeout,
+		IdleTimeout:       a.serverConfig.idleTimeout,
+		MaxHeaderBytes:    a.serverConfig.maxHeaderBytes,
+	}
+	go func() {
+		if err := a.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			a.done <- err
+		}
+		close(a.done)
+	}()
+	return nil
+}
+
+// Done is used to get notified when the http api stops serving on its own, with the error it stopped with
+func (a *App) Done() <-chan error {
+	return a.done
+}
+
+// Addr is used to get the address the http api listens on, nil for workers
+func (a *App) Addr() net.Addr {
+	if a.listener == nil {
+		return nil
+	}
+	return a.listener.Addr()
+}
+
+// Handler is used to get the http api, nil for workers
+func (a *App) Handler() http.Handler {
+	if a.router == nil {
+		return nil
+	}
+	return a.router
+}
+
+// Stop is used to drain the http api and the ingestion workers and stop the background components
+func (a *App) Stop(ctx context.Context) error {
+	var err error
+	a.stopOnce.Do(func() {
+		if a.server != nil {
+			err = a.server.Shutdown(ctx)
+		} else {
+			close(a.done)
+		}
+		// the entries the api accepted are replayed by the next process when they could not be delivered yet
+		wal.Stop()
+		queue.Stop()
+		if a.cancel != nil {
+			a.cancel()
+		}
+	})
+	return err
+}
+
+// readerToken is used to get the token the stored entries are read with, they can not be read without one
+func (a *App) readerToken() string {
+	if provider, err := a.dependencies.Configs(constants.StoreConfig); err == nil {
+		return provider.GetString(constants.StoreReaderTokenConfigKey)
+	}
+	return ""
+}
+
+// adminToken is used to get the token of the admin api, it can not be used without one
+func (a *App) adminToken() string {
+	if provider, err := a.dependencies.Configs(constants.ApplicationConfig); err == nil {
+		return provider.GetString(constants.ServerAdminTokenKey)
+	}
+	return ""
+}
+
+// trustedProxies is used to get the proxies the ip addresses of the clients are taken from the headers of
+func (a *App) trustedProxies() ([]string, error) {
+	provider, err := a.dependencies.Configs(constants.ApplicationConfig)
+	if err != nil {
+		return nil, nil
+	}
+	proxies := provider.GetStringSlice(constants.ServerTrustedProxiesKey)
+	for _, proxy := range proxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err = net.ParseCIDR(proxy); err != nil {
+				return nil, fmt.Errorf("invalid server config : trusted proxy %s is neither an ip address nor a cidr range",
+					proxy)
+			}
+		}
+	}
+	return proxies, nil
+}
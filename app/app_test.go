@@ -0,0 +1,112 @@
+package app_test
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/app"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct {
+	entries chan models.LogEntry
+}
+
+func (s *recordingSink) Name() string {
+	return "recording"
+}
+
+func (s *recordingSink) Send(_ context.Context, entry models.LogEntry) error {
+	s.entries <- entry
+	return nil
+}
+
+func TestApp(t *testing.T) {
+	noConfigs := func(string) (*viper.Viper, error) {
+		return nil, errors.New("not found")
+	}
+	_, err := app.New(app.Config{Mode: "both"}, app.Dependencies{Configs: noConfigs})
+	assert.Error(t, err)
+
+	sink := &recordingSink{entries: make(chan models.LogEntry, 1)}
+	application, err := app.New(app.Config{}, app.Dependencies{Configs: noConfigs, Sinks: []sinks.Sink{sink}})
+	assert.NoError(t, err)
+	assert.NoError(t, application.Start(context.Background()))
+	assert.NotNil(t, application.Addr())
+
+	request := httptest.NewRequest(http.MethodPost, constants.LoggerRoute,
+		strings.NewReader(`{"type":"app.test","data":{"message":"hello"}}`))
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	application.Handler().ServeHTTP(response, request)
+	assert.Equal(t, http.StatusOK, response.Code)
+
+	select {
+	case entry := <-sink.entries:
+		assert.Equal(t, "app.test", entry.Type)
+	case <-time.After(time.Second):
+		t.Fatal("entry was not emitted to the injected sink")
+	}
+
+	assert.NoError(t, application.Stop(context.Background()))
+	_, open := <-application.Done()
+	assert.False(t, open)
+}
+
+func TestServerConfig(t *testing.T) {
+	configs := func(minSize int) func(string) (*viper.Viper, error) {
+		return func(name string) (*viper.Viper, error) {
+			if name != constants.ApplicationConfig {
+				return nil, errors.New("not found")
+			}
+			provider := viper.New()
+			provider.Set("server.compressionMinSizeInBytes", minSize)
+			provider.Set("server.idleTimeoutInMillis", 1000)
+			return provider, nil
+		}
+	}
+	get := func(application *app.App) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, constants.LivenessRoute, nil)
+		request.Header.Set("Accept-Encoding", "gzip")
+		response := httptest.NewRecorder()
+		application.Handler().ServeHTTP(response, request)
+		return response
+	}
+
+	application, err := app.New(app.Config{}, app.Dependencies{Configs: configs(1)})
+	assert.NoError(t, err)
+	assert.NoError(t, application.Start(context.Background()))
+	response := get(application)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "gzip", response.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(response.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, body)
+	assert.NoError(t, application.Stop(context.Background()))
+
+	// the responses smaller than the minimum size are sent as they are
+	application, err = app.New(app.Config{}, app.Dependencies{Configs: configs(1 << 20)})
+	assert.NoError(t, err)
+	assert.NoError(t, application.Start(context.Background()))
+	response = get(application)
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Empty(t, response.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, response.Body.Bytes())
+	assert.NoError(t, application.Stop(context.Background()))
+
+	application, _ = app.New(app.Config{}, app.Dependencies{Configs: configs(-1)})
+	assert.Error(t, application.Start(context.Background()))
+}
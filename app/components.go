@@ -0,0 +1,961 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/alerts"
+	"github.com/angel-one/nbu-logger-service/api/grpc"
+	"github.com/angel-one/nbu-logger-service/archive"
+	"github.com/angel-one/nbu-logger-service/canary"
+	"github.com/angel-one/nbu-logger-service/capture"
+	"github.com/angel-one/nbu-logger-service/catalog"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/crashes"
+	"github.com/angel-one/nbu-logger-service/dedup"
+	"github.com/angel-one/nbu-logger-service/dlq"
+	"github.com/angel-one/nbu-logger-service/encryption"
+	"github.com/angel-one/nbu-logger-service/escalation"
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/gitops"
+	"github.com/angel-one/nbu-logger-service/health"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/inputs"
+	"github.com/angel-one/nbu-logger-service/lifecycle"
+	"github.com/angel-one/nbu-logger-service/limits"
+	"github.com/angel-one/nbu-logger-service/metrics"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/privacy"
+	"github.com/angel-one/nbu-logger-service/queue"
+	"github.com/angel-one/nbu-logger-service/ratelimit"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/angel-one/nbu-logger-service/replication"
+	"github.com/angel-one/nbu-logger-service/reports"
+	"github.com/angel-one/nbu-logger-service/rollup"
+	"github.com/angel-one/nbu-logger-service/routing"
+	"github.com/angel-one/nbu-logger-service/schemas"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/angel-one/nbu-logger-service/slack"
+	"github.com/angel-one/nbu-logger-service/stats"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/angel-one/nbu-logger-service/tracing"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+	"github.com/angel-one/nbu-logger-service/utils/logging"
+	"github.com/angel-one/nbu-logger-service/violations"
+	"github.com/angel-one/nbu-logger-service/wal"
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// setup is used to set up the components in the order they depend on each other
+func (a *App) setup(ctx context.Context) error {
+	steps := []func(context.Context) error{
+		// set the level of the logs of the service
+		a.initLogLevel,
+		// set up the http client for outgoing calls
+		a.initHTTPClient,
+		// tune the http server of the api
+		a.initServer,
+		// load the owners of the services the alerts and the reports are routed to
+		a.initCatalog,
+		// set up the security channel alerts
+		a.initAlerts,
+		// set up the webhooks of the lifecycle events
+		a.initLifecycle,
+		// set up the export of the spans of the ingestion and the sink writes
+		a.initTracing,
+		// set up the deduplication of the entries resent with an idempotency key
+		a.initDedup,
+		// keep the rejected requests for debugging
+		a.initCapture,
+		// report the panics the requests recover from
+		a.initPanics,
+		// limit the size of the payloads
+		a.initLimits,
+		// limit the requests of each client
+		a.initRateLimit,
+		// split the traffic of the routes with a candidate implementation
+		a.initCanary,
+		// set up the admin resources
+		a.initRegistry,
+		// set up the schemas the data of the entries is validated with
+		a.initSchemas,
+		// set up the types that must only be stored encrypted by their clients
+		a.initEncryption,
+		// set up the anonymized aggregation of the stats
+		a.initPrivacy,
+		// set up the processing pipeline
+		a.initPipeline,
+		// sync the admin resources from git
+		a.initGitOps,
+		// set up the tenant onboarding defaults
+		a.initOnboarding,
+		// set up the counters of the daily quotas of the tenants
+		a.initQuotas,
+		// set up the non json body formats
+		a.initFormats,
+		// derive metrics from the entries
+		a.initMetrics,
+		a.initHealth,
+		// keep the entries the sinks fail to deliver
+		a.initDLQ,
+		// set up the validation failures report
+		a.initViolations,
+		// start the sinks entries are emitted to
+		a.initSinks,
+		// route the types to their sinks and queues, once the sinks they name are started
+		a.initRouting,
+		// set up the query store
+		a.initStore,
+		// query the archives for the ranges the store no longer holds
+		a.initArchive,
+		// set up the counts of the entries by the time they were logged
+		a.initRollup,
+		// publish the snapshots of the process for the triage across the instances
+		a.initStats,
+		// schedule the reports on the stored entries
+		a.initReports,
+		// serve the slash commands and the alert buttons of the slack app
+		a.initSlack,
+		// set up the ingestion queue
+		a.initQueue,
+		// escalate the sampling of the low priority types under pressure
+		a.initEscalation,
+		// write the accepted entries to the local disk before acknowledging them, once they can be delivered
+		a.initWAL,
+		// replicate what was not delivered yet to the secondary region, once it is journaled
+		a.initReplication,
+		// start the optional listeners once what they accept can be queued and journaled,
+		// workers only persist what the api processes enqueue
+		a.initInputs,
+		// serve the optional grpc ingestion api
+		a.initGRPC,
+		// route the logs of the service, they can only be ingested once the rest is set up
+		a.initLogging,
+	}
+	for _, step := range steps {
+		if err := step(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *App) initLogLevel(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.LoggerConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("logger config not found, logging at info level")
+		return nil
+	}
+	if err = setLogLevel(provider.GetString(constants.LogLevelConfigKey)); err != nil {
+		return err
+	}
+	// the level is changed whenever the config file changes, an invalid level keeps the current one
+	provider.OnConfigChange(func(fsnotify.Event) {
+		level := provider.GetString(constants.LogLevelConfigKey)
+		if err := setLogLevel(level); err != nil {
+			log.Error(ctx).Err(err).Msg("error reloading log level")
+			return
+		}
+		log.Info(ctx).Str(constants.LogLevelKey, level).Msg("reloaded log level")
+	})
+	return nil
+}
+
+// setLogLevel is used to set the level of the logs of the service, info when empty
+func setLogLevel(level string) error {
+	if level == "" {
+		level = constants.InfoLevel
+	}
+	parsed, err := zerolog.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("invalid log level %s : %w", level, err)
+	}
+	zerolog.SetGlobalLevel(parsed)
+	return nil
+}
+
+func (a *App) initHTTPClient(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.ApplicationConfig)
+	if err != nil {
+		// without the application config the standard client is used
+		log.Warn(ctx).Err(err).Msg("application config not found, using default http client")
+		return nil
+	}
+	err = httpclient.Init(httpclient.Config{
+		ConnectTimeout:        provider.GetDuration(constants.HTTPConnectTimeoutInMillisKey) * time.Millisecond,
+		KeepAliveDuration:     provider.GetDuration(constants.HTTPKeepAliveDurationInMillisKey) * time.Millisecond,
+		MaxIdleConnections:    provider.GetInt(constants.HTTPMaxIdleConnectionsKey),
+		IdleConnectionTimeout: provider.GetDuration(constants.HTTPIdleConnectionTimeoutInMillisKey) * time.Millisecond,
+		TLSHandshakeTimeout:   provider.GetDuration(constants.HTTPTlsHandshakeTimeoutInMillisKey) * time.Millisecond,
+		ExpectContinueTimeout: provider.GetDuration(constants.HTTPExpectContinueTimeoutInMillisKey) * time.Millisecond,
+		Timeout:               provider.GetDuration(constants.HTTPTimeoutInMillisKey) * time.Millisecond,
+	})
+	if err != nil {
+		return fmt.Errorf("error initializing http client : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initServer(ctx context.Context) error {
+	a.serverConfig = serverConfig{
+		readHeaderTimeout:  constants.DefaultServerReadHeaderTimeoutInMillis * time.Millisecond,
+		idleTimeout:        constants.DefaultServerIdleTimeoutInMillis * time.Millisecond,
+		maxHeaderBytes:     constants.DefaultServerMaxHeaderBytes,
+		compressionMinSize: constants.DefaultServerCompressionMinSizeInBytes,
+	}
+	provider, err := a.dependencies.Configs(constants.ApplicationConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("application config not found, using default http server")
+		return nil
+	}
+	config := &a.serverConfig
+	config.readTimeout = provider.GetDuration(constants.ServerReadTimeoutInMillisKey) * time.Millisecond
+	config.writeTimeout = provider.GetDuration(constants.ServerWriteTimeoutInMillisKey) * time.Millisecond
+	if provider.IsSet(constants.ServerReadHeaderTimeoutInMillisKey) {
+		config.readHeaderTimeout = provider.GetDuration(constants.ServerReadHeaderTimeoutInMillisKey) *
+			time.Millisecond
+	}
+	if provider.IsSet(constants.ServerIdleTimeoutInMillisKey) {
+		config.idleTimeout = provider.GetDuration(constants.ServerIdleTimeoutInMillisKey) * time.Millisecond
+	}
+	if provider.IsSet(constants.ServerMaxHeaderBytesKey) {
+		config.maxHeaderBytes = provider.GetInt(constants.ServerMaxHeaderBytesKey)
+	}
+	if provider.IsSet(constants.ServerCompressionMinSizeInBytesKey) {
+		config.compressionMinSize = provider.GetInt(constants.ServerCompressionMinSizeInBytesKey)
+	}
+	if config.readTimeout < 0 || config.readHeaderTimeout < 0 || config.writeTimeout < 0 || config.idleTimeout < 0 ||
+		config.maxHeaderBytes < 0 || config.compressionMinSize < 0 {
+		return fmt.Errorf("invalid server config : the timeouts and the sizes can not be negative")
+	}
+	return nil
+}
+
+func (a *App) initCatalog(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.CatalogConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("catalog config not found, alerts and reports are not routed to service owners")
+		return nil
+	}
+	var config catalog.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing catalog config : %w", err)
+	}
+	if err = catalog.Init(ctx, config); err != nil {
+		return fmt.Errorf("invalid catalog config : %w", err)
+	}
+	catalog.Start(ctx)
+	return nil
+}
+
+func (a *App) initAlerts(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.AlertsConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("alerts config not found, leaked credentials are only logged")
+		return nil
+	}
+	var config alerts.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing alerts config : %w", err)
+	}
+	if err = alerts.Init(config); err != nil {
+		return fmt.Errorf("error initializing alerts : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initLifecycle(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.LifecycleConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("lifecycle config not found, lifecycle events are not sent")
+		return nil
+	}
+	var config lifecycle.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing lifecycle config : %w", err)
+	}
+	if err = lifecycle.Init(config); err != nil {
+		return fmt.Errorf("error initializing lifecycle events : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initTracing(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.TracingConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("tracing config not found, spans are not exported")
+		return nil
+	}
+	var config tracing.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing tracing config : %w", err)
+	}
+	if err = tracing.Init(ctx, config); err != nil {
+		return fmt.Errorf("error initializing tracing : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initDedup(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.DedupConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("dedup config not found, idempotency keys are only known to this process")
+		return nil
+	}
+	var config dedup.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing dedup config : %w", err)
+	}
+	if err = dedup.Init(config); err != nil {
+		return fmt.Errorf("error initializing dedup : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initDLQ(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.DLQConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("dlq config not found, dead letters are only kept by this process")
+		return nil
+	}
+	var config dlq.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing dlq config : %w", err)
+	}
+	if err = dlq.Init(config); err != nil {
+		return fmt.Errorf("error initializing dlq : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initPanics(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.PanicsConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("panics config not found, panics are reported to the store")
+		return nil
+	}
+	var config crashes.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing panics config : %w", err)
+	}
+	crashes.Init(config)
+	return nil
+}
+
+func (a *App) initLimits(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.LimitsConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("limits config not found, the default payload limits apply")
+		return nil
+	}
+	var config limits.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing limits config : %w", err)
+	}
+	limits.Init(config)
+	return nil
+}
+
+func (a *App) initMetrics(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.MetricsConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("metrics config not found, no metrics are derived from the entries")
+		return nil
+	}
+	var config metrics.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing metrics config : %w", err)
+	}
+	if err = metrics.Init(config); err != nil {
+		return fmt.Errorf("error initializing metrics : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initHealth(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.HealthConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("health config not found, using the default readiness checks")
+		return nil
+	}
+	var config health.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing health config : %w", err)
+	}
+	if err = health.Init(config); err != nil {
+		return fmt.Errorf("error initializing health : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initCapture(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.CaptureConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("capture config not found, rejected requests are kept but can not be read")
+		return nil
+	}
+	var config capture.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing capture config : %w", err)
+	}
+	capture.Init(config)
+	return nil
+}
+
+func (a *App) initRateLimit(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.RateLimitConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("rate limit config not found, clients are not limited")
+		return nil
+	}
+	var config ratelimit.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing rate limit config : %w", err)
+	}
+	if err = config.Validate(); err != nil {
+		return fmt.Errorf("invalid rate limit config : %w", err)
+	}
+	ratelimit.Init(config)
+	return nil
+}
+
+func (a *App) initCanary(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.CanaryConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("canary config not found, requests are only served by the primary handlers")
+		return nil
+	}
+	var config canary.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing canary config : %w", err)
+	}
+	canary.Init(config)
+	return nil
+}
+
+func (a *App) initRegistry(ctx context.Context) error {
+	reg := registry.Get()
+	pipeline.RegisterRules(reg)
+	schemas.Register(reg)
+	reg.RegisterKind(registry.Kind{Name: constants.TypesResourceKind, Validate: registry.ValidateObject})
+	tenants.Register(reg)
+
+	provider, err := a.dependencies.Configs(constants.RegistryConfig)
+	if err != nil || provider.GetString(constants.RegistryJournalPathConfigKey) == "" {
+		log.Warn(ctx).Msg("registry journal not configured, admin changes will not survive restarts")
+		return nil
+	}
+	err = reg.SetJournal(registry.NewFileJournal(provider.GetString(constants.RegistryJournalPathConfigKey)))
+	if err != nil {
+		return fmt.Errorf("error restoring admin resources from journal : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initSchemas(ctx context.Context) error {
+	var config schemas.Config
+	provider, err := a.dependencies.Configs(constants.SchemasConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("schemas config not found, only the schema resources are validated against")
+	} else if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing schemas config : %w", err)
+	}
+	if err = schemas.Init(config, registry.Get()); err != nil {
+		return fmt.Errorf("error loading schemas : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initEncryption(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.EncryptionConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("encryption config not found, encrypted entries can not be decrypted")
+		return nil
+	}
+	var config encryption.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing encryption config : %w", err)
+	}
+	if err = encryption.Init(config); err != nil {
+		return fmt.Errorf("error initializing encryption : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initPrivacy(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.PrivacyConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("privacy config not found, stats are only anonymized on request")
+		return nil
+	}
+	var config privacy.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing privacy config : %w", err)
+	}
+	if err = privacy.Init(config); err != nil {
+		return fmt.Errorf("error initializing privacy : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initPipeline(ctx context.Context) error {
+	var config pipeline.Config
+	provider, err := a.dependencies.Configs(constants.PipelineConfig)
+	if err != nil {
+		// the pipeline config is optional, without it entries pass through untouched
+		log.Warn(ctx).Err(err).Msg("pipeline config not found, using defaults")
+	} else if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing pipeline config : %w", err)
+	}
+	log.Info(ctx).Interface(constants.PipelineConfigKey, config).Msg("initializing pipeline")
+	if err = pipeline.SetBase(config, registry.Get()); err != nil {
+		return fmt.Errorf("error initializing pipeline : %w", err)
+	}
+	if provider != nil {
+		// rules are reloaded whenever the config file changes, an invalid change keeps the running pipeline
+		provider.OnConfigChange(func(fsnotify.Event) {
+			var changed pipeline.Config
+			if err := provider.Unmarshal(&changed); err != nil {
+				log.Error(ctx).Err(err).Msg("error parsing changed pipeline config")
+				return
+			}
+			if err := pipeline.SetBase(changed, registry.Get()); err != nil {
+				log.Error(ctx).Err(err).Msg("error reloading pipeline")
+				return
+			}
+			log.Info(ctx).Interface(constants.PipelineConfigKey, changed).Msg("reloaded pipeline")
+		})
+	}
+	return nil
+}
+
+func (a *App) initGitOps(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.GitOpsConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("gitops config not found, admin resources are managed through the api only")
+		return nil
+	}
+	gitops.Init(gitops.Config{
+		Repository:    provider.GetString(constants.GitOpsRepositoryConfigKey),
+		Branch:        provider.GetString(constants.GitOpsBranchConfigKey),
+		Path:          provider.GetString(constants.GitOpsPathConfigKey),
+		WorkDir:       provider.GetString(constants.GitOpsWorkDirConfigKey),
+		PollInterval:  provider.GetDuration(constants.GitOpsPollIntervalInSecondsConfigKey) * time.Second,
+		WebhookSecret: provider.GetString(constants.GitOpsWebhookSecretConfigKey),
+	}, registry.Get())
+	gitops.Get().Start(ctx)
+	return nil
+}
+
+func (a *App) initOnboarding(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.OnboardingConfig)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("onboarding config not found, using defaults")
+		return nil
+	}
+	var config tenants.OnboardingConfig
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing onboarding config : %w", err)
+	}
+	tenants.Init(config)
+	return nil
+}
+
+func (a *App) initQuotas(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.QuotasConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("quotas config not found, the daily quotas are counted by each process")
+		return nil
+	}
+	var config tenants.QuotasConfig
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing quotas config : %w", err)
+	}
+	if err = tenants.InitQuotas(config); err != nil {
+		return fmt.Errorf("error initializing quotas : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initFormats(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.FormatsConfig)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("formats config not found, using defaults")
+		return nil
+	}
+	var config formats.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing formats config : %w", err)
+	}
+	formats.Init(config)
+	return nil
+}
+
+func (a *App) initSinks(ctx context.Context) error {
+	for _, sink := range a.dependencies.Sinks {
+		sinks.Add(ctx, sink, 0)
+	}
+	provider, err := a.dependencies.Configs(constants.SinksConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("sinks config not found, entries are only stored and logged")
+		return nil
+	}
+	var config sinks.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing sinks config : %w", err)
+	}
+	if err = sinks.Start(ctx, config); err != nil {
+		return fmt.Errorf("error starting sinks : %w", err)
+	}
+	// the sinks are replaced whenever the config file changes, an invalid change keeps the running sinks
+	provider.OnConfigChange(func(fsnotify.Event) {
+		var changed sinks.Config
+		if err := provider.Unmarshal(&changed); err != nil {
+			log.Error(ctx).Err(err).Msg("error parsing changed sinks config")
+			return
+		}
+		if err := sinks.Reload(ctx, changed); err != nil {
+			log.Error(ctx).Err(err).Msg("error reloading sinks")
+			return
+		}
+		log.Info(ctx).Msg("reloaded sinks")
+	})
+	return nil
+}
+
+func (a *App) initRouting(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.RoutingConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("routing config not found, entries of every type go to every sink")
+		return nil
+	}
+	var config routing.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing routing config : %w", err)
+	}
+	if err = routing.Init(config); err != nil {
+		return fmt.Errorf("invalid routing config : %w", err)
+	}
+	started := make(map[string]bool)
+	for _, name := range sinks.Names() {
+		started[name] = true
+	}
+	for _, name := range routing.Get().Sinks() {
+		if !started[name] {
+			return fmt.Errorf("sink %s of the routing is not started", name)
+		}
+	}
+	return nil
+}
+
+func (a *App) initInputs(ctx context.Context) error {
+	if a.config.Mode == constants.WorkerMode {
+		return nil
+	}
+	provider, err := a.dependencies.Configs(constants.InputsConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("inputs config not found, only the http api is listening")
+		return nil
+	}
+	var config inputs.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing inputs config : %w", err)
+	}
+	if err = inputs.Start(ctx, config); err != nil {
+		return fmt.Errorf("error starting inputs : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initGRPC(ctx context.Context) error {
+	if a.config.Mode == constants.WorkerMode {
+		return nil
+	}
+	provider, err := a.dependencies.Configs(constants.GRPCConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("grpc config not found, the grpc ingestion api is disabled")
+		return nil
+	}
+	var config grpc.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing grpc config : %w", err)
+	}
+	if config.Address == "" {
+		return nil
+	}
+	if err = grpc.NewServer(config).Start(ctx); err != nil {
+		return fmt.Errorf("error starting grpc ingestion api : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initStore(ctx context.Context) error {
+	capacity := constants.DefaultStoreCapacity
+	grace := time.Duration(constants.DefaultDeletionGraceInHours) * time.Hour
+	var (
+		retention store.Retention
+		redisURL  string
+		key       = constants.DefaultStoreKey
+	)
+	provider, err := a.dependencies.Configs(constants.StoreConfig)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("store config not found, using defaults")
+	} else {
+		if err = provider.UnmarshalKey(constants.StoreRetentionConfigKey, &retention); err != nil {
+			return fmt.Errorf("error parsing store retention config : %w", err)
+		}
+		if err = retention.Validate(); err != nil {
+			return fmt.Errorf("invalid store retention config : %w", err)
+		}
+		if provider.IsSet(constants.StoreCapacityConfigKey) {
+			capacity = provider.GetInt(constants.StoreCapacityConfigKey)
+		}
+		if provider.IsSet(constants.StoreDeletionGraceInHoursConfigKey) {
+			grace = provider.GetDuration(constants.StoreDeletionGraceInHoursConfigKey) * time.Hour
+		}
+		if provider.IsSet(constants.StoreKeyConfigKey) {
+			key = provider.GetString(constants.StoreKeyConfigKey)
+		}
+		redisURL = provider.GetString(constants.StoreRedisURLConfigKey)
+	}
+	// the api and the workers share the records through redis, each process keeps its own in memory otherwise
+	if redisURL != "" {
+		s, err := store.NewRedis(redisURL, key, capacity)
+		if err != nil {
+			return err
+		}
+		store.Init(s)
+	} else {
+		store.Init(store.NewMemory(capacity))
+	}
+	// soft deleted entries are purged for good once they can no longer be restored,
+	// and the rest once the retention policy expires them unless an exemption keeps them
+	go func() {
+		ticker := time.NewTicker(constants.StorePurgeIntervalInSeconds * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				purged, err := store.Get().Purge(ctx, now.Add(-grace))
+				if err != nil {
+					log.Error(ctx).Err(err).Msg("error purging deleted entries")
+				} else if purged > 0 {
+					log.Info(ctx).Int("purged", purged).Msg("purged deleted entries")
+				}
+				expired, err := store.Get().Expire(ctx, retention, now)
+				if err != nil {
+					log.Error(ctx).Err(err).Msg("error expiring entries")
+				} else if expired > 0 {
+					log.Info(ctx).Int("expired", expired).Msg("expired entries by the retention policy")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func (a *App) initRollup(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.RollupConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("rollup config not found, using defaults")
+		return nil
+	}
+	var config rollup.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing rollup config : %w", err)
+	}
+	rollup.Init(rollup.New(config))
+	return nil
+}
+
+func (a *App) initReports(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.ReportsConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("reports config not found, no reports are scheduled")
+		return nil
+	}
+	var config reports.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing reports config : %w", err)
+	}
+	if err = reports.Init(config); err != nil {
+		return fmt.Errorf("invalid reports config : %w", err)
+	}
+	reports.Start(ctx)
+	return nil
+}
+
+func (a *App) initSlack(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.SlackConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("slack config not found, the slack app is disabled")
+		return nil
+	}
+	var config slack.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing slack config : %w", err)
+	}
+	if err = slack.Init(config); err != nil {
+		return fmt.Errorf("invalid slack config : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initArchive(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.ArchiveConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("archive config not found, only the store is queried")
+		return nil
+	}
+	var config archive.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing archive config : %w", err)
+	}
+	if err = archive.Init(config); err != nil {
+		return fmt.Errorf("invalid archive config : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initQueue(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.JobsConfig)
+	if err != nil {
+		if a.config.Mode != "" {
+			return fmt.Errorf("jobs config is required for the %s mode : %w", a.config.Mode, err)
+		}
+		log.Info(ctx).Err(err).Msg("jobs config not found, entries are persisted synchronously")
+		return nil
+	}
+	var config queue.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing jobs config : %w", err)
+	}
+	if err = queue.Init(config); err != nil {
+		return fmt.Errorf("error initializing ingestion queue : %w", err)
+	}
+	// the sinks are emitted to once the entries are stored, so their failures are retried with the same policy
+	sinks.SetRetryPolicy(sinks.RetryPolicy{MaxRetry: queue.MaxRetry, Delay: queue.Backoff})
+	if config.TenantQueues {
+		queue.SetTenants(tenants.IDs())
+		registry.Get().OnChange(constants.TenantsResourceKind, func() {
+			queue.SetTenants(tenants.IDs())
+		})
+	}
+	if a.config.Mode == "" {
+		if err = queue.Start(a.dependencies.Persist); err != nil {
+			return fmt.Errorf("error starting ingestion workers : %w", err)
+		}
+	}
+	return nil
+}
+
+func (a *App) initViolations(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.ViolationsConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("violations config not found, using the defaults")
+		return nil
+	}
+	var config violations.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing violations config : %w", err)
+	}
+	violations.Init(config)
+	return nil
+}
+
+func (a *App) initStats(ctx context.Context) error {
+	var config stats.Config
+	provider, err := a.dependencies.Configs(constants.StatsConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("stats config not found, the stats of the other instances are not known")
+	} else if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing stats config : %w", err)
+	}
+	if err = stats.Init(config); err != nil {
+		return fmt.Errorf("error initializing stats : %w", err)
+	}
+	stats.Get().Start(ctx)
+	return nil
+}
+
+func (a *App) initEscalation(ctx context.Context) error {
+	// workers only persist what the api processes sampled
+	if a.config.Mode == constants.WorkerMode {
+		return nil
+	}
+	provider, err := a.dependencies.Configs(constants.EscalationConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("escalation config not found, the sampling is only changed by hand")
+		return nil
+	}
+	var config escalation.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing escalation config : %w", err)
+	}
+	if err = escalation.Init(config); err != nil {
+		return fmt.Errorf("error initializing escalation : %w", err)
+	}
+	escalation.Get().Start(ctx)
+	return nil
+}
+
+func (a *App) initWAL(ctx context.Context) error {
+	if a.config.Mode == constants.WorkerMode {
+		return nil
+	}
+	provider, err := a.dependencies.Configs(constants.WALConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("wal config not found, entries are acknowledged once delivered")
+		return nil
+	}
+	var config wal.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing wal config : %w", err)
+	}
+	if err = wal.Init(ctx, config, ingest.Deliver); err != nil {
+		return fmt.Errorf("error initializing wal : %w", err)
+	}
+	return nil
+}
+
+func (a *App) initReplication(ctx context.Context) error {
+	if a.config.Mode == constants.WorkerMode {
+		return nil
+	}
+	provider, err := a.dependencies.Configs(constants.ReplicationConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("replication config not found, undelivered entries are only kept locally")
+		return nil
+	}
+	var config replication.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing replication config : %w", err)
+	}
+	if err = replication.Init(config, ingest.Deliver); err != nil {
+		return fmt.Errorf("error initializing replication : %w", err)
+	}
+	replication.Get().Start(ctx)
+	return nil
+}
+
+func (a *App) initLogging(ctx context.Context) error {
+	provider, err := a.dependencies.Configs(constants.LoggingConfig)
+	if err != nil {
+		log.Info(ctx).Err(err).Msg("logging config not found, logs are only written to stderr")
+		return nil
+	}
+	var config logging.Config
+	if err = provider.Unmarshal(&config); err != nil {
+		return fmt.Errorf("error parsing logging config : %w", err)
+	}
+	if err = logging.Init(ctx, config, ingest.Entry); err != nil {
+		return fmt.Errorf("error initializing logging : %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+// Package archive queries the entries archived out of the hot store, e.g. the daily files of the file sinks, so the
+// investigations of older ranges go through the same /logs api without rehydrating the archives first. The engines
+// reading the archives are pluggable, the json lines files of the file sinks are read by the ndjson engine and the
+// parquet files of the local paths and of the buckets by the parquet one.
+package archive
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/store"
+)
+
+// Config is the configuration of the archives
+type Config struct {
+	// Engine is the engine reading the archives, ndjson or parquet, ndjson by default
+	Engine string `json:"engine" mapstructure:"engine"`
+	// Paths are the globs of the archived files, e.g. /var/log/nbu/*/*.log, gzipped ones included. The parquet
+	// engine also reads the objects of buckets, as s3://bucket/archive/*.parquet.
+	Paths []string `json:"paths" mapstructure:"paths"`
+	// Region is the region of the buckets, their credentials are the ones of the aws environment variables
+	Region string `json:"region" mapstructure:"region"`
+	// Endpoint is the url of the s3 compatible api, the one of the region when empty
+	Endpoint string `json:"endpoint" mapstructure:"endpoint"`
+	// TimeoutInMillis is the time to wait for an object of a bucket to be listed or downloaded
+	TimeoutInMillis int `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+}
+
+// Engine queries the archives
+type Engine interface {
+	// Query is used to get the archived records matching the query, newest first by the time they were logged
+	Query(ctx context.Context, query store.Query) ([]store.Record, error)
+}
+
+var (
+	enginesMu sync.RWMutex
+	// engines are the factories of the engines by name
+	engines = map[string]func(config Config) (Engine, error){
+		constants.NDJSONArchiveEngine: func(config Config) (Engine, error) {
+			return NewNDJSON(config.Paths)
+		},
+		constants.ParquetArchiveEngine: func(config Config) (Engine, error) {
+			return NewParquet(config)
+		},
+	}
+
+	mu     sync.RWMutex
+	engine Engine
+)
+
+// RegisterEngine is used to make an engine available to the config, e.g. one reading columnar archives through
+// its client library
+func RegisterEngine(name string, factory func(config Config) (Engine, error)) {
+	enginesMu.Lock()
+	defer enginesMu.Unlock()
+	engines[name] = factory
+}
+
+// Engines is used to get the names of the available engines
+func Engines() []string {
+	enginesMu.RLock()
+	defer enginesMu.RUnlock()
+	names := make([]string, 0, len(engines))
+	for name := range engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Init is used to create the engine of the config and set it as the default one
+func Init(config Config) error {
+	if config.Engine == "" {
+		config.Engine = constants.NDJSONArchiveEngine
+	}
+	enginesMu.RLock()
+	factory, ok := engines[config.Engine]
+	enginesMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown archive engine %s, available are %s", config.Engine,
+			strings.Join(Engines(), ", "))
+	}
+	e, err := factory(config)
+	if err != nil {
+		return fmt.Errorf("invalid %s archive : %w", config.Engine, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	engine = e
+	return nil
+}
+
+// Get is used to get the default engine, nil when the archives are not queried
+func Get() Engine {
+	mu.RLock()
+	defer mu.RUnlock()
+	return engine
+}
+
+// Complete is used to complete a page of the hot store that is not full with the archived records logged before
+// the oldest of its records, for the queries of a range the hot store no longer holds all of. It returns the page
+// along with the number of archived records in it. The queries without a start are not completed, as they are
+// after the latest entries.
+func Complete(ctx context.Context, query store.Query, hot []store.Record) ([]store.Record, int, error) {
+	e := Get()
+	if e == nil || query.From.IsZero() || query.Deleted || len(query.IDs) > 0 ||
+		(query.Limit > 0 && len(hot) >= query.Limit) {
+		return hot, 0, nil
+	}
+	archived := query
+	// the archives only know when the entries were logged
+	archived.Axis = store.EventTime
+	for _, record := range hot {
+		if at := record.EventTime(); archived.To.IsZero() || at.Before(archived.To) {
+			archived.To = at
+		}
+	}
+	if query.Limit > 0 {
+		archived.Limit = query.Limit - len(hot)
+	}
+	records, err := e.Query(ctx, archived)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying archives : %w", err)
+	}
+	return append(hot, records...), len(records), nil
+}
+
+// add is used to add the record to the records when it matches the query. Only the newest of them are kept along
+// the way, so the memory is bounded by the limit of the query rather than by the size of the archives.
+func add(records []store.Record, record store.Record, query store.Query) []store.Record {
+	if !store.Matches(record, query) {
+		return records
+	}
+	records = append(records, record)
+	if query.Limit > 0 && len(records) >= constants.ArchiveScanFactor*query.Limit {
+		records = store.Select(records, query)
+	}
+	return records
+}
+
+// recordID is used to get the id of an archived record from its position in the archives, stable for the cursors
+// to page through them
+func recordID(position string) uint64 {
+	id := fnv.New64a()
+	_, _ = id.Write([]byte(position))
+	return id.Sum64()
+}
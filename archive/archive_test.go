@@ -0,0 +1,166 @@
+package archive_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/archive"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/utils/parquet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComplete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	start := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	entry := func(after time.Duration, message string) models.LogEntry {
+		at := models.Timestamp{Time: start.Add(after)}
+		return models.LogEntry{Type: "payment", Level: "error", Timestamp: &at,
+			Data: map[string]interface{}{"message": message}}
+	}
+	// the file sink archives the entries of the day, a day before is gzipped
+	sink, err := sinks.NewFile(sinks.FileConfig{Name: "archive", Path: filepath.Join(dir, "{type}-{yyyy-MM-dd}.log")})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Send(ctx, entry(time.Minute, "first")))
+	assert.NoError(t, sink.Send(ctx, entry(2*time.Minute, "second")))
+	assert.NoError(t, sink.Send(ctx, entry(3*time.Minute, "third")))
+	assert.NoError(t, sink.Flush(ctx))
+	assert.NoError(t, sink.Close())
+	file, err := os.Create(filepath.Join(dir, "payment-2024-03-04.log.gz"))
+	assert.NoError(t, err)
+	gz := gzip.NewWriter(file)
+	assert.NoError(t, json.NewEncoder(gz).Encode(entry(-24*time.Hour, "yesterday")))
+	_, _ = gz.Write([]byte("not an entry\n"))
+	assert.NoError(t, gz.Close())
+	assert.NoError(t, file.Close())
+
+	// the store only holds the latest entry
+	hot := []store.Record{{ID: 1, IngestedAt: start.Add(3 * time.Minute), Entry: entry(3*time.Minute, "third")}}
+	query := store.Query{From: start.Add(-48 * time.Hour), Axis: store.EventTime, Limit: 3}
+	records, archived, err := archive.Complete(ctx, query, hot)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, archived)
+	assert.Len(t, records, 1)
+
+	assert.Error(t, archive.Init(archive.Config{Engine: "duckdb", Paths: []string{dir}}))
+	assert.Error(t, archive.Init(archive.Config{}))
+	assert.NoError(t, archive.Init(archive.Config{Paths: []string{filepath.Join(dir, "*.log*")}}))
+	records, archived, err = archive.Complete(ctx, query, hot)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, archived)
+	var messages []interface{}
+	for _, record := range records {
+		messages = append(messages, record.Entry.Data["message"])
+	}
+	// the archived entries logged after the oldest entry of the store are already in the page
+	assert.Equal(t, []interface{}{"third", "second", "first"}, messages)
+
+	// the next page starts after the cursor of the last archived record
+	query.Cursor = store.NextCursor(records, query)
+	records, archived, err = archive.Complete(ctx, query, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, archived)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "yesterday", records[0].Entry.Data["message"])
+	}
+
+	// the queries of the latest entries are not completed
+	_, archived, err = archive.Complete(ctx, store.Query{Limit: 10}, hot)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, archived)
+	assert.Contains(t, archive.Engines(), "ndjson")
+}
+
+func TestParquet(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	columns := []parquet.Column{{Name: "timestamp", Kind: parquet.Timestamp}, {Name: "type", Kind: parquet.String},
+		{Name: "level", Kind: parquet.String}, {Name: "data", Kind: parquet.String},
+		{Name: "region", Kind: parquet.String}}
+	write := func(after time.Duration, messages ...string) []byte {
+		var rows []map[string]interface{}
+		for i, message := range messages {
+			rows = append(rows, map[string]interface{}{"timestamp": start.Add(after + time.Duration(i)*time.Minute),
+				"type": "payment", "level": "error", "data": fmt.Sprintf(`{"message":%q}`, message),
+				"region": "south"})
+		}
+		// a row that is not an entry
+		rows = append(rows, map[string]interface{}{"data": "{}"})
+		var b bytes.Buffer
+		assert.NoError(t, parquet.Write(&b, columns, rows, parquet.Snappy))
+		return b.Bytes()
+	}
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "2024-03-05.parquet"), write(0, "first", "second"), 0o600))
+
+	// a bucket listing its archives a page at a time
+	objects := map[string][]byte{"archive/2024-03-04.parquet": write(-24*time.Hour, "yesterday"),
+		"archive/2024-03-03.json": []byte("{}")}
+	keys := []string{"archive/2024-03-03.json", "archive/2024-03-04.parquet"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/archives" {
+			object, ok := objects[strings.TrimPrefix(r.URL.Path, "/archives/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(object)
+			return
+		}
+		assert.Equal(t, "2", r.URL.Query().Get("list-type"))
+		assert.Equal(t, "archive/", r.URL.Query().Get("prefix"))
+		key, next := keys[0], `<IsTruncated>true</IsTruncated><NextContinuationToken>1</NextContinuationToken>`
+		if r.URL.Query().Get("continuation-token") == "1" {
+			key, next = keys[1], `<IsTruncated>false</IsTruncated>`
+		}
+		_, _ = fmt.Fprintf(w, `<ListBucketResult><Contents><Key>%s</Key><Size>%d</Size>`+
+			`<LastModified>2024-03-05T12:00:00.000Z</LastModified></Contents>%s</ListBucketResult>`,
+			key, len(objects[key]), next)
+	}))
+	defer server.Close()
+
+	config := archive.Config{Engine: "parquet", Region: "ap-south-1", Endpoint: server.URL,
+		Paths: []string{filepath.Join(dir, "*.parquet"), "s3://archives/archive/*.parquet"}}
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	assert.Error(t, archive.Init(config), "the buckets need credentials")
+	t.Setenv("AWS_ACCESS_KEY_ID", "key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	assert.Error(t, archive.Init(archive.Config{Engine: "parquet", Paths: []string{"s3://archives/*.parquet"}}))
+	assert.Error(t, archive.Init(archive.Config{Engine: "parquet", Region: "ap-south-1", Paths: []string{"s3://"}}))
+	assert.NoError(t, archive.Init(config))
+
+	query := store.Query{From: start.Add(-48 * time.Hour), Limit: 10}
+	records, archived, err := archive.Complete(ctx, query, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, archived)
+	var messages []interface{}
+	for _, record := range records {
+		messages = append(messages, record.Entry.Data["message"])
+		assert.Equal(t, "south", record.Entry.Data["region"])
+		assert.Equal(t, "error", record.Entry.Level)
+	}
+	assert.Equal(t, []interface{}{"second", "first", "yesterday"}, messages)
+
+	// the row group of the local archive is after the range and skipped by its bounds
+	query = store.Query{From: start.Add(-48 * time.Hour), To: start.Add(-time.Hour), Limit: 10}
+	records, archived, err = archive.Complete(ctx, query, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, archived)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, start.Add(-24*time.Hour), records[0].EventTime())
+	}
+	assert.Contains(t, archive.Engines(), "parquet")
+}
@@ -0,0 +1,94 @@
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/store"
+)
+
+// NDJSON reads the archives of json entries, one a line, as the file sinks write them in the json format. The
+// files are scanned on every query, the ones last written before the range of the query are skipped.
+type NDJSON struct {
+	paths []string
+}
+
+// NewNDJSON is used to create the engine reading the files matching the globs
+func NewNDJSON(paths []string) (*NDJSON, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("archive paths are required")
+	}
+	for _, path := range paths {
+		if _, err := filepath.Match(path, ""); err != nil {
+			return nil, fmt.Errorf("invalid archive path %s : %w", path, err)
+		}
+	}
+	return &NDJSON{paths: paths}, nil
+}
+
+// Query is used to get the archived records matching the query, the lines that are not json entries are skipped
+func (n *NDJSON) Query(ctx context.Context, query store.Query) ([]store.Record, error) {
+	query.Axis = store.EventTime
+	var records []store.Record
+	for _, pattern := range n.paths {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
+			if records, err = n.scan(file, query, records); err != nil {
+				return nil, fmt.Errorf("error reading archive %s : %w", file, err)
+			}
+		}
+	}
+	return store.Select(records, query), nil
+}
+
+// scan is used to add the records of the file matching the query to the records
+func (n *NDJSON) scan(path string, query store.Query, records []store.Record) ([]store.Record, error) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || (!query.From.IsZero() && info.ModTime().Before(query.From)) {
+		return records, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return records, err
+	}
+	defer file.Close()
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return records, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64<<10), constants.MaxTextLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		var entry models.LogEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Type == "" {
+			continue
+		}
+		record := store.Record{ID: recordID(fmt.Sprintf("%s:%d", path, line)), IngestedAt: info.ModTime(),
+			Entry: entry}
+		if entry.Timestamp != nil {
+			record.IngestedAt = entry.Timestamp.Time
+		}
+		records = add(records, record, query)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,228 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/utils/objectstore"
+	"github.com/angel-one/nbu-logger-service/utils/parquet"
+	"github.com/angel-one/nbu-logger-service/utils/sigv4"
+)
+
+// Parquet reads the archives of entries in parquet files, of local paths and of buckets, e.g. the ones the
+// analytics exports write. The type, level, timestamp, traceId and spanId columns are the ones of the entries and
+// the data column is their data as a json object, the other columns are added to their data. The files are read
+// on every query, the ones last written before the range of the query are skipped and so are the row groups whose
+// timestamps are outside of it.
+type Parquet struct {
+	paths   []string
+	buckets []bucket
+}
+
+// bucket are the objects of a bucket whose keys match a glob
+type bucket struct {
+	store   *objectstore.S3
+	name    string
+	pattern string
+	// prefix is the prefix of the pattern before its first wildcard, the keys listed
+	prefix string
+}
+
+// NewParquet is used to create the engine reading the files and the objects matching the globs of the paths
+func NewParquet(config Config) (*Parquet, error) {
+	if len(config.Paths) == 0 {
+		return nil, fmt.Errorf("archive paths are required")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf(constants.DefaultObjectStoreEndpoint, config.Region)
+	}
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultArchiveTimeoutInMillis
+	}
+	p := &Parquet{}
+	for _, pattern := range config.Paths {
+		if !strings.HasPrefix(pattern, constants.S3ArchiveScheme) {
+			if _, err := filepath.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid archive path %s : %w", pattern, err)
+			}
+			p.paths = append(p.paths, pattern)
+			continue
+		}
+		name, key, _ := strings.Cut(strings.TrimPrefix(pattern, constants.S3ArchiveScheme), "/")
+		if _, err := path.Match(key, ""); err != nil || name == "" || key == "" {
+			return nil, fmt.Errorf("invalid archive path %s, it has to be s3://bucket/key", pattern)
+		}
+		if config.Region == "" {
+			return nil, fmt.Errorf("region is required for the archive path %s", pattern)
+		}
+		credentials := sigv4.FromEnv()
+		if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+			return nil, fmt.Errorf("archive path %s has no aws credentials", pattern)
+		}
+		prefix := key
+		if i := strings.IndexAny(key, `*?[\`); i >= 0 {
+			prefix = key[:i]
+		}
+		p.buckets = append(p.buckets, bucket{
+			store: objectstore.NewS3(config.Endpoint, name, config.Region, credentials,
+				time.Duration(config.TimeoutInMillis)*time.Millisecond),
+			name:    name,
+			pattern: key,
+			prefix:  prefix,
+		})
+	}
+	return p, nil
+}
+
+// Query is used to get the archived records matching the query, the rows that are not entries are skipped
+func (p *Parquet) Query(ctx context.Context, query store.Query) ([]store.Record, error) {
+	query.Axis = store.EventTime
+	var records []store.Record
+	for _, pattern := range p.paths {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
+			info, err := os.Stat(file)
+			if err != nil || info.IsDir() || (!query.From.IsZero() && info.ModTime().Before(query.From)) {
+				continue
+			}
+			if info.Size() > constants.MaxArchiveObjectBytes {
+				return nil, fmt.Errorf("archive %s is larger than %d bytes", file, constants.MaxArchiveObjectBytes)
+			}
+			data, err := os.ReadFile(file)
+			if err == nil {
+				records, err = p.scan(file, data, info.ModTime(), query, records)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading archive %s : %w", file, err)
+			}
+		}
+	}
+	for _, b := range p.buckets {
+		objects, err := b.store.List(b.prefix)
+		if err != nil {
+			return nil, fmt.Errorf("error listing archives of bucket %s : %w", b.name, err)
+		}
+		for _, object := range objects {
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
+			if ok, _ := path.Match(b.pattern, object.Key); !ok ||
+				(!query.From.IsZero() && object.LastModified.Before(query.From)) {
+				continue
+			}
+			name := constants.S3ArchiveScheme + b.name + "/" + object.Key
+			if object.Size > constants.MaxArchiveObjectBytes {
+				return nil, fmt.Errorf("archive %s is larger than %d bytes", name, constants.MaxArchiveObjectBytes)
+			}
+			data, err := b.store.Get(object.Key)
+			if err == nil {
+				records, err = p.scan(name, data, object.LastModified, query, records)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error reading archive %s : %w", name, err)
+			}
+		}
+	}
+	return store.Select(records, query), nil
+}
+
+// scan is used to add the records of the rows of the file matching the query to the records
+func (p *Parquet) scan(name string, data []byte, modified time.Time, query store.Query,
+	records []store.Record) ([]store.Record, error) {
+	file, err := parquet.Open(data)
+	if err != nil {
+		return records, err
+	}
+	for i, group := range file.RowGroups() {
+		if !overlaps(group, query) {
+			continue
+		}
+		rows, err := file.Rows(i)
+		if err != nil {
+			return records, err
+		}
+		for j, row := range rows {
+			entry, ok := toEntry(row)
+			if !ok {
+				continue
+			}
+			record := store.Record{ID: recordID(fmt.Sprintf("%s:%d:%d", name, i, j)), IngestedAt: modified,
+				Entry: entry}
+			if entry.Timestamp != nil {
+				record.IngestedAt = entry.Timestamp.Time
+			}
+			records = add(records, record, query)
+		}
+	}
+	return records, nil
+}
+
+// overlaps is used to check whether the timestamps of the row group may be in the range of the query, the groups
+// without bounds may
+func overlaps(group parquet.RowGroup, query store.Query) bool {
+	if max, ok := group.Max[constants.TimestampField].(time.Time); ok && !query.From.IsZero() &&
+		max.Before(query.From) {
+		return false
+	}
+	if min, ok := group.Min[constants.TimestampField].(time.Time); ok && !query.To.IsZero() &&
+		!min.Before(query.To) {
+		return false
+	}
+	return true
+}
+
+// toEntry is used to get the entry of the row, false when it has no type
+func toEntry(row map[string]interface{}) (models.LogEntry, bool) {
+	var entry models.LogEntry
+	if data, ok := row[constants.DataField].(string); ok {
+		_ = json.Unmarshal([]byte(data), &entry.Data)
+	}
+	if entry.Data == nil {
+		entry.Data = make(map[string]interface{})
+	}
+	for name, value := range row {
+		switch name {
+		case constants.TypeField:
+			entry.Type, _ = value.(string)
+		case constants.LevelField:
+			entry.Level, _ = value.(string)
+		case constants.TimestampField:
+			switch v := value.(type) {
+			case time.Time:
+				entry.Timestamp = &models.Timestamp{Time: v}
+			case int64:
+				entry.Timestamp = &models.Timestamp{Time: time.UnixMilli(v).UTC()}
+			default:
+				if at, err := models.ParseTimestamp(value); err == nil {
+					entry.Timestamp = &at
+				}
+			}
+		case constants.TraceIDField:
+			entry.TraceID, _ = value.(string)
+		case constants.SpanIDField:
+			entry.SpanID, _ = value.(string)
+		case constants.DataField:
+		default:
+			if t, ok := value.(time.Time); ok {
+				value = t.Format(time.RFC3339Nano)
+			}
+			entry.Data[name] = value
+		}
+	}
+	return entry, entry.Type != ""
+}
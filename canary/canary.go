@@ -0,0 +1,121 @@
+// Package canary sends a share of the traffic of a route to a candidate implementation of its handler and
+// keeps the figures of both, so that a rewrite can be compared against the handler it replaces before it
+// takes all the traffic.
+package canary
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+// Config is the configuration of the canaries
+type Config struct {
+	// Weights are the percentages of the traffic of each canary, by canary name, sent to its candidate
+	Weights map[string]int `json:"weights" mapstructure:"weights"`
+}
+
+// Figures are the figures of an implementation of a route
+type Figures struct {
+	Requests      int64   `json:"requests"`
+	ClientErrors  int64   `json:"clientErrors"`
+	ServerErrors  int64   `json:"serverErrors"`
+	MeanLatencyMs float64 `json:"meanLatencyMs"`
+	MaxLatencyMs  float64 `json:"maxLatencyMs"`
+	latency       time.Duration
+	maxLatency    time.Duration
+}
+
+// Report is the comparison of the implementations of a canary
+type Report struct {
+	Name      string  `json:"name"`
+	Weight    int     `json:"weight"`
+	Primary   Figures `json:"primary"`
+	Candidate Figures `json:"candidate"`
+}
+
+var (
+	mu      sync.Mutex
+	weights = make(map[string]int)
+	figures = make(map[string]map[string]*Figures)
+)
+
+// Init is used to set the weights, the figures are kept
+func Init(config Config) {
+	mu.Lock()
+	defer mu.Unlock()
+	weights = make(map[string]int, len(config.Weights))
+	for name, weight := range config.Weights {
+		if weight < 0 {
+			weight = 0
+		} else if weight > 100 {
+			weight = 100
+		}
+		weights[name] = weight
+	}
+}
+
+// Candidate is used to pick whether a request of the canary goes to the candidate
+func Candidate(name string) bool {
+	mu.Lock()
+	weight := weights[name]
+	mu.Unlock()
+	return weight > 0 && rand.Intn(100) < weight
+}
+
+// Record is used to add a request served by the implementation to the figures of the canary
+func Record(name, implementation string, status int, latency time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	implementations, ok := figures[name]
+	if !ok {
+		implementations = make(map[string]*Figures)
+		figures[name] = implementations
+	}
+	f, ok := implementations[implementation]
+	if !ok {
+		f = &Figures{}
+		implementations[implementation] = f
+	}
+	f.Requests++
+	if status >= 500 {
+		f.ServerErrors++
+	} else if status >= 400 {
+		f.ClientErrors++
+	}
+	f.latency += latency
+	if latency > f.maxLatency {
+		f.maxLatency = latency
+	}
+}
+
+// Reports is used to get the comparison of every canary that served requests
+func Reports() []Report {
+	mu.Lock()
+	defer mu.Unlock()
+	reports := make([]Report, 0, len(figures))
+	for name, implementations := range figures {
+		report := Report{Name: name, Weight: weights[name]}
+		if f, ok := implementations[constants.CanaryPrimary]; ok {
+			report.Primary = f.summary()
+		}
+		if f, ok := implementations[constants.CanaryCandidate]; ok {
+			report.Candidate = f.summary()
+		}
+		reports = append(reports, report)
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Name < reports[j].Name })
+	return reports
+}
+
+func (f *Figures) summary() Figures {
+	summary := *f
+	if f.Requests > 0 {
+		summary.MeanLatencyMs = float64(f.latency.Microseconds()) / float64(f.Requests) / 1000
+	}
+	summary.MaxLatencyMs = float64(f.maxLatency.Microseconds()) / 1000
+	return summary
+}
@@ -0,0 +1,32 @@
+package canary_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/canary"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanary(t *testing.T) {
+	canary.Init(canary.Config{Weights: map[string]int{"all": 100, "none": 0, "over": 250}})
+	assert.True(t, canary.Candidate("all"))
+	assert.True(t, canary.Candidate("over"))
+	assert.False(t, canary.Candidate("none"))
+	assert.False(t, canary.Candidate("unknown"))
+
+	canary.Record("all", constants.CanaryPrimary, 200, 10*time.Millisecond)
+	canary.Record("all", constants.CanaryPrimary, 400, 30*time.Millisecond)
+	canary.Record("all", constants.CanaryCandidate, 500, 5*time.Millisecond)
+
+	reports := canary.Reports()
+	if assert.Len(t, reports, 1) {
+		assert.Equal(t, 100, reports[0].Weight)
+		assert.Equal(t, int64(2), reports[0].Primary.Requests)
+		assert.Equal(t, int64(1), reports[0].Primary.ClientErrors)
+		assert.Equal(t, 20.0, reports[0].Primary.MeanLatencyMs)
+		assert.Equal(t, 30.0, reports[0].Primary.MaxLatencyMs)
+		assert.Equal(t, int64(1), reports[0].Candidate.ServerErrors)
+	}
+}
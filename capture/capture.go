@@ -0,0 +1,131 @@
+// Package capture keeps the recently rejected requests, so that the reason a payload was rejected can be
+// looked up after the fact instead of asking the client to reproduce it.
+package capture
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+)
+
+// Config is the configuration of the capture of rejected requests
+type Config struct {
+	// Size is the number of requests kept, the oldest are overwritten
+	Size int `json:"size" mapstructure:"size"`
+	// MaxBodyBytes is the length bodies are truncated to once scrubbed
+	MaxBodyBytes int `json:"maxBodyBytes" mapstructure:"maxBodyBytes"`
+}
+
+// Request is a rejected request, with the secrets and personal data of its headers and body redacted
+type Request struct {
+	Time      time.Time         `json:"time"`
+	Method    string            `json:"method"`
+	Path      string            `json:"path"`
+	Query     string            `json:"query,omitempty"`
+	Tenant    string            `json:"tenant,omitempty"`
+	Status    int               `json:"status"`
+	Response  string            `json:"response,omitempty"`
+	Headers   map[string]string `json:"headers"`
+	Body      string            `json:"body"`
+	Truncated bool              `json:"truncated,omitempty"`
+}
+
+// Ring is a fixed size buffer of the latest rejected requests
+type Ring struct {
+	mu       sync.Mutex
+	config   Config
+	requests []Request
+	next     int
+	full     bool
+}
+
+var r = New(Config{})
+
+// credentialHeaders are parts of the names of the headers carrying credentials
+var credentialHeaders = []string{"authorization", "cookie", "token", "secret", "key", "signature"}
+
+// New is used to create a ring for the config
+func New(config Config) *Ring {
+	if config.Size <= 0 {
+		config.Size = constants.DefaultCaptureSize
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = constants.DefaultCaptureMaxBodyBytes
+	}
+	return &Ring{config: config, requests: make([]Request, config.Size)}
+}
+
+// Init is used to replace the default ring
+func Init(config Config) {
+	r = New(config)
+}
+
+// Get is used to get the default ring
+func Get() *Ring {
+	return r
+}
+
+// Add is used to scrub and keep the request, overwriting the oldest one when full.
+// The body is truncated once scrubbed, as truncating it first would break the json the scrubbing relies on.
+func (r *Ring) Add(request Request, header http.Header, body, response []byte) {
+	request.Headers = scrubHeaders(header)
+	request.Body = pipeline.Scrub(body)
+	request.Response = pipeline.Scrub(response)
+	if len(request.Body) > r.config.MaxBodyBytes {
+		request.Body, request.Truncated = request.Body[:r.config.MaxBodyBytes], true
+	}
+	if len(request.Response) > r.config.MaxBodyBytes {
+		request.Response = request.Response[:r.config.MaxBodyBytes]
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests[r.next] = request
+	r.next = (r.next + 1) % len(r.requests)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// List is used to get the kept requests, the latest first
+func (r *Ring) List() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := r.next
+	if r.full {
+		count = len(r.requests)
+	}
+	requests := make([]Request, 0, count)
+	for i := 1; i <= count; i++ {
+		requests = append(requests, r.requests[(r.next-i+len(r.requests))%len(r.requests)])
+	}
+	return requests
+}
+
+// scrubHeaders is used to flatten the headers, the ones carrying credentials are redacted
+func scrubHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		value := strings.Join(values, ", ")
+		if credentialHeader(name) {
+			value = constants.RedactedPrefix + constants.SensitiveSecret + "]"
+		} else {
+			value = pipeline.Scrub([]byte(value))
+		}
+		headers[name] = value
+	}
+	return headers
+}
+
+func credentialHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, credential := range credentialHeaders {
+		if strings.Contains(name, credential) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package capture_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/capture"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing(t *testing.T) {
+	ring := capture.New(capture.Config{Size: 2, MaxBodyBytes: 100})
+	header := http.Header{"Authorization": {"Bearer abc"}, "Content-Type": {"application/json"}}
+	ring.Add(capture.Request{Path: "/first"}, header, []byte(`{}`), nil)
+	ring.Add(capture.Request{Path: "/second", Status: http.StatusBadRequest}, header,
+		[]byte(`{"type":"signup","data":{"password":"hunter2","email":"jane@example.com"}}`),
+		[]byte(`{"error":"type is required"}`))
+	ring.Add(capture.Request{Path: "/third"}, header, []byte(strings.Repeat("a", 150)), nil)
+
+	requests := ring.List()
+	if assert.Len(t, requests, 2) {
+		assert.Equal(t, "/third", requests[0].Path)
+		assert.True(t, requests[0].Truncated)
+		assert.Len(t, requests[0].Body, 100)
+
+		second := requests[1]
+		assert.Equal(t, "/second", second.Path)
+		assert.NotContains(t, second.Body, "hunter2")
+		assert.NotContains(t, second.Body, "jane@example.com")
+		assert.Contains(t, second.Body, "signup")
+		assert.Equal(t, `{"error":"type is required"}`, second.Response)
+		assert.NotContains(t, second.Headers["Authorization"], "abc")
+		assert.Equal(t, "application/json", second.Headers["Content-Type"])
+	}
+}
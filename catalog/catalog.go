@@ -0,0 +1,261 @@
+// Package catalog resolves the services to their owning team and its slack channel from the service catalog, such
+// as backstage, so the alerts and the reports about a service reach the people owning it.
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+// Config is the configuration of the service catalog
+type Config struct {
+	// URL is the base url of the backstage catalog, e.g. https://backstage.internal, only the owners of the config
+	// are known when empty
+	URL string `json:"url" mapstructure:"url"`
+	// Token is the bearer token of the catalog api
+	Token           string `json:"-" mapstructure:"token"`
+	TimeoutInMillis int    `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+	// RefreshIntervalInSeconds is the time between the reloads of the catalog, the last one loaded is kept while
+	// the catalog is unavailable
+	RefreshIntervalInSeconds int `json:"refreshIntervalInSeconds" mapstructure:"refreshIntervalInSeconds"`
+	// ChannelAnnotation is the annotation of the components and of the groups holding their slack channel, the
+	// one of the component is used first
+	ChannelAnnotation string `json:"channelAnnotation" mapstructure:"channelAnnotation"`
+	// ServiceLabel is the label of the entries naming their service, the type of the entries is their service
+	// without it
+	ServiceLabel string `json:"serviceLabel" mapstructure:"serviceLabel"`
+	// Owners are the owners of the services by name, over the ones of the catalog, for the services missing in it
+	Owners map[string]Owner `json:"owners" mapstructure:"owners"`
+}
+
+// Owner is the team owning a service and where to reach it
+type Owner struct {
+	Team    string `json:"team" mapstructure:"team"`
+	Channel string `json:"channel,omitempty" mapstructure:"channel"`
+	Email   string `json:"email,omitempty" mapstructure:"email"`
+}
+
+// String is used to get the team along with its channel, as written in the notifications
+func (o Owner) String() string {
+	if o.Channel == "" {
+		return o.Team
+	}
+	return fmt.Sprintf("%s (%s)", o.Team, o.Channel)
+}
+
+// Catalog keeps the owners of the services loaded from the service catalog
+type Catalog struct {
+	config Config
+	mu     sync.RWMutex
+	owners map[string]Owner
+	// loadedAt is when the catalog was last loaded, zero until it is
+	loadedAt time.Time
+}
+
+// entity is the part of a backstage entity the owners are read from
+type entity struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name        string            `json:"name"`
+		Namespace   string            `json:"namespace"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Owner   string `json:"owner"`
+		Profile struct {
+			Email string `json:"email"`
+		} `json:"profile"`
+	} `json:"spec"`
+}
+
+var (
+	mu      sync.RWMutex
+	catalog = New(Config{})
+)
+
+// New is used to create the catalog of the config, applying the defaults
+func New(config Config) *Catalog {
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultCatalogTimeoutInMillis
+	}
+	if config.RefreshIntervalInSeconds <= 0 {
+		config.RefreshIntervalInSeconds = constants.DefaultCatalogRefreshIntervalInSeconds
+	}
+	if config.ChannelAnnotation == "" {
+		config.ChannelAnnotation = constants.DefaultCatalogChannelAnnotation
+	}
+	if config.ServiceLabel == "" {
+		config.ServiceLabel = constants.DefaultServiceLabel
+	}
+	return &Catalog{config: config, owners: map[string]Owner{}}
+}
+
+// Init is used to set the default catalog and load it, a catalog that cannot be loaded yet is retried on refresh
+func Init(ctx context.Context, config Config) error {
+	for service, owner := range config.Owners {
+		if owner.Team == "" {
+			return fmt.Errorf("owner of service %s needs a team", service)
+		}
+	}
+	c := New(config)
+	if config.URL != "" {
+		if err := c.Refresh(ctx); err != nil {
+			log.Warn(ctx).Err(err).Msg("error loading service catalog, only the configured owners are known")
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	catalog = c
+	return nil
+}
+
+// Get is used to get the default catalog
+func Get() *Catalog {
+	mu.RLock()
+	defer mu.RUnlock()
+	return catalog
+}
+
+// Start is used to reload the default catalog on its interval until the context is done
+func Start(ctx context.Context) {
+	c := Get()
+	if c.config.URL == "" {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(c.config.RefreshIntervalInSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := c.Refresh(ctx); err != nil {
+					log.Warn(ctx).Err(err).Msg("error refreshing service catalog, keeping the last one loaded")
+				}
+			}
+		}
+	}()
+}
+
+// Lookup is used to get the owner of the service from the default catalog
+func Lookup(service string) (Owner, bool) {
+	return Get().Lookup(service)
+}
+
+// OwnerOf is used to get the owner of the service of the entry from the default catalog
+func OwnerOf(entry models.LogEntry) (Owner, bool) {
+	c := Get()
+	return c.Lookup(c.ServiceOf(entry))
+}
+
+// ServiceOf is used to get the service of the entry, its service label or else its type
+func (c *Catalog) ServiceOf(entry models.LogEntry) string {
+	if service := entry.Labels[c.config.ServiceLabel]; service != "" {
+		return service
+	}
+	return entry.Type
+}
+
+// Lookup is used to get the owner of the service, the configured one first
+func (c *Catalog) Lookup(service string) (Owner, bool) {
+	if owner, ok := c.config.Owners[service]; ok {
+		return owner, true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	owner, ok := c.owners[strings.ToLower(service)]
+	return owner, ok
+}
+
+// LoadedAt is used to get when the catalog was last loaded, zero until it is
+func (c *Catalog) LoadedAt() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.loadedAt
+}
+
+// Refresh is used to load the owners of the components from the catalog, their owner is a group whose channel
+// and email are used when the component has none
+func (c *Catalog) Refresh(_ context.Context) error {
+	query := url.Values{}
+	query.Add("filter", "kind=component")
+	query.Add("filter", "kind=group")
+	query.Set("fields", "kind,metadata.name,metadata.namespace,metadata.annotations,spec.owner,spec.profile.email")
+	headers := map[string]string{"Accept": constants.JSONMediaType}
+	if c.config.Token != "" {
+		headers["Authorization"] = "Bearer " + c.config.Token
+	}
+	response, err := httpclient.GETWithTimeout(strings.TrimSuffix(c.config.URL, "/")+constants.CatalogEntitiesPath+
+		"?"+query.Encode(), headers, time.Duration(c.config.TimeoutInMillis)*time.Millisecond)
+	if err != nil {
+		return fmt.Errorf("%s: %w", constants.ExternalServiceFailureError, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+		return fmt.Errorf("%s: service catalog responded with %d", constants.ExternalServiceFailureError,
+			response.StatusCode)
+	}
+	var entities []entity
+	if err = json.NewDecoder(response.Body).Decode(&entities); err != nil {
+		return fmt.Errorf("error decoding service catalog : %w", err)
+	}
+
+	groups := make(map[string]entity)
+	for _, e := range entities {
+		if strings.EqualFold(e.Kind, "group") {
+			groups[reference(e.Metadata.Namespace, e.Metadata.Name)] = e
+		}
+	}
+	owners := make(map[string]Owner)
+	for _, e := range entities {
+		if !strings.EqualFold(e.Kind, "component") || e.Spec.Owner == "" {
+			continue
+		}
+		ref := e.Spec.Owner
+		// owners are group references, e.g. group:default/payments, with the kind and the namespace optional
+		if kind, name, ok := strings.Cut(ref, ":"); ok {
+			if !strings.EqualFold(kind, "group") {
+				continue
+			}
+			ref = name
+		}
+		namespace, name, ok := strings.Cut(ref, "/")
+		if !ok {
+			namespace, name = "", ref
+		}
+		owner := Owner{Team: name, Channel: e.Metadata.Annotations[c.config.ChannelAnnotation]}
+		if group, ok := groups[reference(namespace, name)]; ok {
+			if owner.Channel == "" {
+				owner.Channel = group.Metadata.Annotations[c.config.ChannelAnnotation]
+			}
+			owner.Email = group.Spec.Profile.Email
+		}
+		owners[strings.ToLower(e.Metadata.Name)] = owner
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.owners, c.loadedAt = owners, time.Now()
+	return nil
+}
+
+// reference is used to get the case insensitive reference of an entity, in the default namespace when it has none
+func reference(namespace, name string) string {
+	if namespace == "" {
+		namespace = constants.DefaultCatalogNamespace
+	}
+	return strings.ToLower(namespace + "/" + name)
+}
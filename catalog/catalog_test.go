@@ -0,0 +1,62 @@
+package catalog_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/catalog"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog(t *testing.T) {
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "/api/catalog/entities", r.URL.Path)
+		assert.Equal(t, []string{"kind=component", "kind=group"}, r.URL.Query()["filter"])
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		_, _ = fmt.Fprint(w, `[
+			{"kind":"Component","metadata":{"name":"payments"},"spec":{"owner":"group:default/Payments-Team"}},
+			{"kind":"Component","metadata":{"name":"orders","annotations":{"slack.com/channel":"#orders-oncall"}},
+				"spec":{"owner":"orders-team"}},
+			{"kind":"Component","metadata":{"name":"legacy"},"spec":{"owner":"user:default/jdoe"}},
+			{"kind":"Group","metadata":{"name":"payments-team","annotations":{"slack.com/channel":"#payments"}},
+				"spec":{"profile":{"email":"payments@example.com"}}},
+			{"kind":"Group","metadata":{"name":"orders-team","annotations":{"slack.com/channel":"#orders"}}}
+		]`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	err := catalog.Init(ctx, catalog.Config{URL: server.URL, Token: "secret",
+		Owners: map[string]catalog.Owner{"kyc": {Team: "onboarding", Channel: "#kyc"}}})
+	assert.NoError(t, err)
+	owner, ok := catalog.Lookup("payments")
+	assert.True(t, ok)
+	assert.Equal(t, catalog.Owner{Team: "Payments-Team", Channel: "#payments", Email: "payments@example.com"}, owner)
+	assert.Equal(t, "Payments-Team (#payments)", owner.String())
+	// the channel of the component comes before the one of its group
+	owner, ok = catalog.OwnerOf(models.LogEntry{Type: "checkout", Labels: map[string]string{"service": "orders"}})
+	assert.True(t, ok)
+	assert.Equal(t, catalog.Owner{Team: "orders-team", Channel: "#orders-oncall"}, owner)
+	owner, ok = catalog.OwnerOf(models.LogEntry{Type: "kyc"})
+	assert.True(t, ok)
+	assert.Equal(t, "onboarding", owner.Team)
+	_, ok = catalog.Lookup("legacy")
+	assert.False(t, ok)
+
+	// the last catalog loaded is kept while the catalog is unavailable
+	available = false
+	assert.Error(t, catalog.Get().Refresh(ctx))
+	_, ok = catalog.Lookup("payments")
+	assert.True(t, ok)
+
+	assert.Error(t, catalog.Init(ctx, catalog.Config{Owners: map[string]catalog.Owner{"kyc": {Channel: "#kyc"}}}))
+}
@@ -0,0 +1,241 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// DefaultTimeout is the timeout of a single request when none is configured
+const DefaultTimeout = 5 * time.Second
+
+// retry defaults, used when the config leaves them empty
+const (
+	DefaultMaxRetries   = 3
+	DefaultRetryWait    = 100 * time.Millisecond
+	DefaultMaxRetryWait = 5 * time.Second
+)
+
+// MaxBatchEntries is the number of entries the service accepts in a batch, larger batches are split
+const MaxBatchEntries = 1000
+
+// Config is the set of parameters to connect to the logger service
+type Config struct {
+	// URL is the base url of the service, e.g. http://nbu-logger-service
+	URL string
+	// Labels are added to every entry sent through the client
+	Labels map[string]string
+	// Timeout is the timeout of a single request
+	Timeout time.Duration
+	// HTTPClient is used to send the requests, a client with the timeout is created when nil
+	HTTPClient *http.Client
+	// MaxRetries is how many times a request failing with a network error, 429 or 5xx is sent again, negative
+	// values disable the retries
+	MaxRetries int
+	// RetryWait is the wait before the first retry, it doubles with every retry up to MaxRetryWait and is
+	// jittered so that the clients of an outage do not retry in lockstep. A Retry-After of the service wins.
+	RetryWait    time.Duration
+	MaxRetryWait time.Duration
+}
+
+// StatusError is returned when the service did not accept the request
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("logger service responded with %d : %s", e.StatusCode, e.Message)
+}
+
+// BatchResult is the outcome of a batch, the rejected entries are reported by their index
+type BatchResult struct {
+	Accepted   int      `json:"accepted"`
+	Rejected   int      `json:"rejected"`
+	Duplicates int      `json:"duplicates"`
+	Errors     []string `json:"errors"`
+}
+
+// Client sends log entries to the logger service
+type Client struct {
+	url          string
+	batchURL     string
+	labels       map[string]string
+	httpClient   *http.Client
+	maxRetries   int
+	retryWait    time.Duration
+	maxRetryWait time.Duration
+}
+
+// New is used to create a client for the config
+func New(config Config) *Client {
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		timeout := config.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+	if config.MaxRetries == 0 {
+		config.MaxRetries = DefaultMaxRetries
+	}
+	if config.RetryWait <= 0 {
+		config.RetryWait = DefaultRetryWait
+	}
+	if config.MaxRetryWait <= 0 {
+		config.MaxRetryWait = DefaultMaxRetryWait
+	}
+	base := strings.TrimSuffix(config.URL, "/")
+	return &Client{
+		url:          base + "/logger",
+		batchURL:     base + "/v1/logger/batch",
+		labels:       config.Labels,
+		httpClient:   httpClient,
+		maxRetries:   config.MaxRetries,
+		retryWait:    config.RetryWait,
+		maxRetryWait: config.MaxRetryWait,
+	}
+}
+
+// Log is used to send the entry, an error is returned when the service did not accept it
+func (c *Client) Log(ctx context.Context, entry models.LogEntry) error {
+	body, err := json.Marshal(c.label(entry))
+	if err != nil {
+		return err
+	}
+	_, err = c.send(ctx, c.url, body)
+	return err
+}
+
+// Batch is used to send the entries in batches of at most MaxBatchEntries. The entries the service rejects are
+// reported in the result by their index, an error is returned when a batch was not accepted at all.
+func (c *Client) Batch(ctx context.Context, entries []models.LogEntry) (BatchResult, error) {
+	var result BatchResult
+	for start := 0; start < len(entries); start += MaxBatchEntries {
+		end := start + MaxBatchEntries
+		if end > len(entries) {
+			end = len(entries)
+		}
+		labeled := make([]models.LogEntry, 0, end-start)
+		for _, entry := range entries[start:end] {
+			labeled = append(labeled, c.label(entry))
+		}
+		body, err := json.Marshal(labeled)
+		if err != nil {
+			return result, err
+		}
+		response, err := c.send(ctx, c.batchURL, body)
+		if err != nil {
+			return result, err
+		}
+		var batch BatchResult
+		if err = json.Unmarshal(response, &batch); err != nil {
+			return result, fmt.Errorf("invalid batch response : %w", err)
+		}
+		result.Accepted += batch.Accepted
+		result.Rejected += batch.Rejected
+		result.Duplicates += batch.Duplicates
+		for _, message := range batch.Errors {
+			// the service counts from the start of the batch it received
+			if start > 0 {
+				var index int
+				if _, err := fmt.Sscanf(message, "entry %d:", &index); err == nil {
+					message = fmt.Sprintf("entry %d:%s", index+start, strings.SplitN(message, ":", 2)[1])
+				}
+			}
+			result.Errors = append(result.Errors, message)
+		}
+	}
+	return result, nil
+}
+
+// label is used to add the labels of the client to the entry, the labels of the entry win
+func (c *Client) label(entry models.LogEntry) models.LogEntry {
+	if len(c.labels) == 0 {
+		return entry
+	}
+	labels := make(map[string]string, len(c.labels)+len(entry.Labels))
+	for key, value := range c.labels {
+		labels[key] = value
+	}
+	for key, value := range entry.Labels {
+		labels[key] = value
+	}
+	entry.Labels = labels
+	return entry
+}
+
+// send is used to post the json body and get the response, retrying the failures that may be transient
+func (c *Client) send(ctx context.Context, url string, body []byte) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		response, wait, err := c.post(ctx, url, body)
+		if err == nil || wait < 0 || attempt >= c.maxRetries {
+			return response, err
+		}
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// post is used to post the body once, the wait is negative when the failure is not worth retrying and the
+// wait the service asked for otherwise, 0 when it did not
+func (c *Client) post(ctx context.Context, url string, body []byte) ([]byte, time.Duration, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, -1, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, -1, err
+		}
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		err = &StatusError{StatusCode: response.StatusCode, Message: strings.TrimSpace(string(message))}
+		if response.StatusCode != http.StatusTooManyRequests && response.StatusCode < http.StatusInternalServerError {
+			return nil, -1, err
+		}
+		var wait time.Duration
+		if seconds, parseErr := strconv.Atoi(response.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+			wait = time.Duration(seconds) * time.Second
+		}
+		if wait > c.maxRetryWait {
+			// e.g. a daily quota, it is not worth holding the entries that long
+			return nil, -1, err
+		}
+		return nil, wait, err
+	}
+	data, err := io.ReadAll(response.Body)
+	return data, 0, err
+}
+
+// backoff is used to get the jittered wait before the retry, between half and all of the doubled wait
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.retryWait << uint(attempt)
+	if wait <= 0 || wait > c.maxRetryWait {
+		wait = c.maxRetryWait
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
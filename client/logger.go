@@ -0,0 +1,210 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// buffering defaults, used when the config leaves them empty
+const (
+	DefaultBufferSize    = 10000
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = time.Second
+)
+
+var (
+	// ErrBufferFull is returned when logging faster than the entries are sent, the entry is dropped
+	ErrBufferFull = errors.New("logger buffer is full")
+	// ErrClosed is returned when logging after the logger was closed
+	ErrClosed = errors.New("logger is closed")
+)
+
+// BufferConfig is the configuration of the buffering of a Logger
+type BufferConfig struct {
+	// Size is the number of entries buffered while waiting to be sent
+	Size int
+	// BatchSize is the number of entries sent at once, a batch is sent as soon as it is full
+	BatchSize int
+	// FlushInterval is the longest an entry waits for its batch to fill up
+	FlushInterval time.Duration
+	// OnError is called with the entries that could not be sent and why, they are reported on stderr when nil
+	OnError func(err error, entries []models.LogEntry)
+}
+
+// Logger buffers the entries and sends them in batches from the background through the client, so logging never
+// waits for the service. Entries are retried by the client, Flush waits for the buffered ones to be sent and
+// Close flushes them before stopping.
+type Logger struct {
+	client  *Client
+	config  BufferConfig
+	entries chan models.LogEntry
+	flushes chan chan error
+	closing chan struct{}
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+}
+
+// NewLogger is used to create a logger sending through the client and start it
+func NewLogger(client *Client, config BufferConfig) *Logger {
+	if config.Size <= 0 {
+		config.Size = DefaultBufferSize
+	}
+	if config.BatchSize <= 0 || config.BatchSize > MaxBatchEntries {
+		config.BatchSize = DefaultBatchSize
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = DefaultFlushInterval
+	}
+	if config.OnError == nil {
+		config.OnError = func(err error, entries []models.LogEntry) {
+			fmt.Fprintf(os.Stderr, "nbu-logger-service client: %d entries were not sent : %s\n", len(entries), err)
+		}
+	}
+	l := &Logger{client: client, config: config, entries: make(chan models.LogEntry, config.Size),
+		flushes: make(chan chan error), closing: make(chan struct{}), done: make(chan struct{})}
+	go l.run()
+	return l
+}
+
+// Log is used to buffer the entry, it fails right away when the buffer is full or the logger is closed
+func (l *Logger) Log(entry models.LogEntry) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if l.closed {
+		return ErrClosed
+	}
+	select {
+	case l.entries <- entry:
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+// Batch is used to buffer the entries, the ones that did not fit are dropped and counted in the error
+func (l *Logger) Batch(entries []models.LogEntry) error {
+	for i, entry := range entries {
+		if err := l.Log(entry); err != nil {
+			return fmt.Errorf("%d entries were not buffered : %w", len(entries)-i, err)
+		}
+	}
+	return nil
+}
+
+// Flush is used to send the buffered entries and wait for them, the error is the one of the last failed batch
+func (l *Logger) Flush(ctx context.Context) error {
+	result := make(chan error, 1)
+	select {
+	case l.flushes <- result:
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close is used to stop accepting entries and send the buffered ones, waiting for them until the context is done
+func (l *Logger) Close(ctx context.Context) error {
+	l.mu.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.closing)
+	}
+	l.mu.Unlock()
+	select {
+	case <-l.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run is used to batch the entries and send the batches as they fill up or the interval passes
+func (l *Logger) run() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.config.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]models.LogEntry, 0, l.config.BatchSize)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := l.send(batch)
+		batch = make([]models.LogEntry, 0, l.config.BatchSize)
+		return err
+	}
+	// drain is used to send every buffered entry
+	drain := func() error {
+		var err error
+		for {
+			select {
+			case entry := <-l.entries:
+				if batch = append(batch, entry); len(batch) >= l.config.BatchSize {
+					if sendErr := send(); sendErr != nil {
+						err = sendErr
+					}
+				}
+			default:
+				if sendErr := send(); sendErr != nil {
+					err = sendErr
+				}
+				return err
+			}
+		}
+	}
+	for {
+		select {
+		case entry := <-l.entries:
+			if batch = append(batch, entry); len(batch) >= l.config.BatchSize {
+				_ = send()
+			}
+		case <-ticker.C:
+			_ = send()
+		case result := <-l.flushes:
+			result <- drain()
+		case <-l.closing:
+			// nothing is buffered after the logger is closed
+			_ = drain()
+			return
+		}
+	}
+}
+
+// send is used to send the batch, reporting the entries that were not sent
+func (l *Logger) send(batch []models.LogEntry) error {
+	result, err := l.client.Batch(context.Background(), batch)
+	if err != nil {
+		l.config.OnError(err, batch)
+		return err
+	}
+	if result.Rejected > 0 {
+		err = fmt.Errorf("%d entries were rejected : %v", result.Rejected, result.Errors)
+		l.config.OnError(err, rejected(batch, result.Errors))
+	}
+	return err
+}
+
+// rejected is used to get the entries of the batch the errors of the service refer to by their index
+func rejected(batch []models.LogEntry, errors []string) []models.LogEntry {
+	var entries []models.LogEntry
+	for _, message := range errors {
+		var index int
+		if _, err := fmt.Sscanf(message, "entry %d:", &index); err == nil && index >= 0 && index < len(batch) {
+			entries = append(entries, batch[index])
+		}
+	}
+	return entries
+}
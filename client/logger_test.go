@@ -0,0 +1,60 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/client"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	var mu sync.Mutex
+	var received []models.LogEntry
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, "/v1/logger/batch", r.URL.Path)
+		// the first batch fails like a restarting service, it is retried
+		if requests++; requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch []models.LogEntry
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		received = append(received, batch...)
+		_ = json.NewEncoder(w).Encode(client.BatchResult{Accepted: len(batch)})
+	}))
+	defer server.Close()
+
+	c := client.New(client.Config{URL: server.URL, RetryWait: time.Millisecond,
+		Labels: map[string]string{"service": "orders"}})
+	var failures []error
+	logger := client.NewLogger(c, client.BufferConfig{BatchSize: 3, FlushInterval: time.Hour,
+		OnError: func(err error, _ []models.LogEntry) { failures = append(failures, err) }})
+	for i := 0; i < 7; i++ {
+		assert.NoError(t, logger.Log(models.LogEntry{Type: "app", Data: map[string]interface{}{"i": i}}))
+	}
+	ctx := context.Background()
+	assert.NoError(t, logger.Flush(ctx))
+	mu.Lock()
+	assert.Len(t, received, 7)
+	assert.Equal(t, 4, requests)
+	assert.Equal(t, "orders", received[0].Labels["service"])
+	mu.Unlock()
+
+	assert.NoError(t, logger.Log(models.LogEntry{Type: "app"}))
+	assert.NoError(t, logger.Close(ctx))
+	assert.ErrorIs(t, logger.Log(models.LogEntry{Type: "app"}), client.ErrClosed)
+	mu.Lock()
+	assert.Len(t, received, 8)
+	mu.Unlock()
+	assert.Empty(t, failures)
+}
@@ -0,0 +1,12 @@
+//go:build go1.21
+
+package client
+
+import (
+	"log/slog"
+)
+
+// NewSlogHandler is used to create a slog handler sending entries of the type through the client
+func NewSlogHandler(client *Client, entryType string, options *slog.HandlerOptions) slog.Handler {
+	return slog.NewJSONHandler(NewWriter(client, entryType), options)
+}
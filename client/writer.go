@@ -0,0 +1,63 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// fieldAliases maps the field names used by the popular json loggers onto the ones of the service
+var fieldAliases = map[string]string{
+	"msg": "message",
+	"ts":  "time",
+}
+
+// Writer sends every json line written to it as an entry of its type.
+// It understands the json output of zerolog, zap, logrus and slog, so an existing logger is routed
+// to the service by pointing its output at the writer, e.g.
+//
+//	zerolog.New(w)
+//	zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(w), zap.InfoLevel))
+//	logrus.SetOutput(w); logrus.SetFormatter(&logrus.JSONFormatter{})
+//	slog.New(slog.NewJSONHandler(w, nil))
+type Writer struct {
+	client    *Client
+	entryType string
+}
+
+// NewWriter is used to create a writer sending entries of the type through the client
+func NewWriter(client *Client, entryType string) *Writer {
+	return &Writer{client: client, entryType: entryType}
+}
+
+// Write is used to send each json line of p, lines that are not json are sent as the message
+func (w *Writer) Write(p []byte) (int, error) {
+	for _, line := range bytes.Split(p, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if err := w.client.Log(context.Background(), w.entry(line)); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *Writer) entry(line []byte) models.LogEntry {
+	var data map[string]interface{}
+	if err := json.Unmarshal(line, &data); err != nil || data == nil {
+		return models.LogEntry{Type: w.entryType, Data: map[string]interface{}{"message": string(line)}}
+	}
+	for alias, field := range fieldAliases {
+		if value, ok := data[alias]; ok {
+			if _, exists := data[field]; !exists {
+				data[field] = value
+				delete(data, alias)
+			}
+		}
+	}
+	return models.LogEntry{Type: w.entryType, Data: data}
+}
@@ -0,0 +1,39 @@
+package client_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/client"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	var received []models.LogEntry
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var entry models.LogEntry
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&entry))
+		received = append(received, entry)
+	}))
+	defer server.Close()
+
+	c := client.New(client.Config{URL: server.URL, Labels: map[string]string{"service": "orders"}})
+	writer := client.NewWriter(c, "app")
+	// a zap style line and a plain text line
+	_, err := writer.Write([]byte("{\"level\":\"info\",\"ts\":1700000000.5,\"msg\":\"started\"}\nplain text\n"))
+	assert.NoError(t, err)
+
+	logger := client.NewZerolog(c, "app")
+	logger.Warn().Str("order", "o-1").Msg("slow")
+
+	assert.Len(t, received, 3)
+	assert.Equal(t, "started", received[0].Data["message"])
+	assert.Equal(t, 1700000000.5, received[0].Data["time"])
+	assert.Equal(t, "orders", received[0].Labels["service"])
+	assert.Equal(t, "plain text", received[1].Data["message"])
+	assert.Equal(t, "warn", received[2].Data["level"])
+	assert.Equal(t, "o-1", received[2].Data["order"])
+}
@@ -0,0 +1,10 @@
+package client
+
+import (
+	"github.com/rs/zerolog"
+)
+
+// NewZerolog is used to create a zerolog logger sending entries of the type through the client
+func NewZerolog(client *Client, entryType string) zerolog.Logger {
+	return zerolog.New(NewWriter(client, entryType)).With().Timestamp().Logger()
+}
@@ -8,6 +8,43 @@ const (
 	DatabaseConfig    = "database"
 	JobsConfig        = "jobs"
 	CounterConfig     = "counter"
+	PipelineConfig    = "pipeline"
+	StoreConfig       = "store"
+	GitOpsConfig      = "gitops"
+	RegistryConfig    = "registry"
+	OnboardingConfig  = "onboarding"
+	FormatsConfig     = "formats"
+	InputsConfig      = "inputs"
+	SinksConfig       = "sinks"
+	AlertsConfig      = "alerts"
+	ViolationsConfig  = "violations"
+	EscalationConfig  = "escalation"
+	StatsConfig       = "stats"
+	DedupConfig       = "dedup"
+	LoggingConfig     = "logging"
+	CaptureConfig     = "capture"
+	RateLimitConfig   = "rateLimit"
+	CanaryConfig      = "canary"
+	GRPCConfig        = "grpc"
+	DLQConfig         = "dlq"
+	RollupConfig      = "rollup"
+	SchemasConfig     = "schemas"
+	ReportsConfig     = "reports"
+	QuotasConfig      = "quotas"
+	LifecycleConfig   = "lifecycle"
+	TracingConfig     = "tracing"
+	WALConfig         = "wal"
+	PanicsConfig      = "panics"
+	ReplicationConfig = "replication"
+	EncryptionConfig  = "encryption"
+	PrivacyConfig     = "privacy"
+	LimitsConfig      = "limits"
+	MetricsConfig     = "metrics"
+	HealthConfig      = "health"
+	CatalogConfig     = "catalog"
+	RoutingConfig     = "routing"
+	SlackConfig       = "slack"
+	ArchiveConfig     = "archive"
 )
 
 // config keys
@@ -21,9 +58,17 @@ const (
 	HTTPTlsHandshakeTimeoutInMillisKey        = "http.tlsHandshakeTimeoutInMillis"
 	HTTPExpectContinueTimeoutInMillisKey      = "http.expectContinueTimeoutInMillis"
 	HTTPTimeoutInMillisKey                    = "http.timeoutInMillis"
+	ServerAdminTokenKey                       = "server.adminToken"
+	ServerTrustedProxiesKey                   = "server.trustedProxies"
+	ServerReadTimeoutInMillisKey              = "server.readTimeoutInMillis"
+	ServerReadHeaderTimeoutInMillisKey        = "server.readHeaderTimeoutInMillis"
+	ServerWriteTimeoutInMillisKey             = "server.writeTimeoutInMillis"
+	ServerIdleTimeoutInMillisKey              = "server.idleTimeoutInMillis"
+	ServerMaxHeaderBytesKey                   = "server.maxHeaderBytes"
+	ServerCompressionMinSizeInBytesKey        = "server.compressionMinSizeInBytes"
 	DatabaseServerConfigKey                   = "server"
 	DatabasePortConfigKey                     = "port"
-	DatabaseUrlConfigKey                     = "url"
+	DatabaseUrlConfigKey                      = "url"
 	DatabaseNameConfigKey                     = "name"
 	DatabaseUsernameConfigKey                 = "username"
 	DatabasePasswordConfigKey                 = "password"
@@ -32,11 +77,24 @@ const (
 	DatabaseConnectionMaxLifetimeInSecondsKey = "connectionMaxLifetimeInSeconds"
 	DatabaseConnectionMaxIdleTimeInSecondsKey = "connectionMaxIdleTimeInSeconds"
 	CounterQueryTimeoutInMillisKey            = "queryTimeoutInMillis"
+	StoreCapacityConfigKey                    = "capacity"
+	StoreReaderTokenConfigKey                 = "readerToken"
+	StoreDeletionGraceInHoursConfigKey        = "deletionGraceInHours"
+	StoreRetentionConfigKey                   = "retention"
+	StoreRedisURLConfigKey                    = "redisUrl"
+	StoreKeyConfigKey                         = "key"
+	GitOpsRepositoryConfigKey                 = "repository"
+	GitOpsBranchConfigKey                     = "branch"
+	GitOpsPathConfigKey                       = "path"
+	GitOpsWorkDirConfigKey                    = "workDir"
+	GitOpsPollIntervalInSecondsConfigKey      = "pollIntervalInSeconds"
+	GitOpsWebhookSecretConfigKey              = "webhookSecret"
+	RegistryJournalPathConfigKey              = "journalPath"
 )
 
 // Jobs Config
 const (
 	RedisConnectionString = "redisUrl"
-	JobsTTLInHrs = "jobsRetentionTimeInHours"
-	NumberOfWorkers = "numberOfWorkers"
+	JobsTTLInHrs          = "jobsRetentionTimeInHours"
+	NumberOfWorkers       = "numberOfWorkers"
 )
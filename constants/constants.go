@@ -2,8 +2,243 @@ package constants
 
 // common constants
 const (
-	ApplicationName = "go-example-project"
-	MySQLDriverName = "mysql"
+	ApplicationName      = "go-example-project"
+	MySQLDriverName      = "mysql"
 	PostgresqlDriverName = "postgres"
-	CounterKey      = "key"
+	CounterKey           = "key"
+)
+
+// store constants
+const (
+	DefaultStoreCapacity = 10000
+	DefaultStoreKey      = "logger:store"
+	DefaultQueryLimit    = 100
+	MaxQueryLimit        = 1000
+	// soft deleted entries can be restored for a day before they are purged
+	DefaultDeletionGraceInHours = 24
+	StorePurgeIntervalInSeconds = 60
+	// MaxContextEntries is the most entries returned on each side of a hit
+	MaxContextEntries = 50
+)
+
+// archive constants
+const (
+	NDJSONArchiveEngine  = "ndjson"
+	ParquetArchiveEngine = "parquet"
+	// S3ArchiveScheme is the scheme of the archive paths of the objects of a bucket, as s3://bucket/key
+	S3ArchiveScheme = "s3://"
+	// MaxArchiveObjectBytes is the largest parquet archive read, the archives are read in memory
+	MaxArchiveObjectBytes = 512 << 20
+	// DefaultArchiveTimeoutInMillis is the time to wait for an archive of a bucket to be listed or downloaded
+	DefaultArchiveTimeoutInMillis = 60000
+	// ArchiveScanFactor is how many times the limit of a query the matching archived records kept while scanning
+	// can grow to before only the newest ones are kept
+	ArchiveScanFactor = 4
+)
+
+// session reconstruction constants, the entries of a session have its id in one of the fields
+const (
+	DefaultSessionFields       = "sessionId,deviceId"
+	DefaultSessionGapInSeconds = 300
+)
+
+// grafana datasource constants, the datapoints are bounded as the dashboards plot them all
+const (
+	GrafanaTableType           = "table"
+	GrafanaCountSeries         = "count"
+	GrafanaNoneGroup           = "none"
+	MinGrafanaIntervalInMillis = 1000
+	MaxGrafanaDataPoints       = 10000
+	MaxGrafanaAnnotations      = 1000
+)
+
+// scheduled report constants
+const (
+	DefaultReportTop               = 10
+	DefaultReportIntervalInMinutes = 24 * 60
+	DefaultReportTimeoutInMillis   = 5000
+	HTMLReportFormat               = "html"
+	CSVReportFormat                = "csv"
+	// NoReportGroup is the group of the entries without the field grouped by
+	NoReportGroup = "(none)"
+)
+
+// service catalog constants
+const (
+	DefaultCatalogTimeoutInMillis          = 5000
+	DefaultCatalogRefreshIntervalInSeconds = 600
+	DefaultCatalogChannelAnnotation        = "slack.com/channel"
+	DefaultCatalogNamespace                = "default"
+	CatalogEntitiesPath                    = "/api/catalog/entities"
+	// DefaultServiceLabel is the label of the entries naming their service
+	DefaultServiceLabel = "service"
+)
+
+// search result constants
+const (
+	HighlightPreTag  = "<em>"
+	HighlightPostTag = "</em>"
+)
+
+// timestamp constants
+const (
+	// MaxClockSkewInHours is how far ahead of the server the timestamp of an entry can be before it is rejected
+	MaxClockSkewInHours = 24
+)
+
+// query params
+const (
+	TypeQueryParam        = "type"
+	LabelQueryParam       = "label"
+	LimitQueryParam       = "limit"
+	VersionQueryParam     = "version"
+	KindQueryParam        = "kind"
+	IDQueryParam          = "id"
+	BeforeQueryParam      = "before"
+	AfterQueryParam       = "after"
+	FormatQueryParam      = "format"
+	OverrideQueryParam    = "override"
+	APIKeyQueryParam      = "apiKey"
+	ConstraintQueryParam  = "constraint"
+	SinkQueryParam        = "sink"
+	FromQueryParam        = "from"
+	ToQueryParam          = "to"
+	TimeAxisQueryParam    = "timeAxis"
+	FilterQueryParam      = "q"
+	SyntaxQueryParam      = "syntax"
+	HighlightQueryParam   = "highlight"
+	ContextQueryParam     = "context"
+	ContextByQueryParam   = "contextBy"
+	LevelQueryParam       = "level"
+	TextQueryParam        = "text"
+	CursorQueryParam      = "cursor"
+	ByQueryParam          = "by"
+	GapQueryParam         = "gapInSeconds"
+	AggregationQueryParam = "aggregation"
+)
+
+// query syntaxes, the queries of the other syntaxes are translated into filter expressions
+const (
+	FilterSyntax = "filter"
+	LogQLSyntax  = "logql"
+	LuceneSyntax = "lucene"
+)
+
+// rejected requests capture constants
+const (
+	DefaultCaptureSize         = 100
+	DefaultCaptureMaxBodyBytes = 4 << 10
+	MaxCaptureBodyBytes        = 64 << 10
+)
+
+// panic capture constants
+const (
+	DefaultPanicsSize     = 50
+	DefaultPanicEntryType = "loggerServicePanic"
+	MaxPanicStackBytes    = 64 << 10
+)
+
+// client side encryption constants
+const (
+	EnvelopeAlgorithm = "AES-256-GCM"
+	EnvelopeNonceSize = 12
+	EnvelopeKeySize   = 32
+)
+
+// anonymized aggregation constants
+const (
+	AnonymizedAggregation     = "anonymized"
+	DefaultAnonymityThreshold = 5
+)
+
+// payload limits constants
+const (
+	DefaultMaxBodyBytes    = 10 << 20
+	DefaultMaxDataDepth    = 32
+	DefaultMaxDataKeys     = 10000
+	DefaultMaxStringLength = 1 << 20
+)
+
+// derived metrics constants
+const (
+	CounterMetric          = "counter"
+	HistogramMetric        = "histogram"
+	BucketLabel            = "le"
+	DefaultMetricMaxSeries = 1000
+	OpenMetricsMediaType   = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	CounterSuffix          = "_total"
+	TraceIDLabel           = "trace_id"
+	SpanIDLabel            = "span_id"
+)
+
+// health check constants
+const (
+	UpStatus                     = "up"
+	DownStatus                   = "down"
+	DefaultHealthTimeoutInMillis = 1000
+	DefaultMaxBufferRatio        = 0.9
+	DefaultMaxSinkFailures       = 5
+	StoreCheck                   = "store"
+	DLQCheck                     = "dlq"
+	DedupCheck                   = "dedup"
+	QueueCheck                   = "queue"
+	SinksCheck                   = "sinks"
+	WALCheck                     = "wal"
+)
+
+// text ingestion constants
+const (
+	MaxTextLineBytes = 1 << 20
+)
+
+// content encodings
+const (
+	GzipEncoding        = "gzip"
+	DeflateEncoding     = "deflate"
+	ZstdEncoding        = "zstd"
+	SnappyEncoding      = "snappy"
+	IdentityEncoding    = "identity"
+	NDJSONMediaType     = "application/x-ndjson"
+	JSONMediaType       = "application/json"
+	XMLMediaType        = "application/xml"
+	TextXMLMediaType    = "text/xml"
+	FormMediaType       = "application/x-www-form-urlencoded"
+	GIFMediaType        = "image/gif"
+	JournalMediaType    = "application/vnd.fdo.journal"
+	PrometheusMediaType = "text/plain; version=0.0.4; charset=utf-8"
+	CSVMediaType        = "text/csv"
+	HTMLMediaType       = "text/html"
+	ProtobufMediaType   = "application/x-protobuf"
+	TextMediaType       = "text/plain; charset=utf-8"
+)
+
+// path params
+const (
+	IDPathParam      = "id"
+	VersionPathParam = "version"
+	InputPathParam   = "input"
+	SourcePathParam  = "source"
+)
+
+// validation failures constants
+const (
+	ValidationFailuresMetric  = "logger_validation_failures_total"
+	ValidationFailuresHelp    = "Entries rejected by validation by api key, type and violated constraint"
+	APIKeyLabel               = "api_key"
+	TypeLabel                 = "type"
+	ConstraintLabel           = "constraint"
+	AnonymousAPIKey           = "anonymous"
+	OverflowLabelValue        = "__overflow__"
+	DefaultMaxViolationGroups = 1000
+	MaxViolationTypeLength    = 128
+	TimestampConstraint       = "timestamp"
+	SchemaConstraintPrefix    = "schema."
+	PipelineConstraintPrefix  = "pipeline."
+)
+
+// rollup constants
+const (
+	DefaultRollupBucketInMinutes          = 60
+	DefaultRollupAllowedLatenessInMinutes = 60
+	DefaultRollupRetentionInHours         = 168
 )
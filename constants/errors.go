@@ -2,9 +2,48 @@ package constants
 
 // Error Codes
 const (
-	RequestBodyBindError        = "request body bind error"
-	RequestBodyValidationError  = "request body validation error"
-	ExternalServiceFailureError = "external service failure error"
-	DatabaseFailureError        = "database failure error"
-	RequestValidationError      = "request validation error"
+	RequestBodyBindError         = "request body bind error"
+	RequestBodyValidationError   = "request body validation error"
+	ExternalServiceFailureError  = "external service failure error"
+	DatabaseFailureError         = "database failure error"
+	RequestValidationError       = "request validation error"
+	QueryParamValidationError    = "query param validation error"
+	QueryDisabledError           = "stored entries can not be read without a reader token"
+	UnauthorizedError            = "unauthorized"
+	SchemaValidationError        = "schema validation error"
+	FutureTimestampError         = "timestamp is too far in the future"
+	UnfilteredDLQError           = "ids or sink are required, every dead letter can not be purged or requeued at once"
+	UnfilteredDeletionError      = "ids, type, labels or filter are required, every entry can not be deleted at once"
+	ResourceNotFoundError        = "resource not found"
+	PreconditionFailedError      = "precondition failed"
+	PreconditionRequiredError    = "precondition required, pass If-Match or the current version"
+	UnknownResourceKindError     = "unknown resource kind"
+	AdminDisabledError           = "admin api can not be used without an admin token"
+	InvalidWebhookSignatureError = "invalid webhook signature"
+	GitOpsDisabledError          = "gitops sync is not enabled"
+	TenantDisabledError          = "tenant is disabled"
+	RedactionImpactError         = "rules affect more recent entries than allowed, pass override=true to apply"
+	IdempotencyKeyInFlightError  = "the entry of the idempotency key is being ingested, retry after the time in Retry-After"
+	UnsupportedAPIVersionError   = "unsupported api version"
+	BatchTooLargeError           = "batch has too many entries"
+	RateLimitedError             = "too many requests, retry after the time in Retry-After"
+	UnknownReportError           = "unknown report"
+	QuotaExceededError           = "tenant is over its quota"
+	UnknownTenantError           = "unknown tenant"
+	TenantMismatchError          = "tenant does not match the api key"
+	TenantKeyRequiredError       = "tenant header needs an api key of the tenant or the admin token"
+	ReadTenantRequiredError      = "reads need an api key of a tenant or the admin token"
+	RollupTenantError            = "rollup is counted across the tenants, it is only read with the admin token"
+	RollupFilterError            = "rollup is only filtered by type, from and to"
+	InternalServerError          = "internal server error, it was reported with the id"
+	ReplicationDisabledError     = "replication is not enabled"
+	AlreadyPromotedError         = "the snapshot of the instance was already promoted"
+	OwnSnapshotError             = "an instance can not promote its own snapshot"
+	ObjectNotFoundError          = "object not found"
+	InvalidDeliveryClassError    = "delivery class has to be best-effort, at-least-once or durable-ack"
+	UnknownAlertError            = "unknown alert"
+	UnknownAlertRuleError        = "unknown alert rule"
+	SlackDisabledError           = "slack app is not configured"
+	InvalidSlackSignatureError   = "invalid slack request signature"
+	InvalidGrafanaTargetError    = "invalid grafana target"
 )
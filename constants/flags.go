@@ -11,4 +11,25 @@ const (
 	BaseConfigPathKey          = "base-config-path"
 	BaseConfigPathDefaultValue = "resources"
 	BaseConfigPathUsage        = "path to folder that stores your configurations"
+	ModeKey                    = "mode"
+	ModeUsage                  = "api only enqueues entries, worker only persists queued entries, both by default"
+	APIMode                    = "api"
+	WorkerMode                 = "worker"
+	TestRulesKey               = "test-rules"
+	TestRulesUsage             = "run the rules test suite in the yaml or json file against the pipeline config and exit"
+	// EnvPrefix prefixes the environment variables overriding the flags and the keys of the config files,
+	// e.g. NBU_LOGGER_PORT for --port and NBU_LOGGER_PIPELINE_MINLEVEL for minLevel of the pipeline config
+	EnvPrefix = "NBU_LOGGER"
+)
+
+// ShutdownTimeoutInSeconds is how long requests and queued entries in flight are waited for on stop
+const ShutdownTimeoutInSeconds = 30
+
+// http server defaults, the reads and the writes of the requests are not bounded by default as the exports stream
+// for as long as they take
+const (
+	DefaultServerReadHeaderTimeoutInMillis = 10000
+	DefaultServerIdleTimeoutInMillis       = 120000
+	DefaultServerMaxHeaderBytes            = 1 << 20
+	DefaultServerCompressionMinSizeInBytes = 1024
 )
@@ -0,0 +1,198 @@
+package constants
+
+// Email intake
+const (
+	DefaultSMTPDomain          = "nbu-logger-service"
+	DefaultSMTPEntryType       = "email"
+	DefaultSMTPMaxMessageBytes = 10 << 20
+	SMTPMaxRecipients          = 100
+	SMTPTimeoutInSeconds       = 300
+	EmailMaxNesting            = 8
+	EmailFromField             = "from"
+	EmailToField               = "to"
+	EmailSubjectField          = "subject"
+	EmailIDField               = "messageId"
+	EmailAttachmentsField      = "attachments"
+	EmailEnvelopeFromField     = "envelopeFrom"
+	EmailEnvelopeToField       = "envelopeTo"
+)
+
+// SNMP traps
+const (
+	DefaultSNMPEntryType       = "snmp-trap"
+	MaxUDPPacketBytes          = 65535
+	SNMPUptimeOID              = "1.3.6.1.2.1.1.3.0"
+	SNMPTrapOID                = "1.3.6.1.6.3.1.1.4.1.0"
+	SNMPGenericTrapsOID        = "1.3.6.1.6.3.1.1.5"
+	SNMPEnterpriseSpecificTrap = 6
+	SNMPVersionField           = "version"
+	SNMPSourceField            = "source"
+	SNMPEnterpriseField        = "enterprise"
+	SNMPAgentAddressField      = "agentAddress"
+	SNMPUptimeField            = "uptime"
+	SNMPTrapOIDField           = "trapOid"
+	SNMPTrapField              = "trap"
+	SNMPVarbindsField          = "varbinds"
+)
+
+// Syslog listener
+const (
+	DefaultSyslogMaxMessageBytes = 64 << 10
+	SyslogIdleTimeoutInSeconds   = 300
+	SyslogMultilineWaitInMillis  = 500
+	SyslogVersion                = "1"
+	SyslogNilValue               = "-"
+	SyslogMaxPriority            = 191
+	SyslogAppNameField           = "appName"
+	SyslogProcIDField            = "procId"
+	SyslogMsgIDField             = "msgId"
+	SyslogSeverityField          = "severity"
+	SyslogStructuredDataField    = "structuredData"
+	SyslogSourceField            = "source"
+)
+
+// Docker log driver plugin
+const (
+	DefaultDockerEntryType       = "container"
+	DockerPluginMediaType        = "application/vnd.docker.plugins.v1+json"
+	DockerPluginTimeoutInSeconds = 30
+	DockerMaxEntryBytes          = 2 << 20
+	DockerMaxLineBytes           = 1 << 20
+	DockerStreamField            = "stream"
+	DockerContainerIDField       = "containerId"
+	DockerContainerField         = "container"
+	DockerImageField             = "image"
+	DockerLabelsField            = "labels"
+)
+
+// Kubernetes events
+const (
+	DefaultKubernetesEntryType        = "kubernetes.event"
+	KubernetesServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	KubernetesServiceAccountCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	KubernetesRetryInSeconds          = 5
+	KubernetesWatchTimeoutInSeconds   = 300
+	KubernetesReasonField             = "reason"
+	KubernetesKindField               = "kind"
+	KubernetesNameField               = "name"
+	KubernetesNamespaceField          = "namespace"
+	KubernetesFieldPathField          = "fieldPath"
+	KubernetesComponentField          = "component"
+	KubernetesCountField              = "count"
+)
+
+// Envoy access log service
+const (
+	DefaultEnvoyEntryType              = "envoy.access"
+	EnvoyALSMethod                     = "/envoy.service.accesslog.v3.AccessLogService/StreamAccessLogs"
+	EnvoyALSReadHeaderTimeoutInSeconds = 10
+	GRPCMediaType                      = "application/grpc"
+	MaxGRPCMessageBytes                = 4 << 20
+	EnvoyNodeField                     = "node"
+	EnvoyLogNameField                  = "logName"
+	EnvoyProtocolField                 = "protocol"
+	EnvoyMethodField                   = "method"
+	EnvoySchemeField                   = "scheme"
+	EnvoyAuthorityField                = "authority"
+	EnvoyPathField                     = "path"
+	EnvoyUserAgentField                = "userAgent"
+	EnvoyRefererField                  = "referer"
+	EnvoyForwardedForField             = "forwardedFor"
+	EnvoyRequestIDField                = "requestId"
+	EnvoyStatusField                   = "status"
+	EnvoyResponseDetailsField          = "responseCodeDetails"
+	EnvoyBytesReceivedField            = "bytesReceived"
+	EnvoyBytesSentField                = "bytesSent"
+	EnvoyDurationField                 = "durationMillis"
+	EnvoyRemoteAddressField            = "remoteAddress"
+	EnvoyUpstreamHostField             = "upstreamHost"
+	EnvoyUpstreamClusterField          = "upstreamCluster"
+	EnvoyUpstreamFailureField          = "upstreamFailure"
+	EnvoyRouteField                    = "route"
+)
+
+// AMQP consumer
+const (
+	DefaultAMQPEntryType = "amqp"
+	DefaultAMQPPrefetch  = 100
+	AMQPRetryInSeconds   = 5
+)
+
+// NATS jetstream consumer
+const (
+	DefaultNATSEntryType      = "nats"
+	DefaultNATSDurable        = "nbu-logger-service"
+	DefaultNATSBatch          = 100
+	NATSFetchExpiresInSeconds = 30
+	NATSRetryInSeconds        = 5
+)
+
+// Kafka consumer
+const (
+	DefaultKafkaEntryType  = "kafka"
+	DefaultKafkaGroup      = "nbu-logger-service"
+	KafkaStartFromEarliest = "earliest"
+	KafkaStartFromLatest   = "latest"
+	KafkaFetchWaitInMillis = 500
+	KafkaFetchMaxBytes     = 4 << 20
+	KafkaRetryInSeconds    = 5
+	KafkaContentTypeHeader = "content-type"
+	KafkaInput             = "kafka"
+)
+
+// Redis streams consumer
+const (
+	RedisStreamsInput               = "redisStreams"
+	DefaultRedisStreamEntryType     = "redis"
+	DefaultRedisStreamField         = "payload"
+	DefaultRedisStreamCount         = 100
+	DefaultRedisStreamBlockInMillis = 5000
+	RedisStreamRetryInSeconds       = 5
+	RedisStreamStart                = "0-0"
+	StreamIDField                   = "streamId"
+)
+
+// Input checkpoints
+const (
+	// DefaultReplayWindowInSeconds is how far back the inputs read a source they have no checkpoint of
+	DefaultReplayWindowInSeconds = 15 * 60
+	OutboxInput                  = "outbox"
+)
+
+// Outbox relay
+const (
+	DefaultOutboxEntryType            = "outbox"
+	DefaultOutboxIDColumn             = "id"
+	DefaultOutboxPayloadColumn        = "payload"
+	DefaultOutboxBatchSize            = 500
+	DefaultOutboxPollIntervalInMillis = 1000
+	OutboxIDField                     = "outboxId"
+)
+
+// Journal export format
+const (
+	JournaldType         = "journald"
+	JournalMaxFields     = 1024
+	JournalMaxFieldBytes = 1 << 20
+	JournalUnitField     = "unit"
+	JournalPIDField      = "pid"
+	JournalFieldsField   = "journal"
+)
+
+// Loki push api
+const (
+	LokiType          = "loki"
+	LokiMetadataField = "metadata"
+	// MaxLokiStreamLabels bounds the labels parsed from the selector of a stream
+	MaxLokiStreamLabels = 1024
+	// ScopeOrgIDHeader is the tenant of the loki clients such as promtail
+	ScopeOrgIDHeader = "X-Scope-OrgID"
+)
+
+// Grpc ingestion api
+const (
+	GRPCLogMethod                  = "/logger.v1.LogService/Log"
+	GRPCBatchLogMethod             = "/logger.v1.LogService/BatchLog"
+	GRPCStreamLogsMethod           = "/logger.v1.LogService/StreamLogs"
+	GRPCReadHeaderTimeoutInSeconds = 10
+)
@@ -2,14 +2,46 @@ package constants
 
 // Log keys
 const (
-	StatusCodeKey = "statusCode"
-	LatencyKey    = "latency"
-	ClientIPKey   = "clientIP"
-	MethodKey     = "method"
-	PathKey       = "path"
-	ErrorKey      = "error"
+	StatusCodeKey  = "statusCode"
+	LatencyKey     = "latency"
+	ClientIPKey    = "clientIP"
+	MethodKey      = "method"
+	PathKey        = "path"
+	ErrorKey       = "error"
+	FieldKey       = "field"
+	ChangesKey     = "changes"
+	TenantKey      = "tenant"
+	ProvisionerKey = "provisioner"
+	AddressKey     = "address"
+	ContainerKey   = "container"
+	NamespaceKey   = "namespace"
+	SinkKey        = "sink"
+	ReportKey      = "report"
+	QueueKey       = "queue"
+	StreamKey      = "stream"
+	SourceKey      = "source"
+	TopicKey       = "topic"
+	PartitionKey   = "partition"
+	OffsetKey      = "offset"
+	IDKey          = "id"
+	TypeKey        = "type"
+	StepKey        = "step"
+	ReasonKey      = "reason"
+	IngestedKey    = "ingested"
+	WebhookKey     = "webhook"
+	EventKey       = "event"
+	PanicKey       = "panic"
+	StackKey       = "stack"
+	RouteKey       = "route"
+	QueryKey       = "query"
+	BuildKey       = "build"
+	InstanceKey    = "instance"
+	KeyIDKey       = "keyId"
+	RetriedKey     = "retried"
+	MaxRetryKey    = "maxRetry"
 
 	HTTPConfigKey     = "httpConfig"
 	DatabaseConfigKey = "databaseConfig"
 	LogLevelKey       = "logLevel"
+	PipelineConfigKey = "pipelineConfig"
 )
@@ -10,3 +10,14 @@ const (
 	FatalLevel = "fatal"
 	PanicLevel = "panic"
 )
+
+// Own logs
+const (
+	ZerologBackend              = "zerolog"
+	ConsoleBackend              = "console"
+	ZapBackend                  = "zap"
+	SlogBackend                 = "slog"
+	SelfIngestID                = "self-ingest"
+	DefaultSelfIngestType       = "logger.internal"
+	DefaultSelfIngestBufferSize = 1000
+)
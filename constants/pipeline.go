@@ -0,0 +1,175 @@
+package constants
+
+// Coercion targets
+const (
+	CoerceToInt       = "int"
+	CoerceToFloat     = "float"
+	CoerceToBool      = "bool"
+	CoerceToString    = "string"
+	CoerceToTimestamp = "timestamp"
+)
+
+// Coercion failure modes
+const (
+	CoercionOnErrorKeep   = "keep"
+	CoercionOnErrorDrop   = "drop"
+	CoercionOnErrorReject = "reject"
+)
+
+// Reserved namespace
+const (
+	MetaNamespace             = "_meta"
+	MetaCollisionRenamePrefix = "client"
+	MetaRenamedFieldsKey      = "renamedFields"
+	MetaTenantKey             = "tenant"
+)
+
+// Label limits
+const (
+	DefaultMaxLabels           = 16
+	DefaultMaxLabelKeyLength   = 64
+	DefaultMaxLabelValueLength = 256
+	LabelKeyPattern            = `^[a-zA-Z_][a-zA-Z0-9_.\-]*$`
+)
+
+// Multiline reassembly
+const (
+	DefaultMultilineMaxLines = 500
+	MessageField             = "message"
+)
+
+// Enrichment
+const (
+	MetaReceivedAtKey = "receivedAt"
+	MetaHostKey       = "host"
+	MetaPodKey        = "pod"
+	MetaClientIPKey   = "clientIp"
+	MetaAPIKeyKey     = "apiKey"
+	DefaultPodNameEnv = "POD_NAME"
+
+	MetaResolvedKey                  = "resolved"
+	EndpointResolver                 = "endpoint"
+	StaticResolver                   = "static"
+	ResolverIDPlaceholder            = "{id}"
+	DefaultResolverTimeoutInMillis   = 200
+	DefaultResolverCacheSize         = 10000
+	DefaultResolverCacheTTLInSeconds = 3600
+	ResolverFailureCacheTTLInSeconds = 10
+)
+
+// Sampling
+const (
+	// ForceSampleHeader set to true keeps every entry of the request, to debug a producer whose type is sampled
+	ForceSampleHeader = "X-Force-Sample"
+)
+
+// Grok extraction
+const (
+	MaxGrokNestingDepth = 16
+	MetaGrokFailureKey  = "grokFailure"
+)
+
+// Classification
+const (
+	MetaCategoriesKey                = "categories"
+	MetaScriptKey                    = "script"
+	DefaultClassifierTimeoutInMillis = 500
+)
+
+// Sensitive data detection
+const (
+	SensitiveFlag          = "flag"
+	SensitiveRedact        = "redact"
+	SensitiveQuarantine    = "quarantine"
+	SensitiveReject        = "reject"
+	SensitivePAN           = "pan"
+	SensitiveAadhaar       = "aadhaar"
+	SensitiveCard          = "card"
+	SensitiveEmail         = "email"
+	SensitivePhone         = "phone"
+	SensitiveJWT           = "jwt"
+	SensitiveSecret        = "secret"
+	SensitiveEntropy       = "entropy"
+	DefaultSecretMinLength = 20
+	SensitiveRulesID       = "sensitive"
+
+	DefaultRedactionMaxImpactPercent = 20
+	RedactionSimulationSampleSize    = 1000
+	RedactedPrefix                   = "[REDACTED:"
+	QuarantineTypePrefix             = "quarantine."
+	LabelsField                      = "labels"
+	MetaSensitiveKey                 = "sensitive"
+	MetaQuarantinedTypeKey           = "quarantinedType"
+	MaxSensitiveProducers            = 10000
+	MaxSensitiveProducerFields       = 32
+)
+
+// Compatible formats
+const (
+	LogbackFormat   = "logback"
+	PinoFormat      = "pino"
+	WinstonFormat   = "winston"
+	WindowsFormat   = "windows"
+	DebeziumFormat  = "debezium"
+	TypeField       = "type"
+	TraceIDField    = "traceId"
+	SpanIDField     = "spanId"
+	EncryptedField  = "encrypted"
+	DataField       = "data"
+	TimeField       = "time"
+	LevelField      = "level"
+	LoggerField     = "logger"
+	ThreadField     = "thread"
+	StackTraceField = "stackTrace"
+	HostField       = "host"
+	ErrorField      = "error"
+	FormDataField   = "data"
+	FormLabelPrefix = "label."
+)
+
+// Windows events
+const (
+	WindowsEventIDField   = "eventId"
+	WindowsChannelField   = "channel"
+	WindowsProviderField  = "provider"
+	WindowsComputerField  = "computer"
+	WindowsRecordIDField  = "recordId"
+	WindowsKeywordsField  = "keywords"
+	WindowsEventDataField = "eventData"
+	WindowsUserDataField  = "userData"
+)
+
+// Database audit entries of change data capture events
+const (
+	DebeziumAuditType     = "audit.database"
+	AuditActionField      = "action"
+	AuditActorField       = "actor"
+	AuditResourceField    = "resource"
+	AuditDatabaseField    = "database"
+	AuditTableField       = "table"
+	AuditConnectorField   = "connector"
+	AuditTransactionField = "transactionId"
+	AuditBeforeField      = "before"
+	AuditAfterField       = "after"
+	AuditChangesField     = "changes"
+)
+
+// XML bodies
+const (
+	DefaultXMLMaxBodyBytes = 1 << 20
+	DefaultXMLMaxDepth     = 32
+	DefaultXMLMaxElements  = 10000
+)
+
+// CloudEvents
+const (
+	CloudEventsSpecVersion    = "1.0"
+	CloudEventsSource         = "/nbu-logger-service"
+	CloudEventsMediaType      = "application/cloudevents+json"
+	CloudEventsBatchMediaType = "application/cloudevents-batch+json"
+	CloudEventsFormat         = "cloudevents"
+	CloudEventsHeaderPrefix   = "Ce-"
+	CloudEventField           = "cloudEvent"
+	CloudEventDataField       = "data"
+	MaxCloudEventAttributeLen = 20
+)
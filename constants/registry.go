@@ -0,0 +1,56 @@
+package constants
+
+// Admin resource kinds
+const (
+	RulesResourceKind   = "rules"
+	SchemasResourceKind = "schemas"
+	TypesResourceKind   = "types"
+	TenantsResourceKind = "tenants"
+	KeysResourceKind    = "keys"
+)
+
+// Admin headers
+const (
+	ETagHeader        = "ETag"
+	IfMatchHeader     = "If-Match"
+	IfNoneMatchHeader = "If-None-Match"
+	AuthorHeader      = "X-Author"
+	LocationHeader    = "Location"
+)
+
+// GitOps
+const (
+	GitOpsAuthor              = "gitops"
+	DefaultGitOpsBranch       = "main"
+	DefaultGitOpsPollInterval = 60
+	GitOpsWorkDirName         = "nbu-logger-gitops"
+	GitHubSignatureHeader     = "X-Hub-Signature-256"
+	GitHubSignaturePrefix     = "sha256="
+	GitOpsCommitKey           = "commit"
+)
+
+// Change actions
+const (
+	ChangeActionCreate  = "create"
+	ChangeActionUpdate  = "update"
+	ChangeActionDelete  = "delete"
+	MaxJournalLineBytes = 16 << 20
+)
+
+// Onboarding
+const (
+	MaxOnboardingKeys                 = 10
+	APIKeySecretBytes                 = 32
+	DefaultProvisionerTimeoutInMillis = 10000
+	MaxProvisionerResponseBytes       = 1 << 20
+	TenantPathPrefixPattern           = `^[a-zA-Z0-9][a-zA-Z0-9_\-]*$`
+)
+
+// Tenants
+const (
+	DefaultTenantShare = 1
+	TenantIDHeader     = "X-Tenant-ID"
+	// daily quota counters are kept for two days, so the count of a day is never reset before it ends
+	DefaultQuotaPrefix     = "nbu-logger:quota:"
+	QuotaCounterTTLInHours = 48
+)
@@ -2,7 +2,132 @@ package constants
 
 // Route constants
 const (
-	SwaggerRoute  = "/swagger/*any"
-	ActuatorRoute = "/actuator/*any"
-	LoggerRoute   = "/logger"
+	SwaggerRoute       = "/swagger/*any"
+	ActuatorRoute      = "/actuator/*any"
+	ActuatorPrefix     = "/actuator/"
+	LoggerRoute        = "/logger"
+	LoggerTextRoute    = "/logger/text"
+	LoggerPixelRoute   = "/logger/pixel"
+	LoggerBatchRoute   = "/logger/batch"
+	LoggerJournalRoute = "/logger/journal/upload"
+	JournalUploadRoute = "/upload"
+	LokiPushRoute      = "/loki/api/v1/push"
+	LogsRoute          = "/logs"
+	LogsStatsRoute     = "/logs/stats"
+	LogsExportRoute    = "/logs/export"
+	LogsRollupRoute    = "/logs/rollup"
+	LogsSessionRoute   = "/logs/session/:id"
+	LogsDecryptRoute   = "/logs/decrypt"
+	AdminRoute         = "/admin"
+	MetricsRoute       = "/metrics"
+	LivenessRoute      = "/healthz"
+	ReadinessRoute     = "/readyz"
+
+	// the routes of the grafana simple json datasource, its url is the one of GrafanaRoute
+	GrafanaRoute            = "/grafana"
+	GrafanaSearchRoute      = "/grafana/search"
+	GrafanaQueryRoute       = "/grafana/query"
+	GrafanaAnnotationsRoute = "/grafana/annotations"
+	GrafanaTagKeysRoute     = "/grafana/tag-keys"
+	GrafanaTagValuesRoute   = "/grafana/tag-values"
+
+	GitOpsStatusRoute = "/gitops/status"
+	GitOpsSyncRoute   = "/gitops/sync"
+
+	ConfigHistoryRoute  = "/config/history"
+	ConfigRollbackRoute = "/config/rollback/:version"
+
+	SensitiveProducersRoute = "/sensitive/producers"
+	RulesSimulateRoute      = "/rules/:id/simulate"
+	RulesTestRoute          = "/rules/test"
+	ValidationFailuresRoute = "/validation-failures"
+	SamplingEscalationRoute = "/sampling/escalation"
+	StatsRoute              = "/stats"
+	RejectedRequestsRoute   = "/requests/rejected"
+	CanariesRoute           = "/canaries"
+	DeletedLogsRoute        = "/logs/deleted"
+	DeleteLogsRoute         = "/logs/delete"
+	RestoreLogsRoute        = "/logs/restore"
+	DLQRoute                = "/dlq"
+	DLQRequeueRoute         = "/dlq/requeue"
+	DLQPurgeRoute           = "/dlq/purge"
+	ReportsRoute            = "/reports"
+	ReportRoute             = "/reports/:id"
+	ReportRunRoute          = "/reports/:id/run"
+	SinksFairShareRoute     = "/sinks/fairshare"
+	WALRoute                = "/wal"
+	ReplicationRoute        = "/replication"
+	ReplicationPromoteRoute = "/replication/promote"
+	PanicsRoute             = "/panics"
+	InputCheckpointsRoute   = "/inputs/checkpoints"
+	InputCheckpointRoute    = "/inputs/checkpoints/:input/:source"
+
+	SlackCommandsRoute = "/slack/commands"
+	SlackActionsRoute  = "/slack/actions"
+
+	OnboardingRoute = "/onboarding"
+)
+
+// APIKeyHeader is the header the api keys of the tenants are sent in
+const APIKeyHeader = "X-API-Key"
+
+// Actuator endpoints served by the service rather than go-actuator
+const (
+	ActuatorEndpointParam      = "any"
+	ActuatorBuildEndpoint      = "/build"
+	ActuatorFeaturesEndpoint   = "/features"
+	ActuatorConfigEndpoint     = "/config"
+	ActuatorGoroutinesEndpoint = "/goroutines"
+)
+
+// Api versions
+const (
+	APIVersionV1        = "v1"
+	APIVersionHeader    = "API-Version"
+	AcceptVersionHeader = "Accept-Version"
+	DeprecationHeader   = "Deprecation"
+	MaxBatchEntries     = 1000
+)
+
+// VersionedRoute is a route of a version of the public api
+type VersionedRoute struct {
+	Method string
+	Path   string
+	// VersionedOnly is set for the routes added after versioning, they have no unversioned alias
+	VersionedOnly bool
+}
+
+// APIVersions are the routes of each version of the public api, mounted under /{version}.
+// A new version gets its own entry, so the routes of the existing ones keep working as they are.
+var APIVersions = map[string][]VersionedRoute{
+	APIVersionV1: {
+		{Method: "POST", Path: LoggerRoute},
+		{Method: "POST", Path: LoggerBatchRoute, VersionedOnly: true},
+		{Method: "POST", Path: LoggerTextRoute},
+		{Method: "GET", Path: LoggerPixelRoute},
+		{Method: "POST", Path: LoggerJournalRoute},
+		{Method: "POST", Path: JournalUploadRoute},
+		{Method: "POST", Path: LokiPushRoute},
+		{Method: "GET", Path: RateLimitRoute, VersionedOnly: true},
+	},
+}
+
+// Rate limits
+const (
+	APIKeyClientPrefix                   = "key:"
+	DefaultRateLimitIdleTimeoutInSeconds = 600
+	RateLimitRoute                       = "/ratelimit"
+	RateLimitLimitHeader                 = "RateLimit-Limit"
+	RateLimitRemainingHeader             = "RateLimit-Remaining"
+	RateLimitResetHeader                 = "RateLimit-Reset"
+	RateLimitPolicyHeader                = "RateLimit-Policy"
+	SecondsPerDay                        = 24 * 60 * 60
+)
+
+// Canaries
+const (
+	CanaryPrimary      = "primary"
+	CanaryCandidate    = "candidate"
+	LoggerCanary       = "logger"
+	MaxPooledBodyBytes = 1 << 20
 )
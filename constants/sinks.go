@@ -0,0 +1,244 @@
+package constants
+
+// Sinks
+const (
+	DefaultSinkBufferSize = 10000
+	// DefaultStarvationThresholdInMillis is the wait in the buffer of a sink past which an entry counts as starved
+	DefaultStarvationThresholdInMillis = 5000
+	DefaultSinkTimeoutInMillis         = 5000
+	DefaultRoutingKey                  = "{type}.{level}"
+	UnknownLevel                       = "unknown"
+	DefaultNATSSubject                 = "logs.{type}.{level}"
+	SinkFlushIntervalInMillis          = 1000
+	MaxSinkResponseBytes               = 1 << 20
+	DefaultPulsarTopic                 = "{type}"
+	DefaultPulsarKey                   = "{type}"
+	DefaultPulsarBatchSize             = 100
+
+	DefaultElasticsearchIndex           = "logs-{type}-{yyyy.MM.dd}"
+	DefaultElasticsearchBatchSize       = 500
+	DefaultElasticsearchMaxRetries      = 3
+	DefaultElasticsearchBackoffInMillis = 200
+
+	DefaultCloudWatchEndpoint        = "https://logs.%s.amazonaws.com"
+	DefaultCloudWatchLogStream       = "{type}"
+	DefaultCloudWatchMaxRetries      = 3
+	DefaultCloudWatchBackoffInMillis = 200
+	// the limits of the PutLogEvents requests, the size of an event is the one of its message and 26 bytes
+	MaxCloudWatchBatchEvents      = 10000
+	MaxCloudWatchBatchBytes       = 1 << 20
+	MaxCloudWatchEventBytes       = 256 << 10
+	CloudWatchEventOverheadBytes  = 26
+	MaxCloudWatchBatchSpanInHours = 24
+	MaxCloudWatchLogStreamLength  = 512
+	CloudWatchService             = "logs"
+	CloudWatchTargetPrefix        = "Logs_20140328."
+	CloudWatchMediaType           = "application/x-amz-json-1.1"
+
+	DefaultWebhookBatchSize = 100
+	FilePermissions         = 0o640
+	FileDirPermissions      = 0o755
+)
+
+// Sink output templates
+const (
+	JSONFormat             = "json"
+	LogfmtFormat           = "logfmt"
+	CEFFormat              = "cef"
+	LEEFFormat             = "leef"
+	DefaultTemplateVendor  = "Angel One"
+	DefaultTemplateProduct = "nbu-logger-service"
+	DefaultTemplateVersion = "1.0"
+	UnknownSeverity        = 5
+	CEFVersion             = "CEF:0"
+	CEFTimeKey             = "rt"
+	CEFMessageKey          = "msg"
+	LEEFVersion            = "LEEF:1.0"
+	LEEFTimeKey            = "devTime"
+	LEEFTimeFormatKey      = "devTimeFormat"
+	LEEFTimeFormat         = "MMM dd yyyy HH:mm:ss.SSS zzz"
+	LEEFTimeLayout         = "Jan 02 2006 15:04:05.000 MST"
+	LEEFSeverityKey        = "sev"
+	TimestampField         = "timestamp"
+	MinSeverity            = 0
+	MinLEEFSeverity        = 1
+	MaxSeverity            = 10
+	MaxCEFDeviceLength     = 63
+	MaxCEFVersionLength    = 31
+	MaxCEFEventIDLength    = 1023
+	MaxCEFNameLength       = 512
+	CEFLabelSuffix         = "Label"
+)
+
+// Security channel alerts
+const (
+	DefaultAlertTimeoutInMillis   = 5000
+	DefaultAlertIntervalInSeconds = 300
+	MaxAlertKeys                  = 10000
+	AlertRuleKeyPrefix            = "rule:"
+	// DefaultAlertAckDurationInMinutes is how long the repeats of an acknowledged alert are not sent
+	DefaultAlertAckDurationInMinutes = 60
+	AlertIDLength                    = 8
+	AckAlertAction                   = "ack_alert"
+	SilenceRuleAction                = "silence_rule"
+	// DefaultSilenceDuration is the silence of the rules silenced from the buttons of their alerts
+	DefaultSilenceDuration = "1h"
+)
+
+// Slack app
+const (
+	SlackSignatureHeader        = "X-Slack-Signature"
+	SlackTimestampHeader        = "X-Slack-Request-Timestamp"
+	SlackSignatureVersion       = "v0"
+	SlackSignaturePrefix        = "v0="
+	MaxSlackRequestAgeInSeconds = 300
+	DefaultSlackWindowInMinutes = 60
+	DefaultSlackMaxResults      = 10
+	DefaultSlackTimeoutInMillis = 5000
+	SlackSearchCommand          = "search"
+	SlackReportCommand          = "report"
+	SlackAckCommand             = "ack"
+	SlackSilenceCommand         = "silence"
+	SlackUnsilenceCommand       = "unsilence"
+	SlackSilencesCommand        = "silences"
+	SlackEphemeral              = "ephemeral"
+	SlackInChannel              = "in_channel"
+	SlackPayloadField           = "payload"
+)
+
+// Lifecycle events
+const (
+	LogTypeSeenEvent                   = "nbu.logger.type.seen"
+	SchemaViolationSpikeEvent          = "nbu.logger.schema.violations.spiked"
+	QuotaExceededEvent                 = "nbu.logger.quota.exceeded"
+	DeadLetterThresholdEvent           = "nbu.logger.dlq.threshold.crossed"
+	DefaultSeenTypesKey                = "nbu-logger:types"
+	DefaultSchemaViolationWindowInSecs = 60
+	MaxSeenTypes                       = 10000
+	SignatureHeader                    = "X-Signature-256"
+	SignaturePrefix                    = "sha256="
+)
+
+// Tracing
+const (
+	TraceparentHeader                   = "traceparent"
+	TracestateHeader                    = "tracestate"
+	OTLPTracesPath                      = "/v1/traces"
+	TracingServiceName                  = "nbu-logger-service"
+	TracingScopeName                    = "github.com/angel-one/nbu-logger-service/tracing"
+	IngestSpanName                      = "ingest"
+	SinkSpanPrefix                      = "sink "
+	LogTypeAttribute                    = "log.type"
+	StatusCodeAttribute                 = "http.status_code"
+	MethodAttribute                     = "http.method"
+	RouteAttribute                      = "http.route"
+	DefaultTracingBatchSize             = 512
+	DefaultTracingQueueSize             = 2048
+	DefaultTracingFlushIntervalInMillis = 5000
+	DefaultTracingTimeoutInMillis       = 10000
+)
+
+// Ingestion queue
+const (
+	IngestTaskType       = "logger:ingest"
+	DefaultIngestQueue   = "logs"
+	DefaultQueueWorkers  = 10
+	DefaultQueueMaxRetry = 5
+	// QueueDiscoveryIntervalInSeconds is the time between the discoveries of the tenant queues found in redis
+	QueueDiscoveryIntervalInSeconds = 30
+
+	DefaultQueueRetryBaseDelayInMillis = 1000
+	DefaultQueueRetryMaxDelayInMillis  = 600000
+	DefaultQueueRetryJitterRatio       = 0.2
+)
+
+// Sampling escalation
+const (
+	DefaultEscalationIntervalInSeconds = 10
+	DefaultEscalationCooldownInSeconds = 300
+	DefaultEscalationBufferRatio       = 0.7
+	EscalationAlertKeyPrefix           = "escalation:"
+)
+
+// Operational stats
+const (
+	DefaultStatsKey               = "nbu-logger:stats"
+	DefaultStatsIntervalInSeconds = 10
+	DefaultStatsTopTalkers        = 10
+	// StatsStaleIntervals is the number of intervals after which the snapshot of an instance is taken for gone
+	StatsStaleIntervals = 3
+	MaxStatsTenants     = 1000
+	// SinkLatencyWeight is the weight of the latest delivery in the moving average of the latency of a sink
+	SinkLatencyWeight = 0.2
+)
+
+// Active-active deduplication
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	DefaultDedupTTLInSeconds = 24 * 60 * 60
+	DefaultDedupPrefix       = "nbu-logger:dedup:"
+	MaxMemoryDedupKeys       = 1000000
+	MetaRegionKey            = "region"
+	// the entries of the keys are in flight until they are accepted, the claims of a process that died expire
+	DedupInFlightPrefix       = "in-flight:"
+	DedupInFlightTTLInSeconds = 30
+)
+
+// Write ahead log
+const (
+	DefaultWALSegmentSizeInBytes    = 64 << 20
+	DefaultWALRetryIntervalInMillis = 1000
+	WALSegmentSuffix                = ".wal"
+	WALCheckpointFile               = "checkpoint"
+	// WALHeaderSize is the length and the crc32 of the payload of a record
+	WALHeaderSize = 8
+	// WALCheckpointEvery is the number of entries replayed between the records of the position while behind
+	WALCheckpointEvery = 100
+)
+
+// Delivery classes
+const (
+	// DeliveryClassHeader is the delivery guarantee the producer asks for the entries of the request
+	DeliveryClassHeader = "X-Delivery-Class"
+	BestEffortDelivery  = "best-effort"
+	AtLeastOnceDelivery = "at-least-once"
+	DurableAckDelivery  = "durable-ack"
+)
+
+// Dead letter queue
+const (
+	DefaultMaxDeadLetters = 10000
+	DefaultDeadLettersKey = "logger:dlq"
+)
+
+// Cross region replication
+const (
+	DefaultObjectStoreEndpoint          = "https://s3.%s.amazonaws.com"
+	ObjectStoreService                  = "s3"
+	DefaultReplicationPrefix            = "nbu-logger"
+	DefaultReplicationIntervalInSeconds = 30
+	DefaultReplicationMaxEntries        = 100000
+	ReplicationSnapshotObject           = "snapshot.json"
+	ReplicationPromotionObject          = "promoted.json"
+)
+
+// alert ticket constants
+const (
+	JiraTracker                 = "jira"
+	ServiceNowTracker           = "servicenow"
+	DefaultJiraIssueType        = "Bug"
+	DefaultJiraReopenTransition = "Reopen"
+	JiraDoneCategory            = "done"
+	DefaultServiceNowTable      = "incident"
+	ServiceNowNewState          = "1"
+	ServiceNowResolvedState     = "6"
+	ServiceNowClosedState       = "7"
+	TicketKeyPrefix             = "ticket:"
+	// TicketLabelPrefix is the prefix of the jira label of the fingerprint of the error group of a ticket
+	TicketLabelPrefix      = "log-group-"
+	FingerprintLength      = 16
+	MaxTicketSummaryLength = 255
+	TicketCreated          = "created"
+	TicketUpdated          = "updated"
+	TicketReopened         = "reopened"
+)
@@ -0,0 +1,152 @@
+// Package crashes keeps the panics the service recovered from, so that its own crashes are log data like the
+// entries of the producers, with the stack, the request and the build they happened in.
+package crashes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/utils/buildinfo"
+	"github.com/google/uuid"
+)
+
+// Config is the configuration of the capture of panics
+type Config struct {
+	// Size is the number of panics kept for the admin api, the oldest are overwritten
+	Size int `json:"size" mapstructure:"size"`
+	// Type is the log type of the entries of the panics
+	Type string `json:"type" mapstructure:"type"`
+	// Sink is the sink the entries of the panics are sent to, they are kept in the store without one
+	Sink string `json:"sink" mapstructure:"sink"`
+}
+
+// Panic is a panic recovered while handling a request
+type Panic struct {
+	ID     string         `json:"id"`
+	Time   time.Time      `json:"time"`
+	Value  string         `json:"value"`
+	Stack  string         `json:"stack"`
+	Method string         `json:"method,omitempty"`
+	Path   string         `json:"path,omitempty"`
+	Route  string         `json:"route,omitempty"`
+	Query  string         `json:"query,omitempty"`
+	Tenant string         `json:"tenant,omitempty"`
+	Client string         `json:"client,omitempty"`
+	Build  buildinfo.Info `json:"build"`
+	// TraceID is the trace of the request, when it was traced
+	TraceID string `json:"traceId,omitempty"`
+}
+
+// Recorder keeps the latest panics and reports them as entries
+type Recorder struct {
+	mu     sync.Mutex
+	config Config
+	panics []Panic
+	next   int
+	full   bool
+}
+
+var r = New(Config{})
+
+// New is used to create a recorder for the config
+func New(config Config) *Recorder {
+	if config.Size <= 0 {
+		config.Size = constants.DefaultPanicsSize
+	}
+	if config.Type == "" {
+		config.Type = constants.DefaultPanicEntryType
+	}
+	return &Recorder{config: config, panics: make([]Panic, config.Size)}
+}
+
+// Init is used to replace the default recorder
+func Init(config Config) {
+	r = New(config)
+}
+
+// Get is used to get the default recorder
+func Get() *Recorder {
+	return r
+}
+
+// Record is used to keep the panic and report it as an entry. The entry skips the pipeline and the queue, as
+// they may be what panicked, and is sent to the sink of the panics or else kept in the store.
+func (r *Recorder) Record(ctx context.Context, p Panic) Panic {
+	if p.ID == "" {
+		p.ID = uuid.NewString()
+	}
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+	if len(p.Stack) > constants.MaxPanicStackBytes {
+		p.Stack = p.Stack[:constants.MaxPanicStackBytes]
+	}
+	p.Build = buildinfo.Get()
+	log.Error(ctx).Str(constants.IDKey, p.ID).Str(constants.MethodKey, p.Method).Str(constants.PathKey, p.Path).
+		Str(constants.PanicKey, p.Value).Str(constants.StackKey, p.Stack).Msg("recovered from panic")
+	r.mu.Lock()
+	r.panics[r.next] = p
+	r.next = (r.next + 1) % len(r.panics)
+	if r.next == 0 {
+		r.full = true
+	}
+	r.mu.Unlock()
+
+	entry := r.entry(p)
+	var err error
+	if r.config.Sink != "" {
+		err = sinks.Requeue(ctx, r.config.Sink, entry)
+	} else {
+		_, err = store.Get().Add(ctx, entry)
+	}
+	if err != nil {
+		log.Error(ctx).Err(err).Str(constants.IDKey, p.ID).Msg("error reporting panic")
+	}
+	return p
+}
+
+// List is used to get the kept panics, the latest first
+func (r *Recorder) List() []Panic {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	count := r.next
+	if r.full {
+		count = len(r.panics)
+	}
+	panics := make([]Panic, 0, count)
+	for i := 1; i <= count; i++ {
+		panics = append(panics, r.panics[(r.next-i+len(r.panics))%len(r.panics)])
+	}
+	return panics
+}
+
+// entry is used to get the entry reporting the panic
+func (r *Recorder) entry(p Panic) models.LogEntry {
+	data := map[string]interface{}{
+		constants.IDKey:       p.ID,
+		constants.PanicKey:    p.Value,
+		constants.StackKey:    p.Stack,
+		constants.MethodKey:   p.Method,
+		constants.PathKey:     p.Path,
+		constants.RouteKey:    p.Route,
+		constants.QueryKey:    p.Query,
+		constants.TenantKey:   p.Tenant,
+		constants.ClientIPKey: p.Client,
+		constants.BuildKey: map[string]interface{}{
+			"version":   p.Build.Version,
+			"commit":    p.Build.Commit,
+			"buildTime": p.Build.BuildTime,
+			"goVersion": p.Build.GoVersion,
+			"modified":  p.Build.Modified,
+		},
+	}
+	return models.LogEntry{Type: r.config.Type, Level: constants.ErrorLevel, Timestamp: &models.Timestamp{Time: p.Time},
+		Data:    data,
+		TraceID: p.TraceID}
+}
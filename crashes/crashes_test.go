@@ -0,0 +1,35 @@
+package crashes_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/crashes"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord(t *testing.T) {
+	ctx := context.Background()
+	store.Init(store.NewMemory(10))
+	recorder := crashes.New(crashes.Config{Size: 2})
+	for _, path := range []string{"/a", "/b", "/c"} {
+		recorder.Record(ctx, crashes.Panic{Value: "boom", Stack: "goroutine 1", Method: "POST", Path: path})
+	}
+	panics := recorder.List()
+	assert.Len(t, panics, 2)
+	assert.Equal(t, "/c", panics[0].Path)
+	assert.Equal(t, "/b", panics[1].Path)
+	assert.NotEmpty(t, panics[0].ID)
+	assert.NotEmpty(t, panics[0].Build.GoVersion)
+
+	records, err := store.Get().Query(ctx, store.Query{Type: constants.DefaultPanicEntryType})
+	assert.NoError(t, err)
+	assert.Len(t, records, 3)
+	entry := records[0].Entry
+	assert.Equal(t, constants.ErrorLevel, entry.Level)
+	assert.Equal(t, "boom", entry.Data[constants.PanicKey])
+	assert.Equal(t, "/c", entry.Data[constants.PathKey])
+	assert.Equal(t, panics[0].ID, entry.Data[constants.IDKey])
+}
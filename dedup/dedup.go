@@ -0,0 +1,213 @@
+// Package dedup makes retried and failed over submissions idempotent across regions. Keys are claimed
+// in a key store shared by the regions, e.g. an active-active redis, so an entry accepted in one region
+// is recognized as a duplicate when the client resends it to the other.
+package dedup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/go-redis/redis/v8"
+)
+
+// Config is the configuration of the active-active deployment
+type Config struct {
+	// Region is the region of the process, entries are tagged with it
+	Region string `json:"region" mapstructure:"region"`
+	// RedisURL is the replicated key store, keys are only known to the process without it
+	RedisURL string `json:"-" mapstructure:"redisUrl"`
+	// TTLInSeconds is how long a key is remembered, it has to cover the retries and failovers of the clients
+	TTLInSeconds int `json:"ttlInSeconds" mapstructure:"ttlInSeconds"`
+	// Prefix namespaces the keys in the store
+	Prefix string `json:"prefix" mapstructure:"prefix"`
+}
+
+// Store keeps the claimed keys
+type Store interface {
+	// Claim is used to record the key with the value, when it was already claimed false is returned along with
+	// the value it was claimed with
+	Claim(ctx context.Context, key, value string, ttl time.Duration) (bool, string, error)
+	// Commit is used to replace the value of the key, once its entry is accepted
+	Commit(ctx context.Context, key, value string, ttl time.Duration) error
+	// Release is used to forget the key, so the entry can be sent again
+	Release(ctx context.Context, key string) error
+}
+
+// Status is the outcome of claiming an idempotency key
+type Status int
+
+const (
+	// Claimed means the key is new, its entry has to be ingested and the key committed or released after
+	Claimed Status = iota
+	// Duplicate means the entry of the key was already accepted
+	Duplicate
+	// InFlight means the entry of the key is being ingested, the client has to retry once it is done
+	InFlight
+)
+
+var (
+	config       = Config{TTLInSeconds: constants.DefaultDedupTTLInSeconds, Prefix: constants.DefaultDedupPrefix}
+	store  Store = NewMemory()
+)
+
+// Init is used to set up the region and the key store
+func Init(c Config) error {
+	if c.TTLInSeconds <= 0 {
+		c.TTLInSeconds = constants.DefaultDedupTTLInSeconds
+	}
+	if c.Prefix == "" {
+		c.Prefix = constants.DefaultDedupPrefix
+	}
+	s := Store(NewMemory())
+	if c.RedisURL != "" {
+		options, err := redis.ParseURL(c.RedisURL)
+		if err != nil {
+			return fmt.Errorf("invalid dedup redis url : %w", err)
+		}
+		s = &Redis{client: redis.NewClient(options)}
+	}
+	config, store = c, s
+	return nil
+}
+
+// Get is used to get the key store
+func Get() Store {
+	return store
+}
+
+// Region is used to get the region of the process, empty when not configured
+func Region() string {
+	return config.Region
+}
+
+// Claim is used to claim the idempotency key of the tenant while its entry is ingested. The key is only marked
+// as accepted by Commit, so a resend arriving while the entry is in flight is not acknowledged before it is
+// known to be accepted. The in flight claims expire soon, so the key is not held by a process that died.
+// The store failing must not lose entries, so the key is taken as new then.
+func Claim(ctx context.Context, tenant, key string) Status {
+	claimed, value, err := store.Claim(ctx, config.Prefix+tenant+":"+key,
+		constants.DedupInFlightPrefix+config.Region, constants.DedupInFlightTTLInSeconds*time.Second)
+	if err != nil {
+		log.Warn(ctx).Err(err).Msg("error claiming idempotency key, accepting the entry")
+		return Claimed
+	}
+	switch {
+	case claimed:
+		return Claimed
+	case strings.HasPrefix(value, constants.DedupInFlightPrefix):
+		return InFlight
+	default:
+		return Duplicate
+	}
+}
+
+// Commit is used to mark the idempotency key of an accepted entry, the resends of the entry are duplicates
+// until the ttl passes
+func Commit(ctx context.Context, tenant, key string) {
+	if err := store.Commit(ctx, config.Prefix+tenant+":"+key, config.Region,
+		time.Duration(config.TTLInSeconds)*time.Second); err != nil {
+		log.Warn(ctx).Err(err).Msg("error committing idempotency key")
+	}
+}
+
+// Release is used to forget the idempotency key of an entry that was not accepted
+func Release(ctx context.Context, tenant, key string) {
+	if err := store.Release(ctx, config.Prefix+tenant+":"+key); err != nil {
+		log.Warn(ctx).Err(err).Msg("error releasing idempotency key")
+	}
+}
+
+// Redis is the key store of a redis shared by the regions
+type Redis struct {
+	client *redis.Client
+}
+
+// Ping is used to check that redis is reachable
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Claim is used to set the key when it does not exist, or else get its value
+func (r *Redis) Claim(ctx context.Context, key, value string, ttl time.Duration) (bool, string, error) {
+	claimed, err := r.client.SetNX(ctx, key, value, ttl).Result()
+	if err != nil || claimed {
+		return claimed, "", err
+	}
+	existing, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		// the key was released in between, its entry is taken as still in flight for the client to retry
+		return false, constants.DedupInFlightPrefix, nil
+	}
+	return false, existing, err
+}
+
+// Commit is used to set the value of the key
+func (r *Redis) Commit(ctx context.Context, key, value string, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Release is used to delete the key
+func (r *Redis) Release(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+// Memory is the key store of a single process
+type Memory struct {
+	mu   sync.Mutex
+	keys map[string]memoryKey
+}
+
+// memoryKey is the value of a key and when it expires
+type memoryKey struct {
+	value  string
+	expiry time.Time
+}
+
+// NewMemory is used to create an empty in memory key store
+func NewMemory() *Memory {
+	return &Memory{keys: make(map[string]memoryKey)}
+}
+
+// Claim is used to record the key until the ttl passes
+func (m *Memory) Claim(_ context.Context, key, value string, ttl time.Duration) (bool, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if existing, ok := m.keys[key]; ok && now.Before(existing.expiry) {
+		return false, existing.value, nil
+	}
+	if len(m.keys) >= constants.MaxMemoryDedupKeys {
+		for k, existing := range m.keys {
+			if !now.Before(existing.expiry) {
+				delete(m.keys, k)
+			}
+		}
+		if len(m.keys) >= constants.MaxMemoryDedupKeys {
+			return false, "", fmt.Errorf("dedup memory store is full")
+		}
+	}
+	m.keys[key] = memoryKey{value: value, expiry: now.Add(ttl)}
+	return true, "", nil
+}
+
+// Commit is used to set the value of the key until the ttl passes
+func (m *Memory) Commit(_ context.Context, key, value string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keys[key] = memoryKey{value: value, expiry: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release is used to forget the key
+func (m *Memory) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, key)
+	return nil
+}
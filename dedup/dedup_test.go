@@ -0,0 +1,35 @@
+package dedup_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/dedup"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaim(t *testing.T) {
+	ctx := context.Background()
+	assert.NoError(t, dedup.Init(dedup.Config{Region: "ap-south-1"}))
+
+	assert.Equal(t, dedup.Claimed, dedup.Claim(ctx, "tenant", "key"))
+	// a resend is not acknowledged before the entry is accepted, as it could still be rejected
+	assert.Equal(t, dedup.InFlight, dedup.Claim(ctx, "tenant", "key"))
+	assert.Equal(t, dedup.Claimed, dedup.Claim(ctx, "other", "key"))
+	dedup.Commit(ctx, "tenant", "key")
+	assert.Equal(t, dedup.Duplicate, dedup.Claim(ctx, "tenant", "key"))
+
+	dedup.Release(ctx, "tenant", "key")
+	assert.Equal(t, dedup.Claimed, dedup.Claim(ctx, "tenant", "key"))
+
+	entry := models.LogEntry{Type: "dedup.test", Data: map[string]interface{}{"message": "hello"}}
+	assert.NoError(t, ingest.Entry(ctx, &entry))
+	region, _ := pipeline.GetMeta(&entry, constants.MetaRegionKey)
+	assert.Equal(t, "ap-south-1", region)
+
+	assert.Error(t, dedup.Init(dedup.Config{RedisURL: "://invalid"}))
+}
@@ -0,0 +1,262 @@
+// Package dlq keeps the entries the sinks permanently failed to deliver, so that they can be inspected and
+// requeued once the sink recovers, or purged. Letters are kept in a redis list shared by the processes when
+// configured, in memory otherwise.
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/lifecycle"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// Config is the configuration of the dead letter queue
+type Config struct {
+	// RedisURL is the redis the letters are kept in, they are only kept by the process without it
+	RedisURL string `json:"-" mapstructure:"redisUrl"`
+	// Key is the redis list of the letters
+	Key string `json:"key" mapstructure:"key"`
+	// MaxLetters is the number of letters kept, the oldest are dropped beyond it
+	MaxLetters int `json:"maxLetters" mapstructure:"maxLetters"`
+}
+
+// Letter is an entry a sink failed to deliver
+type Letter struct {
+	ID       string          `json:"id"`
+	Sink     string          `json:"sink"`
+	Entry    models.LogEntry `json:"entry"`
+	Error    string          `json:"error"`
+	FailedAt time.Time       `json:"failedAt"`
+}
+
+// Filter selects letters, empty fields match all
+type Filter struct {
+	Sink string
+	IDs  []string
+	// Limit is the maximum number of letters returned, 0 means no limit
+	Limit int
+}
+
+// Store keeps the letters
+type Store interface {
+	// Add is used to keep the letter
+	Add(ctx context.Context, letter Letter) error
+	// List is used to get the matching letters, newest first
+	List(ctx context.Context, filter Filter) ([]Letter, error)
+	// Remove is used to drop the letters of the ids and get how many were dropped
+	Remove(ctx context.Context, ids []string) (int, error)
+	// Len is used to get the number of letters
+	Len(ctx context.Context) (int, error)
+}
+
+var store Store = NewMemory(constants.DefaultMaxDeadLetters)
+
+// Init is used to set up the store of the letters
+func Init(c Config) error {
+	if c.MaxLetters <= 0 {
+		c.MaxLetters = constants.DefaultMaxDeadLetters
+	}
+	if c.Key == "" {
+		c.Key = constants.DefaultDeadLettersKey
+	}
+	s := Store(NewMemory(c.MaxLetters))
+	if c.RedisURL != "" {
+		options, err := redis.ParseURL(c.RedisURL)
+		if err != nil {
+			return fmt.Errorf("invalid dlq redis url : %w", err)
+		}
+		s = &Redis{client: redis.NewClient(options), key: c.Key, maxLetters: c.MaxLetters}
+	}
+	store = s
+	return nil
+}
+
+// Get is used to get the store of the letters
+func Get() Store {
+	return store
+}
+
+// Add is used to keep the entry the sink failed to deliver with the error it failed with
+func Add(ctx context.Context, sink string, entry models.LogEntry, cause error) {
+	letter := Letter{ID: uuid.NewString(), Sink: sink, Entry: entry, Error: cause.Error(), FailedAt: time.Now()}
+	if err := store.Add(ctx, letter); err != nil {
+		log.Error(ctx).Err(err).Str(constants.SinkKey, sink).Msg("error dead lettering entry, it is lost")
+		return
+	}
+	if count, err := store.Len(ctx); err == nil {
+		lifecycle.DeadLetters(ctx, count)
+	}
+}
+
+// matches is used to check whether the letter is selected by the filter
+func (f Filter) matches(letter Letter) bool {
+	if f.Sink != "" && letter.Sink != f.Sink {
+		return false
+	}
+	if len(f.IDs) == 0 {
+		return true
+	}
+	for _, id := range f.IDs {
+		if letter.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Redis keeps the letters in a redis list, newest first
+type Redis struct {
+	client     *redis.Client
+	key        string
+	maxLetters int
+}
+
+// Ping is used to check that redis is reachable
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+// Add is used to push the letter and drop the oldest ones beyond the max
+func (r *Redis) Add(ctx context.Context, letter Letter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, r.key, data)
+		pipe.LTrim(ctx, r.key, 0, int64(r.maxLetters-1))
+		return nil
+	})
+	return err
+}
+
+// List is used to get the matching letters, newest first
+func (r *Redis) List(ctx context.Context, filter Filter) ([]Letter, error) {
+	letters, _, err := r.list(ctx, filter)
+	return letters, err
+}
+
+// Remove is used to drop the letters by their encoded value, as redis lists can not be edited by index safely
+func (r *Redis) Remove(ctx context.Context, ids []string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	_, values, err := r.list(ctx, Filter{IDs: ids})
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, value := range values {
+		n, err := r.client.LRem(ctx, r.key, 1, value).Result()
+		if err != nil {
+			return removed, err
+		}
+		removed += int(n)
+	}
+	return removed, nil
+}
+
+// Len is used to get the number of letters
+func (r *Redis) Len(ctx context.Context) (int, error) {
+	count, err := r.client.LLen(ctx, r.key).Result()
+	return int(count), err
+}
+
+// list is used to get the matching letters along with their encoded values
+func (r *Redis) list(ctx context.Context, filter Filter) ([]Letter, []string, error) {
+	values, err := r.client.LRange(ctx, r.key, 0, -1).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	letters := make([]Letter, 0)
+	var matched []string
+	for _, value := range values {
+		var letter Letter
+		if err = json.Unmarshal([]byte(value), &letter); err != nil {
+			log.Warn(ctx).Err(err).Msg("skipped invalid dead letter")
+			continue
+		}
+		if !filter.matches(letter) {
+			continue
+		}
+		letters, matched = append(letters, letter), append(matched, value)
+		if filter.Limit > 0 && len(letters) == filter.Limit {
+			break
+		}
+	}
+	return letters, matched, nil
+}
+
+// Memory keeps the letters of a single process
+type Memory struct {
+	mu         sync.Mutex
+	letters    []Letter
+	maxLetters int
+}
+
+// NewMemory is used to create an empty in memory store keeping up to max letters
+func NewMemory(maxLetters int) *Memory {
+	return &Memory{maxLetters: maxLetters}
+}
+
+// Add is used to keep the letter and drop the oldest one beyond the max
+func (m *Memory) Add(_ context.Context, letter Letter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.letters) >= m.maxLetters {
+		m.letters = m.letters[1:]
+	}
+	m.letters = append(m.letters, letter)
+	return nil
+}
+
+// List is used to get the matching letters, newest first
+func (m *Memory) List(_ context.Context, filter Filter) ([]Letter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	letters := make([]Letter, 0)
+	for i := len(m.letters) - 1; i >= 0; i-- {
+		if !filter.matches(m.letters[i]) {
+			continue
+		}
+		letters = append(letters, m.letters[i])
+		if filter.Limit > 0 && len(letters) == filter.Limit {
+			break
+		}
+	}
+	return letters, nil
+}
+
+// Len is used to get the number of letters
+func (m *Memory) Len(_ context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.letters), nil
+}
+
+// Remove is used to drop the letters
+func (m *Memory) Remove(_ context.Context, ids []string) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	filter := Filter{IDs: ids}
+	letters := m.letters[:0]
+	for _, letter := range m.letters {
+		if !filter.matches(letter) {
+			letters = append(letters, letter)
+		}
+	}
+	removed := len(m.letters) - len(letters)
+	m.letters = letters
+	return removed, nil
+}
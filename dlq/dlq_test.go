@@ -0,0 +1,50 @@
+package dlq_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/dlq"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+type brokenSink struct{}
+
+func (brokenSink) Name() string {
+	return "broken"
+}
+
+func (brokenSink) Send(context.Context, models.LogEntry) error {
+	return errors.New("connection refused")
+}
+
+func TestDeadLetters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, dlq.Init(dlq.Config{MaxLetters: 2}))
+	sinks.Add(ctx, brokenSink{}, 10)
+	for _, kind := range []string{"a", "b", "c"} {
+		sinks.Emit(ctx, models.LogEntry{Type: kind})
+	}
+
+	var letters []dlq.Letter
+	assert.Eventually(t, func() bool {
+		letters, _ = dlq.Get().List(ctx, dlq.Filter{Sink: "broken"})
+		return len(letters) == 2 && letters[0].Entry.Type == "c"
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "connection refused", letters[0].Error)
+	assert.Equal(t, "b", letters[1].Entry.Type)
+
+	removed, err := dlq.Get().Remove(ctx, nil)
+	assert.NoError(t, err)
+	assert.Zero(t, removed)
+	removed, err = dlq.Get().Remove(ctx, []string{letters[1].ID})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	letters, _ = dlq.Get().List(ctx, dlq.Filter{})
+	assert.Len(t, letters, 1)
+}
@@ -0,0 +1,164 @@
+// Package encryption enforces the client side encryption of the most sensitive log types. Their entries carry
+// their data in an envelope encrypted with a key of the client, the service stores and routes them by their type,
+// level and labels without decrypting them, and only decrypts them in memory for the readers of the key who
+// present it.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+var (
+	// ErrPlaintext is returned when an entry of a type that has to be encrypted carries plaintext data
+	ErrPlaintext = errors.New("entries of the type have to be encrypted")
+	// ErrInvalidEnvelope is returned when the envelope of an encrypted entry can not be decrypted by anyone
+	ErrInvalidEnvelope = errors.New("invalid encrypted envelope")
+	// ErrUnauthorized is returned when decrypting with a token that is not a reader of the key
+	ErrUnauthorized = errors.New("not a reader of the key")
+	// ErrDecryption is returned when the key does not decrypt the entry
+	ErrDecryption = errors.New("entry can not be decrypted with the key")
+)
+
+// Config is the configuration of the client side encryption
+type Config struct {
+	// Types are the log types whose entries have to be encrypted, their plaintext entries are rejected
+	Types []string `json:"types" mapstructure:"types"`
+	// Keys are the keys the entries are encrypted with, entries of other keys are rejected when there are keys
+	Keys []KeyConfig `json:"keys" mapstructure:"keys"`
+}
+
+// KeyConfig is a key of the clients, only its id and its readers are known to the service
+type KeyConfig struct {
+	ID string `json:"id" mapstructure:"id"`
+	// Readers are the bearer tokens of the readers allowed to decrypt the entries of the key
+	Readers []string `json:"-" mapstructure:"readers"`
+}
+
+// Encryption checks the encrypted entries at ingestion and decrypts them for the readers of their key
+type Encryption struct {
+	types   map[string]bool
+	readers map[string][]string
+}
+
+var e, _ = New(Config{})
+
+// New is used to create the encryption of the config
+func New(config Config) (*Encryption, error) {
+	enc := &Encryption{types: make(map[string]bool, len(config.Types)), readers: make(map[string][]string)}
+	for _, t := range config.Types {
+		enc.types[t] = true
+	}
+	for _, key := range config.Keys {
+		if key.ID == "" {
+			return nil, fmt.Errorf("encryption key needs an id")
+		}
+		if _, ok := enc.readers[key.ID]; ok {
+			return nil, fmt.Errorf("encryption key %s is configured twice", key.ID)
+		}
+		enc.readers[key.ID] = key.Readers
+	}
+	return enc, nil
+}
+
+// Init is used to replace the default encryption
+func Init(config Config) error {
+	enc, err := New(config)
+	if err != nil {
+		return err
+	}
+	e = enc
+	return nil
+}
+
+// Get is used to get the default encryption
+func Get() *Encryption {
+	return e
+}
+
+// Check is used to reject the plaintext entries of the types that have to be encrypted, and the encrypted
+// entries that carry plaintext data too or that no reader could decrypt
+func (e *Encryption) Check(entry models.LogEntry) error {
+	envelope := entry.Encrypted
+	if envelope == nil {
+		if e.types[entry.Type] {
+			return fmt.Errorf("%w, type %s", ErrPlaintext, entry.Type)
+		}
+		return nil
+	}
+	switch {
+	case envelope.KeyID == "":
+		return fmt.Errorf("%w: keyId is required", ErrInvalidEnvelope)
+	case envelope.Algorithm != constants.EnvelopeAlgorithm:
+		return fmt.Errorf("%w: algorithm %q is not %s", ErrInvalidEnvelope, envelope.Algorithm,
+			constants.EnvelopeAlgorithm)
+	case len(envelope.Nonce) != constants.EnvelopeNonceSize:
+		return fmt.Errorf("%w: nonce has to be %d bytes", ErrInvalidEnvelope, constants.EnvelopeNonceSize)
+	case len(envelope.Ciphertext) == 0:
+		return fmt.Errorf("%w: ciphertext is required", ErrInvalidEnvelope)
+	case len(entry.Data) > 0:
+		return fmt.Errorf("%w: data has to be in the ciphertext", ErrInvalidEnvelope)
+	}
+	if _, ok := e.readers[envelope.KeyID]; len(e.readers) > 0 && !ok {
+		return fmt.Errorf("%w: unknown key %s", ErrInvalidEnvelope, envelope.KeyID)
+	}
+	return nil
+}
+
+// Reader is used to check whether the token is a reader of the key
+func (e *Encryption) Reader(keyID, token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, reader := range e.readers[keyID] {
+		if subtle.ConstantTimeCompare([]byte(reader), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// Decrypt is used to get the entry with the data decrypted with the key presented by a reader of its key. The
+// server added fields, e.g. the meta, win over the decrypted ones, so that the clients can not spoof them.
+// Entries that are not encrypted are returned as they are.
+func (e *Encryption) Decrypt(entry models.LogEntry, token string, key []byte) (models.LogEntry, error) {
+	envelope := entry.Encrypted
+	if envelope == nil {
+		return entry, nil
+	}
+	if !e.Reader(envelope.KeyID, token) {
+		return entry, fmt.Errorf("%w %s", ErrUnauthorized, envelope.KeyID)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil || len(key) != constants.EnvelopeKeySize {
+		return entry, fmt.Errorf("%w: key has to be %d bytes", ErrDecryption, constants.EnvelopeKeySize)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return entry, err
+	}
+	if len(envelope.Nonce) != gcm.NonceSize() {
+		return entry, fmt.Errorf("%w: invalid nonce", ErrDecryption)
+	}
+	// the type is authenticated along with the data, so the ciphertext of an entry can not be moved to another
+	plaintext, err := gcm.Open(nil, envelope.Nonce, envelope.Ciphertext, []byte(entry.Type))
+	if err != nil {
+		return entry, ErrDecryption
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(plaintext, &data); err != nil {
+		return entry, fmt.Errorf("%w: data is not a json object", ErrDecryption)
+	}
+	for key, value := range entry.Data {
+		data[key] = value
+	}
+	entry.Data, entry.Encrypted = data, nil
+	return entry, nil
+}
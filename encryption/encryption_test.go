@@ -0,0 +1,72 @@
+package encryption_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/encryption"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// seal is used to encrypt the data the way the clients do
+func seal(t *testing.T, key []byte, entryType, data string) *models.Envelope {
+	block, err := aes.NewCipher(key)
+	assert.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	assert.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	_, _ = rand.Read(nonce)
+	return &models.Envelope{KeyID: "cards", Algorithm: constants.EnvelopeAlgorithm, Nonce: nonce,
+		Ciphertext: gcm.Seal(nil, nonce, []byte(data), []byte(entryType))}
+}
+
+func TestEncryption(t *testing.T) {
+	enc, err := encryption.New(encryption.Config{Types: []string{"payment"},
+		Keys: []encryption.KeyConfig{{ID: "cards", Readers: []string{"auditor"}}}})
+	assert.NoError(t, err)
+	key := make([]byte, constants.EnvelopeKeySize)
+	_, _ = rand.Read(key)
+
+	plaintext := models.LogEntry{Type: "payment", Data: map[string]interface{}{"card": "4111"}}
+	assert.ErrorIs(t, enc.Check(plaintext), encryption.ErrPlaintext)
+	assert.NoError(t, enc.Check(models.LogEntry{Type: "app", Data: map[string]interface{}{"a": 1}}))
+
+	entry := models.LogEntry{Type: "payment", Level: constants.InfoLevel,
+		Encrypted: seal(t, key, "payment", `{"card":"4111"}`)}
+	assert.NoError(t, enc.Check(entry))
+	mixed := entry
+	mixed.Data = map[string]interface{}{"card": "4111"}
+	assert.ErrorIs(t, enc.Check(mixed), encryption.ErrInvalidEnvelope)
+	unknown := entry
+	unknown.Encrypted = &models.Envelope{KeyID: "other", Algorithm: constants.EnvelopeAlgorithm,
+		Nonce: entry.Encrypted.Nonce, Ciphertext: entry.Encrypted.Ciphertext}
+	assert.ErrorIs(t, enc.Check(unknown), encryption.ErrInvalidEnvelope)
+
+	// the server added fields are kept along with the decrypted data
+	entry.Data = map[string]interface{}{constants.MetaNamespace: map[string]interface{}{"tenant": "acme"}}
+	_, err = enc.Decrypt(entry, "someone", key)
+	assert.ErrorIs(t, err, encryption.ErrUnauthorized)
+	wrong := make([]byte, constants.EnvelopeKeySize)
+	_, err = enc.Decrypt(entry, "auditor", wrong)
+	assert.ErrorIs(t, err, encryption.ErrDecryption)
+	moved := entry
+	moved.Type = "app"
+	_, err = enc.Decrypt(moved, "auditor", key)
+	assert.ErrorIs(t, err, encryption.ErrDecryption)
+	decrypted, err := enc.Decrypt(entry, "auditor", key)
+	assert.NoError(t, err)
+	assert.Nil(t, decrypted.Encrypted)
+	assert.Equal(t, "4111", decrypted.Data["card"])
+	assert.Contains(t, decrypted.Data, constants.MetaNamespace)
+
+	// the meta the client encrypted does not override the one the server added
+	spoofed := entry
+	spoofed.Encrypted = seal(t, key, "payment", `{"card":"4111","`+constants.MetaNamespace+`":{"tenant":"other"}}`)
+	decrypted, err = enc.Decrypt(spoofed, "auditor", key)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"tenant": "acme"}, decrypted.Data[constants.MetaNamespace])
+}
@@ -0,0 +1,343 @@
+// Package escalation samples the entries of the low priority types harder while the ingestion threatens its SLOs
+// or the capacity of the sinks, climbing a configured ladder of steps one interval at a time, and steps back down
+// once the pressure is gone for the cooldown, so that a spike at night is absorbed without someone changing the
+// sampling. The owners of the types of the ladder are notified of every change of step.
+package escalation
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/alerts"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/queue"
+	"github.com/angel-one/nbu-logger-service/sinks"
+)
+
+// Config is the configuration of the sampling escalation, it is disabled without a ladder
+type Config struct {
+	// IntervalInSeconds is the time between evaluations, the ladder is climbed at most a step an interval
+	IntervalInSeconds int `json:"intervalInSeconds" mapstructure:"intervalInSeconds"`
+	// MaxEntriesPerSecond is the ingestion rate the SLOs hold up to, counting the entries before sampling,
+	// the rate is not watched when 0
+	MaxEntriesPerSecond float64 `json:"maxEntriesPerSecond" mapstructure:"maxEntriesPerSecond"`
+	// MaxBufferRatio is the fill of the buffer of a sink from which the sinks are short of capacity
+	MaxBufferRatio float64 `json:"maxBufferRatio" mapstructure:"maxBufferRatio"`
+	// MaxQueueBacklog is the number of entries waiting in the ingestion queue from which the workers are short of
+	// capacity, the backlog is not watched when 0
+	MaxQueueBacklog int `json:"maxQueueBacklog" mapstructure:"maxQueueBacklog"`
+	// ErrorBudget is the fraction of the accepted entries of an interval that can fail to be delivered, e.g.
+	// 0.001 for an SLO of 99.9%, the failures are not watched when 0
+	ErrorBudget float64 `json:"errorBudget" mapstructure:"errorBudget"`
+	// CooldownInSeconds is the time without pressure after which the ladder is stepped down, a step at a time
+	CooldownInSeconds int `json:"cooldownInSeconds" mapstructure:"cooldownInSeconds"`
+	// Ladder are the steps climbed in order, a step adds to the ones below it and takes precedence over them
+	Ladder []Step `json:"ladder" mapstructure:"ladder"`
+}
+
+// Step samples the entries of low priority types
+type Step struct {
+	// Types are the log types sampled
+	Types []string `json:"types" mapstructure:"types"`
+	// Levels are the levels sampled, debug and info when empty so that warnings and errors are kept
+	Levels []string `json:"levels" mapstructure:"levels"`
+	// Rate is the fraction of the entries kept, 0 drops them all
+	Rate float64 `json:"rate" mapstructure:"rate"`
+	// Channel is the channel of the team owning the types, the alerts go to the one of the webhook when empty
+	Channel string `json:"channel" mapstructure:"channel"`
+}
+
+// Status is the step of the ladder and the signals of the latest evaluation
+type Status struct {
+	Enabled bool `json:"enabled"`
+	// Step is the step of the ladder applied, 0 when the sampling is not escalated
+	Step  int `json:"step"`
+	Steps int `json:"steps"`
+	// Since is when the step was reached
+	Since time.Time `json:"since,omitempty"`
+	// Reason is why the step was reached
+	Reason           string    `json:"reason,omitempty"`
+	EntriesPerSecond float64   `json:"entriesPerSecond"`
+	BufferRatio      float64   `json:"bufferRatio"`
+	QueueBacklog     int       `json:"queueBacklog"`
+	ErrorRate        float64   `json:"errorRate"`
+	EvaluatedAt      time.Time `json:"evaluatedAt,omitempty"`
+}
+
+// step is a step of the ladder with the types and levels it samples by name
+type step struct {
+	Step
+	types  map[string]bool
+	levels map[string]bool
+}
+
+// Escalator climbs the ladder while the ingestion is under pressure and samples the entries of the step reached
+type Escalator struct {
+	config Config
+	ladder []step
+	// step is the step applied, read for every entry
+	step int32
+	// offered, accepted and failed are the entries ingested, the ones that went through the pipeline and the ones
+	// that failed to be stored since the latest evaluation, undelivered is the count of the sinks at the latest one
+	offered     uint64
+	accepted    uint64
+	failed      uint64
+	undelivered uint64
+	mu          sync.Mutex
+	status      Status
+	// evaluatedAt and pressuredAt are the times of the latest evaluation and of the latest one under pressure
+	evaluatedAt time.Time
+	pressuredAt time.Time
+}
+
+var e = &Escalator{}
+
+// New is used to create an escalator for the config
+func New(config Config) (*Escalator, error) {
+	if config.IntervalInSeconds <= 0 {
+		config.IntervalInSeconds = constants.DefaultEscalationIntervalInSeconds
+	}
+	if config.MaxBufferRatio == 0 {
+		config.MaxBufferRatio = constants.DefaultEscalationBufferRatio
+	}
+	if config.MaxBufferRatio < 0 || config.MaxBufferRatio > 1 {
+		return nil, fmt.Errorf("max buffer ratio %v has to be between 0 and 1", config.MaxBufferRatio)
+	}
+	if config.MaxQueueBacklog < 0 {
+		return nil, fmt.Errorf("max queue backlog %d can not be negative", config.MaxQueueBacklog)
+	}
+	if config.ErrorBudget < 0 || config.ErrorBudget > 1 {
+		return nil, fmt.Errorf("error budget %v has to be between 0 and 1", config.ErrorBudget)
+	}
+	if config.CooldownInSeconds <= 0 {
+		config.CooldownInSeconds = constants.DefaultEscalationCooldownInSeconds
+	}
+	known := map[string]bool{constants.DebugLevel: true, constants.InfoLevel: true, constants.WarnLevel: true,
+		constants.ErrorLevel: true, constants.FatalLevel: true}
+	ladder := make([]step, 0, len(config.Ladder))
+	for i, s := range config.Ladder {
+		if len(s.Types) == 0 {
+			return nil, fmt.Errorf("step %d of the escalation ladder has no types", i+1)
+		}
+		if s.Rate < 0 || s.Rate > 1 {
+			return nil, fmt.Errorf("step %d of the escalation ladder has a rate %v not between 0 and 1", i+1,
+				s.Rate)
+		}
+		levels := s.Levels
+		if len(levels) == 0 {
+			levels = []string{constants.DebugLevel, constants.InfoLevel}
+		}
+		compiled := step{Step: s, types: make(map[string]bool, len(s.Types)), levels: make(map[string]bool)}
+		for _, logType := range s.Types {
+			compiled.types[logType] = true
+		}
+		for _, level := range levels {
+			if !known[level] {
+				return nil, fmt.Errorf("step %d of the escalation ladder has an unknown level %q", i+1, level)
+			}
+			compiled.levels[level] = true
+		}
+		ladder = append(ladder, compiled)
+	}
+	return &Escalator{
+		config:      config,
+		ladder:      ladder,
+		status:      Status{Enabled: len(ladder) > 0, Steps: len(ladder)},
+		undelivered: sinks.Undelivered(),
+		evaluatedAt: time.Now(),
+	}, nil
+}
+
+// Init is used to initialize the default escalator
+func Init(config Config) error {
+	escalator, err := New(config)
+	if err != nil {
+		return err
+	}
+	e = escalator
+	return nil
+}
+
+// Get is used to get the default escalator
+func Get() *Escalator {
+	return e
+}
+
+// Enabled is used to check whether there is a ladder to climb
+func (e *Escalator) Enabled() bool {
+	return len(e.ladder) > 0
+}
+
+// Status is used to get the step applied and the signals of the latest evaluation
+func (e *Escalator) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
+
+// Offered is used to count an entry ingested, before it is validated or sampled
+func (e *Escalator) Offered() {
+	atomic.AddUint64(&e.offered, 1)
+}
+
+// Failed is used to count an accepted entry that failed to be stored, the ones the sinks fail to deliver are
+// counted by the sinks
+func (e *Escalator) Failed() {
+	atomic.AddUint64(&e.failed, 1)
+}
+
+// Keep is used to check whether the entry that went through the pipeline is kept by the step applied, the highest
+// step matching its type and level decides
+func (e *Escalator) Keep(_ context.Context, entry models.LogEntry) bool {
+	atomic.AddUint64(&e.accepted, 1)
+	current := int(atomic.LoadInt32(&e.step))
+	for i := current - 1; i >= 0; i-- {
+		s := e.ladder[i]
+		if s.types[entry.Type] && s.levels[entry.Level] {
+			return s.Rate > 0 && rand.Float64() < s.Rate
+		}
+	}
+	return true
+}
+
+// Start is used to evaluate the pressure in the background on every interval until the context is done
+func (e *Escalator) Start(ctx context.Context) {
+	if !e.Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(e.config.IntervalInSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				e.Evaluate(ctx, now)
+			}
+		}
+	}()
+}
+
+// Evaluate is used to climb a step of the ladder when the ingestion is under pressure since the previous
+// evaluation, or to step down when it has not been for the cooldown
+func (e *Escalator) Evaluate(ctx context.Context, now time.Time) {
+	if !e.Enabled() {
+		return
+	}
+	offered := atomic.SwapUint64(&e.offered, 0)
+	accepted := atomic.SwapUint64(&e.accepted, 0)
+	failed := atomic.SwapUint64(&e.failed, 0)
+	// the backlog is read from redis, so it is read before locking out the status
+	backlog := 0
+	if e.config.MaxQueueBacklog > 0 && queue.Enabled() {
+		var err error
+		if backlog, err = queue.Backlog(); err != nil {
+			log.Warn(ctx).Err(err).Msg("error reading the ingestion queue backlog")
+		}
+	}
+
+	e.mu.Lock()
+	undelivered := sinks.Undelivered()
+	failed += undelivered - e.undelivered
+	e.undelivered = undelivered
+	elapsed := now.Sub(e.evaluatedAt).Seconds()
+	e.evaluatedAt = now
+	e.status.EvaluatedAt = now
+	e.status.EntriesPerSecond, e.status.ErrorRate = 0, 0
+	if elapsed > 0 {
+		e.status.EntriesPerSecond = float64(offered) / elapsed
+	}
+	if accepted > 0 {
+		e.status.ErrorRate = float64(failed) / float64(accepted)
+	}
+	e.status.BufferRatio = bufferRatio()
+	e.status.QueueBacklog = backlog
+	var reasons []string
+	if e.config.MaxEntriesPerSecond > 0 && e.status.EntriesPerSecond > e.config.MaxEntriesPerSecond {
+		reasons = append(reasons, fmt.Sprintf("%.0f entries/s over %.0f", e.status.EntriesPerSecond,
+			e.config.MaxEntriesPerSecond))
+	}
+	if e.status.BufferRatio >= e.config.MaxBufferRatio {
+		reasons = append(reasons, fmt.Sprintf("sink buffers %.0f%% full", e.status.BufferRatio*100))
+	}
+	if e.config.MaxQueueBacklog > 0 && backlog > e.config.MaxQueueBacklog {
+		reasons = append(reasons, fmt.Sprintf("%d entries queued over %d", backlog, e.config.MaxQueueBacklog))
+	}
+	if e.config.ErrorBudget > 0 && e.status.ErrorRate > e.config.ErrorBudget {
+		reasons = append(reasons, fmt.Sprintf("%.2f%% of the entries not delivered, over the budget of %.2f%%",
+			e.status.ErrorRate*100, e.config.ErrorBudget*100))
+	}
+	current, next := e.status.Step, e.status.Step
+	cooldown := time.Duration(e.config.CooldownInSeconds) * time.Second
+	switch {
+	case len(reasons) > 0:
+		e.pressuredAt = now
+		if current < len(e.ladder) {
+			next = current + 1
+		}
+	case current > 0 && now.Sub(e.pressuredAt) >= cooldown && now.Sub(e.status.Since) >= cooldown:
+		next = current - 1
+		reasons = []string{fmt.Sprintf("no pressure for %s", cooldown)}
+	}
+	if next == current {
+		e.mu.Unlock()
+		return
+	}
+	e.status.Step, e.status.Since, e.status.Reason = next, now, strings.Join(reasons, ", ")
+	atomic.StoreInt32(&e.step, int32(next))
+	status := e.status
+	e.mu.Unlock()
+
+	log.Warn(ctx).Int(constants.StepKey, next).Str(constants.ReasonKey, status.Reason).
+		Msg("sampling escalation changed")
+	e.notify(ctx, current, status)
+}
+
+// notify is used to tell the owners of the types of the ladder about the change of step, in the channels of
+// their steps
+func (e *Escalator) notify(ctx context.Context, previous int, status Status) {
+	verb := "escalated"
+	if status.Step < previous {
+		verb = "de-escalated"
+	}
+	byChannel := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, s := range e.ladder {
+		for _, logType := range s.Types {
+			if !seen[s.Channel+"/"+logType] {
+				seen[s.Channel+"/"+logType] = true
+				byChannel[s.Channel] = append(byChannel[s.Channel], logType)
+			}
+		}
+	}
+	for channel, owned := range byChannel {
+		sort.Strings(owned)
+		text := fmt.Sprintf("sampling of %s %s to step %d of %d : %s", strings.Join(owned, ", "), verb,
+			status.Step, status.Steps, status.Reason)
+		// the key tells the changes apart, so that stepping down is not throttled by the alert of stepping up
+		key := fmt.Sprintf("%s%s/%d/%s", constants.EscalationAlertKeyPrefix, verb, status.Step, channel)
+		alerts.SendTo(ctx, key, text, channel)
+	}
+}
+
+// bufferRatio is used to get the fill of the fullest buffer of the sinks
+func bufferRatio() float64 {
+	var ratio float64
+	for _, buffer := range sinks.Buffers() {
+		if buffer.Capacity > 0 {
+			if r := float64(buffer.Queued) / float64(buffer.Capacity); r > ratio {
+				ratio = r
+			}
+		}
+	}
+	return ratio
+}
@@ -0,0 +1,132 @@
+package escalation_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/alerts"
+	"github.com/angel-one/nbu-logger-service/escalation"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEscalation(t *testing.T) {
+	var mu sync.Mutex
+	var alerted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		defer mu.Unlock()
+		alerted = append(alerted, body["channel"]+" "+body["text"])
+	}))
+	defer server.Close()
+	alerts.Init(alerts.Config{URL: server.URL})
+	defer alerts.Init(alerts.Config{})
+	ctx := context.Background()
+
+	escalator, err := escalation.New(escalation.Config{
+		MaxEntriesPerSecond: 10,
+		ErrorBudget:         0.01,
+		CooldownInSeconds:   60,
+		Ladder: []escalation.Step{
+			{Types: []string{"clickstream"}, Rate: 0, Channel: "#growth"},
+			{Types: []string{"heartbeat"}, Levels: []string{"debug", "info", "warn"}, Rate: 0},
+		},
+	})
+	assert.NoError(t, err)
+	kept := func(logType, level string) bool {
+		return escalator.Keep(ctx, models.LogEntry{Type: logType, Level: level})
+	}
+	offer := func(entries, failed int) {
+		for i := 0; i < entries; i++ {
+			escalator.Offered()
+		}
+		for i := 0; i < failed; i++ {
+			escalator.Failed()
+		}
+	}
+	start := time.Now()
+	at := func(seconds int) time.Time {
+		return start.Add(time.Duration(seconds) * time.Second)
+	}
+	assert.True(t, kept("clickstream", "info"))
+
+	// more entries than the SLOs allow climb the first step
+	offer(100, 0)
+	escalator.Evaluate(ctx, at(1))
+	status := escalator.Status()
+	assert.Equal(t, 1, status.Step)
+	assert.Equal(t, 2, status.Steps)
+	assert.Contains(t, status.Reason, "entries/s")
+	assert.False(t, kept("clickstream", "info"))
+	assert.True(t, kept("clickstream", "warn"))
+	assert.True(t, kept("heartbeat", "info"))
+	assert.True(t, kept("order", "debug"))
+
+	// the entries failing to be stored beyond the error budget climb the next one, the top step is kept
+	for i := 0; i < 10; i++ {
+		kept("order", "debug")
+	}
+	offer(10, 1)
+	escalator.Evaluate(ctx, at(2))
+	status = escalator.Status()
+	assert.Equal(t, 2, status.Step)
+	assert.Contains(t, status.Reason, "budget")
+	assert.False(t, kept("heartbeat", "warn"))
+	assert.True(t, kept("heartbeat", "error"))
+	assert.True(t, kept("order", "debug"))
+	offer(100, 0)
+	escalator.Evaluate(ctx, at(3))
+	assert.Equal(t, 2, escalator.Status().Step)
+
+	// the ladder is stepped down a step every cooldown without pressure
+	escalator.Evaluate(ctx, at(30))
+	assert.Equal(t, 2, escalator.Status().Step)
+	escalator.Evaluate(ctx, at(63))
+	assert.Equal(t, 1, escalator.Status().Step)
+	assert.True(t, kept("heartbeat", "info"))
+	escalator.Evaluate(ctx, at(100))
+	assert.Equal(t, 1, escalator.Status().Step)
+	escalator.Evaluate(ctx, at(123))
+	status = escalator.Status()
+	assert.Equal(t, 0, status.Step)
+	assert.Equal(t, at(123), status.Since)
+	assert.True(t, kept("clickstream", "info"))
+
+	// the owners are told of every change, in the channel of their step when it has one
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(alerted) == 8
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Contains(t, alerted, "#growth sampling of clickstream escalated to step 1 of 2 : 100 entries/s over 10")
+	assert.Contains(t, alerted, " sampling of heartbeat escalated to step 1 of 2 : 100 entries/s over 10")
+	assert.Contains(t, alerted, "#growth sampling of clickstream de-escalated to step 1 of 2 : no pressure for 1m0s")
+	assert.Contains(t, alerted, " sampling of heartbeat de-escalated to step 0 of 2 : no pressure for 1m0s")
+}
+
+func TestEscalationConfig(t *testing.T) {
+	escalator, err := escalation.New(escalation.Config{})
+	assert.NoError(t, err)
+	assert.False(t, escalator.Status().Enabled)
+	escalator.Evaluate(context.Background(), time.Now())
+	assert.True(t, escalator.Keep(context.Background(), models.LogEntry{Type: "order"}))
+
+	for _, config := range []escalation.Config{
+		{Ladder: []escalation.Step{{Rate: 0.5}}},
+		{Ladder: []escalation.Step{{Types: []string{"clickstream"}, Rate: 2}}},
+		{Ladder: []escalation.Step{{Types: []string{"clickstream"}, Levels: []string{"trace"}}}},
+		{MaxBufferRatio: 1.5},
+		{MaxQueueBacklog: -1},
+		{ErrorBudget: -1},
+	} {
+		_, err = escalation.New(config)
+		assert.Error(t, err)
+	}
+}
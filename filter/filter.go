@@ -0,0 +1,241 @@
+// Package filter implements the filter expressions entries are selected with, the same language for queries,
+// alert rules and sink routes. An expression compares fields with values and combines the comparisons:
+//
+//	type = payment and (level = error or data.amount >= 10000) and not labels.env = uat
+//	message ~ "timeout|refused" and exists data.requestId
+//
+// Fields are type, level, labels.<key> and the data fields, with or without the data. prefix and dotted for
+// nested ones. Operators are =, !=, <, <=, >, >=, ~ and !~ for regular expressions, and exists. Values are
+// numbers, true, false, null, quoted strings or bare words.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// Filter is a parsed filter expression
+type Filter struct {
+	expression string
+	root       node
+}
+
+// Parse is used to parse the expression, an empty expression matches every entry
+func Parse(expression string) (*Filter, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter : %w", err)
+	}
+	if len(tokens) == 0 {
+		return &Filter{expression: expression}, nil
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.or()
+	if err == nil && p.position < len(p.tokens) {
+		err = fmt.Errorf("unexpected %s", p.tokens[p.position].text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter : %w", err)
+	}
+	return &Filter{expression: expression, root: root}, nil
+}
+
+// Match is used to check whether the entry matches, a nil filter matches every entry
+func (f *Filter) Match(entry models.LogEntry) bool {
+	if f == nil || f.root == nil {
+		return true
+	}
+	return f.root.match(entry)
+}
+
+// String is used to get the expression of the filter
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expression
+}
+
+type node interface {
+	match(entry models.LogEntry) bool
+}
+
+type and []node
+
+func (n and) match(entry models.LogEntry) bool {
+	for _, operand := range n {
+		if !operand.match(entry) {
+			return false
+		}
+	}
+	return true
+}
+
+type or []node
+
+func (n or) match(entry models.LogEntry) bool {
+	for _, operand := range n {
+		if operand.match(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+type not struct {
+	operand node
+}
+
+func (n not) match(entry models.LogEntry) bool {
+	return !n.operand.match(entry)
+}
+
+type exists struct {
+	field []string
+}
+
+func (n exists) match(entry models.LogEntry) bool {
+	_, ok := lookup(entry, n.field)
+	return ok
+}
+
+type comparison struct {
+	field    []string
+	operator string
+	value    interface{}
+	regex    *regexp.Regexp
+}
+
+func (n comparison) match(entry models.LogEntry) bool {
+	actual, ok := lookup(entry, n.field)
+	switch n.operator {
+	case "~":
+		return ok && n.regex.MatchString(text(actual))
+	case "!~":
+		return !ok || !n.regex.MatchString(text(actual))
+	case "=":
+		return ok && equal(actual, n.value) || !ok && n.value == nil
+	case "!=":
+		return !(ok && equal(actual, n.value) || !ok && n.value == nil)
+	}
+	if !ok {
+		return false
+	}
+	var order int
+	if x, isNumber := number(actual); isNumber {
+		y, isNumber := number(n.value)
+		if !isNumber {
+			return false
+		}
+		order = compare(x, y)
+	} else {
+		order = strings.Compare(text(actual), text(n.value))
+	}
+	switch n.operator {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+// Lookup is used to get the value of the field of the entry, the field is written as in the expressions
+func Lookup(entry models.LogEntry, field string) (interface{}, bool) {
+	return lookup(entry, strings.Split(field, "."))
+}
+
+// lookup is used to get the value of the field of the entry
+func lookup(entry models.LogEntry, field []string) (interface{}, bool) {
+	switch field[0] {
+	case constants.TypeField:
+		if len(field) == 1 {
+			return entry.Type, true
+		}
+	case constants.LevelField:
+		if len(field) == 1 && entry.Level != "" {
+			return entry.Level, true
+		}
+	case constants.TraceIDField:
+		if len(field) == 1 && entry.TraceID != "" {
+			return entry.TraceID, true
+		}
+	case constants.SpanIDField:
+		if len(field) == 1 && entry.SpanID != "" {
+			return entry.SpanID, true
+		}
+	case "labels":
+		if len(field) == 2 {
+			value, ok := entry.Labels[field[1]]
+			return value, ok
+		}
+	case "data":
+		if len(field) > 1 {
+			field = field[1:]
+		}
+	}
+	var value interface{} = entry.Data
+	for _, key := range field {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func equal(actual, expected interface{}) bool {
+	if x, ok := number(actual); ok {
+		y, ok := number(expected)
+		return ok && x == y
+	}
+	if actual == nil || expected == nil {
+		return actual == expected
+	}
+	return text(actual) == text(expected)
+}
+
+func number(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case fmt.Stringer:
+		f, err := strconv.ParseFloat(v.String(), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func compare(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func text(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,53 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	entry := models.LogEntry{Type: "payment", Level: "error", Labels: map[string]string{"env": "prod"},
+		Data: map[string]interface{}{"amount": 12000.0, "message": "gateway timeout",
+			"user": map[string]interface{}{"id": "u1"}}}
+	cases := map[string]bool{
+		"":                                   true,
+		"type = payment":                     true,
+		`type == "payment" && level != warn`: true,
+		"labels.env = uat":                   false,
+		"not labels.env = uat":               true,
+		"amount >= 10000 and data.amount < 20000": true,
+		"amount > 12000":                                        false,
+		`message ~ "time(out|d)"`:                               true,
+		"message !~ refused":                                    true,
+		"exists user.id and not exists data.user.name":          true,
+		"type = order or (level = error and labels.env = prod)": true,
+		"missing = null":                                        true,
+		"! (type = payment)":                                    false,
+	}
+	for expression, matches := range cases {
+		f, err := filter.Parse(expression)
+		if assert.NoError(t, err, expression) {
+			assert.Equal(t, matches, f.Match(entry), expression)
+		}
+	}
+	for _, expression := range []string{"type =", "type payment", "(type = a", "a ~ \"(\"", "type = a or", `a = "b`} {
+		_, err := filter.Parse(expression)
+		assert.Error(t, err, expression)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	entry := models.LogEntry{Type: "payment", Level: "error",
+		Data: map[string]interface{}{"message": "gateway timeout after timeout", "amount": 12000.0}}
+	f, err := filter.Parse(`message ~ "time(out)?" and (amount > 10000 or level = warn) and not type = order`)
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{
+			"message": "gateway <em>timeout</em> after <em>timeout</em>",
+			"amount":  "<em>12000</em>",
+		}, f.Highlight(entry))
+	}
+	assert.Nil(t, (*filter.Filter)(nil).Highlight(entry))
+}
@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// Highlight is used to get the values of the fields the filter matched the entry on, with the matched parts
+// between the highlight tags. Regular expressions highlight what they matched and the other comparisons the
+// whole value, the comparisons under a not match nothing to highlight.
+func (f *Filter) Highlight(entry models.LogEntry) map[string]string {
+	if f == nil || f.root == nil {
+		return nil
+	}
+	spans := make(map[string][][2]int)
+	values := make(map[string]string)
+	highlight(f.root, entry, spans, values)
+	if len(spans) == 0 {
+		return nil
+	}
+	highlights := make(map[string]string, len(spans))
+	for field, fieldSpans := range spans {
+		highlights[field] = tag(values[field], fieldSpans)
+	}
+	return highlights
+}
+
+func highlight(n node, entry models.LogEntry, spans map[string][][2]int, values map[string]string) {
+	switch n := n.(type) {
+	case and:
+		for _, operand := range n {
+			highlight(operand, entry, spans, values)
+		}
+	case or:
+		for _, operand := range n {
+			if operand.match(entry) {
+				highlight(operand, entry, spans, values)
+			}
+		}
+	case comparison:
+		if n.operator == "!~" || n.operator == "!=" || !n.match(entry) {
+			return
+		}
+		actual, ok := lookup(entry, n.field)
+		if !ok || actual == nil {
+			return
+		}
+		field, value := strings.Join(n.field, "."), text(actual)
+		values[field] = value
+		if n.regex == nil {
+			spans[field] = append(spans[field], [2]int{0, len(value)})
+			return
+		}
+		for _, match := range n.regex.FindAllStringIndex(value, -1) {
+			if match[0] < match[1] {
+				spans[field] = append(spans[field], [2]int{match[0], match[1]})
+			}
+		}
+	}
+}
+
+// tag is used to put the highlight tags around the spans of the value, overlapping spans are merged
+func tag(value string, spans [][2]int) string {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	var b strings.Builder
+	position := 0
+	for i := 0; i < len(spans); i++ {
+		start, end := spans[i][0], spans[i][1]
+		for i+1 < len(spans) && spans[i+1][0] <= end {
+			i++
+			if spans[i][1] > end {
+				end = spans[i][1]
+			}
+		}
+		if start < position {
+			start = position
+		}
+		b.WriteString(value[position:start])
+		b.WriteString(constants.HighlightPreTag)
+		b.WriteString(value[start:end])
+		b.WriteString(constants.HighlightPostTag)
+		position = end
+	}
+	b.WriteString(value[position:])
+	return b.String()
+}
@@ -0,0 +1,241 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type token struct {
+	text string
+	// quoted is set for quoted strings, they are values and never operators or keywords
+	quoted bool
+}
+
+// operators are the comparison operators, longest first so that >= is not read as >
+var operators = []string{"==", "!=", ">=", "<=", "!~", "=", ">", "<", "~"}
+
+func tokenize(expression string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expression); {
+		c := rune(expression[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{text: string(c)})
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(expression) && expression[end] != byte(c) {
+				if expression[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(expression) {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			value := expression[i+1 : end]
+			if c == '"' {
+				unquoted, err := strconv.Unquote(expression[i : end+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid string at %d : %w", i, err)
+				}
+				value = unquoted
+			}
+			tokens = append(tokens, token{text: value, quoted: true})
+			i = end + 1
+		case strings.HasPrefix(expression[i:], "&&") || strings.HasPrefix(expression[i:], "||"):
+			tokens = append(tokens, token{text: expression[i : i+2]})
+			i += 2
+		default:
+			operator := ""
+			for _, o := range operators {
+				if strings.HasPrefix(expression[i:], o) {
+					operator = o
+					break
+				}
+			}
+			if operator != "" {
+				tokens = append(tokens, token{text: operator})
+				i += len(operator)
+				continue
+			}
+			if c == '!' {
+				tokens = append(tokens, token{text: "!"})
+				i++
+				continue
+			}
+			end := i
+			for end < len(expression) && !strings.ContainsRune(" \t\r\n()=!<>~\"'&|", rune(expression[end])) {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("unexpected %q at %d", expression[i], i)
+			}
+			tokens = append(tokens, token{text: expression[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// parser is a recursive descent parser, not binds tighter than and, which binds tighter than or
+type parser struct {
+	tokens   []token
+	position int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.position >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.position], true
+}
+
+// keyword is used to consume the next token when it is one of the keywords
+func (p *parser) keyword(keywords ...string) bool {
+	next, ok := p.peek()
+	if !ok || next.quoted {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.EqualFold(next.text, keyword) {
+			p.position++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) or() (node, error) {
+	operands := or{}
+	for {
+		operand, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		if !p.keyword("or", "||") {
+			break
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return operands, nil
+}
+
+func (p *parser) and() (node, error) {
+	operands := and{}
+	for {
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, operand)
+		if !p.keyword("and", "&&") {
+			break
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return operands, nil
+}
+
+func (p *parser) not() (node, error) {
+	if p.keyword("not", "!") {
+		operand, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return not{operand: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	if p.keyword("(") {
+		expression, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.keyword(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		return expression, nil
+	}
+	if p.keyword("exists") {
+		field, err := p.field()
+		if err != nil {
+			return nil, err
+		}
+		return exists{field: field}, nil
+	}
+	field, err := p.field()
+	if err != nil {
+		return nil, err
+	}
+	next, ok := p.peek()
+	if !ok || next.quoted || !isOperator(next.text) {
+		return nil, fmt.Errorf("missing operator after %s", strings.Join(field, "."))
+	}
+	p.position++
+	operator := next.text
+	if operator == "==" {
+		operator = "="
+	}
+	value, ok := p.peek()
+	if !ok || (!value.quoted && (value.text == "(" || value.text == ")" || isOperator(value.text))) {
+		return nil, fmt.Errorf("missing value after %s %s", strings.Join(field, "."), operator)
+	}
+	p.position++
+	n := comparison{field: field, operator: operator, value: literal(value)}
+	if operator == "~" || operator == "!~" {
+		if n.regex, err = regexp.Compile(value.text); err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q : %w", value.text, err)
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) field() ([]string, error) {
+	next, ok := p.peek()
+	if !ok || next.quoted || next.text == "(" || next.text == ")" || isOperator(next.text) {
+		return nil, fmt.Errorf("missing field")
+	}
+	p.position++
+	return strings.Split(next.text, "."), nil
+}
+
+func isOperator(text string) bool {
+	for _, operator := range operators {
+		if text == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// literal is used to get the value of the token, bare numbers, booleans and null are typed
+func literal(t token) interface{} {
+	if t.quoted {
+		return t.text
+	}
+	switch t.text {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return f
+	}
+	return t.text
+}
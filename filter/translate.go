@@ -0,0 +1,490 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+// Translate is used to translate a query of another syntax, logql or lucene, into a filter expression. The
+// native syntax, empty or filter, is returned as is.
+func Translate(syntax, query string) (string, error) {
+	var (
+		expression string
+		err        error
+	)
+	switch strings.ToLower(syntax) {
+	case "", constants.FilterSyntax:
+		return query, nil
+	case constants.LogQLSyntax:
+		expression, err = translateLogQL(query)
+	case constants.LuceneSyntax:
+		expression, err = translateLucene(query)
+	default:
+		return "", fmt.Errorf("unknown query syntax %s, available are %s, %s and %s", syntax,
+			constants.FilterSyntax, constants.LogQLSyntax, constants.LuceneSyntax)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid %s query : %w", strings.ToLower(syntax), err)
+	}
+	return expression, nil
+}
+
+// reader is used to read the queries of the other syntaxes character by character
+type reader struct {
+	query    string
+	position int
+}
+
+func (r *reader) skipSpace() {
+	for r.position < len(r.query) && unicode.IsSpace(rune(r.query[r.position])) {
+		r.position++
+	}
+}
+
+func (r *reader) done() bool {
+	r.skipSpace()
+	return r.position >= len(r.query)
+}
+
+// consume is used to read the prefix when the query continues with it
+func (r *reader) consume(prefix string) bool {
+	r.skipSpace()
+	if strings.HasPrefix(r.query[r.position:], prefix) {
+		r.position += len(prefix)
+		return true
+	}
+	return false
+}
+
+// keyword is used to read the word when the query continues with it as a whole word
+func (r *reader) keyword(word string) bool {
+	r.skipSpace()
+	end := r.position + len(word)
+	if end > len(r.query) || r.query[r.position:end] != word {
+		return false
+	}
+	if end < len(r.query) && !unicode.IsSpace(rune(r.query[end])) && r.query[end] != '(' {
+		return false
+	}
+	r.position = end
+	return true
+}
+
+// quoted is used to read a double quoted string or, when raw is set, a back quoted one
+func (r *reader) quoted(raw bool) (string, bool, error) {
+	r.skipSpace()
+	if r.position >= len(r.query) {
+		return "", false, nil
+	}
+	quote := r.query[r.position]
+	if quote != '"' && !(raw && quote == '`') {
+		return "", false, nil
+	}
+	end := r.position + 1
+	for end < len(r.query) && r.query[end] != quote {
+		if quote == '"' && r.query[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(r.query) {
+		return "", false, fmt.Errorf("unterminated string at %d", r.position)
+	}
+	value := r.query[r.position+1 : end]
+	if quote == '"' {
+		unquoted, err := strconv.Unquote(r.query[r.position : end+1])
+		if err != nil {
+			return "", false, fmt.Errorf("invalid string at %d : %w", r.position, err)
+		}
+		value = unquoted
+	}
+	r.position = end + 1
+	return value, true, nil
+}
+
+// word is used to read the characters up to a space or one of the stops, backslash escapes a stop
+func (r *reader) word(stops string) string {
+	r.skipSpace()
+	var w strings.Builder
+	for r.position < len(r.query) {
+		c := r.query[r.position]
+		if c == '\\' && r.position+1 < len(r.query) {
+			w.WriteByte(r.query[r.position+1])
+			r.position += 2
+			continue
+		}
+		if unicode.IsSpace(rune(c)) || strings.IndexByte(stops, c) >= 0 {
+			break
+		}
+		w.WriteByte(c)
+		r.position++
+	}
+	return w.String()
+}
+
+// value is used to write the value in the filter language, bare words that are numbers or booleans stay typed
+func value(text string, quoted bool) string {
+	if !quoted {
+		if _, err := strconv.ParseFloat(text, 64); err == nil || text == "true" || text == "false" {
+			return text
+		}
+	}
+	return strconv.Quote(text)
+}
+
+// join is used to combine the expressions with the operator
+func join(operator string, expressions []string) string {
+	if len(expressions) == 1 {
+		return expressions[0]
+	}
+	return "(" + strings.Join(expressions, " "+operator+" ") + ")"
+}
+
+// translateLogQL is used to translate a logql query. The stream selector matches the type, the level and the
+// labels of the entries, the line filters the message, and the label filters after the json or logfmt parsers
+// the data fields, e.g. {type="payment", env="prod"} |= "timeout" | json | amount > 1000.
+func translateLogQL(query string) (string, error) {
+	r := &reader{query: query}
+	var expressions []string
+	if !r.consume("{") {
+		return "", fmt.Errorf("missing stream selector")
+	}
+	for !r.consume("}") {
+		if len(expressions) > 0 && !r.consume(",") {
+			return "", fmt.Errorf("missing , at %d", r.position)
+		}
+		name := r.word("=!~,{}\"`")
+		if name == "" {
+			return "", fmt.Errorf("missing label at %d", r.position)
+		}
+		expression, err := logQLMatcher(r, logQLLabel(name))
+		if err != nil {
+			return "", err
+		}
+		expressions = append(expressions, expression)
+	}
+	for !r.done() {
+		var (
+			operator string
+			regex    bool
+		)
+		switch {
+		case r.consume("|="):
+			operator = "~"
+		case r.consume("!="):
+			operator = "!~"
+		case r.consume("|~"):
+			operator, regex = "~", true
+		case r.consume("!~"):
+			operator, regex = "!~", true
+		case r.consume("|"):
+			expression, err := logQLStage(r)
+			if err != nil {
+				return "", err
+			}
+			if expression != "" {
+				expressions = append(expressions, expression)
+			}
+			continue
+		default:
+			return "", fmt.Errorf("unexpected %q at %d", r.query[r.position], r.position)
+		}
+		line, ok, err := r.quoted(true)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			return "", fmt.Errorf("missing string after line filter at %d", r.position)
+		}
+		if !regex {
+			line = regexp.QuoteMeta(line)
+		}
+		expressions = append(expressions, constants.MessageField+" "+operator+" "+strconv.Quote(line))
+	}
+	if len(expressions) == 0 {
+		return "", nil
+	}
+	return join("and", expressions), nil
+}
+
+// logQLLabel is used to get the field of a stream label, every label but the type and the level is a label
+func logQLLabel(name string) string {
+	if name == constants.TypeField || name == constants.LevelField {
+		return name
+	}
+	return "labels." + name
+}
+
+// logQLMatcher is used to read the operator and the value of a matcher of the field, regular expressions of
+// logql match the whole value
+func logQLMatcher(r *reader, field string) (string, error) {
+	var operator string
+	for _, o := range []string{"=~", "!~", "!=", "==", ">=", "<=", "=", ">", "<"} {
+		if r.consume(o) {
+			operator = o
+			break
+		}
+	}
+	if operator == "" {
+		return "", fmt.Errorf("missing operator after %s", field)
+	}
+	text, quoted, err := r.quoted(true)
+	if err != nil {
+		return "", err
+	}
+	if !quoted {
+		if text = r.word(",|(){}\"`"); text == "" {
+			return "", fmt.Errorf("missing value after %s %s", field, operator)
+		}
+	}
+	switch operator {
+	case "=~":
+		return field + " ~ " + strconv.Quote("^(?:"+text+")$"), nil
+	case "!~":
+		return field + " !~ " + strconv.Quote("^(?:"+text+")$"), nil
+	}
+	return field + " " + operator + " " + value(text, quoted), nil
+}
+
+// logQLStage is used to translate a stage of the pipeline. The entries are structured already, so the parsers
+// are skipped, the stages formatting the lines or the labels have no counterpart in a filter.
+func logQLStage(r *reader) (string, error) {
+	switch {
+	case r.keyword("json"), r.keyword("logfmt"), r.keyword("unpack"):
+		return "", nil
+	}
+	for _, stage := range []string{"line_format", "label_format", "pattern", "regexp", "drop", "keep", "unwrap",
+		"decolorize"} {
+		if r.keyword(stage) {
+			return "", fmt.Errorf("%s stage is not supported", stage)
+		}
+	}
+	return logQLLabelFilter(r)
+}
+
+// logQLLabelFilter is used to translate a label filter, comparisons combined with and, or and commas
+func logQLLabelFilter(r *reader) (string, error) {
+	var alternatives, comparisons []string
+	for {
+		var expression string
+		if r.consume("(") {
+			nested, err := logQLLabelFilter(r)
+			if err != nil {
+				return "", err
+			}
+			if !r.consume(")") {
+				return "", fmt.Errorf("missing ) at %d", r.position)
+			}
+			expression = nested
+		} else {
+			name := r.word("=!~<>,|()\"`")
+			if name == "" {
+				return "", fmt.Errorf("missing label at %d", r.position)
+			}
+			var err error
+			if expression, err = logQLMatcher(r, name); err != nil {
+				return "", err
+			}
+		}
+		comparisons = append(comparisons, expression)
+		switch {
+		case r.consume(","), r.keyword("and"):
+		case r.keyword("or"):
+			alternatives = append(alternatives, join("and", comparisons))
+			comparisons = nil
+		default:
+			return join("or", append(alternatives, join("and", comparisons))), nil
+		}
+	}
+}
+
+// translateLucene is used to translate a lucene query as typed in kibana, e.g.
+// type:payment AND amount:[1000 TO *] AND NOT labels.env:uat. Terms without a field match the message, and
+// terms next to each other without an operator are alternatives like in lucene.
+func translateLucene(query string) (string, error) {
+	r := &reader{query: query}
+	if r.done() {
+		return "", nil
+	}
+	expression, err := luceneOr(r, "")
+	if err != nil {
+		return "", err
+	}
+	if !r.done() {
+		return "", fmt.Errorf("unexpected %q at %d", r.query[r.position], r.position)
+	}
+	return expression, nil
+}
+
+func luceneOr(r *reader, field string) (string, error) {
+	var alternatives []string
+	for {
+		expression, err := luceneAnd(r, field)
+		if err != nil {
+			return "", err
+		}
+		alternatives = append(alternatives, expression)
+		if r.keyword("OR") || r.consume("||") {
+			continue
+		}
+		if r.done() || strings.HasPrefix(r.query[r.position:], ")") {
+			return join("or", alternatives), nil
+		}
+	}
+}
+
+func luceneAnd(r *reader, field string) (string, error) {
+	var operands []string
+	for {
+		expression, err := luceneUnary(r, field)
+		if err != nil {
+			return "", err
+		}
+		operands = append(operands, expression)
+		if !r.keyword("AND") && !r.consume("&&") {
+			return join("and", operands), nil
+		}
+	}
+}
+
+func luceneUnary(r *reader, field string) (string, error) {
+	if r.keyword("NOT") || r.consume("!") || r.consume("-") {
+		expression, err := luceneUnary(r, field)
+		if err != nil {
+			return "", err
+		}
+		return "not " + expression, nil
+	}
+	r.consume("+")
+	return lucenePrimary(r, field)
+}
+
+// lucenePrimary is used to translate a group or a term, the field of a group applies to the terms in it
+func lucenePrimary(r *reader, field string) (string, error) {
+	if r.consume("(") {
+		expression, err := luceneOr(r, field)
+		if err != nil {
+			return "", err
+		}
+		if !r.consume(")") {
+			return "", fmt.Errorf("missing ) at %d", r.position)
+		}
+		return expression, nil
+	}
+	if field == "" {
+		start := r.position
+		name := r.word(`:()[]{}"/`)
+		if name != "" && r.consume(":") {
+			if name == "_exists_" {
+				if exists := r.word(`()[]{}"/:`); exists != "" {
+					return "exists " + exists, nil
+				}
+				return "", fmt.Errorf("missing field after _exists_")
+			}
+			return lucenePrimary(r, name)
+		}
+		r.position = start
+	}
+	return luceneTerm(r, field)
+}
+
+// luceneTerm is used to translate the value of the field, ranges, comparisons, regular expressions, wildcards,
+// phrases and words. Without a field the message has to contain the term.
+func luceneTerm(r *reader, field string) (string, error) {
+	r.skipSpace()
+	if r.position >= len(r.query) {
+		return "", fmt.Errorf("missing term")
+	}
+	if r.query[r.position] == '[' || r.query[r.position] == '{' {
+		return luceneRange(r, field)
+	}
+	for _, operator := range []string{">=", "<=", ">", "<"} {
+		if field != "" && r.consume(operator) {
+			bound := r.word(`()[]{}"`)
+			if bound == "" {
+				return "", fmt.Errorf("missing value after %s:%s", field, operator)
+			}
+			return field + " " + operator + " " + value(bound, false), nil
+		}
+	}
+	if r.consume("/") {
+		end := strings.IndexByte(r.query[r.position:], '/')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated regular expression at %d", r.position)
+		}
+		pattern := r.query[r.position : r.position+end]
+		r.position += end + 1
+		if field == "" {
+			return constants.MessageField + " ~ " + strconv.Quote(pattern), nil
+		}
+		return field + " ~ " + strconv.Quote("^(?:"+pattern+")$"), nil
+	}
+	text, quoted, err := r.quoted(false)
+	if err != nil {
+		return "", err
+	}
+	if !quoted {
+		if text = r.word(`()[]{}":`); text == "" {
+			return "", fmt.Errorf("missing term at %d", r.position)
+		}
+		if strings.ContainsAny(text, "^~") {
+			return "", fmt.Errorf("boosts and fuzzy terms are not supported, %s", text)
+		}
+	}
+	if field == "" {
+		return constants.MessageField + " ~ " + strconv.Quote("(?i)"+regexp.QuoteMeta(text)), nil
+	}
+	if !quoted && text == "*" {
+		return "exists " + field, nil
+	}
+	if !quoted && strings.ContainsAny(text, "*?") {
+		pattern := regexp.QuoteMeta(text)
+		pattern = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(pattern)
+		return field + " ~ " + strconv.Quote("^"+pattern+"$"), nil
+	}
+	return field + " = " + value(text, quoted), nil
+}
+
+// luceneRange is used to translate [from TO to], braces exclude the bound and * leaves it open
+func luceneRange(r *reader, field string) (string, error) {
+	if field == "" {
+		return "", fmt.Errorf("range without a field at %d", r.position)
+	}
+	inclusive := r.query[r.position] == '['
+	r.position++
+	from := r.word("]}")
+	if !r.keyword("TO") {
+		return "", fmt.Errorf("missing TO in the range of %s", field)
+	}
+	to := r.word("]}")
+	r.skipSpace()
+	if r.position >= len(r.query) || (r.query[r.position] != ']' && r.query[r.position] != '}') {
+		return "", fmt.Errorf("unterminated range of %s", field)
+	}
+	inclusiveTo := r.query[r.position] == ']'
+	r.position++
+	var bounds []string
+	if from != "*" && from != "" {
+		operator := ">"
+		if inclusive {
+			operator = ">="
+		}
+		bounds = append(bounds, field+" "+operator+" "+value(from, false))
+	}
+	if to != "*" && to != "" {
+		operator := "<"
+		if inclusiveTo {
+			operator = "<="
+		}
+		bounds = append(bounds, field+" "+operator+" "+value(to, false))
+	}
+	if len(bounds) == 0 {
+		return "exists " + field, nil
+	}
+	return join("and", bounds), nil
+}
@@ -0,0 +1,55 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslate(t *testing.T) {
+	entry := models.LogEntry{Type: "payment", Level: "error", Labels: map[string]string{"env": "prod"},
+		Data: map[string]interface{}{"amount": 12000.0, "message": "Gateway timeout (upstream)", "user": "john"}}
+	cases := map[string]map[string]bool{
+		"logql": {
+			`{type="payment", env="prod"}`:                              true,
+			`{type=~"pay.*"} |= "timeout (upstream)" != "refused"`:      true,
+			`{type="payment"} |~ "time(out|d)" | json | amount > 10000`: true,
+			`{level!="error"}`: false,
+			`{type="payment"} | logfmt | user="bob" or amount >= 12000`: true,
+			`{type=~"pay"}`: false,
+			`{env="prod"} | json | level="error", amount < 100`:       false,
+			`{env="prod"} |= "Gateway" | (user="john" or user="bob")`: true,
+		},
+		"lucene": {
+			"type:payment AND level:error":                     true,
+			"type:payment AND NOT labels.env:prod":             false,
+			"amount:[10000 TO *] AND user:jo*":                 true,
+			"amount:{12000 TO 20000]":                          false,
+			`timeout AND message:"Gateway timeout (upstream)"`: true,
+			"level:(warn OR error) AND _exists_:user":          true,
+			"amount:>=12000 && -type:order":                    true,
+			"type:order refused":                               false,
+			"user:/j.hn/":                                      true,
+		},
+	}
+	for syntax, queries := range cases {
+		for query, matches := range queries {
+			expression, err := filter.Translate(syntax, query)
+			if !assert.NoError(t, err, query) {
+				continue
+			}
+			f, err := filter.Parse(expression)
+			if assert.NoError(t, err, expression) {
+				assert.Equal(t, matches, f.Match(entry), "%s translated to %s", query, expression)
+			}
+		}
+	}
+	for syntax, query := range map[string]string{"logql": `type="payment"`, "lucene": "amount:[1 TO 2"} {
+		_, err := filter.Translate(syntax, query)
+		assert.Error(t, err, query)
+	}
+	_, err := filter.Translate("sql", "select 1")
+	assert.Error(t, err)
+}
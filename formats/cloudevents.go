@@ -0,0 +1,255 @@
+package formats
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// cloudEventAttributes are the context attributes defined by the spec, the other attributes are extensions
+var cloudEventAttributes = map[string]bool{
+	"specversion": true, "id": true, "source": true, "type": true, "subject": true, "time": true,
+	"datacontenttype": true, "dataschema": true, "data": true, "data_base64": true,
+}
+
+// CloudEvent is a CloudEvents 1.0 event in the structured json format
+type CloudEvent struct {
+	SpecVersion     string                 `json:"specversion"`
+	ID              string                 `json:"id"`
+	Source          string                 `json:"source"`
+	Type            string                 `json:"type"`
+	Subject         string                 `json:"subject,omitempty"`
+	Time            string                 `json:"time,omitempty"`
+	DataContentType string                 `json:"datacontenttype,omitempty"`
+	DataSchema      string                 `json:"dataschema,omitempty"`
+	Data            interface{}            `json:"data,omitempty"`
+	DataBase64      string                 `json:"data_base64,omitempty"`
+	Extensions      map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON is used to write the extensions next to the context attributes
+func (e CloudEvent) MarshalJSON() ([]byte, error) {
+	type event CloudEvent
+	body, err := json.Marshal(event(e))
+	if err != nil || len(e.Extensions) == 0 {
+		return body, err
+	}
+	fields := make(map[string]interface{}, len(e.Extensions))
+	if err = json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range e.Extensions {
+		if !cloudEventAttributes[name] {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// DecodeCloudEvent is used to read an entry from a structured mode event
+func DecodeCloudEvent(body []byte) (models.LogEntry, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil || fields == nil {
+		return models.LogEntry{}, fmt.Errorf("cloud event has to be a json object")
+	}
+	event := CloudEvent{Extensions: make(map[string]interface{})}
+	for name, value := range fields {
+		text, _ := value.(string)
+		switch name {
+		case "specversion":
+			event.SpecVersion = text
+		case "id":
+			event.ID = text
+		case "source":
+			event.Source = text
+		case "type":
+			event.Type = text
+		case "subject":
+			event.Subject = text
+		case "time":
+			event.Time = text
+		case "datacontenttype":
+			event.DataContentType = text
+		case "dataschema":
+			event.DataSchema = text
+		case "data":
+			event.Data = value
+		case "data_base64":
+			event.DataBase64 = text
+		default:
+			event.Extensions[name] = value
+		}
+	}
+	if event.DataBase64 != "" {
+		data, err := base64.StdEncoding.DecodeString(event.DataBase64)
+		if err != nil {
+			return models.LogEntry{}, fmt.Errorf("cloud event data_base64 : %w", err)
+		}
+		event.Data, event.DataBase64 = decodeCloudEventData(event.DataContentType, data), ""
+	}
+	return event.Entry()
+}
+
+// DecodeCloudEventBinary is used to read an entry from a binary mode event,
+// where the attributes are ce- headers and the body is the data
+func DecodeCloudEventBinary(header http.Header, body []byte) (models.LogEntry, error) {
+	event := CloudEvent{Extensions: make(map[string]interface{}), DataContentType: header.Get("Content-Type")}
+	for key, values := range header {
+		if !strings.HasPrefix(key, constants.CloudEventsHeaderPrefix) || len(values) == 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(key, constants.CloudEventsHeaderPrefix))
+		value, err := url.PathUnescape(values[0])
+		if err != nil {
+			value = values[0]
+		}
+		switch name {
+		case "specversion":
+			event.SpecVersion = value
+		case "id":
+			event.ID = value
+		case "source":
+			event.Source = value
+		case "type":
+			event.Type = value
+		case "subject":
+			event.Subject = value
+		case "time":
+			event.Time = value
+		case "dataschema":
+			event.DataSchema = value
+		default:
+			event.Extensions[name] = value
+		}
+	}
+	if len(body) > 0 {
+		event.Data = decodeCloudEventData(event.DataContentType, body)
+	}
+	return event.Entry()
+}
+
+// IsCloudEventBinary is used to check whether the headers carry a binary mode event
+func IsCloudEventBinary(header http.Header) bool {
+	return header.Get(constants.CloudEventsHeaderPrefix+"Specversion") != ""
+}
+
+// decodeCloudEventData is used to decode json data and keep any other data as text, or base64 when not text
+func decodeCloudEventData(contentType string, data []byte) interface{} {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if contentType == "" || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		var value interface{}
+		if err := json.Unmarshal(data, &value); err == nil {
+			return value
+		}
+	}
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// Entry is used to map the event onto an entry typed by the event type.
+// Object data becomes the data of the entry, other data is kept under data,
+// and the attributes are kept under cloudEvent so that the event can be emitted again.
+func (e CloudEvent) Entry() (models.LogEntry, error) {
+	if e.SpecVersion != constants.CloudEventsSpecVersion {
+		return models.LogEntry{}, fmt.Errorf("cloud event specversion has to be %s", constants.CloudEventsSpecVersion)
+	}
+	if e.ID == "" || e.Source == "" || e.Type == "" {
+		return models.LogEntry{}, fmt.Errorf("cloud event id, source and type are required")
+	}
+	entry := models.LogEntry{Type: e.Type, Data: make(map[string]interface{})}
+	if data, ok := e.Data.(map[string]interface{}); ok {
+		entry.Data = data
+	} else if e.Data != nil {
+		entry.Data[constants.CloudEventDataField] = e.Data
+	}
+	attributes := map[string]interface{}{"id": e.ID, "source": e.Source}
+	for name, value := range map[string]string{"subject": e.Subject, "time": e.Time,
+		"datacontenttype": e.DataContentType, "dataschema": e.DataSchema} {
+		if value != "" {
+			attributes[name] = value
+		}
+	}
+	for name, value := range e.Extensions {
+		attributes[name] = value
+	}
+	entry.Data[constants.CloudEventField] = attributes
+	if _, ok := entry.Data[constants.TimeField]; !ok && e.Time != "" {
+		entry.Data[constants.TimeField] = e.Time
+	}
+	return entry, nil
+}
+
+// NewCloudEvent is used to emit the entry as a structured mode event. The attributes the entry was
+// received with are kept, otherwise the id and time are the given ones and this service is the source.
+// Labels with valid attribute names are emitted as extensions.
+func NewCloudEvent(entry models.LogEntry, id string, at time.Time) CloudEvent {
+	event := CloudEvent{
+		SpecVersion:     constants.CloudEventsSpecVersion,
+		ID:              id,
+		Source:          constants.CloudEventsSource,
+		Type:            entry.Type,
+		Time:            at.UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+		Extensions:      make(map[string]interface{}),
+	}
+	for key, value := range entry.Labels {
+		if validCloudEventAttribute(key) && !cloudEventAttributes[key] {
+			event.Extensions[key] = value
+		}
+	}
+	data := make(map[string]interface{}, len(entry.Data))
+	for key, value := range entry.Data {
+		data[key] = value
+	}
+	if attributes, ok := data[constants.CloudEventField].(map[string]interface{}); ok {
+		delete(data, constants.CloudEventField)
+		for name, value := range attributes {
+			text, _ := value.(string)
+			switch name {
+			case "id":
+				event.ID = text
+			case "source":
+				event.Source = text
+			case "subject":
+				event.Subject = text
+			case "time":
+				event.Time = text
+			case "dataschema":
+				event.DataSchema = text
+			case "datacontenttype":
+			default:
+				event.Extensions[name] = value
+			}
+		}
+	}
+	if value, ok := data[constants.CloudEventDataField]; ok && len(data) == 1 {
+		event.Data = value
+	} else {
+		event.Data = data
+	}
+	return event
+}
+
+// validCloudEventAttribute is used to check the attribute naming rule of the spec
+func validCloudEventAttribute(name string) bool {
+	if name == "" || len(name) > constants.MaxCloudEventAttributeLen {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package formats
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// debeziumActions are the audit actions of the debezium operations
+var debeziumActions = map[string]string{
+	"c": "create", "u": "update", "d": "delete", "r": "read", "t": "truncate", "m": "message",
+}
+
+// debeziumActorColumns are the row columns holding who made the change, as databases do not record it in the log
+var debeziumActorColumns = []string{"updated_by", "modified_by", "created_by", "deleted_by", "updatedBy",
+	"modifiedBy", "createdBy", "deletedBy"}
+
+// debezium is the shape of debezium change events, with or without the schema envelope of the json converter,
+// as consumed from a broker or posted by the debezium server http sink. Changes become database audit entries
+// recording who changed what, with the row before and after the change.
+type debezium struct{}
+
+func (debezium) Name() string {
+	return constants.DebeziumFormat
+}
+
+func (debezium) Detect(fields map[string]interface{}) bool {
+	payload := debeziumPayload(fields)
+	_, hasOp := payload["op"].(string)
+	source, hasSource := payload["source"].(map[string]interface{})
+	return hasOp && hasSource && source["connector"] != nil
+}
+
+func (debezium) Convert(fields map[string]interface{}) models.LogEntry {
+	payload := debeziumPayload(fields)
+	source, _ := payload["source"].(map[string]interface{})
+	before, _ := payload["before"].(map[string]interface{})
+	after, _ := payload["after"].(map[string]interface{})
+	op, _ := payload["op"].(string)
+	action, ok := debeziumActions[op]
+	if !ok {
+		action = op
+	}
+	var resource []string
+	for _, key := range []string{"db", "schema", "table"} {
+		if name, ok := source[key].(string); ok && name != "" {
+			resource = append(resource, name)
+		}
+	}
+	data := map[string]interface{}{
+		constants.AuditActionField:   action,
+		constants.AuditResourceField: strings.Join(resource, "."),
+		constants.LevelField:         "info",
+		constants.MessageField:       fmt.Sprintf("%s on %s", action, strings.Join(resource, ".")),
+	}
+	if action == "delete" || action == "truncate" {
+		data[constants.LevelField] = "warn"
+	}
+	for field, key := range map[string]string{
+		constants.AuditDatabaseField: "db", constants.AuditTableField: "table", constants.AuditConnectorField: "connector",
+	} {
+		if value, ok := source[key]; ok && value != nil {
+			data[field] = value
+		}
+	}
+	if at, ok := epochMillis(source["ts_ms"]); ok {
+		data[constants.TimeField] = at
+	} else if at, ok = epochMillis(payload["ts_ms"]); ok {
+		data[constants.TimeField] = at
+	}
+	if transaction, ok := payload["transaction"].(map[string]interface{}); ok && transaction["id"] != nil {
+		data[constants.AuditTransactionField] = transaction["id"]
+	} else if source["txId"] != nil {
+		data[constants.AuditTransactionField] = source["txId"]
+	}
+	if actor := debeziumActor(source, before, after); actor != nil {
+		data[constants.AuditActorField] = actor
+	}
+	if before != nil {
+		data[constants.AuditBeforeField] = before
+	}
+	if after != nil {
+		data[constants.AuditAfterField] = after
+	}
+	if before != nil && after != nil {
+		data[constants.AuditChangesField] = debeziumChanges(before, after)
+	}
+	return models.LogEntry{Type: constants.DebeziumAuditType, Data: data}
+}
+
+// debeziumPayload is used to unwrap the payload of the schema envelope
+func debeziumPayload(fields map[string]interface{}) map[string]interface{} {
+	if payload, ok := fields["payload"].(map[string]interface{}); ok && fields["schema"] != nil {
+		return payload
+	}
+	return fields
+}
+
+// debeziumActor is used to find who made the change, the oracle connector records the user,
+// other databases only have it when the row has one of the actor columns
+func debeziumActor(source, before, after map[string]interface{}) interface{} {
+	if user, ok := source["user_name"].(string); ok && user != "" {
+		return user
+	}
+	for _, row := range []map[string]interface{}{after, before} {
+		for _, column := range debeziumActorColumns {
+			if actor, ok := row[column]; ok && actor != nil {
+				return actor
+			}
+		}
+	}
+	return nil
+}
+
+// debeziumChanges is used to get the sorted columns whose value changed
+func debeziumChanges(before, after map[string]interface{}) []string {
+	changes := make([]string, 0)
+	for column, value := range after {
+		if previous, ok := before[column]; !ok || !reflect.DeepEqual(previous, value) {
+			changes = append(changes, column)
+		}
+	}
+	for column := range before {
+		if _, ok := after[column]; !ok {
+			changes = append(changes, column)
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
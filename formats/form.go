@@ -0,0 +1,45 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// DecodeForm is used to read an entry from form or query values for clients that can not send json.
+// The type value is the type, data holds an optional json object, label.{key} values are labels
+// and every other value is added to the data as a string, or a list of strings when repeated.
+func DecodeForm(values url.Values) (models.LogEntry, error) {
+	entry := models.LogEntry{Type: values.Get(constants.TypeField), Data: make(map[string]interface{})}
+	if entry.Type == "" {
+		return entry, fmt.Errorf("type is required")
+	}
+	if data := values.Get(constants.FormDataField); data != "" {
+		if err := json.Unmarshal([]byte(data), &entry.Data); err != nil || entry.Data == nil {
+			return entry, fmt.Errorf("data has to be a json object")
+		}
+	}
+	for key, list := range values {
+		switch {
+		case key == constants.TypeField || key == constants.FormDataField || len(list) == 0:
+		case strings.HasPrefix(key, constants.FormLabelPrefix):
+			if entry.Labels == nil {
+				entry.Labels = make(map[string]string)
+			}
+			entry.Labels[strings.TrimPrefix(key, constants.FormLabelPrefix)] = list[0]
+		case len(list) == 1:
+			entry.Data[key] = list[0]
+		default:
+			items := make([]interface{}, len(list))
+			for i, item := range list {
+				items[i] = item
+			}
+			entry.Data[key] = items
+		}
+	}
+	return entry, nil
+}
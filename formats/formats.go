@@ -0,0 +1,70 @@
+package formats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// Format maps the json shape emitted by a foreign logging library onto an entry
+type Format interface {
+	// Name is used to select the format explicitly
+	Name() string
+	// Detect is used to check whether the decoded json has the shape of the format
+	Detect(fields map[string]interface{}) bool
+	// Convert is used to map the decoded json onto an entry, the type is left empty when the shape has none
+	Convert(fields map[string]interface{}) models.LogEntry
+}
+
+// formats are checked in order when detecting, so the more specific shapes come first
+var formats = []Format{debezium{}, windows{}, logback{}, pino{}, winston{}}
+
+// Get is used to get the format by name
+func Get(name string) (Format, bool) {
+	for _, format := range formats {
+		if format.Name() == name {
+			return format, true
+		}
+	}
+	return nil, false
+}
+
+// Detect is used to get the first format the decoded json has the shape of
+func Detect(fields map[string]interface{}) (Format, bool) {
+	for _, format := range formats {
+		if format.Detect(fields) {
+			return format, true
+		}
+	}
+	return nil, false
+}
+
+// Names is used to get the names of the supported formats
+func Names() []string {
+	names := make([]string, 0, len(formats))
+	for _, format := range formats {
+		names = append(names, format.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// rename is used to move the value of a field to its name in the entry, if the field is present
+func rename(fields map[string]interface{}, from, to string) {
+	value, ok := fields[from]
+	if !ok || from == to {
+		return
+	}
+	delete(fields, from)
+	fields[to] = value
+}
+
+// epochMillis is used to format an epoch in milliseconds as an RFC3339 timestamp
+func epochMillis(value interface{}) (string, bool) {
+	millis, ok := value.(float64)
+	if !ok {
+		return "", false
+	}
+	return time.UnixMilli(int64(millis)).UTC().Format(time.RFC3339Nano), true
+}
@@ -0,0 +1,180 @@
+package formats_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectAndConvert(t *testing.T) {
+	cases := []struct {
+		body    string
+		format  string
+		typ     string
+		level   string
+		message string
+	}{
+		{`{"@timestamp":"2024-01-02T03:04:05.000Z","@version":"1","message":"started","logger_name":"com.acme.Orders",
+			"thread_name":"main","level":"INFO","level_value":20000}`, "logback", "com.acme.Orders", "info", "started"},
+		{`{"level":50,"time":1700000000000,"pid":1,"hostname":"h","name":"checkout","msg":"failed"}`,
+			"pino", "checkout", "error", "failed"},
+		{`{"level":"warn","message":"slow","service":"cart","timestamp":"2024-01-02T03:04:05Z"}`,
+			"winston", "cart", "warn", "slow"},
+		{`{"@timestamp":"2024-01-02T03:04:05Z","message":"An account was logged on","log":{"level":"information"},
+			"winlog":{"channel":"Security","event_id":"4624","record_id":7,"computer_name":"DC01"}}`,
+			"windows", "windows.security", "info", "An account was logged on"},
+		{`{"EventTime":"2024-01-02 03:04:05","Hostname":"BR01","EventID":7036,"Channel":"System","Level":2,
+			"Message":"service stopped","ServiceName":"spooler"}`, "windows", "windows.system", "error", "service stopped"},
+	}
+	for _, c := range cases {
+		var fields map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(c.body), &fields))
+		format, ok := formats.Detect(fields)
+		assert.True(t, ok)
+		assert.Equal(t, c.format, format.Name())
+		entry := format.Convert(fields)
+		assert.Equal(t, c.typ, entry.Type)
+		assert.Equal(t, c.level, entry.Data["level"])
+		assert.Equal(t, c.message, entry.Data["message"])
+		assert.NotEmpty(t, entry.Data["time"])
+	}
+}
+
+func TestDecodeXML(t *testing.T) {
+	config := formats.XMLConfig{MaxBodyBytes: 1024, MaxDepth: 4, MaxElements: 10, Mappings: []formats.XMLMapping{{
+		Root:   "event",
+		Type:   "legacy-billing",
+		Fields: map[string]string{"message": "body/text", "code": "@code"},
+		Labels: map[string]string{"source": "header/source"},
+	}}}
+	entry, err := formats.DecodeXML(strings.NewReader(
+		`<event code="E42"><header><source>billing</source></header><body><text>failed</text></body></event>`),
+		config, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy-billing", entry.Type)
+	assert.Equal(t, map[string]interface{}{"message": "failed", "code": "E42"}, entry.Data)
+	assert.Equal(t, "billing", entry.Labels["source"])
+
+	_, err = formats.DecodeXML(strings.NewReader(`<a><b><c><d><e/></d></c></b></a>`), config, "")
+	assert.Error(t, err)
+	_, err = formats.DecodeXML(strings.NewReader(`<!DOCTYPE a [<!ENTITY x "x">]><a>&x;</a>`), config, "")
+	assert.Error(t, err)
+	_, err = formats.DecodeXML(strings.NewReader("<a>"+strings.Repeat("x", 2048)+"</a>"), config, "")
+	assert.ErrorIs(t, err, formats.ErrBodyTooLarge)
+}
+
+func TestJournalReader(t *testing.T) {
+	var stream bytes.Buffer
+	stream.WriteString("__REALTIME_TIMESTAMP=1700000000000000\nPRIORITY=3\nSYSLOG_IDENTIFIER=sshd\n" +
+		"_HOSTNAME=vm1\nMESSAGE=first\n\n")
+	stream.WriteString("SYSLOG_IDENTIFIER=app\nMESSAGE\n")
+	_ = binary.Write(&stream, binary.LittleEndian, uint64(11))
+	stream.WriteString("two\nlines\n!\n\n")
+
+	reader := formats.NewJournalReader(&stream)
+	entry, err := reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "journald.sshd", entry.Type)
+	assert.Equal(t, "first", entry.Data["message"])
+	assert.Equal(t, "error", entry.Data["level"])
+	assert.Equal(t, "vm1", entry.Data["host"])
+	assert.Equal(t, "2023-11-14T22:13:20Z", entry.Data["time"])
+
+	entry, err = reader.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, "journald.app", entry.Type)
+	assert.Equal(t, "two\nlines\n!", entry.Data["message"])
+
+	_, err = reader.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestCloudEvents(t *testing.T) {
+	entry, err := formats.DecodeCloudEvent([]byte(`{"specversion":"1.0","id":"e1","source":"/orders",` +
+		`"type":"order.created","time":"2024-01-01T00:00:00Z","tenant":"acme","data":{"orderId":7}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "order.created", entry.Type)
+	assert.Equal(t, float64(7), entry.Data["orderId"])
+	assert.Equal(t, "2024-01-01T00:00:00Z", entry.Data["time"])
+
+	event := formats.NewCloudEvent(entry, "ignored", time.Now())
+	assert.Equal(t, "e1", event.ID)
+	assert.Equal(t, "/orders", event.Source)
+	body, err := json.Marshal(event)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), `"tenant":"acme"`)
+	assert.NotContains(t, string(body), "cloudEvent")
+
+	header := http.Header{}
+	header.Set("Ce-Specversion", "1.0")
+	header.Set("Ce-Id", "e2")
+	header.Set("Ce-Source", "/billing")
+	header.Set("Ce-Type", "invoice.failed")
+	header.Set("Content-Type", "text/plain")
+	entry, err = formats.DecodeCloudEventBinary(header, []byte("card declined"))
+	assert.NoError(t, err)
+	assert.Equal(t, "invoice.failed", entry.Type)
+	assert.Equal(t, "card declined", entry.Data["data"])
+
+	_, err = formats.DecodeCloudEvent([]byte(`{"specversion":"0.3","id":"e3","source":"/a","type":"b"}`))
+	assert.Error(t, err)
+}
+
+func TestDebezium(t *testing.T) {
+	body := `{"schema":{"type":"struct"},"payload":{"op":"u","ts_ms":1700000000500,
+		"before":{"id":7,"status":"pending","updated_by":"checkout"},
+		"after":{"id":7,"status":"paid","updated_by":"payments"},
+		"source":{"connector":"postgresql","db":"orders","schema":"public","table":"orders","ts_ms":1700000000000,
+			"txId":991}}}`
+	var fields map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(body), &fields))
+	format, ok := formats.Detect(fields)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, "debezium", format.Name())
+	entry := format.Convert(fields)
+	assert.Equal(t, "audit.database", entry.Type)
+	assert.Equal(t, "update", entry.Data["action"])
+	assert.Equal(t, "payments", entry.Data["actor"])
+	assert.Equal(t, "orders.public.orders", entry.Data["resource"])
+	assert.Equal(t, []string{"status", "updated_by"}, entry.Data["changes"])
+	assert.Equal(t, float64(991), entry.Data["transactionId"])
+	assert.Equal(t, "2023-11-14T22:13:20Z", entry.Data["time"])
+
+	fields = nil
+	assert.NoError(t, json.Unmarshal([]byte(`{"op":"d","before":{"id":7},"after":null,
+		"source":{"connector":"mysql","db":"shop","table":"carts"}}`), &fields))
+	entry = format.Convert(fields)
+	assert.Equal(t, "delete", entry.Data["action"])
+	assert.Equal(t, "warn", entry.Data["level"])
+	assert.Nil(t, entry.Data["after"])
+}
+
+func TestDecodeForm(t *testing.T) {
+	values, err := url.ParseQuery(`type=email-open&data={"campaign":"diwali","opens":2}&label.region=north` +
+		`&device=tv&tag=a&tag=b`)
+	assert.NoError(t, err)
+	entry, err := formats.DecodeForm(values)
+	assert.NoError(t, err)
+	assert.Equal(t, "email-open", entry.Type)
+	assert.Equal(t, map[string]string{"region": "north"}, entry.Labels)
+	assert.Equal(t, map[string]interface{}{"campaign": "diwali", "opens": float64(2), "device": "tv",
+		"tag": []interface{}{"a", "b"}}, entry.Data)
+
+	_, err = formats.DecodeForm(url.Values{"device": {"tv"}})
+	assert.Error(t, err)
+	_, err = formats.DecodeForm(url.Values{"type": {"email-open"}, "data": {"[1]"}})
+	assert.Error(t, err)
+	_, err = formats.DecodeForm(url.Values{"type": {"email-open"}, "data": {"null"}})
+	assert.Error(t, err)
+}
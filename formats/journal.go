@@ -0,0 +1,116 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// journalPriorities are the level names of the syslog priorities used by journald
+var journalPriorities = map[string]string{
+	"0": "fatal", "1": "fatal", "2": "fatal", "3": "error", "4": "warn", "5": "info", "6": "info", "7": "debug",
+}
+
+// journalFields are the journal fields mapped onto the common ones, the others are kept under journal
+var journalFields = map[string]string{
+	"MESSAGE":       constants.MessageField,
+	"_HOSTNAME":     constants.HostField,
+	"_SYSTEMD_UNIT": constants.JournalUnitField,
+	"_PID":          constants.JournalPIDField,
+}
+
+// JournalReader reads the entries of a systemd journal export format stream, as sent by systemd-journal-upload
+type JournalReader struct {
+	r *bufio.Reader
+}
+
+// NewJournalReader is used to create a reader of the export format stream
+func NewJournalReader(r io.Reader) *JournalReader {
+	return &JournalReader{r: bufio.NewReaderSize(r, bufio.MaxScanTokenSize)}
+}
+
+// Next is used to read the next entry, io.EOF is returned at the end of the stream
+func (j *JournalReader) Next() (models.LogEntry, error) {
+	fields := make(map[string]string)
+	for {
+		line, err := j.r.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			return models.LogEntry{}, fmt.Errorf("journal field is longer than %d bytes", j.r.Size())
+		}
+		if err == io.EOF && len(line) == 0 {
+			if len(fields) == 0 {
+				return models.LogEntry{}, io.EOF
+			}
+			return journalEntry(fields), nil
+		}
+		if err != nil && err != io.EOF {
+			return models.LogEntry{}, err
+		}
+		line = bytes.TrimSuffix(line, []byte("\n"))
+		if len(line) == 0 {
+			if len(fields) == 0 {
+				continue
+			}
+			return journalEntry(fields), nil
+		}
+		if len(fields) >= constants.JournalMaxFields {
+			return models.LogEntry{}, fmt.Errorf("journal entry has more than %d fields", constants.JournalMaxFields)
+		}
+		if name, value, ok := bytes.Cut(line, []byte("=")); ok {
+			fields[string(name)] = string(value)
+			continue
+		}
+		// binary fields are the name on its own line followed by the little endian size and the data
+		name := string(line)
+		var size uint64
+		if err = binary.Read(j.r, binary.LittleEndian, &size); err != nil {
+			return models.LogEntry{}, fmt.Errorf("journal field %s : %w", name, err)
+		}
+		if size > constants.JournalMaxFieldBytes {
+			return models.LogEntry{}, fmt.Errorf("journal field %s is larger than %d bytes", name,
+				constants.JournalMaxFieldBytes)
+		}
+		value := make([]byte, size+1)
+		if _, err = io.ReadFull(j.r, value); err != nil {
+			return models.LogEntry{}, fmt.Errorf("journal field %s : %w", name, err)
+		}
+		fields[name] = string(value[:size])
+	}
+}
+
+// journalEntry is used to map the journal fields onto an entry typed by the syslog identifier
+func journalEntry(fields map[string]string) models.LogEntry {
+	entry := models.LogEntry{Type: constants.JournaldType, Data: make(map[string]interface{})}
+	if identifier := fields["SYSLOG_IDENTIFIER"]; identifier != "" {
+		entry.Type = constants.JournaldType + "." + identifier
+	} else if command := fields["_COMM"]; command != "" {
+		entry.Type = constants.JournaldType + "." + command
+	}
+	if level, ok := journalPriorities[fields["PRIORITY"]]; ok {
+		entry.Data[constants.LevelField] = level
+	}
+	if micros, err := strconv.ParseInt(fields["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+		entry.Data[constants.TimeField] = time.UnixMicro(micros).UTC().Format(time.RFC3339Nano)
+	}
+	rest := make(map[string]interface{})
+	for name, value := range fields {
+		if field, ok := journalFields[name]; ok {
+			entry.Data[field] = value
+		} else if !strings.HasPrefix(name, "__") {
+			rest[name] = value
+		}
+	}
+	if len(rest) > 0 {
+		entry.Data[constants.JournalFieldsField] = rest
+	}
+	return entry
+}
@@ -0,0 +1,105 @@
+package formats
+
+import (
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// logback is the shape of the logstash-logback-encoder
+type logback struct{}
+
+func (logback) Name() string {
+	return constants.LogbackFormat
+}
+
+func (logback) Detect(fields map[string]interface{}) bool {
+	if _, ok := fields["@timestamp"]; !ok {
+		return false
+	}
+	_, hasLogger := fields["logger_name"]
+	_, hasLevelValue := fields["level_value"]
+	return hasLogger || hasLevelValue
+}
+
+func (logback) Convert(fields map[string]interface{}) models.LogEntry {
+	entry := models.LogEntry{Data: fields}
+	if logger, ok := fields["logger_name"].(string); ok {
+		entry.Type = logger
+	}
+	rename(fields, "@timestamp", constants.TimeField)
+	rename(fields, "logger_name", constants.LoggerField)
+	rename(fields, "thread_name", constants.ThreadField)
+	rename(fields, "stack_trace", constants.StackTraceField)
+	if level, ok := fields[constants.LevelField].(string); ok {
+		fields[constants.LevelField] = strings.ToLower(level)
+	}
+	delete(fields, "@version")
+	delete(fields, "level_value")
+	return entry
+}
+
+// pinoLevels are the names of the default numeric pino levels
+var pinoLevels = map[float64]string{10: "trace", 20: "debug", 30: "info", 40: "warn", 50: "error", 60: "fatal"}
+
+// pino is the default json shape of pino
+type pino struct{}
+
+func (pino) Name() string {
+	return constants.PinoFormat
+}
+
+func (pino) Detect(fields map[string]interface{}) bool {
+	_, numericLevel := fields[constants.LevelField].(float64)
+	_, hasMsg := fields["msg"]
+	return numericLevel && hasMsg
+}
+
+func (pino) Convert(fields map[string]interface{}) models.LogEntry {
+	entry := models.LogEntry{Data: fields}
+	if name, ok := fields["name"].(string); ok {
+		entry.Type = name
+		rename(fields, "name", constants.LoggerField)
+	}
+	rename(fields, "msg", constants.MessageField)
+	rename(fields, "hostname", constants.HostField)
+	if level, ok := fields[constants.LevelField].(float64); ok {
+		if name, ok := pinoLevels[level]; ok {
+			fields[constants.LevelField] = name
+		}
+	}
+	if t, ok := epochMillis(fields[constants.TimeField]); ok {
+		fields[constants.TimeField] = t
+	}
+	if err, ok := fields["err"].(map[string]interface{}); ok {
+		rename(err, "stack", constants.StackTraceField)
+		delete(fields, "err")
+		fields[constants.ErrorField] = err
+	}
+	delete(fields, "v")
+	return entry
+}
+
+// winston is the default json shape of winston
+type winston struct{}
+
+func (winston) Name() string {
+	return constants.WinstonFormat
+}
+
+func (winston) Detect(fields map[string]interface{}) bool {
+	_, stringLevel := fields[constants.LevelField].(string)
+	_, hasMessage := fields[constants.MessageField]
+	return stringLevel && hasMessage
+}
+
+func (winston) Convert(fields map[string]interface{}) models.LogEntry {
+	entry := models.LogEntry{Data: fields}
+	if service, ok := fields["service"].(string); ok {
+		entry.Type = service
+	}
+	rename(fields, "timestamp", constants.TimeField)
+	rename(fields, "stack", constants.StackTraceField)
+	return entry
+}
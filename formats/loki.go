@@ -0,0 +1,294 @@
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// lokiTypeLabels are the stream labels the type of the entries is taken from, the first one set
+var lokiTypeLabels = []string{"type", "service_name", "app", "job"}
+
+// lokiLevelLabels are the stream labels and the structured metadata the level is taken from, the first one set
+var lokiLevelLabels = []string{"level", "detected_level", "severity"}
+
+// lokiLevelNames map the level names used by the loki clients onto the common ones
+var lokiLevelNames = map[string]string{
+	"trace": "debug", "debug": "debug", "info": "info", "notice": "info", "warn": "warn", "warning": "warn",
+	"error": "error", "err": "error", "critical": "fatal", "crit": "fatal", "fatal": "fatal", "panic": "fatal",
+}
+
+// field numbers of the messages of the push.proto of loki
+const (
+	lokiPushStreams      = 1
+	lokiStreamLabels     = 1
+	lokiStreamEntries    = 2
+	lokiEntryTimestamp   = 1
+	lokiEntryLine        = 2
+	lokiEntryMetadata    = 3
+	lokiLabelName        = 1
+	lokiLabelValue       = 2
+	lokiTimestampSeconds = 1
+	lokiTimestampNanos   = 2
+)
+
+// lokiPush is the json push request of loki, Labels and Entries are the shape of the legacy one
+type lokiPush struct {
+	Streams []struct {
+		Stream  map[string]string   `json:"stream"`
+		Values  [][]json.RawMessage `json:"values"`
+		Labels  string              `json:"labels"`
+		Entries []struct {
+			Timestamp time.Time `json:"ts"`
+			Line      string    `json:"line"`
+		} `json:"entries"`
+	} `json:"streams"`
+}
+
+// DecodeLokiJSON is used to get the entries of the streams of a json loki push request
+func DecodeLokiJSON(body []byte) ([]models.LogEntry, error) {
+	var push lokiPush
+	if err := json.Unmarshal(body, &push); err != nil {
+		return nil, err
+	}
+	var entries []models.LogEntry
+	for i, stream := range push.Streams {
+		labels := stream.Stream
+		if stream.Labels != "" {
+			var err error
+			if labels, err = ParseLokiLabels(stream.Labels); err != nil {
+				return nil, fmt.Errorf("stream %d : %w", i, err)
+			}
+		}
+		for _, entry := range stream.Entries {
+			entries = append(entries, lokiEntry(labels, entry.Timestamp, entry.Line, nil))
+		}
+		for j, value := range stream.Values {
+			if len(value) != 2 && len(value) != 3 {
+				return nil, fmt.Errorf("stream %d value %d has to be a timestamp, a line and optional metadata", i, j)
+			}
+			var timestamp, line string
+			var metadata map[string]string
+			if err := json.Unmarshal(value[0], &timestamp); err != nil {
+				return nil, fmt.Errorf("stream %d value %d timestamp : %w", i, j, err)
+			}
+			nanos, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("stream %d value %d timestamp has to be unix nanoseconds : %w", i, j, err)
+			}
+			if err = json.Unmarshal(value[1], &line); err != nil {
+				return nil, fmt.Errorf("stream %d value %d line : %w", i, j, err)
+			}
+			if len(value) == 3 {
+				if err = json.Unmarshal(value[2], &metadata); err != nil {
+					return nil, fmt.Errorf("stream %d value %d metadata : %w", i, j, err)
+				}
+			}
+			entries = append(entries, lokiEntry(labels, time.Unix(0, nanos), line, metadata))
+		}
+	}
+	return entries, nil
+}
+
+// DecodeLokiProtobuf is used to get the entries of the streams of a protobuf loki push request, once decompressed
+func DecodeLokiProtobuf(data []byte) ([]models.LogEntry, error) {
+	var entries []models.LogEntry
+	err := eachProtoField(data, func(number protowire.Number, _ uint64, stream []byte) error {
+		if number != lokiPushStreams {
+			return nil
+		}
+		var labels map[string]string
+		var encoded [][]byte
+		err := eachProtoField(stream, func(number protowire.Number, _ uint64, value []byte) error {
+			var err error
+			switch number {
+			case lokiStreamLabels:
+				labels, err = ParseLokiLabels(string(value))
+			case lokiStreamEntries:
+				encoded = append(encoded, value)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, data := range encoded {
+			entry, err := decodeLokiEntry(labels, data)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// decodeLokiEntry is used to decode an EntryAdapter message of the stream with the labels
+func decodeLokiEntry(labels map[string]string, data []byte) (models.LogEntry, error) {
+	var at time.Time
+	var line string
+	metadata := make(map[string]string)
+	err := eachProtoField(data, func(number protowire.Number, _ uint64, value []byte) error {
+		switch number {
+		case lokiEntryTimestamp:
+			var seconds, nanos uint64
+			err := eachProtoField(value, func(number protowire.Number, varint uint64, _ []byte) error {
+				switch number {
+				case lokiTimestampSeconds:
+					seconds = varint
+				case lokiTimestampNanos:
+					nanos = varint
+				}
+				return nil
+			})
+			at = time.Unix(int64(seconds), int64(nanos))
+			return err
+		case lokiEntryLine:
+			line = string(value)
+		case lokiEntryMetadata:
+			var name, pair string
+			err := eachProtoField(value, func(number protowire.Number, _ uint64, value []byte) error {
+				switch number {
+				case lokiLabelName:
+					name = string(value)
+				case lokiLabelValue:
+					pair = string(value)
+				}
+				return nil
+			})
+			metadata[name] = pair
+			return err
+		}
+		return nil
+	})
+	return lokiEntry(labels, at, line, metadata), err
+}
+
+// lokiEntry is used to map a line of a stream onto an entry typed by the labels of the stream
+func lokiEntry(labels map[string]string, at time.Time, line string, metadata map[string]string) models.LogEntry {
+	// the entries of a stream get labels of their own, as the pipeline can change them
+	entry := models.LogEntry{Type: constants.LokiType, Labels: make(map[string]string, len(labels)),
+		Data: map[string]interface{}{constants.MessageField: line}}
+	for name, value := range labels {
+		entry.Labels[name] = value
+	}
+	for _, name := range lokiTypeLabels {
+		if value := labels[name]; value != "" {
+			entry.Type = value
+			break
+		}
+	}
+	for _, name := range lokiLevelLabels {
+		level := labels[name]
+		if level == "" {
+			level = metadata[name]
+		}
+		if level == "" {
+			continue
+		}
+		level = strings.ToLower(level)
+		if mapped, ok := lokiLevelNames[level]; ok {
+			level = mapped
+		}
+		entry.Data[constants.LevelField] = level
+		break
+	}
+	if !at.IsZero() && at.UnixNano() > 0 {
+		entry.Data[constants.TimeField] = at.UTC().Format(time.RFC3339Nano)
+	}
+	if len(metadata) > 0 {
+		fields := make(map[string]interface{}, len(metadata))
+		for name, value := range metadata {
+			fields[name] = value
+		}
+		entry.Data[constants.LokiMetadataField] = fields
+	}
+	return entry
+}
+
+// ParseLokiLabels is used to parse the labels of a stream written as a prometheus selector, e.g.
+// {job="api", env="prod"}
+func ParseLokiLabels(s string) (map[string]string, error) {
+	rest := strings.TrimSpace(s)
+	if !strings.HasPrefix(rest, "{") || !strings.HasSuffix(rest, "}") {
+		return nil, fmt.Errorf("labels %q have to be in braces", s)
+	}
+	rest = strings.TrimSpace(rest[1 : len(rest)-1])
+	labels := make(map[string]string)
+	for rest != "" {
+		if len(labels) == constants.MaxLokiStreamLabels {
+			return nil, fmt.Errorf("labels %q are more than %d", s, constants.MaxLokiStreamLabels)
+		}
+		end := strings.IndexByte(rest, '=')
+		if end <= 0 {
+			return nil, fmt.Errorf("labels %q have a label without a value", s)
+		}
+		name := strings.TrimSpace(rest[:end])
+		if !labelName(name) {
+			return nil, fmt.Errorf("labels %q have an invalid name %q", s, name)
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+		quoted, err := strconv.QuotedPrefix(rest)
+		if err != nil {
+			return nil, fmt.Errorf("labels %q have an unquoted value of %s", s, name)
+		}
+		if labels[name], err = strconv.Unquote(quoted); err != nil {
+			return nil, fmt.Errorf("labels %q have an invalid value of %s : %w", s, name, err)
+		}
+		rest = strings.TrimSpace(rest[len(quoted):])
+		if rest != "" {
+			if rest[0] != ',' {
+				return nil, fmt.Errorf("labels %q have to be separated by commas", s)
+			}
+			rest = strings.TrimSpace(rest[1:])
+		}
+	}
+	return labels, nil
+}
+
+// labelName is used to check that the name is a prometheus label name
+func labelName(name string) bool {
+	for i, c := range name {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return name != ""
+}
+
+// eachProtoField is used to call the function with the fields of the message, the value of varints or else
+// the bytes of the length delimited ones
+func eachProtoField(data []byte, f func(number protowire.Number, varint uint64, value []byte) error) error {
+	for len(data) > 0 {
+		number, kind, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		var varint uint64
+		var value []byte
+		switch kind {
+		case protowire.VarintType:
+			varint, n = protowire.ConsumeVarint(data)
+		case protowire.BytesType:
+			value, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(number, kind, data)
+		}
+		if n < 0 {
+			return fmt.Errorf("field %d : %w", number, protowire.ParseError(n))
+		}
+		data = data[n:]
+		if err := f(number, varint, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package formats_test
+
+import (
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestDecodeLokiJSON(t *testing.T) {
+	entries, err := formats.DecodeLokiJSON([]byte(`{"streams":[
+		{"stream":{"job":"varlogs","env":"prod","level":"WARNING"},"values":[
+			["1792143000000000000","disk is 91% full"],
+			["1792143001000000000","disk is 95% full",{"traceID":"abc"}]]},
+		{"labels":"{service_name=\"api\", path=\"/a\\\"b\"}","entries":[{"ts":"2026-10-16T09:30:00Z","line":"started"}]}
+	]}`))
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, "varlogs", entries[0].Type)
+		assert.Equal(t, map[string]string{"job": "varlogs", "env": "prod", "level": "WARNING"}, entries[0].Labels)
+		assert.Equal(t, map[string]interface{}{"message": "disk is 91% full", "level": "warn",
+			"time": "2026-10-16T09:30:00Z"}, entries[0].Data)
+		assert.Equal(t, map[string]interface{}{"traceID": "abc"}, entries[1].Data["metadata"])
+		assert.Equal(t, "api", entries[2].Type)
+		assert.Equal(t, `/a"b`, entries[2].Labels["path"])
+		assert.Equal(t, "started", entries[2].Data["message"])
+	}
+
+	_, err = formats.DecodeLokiJSON([]byte(`{"streams":[{"stream":{},"values":[["soon","line"]]}]}`))
+	assert.Error(t, err)
+}
+
+func TestDecodeLokiProtobuf(t *testing.T) {
+	message := func(fields ...[]byte) []byte {
+		var b []byte
+		for _, field := range fields {
+			b = append(b, field...)
+		}
+		return b
+	}
+	bytesField := func(number protowire.Number, value []byte) []byte {
+		b := protowire.AppendTag(nil, number, protowire.BytesType)
+		return protowire.AppendBytes(b, value)
+	}
+	varintField := func(number protowire.Number, value uint64) []byte {
+		b := protowire.AppendTag(nil, number, protowire.VarintType)
+		return protowire.AppendVarint(b, value)
+	}
+	entry := message(
+		bytesField(1, message(varintField(1, 1792143000), varintField(2, 500))),
+		bytesField(2, []byte("payment failed")),
+		bytesField(3, message(bytesField(1, []byte("detected_level")), bytesField(2, []byte("error")))),
+	)
+	stream := message(bytesField(1, []byte(`{app="payments",env="prod"}`)), bytesField(2, entry), varintField(3, 42))
+	entries, err := formats.DecodeLokiProtobuf(bytesField(1, stream))
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "payments", entries[0].Type)
+		assert.Equal(t, map[string]string{"app": "payments", "env": "prod"}, entries[0].Labels)
+		assert.Equal(t, "payment failed", entries[0].Data["message"])
+		assert.Equal(t, "error", entries[0].Data["level"])
+		assert.Equal(t, "2026-10-16T09:30:00.0000005Z", entries[0].Data["time"])
+	}
+
+	for _, invalid := range []string{`app="payments"`, `{app=payments}`, `{1app="x"}`, `{app="a" env="b"}`} {
+		_, err = formats.ParseLokiLabels(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
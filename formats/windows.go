@@ -0,0 +1,174 @@
+package formats
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// windowsLevels are the names of the numeric windows event levels
+var windowsLevels = map[int64]string{0: "info", 1: "fatal", 2: "error", 3: "warn", 4: "info", 5: "debug"}
+
+// windowsLevelNames map the level names used by winlogbeat and forwarders onto the common ones
+var windowsLevelNames = map[string]string{
+	"critical": "fatal", "error": "error", "warning": "warn", "information": "info", "info": "info",
+	"verbose": "debug", "audit success": "info", "audit failure": "warn",
+}
+
+// windows is the shape of windows events as shipped by winlogbeat, by forwarders rendering
+// the event xml as json (System and EventData) and by flat forwarders such as nxlog (EventID and Channel)
+type windows struct{}
+
+func (windows) Name() string {
+	return constants.WindowsFormat
+}
+
+func (windows) Detect(fields map[string]interface{}) bool {
+	if winlog, ok := fields["winlog"].(map[string]interface{}); ok {
+		_, hasID := winlog["event_id"]
+		return hasID
+	}
+	if system, ok := fields["System"].(map[string]interface{}); ok {
+		_, hasID := system["EventID"]
+		return hasID
+	}
+	_, hasID := fields["EventID"]
+	_, hasChannel := fields["Channel"]
+	return hasID && hasChannel
+}
+
+func (windows) Convert(fields map[string]interface{}) models.LogEntry {
+	event := windowsEvent{data: make(map[string]interface{})}
+	switch {
+	case fields["winlog"] != nil:
+		event.fromWinlogbeat(fields)
+	case fields["System"] != nil:
+		event.fromRendered(fields)
+	default:
+		event.fromFlat(fields)
+	}
+	entry := models.LogEntry{Type: constants.WindowsFormat, Data: event.data}
+	if channel, ok := event.data[constants.WindowsChannelField].(string); ok && channel != "" {
+		entry.Type = constants.WindowsFormat + "." + strings.ToLower(strings.ReplaceAll(channel, "/", "."))
+	}
+	return entry
+}
+
+type windowsEvent struct {
+	data map[string]interface{}
+}
+
+// set is used to set the normalized field when the value is present
+func (e windowsEvent) set(field string, value interface{}) {
+	if value == nil || value == "" {
+		return
+	}
+	switch field {
+	case constants.WindowsEventIDField, constants.WindowsRecordIDField:
+		if id, ok := toInt(value); ok {
+			value = id
+		}
+	case constants.LevelField:
+		value = windowsLevel(value)
+	}
+	e.data[field] = value
+}
+
+func (e windowsEvent) fromWinlogbeat(fields map[string]interface{}) {
+	winlog, _ := fields["winlog"].(map[string]interface{})
+	e.set(constants.WindowsEventIDField, winlog["event_id"])
+	e.set(constants.WindowsChannelField, winlog["channel"])
+	e.set(constants.WindowsProviderField, winlog["provider_name"])
+	e.set(constants.WindowsComputerField, winlog["computer_name"])
+	e.set(constants.WindowsRecordIDField, winlog["record_id"])
+	e.set(constants.WindowsKeywordsField, winlog["keywords"])
+	e.set(constants.WindowsEventDataField, winlog["event_data"])
+	e.set(constants.WindowsUserDataField, winlog["user_data"])
+	if log, ok := fields["log"].(map[string]interface{}); ok {
+		e.set(constants.LevelField, log["level"])
+	}
+	e.set(constants.TimeField, fields["@timestamp"])
+	e.set(constants.MessageField, fields["message"])
+}
+
+func (e windowsEvent) fromRendered(fields map[string]interface{}) {
+	system, _ := fields["System"].(map[string]interface{})
+	e.set(constants.WindowsEventIDField, system["EventID"])
+	e.set(constants.WindowsChannelField, system["Channel"])
+	if provider, ok := system["Provider"].(map[string]interface{}); ok {
+		e.set(constants.WindowsProviderField, provider["Name"])
+	}
+	e.set(constants.WindowsComputerField, system["Computer"])
+	e.set(constants.WindowsRecordIDField, system["EventRecordID"])
+	e.set(constants.WindowsKeywordsField, system["Keywords"])
+	e.set(constants.LevelField, system["Level"])
+	if created, ok := system["TimeCreated"].(map[string]interface{}); ok {
+		e.set(constants.TimeField, created["SystemTime"])
+	}
+	e.set(constants.WindowsEventDataField, fields["EventData"])
+	e.set(constants.WindowsUserDataField, fields["UserData"])
+	if rendering, ok := fields["RenderingInfo"].(map[string]interface{}); ok {
+		e.set(constants.MessageField, rendering["Message"])
+	}
+	e.set(constants.MessageField, fields["Message"])
+}
+
+// flatFields are the fields of flat forwarders mapped onto the normalized ones
+var flatFields = map[string]string{
+	"EventID":       constants.WindowsEventIDField,
+	"Channel":       constants.WindowsChannelField,
+	"SourceName":    constants.WindowsProviderField,
+	"ProviderName":  constants.WindowsProviderField,
+	"Hostname":      constants.WindowsComputerField,
+	"Computer":      constants.WindowsComputerField,
+	"RecordNumber":  constants.WindowsRecordIDField,
+	"EventRecordID": constants.WindowsRecordIDField,
+	"Keywords":      constants.WindowsKeywordsField,
+	"EventTime":     constants.TimeField,
+	"TimeCreated":   constants.TimeField,
+	"Message":       constants.MessageField,
+	"Level":         constants.LevelField,
+	"Severity":      constants.LevelField,
+}
+
+func (e windowsEvent) fromFlat(fields map[string]interface{}) {
+	for key, value := range fields {
+		if field, ok := flatFields[key]; ok {
+			if _, exists := e.data[field]; !exists {
+				e.set(field, value)
+			}
+			continue
+		}
+		e.data[key] = value
+	}
+}
+
+// windowsLevel is used to map numeric and named windows levels onto the common level names
+func windowsLevel(value interface{}) interface{} {
+	if level, ok := toInt(value); ok {
+		if name, ok := windowsLevels[level]; ok {
+			return name
+		}
+		return value
+	}
+	if name, ok := value.(string); ok {
+		if mapped, ok := windowsLevelNames[strings.ToLower(name)]; ok {
+			return mapped
+		}
+		return strings.ToLower(name)
+	}
+	return value
+}
+
+func toInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), true
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,260 @@
+package formats
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// Config is the configuration of the non json body formats
+type Config struct {
+	XML XMLConfig `json:"xml" mapstructure:"xml"`
+}
+
+// XMLConfig is the configuration of xml bodies
+type XMLConfig struct {
+	// MaxBodyBytes is the largest accepted body
+	MaxBodyBytes int64 `json:"maxBodyBytes" mapstructure:"maxBodyBytes"`
+	// MaxDepth is the deepest accepted element nesting
+	MaxDepth int `json:"maxDepth" mapstructure:"maxDepth"`
+	// MaxElements is the largest accepted number of elements
+	MaxElements int `json:"maxElements" mapstructure:"maxElements"`
+	// Mappings map documents by their root element, documents without a mapping are converted as a whole
+	Mappings []XMLMapping `json:"mappings" mapstructure:"mappings"`
+}
+
+// XMLMapping picks the fields of an entry out of a document.
+// Paths are relative to the root element, e.g. header/source or event/@id for an attribute.
+type XMLMapping struct {
+	// Root is the name of the root element the mapping applies to, * matches all
+	Root string `json:"root" mapstructure:"root"`
+	// Type is the type of the entries, the type query param and then the root element name are used when empty
+	Type   string            `json:"type" mapstructure:"type"`
+	Fields map[string]string `json:"fields" mapstructure:"fields"`
+	Labels map[string]string `json:"labels" mapstructure:"labels"`
+}
+
+var (
+	configMu sync.RWMutex
+	config   = Config{}
+	// errDoctype is returned for documents declaring a doctype, which is where entity expansion attacks live
+	errDoctype = errors.New("xml doctype declarations are not allowed")
+	// ErrBodyTooLarge is returned for bodies above the configured size
+	ErrBodyTooLarge = errors.New("body is too large")
+)
+
+// Init is used to set the configuration of the formats
+func Init(c Config) {
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+// XML is used to get the xml configuration with the defaults applied
+func XML() XMLConfig {
+	configMu.RLock()
+	c := config.XML
+	configMu.RUnlock()
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = constants.DefaultXMLMaxBodyBytes
+	}
+	if c.MaxDepth <= 0 {
+		c.MaxDepth = constants.DefaultXMLMaxDepth
+	}
+	if c.MaxElements <= 0 {
+		c.MaxElements = constants.DefaultXMLMaxElements
+	}
+	return c
+}
+
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     strings.Builder
+}
+
+// DecodeXML is used to read an entry from an xml document.
+// The type of the mapping wins over the passed type, which wins over the name of the root element.
+func DecodeXML(r io.Reader, c XMLConfig, entryType string) (models.LogEntry, error) {
+	root, err := parseXML(io.LimitReader(r, c.MaxBodyBytes+1), c)
+	if err != nil {
+		return models.LogEntry{}, err
+	}
+	mapping, ok := findMapping(c.Mappings, root.name)
+	if mapping.Type == "" {
+		mapping.Type = entryType
+	}
+	if mapping.Type == "" {
+		mapping.Type = root.name
+	}
+	if !ok || len(mapping.Fields) == 0 {
+		value := root.value()
+		data, isMap := value.(map[string]interface{})
+		if !isMap {
+			data = map[string]interface{}{constants.MessageField: value}
+		}
+		return models.LogEntry{Type: mapping.Type, Data: data}, nil
+	}
+	entry := models.LogEntry{Type: mapping.Type, Data: make(map[string]interface{}, len(mapping.Fields))}
+	for field, path := range mapping.Fields {
+		if value, ok := root.find(path); ok {
+			entry.Data[field] = value
+		}
+	}
+	for label, path := range mapping.Labels {
+		if value, ok := root.find(path); ok {
+			if entry.Labels == nil {
+				entry.Labels = make(map[string]string, len(mapping.Labels))
+			}
+			entry.Labels[label] = value
+		}
+	}
+	return entry, nil
+}
+
+func findMapping(mappings []XMLMapping, root string) (XMLMapping, bool) {
+	var wildcard *XMLMapping
+	for i, mapping := range mappings {
+		if mapping.Root == root {
+			return mapping, true
+		}
+		if mapping.Root == "*" && wildcard == nil {
+			wildcard = &mappings[i]
+		}
+	}
+	if wildcard != nil {
+		return *wildcard, true
+	}
+	return XMLMapping{}, false
+}
+
+// parseXML is used to build the element tree while enforcing the size, depth and element limits
+func parseXML(r io.Reader, c XMLConfig) (*xmlNode, error) {
+	counter := &countingReader{r: r}
+	decoder := xml.NewDecoder(counter)
+	decoder.Strict = true
+	var root *xmlNode
+	var stack []*xmlNode
+	elements := 0
+	for {
+		token, err := decoder.Token()
+		if counter.n > c.MaxBodyBytes {
+			return nil, fmt.Errorf("%w, the limit is %d bytes", ErrBodyTooLarge, c.MaxBodyBytes)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid xml : %w", err)
+		}
+		switch t := token.(type) {
+		case xml.Directive:
+			return nil, errDoctype
+		case xml.StartElement:
+			elements++
+			if elements > c.MaxElements {
+				return nil, fmt.Errorf("xml has more than %d elements", c.MaxElements)
+			}
+			if len(stack) >= c.MaxDepth {
+				return nil, fmt.Errorf("xml is nested deeper than %d elements", c.MaxDepth)
+			}
+			node := &xmlNode{name: t.Name.Local, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root != nil {
+				return nil, fmt.Errorf("xml has more than one root element")
+			} else {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("xml has no root element")
+	}
+	return root, nil
+}
+
+// value is used to convert the element to json like values: attributes become @name fields,
+// repeated children become arrays and the text of elements with attributes or children becomes #text
+func (n *xmlNode) value() interface{} {
+	text := strings.TrimSpace(n.text.String())
+	if len(n.attrs) == 0 && len(n.children) == 0 {
+		return text
+	}
+	value := make(map[string]interface{}, len(n.attrs)+len(n.children))
+	for _, attr := range n.attrs {
+		value["@"+attr.Name.Local] = attr.Value
+	}
+	for _, child := range n.children {
+		existing, ok := value[child.name]
+		if !ok {
+			value[child.name] = child.value()
+			continue
+		}
+		list, isList := existing.([]interface{})
+		if !isList {
+			list = []interface{}{existing}
+		}
+		value[child.name] = append(list, child.value())
+	}
+	if text != "" {
+		value["#text"] = text
+	}
+	return value
+}
+
+// find is used to get the text or attribute at the path, following the first matching child at every step
+func (n *xmlNode) find(path string) (string, bool) {
+	node := n
+	for _, step := range strings.Split(strings.Trim(path, "/"), "/") {
+		if strings.HasPrefix(step, "@") {
+			for _, attr := range node.attrs {
+				if attr.Name.Local == step[1:] {
+					return attr.Value, true
+				}
+			}
+			return "", false
+		}
+		if step == "" || step == "." {
+			continue
+		}
+		var next *xmlNode
+		for _, child := range node.children {
+			if child.name == step {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return "", false
+		}
+		node = next
+	}
+	return strings.TrimSpace(node.text.String()), true
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,269 @@
+package gitops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrDisabled is returned when a sync is requested but no repository is configured
+var ErrDisabled = errors.New(constants.GitOpsDisabledError)
+
+// Config is the set of configurable parameters for syncing admin resources from git
+type Config struct {
+	// Repository is the url of the git repository, sync is disabled when empty
+	Repository string `json:"repository"`
+	// Branch is the branch that is synced
+	Branch string `json:"branch"`
+	// Path is the directory inside the repository holding one directory per resource kind
+	Path string `json:"path"`
+	// WorkDir is the local directory the repository is cloned into
+	WorkDir string `json:"workDir"`
+	// PollInterval is the interval between syncs, webhooks trigger syncs in between
+	PollInterval time.Duration `json:"pollInterval"`
+	// WebhookSecret is used to verify the signature of webhook calls
+	WebhookSecret string `json:"-"`
+}
+
+// Status is the outcome of the latest sync
+type Status struct {
+	Enabled       bool      `json:"enabled"`
+	AppliedCommit string    `json:"appliedCommit,omitempty"`
+	LastCommit    string    `json:"lastCommit,omitempty"`
+	LastSyncAt    time.Time `json:"lastSyncAt,omitempty"`
+	LastChanges   int       `json:"lastChanges"`
+	LastError     string    `json:"lastError,omitempty"`
+}
+
+// Syncer keeps the registry in sync with the resources declared in a git repository.
+// The repository has the layout {path}/{kind}/{id}.{json,yaml,yml}, and every kind directory
+// present replaces all the resources of that kind. A commit that fails validation is not applied,
+// so the registry stays on the last good commit until the repository is fixed.
+type Syncer struct {
+	config   Config
+	registry *registry.Registry
+	trigger  chan struct{}
+	mu       sync.Mutex
+	status   Status
+}
+
+var s = &Syncer{}
+
+// Init is used to initialize the default syncer
+func Init(config Config, reg *registry.Registry) {
+	s = New(config, reg)
+}
+
+// Get is used to get the default syncer
+func Get() *Syncer {
+	return s
+}
+
+// New is used to create a syncer for the config
+func New(config Config, reg *registry.Registry) *Syncer {
+	if config.Branch == "" {
+		config.Branch = constants.DefaultGitOpsBranch
+	}
+	if config.WorkDir == "" {
+		config.WorkDir = filepath.Join(os.TempDir(), constants.GitOpsWorkDirName)
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = constants.DefaultGitOpsPollInterval * time.Second
+	}
+	return &Syncer{
+		config:   config,
+		registry: reg,
+		trigger:  make(chan struct{}, 1),
+		status:   Status{Enabled: config.Repository != ""},
+	}
+}
+
+// Enabled is used to check whether a repository is configured
+func (s *Syncer) Enabled() bool {
+	return s.config.Repository != ""
+}
+
+// WebhookSecret is used to get the secret webhook calls are signed with
+func (s *Syncer) WebhookSecret() string {
+	return s.config.WebhookSecret
+}
+
+// Status is used to get the outcome of the latest sync
+func (s *Syncer) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Start is used to sync in the background on every poll interval and trigger until the context is done
+func (s *Syncer) Start(ctx context.Context) {
+	if !s.Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(s.config.PollInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.Sync(ctx); err != nil {
+				log.Error(ctx).Err(err).Msg("error syncing admin resources from git")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.trigger:
+			}
+		}
+	}()
+}
+
+// Trigger is used to request a sync without waiting for it, e.g. from a webhook
+func (s *Syncer) Trigger() error {
+	if !s.Enabled() {
+		return ErrDisabled
+	}
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+		// a sync is already pending
+	}
+	return nil
+}
+
+// Sync is used to pull the repository and apply the declared resources
+func (s *Syncer) Sync(ctx context.Context) error {
+	if !s.Enabled() {
+		return ErrDisabled
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// the resources are applied even for an already applied commit to undo drift from manual changes
+	commit, err := s.pull(ctx)
+	if err == nil {
+		s.status.LastCommit = commit
+		var desired map[string][]registry.Resource
+		desired, err = s.read()
+		if err == nil {
+			s.status.LastChanges, err = s.registry.Apply(desired, constants.GitOpsAuthor+"@"+shortCommit(commit))
+		}
+	}
+	s.status.LastSyncAt = time.Now().UTC()
+	if err != nil {
+		s.status.LastError = err.Error()
+		return err
+	}
+	s.status.AppliedCommit = commit
+	s.status.LastError = ""
+	if s.status.LastChanges > 0 {
+		log.Info(ctx).Str(constants.GitOpsCommitKey, commit).Int(constants.ChangesKey, s.status.LastChanges).
+			Msg("applied admin resources from git")
+	}
+	return nil
+}
+
+// pull is used to clone or update the work dir to the head of the branch and get its commit
+func (s *Syncer) pull(ctx context.Context) (string, error) {
+	if _, err := os.Stat(filepath.Join(s.config.WorkDir, ".git")); err != nil {
+		if err = os.RemoveAll(s.config.WorkDir); err != nil {
+			return "", err
+		}
+		if _, err = git(ctx, "", "clone", "--depth", "1", "--branch", s.config.Branch,
+			s.config.Repository, s.config.WorkDir); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err = git(ctx, s.config.WorkDir, "fetch", "--depth", "1", "origin", s.config.Branch); err != nil {
+			return "", err
+		}
+		if _, err = git(ctx, s.config.WorkDir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	}
+	commit, err := git(ctx, s.config.WorkDir, "rev-parse", "HEAD")
+	return strings.TrimSpace(commit), err
+}
+
+// read is used to load the resources of every registered kind that has a directory in the repository
+func (s *Syncer) read() (map[string][]registry.Resource, error) {
+	root := filepath.Join(s.config.WorkDir, s.config.Path)
+	desired := make(map[string][]registry.Resource)
+	for _, kind := range s.registry.Kinds() {
+		dir := filepath.Join(root, kind)
+		files, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		resources := make([]registry.Resource, 0, len(files))
+		for _, file := range files {
+			ext := filepath.Ext(file.Name())
+			if file.IsDir() || (ext != ".json" && ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+			spec, err := readSpec(filepath.Join(dir, file.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("%s/%s : %w", kind, file.Name(), err)
+			}
+			resources = append(resources, registry.Resource{
+				Kind: kind,
+				ID:   strings.TrimSuffix(file.Name(), ext),
+				Spec: spec,
+			})
+		}
+		desired[kind] = resources
+	}
+	return desired, nil
+}
+
+// readSpec is used to read a json or yaml file as compact json
+func readSpec(path string) (json.RawMessage, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if filepath.Ext(path) == ".json" {
+		err = json.Unmarshal(content, &value)
+	} else {
+		err = yaml.Unmarshal(content, &value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s : %w : %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
@@ -0,0 +1,147 @@
+package gitops_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/gitops"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+// fixture is a local git repository standing in for the config repository
+type fixture struct {
+	t   *testing.T
+	dir string
+}
+
+func newFixture(t *testing.T) *fixture {
+	f := &fixture{t: t, dir: t.TempDir()}
+	f.git("init", "-q")
+	f.git("checkout", "-q", "-b", "main")
+	return f
+}
+
+func (f *fixture) git(args ...string) string {
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = f.dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		f.t.Fatalf("git %s : %v : %s", args[4], err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit is used to write the files, remove the ones with an empty content and commit the result
+func (f *fixture) commit(files map[string]string) string {
+	for name, content := range files {
+		path := filepath.Join(f.dir, name)
+		if content == "" {
+			assert.NoError(f.t, os.Remove(path))
+			continue
+		}
+		assert.NoError(f.t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(f.t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	f.git("add", "-A")
+	f.git("commit", "-q", "-m", "change")
+	return f.git("rev-parse", "HEAD")
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	repo := newFixture(t)
+	reg := registry.New()
+	reg.RegisterKind(registry.Kind{Name: "types", Validate: registry.ValidateObject})
+	reg.RegisterKind(registry.Kind{Name: "rules", Validate: registry.ValidateObject})
+	_, err := reg.Put("rules", "manual", json.RawMessage(`{"drop":true}`), registry.Precondition{}, "alice")
+	assert.NoError(t, err)
+	syncer := gitops.New(gitops.Config{
+		Repository: "file://" + repo.dir,
+		Branch:     "main",
+		Path:       "config",
+		WorkDir:    filepath.Join(t.TempDir(), "work"),
+	}, reg)
+
+	// json and yaml files are applied, other files are ignored
+	first := repo.commit(map[string]string{
+		"config/types/order.json":   `{"owner":"payments"}`,
+		"config/types/refund.yaml":  "owner: refunds\nretention: 30\n",
+		"config/types/README.md":    "types owned by the payments team",
+		"config/unknown/ignored.js": `{}`,
+	})
+	assert.NoError(t, syncer.Sync(ctx))
+	status := syncer.Status()
+	assert.True(t, status.Enabled)
+	assert.Equal(t, first, status.AppliedCommit)
+	assert.Equal(t, 2, status.LastChanges)
+	order, err := reg.Get("types", "order")
+	assert.NoError(t, err)
+	assert.Equal(t, "gitops@"+first[:12], order.UpdatedBy)
+	refund, err := reg.Get("types", "refund")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"owner":"refunds","retention":30}`, string(refund.Spec))
+	// the kinds without a directory are left alone
+	_, err = reg.Get("rules", "manual")
+	assert.NoError(t, err)
+
+	// only the difference to the registry is applied, the unchanged resources keep their version
+	second := repo.commit(map[string]string{
+		"config/types/order.json":  "",
+		"config/types/refund.yaml": "owner: refunds\nretention: 60\n",
+		"config/types/trade.yml":   "owner: trading\n",
+	})
+	assert.NoError(t, syncer.Sync(ctx))
+	assert.Equal(t, second, syncer.Status().AppliedCommit)
+	assert.Equal(t, 3, syncer.Status().LastChanges)
+	_, err = reg.Get("types", "order")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	refund, _ = reg.Get("types", "refund")
+	assert.Equal(t, int64(2), refund.Version)
+	assert.NoError(t, syncer.Sync(ctx))
+	assert.Equal(t, 0, syncer.Status().LastChanges)
+
+	// manual changes drifting from the repository are undone on the next sync
+	_, err = reg.Put("types", "trade", json.RawMessage(`{"owner":"someone"}`), registry.Precondition{Version: 1}, "bob")
+	assert.NoError(t, err)
+	assert.NoError(t, syncer.Sync(ctx))
+	assert.Equal(t, 1, syncer.Status().LastChanges)
+	trade, _ := reg.Get("types", "trade")
+	assert.JSONEq(t, `{"owner":"trading"}`, string(trade.Spec))
+
+	// a commit failing validation is not applied at all, the registry stays on the last good commit
+	revision := reg.Revision()
+	broken := repo.commit(map[string]string{
+		"config/types/refund.yaml": "owner: refunds\nretention: 90\n",
+		"config/types/trade.yml":   "- not an object\n",
+	})
+	assert.Error(t, syncer.Sync(ctx))
+	status = syncer.Status()
+	assert.Equal(t, second, status.AppliedCommit)
+	assert.Equal(t, broken, status.LastCommit)
+	assert.NotEmpty(t, status.LastError)
+	assert.Equal(t, revision, reg.Revision())
+	refund, _ = reg.Get("types", "refund")
+	assert.JSONEq(t, `{"owner":"refunds","retention":60}`, string(refund.Spec))
+
+	fixed := repo.commit(map[string]string{"config/types/trade.yml": "owner: trading\n"})
+	assert.NoError(t, syncer.Sync(ctx))
+	status = syncer.Status()
+	assert.Equal(t, fixed, status.AppliedCommit)
+	assert.Empty(t, status.LastError)
+	refund, _ = reg.Get("types", "refund")
+	assert.JSONEq(t, `{"owner":"refunds","retention":90}`, string(refund.Spec))
+}
+
+func TestSyncDisabled(t *testing.T) {
+	syncer := gitops.New(gitops.Config{}, registry.New())
+	assert.False(t, syncer.Status().Enabled)
+	assert.ErrorIs(t, syncer.Sync(context.Background()), gitops.ErrDisabled)
+	assert.ErrorIs(t, syncer.Trigger(), gitops.ErrDisabled)
+}
@@ -0,0 +1,342 @@
+// Package grafana answers the requests of the grafana simple json datasource, so the dashboards chart the counts of
+// the entries matching filter expressions, list the latest ones in tables and annotate the graphs with them,
+// without an exporter in between.
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/tenants"
+)
+
+// ErrInvalidTarget is returned for the targets and the filters that are not valid expressions
+var ErrInvalidTarget = errors.New(constants.InvalidGrafanaTargetError)
+
+// Range is the time range of the dashboard
+type Range struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Target is a query of a panel, its target is a filter expression of the entries
+type Target struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId"`
+	// Type is timeserie for the counts of the entries by interval, table for the latest entries
+	Type string `json:"type"`
+	// Data are the options of the target, as the additional json data of the query editor
+	Data TargetData `json:"data"`
+}
+
+// TargetData are the options of a target
+type TargetData struct {
+	// GroupBy is the field the counts are split by, one series by value, e.g. level
+	GroupBy string `json:"groupBy"`
+	// Syntax is the syntax of the target, the filter language by default, logql or lucene
+	Syntax string `json:"syntax"`
+}
+
+// AdhocFilter is a filter of the dashboard applying to every target
+type AdhocFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// QueryRequest is the request of the panels of a dashboard
+type QueryRequest struct {
+	Range         Range         `json:"range"`
+	IntervalMs    int64         `json:"intervalMs"`
+	MaxDataPoints int           `json:"maxDataPoints"`
+	Targets       []Target      `json:"targets"`
+	AdhocFilters  []AdhocFilter `json:"adhocFilters"`
+}
+
+// Series is the counts of the entries of a target, the datapoints are the count and the start of the interval in
+// milliseconds
+type Series struct {
+	Target     string       `json:"target"`
+	RefID      string       `json:"refId,omitempty"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// Table is the latest entries of a target
+type Table struct {
+	Type    string          `json:"type"`
+	RefID   string          `json:"refId,omitempty"`
+	Columns []Column        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// Column is a column of a table
+type Column struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+// AnnotationRequest is the request of an annotation of a dashboard, its query is a filter expression of the entries
+type AnnotationRequest struct {
+	Range      Range `json:"range"`
+	Annotation struct {
+		Name   string `json:"name"`
+		Query  string `json:"query"`
+		Enable bool   `json:"enable"`
+	} `json:"annotation"`
+}
+
+// Annotation is an entry marked on the graphs
+type Annotation struct {
+	// Annotation is the annotation of the request, as grafana expects it back
+	Annotation interface{} `json:"annotation"`
+	Time       int64       `json:"time"`
+	Title      string      `json:"title"`
+	Text       string      `json:"text"`
+	Tags       []string    `json:"tags"`
+}
+
+// TagKey is a field the dashboards can filter on
+type TagKey struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// TagValue is a value of a tag key
+type TagValue struct {
+	Text string `json:"text"`
+}
+
+// adhocOperators are the operators of the filter language of the operators of grafana
+var adhocOperators = map[string]string{"=": "=", "!=": "!=", "<": "<", ">": ">", "=~": "~", "!~": "!~"}
+
+// Search is used to get the targets suggested in the query editor, a filter of every type stored containing the
+// text typed
+func Search(ctx context.Context, text string) ([]string, error) {
+	stats, err := store.Get().Stats(ctx, store.Query{Tenant: tenants.Scope(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	targets := []string{}
+	for logType := range stats.ByType {
+		target := fmt.Sprintf("%s = %s", constants.TypeField, strconv.Quote(logType))
+		if strings.Contains(target, text) {
+			targets = append(targets, target)
+		}
+	}
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// Query is used to answer the targets of the request, with a Series or a Table for each of them in their order
+func Query(ctx context.Context, request QueryRequest) ([]interface{}, error) {
+	results := []interface{}{}
+	for _, target := range request.Targets {
+		f, err := parse(target.Target, target.Data.Syntax, request.AdhocFilters)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s : %s", ErrInvalidTarget, target.RefID, err)
+		}
+		if target.Type == constants.GrafanaTableType {
+			table, err := latest(ctx, target, f, request)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, table)
+			continue
+		}
+		series, err := count(ctx, target, f, request)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range series {
+			results = append(results, s)
+		}
+	}
+	return results, nil
+}
+
+// Annotations is used to get the entries of the annotation query in the range, the latest ones first
+func Annotations(ctx context.Context, request AnnotationRequest) ([]Annotation, error) {
+	f, err := parse(request.Annotation.Query, "", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s : %s", ErrInvalidTarget, request.Annotation.Name, err)
+	}
+	records, err := store.Get().Query(ctx, store.Query{Filter: f, Tenant: tenants.Scope(ctx), Axis: store.EventTime,
+		From: request.Range.From, To: request.Range.To, Limit: constants.MaxGrafanaAnnotations})
+	if err != nil {
+		return nil, err
+	}
+	annotations := make([]Annotation, 0, len(records))
+	for _, record := range records {
+		entry := record.Entry
+		tags := []string{entry.Type}
+		if entry.Level != "" {
+			tags = append(tags, entry.Level)
+		}
+		annotations = append(annotations, Annotation{Annotation: request.Annotation,
+			Time: record.EventTime().UnixMilli(), Title: entry.Type, Text: message(record), Tags: tags})
+	}
+	return annotations, nil
+}
+
+// TagKeys is used to get the fields the dashboards can filter on
+func TagKeys() []TagKey {
+	return []TagKey{{Type: "string", Text: constants.TypeField}, {Type: "string", Text: constants.LevelField}}
+}
+
+// TagValues is used to get the values of the field among the latest entries
+func TagValues(ctx context.Context, key string) ([]TagValue, error) {
+	records, err := store.Get().Query(ctx, store.Query{Tenant: tenants.Scope(ctx), Limit: constants.MaxQueryLimit})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	values := []TagValue{}
+	for _, record := range records {
+		value, ok := filter.Lookup(record.Entry, key)
+		if !ok || seen[fmt.Sprint(value)] {
+			continue
+		}
+		seen[fmt.Sprint(value)] = true
+		values = append(values, TagValue{Text: fmt.Sprint(value)})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Text < values[j].Text
+	})
+	return values, nil
+}
+
+// count is used to get the counts of the entries of the target by interval, one series for each value of the
+// field it is grouped by. Every interval of the range has a datapoint, so the graphs show the intervals without
+// entries as zero.
+func count(ctx context.Context, target Target, f *filter.Filter, request QueryRequest) ([]Series, error) {
+	from, to := request.Range.From, request.Range.To
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w %s : the range has to end after it starts", ErrInvalidTarget, target.RefID)
+	}
+	interval := interval(request)
+	start := from.UnixMilli() / interval * interval
+	buckets := int((to.UnixMilli()-start)/interval) + 1
+	records, err := store.Get().Query(ctx, store.Query{Filter: f, Tenant: tenants.Scope(ctx), Axis: store.EventTime,
+		From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string][]float64)
+	for _, record := range records {
+		group := ""
+		if target.Data.GroupBy != "" {
+			group = constants.GrafanaNoneGroup
+			if value, ok := filter.Lookup(record.Entry, target.Data.GroupBy); ok {
+				group = fmt.Sprint(value)
+			}
+		}
+		bucket := int((record.EventTime().UnixMilli() - start) / interval)
+		if bucket < 0 || bucket >= buckets {
+			continue
+		}
+		if counts[group] == nil {
+			counts[group] = make([]float64, buckets)
+		}
+		counts[group][bucket]++
+	}
+	if target.Data.GroupBy == "" && counts[""] == nil {
+		counts[""] = make([]float64, buckets)
+	}
+	groups := make([]string, 0, len(counts))
+	for group := range counts {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	series := make([]Series, 0, len(groups))
+	for _, group := range groups {
+		name := target.Target
+		if group != "" {
+			name = group
+		} else if name == "" {
+			name = constants.GrafanaCountSeries
+		}
+		s := Series{Target: name, RefID: target.RefID, Datapoints: make([][2]float64, buckets)}
+		for i, c := range counts[group] {
+			s.Datapoints[i] = [2]float64{c, float64(start + int64(i)*interval)}
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
+// latest is used to get the latest entries of the target in the range as a table
+func latest(ctx context.Context, target Target, f *filter.Filter, request QueryRequest) (Table, error) {
+	limit := request.MaxDataPoints
+	if limit <= 0 || limit > constants.MaxQueryLimit {
+		limit = constants.DefaultQueryLimit
+	}
+	records, err := store.Get().Query(ctx, store.Query{Filter: f, Tenant: tenants.Scope(ctx), Axis: store.EventTime,
+		From: request.Range.From, To: request.Range.To, Limit: limit})
+	if err != nil {
+		return Table{}, err
+	}
+	table := Table{Type: constants.GrafanaTableType, RefID: target.RefID, Columns: []Column{
+		{Text: "Time", Type: "time"}, {Text: "Type", Type: "string"}, {Text: "Level", Type: "string"},
+		{Text: "Message", Type: "string"},
+	}, Rows: make([][]interface{}, 0, len(records))}
+	for _, record := range records {
+		table.Rows = append(table.Rows, []interface{}{record.EventTime().UnixMilli(), record.Entry.Type,
+			record.Entry.Level, message(record)})
+	}
+	return table, nil
+}
+
+// interval is used to get the interval of the datapoints in milliseconds, the one of the request as long as the
+// range does not have too many of them
+func interval(request QueryRequest) int64 {
+	span := request.Range.To.Sub(request.Range.From).Milliseconds()
+	interval := request.IntervalMs
+	if interval <= 0 && request.MaxDataPoints > 0 {
+		interval = span / int64(request.MaxDataPoints)
+	}
+	if interval < constants.MinGrafanaIntervalInMillis {
+		interval = constants.MinGrafanaIntervalInMillis
+	}
+	if minimum := span / constants.MaxGrafanaDataPoints; interval < minimum {
+		interval = minimum
+	}
+	return interval
+}
+
+// parse is used to parse the target in the syntax along with the filters of the dashboard
+func parse(target, syntax string, adhoc []AdhocFilter) (*filter.Filter, error) {
+	expression, err := filter.Translate(syntax, target)
+	if err != nil {
+		return nil, err
+	}
+	var parts []string
+	if strings.TrimSpace(expression) != "" {
+		parts = append(parts, "("+expression+")")
+	}
+	for _, a := range adhoc {
+		operator, ok := adhocOperators[a.Operator]
+		if !ok || a.Key == "" {
+			return nil, fmt.Errorf("unsupported filter %s %s", a.Key, a.Operator)
+		}
+		parts = append(parts, fmt.Sprintf("%s %s %s", a.Key, operator, strconv.Quote(a.Value)))
+	}
+	return filter.Parse(strings.Join(parts, " and "))
+}
+
+// message is used to get the message of the entry, or its data when it has none
+func message(record store.Record) string {
+	if message, ok := record.Entry.Data[constants.MessageField]; ok {
+		return fmt.Sprint(message)
+	}
+	data, _ := json.Marshal(record.Entry.Data)
+	return string(data)
+}
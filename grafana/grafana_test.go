@@ -0,0 +1,91 @@
+package grafana_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/grafana"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	ctx := context.Background()
+	s := store.NewMemory(100)
+	store.Init(s)
+	start := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	add := func(logType, level string, after time.Duration, message string) {
+		at := models.Timestamp{Time: start.Add(after)}
+		_, err := s.Add(ctx, models.LogEntry{Type: logType, Level: level, Timestamp: &at,
+			Data: map[string]interface{}{"message": message}})
+		assert.NoError(t, err)
+	}
+	add("payment", "error", 10*time.Second, "card declined")
+	add("payment", "info", 20*time.Second, "paid")
+	add("payment", "error", 70*time.Second, "card declined")
+	add("order", "info", 80*time.Second, "placed")
+
+	targets, err := grafana.Search(ctx, "pay")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`type = "payment"`}, targets)
+	// the dashboards of a tenant only see its entries
+	targets, err = grafana.Search(tenants.WithScope(ctx, "acme"), "pay")
+	assert.NoError(t, err)
+	assert.Empty(t, targets)
+
+	request := grafana.QueryRequest{Range: grafana.Range{From: start, To: start.Add(3 * time.Minute)},
+		IntervalMs: 60000, Targets: []grafana.Target{
+			{RefID: "A", Target: "type = payment"},
+			{RefID: "B", Target: "type = payment", Data: grafana.TargetData{GroupBy: "level"}},
+			{RefID: "C", Type: "table", Target: `{type="payment"} |= "declined"`,
+				Data: grafana.TargetData{Syntax: "logql"}},
+		}}
+	results, err := grafana.Query(ctx, request)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 4) {
+		minute := float64(start.UnixMilli())
+		assert.Equal(t, grafana.Series{Target: "type = payment", RefID: "A", Datapoints: [][2]float64{
+			{2, minute}, {1, minute + 60000}, {0, minute + 120000}, {0, minute + 180000}}}, results[0])
+		assert.Equal(t, "error", results[1].(grafana.Series).Target)
+		assert.Equal(t, [2]float64{1, minute + 60000}, results[1].(grafana.Series).Datapoints[1])
+		assert.Equal(t, "info", results[2].(grafana.Series).Target)
+		table := results[3].(grafana.Table)
+		assert.Equal(t, "C", table.RefID)
+		if assert.Len(t, table.Rows, 2) {
+			assert.Equal(t, []interface{}{start.Add(70 * time.Second).UnixMilli(), "payment", "error",
+				"card declined"}, table.Rows[0])
+		}
+	}
+
+	// the ad hoc filters of the dashboard apply to every target
+	request.Targets = request.Targets[:1]
+	request.AdhocFilters = []grafana.AdhocFilter{{Key: "level", Operator: "=~", Value: "err.*"}}
+	results, err = grafana.Query(ctx, request)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, [2]float64{1, float64(start.UnixMilli())}, results[0].(grafana.Series).Datapoints[0])
+	}
+
+	request.Targets = []grafana.Target{{RefID: "A", Target: "level ="}}
+	_, err = grafana.Query(ctx, request)
+	assert.True(t, errors.Is(err, grafana.ErrInvalidTarget))
+
+	var annotations grafana.AnnotationRequest
+	annotations.Range = request.Range
+	annotations.Annotation.Query = "level = error"
+	marked, err := grafana.Annotations(ctx, annotations)
+	assert.NoError(t, err)
+	if assert.Len(t, marked, 2) {
+		assert.Equal(t, start.Add(70*time.Second).UnixMilli(), marked[0].Time)
+		assert.Equal(t, "card declined", marked[0].Text)
+		assert.Equal(t, []string{"payment", "error"}, marked[0].Tags)
+	}
+
+	values, err := grafana.TagValues(ctx, "level")
+	assert.NoError(t, err)
+	assert.Equal(t, []grafana.TagValue{{Text: "error"}, {Text: "info"}}, values)
+}
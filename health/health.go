@@ -0,0 +1,184 @@
+// Package health tells whether the process is alive and whether it is ready to take entries, checking the
+// redis of every component using one, the sinks and the buffers, so that probes do not rely on the actuator.
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/dedup"
+	"github.com/angel-one/nbu-logger-service/dlq"
+	"github.com/angel-one/nbu-logger-service/queue"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/wal"
+)
+
+// Config is the configuration of the readiness checks
+type Config struct {
+	// TimeoutInMillis is the time a dependency has to answer, it is down otherwise
+	TimeoutInMillis int `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+	// MaxBufferRatio is the fill of the buffer of a sink or of the write ahead log from which the process is not
+	// ready, so that the traffic goes to the other instances before entries are dropped or rejected
+	MaxBufferRatio float64 `json:"maxBufferRatio" mapstructure:"maxBufferRatio"`
+	// MaxSinkFailures are the consecutive failures of a sink from which it is down
+	MaxSinkFailures int64 `json:"maxSinkFailures" mapstructure:"maxSinkFailures"`
+}
+
+// Check is the state of a dependency
+type Check struct {
+	Status           string      `json:"status"`
+	Error            string      `json:"error,omitempty"`
+	Details          interface{} `json:"details,omitempty"`
+	DurationInMillis int64       `json:"durationInMillis"`
+}
+
+// Report is the state of the process and of its dependencies, it is down when any of them is
+type Report struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks,omitempty"`
+}
+
+// Pinger is implemented by the stores in a redis, to check that it is reachable
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// checker is used to check a dependency and get its details
+type checker func(ctx context.Context) (interface{}, error)
+
+var config = Config{
+	TimeoutInMillis: constants.DefaultHealthTimeoutInMillis,
+	MaxBufferRatio:  constants.DefaultMaxBufferRatio,
+	MaxSinkFailures: constants.DefaultMaxSinkFailures,
+}
+
+// Init is used to set up the readiness checks
+func Init(c Config) error {
+	if c.TimeoutInMillis <= 0 {
+		c.TimeoutInMillis = constants.DefaultHealthTimeoutInMillis
+	}
+	if c.MaxBufferRatio == 0 {
+		c.MaxBufferRatio = constants.DefaultMaxBufferRatio
+	}
+	if c.MaxBufferRatio < 0 || c.MaxBufferRatio > 1 {
+		return fmt.Errorf("max buffer ratio %v has to be between 0 and 1", c.MaxBufferRatio)
+	}
+	if c.MaxSinkFailures <= 0 {
+		c.MaxSinkFailures = constants.DefaultMaxSinkFailures
+	}
+	config = c
+	return nil
+}
+
+// Live is used to get the liveness of the process, it is up as long as it can answer
+func Live() Report {
+	return Report{Status: constants.UpStatus}
+}
+
+// Ready is used to check the dependencies of the process concurrently and get their states
+func Ready(ctx context.Context) Report {
+	checkers := checkers()
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.TimeoutInMillis)*time.Millisecond)
+	defer cancel()
+	report := Report{Status: constants.UpStatus, Checks: make(map[string]Check, len(checkers))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checkers {
+		wg.Add(1)
+		go func(name string, check checker) {
+			defer wg.Done()
+			result := run(ctx, check)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != constants.UpStatus {
+				report.Status = constants.DownStatus
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// run is used to check a dependency, it is down when it does not answer before the context is done
+func run(ctx context.Context, check checker) Check {
+	start := time.Now()
+	type outcome struct {
+		details interface{}
+		err     error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		details, err := check(ctx)
+		done <- outcome{details: details, err: err}
+	}()
+	var o outcome
+	select {
+	case o = <-done:
+	case <-ctx.Done():
+		o.err = fmt.Errorf("no answer in %dms", config.TimeoutInMillis)
+	}
+	result := Check{Status: constants.UpStatus, Details: o.details, DurationInMillis: time.Since(start).Milliseconds()}
+	if o.err != nil {
+		result.Status, result.Error = constants.DownStatus, o.err.Error()
+	}
+	return result
+}
+
+// checkers are used to get the checks of the dependencies in use, the components are looked up on every check
+// as they are replaced when their config is reloaded
+func checkers() map[string]checker {
+	checkers := map[string]checker{constants.SinksCheck: checkSinks}
+	for name, component := range map[string]interface{}{
+		constants.StoreCheck: store.Get(),
+		constants.DLQCheck:   dlq.Get(),
+		constants.DedupCheck: dedup.Get(),
+	} {
+		if pinger, ok := component.(Pinger); ok {
+			checkers[name] = ping(pinger.Ping)
+		}
+	}
+	if queue.Enabled() {
+		checkers[constants.QueueCheck] = ping(queue.Ping)
+	}
+	if wal.Enabled() {
+		checkers[constants.WALCheck] = checkWAL
+	}
+	return checkers
+}
+
+// ping is used to check that a redis is reachable
+func ping(ping func(ctx context.Context) error) checker {
+	return func(ctx context.Context) (interface{}, error) {
+		return nil, ping(ctx)
+	}
+}
+
+// checkSinks is used to check that no sink keeps failing or has its buffer about full
+func checkSinks(context.Context) (interface{}, error) {
+	health := sinks.Health()
+	for _, sink := range health {
+		if sink.ConsecutiveFailures >= config.MaxSinkFailures {
+			return health, fmt.Errorf("sink %s failed %d times in a row : %s", sink.Name,
+				sink.ConsecutiveFailures, sink.LastError)
+		}
+		if sink.Capacity > 0 && float64(sink.Queued) >= config.MaxBufferRatio*float64(sink.Capacity) {
+			return health, fmt.Errorf("buffer of sink %s has %d of %d entries", sink.Name, sink.Queued,
+				sink.Capacity)
+		}
+	}
+	return health, nil
+}
+
+// checkWAL is used to check that the write ahead log is not about full
+func checkWAL(context.Context) (interface{}, error) {
+	stats := wal.GetStats()
+	if stats.MaxSizeInBytes > 0 && float64(stats.SizeInBytes) >= config.MaxBufferRatio*float64(stats.MaxSizeInBytes) {
+		return stats, fmt.Errorf("write ahead log has %d of %d bytes", stats.SizeInBytes, stats.MaxSizeInBytes)
+	}
+	return stats, nil
+}
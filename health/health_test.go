@@ -0,0 +1,65 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/dlq"
+	"github.com/angel-one/nbu-logger-service/health"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingSink struct{}
+
+func (failingSink) Name() string {
+	return "failing"
+}
+
+func (failingSink) Send(context.Context, models.LogEntry) error {
+	return errors.New("connection refused")
+}
+
+func TestReady(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, health.Init(health.Config{TimeoutInMillis: 500, MaxSinkFailures: 3}))
+	assert.Equal(t, "up", health.Live().Status)
+
+	report := health.Ready(ctx)
+	assert.Equal(t, "up", report.Status)
+	assert.Equal(t, []string{"sinks"}, keys(report.Checks))
+
+	sinks.Add(ctx, failingSink{}, 10)
+	for i := 0; i < 3; i++ {
+		sinks.Emit(ctx, models.LogEntry{Type: "app"})
+	}
+	assert.Eventually(t, func() bool {
+		return health.Ready(ctx).Status == "down"
+	}, 5*time.Second, 10*time.Millisecond)
+	check := health.Ready(ctx).Checks["sinks"]
+	assert.Equal(t, "sink failing failed 3 times in a row : connection refused", check.Error)
+
+	// the redis of the dead letters is not reachable
+	assert.NoError(t, dlq.Init(dlq.Config{RedisURL: "redis://127.0.0.1:1"}))
+	report = health.Ready(ctx)
+	assert.Equal(t, "down", report.Status)
+	assert.Equal(t, []string{"dlq", "sinks"}, keys(report.Checks))
+	assert.Equal(t, "down", report.Checks["dlq"].Status)
+	assert.NotEmpty(t, report.Checks["dlq"].Error)
+
+	assert.Error(t, health.Init(health.Config{MaxBufferRatio: 2}))
+}
+
+func keys(checks map[string]health.Check) []string {
+	names := make([]string, 0, len(checks))
+	for _, name := range []string{"dedup", "dlq", "queue", "sinks", "store", "wal"} {
+		if _, ok := checks[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
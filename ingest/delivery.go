@@ -0,0 +1,40 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+// ErrNotDurable is returned when an entry asking for a durable acknowledgement could not be made durable, it was
+// not accepted and the producer has to send it again
+var ErrNotDurable = errors.New("entry could not be made durable")
+
+// ErrNotDelivered is returned when an entry that is not best effort could not be delivered without a write ahead
+// log, it was not accepted and the producer has to send it again
+var ErrNotDelivered = errors.New("entry could not be delivered")
+
+type deliveryClassKey struct{}
+
+// ValidDeliveryClass is used to check whether the delivery class is one the producers can ask for
+func ValidDeliveryClass(class string) bool {
+	switch class {
+	case constants.BestEffortDelivery, constants.AtLeastOnceDelivery, constants.DurableAckDelivery:
+		return true
+	}
+	return false
+}
+
+// WithDeliveryClass is used to get a context whose entries are handled with the delivery class
+func WithDeliveryClass(ctx context.Context, class string) context.Context {
+	return context.WithValue(ctx, deliveryClassKey{}, class)
+}
+
+// DeliveryClass is used to get the delivery class of the entries of the context, at-least-once by default
+func DeliveryClass(ctx context.Context) string {
+	if class, ok := ctx.Value(deliveryClassKey{}).(string); ok {
+		return class
+	}
+	return constants.AtLeastOnceDelivery
+}
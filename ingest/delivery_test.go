@@ -0,0 +1,59 @@
+package ingest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/wal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryClass(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "at-least-once", ingest.DeliveryClass(ctx))
+	assert.True(t, ingest.ValidDeliveryClass("durable-ack"))
+	assert.False(t, ingest.ValidDeliveryClass("exactly-once"))
+
+	delivered := make(chan models.LogEntry, 10)
+	assert.NoError(t, wal.Init(ctx, wal.Config{Dir: t.TempDir(), SyncIntervalInMillis: 60000},
+		func(_ context.Context, entry models.LogEntry) error {
+			delivered <- entry
+			return nil
+		}))
+	defer wal.Stop()
+
+	// debug entries skip the write ahead log
+	assert.NoError(t, ingest.Entry(ingest.WithDeliveryClass(ctx, "best-effort"), &models.LogEntry{Type: "debug"}))
+	assert.Equal(t, int64(0), wal.GetStats().Appended)
+	assert.NoError(t, ingest.Entry(ctx, &models.LogEntry{Type: "app"}))
+	assert.NoError(t, ingest.Entry(ingest.WithDeliveryClass(ctx, "durable-ack"), &models.LogEntry{Type: "audit"}))
+	assert.Equal(t, int64(2), wal.GetStats().Appended)
+	assert.Equal(t, "app", (<-delivered).Type)
+	assert.Equal(t, "audit", (<-delivered).Type)
+}
+
+// failingStore is a store failing to add the entries
+type failingStore struct {
+	store.Store
+}
+
+func (failingStore) Add(context.Context, models.LogEntry) (store.Record, error) {
+	return store.Record{}, errors.New("store unavailable")
+}
+
+func TestDeliveryFailure(t *testing.T) {
+	ctx := context.Background()
+	previous := store.Get()
+	store.Init(failingStore{Store: previous})
+	defer store.Init(previous)
+
+	// without a write ahead log only the best effort entries are acknowledged when they cannot be delivered
+	assert.NoError(t, ingest.Entry(ingest.WithDeliveryClass(ctx, "best-effort"), &models.LogEntry{Type: "debug"}))
+	assert.ErrorIs(t, ingest.Entry(ctx, &models.LogEntry{Type: "app"}), ingest.ErrNotDelivered)
+	assert.ErrorIs(t, ingest.Entry(ingest.WithDeliveryClass(ctx, "durable-ack"), &models.LogEntry{Type: "audit"}),
+		ingest.ErrNotDurable)
+}
@@ -0,0 +1,220 @@
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/alerts"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/dedup"
+	"github.com/angel-one/nbu-logger-service/encryption"
+	"github.com/angel-one/nbu-logger-service/escalation"
+	"github.com/angel-one/nbu-logger-service/lifecycle"
+	"github.com/angel-one/nbu-logger-service/limits"
+	"github.com/angel-one/nbu-logger-service/metrics"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/queue"
+	"github.com/angel-one/nbu-logger-service/rollup"
+	"github.com/angel-one/nbu-logger-service/schemas"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/angel-one/nbu-logger-service/stats"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/angel-one/nbu-logger-service/tracing"
+	"github.com/angel-one/nbu-logger-service/violations"
+	"github.com/angel-one/nbu-logger-service/wal"
+	"github.com/rs/zerolog"
+)
+
+// Entry is used to run the entry through the pipeline and emit it, through the queue when there is one.
+// A returned error means the entry was rejected by the pipeline, entries below the minimum level are dropped.
+func Entry(ctx context.Context, entry *models.LogEntry) error {
+	// Correlate the entry with the trace of the request that carried it, the ingestion is traced along with it
+	tracing.Annotate(ctx, entry)
+	if _, ok := tracing.FromContext(ctx); !ok {
+		return process(ctx, entry)
+	}
+	ctx, span := tracing.Start(ctx, constants.IngestSpanName, tracing.KindInternal)
+	span.SetAttribute(constants.LogTypeAttribute, entry.Type)
+	err := process(ctx, entry)
+	span.End(err)
+	return err
+}
+
+// process is used to run the entry through the pipeline and emit it
+func process(ctx context.Context, entry *models.LogEntry) error {
+	// Count the offered entries, the sampling is escalated when more of them are ingested than the SLOs allow
+	escalation.Get().Offered()
+	// Order the entry by the time it was logged, buffered clients send entries well after logging them
+	if err := Stamp(entry, time.Now()); err != nil {
+		return rejected(ctx, *entry, err)
+	}
+	// Reject the entries too large to process safely
+	if err := limits.Get().Check(*entry); err != nil {
+		return err
+	}
+	// Reject the plaintext entries of the types that must only be stored encrypted
+	if err := encryption.Get().Check(*entry); err != nil {
+		return err
+	}
+	// Reject data that does not match the schema of the type before it is processed, the data of encrypted
+	// entries is only known to the readers of their key
+	if entry.Encrypted == nil {
+		if err := schemas.Validate(*entry); err != nil {
+			lifecycle.SchemaViolation(ctx, entry.Type, time.Now())
+			return rejected(ctx, *entry, err)
+		}
+	}
+	// Run the entry through the processing pipeline
+	if err := pipeline.Get().Process(ctx, entry); errors.Is(err, pipeline.ErrBelowMinLevel) ||
+		errors.Is(err, pipeline.ErrSampledOut) {
+		return nil
+	} else if err != nil {
+		return rejected(ctx, *entry, err)
+	}
+	// Sample the low priority types harder while the ingestion is under pressure
+	if !escalation.Get().Keep(ctx, *entry) {
+		return nil
+	}
+	// Tag the entry with the tenant the request was resolved to and count it against the quotas of the tenant
+	tenant, ok := tenants.FromContext(ctx)
+	if ok {
+		if err := tenants.Consume(ctx, tenant, time.Now()); err != nil {
+			if errors.Is(err, tenants.ErrQuotaExceeded) {
+				lifecycle.QuotaExceeded(ctx, tenant, err)
+			}
+			return err
+		}
+		pipeline.SetMeta(entry, constants.MetaTenantKey, tenant)
+	}
+	// Tag the entry with the region that accepted it, so the regions of an active-active deployment can be told apart
+	if region := dedup.Region(); region != "" {
+		pipeline.SetMeta(entry, constants.MetaRegionKey, region)
+	}
+	stats.Get().Accepted(tenant)
+	return accept(ctx, *entry)
+}
+
+// accept is used to hand on the processed entry as its delivery class asks. Best effort entries take the fast
+// path and are delivered right away, at least once entries are acknowledged once they are in the write ahead log
+// or else delivered, and durable entries once they are synced to the disk or else delivered. Only the best effort
+// entries are acknowledged when their delivery fails.
+func accept(ctx context.Context, entry models.LogEntry) error {
+	class := DeliveryClass(ctx)
+	if class == constants.DurableAckDelivery {
+		var err error
+		if wal.Enabled() {
+			err = wal.AppendDurable(ctx, entry)
+		} else {
+			err = Deliver(ctx, entry)
+		}
+		if err != nil && !errors.Is(err, wal.ErrFull) {
+			return fmt.Errorf("%w : %v", ErrNotDurable, err)
+		}
+		return err
+	}
+	if class != constants.BestEffortDelivery {
+		if wal.Enabled() {
+			// the entry is acknowledged once it is on the local disk, it is delivered from there
+			return wal.Append(ctx, entry)
+		}
+		if err := Deliver(ctx, entry); err != nil {
+			return fmt.Errorf("%w : %v", ErrNotDelivered, err)
+		}
+		return nil
+	}
+	if err := Deliver(ctx, entry); err != nil {
+		log.Error(ctx).Err(err).Msg("error storing log entry")
+	}
+	return nil
+}
+
+// Deliver is used to hand the processed entry to the queue, or to persist it right away when there is none.
+// The write ahead log delivers its entries again when it fails.
+func Deliver(ctx context.Context, entry models.LogEntry) error {
+	if _, ok := tenants.FromContext(ctx); !ok {
+		// the entries replayed from the write ahead log only carry their tenant in the meta
+		if tenant, ok := pipeline.GetMeta(&entry, constants.MetaTenantKey); ok {
+			if id, ok := tenant.(string); ok {
+				ctx = tenants.WithTenant(ctx, id)
+			}
+		}
+	}
+	if queue.Enabled() {
+		err := queue.Enqueue(ctx, entry)
+		if err == nil {
+			return nil
+		}
+		// the entry is accepted, so it is persisted right away rather than lost
+		log.Warn(ctx).Err(err).Msg("error enqueueing log entry, persisting it synchronously")
+	}
+	if err := Persist(ctx, entry); err != nil {
+		escalation.Get().Failed()
+		return err
+	}
+	return nil
+}
+
+// Persist is used to store, emit and log an entry that went through the pipeline.
+// Nothing is emitted when storing fails, so that a retry does not emit the entry twice.
+func Persist(ctx context.Context, entry models.LogEntry) error {
+	// Keep the entry queryable
+	record, err := store.Get().Add(ctx, entry)
+	if err != nil {
+		return err
+	}
+	// Count the entry in the bucket of the time it was logged
+	rollup.Get().Add(entry, record.IngestedAt)
+	// Announce the types stored for the first time
+	lifecycle.TypeSeen(ctx, entry.Type)
+	// Emit the entry to the configured sinks
+	sinks.Emit(ctx, entry)
+	// Alert on the entry when it matches the alert rules
+	alerts.Evaluate(ctx, entry)
+	// Derive the metrics of the rules the entry matches
+	metrics.Get().Observe(entry)
+	messageJson, _ := json.Marshal(entry)
+	// the flag tells the records of entries apart from the logs of the service itself
+	event(ctx, entry.Level).Bool(constants.IngestedKey, true).Msg(string(messageJson))
+	return nil
+}
+
+// rejected is used to count the entry rejected by validation against the constraint it violated
+func rejected(ctx context.Context, entry models.LogEntry, err error) error {
+	var invalid *schemas.ValidationError
+	var stage *pipeline.StageError
+	switch {
+	case errors.As(err, &invalid):
+		seen := make(map[string]bool, len(invalid.Fields))
+		for _, field := range invalid.Fields {
+			if !seen[field.Constraint] {
+				seen[field.Constraint] = true
+				violations.Get().Record(ctx, entry.Type, constants.SchemaConstraintPrefix+field.Constraint, err)
+			}
+		}
+	case errors.As(err, &stage):
+		violations.Get().Record(ctx, entry.Type, constants.PipelineConstraintPrefix+stage.Stage, err)
+	case errors.Is(err, ErrFutureTimestamp):
+		violations.Get().Record(ctx, entry.Type, constants.TimestampConstraint, err)
+	}
+	return err
+}
+
+// event is used to get the log event of the level, fatal entries are logged as errors as they must not exit
+func event(ctx context.Context, level string) *zerolog.Event {
+	switch level {
+	case constants.DebugLevel:
+		return log.Debug(ctx)
+	case constants.WarnLevel:
+		return log.Warn(ctx)
+	case constants.ErrorLevel, constants.FatalLevel:
+		return log.Error(ctx)
+	default:
+		return log.Info(ctx)
+	}
+}
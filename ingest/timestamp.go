@@ -0,0 +1,38 @@
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// ErrFutureTimestamp is returned for entries logged further in the future than any clock skew explains
+var ErrFutureTimestamp = errors.New(constants.FutureTimestampError)
+
+// Stamp is used to give the entry the time it was logged. Without a timestamp the time field of the data is used,
+// e.g. the one the formats map, and the time it was received without either. Timestamps ahead of the server
+// are clamped to the time it was received, the one the client sent is kept alongside.
+func Stamp(entry *models.LogEntry, receivedAt time.Time) error {
+	if entry.Timestamp == nil {
+		at := models.Timestamp{Time: receivedAt}
+		if value, ok := entry.Data[constants.TimeField]; ok {
+			if parsed, err := models.ParseTimestamp(value); err == nil {
+				at = parsed
+			}
+		}
+		entry.Timestamp = &at
+	}
+	if !entry.Timestamp.After(receivedAt) {
+		return nil
+	}
+	if entry.Timestamp.Sub(receivedAt) > constants.MaxClockSkewInHours*time.Hour {
+		return fmt.Errorf("%w : %s is more than %d hours ahead", ErrFutureTimestamp,
+			entry.Timestamp.UTC().Format(time.RFC3339Nano), constants.MaxClockSkewInHours)
+	}
+	entry.ClientTimestamp = entry.Timestamp
+	entry.Timestamp = &models.Timestamp{Time: receivedAt}
+	return nil
+}
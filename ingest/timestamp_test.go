@@ -0,0 +1,40 @@
+package ingest_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStamp(t *testing.T) {
+	receivedAt := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+
+	var entry models.LogEntry
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"a","timestamp":1709629200000}`), &entry))
+	assert.NoError(t, ingest.Stamp(&entry, receivedAt))
+	assert.True(t, entry.Timestamp.Equal(receivedAt.Add(-time.Hour)))
+	assert.Nil(t, entry.ClientTimestamp)
+
+	entry = models.LogEntry{Type: "a", Data: map[string]interface{}{"time": "2024-03-05T09:30:00Z"}}
+	assert.NoError(t, ingest.Stamp(&entry, receivedAt))
+	assert.True(t, entry.Timestamp.Equal(receivedAt.Add(-30*time.Minute)))
+
+	entry = models.LogEntry{Type: "a"}
+	assert.NoError(t, ingest.Stamp(&entry, receivedAt))
+	assert.True(t, entry.Timestamp.Equal(receivedAt))
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"type":"a","timestamp":"2024-03-05T12:00:00Z"}`), &entry))
+	assert.NoError(t, ingest.Stamp(&entry, receivedAt))
+	assert.True(t, entry.Timestamp.Equal(receivedAt))
+	assert.True(t, entry.ClientTimestamp.Equal(receivedAt.Add(2*time.Hour)))
+	body, _ := json.Marshal(entry)
+	assert.Contains(t, string(body), `"clientTimestamp":"2024-03-05T12:00:00Z"`)
+
+	entry = models.LogEntry{Type: "a", Timestamp: &models.Timestamp{Time: receivedAt.Add(25 * time.Hour)}}
+	assert.True(t, errors.Is(ingest.Stamp(&entry, receivedAt), ingest.ErrFutureTimestamp))
+}
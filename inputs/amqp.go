@@ -0,0 +1,89 @@
+package inputs
+
+import (
+	"context"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/utils/amqp"
+)
+
+// AMQPConfig is the configuration of the optional rabbitmq consumer
+type AMQPConfig struct {
+	// URL is the amqp url of the broker, the consumer is disabled when empty
+	URL string `json:"-" mapstructure:"url"`
+	// Queue is the queue consumed from
+	Queue string `json:"queue" mapstructure:"queue"`
+	// Prefetch is the number of unacknowledged messages the broker delivers at once
+	Prefetch int `json:"prefetch" mapstructure:"prefetch"`
+	// Type is the type of the entries for messages that are not entries themselves
+	Type string `json:"type" mapstructure:"type"`
+}
+
+// AMQPConsumer ingests the messages of a rabbitmq queue. Messages are acked once ingested
+// and rejected without requeue when the pipeline rejects them, so that they are dead lettered if configured.
+type AMQPConsumer struct {
+	config AMQPConfig
+}
+
+// NewAMQPConsumer is used to create the consumer for the config
+func NewAMQPConsumer(config AMQPConfig) *AMQPConsumer {
+	if config.Prefetch <= 0 {
+		config.Prefetch = constants.DefaultAMQPPrefetch
+	}
+	if config.Type == "" {
+		config.Type = constants.DefaultAMQPEntryType
+	}
+	return &AMQPConsumer{config: config}
+}
+
+// Start is used to consume in the background until the context is done, reconnecting when the connection is lost
+func (a *AMQPConsumer) Start(ctx context.Context) {
+	go func() {
+		for ctx.Err() == nil {
+			if err := a.consume(ctx); err != nil && ctx.Err() == nil {
+				log.Warn(ctx).Err(err).Str(constants.QueueKey, a.config.Queue).Msg("error consuming amqp queue")
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(constants.AMQPRetryInSeconds * time.Second):
+			}
+		}
+	}()
+}
+
+func (a *AMQPConsumer) consume(ctx context.Context) error {
+	conn, err := amqp.Dial(ctx, a.config.URL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deliveries, err := conn.Consume(a.config.Queue, a.config.Prefetch)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-conn.Done():
+			return conn.Err()
+		case delivery := <-deliveries:
+			entry, err := decodeMessage(delivery.ContentType, delivery.Body, a.config.Type)
+			if err == nil {
+				err = ingest.Entry(ctx, &entry)
+			}
+			if err != nil {
+				log.Warn(ctx).Err(err).Str(constants.QueueKey, a.config.Queue).Msg("rejected amqp message")
+				err = conn.Nack(delivery.Tag, false)
+			} else {
+				err = conn.Ack(delivery.Tag)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package inputs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ber tags used by snmp
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berNull        = 0x05
+	berOID         = 0x06
+	berSequence    = 0x30
+	berIPAddress   = 0x40
+	berCounter32   = 0x41
+	berGauge32     = 0x42
+	berTimeTicks   = 0x43
+	berOpaque      = 0x44
+	berCounter64   = 0x46
+	berNoSuchObj   = 0x80
+	berNoSuchInst  = 0x81
+	berEndOfView   = 0x82
+)
+
+var errTruncated = errors.New("truncated ber value")
+
+// berValue is a decoded tag, length, value triple
+type berValue struct {
+	tag     byte
+	content []byte
+	// raw is the whole encoding including the tag and the length
+	raw []byte
+}
+
+// readBER is used to read the value at the start of data and get the rest
+func readBER(data []byte) (berValue, []byte, error) {
+	if len(data) < 2 {
+		return berValue{}, nil, errTruncated
+	}
+	tag := data[0]
+	length := int(data[1])
+	offset := 2
+	if length&0x80 != 0 {
+		octets := length & 0x7f
+		if octets == 0 || octets > 4 || len(data) < 2+octets {
+			return berValue{}, nil, fmt.Errorf("unsupported ber length")
+		}
+		length = 0
+		for _, b := range data[2 : 2+octets] {
+			length = length<<8 | int(b)
+		}
+		offset += octets
+	}
+	if length < 0 || len(data)-offset < length {
+		return berValue{}, nil, errTruncated
+	}
+	end := offset + length
+	return berValue{tag: tag, content: data[offset:end], raw: data[:end]}, data[end:], nil
+}
+
+// children is used to read all the values inside a constructed value
+func (v berValue) children() ([]berValue, error) {
+	var values []berValue
+	rest := v.content
+	for len(rest) > 0 {
+		child, next, err := readBER(rest)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, child)
+		rest = next
+	}
+	return values, nil
+}
+
+func (v berValue) int() (int64, error) {
+	if v.tag != berInteger || len(v.content) == 0 || len(v.content) > 8 {
+		return 0, fmt.Errorf("invalid ber integer")
+	}
+	n := int64(int8(v.content[0]))
+	for _, b := range v.content[1:] {
+		n = n<<8 | int64(b)
+	}
+	return n, nil
+}
+
+func (v berValue) uint() uint64 {
+	var n uint64
+	for _, b := range v.content {
+		n = n<<8 | uint64(b)
+	}
+	return n
+}
+
+func (v berValue) oid() (string, error) {
+	if v.tag != berOID || len(v.content) == 0 {
+		return "", fmt.Errorf("invalid ber object identifier")
+	}
+	first := int(v.content[0])
+	parts := []string{strconv.Itoa(first / 40), strconv.Itoa(first % 40)}
+	var n uint64
+	for _, b := range v.content[1:] {
+		n = n<<7 | uint64(b&0x7f)
+		if b&0x80 == 0 {
+			parts = append(parts, strconv.FormatUint(n, 10))
+			n = 0
+		}
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// value is used to convert a varbind value to a json friendly value
+func (v berValue) value() interface{} {
+	switch v.tag {
+	case berInteger:
+		n, _ := v.int()
+		return n
+	case berOctetString, berOpaque:
+		if printable(v.content) {
+			return string(v.content)
+		}
+		return fmt.Sprintf("%x", v.content)
+	case berOID:
+		oid, _ := v.oid()
+		return oid
+	case berIPAddress:
+		if len(v.content) == 4 {
+			return fmt.Sprintf("%d.%d.%d.%d", v.content[0], v.content[1], v.content[2], v.content[3])
+		}
+		return fmt.Sprintf("%x", v.content)
+	case berCounter32, berGauge32, berTimeTicks, berCounter64:
+		return v.uint()
+	default:
+		// null, noSuchObject, noSuchInstance and endOfMibView carry no value
+		return nil
+	}
+}
+
+func printable(content []byte) bool {
+	for _, r := range string(content) {
+		if r == 0xfffd || (r < 0x20 && r != '\n' && r != '\r' && r != '\t') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package inputs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/utils/files"
+)
+
+var (
+	// ErrUnknownCheckpoint is returned when resetting the checkpoint of an input or a source that is not running
+	ErrUnknownCheckpoint = errors.New("unknown input source")
+	// ErrInvalidPosition is returned when resetting a checkpoint to a position the input can not resume from
+	ErrInvalidPosition = errors.New("invalid position")
+)
+
+// Checkpoint is the position a source of a pull based input resumes from, the position of the last message
+// ingested. Checkpoints only move forward, out of order commits are ignored, unless they are reset deliberately.
+type Checkpoint struct {
+	Position    string    `json:"position"`
+	CommittedAt time.Time `json:"committedAt"`
+	// Generation counts the resets, the commits of the reads started before a reset are ignored
+	Generation int `json:"generation"`
+}
+
+// checkpointer is a pull based input that resumes its sources from checkpoints
+type checkpointer interface {
+	// checkpoints are used to get the checkpoints of the sources
+	checkpoints() map[string]Checkpoint
+	// reset is used to move the checkpoint of the source, to the position or else to the time
+	reset(source, position string, at time.Time, now time.Time) error
+}
+
+var (
+	checkpointersMu sync.RWMutex
+	checkpointers   = make(map[string]checkpointer)
+)
+
+// register is used to expose the checkpoints of the input to the admin apis
+func register(input string, c checkpointer) {
+	checkpointersMu.Lock()
+	defer checkpointersMu.Unlock()
+	checkpointers[input] = c
+}
+
+// Checkpoints is used to get the checkpoints of the sources of the running pull based inputs, by input
+func Checkpoints() map[string]map[string]Checkpoint {
+	checkpointersMu.RLock()
+	defer checkpointersMu.RUnlock()
+	all := make(map[string]map[string]Checkpoint, len(checkpointers))
+	for input, c := range checkpointers {
+		all[input] = c.checkpoints()
+	}
+	return all
+}
+
+// ResetCheckpoint is used to move the checkpoint of a source deliberately, back to replay its messages or ahead
+// to skip them. The source resumes from the position, or from the time when there is no position.
+func ResetCheckpoint(input, source, position string, at time.Time) error {
+	checkpointersMu.RLock()
+	c, ok := checkpointers[input]
+	checkpointersMu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w %s", ErrUnknownCheckpoint, input)
+	}
+	if position == "" && at.IsZero() {
+		return fmt.Errorf("%w: a position or a time is required", ErrInvalidPosition)
+	}
+	return c.reset(source, position, at, time.Now())
+}
+
+// checkpointStore keeps the checkpoints of the sources of an input in a file
+type checkpointStore struct {
+	path string
+	// compare orders two positions, it fails for invalid ones
+	compare   func(a, b string) (int, error)
+	mu        sync.Mutex
+	positions map[string]Checkpoint
+}
+
+// loadCheckpoints is used to read the checkpoints recorded in the file, there are none when it is missing or
+// the path is empty
+func loadCheckpoints(path string, compare func(a, b string) (int, error)) (*checkpointStore, error) {
+	s := &checkpointStore{path: path, compare: compare, positions: make(map[string]Checkpoint)}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &s.positions); err != nil {
+			return nil, fmt.Errorf("checkpoints %s : %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+// get is used to get the checkpoint of the source
+func (s *checkpointStore) get(source string) (Checkpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	checkpoint, ok := s.positions[source]
+	return checkpoint, ok
+}
+
+// commit is used to record the position of the source read in the generation, it is ignored when it is not
+// ahead of the checkpoint or the checkpoint was reset since
+func (s *checkpointStore) commit(source string, generation int, position string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.positions[source]
+	if ok && current.Generation != generation {
+		return nil
+	}
+	if ok {
+		if order, err := s.compare(position, current.Position); err != nil || order <= 0 {
+			return err
+		}
+	}
+	s.positions[source] = Checkpoint{Position: position, CommittedAt: now, Generation: generation}
+	return s.save()
+}
+
+// reset is used to move the checkpoint of the source to the position, in any direction
+func (s *checkpointStore) reset(source, position string, now time.Time) error {
+	if _, err := s.compare(position, position); err != nil {
+		return fmt.Errorf("%w %q : %s", ErrInvalidPosition, position, err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current := s.positions[source]
+	s.positions[source] = Checkpoint{Position: position, CommittedAt: now, Generation: current.Generation + 1}
+	return s.save()
+}
+
+// all is used to get a copy of the checkpoints
+func (s *checkpointStore) all() map[string]Checkpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all := make(map[string]Checkpoint, len(s.positions))
+	for source, checkpoint := range s.positions {
+		all[source] = checkpoint
+	}
+	return all
+}
+
+// save is used to write the checkpoints to the file, the checkpoints without one are only kept in memory
+func (s *checkpointStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.positions)
+	if err != nil {
+		return err
+	}
+	return files.WriteAtomic(s.path, data)
+}
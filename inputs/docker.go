@@ -0,0 +1,243 @@
+package inputs
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// DockerConfig is the configuration of the optional docker log driver plugin
+type DockerConfig struct {
+	// Socket is the unix socket the plugin api listens on, e.g. /run/docker/plugins/nbu-logger.sock,
+	// the plugin is disabled when empty
+	Socket string `json:"socket" mapstructure:"socket"`
+	// Type is the type of the entries, containers can override it with --log-opt type=...
+	Type string `json:"type" mapstructure:"type"`
+}
+
+// DockerPlugin implements the docker log driver plugin api, so that containers on standalone hosts can use
+// --log-driver with this service. Docker hands every container over as a fifo of length prefixed log entries.
+// To ship it as a managed plugin, run the service in the plugin rootfs with the socket set and a config.json
+// declaring the docker.logdriver/1.0 interface.
+type DockerPlugin struct {
+	config   DockerConfig
+	listener net.Listener
+	mu       sync.Mutex
+	streams  map[string]io.Closer
+}
+
+// dockerContainer is the container info sent with StartLogging
+type dockerContainer struct {
+	Config             map[string]string `json:"Config"`
+	ContainerID        string            `json:"ContainerID"`
+	ContainerName      string            `json:"ContainerName"`
+	ContainerImageName string            `json:"ContainerImageName"`
+	ContainerLabels    map[string]string `json:"ContainerLabels"`
+}
+
+type dockerLoggingRequest struct {
+	File string          `json:"File"`
+	Info dockerContainer `json:"Info"`
+}
+
+// NewDockerPlugin is used to create the plugin for the config
+func NewDockerPlugin(config DockerConfig) *DockerPlugin {
+	if config.Type == "" {
+		config.Type = constants.DefaultDockerEntryType
+	}
+	return &DockerPlugin{config: config, streams: make(map[string]io.Closer)}
+}
+
+// Start is used to serve the plugin api in the background until the context is done
+func (p *DockerPlugin) Start(ctx context.Context) error {
+	_ = os.Remove(p.config.Socket)
+	listener, err := net.Listen("unix", p.config.Socket)
+	if err != nil {
+		return err
+	}
+	p.listener = listener
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		dockerRespond(w, map[string]interface{}{"Implements": []string{"LogDriver"}})
+	})
+	mux.HandleFunc("/LogDriver.Capabilities", func(w http.ResponseWriter, r *http.Request) {
+		dockerRespond(w, map[string]interface{}{"Cap": map[string]bool{"ReadLogs": false}})
+	})
+	mux.HandleFunc("/LogDriver.StartLogging", func(w http.ResponseWriter, r *http.Request) {
+		var request dockerLoggingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			dockerRespond(w, map[string]string{"Err": err.Error()})
+			return
+		}
+		if err := p.startLogging(ctx, request); err != nil {
+			dockerRespond(w, map[string]string{"Err": err.Error()})
+			return
+		}
+		dockerRespond(w, map[string]string{})
+	})
+	mux.HandleFunc("/LogDriver.StopLogging", func(w http.ResponseWriter, r *http.Request) {
+		var request dockerLoggingRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			dockerRespond(w, map[string]string{"Err": err.Error()})
+			return
+		}
+		p.stopLogging(request.File)
+		dockerRespond(w, map[string]string{})
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: constants.DockerPluginTimeoutInSeconds * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(ctx).Err(err).Msg("error serving docker log driver plugin")
+		}
+	}()
+	return nil
+}
+
+// Addr is used to get the address the plugin listens on
+func (p *DockerPlugin) Addr() net.Addr {
+	return p.listener.Addr()
+}
+
+// startLogging is used to open the fifo of a container and ingest its entries in the background
+func (p *DockerPlugin) startLogging(ctx context.Context, request dockerLoggingRequest) error {
+	// the fifo is opened read write so that opening does not wait for docker and the stream only ends on stop
+	fifo, err := os.OpenFile(request.File, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("error opening %s : %w", request.File, err)
+	}
+	p.mu.Lock()
+	p.streams[request.File] = fifo
+	p.mu.Unlock()
+	go func() {
+		defer p.stopLogging(request.File)
+		if err := p.consume(ctx, fifo, request.Info); err != nil && !errors.Is(err, os.ErrClosed) {
+			log.Warn(ctx).Err(err).Str(constants.ContainerKey, request.Info.ContainerName).
+				Msg("error reading container logs")
+		}
+	}()
+	return nil
+}
+
+func (p *DockerPlugin) stopLogging(file string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if stream, ok := p.streams[file]; ok {
+		_ = stream.Close()
+		delete(p.streams, file)
+	}
+}
+
+// consume is used to read the entries of a container until the stream ends, reassembling partial lines
+func (p *DockerPlugin) consume(ctx context.Context, r io.Reader, info dockerContainer) error {
+	reader := bufio.NewReader(r)
+	entryType := p.config.Type
+	if configured := info.Config[constants.TypeField]; configured != "" {
+		entryType = configured
+	}
+	var partial []byte
+	for {
+		message, err := readDockerEntry(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		partial = append(partial, message.line...)
+		if message.partial && len(partial) < constants.DockerMaxLineBytes {
+			continue
+		}
+		entry := models.LogEntry{Type: entryType, Data: map[string]interface{}{
+			constants.MessageField:           strings.TrimRight(string(partial), "\r\n"),
+			constants.DockerStreamField:      message.source,
+			constants.DockerContainerIDField: info.ContainerID,
+			constants.DockerContainerField:   strings.TrimPrefix(info.ContainerName, "/"),
+			constants.DockerImageField:       info.ContainerImageName,
+			constants.TimeField:              time.Unix(0, message.timeNano).UTC().Format(time.RFC3339Nano),
+		}}
+		if len(info.ContainerLabels) > 0 {
+			entry.Data[constants.DockerLabelsField] = info.ContainerLabels
+		}
+		partial = nil
+		if err = ingest.Entry(ctx, &entry); err != nil {
+			log.Warn(ctx).Err(err).Str(constants.ContainerKey, info.ContainerName).Msg("dropped container log")
+		}
+	}
+}
+
+// dockerEntry is the LogEntry message of the docker logdriver protocol
+type dockerEntry struct {
+	source   string
+	timeNano int64
+	line     []byte
+	partial  bool
+}
+
+// readDockerEntry is used to read one big endian length prefixed protobuf LogEntry
+func readDockerEntry(r io.Reader) (dockerEntry, error) {
+	var size uint32
+	if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+		return dockerEntry{}, err
+	}
+	if size > constants.DockerMaxEntryBytes {
+		return dockerEntry{}, fmt.Errorf("docker log entry is larger than %d bytes", constants.DockerMaxEntryBytes)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return dockerEntry{}, err
+	}
+	var entry dockerEntry
+	for len(data) > 0 {
+		number, kind, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return dockerEntry{}, protowire.ParseError(n)
+		}
+		data = data[n:]
+		switch {
+		case number == 1 && kind == protowire.BytesType:
+			value, m := protowire.ConsumeBytes(data)
+			entry.source, n = string(value), m
+		case number == 2 && kind == protowire.VarintType:
+			value, m := protowire.ConsumeVarint(data)
+			entry.timeNano, n = int64(value), m
+		case number == 3 && kind == protowire.BytesType:
+			value, m := protowire.ConsumeBytes(data)
+			entry.line, n = append([]byte(nil), value...), m
+		case number == 4 && kind == protowire.VarintType:
+			value, m := protowire.ConsumeVarint(data)
+			entry.partial, n = value != 0, m
+		default:
+			n = protowire.ConsumeFieldValue(number, kind, data)
+		}
+		if n < 0 {
+			return dockerEntry{}, protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return entry, nil
+}
+
+func dockerRespond(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", constants.DockerPluginMediaType)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,78 @@
+package inputs_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/inputs"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func dockerEntry(line string, partial bool) []byte {
+	var message []byte
+	message = protowire.AppendTag(message, 1, protowire.BytesType)
+	message = protowire.AppendString(message, "stdout")
+	message = protowire.AppendTag(message, 2, protowire.VarintType)
+	message = protowire.AppendVarint(message, uint64(1700000000000000000))
+	message = protowire.AppendTag(message, 3, protowire.BytesType)
+	message = protowire.AppendString(message, line)
+	if partial {
+		message = protowire.AppendTag(message, 4, protowire.VarintType)
+		message = protowire.AppendVarint(message, 1)
+	}
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(message)))
+	return append(size, message...)
+}
+
+func TestDockerPlugin(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.Init(store.NewMemory(10))
+	dir := t.TempDir()
+	plugin := inputs.NewDockerPlugin(inputs.DockerConfig{Socket: filepath.Join(dir, "plugin.sock")})
+	assert.NoError(t, plugin.Start(ctx))
+	client := http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", plugin.Addr().String())
+		},
+	}}
+
+	file := filepath.Join(dir, "container.fifo")
+	assert.NoError(t, syscall.Mkfifo(file, 0o600))
+	response, err := client.Post("http://plugin/LogDriver.StartLogging", "application/json", strings.NewReader(
+		`{"File":"`+file+`","Info":{"ContainerID":"abc","ContainerName":"/web","Config":{"type":"web"}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	_ = response.Body.Close()
+
+	fifo, err := os.OpenFile(file, os.O_WRONLY, 0)
+	assert.NoError(t, err)
+	_, err = fifo.Write(bytes.Join([][]byte{dockerEntry("hello ", true), dockerEntry("world\n", false)}, nil))
+	assert.NoError(t, err)
+	_ = fifo.Close()
+
+	var records []store.Record
+	for i := 0; i < 50 && len(records) == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+		records, err = store.Get().Query(ctx, store.Query{Type: "web"})
+		assert.NoError(t, err)
+	}
+	if assert.Len(t, records, 1) {
+		data := records[0].Entry.Data
+		assert.Equal(t, "hello world", data["message"])
+		assert.Equal(t, "web", data["container"])
+		assert.Equal(t, "stdout", data["stream"])
+	}
+}
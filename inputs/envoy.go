@@ -0,0 +1,242 @@
+package inputs
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/grpcwire"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// envoy access log field numbers, see envoy/data/accesslog/v3/accesslog.proto
+const (
+	alsIdentifier = 1
+	alsHTTPLogs   = 2
+	alsTCPLogs    = 3
+	alsLogEntries = 1
+
+	alsCommon     = 1
+	alsProtocol   = 2
+	alsRequest    = 3
+	alsResponse   = 4
+	alsConnection = 2
+)
+
+var httpMethods = []string{"", "GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH"}
+
+var httpProtocols = []string{"", "HTTP/1.0", "HTTP/1.1", "HTTP/2", "HTTP/3"}
+
+// EnvoyALSConfig is the configuration of the optional envoy access log service receiver
+type EnvoyALSConfig struct {
+	// Address is the address of the plaintext http2 grpc listener, e.g. :9001, the receiver is disabled when empty
+	Address string `json:"address" mapstructure:"address"`
+	// Type is the type of the entries created from the access logs
+	Type string `json:"type" mapstructure:"type"`
+}
+
+// EnvoyALSReceiver implements the StreamAccessLogs rpc of the envoy v3 access log service, so that the
+// mesh can stream the access logs of the http and tcp proxies instead of writing them to files.
+// The grpc framing is implemented on top of h2c since envoy streams one message per batch of access logs.
+type EnvoyALSReceiver struct {
+	config   EnvoyALSConfig
+	listener net.Listener
+}
+
+// NewEnvoyALSReceiver is used to create the receiver for the config
+func NewEnvoyALSReceiver(config EnvoyALSConfig) *EnvoyALSReceiver {
+	if config.Type == "" {
+		config.Type = constants.DefaultEnvoyEntryType
+	}
+	return &EnvoyALSReceiver{config: config}
+}
+
+// Start is used to serve the access log service in the background until the context is done
+func (r *EnvoyALSReceiver) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", r.config.Address)
+	if err != nil {
+		return err
+	}
+	r.listener = listener
+	server := &http.Server{
+		Handler:           h2c.NewHandler(http.HandlerFunc(r.serveHTTP), &http2.Server{}),
+		ReadHeaderTimeout: constants.EnvoyALSReadHeaderTimeoutInSeconds * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error(ctx).Err(err).Msg("error serving envoy access log service")
+		}
+	}()
+	return nil
+}
+
+// Addr is used to get the address the receiver listens on
+func (r *EnvoyALSReceiver) Addr() net.Addr {
+	return r.listener.Addr()
+}
+
+// serveHTTP is used to read the client stream of access log messages and answer with the grpc status
+func (r *EnvoyALSReceiver) serveHTTP(w http.ResponseWriter, request *http.Request) {
+	grpcwire.Prepare(w)
+	status, message := grpcwire.OK, ""
+	if request.URL.Path != constants.EnvoyALSMethod {
+		status, message = grpcwire.Unimplemented, "unknown method "+request.URL.Path
+	} else if err := r.stream(request); err != nil {
+		status, message = grpcwire.InvalidArgument, err.Error()
+		log.Warn(request.Context()).Err(err).Msg("error reading envoy access logs")
+	}
+	w.WriteHeader(http.StatusOK)
+	grpcwire.Finish(w, status, message)
+}
+
+// stream is used to ingest the messages until the client ends the stream.
+// Only the first message carries the identifier of the envoy node, so it is kept for the rest.
+func (r *EnvoyALSReceiver) stream(request *http.Request) error {
+	ctx := request.Context()
+	reader := bufio.NewReader(request.Body)
+	var node, logName string
+	for {
+		data, err := grpcwire.ReadMessage(reader, request.Header.Get("Grpc-Encoding"))
+		if errors.Is(err, io.EOF) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		message, err := parseProto(data)
+		if err != nil {
+			return err
+		}
+		if identifier, ok := message.last(alsIdentifier); ok {
+			fields, _ := parseProto(identifier.bytes)
+			node, logName = fields.message(1).string(1), fields.string(2)
+		}
+		httpLogs, err := message.message(alsHTTPLogs).messages(alsLogEntries)
+		if err != nil {
+			return err
+		}
+		for _, entry := range httpLogs {
+			r.ingest(ctx, r.httpEntry(entry), node, logName)
+		}
+		tcpLogs, err := message.message(alsTCPLogs).messages(alsLogEntries)
+		if err != nil {
+			return err
+		}
+		for _, entry := range tcpLogs {
+			r.ingest(ctx, r.tcpEntry(entry), node, logName)
+		}
+	}
+}
+
+func (r *EnvoyALSReceiver) ingest(ctx context.Context, entry models.LogEntry, node, logName string) {
+	if node != "" {
+		entry.Data[constants.EnvoyNodeField] = node
+	}
+	if logName != "" {
+		entry.Data[constants.EnvoyLogNameField] = logName
+	}
+	if err := ingest.Entry(ctx, &entry); err != nil {
+		log.Warn(ctx).Err(err).Msg("dropped envoy access log")
+	}
+}
+
+// httpEntry is used to map an HTTPAccessLogEntry
+func (r *EnvoyALSReceiver) httpEntry(message protoMessage) models.LogEntry {
+	entry := r.commonEntry(message.message(alsCommon))
+	if protocol := message.uint(alsProtocol); protocol < uint64(len(httpProtocols)) {
+		setField(entry.Data, constants.EnvoyProtocolField, httpProtocols[protocol])
+	}
+	request := message.message(alsRequest)
+	if method := request.uint(1); method < uint64(len(httpMethods)) {
+		setField(entry.Data, constants.EnvoyMethodField, httpMethods[method])
+	}
+	setField(entry.Data, constants.EnvoySchemeField, request.string(2))
+	setField(entry.Data, constants.EnvoyAuthorityField, request.string(3))
+	setField(entry.Data, constants.EnvoyPathField, request.string(5))
+	setField(entry.Data, constants.EnvoyUserAgentField, request.string(6))
+	setField(entry.Data, constants.EnvoyRefererField, request.string(7))
+	setField(entry.Data, constants.EnvoyForwardedForField, request.string(8))
+	setField(entry.Data, constants.EnvoyRequestIDField, request.string(9))
+	entry.Data[constants.EnvoyBytesReceivedField] = request.uint(11) + request.uint(12)
+
+	response := message.message(alsResponse)
+	status := response.message(1).uint(1)
+	entry.Data[constants.EnvoyStatusField] = status
+	entry.Data[constants.EnvoyBytesSentField] = response.uint(2) + response.uint(3)
+	setField(entry.Data, constants.EnvoyResponseDetailsField, response.string(6))
+	switch {
+	case status >= 500 || status == 0:
+		entry.Data[constants.LevelField] = "error"
+	case status >= 400:
+		entry.Data[constants.LevelField] = "warn"
+	default:
+		entry.Data[constants.LevelField] = "info"
+	}
+	entry.Data[constants.MessageField] = strings.TrimSpace(fmt.Sprintf("%s %s %d", entry.Data[constants.EnvoyMethodField],
+		entry.Data[constants.EnvoyPathField], status))
+	return entry
+}
+
+// tcpEntry is used to map a TCPAccessLogEntry
+func (r *EnvoyALSReceiver) tcpEntry(message protoMessage) models.LogEntry {
+	entry := r.commonEntry(message.message(alsCommon))
+	connection := message.message(alsConnection)
+	entry.Data[constants.EnvoyProtocolField] = "tcp"
+	entry.Data[constants.EnvoyBytesReceivedField] = connection.uint(1)
+	entry.Data[constants.EnvoyBytesSentField] = connection.uint(2)
+	entry.Data[constants.LevelField] = "info"
+	return entry
+}
+
+// commonEntry is used to map the AccessLogCommon properties shared by http and tcp entries
+func (r *EnvoyALSReceiver) commonEntry(common protoMessage) models.LogEntry {
+	entry := models.LogEntry{Type: r.config.Type, Data: make(map[string]interface{})}
+	if start, ok := common.last(5); ok {
+		timestamp, _ := parseProto(start.bytes)
+		entry.Data[constants.TimeField] = time.Unix(int64(timestamp.uint(1)), int64(int32(timestamp.uint(2)))).
+			UTC().Format(time.RFC3339Nano)
+	}
+	if duration, ok := common.last(23); ok {
+		fields, _ := parseProto(duration.bytes)
+		entry.Data[constants.EnvoyDurationField] = (time.Duration(fields.uint(1))*time.Second +
+			time.Duration(int32(fields.uint(2)))).Milliseconds()
+	}
+	setField(entry.Data, constants.EnvoyRemoteAddressField, socketAddress(common.message(2)))
+	setField(entry.Data, constants.EnvoyUpstreamHostField, socketAddress(common.message(13)))
+	setField(entry.Data, constants.EnvoyUpstreamClusterField, common.string(15))
+	setField(entry.Data, constants.EnvoyUpstreamFailureField, common.string(18))
+	setField(entry.Data, constants.EnvoyRouteField, common.string(19))
+	return entry
+}
+
+// socketAddress is used to format an envoy Address holding a SocketAddress
+func socketAddress(address protoMessage) string {
+	socket := address.message(1)
+	host := socket.string(2)
+	if host == "" {
+		return ""
+	}
+	return net.JoinHostPort(host, strconv.FormatUint(socket.uint(3), 10))
+}
+
+func setField(data map[string]interface{}, field, value string) {
+	if value != "" {
+		data[field] = value
+	}
+}
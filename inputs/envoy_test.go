@@ -0,0 +1,89 @@
+package inputs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/inputs"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func field(number protowire.Number, value interface{}) []byte {
+	var b []byte
+	switch v := value.(type) {
+	case uint64:
+		b = protowire.AppendTag(b, number, protowire.VarintType)
+		return protowire.AppendVarint(b, v)
+	case string:
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendString(b, v)
+	default:
+		b = protowire.AppendTag(b, number, protowire.BytesType)
+		return protowire.AppendBytes(b, v.([]byte))
+	}
+}
+
+func join(fields ...[]byte) []byte {
+	return bytes.Join(fields, nil)
+}
+
+func TestEnvoyALS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.Init(store.NewMemory(10))
+	receiver := inputs.NewEnvoyALSReceiver(inputs.EnvoyALSConfig{Address: "127.0.0.1:0"})
+	assert.NoError(t, receiver.Start(ctx))
+
+	identifier := join(field(1, join(field(1, "sidecar~10.0.0.1~web"))), field(2, "als"))
+	entry := join(
+		field(1, join(
+			field(2, join(field(1, join(field(2, "10.0.0.9"), field(3, uint64(51000)))))),
+			field(5, join(field(1, uint64(1700000000)))),
+			field(15, "outbound|80||orders"),
+		)),
+		field(2, uint64(3)),
+		field(3, join(field(1, uint64(3)), field(3, "orders.local"), field(5, "/orders"))),
+		field(4, join(field(1, join(field(1, uint64(503)))))),
+	)
+	message := join(field(1, identifier), field(2, join(field(1, entry))))
+	frame := make([]byte, 5)
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(message)))
+
+	client := http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	request, err := http.NewRequest(http.MethodPost, "http://"+receiver.Addr().String()+
+		"/envoy.service.accesslog.v3.AccessLogService/StreamAccessLogs", bytes.NewReader(append(frame, message...)))
+	assert.NoError(t, err)
+	request.Header.Set("Content-Type", "application/grpc")
+	response, err := client.Do(request)
+	assert.NoError(t, err)
+	_, _ = io.Copy(io.Discard, response.Body)
+	_ = response.Body.Close()
+	assert.Equal(t, "0", response.Trailer.Get("Grpc-Status"))
+
+	records, err := store.Get().Query(ctx, store.Query{Type: "envoy.access"})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		data := records[0].Entry.Data
+		assert.Equal(t, "POST", data["method"])
+		assert.Equal(t, "/orders", data["path"])
+		assert.Equal(t, uint64(503), data["status"])
+		assert.Equal(t, "error", data["level"])
+		assert.Equal(t, "10.0.0.9:51000", data["remoteAddress"])
+		assert.Equal(t, "sidecar~10.0.0.1~web", data["node"])
+		assert.Equal(t, "HTTP/2", data["protocol"])
+	}
+}
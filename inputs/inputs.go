@@ -0,0 +1,190 @@
+package inputs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// Config is the configuration of the optional listeners for sources that can not call the http api
+type Config struct {
+	SMTP       SMTPConfig       `json:"smtp" mapstructure:"smtp"`
+	SNMP       SNMPConfig       `json:"snmp" mapstructure:"snmp"`
+	Syslog     SyslogConfig     `json:"syslog" mapstructure:"syslog"`
+	Docker     DockerConfig     `json:"docker" mapstructure:"docker"`
+	Kubernetes KubernetesConfig `json:"kubernetes" mapstructure:"kubernetes"`
+	EnvoyALS   EnvoyALSConfig   `json:"envoyAls" mapstructure:"envoyAls"`
+	AMQP       AMQPConfig       `json:"amqp" mapstructure:"amqp"`
+	NATS       NATSConfig       `json:"nats" mapstructure:"nats"`
+	Outbox     OutboxConfig     `json:"outbox" mapstructure:"outbox"`
+	Kafka      KafkaConfig      `json:"kafka" mapstructure:"kafka"`
+	// RedisStreams is checkpointed like the outbox, the checkpoints of both are managed by the admin apis
+	RedisStreams RedisStreamsConfig `json:"redisStreams" mapstructure:"redisStreams"`
+}
+
+// Start is used to start the configured listeners until the context is done
+func Start(ctx context.Context, config Config) error {
+	if config.SMTP.Address != "" {
+		server, err := NewSMTPServer(config.SMTP)
+		if err != nil {
+			return err
+		}
+		if err = server.Start(ctx); err != nil {
+			return err
+		}
+		log.Info(ctx).Str(constants.AddressKey, server.Addr().String()).Msg("smtp listener started")
+	}
+	if config.SNMP.Address != "" {
+		receiver, err := NewSNMPReceiver(config.SNMP)
+		if err != nil {
+			return err
+		}
+		if err = receiver.Start(ctx); err != nil {
+			return err
+		}
+		log.Info(ctx).Str(constants.AddressKey, receiver.Addr().String()).Msg("snmp trap listener started")
+	}
+	if config.Syslog.UDPAddress != "" || config.Syslog.TCPAddress != "" {
+		receiver, err := NewSyslogReceiver(config.Syslog)
+		if err != nil {
+			return err
+		}
+		if err = receiver.Start(ctx); err != nil {
+			return err
+		}
+		for _, addr := range []net.Addr{receiver.UDPAddr(), receiver.TCPAddr()} {
+			if addr != nil {
+				log.Info(ctx).Str(constants.AddressKey, addr.Network()+"://"+addr.String()).Msg("syslog listener started")
+			}
+		}
+	}
+	if config.Docker.Socket != "" {
+		plugin := NewDockerPlugin(config.Docker)
+		if err := plugin.Start(ctx); err != nil {
+			return err
+		}
+		log.Info(ctx).Str(constants.AddressKey, plugin.Addr().String()).Msg("docker log driver plugin started")
+	}
+	if config.Kubernetes.Enabled {
+		watcher, err := NewKubernetesWatcher(config.Kubernetes)
+		if err != nil {
+			return err
+		}
+		watcher.Start(ctx)
+		log.Info(ctx).Strs(constants.NamespaceKey, config.Kubernetes.Namespaces).Msg("kubernetes events watcher started")
+	}
+	if config.EnvoyALS.Address != "" {
+		receiver := NewEnvoyALSReceiver(config.EnvoyALS)
+		if err := receiver.Start(ctx); err != nil {
+			return err
+		}
+		log.Info(ctx).Str(constants.AddressKey, receiver.Addr().String()).Msg("envoy access log service started")
+	}
+	if config.AMQP.URL != "" {
+		NewAMQPConsumer(config.AMQP).Start(ctx)
+		log.Info(ctx).Str(constants.QueueKey, config.AMQP.Queue).Msg("amqp consumer started")
+	}
+	if config.NATS.URL != "" {
+		NewNATSConsumer(config.NATS).Start(ctx)
+		log.Info(ctx).Str(constants.StreamKey, config.NATS.Stream).Msg("jetstream consumer started")
+	}
+	if len(config.Outbox.Sources) > 0 {
+		relay, err := NewOutboxRelay(config.Outbox)
+		if err != nil {
+			return err
+		}
+		if err = relay.Start(ctx); err != nil {
+			return err
+		}
+		log.Info(ctx).Int(constants.SourceKey, len(config.Outbox.Sources)).Msg("outbox relay started")
+	}
+	if len(config.Kafka.Brokers) > 0 {
+		consumer, err := NewKafkaConsumer(config.Kafka)
+		if err != nil {
+			return err
+		}
+		consumer.Start(ctx)
+		log.Info(ctx).Strs(constants.TopicKey, config.Kafka.Topics).Msg("kafka consumer started")
+	}
+	if config.RedisStreams.URL != "" {
+		consumer, err := NewRedisStreamsConsumer(config.RedisStreams)
+		if err != nil {
+			return err
+		}
+		if err = consumer.Start(ctx); err != nil {
+			return err
+		}
+		log.Info(ctx).Strs(constants.StreamKey, config.RedisStreams.Streams).Msg("redis streams consumer started")
+	}
+	return nil
+}
+
+// decodeMessage is used to read an entry from a broker message. Json entries and CloudEvents are taken as is,
+// debezium change events become database audit entries, other json objects become the data of an entry
+// of the type and anything else becomes its message.
+func decodeMessage(contentType string, body []byte, entryType string) (models.LogEntry, error) {
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == constants.CloudEventsMediaType {
+		return formats.DecodeCloudEvent(body)
+	}
+	var entry models.LogEntry
+	if err := json.Unmarshal(body, &entry); err == nil && entry.Type != "" {
+		return entry, nil
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(body, &data); err == nil && data != nil {
+		if format, ok := formats.Detect(data); ok && format.Name() == constants.DebeziumFormat {
+			return format.Convert(data), nil
+		}
+		return models.LogEntry{Type: entryType, Data: data}, nil
+	}
+	if !utf8.Valid(body) {
+		return entry, fmt.Errorf("message is neither json nor text")
+	}
+	return models.LogEntry{Type: entryType, Data: map[string]interface{}{
+		constants.MessageField: strings.TrimRight(string(body), "\r\n"),
+	}}, nil
+}
+
+// parseNetworks is used to parse the cidr ranges a listener accepts connections from
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("allowed network %s : %w", cidr, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// allowed is used to check whether the address is in one of the networks, no networks allow all
+func allowed(networks []*net.IPNet, addr net.Addr) bool {
+	if len(networks) == 0 {
+		return true
+	}
+	var ip net.IP
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		ip = a.IP
+	case *net.UDPAddr:
+		ip = a.IP
+	default:
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
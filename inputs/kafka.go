@@ -0,0 +1,414 @@
+package inputs
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/utils/kafka"
+)
+
+// KafkaConfig is the configuration of the optional kafka consumer, e.g. of the topics debezium writes to
+type KafkaConfig struct {
+	// Brokers are the host:port of the bootstrap brokers, the consumer is disabled when empty
+	Brokers []string `json:"brokers" mapstructure:"brokers"`
+	// TLS is used to connect to the brokers over tls
+	TLS bool `json:"tls" mapstructure:"tls"`
+	// Topics are the topics consumed from
+	Topics []string `json:"topics" mapstructure:"topics"`
+	// Group is the group the offsets are committed for, the group is not joined, so one instance consumes it
+	Group string `json:"group" mapstructure:"group"`
+	// StartFrom is where the partitions without a committed offset are read from, earliest or latest
+	StartFrom string `json:"startFrom" mapstructure:"startFrom"`
+	// ReplayWindowInSeconds is how far back the partitions without a committed offset are read from when they
+	// start from the earliest offset, so a new group neither replays the whole retention nor skips what was
+	// written while it was starting. -1 reads them all.
+	ReplayWindowInSeconds int `json:"replayWindowInSeconds" mapstructure:"replayWindowInSeconds"`
+	// Type is the type of the entries for messages that are not entries themselves
+	Type string `json:"type" mapstructure:"type"`
+}
+
+// KafkaConsumer ingests the records of kafka topics, debezium change events becoming database audit entries.
+// The offsets are committed once the records of a fetch are ingested, records the pipeline rejects are
+// logged and skipped, and tombstones are skipped as the delete event before them carries the change.
+// The offsets committed to the group are the checkpoints of the partitions, named topic/partition, they only
+// move forward unless they are reset through the admin api.
+type KafkaConsumer struct {
+	config KafkaConfig
+	// store keeps the checkpoints in memory, the group keeps them across restarts
+	store *checkpointStore
+}
+
+// NewKafkaConsumer is used to create the consumer for the config
+func NewKafkaConsumer(config KafkaConfig) (*KafkaConsumer, error) {
+	if len(config.Topics) == 0 {
+		return nil, fmt.Errorf("kafka consumer needs topics")
+	}
+	if config.Group == "" {
+		config.Group = constants.DefaultKafkaGroup
+	}
+	switch config.StartFrom {
+	case "":
+		config.StartFrom = constants.KafkaStartFromEarliest
+	case constants.KafkaStartFromEarliest, constants.KafkaStartFromLatest:
+	default:
+		return nil, fmt.Errorf("kafka start from has to be %s or %s", constants.KafkaStartFromEarliest,
+			constants.KafkaStartFromLatest)
+	}
+	if config.Type == "" {
+		config.Type = constants.DefaultKafkaEntryType
+	}
+	if config.ReplayWindowInSeconds == 0 {
+		config.ReplayWindowInSeconds = constants.DefaultReplayWindowInSeconds
+	}
+	store, err := loadCheckpoints("", compareOffsets)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaConsumer{config: config, store: store}, nil
+}
+
+// Start is used to consume in the background until the context is done, reconnecting when a broker fails
+func (k *KafkaConsumer) Start(ctx context.Context) {
+	register(constants.KafkaInput, k)
+	go func() {
+		for ctx.Err() == nil {
+			if err := k.consume(ctx); err != nil && ctx.Err() == nil {
+				log.Warn(ctx).Err(err).Strs(constants.TopicKey, k.config.Topics).Msg("error consuming kafka topics")
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(constants.KafkaRetryInSeconds * time.Second):
+			}
+		}
+	}()
+}
+
+func (k *KafkaConsumer) consume(ctx context.Context) error {
+	client := k.client()
+	defer client.Close()
+	metadata, err := client.Metadata(ctx, k.config.Topics)
+	if err != nil {
+		return err
+	}
+	offsets, err := k.startOffsets(ctx, client, metadata.Partitions, time.Now())
+	if err != nil {
+		return err
+	}
+	// the generations of the checkpoints read from, a reset moves the partition to its checkpoint
+	generations := make(map[string]int, len(metadata.Partitions))
+	// the partitions of a broker are fetched together, a leader change fails the fetch and the metadata is
+	// read again on the retry
+	byLeader := make(map[int32]kafka.Offsets)
+	for _, partition := range metadata.Partitions {
+		if partition.Leader < 0 {
+			return fmt.Errorf("kafka partition %s/%d has no leader", partition.Topic, partition.ID)
+		}
+		if byLeader[partition.Leader] == nil {
+			byLeader[partition.Leader] = make(kafka.Offsets)
+		}
+		byLeader[partition.Leader].Set(partition.Topic, partition.ID, offsets[partition.Topic][partition.ID])
+		checkpoint, _ := k.store.get(partitionSource(partition.Topic, partition.ID))
+		generations[partitionSource(partition.Topic, partition.ID)] = checkpoint.Generation
+	}
+	for ctx.Err() == nil {
+		for leader, partitions := range byLeader {
+			if err = k.applyResets(ctx, client, partitions, generations); err != nil {
+				return err
+			}
+			if err = k.fetch(ctx, client, leader, partitions, generations); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// client is used to connect to the brokers
+func (k *KafkaConsumer) client() *kafka.Client {
+	var tlsConfig *tls.Config
+	if k.config.TLS {
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return kafka.NewClient(k.config.Brokers, tlsConfig)
+}
+
+// applyResets is used to move the partitions whose checkpoint was reset to it, and commit it to the group
+func (k *KafkaConsumer) applyResets(ctx context.Context, client *kafka.Client, partitions kafka.Offsets,
+	generations map[string]int) error {
+	reset := make(kafka.Offsets)
+	for topic, offsets := range partitions {
+		for partition := range offsets {
+			source := partitionSource(topic, partition)
+			checkpoint, ok := k.store.get(source)
+			if !ok || checkpoint.Generation == generations[source] {
+				continue
+			}
+			offset, err := strconv.ParseInt(checkpoint.Position, 10, 64)
+			if err != nil {
+				return err
+			}
+			generations[source] = checkpoint.Generation
+			partitions.Set(topic, partition, offset)
+			reset.Set(topic, partition, offset)
+		}
+	}
+	if len(reset) == 0 {
+		return nil
+	}
+	coordinator, err := client.Coordinator(ctx, k.config.Group)
+	if err != nil {
+		return err
+	}
+	return coordinator.OffsetCommit(ctx, k.config.Group, reset)
+}
+
+// startOffsets is used to get the checkpoints of the partitions, or else their offsets committed to the group,
+// or else where they start from. The partitions read from the earliest offset start at the replay window.
+func (k *KafkaConsumer) startOffsets(ctx context.Context, client *kafka.Client, partitions []kafka.Partition,
+	now time.Time) (kafka.Offsets, error) {
+	coordinator, err := client.Coordinator(ctx, k.config.Group)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string][]int32)
+	for _, partition := range partitions {
+		ids[partition.Topic] = append(ids[partition.Topic], partition.ID)
+	}
+	offsets, err := coordinator.OffsetFetch(ctx, k.config.Group, ids)
+	if err != nil {
+		return nil, err
+	}
+	at := kafka.Earliest
+	switch {
+	case k.config.StartFrom == constants.KafkaStartFromLatest:
+		at = kafka.Latest
+	case k.config.ReplayWindowInSeconds > 0:
+		at = now.Add(-time.Duration(k.config.ReplayWindowInSeconds) * time.Second).UnixMilli()
+	}
+	missing := make(map[int32]kafka.Offsets)
+	for _, partition := range partitions {
+		source := partitionSource(partition.Topic, partition.ID)
+		if checkpoint, ok := k.store.get(source); ok {
+			offset, err := strconv.ParseInt(checkpoint.Position, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			offsets.Set(partition.Topic, partition.ID, offset)
+			continue
+		}
+		if offset, ok := offsets[partition.Topic][partition.ID]; ok && offset >= 0 {
+			continue
+		}
+		if missing[partition.Leader] == nil {
+			missing[partition.Leader] = make(kafka.Offsets)
+		}
+		missing[partition.Leader].Set(partition.Topic, partition.ID, at)
+	}
+	for leader, times := range missing {
+		listed, err := listOffsets(ctx, client, leader, times)
+		if err != nil {
+			return nil, err
+		}
+		for topic, partitions := range listed {
+			for partition, offset := range partitions {
+				offsets.Set(topic, partition, offset)
+			}
+		}
+	}
+	// the start is recorded, so that the partitions only move forward from it
+	for topic, partitions := range offsets {
+		for partition, offset := range partitions {
+			err := k.store.commit(partitionSource(topic, partition), 0, strconv.FormatInt(offset, 10), now)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return offsets, nil
+}
+
+// listOffsets is used to get the offsets of the partitions led by the broker at the times, the partitions
+// without records from the time are read from their next record
+func listOffsets(ctx context.Context, client *kafka.Client, leader int32, times kafka.Offsets) (kafka.Offsets,
+	error) {
+	conn, err := client.Broker(ctx, leader)
+	if err != nil {
+		return nil, err
+	}
+	listed, err := conn.ListOffsets(ctx, times)
+	if err != nil {
+		return nil, err
+	}
+	latest := make(kafka.Offsets)
+	for topic, partitions := range listed {
+		for partition, offset := range partitions {
+			if offset < 0 {
+				latest.Set(topic, partition, kafka.Latest)
+			}
+		}
+	}
+	if len(latest) > 0 {
+		if latest, err = conn.ListOffsets(ctx, latest); err != nil {
+			return nil, err
+		}
+		for topic, partitions := range latest {
+			for partition, offset := range partitions {
+				listed.Set(topic, partition, offset)
+			}
+		}
+	}
+	return listed, nil
+}
+
+// fetch is used to fetch the partitions led by the broker once, ingest their records and commit their offsets.
+// The offsets of the partitions reset meanwhile are not committed, so that the reset wins.
+func (k *KafkaConsumer) fetch(ctx context.Context, client *kafka.Client, leader int32, partitions kafka.Offsets,
+	generations map[string]int) error {
+	conn, err := client.Broker(ctx, leader)
+	if err != nil {
+		return err
+	}
+	results, err := conn.Fetch(ctx, partitions, constants.KafkaFetchWaitInMillis*time.Millisecond,
+		constants.KafkaFetchMaxBytes)
+	if err != nil {
+		client.Drop(leader)
+		return err
+	}
+	consumed := make(kafka.Offsets)
+	var errs []error
+	for _, result := range results {
+		if errors.Is(result.Err, kafka.ErrOffsetOutOfRange) {
+			// the records were deleted by the retention before being read, reading goes on from the oldest left
+			log.Warn(ctx).Str(constants.TopicKey, result.Topic).Int32(constants.PartitionKey, result.Partition).
+				Msg("kafka offset out of range, resetting to the earliest offset")
+			listed, err := conn.ListOffsets(ctx, kafka.Offsets{result.Topic: {result.Partition: kafka.Earliest}})
+			if err != nil {
+				return err
+			}
+			partitions.Set(result.Topic, result.Partition, listed[result.Topic][result.Partition])
+			continue
+		}
+		for _, record := range result.Records {
+			k.ingest(ctx, record)
+			partitions.Set(result.Topic, result.Partition, record.Offset+1)
+		}
+		if n := len(result.Records); n > 0 {
+			source, next := partitionSource(result.Topic, result.Partition), result.Records[n-1].Offset+1
+			if checkpoint, _ := k.store.get(source); checkpoint.Generation == generations[source] {
+				if err := k.store.commit(source, generations[source], strconv.FormatInt(next, 10),
+					time.Now()); err != nil {
+					return err
+				}
+				consumed.Set(result.Topic, result.Partition, next)
+			}
+		}
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("kafka partition %s/%d : %w", result.Topic, result.Partition, result.Err))
+		}
+	}
+	if len(consumed) > 0 {
+		coordinator, err := client.Coordinator(ctx, k.config.Group)
+		if err != nil {
+			return err
+		}
+		if err = coordinator.OffsetCommit(ctx, k.config.Group, consumed); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkpoints are used to get the checkpoints of the partitions, the offsets of their next records
+func (k *KafkaConsumer) checkpoints() map[string]Checkpoint {
+	return k.store.all()
+}
+
+// reset is used to move the checkpoint of the partition, named topic/partition, to the offset, or to the
+// records written from the time
+func (k *KafkaConsumer) reset(source, position string, at time.Time, now time.Time) error {
+	topic, id, ok := strings.Cut(source, "/")
+	partition, err := strconv.ParseInt(id, 10, 32)
+	known := false
+	for _, t := range k.config.Topics {
+		known = known || t == topic
+	}
+	if !ok || err != nil || !known {
+		return fmt.Errorf("%w %s", ErrUnknownCheckpoint, source)
+	}
+	if position == "" {
+		client := k.client()
+		defer client.Close()
+		metadata, err := client.Metadata(context.Background(), []string{topic})
+		if err != nil {
+			return err
+		}
+		for _, p := range metadata.Partitions {
+			if p.Topic != topic || p.ID != int32(partition) {
+				continue
+			}
+			listed, err := listOffsets(context.Background(), client, p.Leader,
+				kafka.Offsets{topic: {p.ID: at.UnixMilli()}})
+			if err != nil {
+				return err
+			}
+			position = strconv.FormatInt(listed[topic][p.ID], 10)
+		}
+		if position == "" {
+			return fmt.Errorf("%w %s", ErrUnknownCheckpoint, source)
+		}
+	}
+	return k.store.reset(source, position, now)
+}
+
+// partitionSource is used to get the name of the checkpoint of the partition
+func partitionSource(topic string, partition int32) string {
+	return topic + "/" + strconv.Itoa(int(partition))
+}
+
+// compareOffsets is used to order two offsets
+func compareOffsets(a, b string) (int, error) {
+	x, err := strconv.ParseInt(a, 10, 64)
+	if err != nil || x < 0 {
+		return 0, fmt.Errorf("offset %q has to be a positive number", a)
+	}
+	y, err := strconv.ParseInt(b, 10, 64)
+	if err != nil || y < 0 {
+		return 0, fmt.Errorf("offset %q has to be a positive number", b)
+	}
+	switch {
+	case x < y:
+		return -1, nil
+	case x == y:
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// ingest is used to ingest the value of the record, the errors are logged as the record is not read again
+func (k *KafkaConsumer) ingest(ctx context.Context, record kafka.Record) {
+	if record.Value == nil {
+		return
+	}
+	var contentType string
+	for _, header := range record.Headers {
+		if strings.EqualFold(header.Key, constants.KafkaContentTypeHeader) {
+			contentType = string(header.Value)
+		}
+	}
+	entry, err := decodeMessage(contentType, record.Value, k.config.Type)
+	if err == nil {
+		err = ingest.Entry(ctx, &entry)
+	}
+	if err != nil {
+		log.Warn(ctx).Err(err).Str(constants.TopicKey, record.Topic).Int32(constants.PartitionKey, record.Partition).
+			Int64(constants.OffsetKey, record.Offset).Msg("rejected kafka record")
+	}
+}
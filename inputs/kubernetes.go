@@ -0,0 +1,301 @@
+package inputs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// KubernetesConfig is the configuration of the optional kubernetes events watcher
+type KubernetesConfig struct {
+	// Enabled is used to start the watcher
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// APIServer is the url of the api server, the in cluster service account is used when empty
+	APIServer string `json:"apiServer" mapstructure:"apiServer"`
+	// TokenFile is the bearer token file, read on every watch so that rotated tokens are picked up
+	TokenFile string `json:"tokenFile" mapstructure:"tokenFile"`
+	// CAFile is the certificate authority of the api server
+	CAFile string `json:"caFile" mapstructure:"caFile"`
+	// Namespaces are the watched namespaces, all the namespaces are watched when empty
+	Namespaces []string `json:"namespaces" mapstructure:"namespaces"`
+	// EventTypes are the ingested event types, e.g. Warning, all are ingested when empty
+	EventTypes []string `json:"eventTypes" mapstructure:"eventTypes"`
+	// Type is the type of the entries created from the events
+	Type string `json:"type" mapstructure:"type"`
+}
+
+// KubernetesWatcher ingests the core v1 events of the configured namespaces, so that pod evictions,
+// oom kills and failed probes can be correlated with the application logs
+type KubernetesWatcher struct {
+	config KubernetesConfig
+	client *http.Client
+}
+
+type kubernetesWatchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+type kubernetesEvent struct {
+	Metadata struct {
+		ResourceVersion string `json:"resourceVersion"`
+	} `json:"metadata"`
+	InvolvedObject struct {
+		Kind      string `json:"kind"`
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+		FieldPath string `json:"fieldPath"`
+	} `json:"involvedObject"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Count   int64  `json:"count"`
+	Source  struct {
+		Component string `json:"component"`
+		Host      string `json:"host"`
+	} `json:"source"`
+	ReportingComponent string `json:"reportingComponent"`
+	FirstTimestamp     string `json:"firstTimestamp"`
+	LastTimestamp      string `json:"lastTimestamp"`
+	EventTime          string `json:"eventTime"`
+	// Code is set when the watch event is an error status
+	Code int `json:"code"`
+}
+
+// errResourceVersionGone is returned when the watched resource version is too old and a new list is needed
+var errResourceVersionGone = errors.New("resource version gone")
+
+// NewKubernetesWatcher is used to create the watcher for the config
+func NewKubernetesWatcher(config KubernetesConfig) (*KubernetesWatcher, error) {
+	if config.Type == "" {
+		config.Type = constants.DefaultKubernetesEntryType
+	}
+	if config.APIServer == "" {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" {
+			return nil, fmt.Errorf("kubernetes api server is not configured and not running in a cluster")
+		}
+		config.APIServer = "https://" + net.JoinHostPort(host, port)
+		if config.TokenFile == "" {
+			config.TokenFile = constants.KubernetesServiceAccountTokenFile
+		}
+		if config.CAFile == "" {
+			config.CAFile = constants.KubernetesServiceAccountCAFile
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.CAFile != "" {
+		ca, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes ca : %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("kubernetes ca %s has no certificates", config.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	if len(config.Namespaces) == 0 {
+		config.Namespaces = []string{""}
+	}
+	return &KubernetesWatcher{config: config, client: &http.Client{Transport: transport}}, nil
+}
+
+// Start is used to watch every namespace in the background until the context is done
+func (w *KubernetesWatcher) Start(ctx context.Context) {
+	for _, namespace := range w.config.Namespaces {
+		go w.run(ctx, namespace)
+	}
+}
+
+// run is used to keep watching the namespace, listing again when the resource version is gone.
+// The watch starts at the listed resource version so that a restart does not ingest the past events again.
+func (w *KubernetesWatcher) run(ctx context.Context, namespace string) {
+	var resourceVersion string
+	for ctx.Err() == nil {
+		var err error
+		if resourceVersion == "" {
+			resourceVersion, err = w.list(ctx, namespace)
+		}
+		if err == nil {
+			resourceVersion, err = w.watch(ctx, namespace, resourceVersion)
+		}
+		if errors.Is(err, errResourceVersionGone) {
+			resourceVersion = ""
+			continue
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Warn(ctx).Err(err).Str(constants.NamespaceKey, namespace).Msg("error watching kubernetes events")
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(constants.KubernetesRetryInSeconds * time.Second):
+		}
+	}
+}
+
+func (w *KubernetesWatcher) path(namespace string) string {
+	if namespace == "" {
+		return w.config.APIServer + "/api/v1/events"
+	}
+	return w.config.APIServer + "/api/v1/namespaces/" + url.PathEscape(namespace) + "/events"
+}
+
+func (w *KubernetesWatcher) get(ctx context.Context, endpoint string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if w.config.TokenFile != "" {
+		token, err := os.ReadFile(w.config.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("kubernetes token : %w", err)
+		}
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	response, err := w.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusGone {
+		_ = response.Body.Close()
+		return nil, errResourceVersionGone
+	}
+	if response.StatusCode != http.StatusOK {
+		_ = response.Body.Close()
+		return nil, fmt.Errorf("kubernetes api server responded %s", response.Status)
+	}
+	return response, nil
+}
+
+// list is used to get the current resource version of the events
+func (w *KubernetesWatcher) list(ctx context.Context, namespace string) (string, error) {
+	response, err := w.get(ctx, w.path(namespace)+"?limit=1")
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	var list struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&list); err != nil {
+		return "", err
+	}
+	return list.Metadata.ResourceVersion, nil
+}
+
+// watch is used to ingest the events until the watch ends and get the last seen resource version
+func (w *KubernetesWatcher) watch(ctx context.Context, namespace, resourceVersion string) (string, error) {
+	query := url.Values{
+		"watch":               {"true"},
+		"allowWatchBookmarks": {"true"},
+		"resourceVersion":     {resourceVersion},
+		"timeoutSeconds":      {fmt.Sprint(constants.KubernetesWatchTimeoutInSeconds)},
+	}
+	response, err := w.get(ctx, w.path(namespace)+"?"+query.Encode())
+	if err != nil {
+		return resourceVersion, err
+	}
+	defer response.Body.Close()
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var event kubernetesWatchEvent
+		if err = decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				return resourceVersion, nil
+			}
+			if errors.Is(err, io.EOF) {
+				// the server ends the watch after the timeout, it is resumed from the last version
+				return resourceVersion, nil
+			}
+			return resourceVersion, err
+		}
+		var object kubernetesEvent
+		if err = json.Unmarshal(event.Object, &object); err != nil {
+			return resourceVersion, err
+		}
+		switch event.Type {
+		case "ERROR":
+			if object.Code == http.StatusGone {
+				return "", errResourceVersionGone
+			}
+			return resourceVersion, fmt.Errorf("kubernetes watch error : %s", object.Message)
+		case "ADDED", "MODIFIED":
+			// a modified event is a repeat of the same event with a higher count
+			w.ingest(ctx, object)
+		}
+		if object.Metadata.ResourceVersion != "" {
+			resourceVersion = object.Metadata.ResourceVersion
+		}
+	}
+}
+
+func (w *KubernetesWatcher) ingest(ctx context.Context, event kubernetesEvent) {
+	if len(w.config.EventTypes) > 0 && !contains(w.config.EventTypes, event.Type) {
+		return
+	}
+	level := "info"
+	if event.Type == "Warning" {
+		level = "warn"
+	}
+	component := event.Source.Component
+	if component == "" {
+		component = event.ReportingComponent
+	}
+	entry := models.LogEntry{Type: w.config.Type, Data: map[string]interface{}{
+		constants.MessageField:             event.Message,
+		constants.LevelField:               level,
+		constants.KubernetesReasonField:    event.Reason,
+		constants.KubernetesKindField:      event.InvolvedObject.Kind,
+		constants.KubernetesNameField:      event.InvolvedObject.Name,
+		constants.KubernetesNamespaceField: event.InvolvedObject.Namespace,
+		constants.KubernetesComponentField: component,
+		constants.KubernetesCountField:     event.Count,
+		constants.TimeField:                firstNonEmpty(event.LastTimestamp, event.EventTime, event.FirstTimestamp),
+	}}
+	if event.InvolvedObject.FieldPath != "" {
+		entry.Data[constants.KubernetesFieldPathField] = event.InvolvedObject.FieldPath
+	}
+	if event.Source.Host != "" {
+		entry.Data[constants.HostField] = event.Source.Host
+	}
+	if err := ingest.Entry(ctx, &entry); err != nil {
+		log.Warn(ctx).Err(err).Str(constants.NamespaceKey, event.InvolvedObject.Namespace).
+			Msg("dropped kubernetes event")
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
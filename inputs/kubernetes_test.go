@@ -0,0 +1,59 @@
+package inputs_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/inputs"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKubernetesWatcher(t *testing.T) {
+	store.Init(store.NewMemory(10))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/v1/namespaces/payments/events", r.URL.Path)
+		if r.URL.Query().Get("watch") == "" {
+			_, _ = fmt.Fprint(w, `{"metadata":{"resourceVersion":"41"},"items":[]}`)
+			return
+		}
+		assert.Equal(t, "41", r.URL.Query().Get("resourceVersion"))
+		_, _ = fmt.Fprint(w, `{"type":"ADDED","object":{"metadata":{"resourceVersion":"42"},`+
+			`"involvedObject":{"kind":"Pod","name":"api-1","namespace":"payments"},"reason":"OOMKilling",`+
+			`"message":"Memory cgroup out of memory","type":"Warning","count":1,`+
+			`"source":{"component":"kernel-monitor","host":"node-1"},"lastTimestamp":"2024-01-01T00:00:00Z"}}`)
+		_, _ = fmt.Fprint(w, `{"type":"ADDED","object":{"metadata":{"resourceVersion":"43"},"type":"Normal"}}`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	// the watch is cancelled before the server closes, which waits for the watch request
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	watcher, err := inputs.NewKubernetesWatcher(inputs.KubernetesConfig{
+		APIServer:  server.URL,
+		Namespaces: []string{"payments"},
+		EventTypes: []string{"Warning"},
+	})
+	assert.NoError(t, err)
+	watcher.Start(ctx)
+
+	var records []store.Record
+	for i := 0; i < 50 && len(records) == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+		records, err = store.Get().Query(ctx, store.Query{Type: "kubernetes.event"})
+		assert.NoError(t, err)
+	}
+	if assert.Len(t, records, 1) {
+		data := records[0].Entry.Data
+		assert.Equal(t, "OOMKilling", data["reason"])
+		assert.Equal(t, "api-1", data["name"])
+		assert.Equal(t, "warn", data["level"])
+		assert.Equal(t, "node-1", data["host"])
+	}
+}
@@ -0,0 +1,129 @@
+package inputs
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/utils/nats"
+)
+
+// NATSConfig is the configuration of the optional nats jetstream consumer
+type NATSConfig struct {
+	// URL is the nats url of the server, the consumer is disabled when empty
+	URL string `json:"-" mapstructure:"url"`
+	// Stream is the stream consumed from
+	Stream string `json:"stream" mapstructure:"stream"`
+	// Durable is the name of the durable pull consumer, it is created when missing
+	Durable string `json:"durable" mapstructure:"durable"`
+	// FilterSubject limits the consumer to the matching subjects
+	FilterSubject string `json:"filterSubject" mapstructure:"filterSubject"`
+	// Batch is the number of messages pulled at once
+	Batch int `json:"batch" mapstructure:"batch"`
+	// Type is the type of the entries for messages that are not entries themselves
+	Type string `json:"type" mapstructure:"type"`
+	// Types are the types of the messages by subject, * and > wildcards are allowed and the first match wins
+	Types []SubjectType `json:"types" mapstructure:"types"`
+}
+
+// SubjectType is the entry type of the messages of a subject
+type SubjectType struct {
+	Subject string `json:"subject" mapstructure:"subject"`
+	Type    string `json:"type" mapstructure:"type"`
+}
+
+// NATSConsumer ingests the messages of a jetstream durable consumer. Messages are acked once ingested
+// and terminated when the pipeline rejects them, so that they are not redelivered forever.
+type NATSConsumer struct {
+	config NATSConfig
+}
+
+// NewNATSConsumer is used to create the consumer for the config
+func NewNATSConsumer(config NATSConfig) *NATSConsumer {
+	if config.Batch <= 0 {
+		config.Batch = constants.DefaultNATSBatch
+	}
+	if config.Durable == "" {
+		config.Durable = constants.DefaultNATSDurable
+	}
+	if config.Type == "" {
+		config.Type = constants.DefaultNATSEntryType
+	}
+	return &NATSConsumer{config: config}
+}
+
+// Start is used to consume in the background until the context is done, reconnecting when the connection is lost
+func (n *NATSConsumer) Start(ctx context.Context) {
+	go func() {
+		for ctx.Err() == nil {
+			if err := n.consume(ctx); err != nil && ctx.Err() == nil {
+				log.Warn(ctx).Err(err).Str(constants.StreamKey, n.config.Stream).Msg("error consuming jetstream")
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(constants.NATSRetryInSeconds * time.Second):
+			}
+		}
+	}()
+}
+
+func (n *NATSConsumer) consume(ctx context.Context) error {
+	conn, err := nats.Dial(ctx, n.config.URL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err = conn.EnsureConsumer(ctx, n.config.Stream, n.config.Durable, n.config.FilterSubject); err != nil {
+		return err
+	}
+	for ctx.Err() == nil {
+		messages, err := conn.Fetch(ctx, n.config.Stream, n.config.Durable, n.config.Batch,
+			constants.NATSFetchExpiresInSeconds*time.Second)
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			entry, err := decodeMessage("", msg.Data, n.entryType(msg.Subject))
+			if err == nil {
+				err = ingest.Entry(ctx, &entry)
+			}
+			if err != nil {
+				log.Warn(ctx).Err(err).Str(constants.StreamKey, n.config.Stream).Msg("rejected jetstream message")
+				err = conn.Term(msg)
+			} else {
+				err = conn.Ack(msg)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entryType is used to get the type of the first mapping matching the subject
+func (n *NATSConsumer) entryType(subject string) string {
+	for _, mapping := range n.config.Types {
+		if subjectMatches(mapping.Subject, subject) {
+			return mapping.Type
+		}
+	}
+	return n.config.Type
+}
+
+// subjectMatches is used to match a subject against a pattern with the nats wildcards
+func subjectMatches(pattern, subject string) bool {
+	patternTokens, subjectTokens := strings.Split(pattern, "."), strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
@@ -0,0 +1,264 @@
+package inputs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/utils/files"
+	// the postgres driver is registered for the outbox sources
+	_ "github.com/lib/pq"
+)
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// OutboxConfig is the configuration of the optional outbox relay
+type OutboxConfig struct {
+	// OffsetsPath is the file the relayed offsets of every source are kept in, it is required with sources
+	OffsetsPath string `json:"offsetsPath" mapstructure:"offsetsPath"`
+	// Sources are the outbox tables relayed
+	Sources []OutboxSource `json:"sources" mapstructure:"sources"`
+}
+
+// OutboxSource is an outbox table of a producer database
+type OutboxSource struct {
+	// Name identifies the source in the offsets, it must not change
+	Name string `json:"name" mapstructure:"name"`
+	// Driver is the database/sql driver, postgres or a driver registered in the build such as mysql
+	Driver string `json:"driver" mapstructure:"driver"`
+	// URL is the data source name of the producer database
+	URL string `json:"-" mapstructure:"url"`
+	// Table is the outbox table, optionally qualified by the schema
+	Table string `json:"table" mapstructure:"table"`
+	// IDColumn is the increasing integer id of the rows, it has to increase in commit order
+	IDColumn string `json:"idColumn" mapstructure:"idColumn"`
+	// PayloadColumn is the json payload of the rows
+	PayloadColumn string `json:"payloadColumn" mapstructure:"payloadColumn"`
+	// TypeColumn is the optional column holding the type of rows whose payload is not an entry
+	TypeColumn string `json:"typeColumn" mapstructure:"typeColumn"`
+	// Type is the type of the rows when there is no type column
+	Type string `json:"type" mapstructure:"type"`
+	// BatchSize is the number of rows read per poll
+	BatchSize int `json:"batchSize" mapstructure:"batchSize"`
+	// PollIntervalInMillis is the time between polls when the outbox is drained
+	PollIntervalInMillis int `json:"pollIntervalInMillis" mapstructure:"pollIntervalInMillis"`
+}
+
+// OutboxRelay polls the outbox tables of producers and ingests their rows in id order. Services write the row
+// in the transaction of the change it describes, so there is no dual write, and the relay records the last
+// relayed id of every source after each batch. Rows are relayed at least once, a crash between the ingestion
+// and the offset write relays them again with the same outboxId, which can be used to drop the duplicates.
+type OutboxRelay struct {
+	config  OutboxConfig
+	mu      sync.Mutex
+	offsets map[string]int64
+	// generations count the resets of the offsets, the batches read before a reset do not record their offset
+	generations map[string]int
+	committed   map[string]time.Time
+}
+
+// NewOutboxRelay is used to create the relay for the config and load the recorded offsets
+func NewOutboxRelay(config OutboxConfig) (*OutboxRelay, error) {
+	if config.OffsetsPath == "" {
+		return nil, fmt.Errorf("outbox offsets path is required")
+	}
+	names := make(map[string]bool)
+	for i, source := range config.Sources {
+		if source.Name == "" || names[source.Name] || source.URL == "" {
+			return nil, fmt.Errorf("outbox source %d needs a unique name and a url", i)
+		}
+		names[source.Name] = true
+		if source.Driver == "" {
+			source.Driver = constants.PostgresqlDriverName
+		}
+		if source.IDColumn == "" {
+			source.IDColumn = constants.DefaultOutboxIDColumn
+		}
+		if source.PayloadColumn == "" {
+			source.PayloadColumn = constants.DefaultOutboxPayloadColumn
+		}
+		if source.Type == "" {
+			source.Type = constants.DefaultOutboxEntryType
+		}
+		if source.BatchSize <= 0 {
+			source.BatchSize = constants.DefaultOutboxBatchSize
+		}
+		if source.PollIntervalInMillis <= 0 {
+			source.PollIntervalInMillis = constants.DefaultOutboxPollIntervalInMillis
+		}
+		for _, name := range []string{source.Table, source.IDColumn, source.PayloadColumn} {
+			if !identifier.MatchString(name) {
+				return nil, fmt.Errorf("outbox source %s has an invalid table or column %q", source.Name, name)
+			}
+		}
+		if source.TypeColumn != "" && !identifier.MatchString(source.TypeColumn) {
+			return nil, fmt.Errorf("outbox source %s has an invalid type column", source.Name)
+		}
+		config.Sources[i] = source
+	}
+	r := &OutboxRelay{config: config, offsets: make(map[string]int64), generations: make(map[string]int),
+		committed: make(map[string]time.Time)}
+	data, err := os.ReadFile(config.OffsetsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &r.offsets); err != nil {
+			return nil, fmt.Errorf("outbox offsets : %w", err)
+		}
+	}
+	return r, nil
+}
+
+// Start is used to relay every source in the background until the context is done
+func (r *OutboxRelay) Start(ctx context.Context) error {
+	register(constants.OutboxInput, r)
+	for _, source := range r.config.Sources {
+		db, err := sql.Open(source.Driver, source.URL)
+		if err != nil {
+			return fmt.Errorf("outbox source %s : %w", source.Name, err)
+		}
+		go r.run(ctx, db, source)
+	}
+	return nil
+}
+
+func (r *OutboxRelay) run(ctx context.Context, db *sql.DB, source OutboxSource) {
+	defer db.Close()
+	for ctx.Err() == nil {
+		relayed, err := r.Poll(ctx, db, source)
+		if err != nil && ctx.Err() == nil {
+			log.Warn(ctx).Err(err).Str(constants.SourceKey, source.Name).Msg("error relaying outbox")
+		}
+		if relayed == source.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Duration(source.PollIntervalInMillis) * time.Millisecond):
+		}
+	}
+}
+
+// Poll is used to relay the next batch of rows of the source and get the number of relayed rows
+func (r *OutboxRelay) Poll(ctx context.Context, db *sql.DB, source OutboxSource) (int, error) {
+	typeColumn := "NULL"
+	if source.TypeColumn != "" {
+		typeColumn = source.TypeColumn
+	}
+	placeholder := "$1"
+	if source.Driver != constants.PostgresqlDriverName {
+		placeholder = "?"
+	}
+	query := fmt.Sprintf("SELECT %s, %s, %s FROM %s WHERE %s > %s ORDER BY %s LIMIT %d", source.IDColumn,
+		typeColumn, source.PayloadColumn, source.Table, source.IDColumn, placeholder, source.IDColumn,
+		source.BatchSize)
+	r.mu.Lock()
+	offset, generation := r.offsets[source.Name], r.generations[source.Name]
+	r.mu.Unlock()
+	rows, err := db.QueryContext(ctx, query, offset)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	relayed := 0
+	for rows.Next() {
+		var id int64
+		var entryType sql.NullString
+		var payload []byte
+		if err = rows.Scan(&id, &entryType, &payload); err != nil {
+			break
+		}
+		if !entryType.Valid || entryType.String == "" {
+			entryType.String = source.Type
+		}
+		entry, err := decodeMessage("", payload, entryType.String)
+		if err == nil {
+			if entry.Data == nil {
+				entry.Data = make(map[string]interface{})
+			}
+			entry.Data[constants.OutboxIDField] = source.Name + ":" + strconv.FormatInt(id, 10)
+			err = ingest.Entry(ctx, &entry)
+		}
+		if err != nil {
+			// a rejected row would block the outbox forever, so it is skipped
+			log.Warn(ctx).Err(err).Str(constants.SourceKey, source.Name).Int64(constants.IDKey, id).
+				Msg("rejected outbox row")
+		}
+		offset = id
+		relayed++
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	if relayed > 0 {
+		if saveErr := r.save(source.Name, generation, offset); saveErr != nil {
+			return relayed, saveErr
+		}
+	}
+	return relayed, err
+}
+
+// save is used to record the offset of the source relayed in the generation, it is ignored when it is not ahead
+// of the recorded one or the offset was reset since
+func (r *OutboxRelay) save(name string, generation int, offset int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if generation != r.generations[name] || offset <= r.offsets[name] {
+		return nil
+	}
+	r.offsets[name] = offset
+	r.committed[name] = time.Now()
+	return r.write()
+}
+
+// write is used to write the offsets to the file, it is replaced atomically
+func (r *OutboxRelay) write() error {
+	data, err := json.Marshal(r.offsets)
+	if err != nil {
+		return err
+	}
+	return files.WriteAtomic(r.config.OffsetsPath, data)
+}
+
+// checkpoints are used to get the offsets of the sources
+func (r *OutboxRelay) checkpoints() map[string]Checkpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	checkpoints := make(map[string]Checkpoint, len(r.config.Sources))
+	for _, source := range r.config.Sources {
+		checkpoints[source.Name] = Checkpoint{Position: strconv.FormatInt(r.offsets[source.Name], 10),
+			CommittedAt: r.committed[source.Name], Generation: r.generations[source.Name]}
+	}
+	return checkpoints
+}
+
+// reset is used to move the offset of the source to the row id, the rows after it are relayed next
+func (r *OutboxRelay) reset(name, position string, _ time.Time, now time.Time) error {
+	known := false
+	for _, source := range r.config.Sources {
+		known = known || source.Name == name
+	}
+	if !known {
+		return fmt.Errorf("%w %s", ErrUnknownCheckpoint, name)
+	}
+	offset, err := strconv.ParseInt(position, 10, 64)
+	if err != nil || offset < 0 {
+		return fmt.Errorf("%w %q : outbox positions are row ids", ErrInvalidPosition, position)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.offsets[name] = offset
+	r.generations[name]++
+	r.committed[name] = now
+	return r.write()
+}
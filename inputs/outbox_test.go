@@ -0,0 +1,111 @@
+package inputs_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/inputs"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// outboxRows are the rows of the fake outbox table, the query returns those after the offset argument
+var outboxRows = [][]driver.Value{
+	{int64(1), "orders", []byte(`{"orderId":"a1"}`)},
+	{int64(2), nil, []byte(`{"type":"payments","data":{"paymentId":"p1"}}`)},
+	{int64(3), "orders", []byte(`{"orderId":"a2"}`)},
+}
+
+type outboxDriver struct{}
+
+func (outboxDriver) Open(string) (driver.Conn, error) { return outboxConn{}, nil }
+
+type outboxConn struct{}
+
+func (outboxConn) Prepare(query string) (driver.Stmt, error) {
+	_, limit, _ := strings.Cut(query, " LIMIT ")
+	size, err := strconv.Atoi(limit)
+	return outboxStmt{limit: size}, err
+}
+func (outboxConn) Close() error              { return nil }
+func (outboxConn) Begin() (driver.Tx, error) { return nil, driver.ErrSkip }
+
+type outboxStmt struct {
+	limit int
+}
+
+func (outboxStmt) Close() error                               { return nil }
+func (outboxStmt) NumInput() int                              { return 1 }
+func (outboxStmt) Exec([]driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+func (s outboxStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows := &outboxResult{}
+	for _, row := range outboxRows {
+		if row[0].(int64) > args[0].(int64) && len(rows.rows) < s.limit {
+			rows.rows = append(rows.rows, row)
+		}
+	}
+	return rows, nil
+}
+
+type outboxResult struct {
+	rows [][]driver.Value
+}
+
+func (r *outboxResult) Columns() []string { return []string{"id", "type", "payload"} }
+func (r *outboxResult) Close() error      { return nil }
+func (r *outboxResult) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestOutboxRelay(t *testing.T) {
+	ctx := context.Background()
+	store.Init(store.NewMemory(10))
+	sql.Register("outbox", outboxDriver{})
+	db, err := sql.Open("outbox", "")
+	assert.NoError(t, err)
+	offsets := filepath.Join(t.TempDir(), "offsets.json")
+	config := inputs.OutboxConfig{OffsetsPath: offsets, Sources: []inputs.OutboxSource{{
+		Name: "orders-db", Driver: "outbox", URL: "fake", Table: "public.outbox", TypeColumn: "type", BatchSize: 2,
+	}}}
+
+	relay, err := inputs.NewOutboxRelay(config)
+	assert.NoError(t, err)
+	relayed, err := relay.Poll(ctx, db, config.Sources[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 2, relayed)
+	data, err := os.ReadFile(offsets)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"orders-db":2}`, string(data))
+
+	// a restarted relay continues after the recorded offset
+	relay, err = inputs.NewOutboxRelay(config)
+	assert.NoError(t, err)
+	relayed, err = relay.Poll(ctx, db, config.Sources[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, relayed)
+
+	records, err := store.Get().Query(ctx, store.Query{Type: "orders"})
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	records, err = store.Get().Query(ctx, store.Query{Type: "payments"})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "orders-db:2", records[0].Entry.Data["outboxId"])
+	}
+
+	config.Sources[0].Table = "outbox; drop table orders"
+	_, err = inputs.NewOutboxRelay(config)
+	assert.Error(t, err)
+}
@@ -0,0 +1,93 @@
+package inputs
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protoMessage is a decoded protobuf message keyed by field number, used to read the few
+// well known messages the inputs need without generated code
+type protoMessage map[protowire.Number][]protoValue
+
+type protoValue struct {
+	number uint64
+	bytes  []byte
+}
+
+// parseProto is used to decode the fields of a message
+func parseProto(data []byte) (protoMessage, error) {
+	message := make(protoMessage)
+	for len(data) > 0 {
+		number, kind, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		var value protoValue
+		switch kind {
+		case protowire.VarintType:
+			value.number, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			value.number, n = protowire.ConsumeFixed64(data)
+		case protowire.Fixed32Type:
+			var fixed uint32
+			fixed, n = protowire.ConsumeFixed32(data)
+			value.number = uint64(fixed)
+		case protowire.BytesType:
+			value.bytes, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(number, kind, data)
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("field %d : %w", number, protowire.ParseError(n))
+		}
+		data = data[n:]
+		message[number] = append(message[number], value)
+	}
+	return message, nil
+}
+
+func (m protoMessage) last(number protowire.Number) (protoValue, bool) {
+	values := m[number]
+	if len(values) == 0 {
+		return protoValue{}, false
+	}
+	return values[len(values)-1], true
+}
+
+func (m protoMessage) string(number protowire.Number) string {
+	value, _ := m.last(number)
+	return string(value.bytes)
+}
+
+func (m protoMessage) uint(number protowire.Number) uint64 {
+	value, _ := m.last(number)
+	return value.number
+}
+
+// message is used to decode an embedded message, an absent message is empty
+func (m protoMessage) message(number protowire.Number) protoMessage {
+	value, ok := m.last(number)
+	if !ok {
+		return protoMessage{}
+	}
+	message, err := parseProto(value.bytes)
+	if err != nil {
+		return protoMessage{}
+	}
+	return message
+}
+
+// messages is used to decode a repeated embedded message
+func (m protoMessage) messages(number protowire.Number) ([]protoMessage, error) {
+	messages := make([]protoMessage, 0, len(m[number]))
+	for _, value := range m[number] {
+		message, err := parseProto(value.bytes)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
@@ -0,0 +1,232 @@
+package inputs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStreamsConfig is the configuration of the optional redis streams consumer
+type RedisStreamsConfig struct {
+	// URL is the redis url of the server, the consumer is disabled when empty
+	URL string `json:"-" mapstructure:"url"`
+	// Streams are the streams consumed from
+	Streams []string `json:"streams" mapstructure:"streams"`
+	// Field is the field of the messages holding the entry, the fields of the messages without it are the data
+	Field string `json:"field" mapstructure:"field"`
+	// Type is the type of the entries for messages that are not entries themselves
+	Type string `json:"type" mapstructure:"type"`
+	// Count is the number of messages read at once
+	Count int64 `json:"count" mapstructure:"count"`
+	// BlockInMillis is how long a read waits for new messages
+	BlockInMillis int `json:"blockInMillis" mapstructure:"blockInMillis"`
+	// CheckpointsPath is the file the position of every stream is kept in, it is required with streams
+	CheckpointsPath string `json:"checkpointsPath" mapstructure:"checkpointsPath"`
+	// ReplayWindowInSeconds is how far back a stream without a checkpoint is read from, so a new instance
+	// neither replays the whole stream nor skips what was added while it was starting. -1 reads it all.
+	ReplayWindowInSeconds int `json:"replayWindowInSeconds" mapstructure:"replayWindowInSeconds"`
+}
+
+// StreamReader reads the messages of redis streams, the redis client is one
+type StreamReader interface {
+	XRead(ctx context.Context, a *redis.XReadArgs) *redis.XStreamSliceCmd
+}
+
+// RedisStreamsConsumer ingests the messages of redis streams in order. The id of the last message ingested of
+// every stream is checkpointed after each read, so a restarted instance resumes right after it. Messages are
+// ingested at least once, a crash between the ingestion and the checkpoint reads them again with the same
+// streamId, which can be used to drop the duplicates.
+type RedisStreamsConsumer struct {
+	config RedisStreamsConfig
+	store  *checkpointStore
+}
+
+// NewRedisStreamsConsumer is used to create the consumer for the config and load the recorded checkpoints
+func NewRedisStreamsConsumer(config RedisStreamsConfig) (*RedisStreamsConsumer, error) {
+	if len(config.Streams) == 0 || config.CheckpointsPath == "" {
+		return nil, fmt.Errorf("redis streams consumer needs streams and a checkpoints path")
+	}
+	if config.Field == "" {
+		config.Field = constants.DefaultRedisStreamField
+	}
+	if config.Type == "" {
+		config.Type = constants.DefaultRedisStreamEntryType
+	}
+	if config.Count <= 0 {
+		config.Count = constants.DefaultRedisStreamCount
+	}
+	if config.BlockInMillis <= 0 {
+		config.BlockInMillis = constants.DefaultRedisStreamBlockInMillis
+	}
+	if config.ReplayWindowInSeconds == 0 {
+		config.ReplayWindowInSeconds = constants.DefaultReplayWindowInSeconds
+	}
+	store, err := loadCheckpoints(config.CheckpointsPath, compareStreamIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStreamsConsumer{config: config, store: store}, nil
+}
+
+// Start is used to consume in the background until the context is done, reconnecting when reads fail
+func (r *RedisStreamsConsumer) Start(ctx context.Context) error {
+	options, err := redis.ParseURL(r.config.URL)
+	if err != nil {
+		return fmt.Errorf("invalid redis streams url : %w", err)
+	}
+	client := redis.NewClient(options)
+	register(constants.RedisStreamsInput, r)
+	go func() {
+		defer client.Close()
+		for ctx.Err() == nil {
+			if _, err := r.Poll(ctx, client, time.Now()); err != nil && ctx.Err() == nil {
+				log.Warn(ctx).Err(err).Strs(constants.StreamKey, r.config.Streams).Msg("error consuming redis streams")
+				select {
+				case <-ctx.Done():
+				case <-time.After(constants.RedisStreamRetryInSeconds * time.Second):
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Poll is used to read and ingest the next messages of the streams after their checkpoints and get the number
+// of ingested messages. A stream without a checkpoint starts at the replay window.
+func (r *RedisStreamsConsumer) Poll(ctx context.Context, reader StreamReader, now time.Time) (int, error) {
+	start := constants.RedisStreamStart
+	if r.config.ReplayWindowInSeconds > 0 {
+		start = streamIDBefore(now.Add(-time.Duration(r.config.ReplayWindowInSeconds) * time.Second))
+	}
+	generations := make(map[string]int, len(r.config.Streams))
+	args := &redis.XReadArgs{Streams: make([]string, 2*len(r.config.Streams)), Count: r.config.Count,
+		Block: time.Duration(r.config.BlockInMillis) * time.Millisecond}
+	for i, stream := range r.config.Streams {
+		checkpoint, ok := r.store.get(stream)
+		if !ok {
+			checkpoint.Position = start
+			// the start is recorded, so an instance restarting before the first message keeps it
+			if err := r.store.commit(stream, 0, checkpoint.Position, now); err != nil {
+				return 0, err
+			}
+		}
+		generations[stream] = checkpoint.Generation
+		args.Streams[i], args.Streams[len(r.config.Streams)+i] = stream, checkpoint.Position
+	}
+	streams, err := reader.XRead(ctx, args).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	ingested := 0
+	for _, stream := range streams {
+		for _, message := range stream.Messages {
+			entry, err := decodeStreamMessage(message.Values, r.config.Field, r.config.Type)
+			if err == nil {
+				if entry.Data == nil {
+					entry.Data = make(map[string]interface{})
+				}
+				entry.Data[constants.StreamIDField] = stream.Stream + ":" + message.ID
+				err = ingest.Entry(ctx, &entry)
+			}
+			if err != nil {
+				// a rejected message would block the stream forever, so it is skipped
+				log.Warn(ctx).Err(err).Str(constants.StreamKey, stream.Stream).Str(constants.IDKey, message.ID).
+					Msg("rejected redis stream message")
+			}
+			ingested++
+		}
+		if len(stream.Messages) > 0 {
+			last := stream.Messages[len(stream.Messages)-1].ID
+			if err = r.store.commit(stream.Stream, generations[stream.Stream], last, time.Now()); err != nil {
+				return ingested, err
+			}
+		}
+	}
+	return ingested, nil
+}
+
+// checkpoints are used to get the checkpoints of the streams
+func (r *RedisStreamsConsumer) checkpoints() map[string]Checkpoint {
+	return r.store.all()
+}
+
+// reset is used to move the checkpoint of the stream to the message id, or to the messages added from the time
+func (r *RedisStreamsConsumer) reset(stream, position string, at time.Time, now time.Time) error {
+	known := false
+	for _, s := range r.config.Streams {
+		known = known || s == stream
+	}
+	if !known {
+		return fmt.Errorf("%w %s", ErrUnknownCheckpoint, stream)
+	}
+	if position == "" {
+		position = streamIDBefore(at)
+	}
+	return r.store.reset(stream, position, now)
+}
+
+// decodeStreamMessage is used to read an entry from the field of a stream message, or from all its fields
+func decodeStreamMessage(values map[string]interface{}, field, entryType string) (models.LogEntry, error) {
+	if value, ok := values[field]; ok {
+		return decodeMessage("", []byte(fmt.Sprint(value)), entryType)
+	}
+	return models.LogEntry{Type: entryType, Data: values}, nil
+}
+
+// streamIDBefore is used to get the id right before the messages added at the time, as reads exclude the id
+func streamIDBefore(at time.Time) string {
+	millis := at.UnixMilli()
+	if millis <= 0 {
+		return constants.RedisStreamStart
+	}
+	return strconv.FormatInt(millis-1, 10) + "-" + strconv.FormatUint(math.MaxUint64, 10)
+}
+
+// compareStreamIDs is used to order two stream ids, made of the millis and the sequence of the message
+func compareStreamIDs(a, b string) (int, error) {
+	am, as, err := parseStreamID(a)
+	if err != nil {
+		return 0, err
+	}
+	bm, bs, err := parseStreamID(b)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case am < bm || am == bm && as < bs:
+		return -1, nil
+	case am == bm && as == bs:
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// parseStreamID is used to read the millis and the sequence of a stream id
+func parseStreamID(id string) (uint64, uint64, error) {
+	millis, sequence, ok := strings.Cut(id, "-")
+	if !ok {
+		sequence = "0"
+	}
+	m, err := strconv.ParseUint(millis, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("stream id %q has to be millis-sequence", id)
+	}
+	s, err := strconv.ParseUint(sequence, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("stream id %q has to be millis-sequence", id)
+	}
+	return m, s, nil
+}
@@ -0,0 +1,81 @@
+package inputs_test
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/inputs"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStreams returns the messages once and records the ids the streams were read after
+type fakeStreams struct {
+	after    []string
+	messages []redis.XMessage
+}
+
+func (f *fakeStreams) XRead(_ context.Context, a *redis.XReadArgs) *redis.XStreamSliceCmd {
+	f.after = a.Streams[len(a.Streams)/2:]
+	if len(f.messages) == 0 {
+		return redis.NewXStreamSliceCmdResult(nil, redis.Nil)
+	}
+	messages := f.messages
+	f.messages = nil
+	return redis.NewXStreamSliceCmdResult([]redis.XStream{{Stream: a.Streams[0], Messages: messages}}, nil)
+}
+
+func TestRedisStreamsCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	store.Init(store.NewMemory(10))
+	now := time.Now()
+	config := inputs.RedisStreamsConfig{URL: "redis://127.0.0.1:1", Streams: []string{"app"},
+		CheckpointsPath: filepath.Join(t.TempDir(), "checkpoints.json"), ReplayWindowInSeconds: 60}
+	millis := strconv.FormatInt(now.UnixMilli(), 10)
+	reader := &fakeStreams{messages: []redis.XMessage{
+		{ID: millis + "-0", Values: map[string]interface{}{"payload": `{"type":"orders","Data":{"id":1}}`}},
+		{ID: millis + "-1", Values: map[string]interface{}{"level": "warn"}},
+	}}
+
+	// a new consumer only reads back as far as the replay window
+	consumer, err := inputs.NewRedisStreamsConsumer(config)
+	assert.NoError(t, err)
+	ingested, err := consumer.Poll(ctx, reader, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ingested)
+	assert.Equal(t, []string{strconv.FormatInt(now.Add(-time.Minute).UnixMilli()-1, 10) + "-18446744073709551615"},
+		reader.after)
+	records, err := store.Get().Query(ctx, store.Query{Type: "orders"})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "app:"+millis+"-0", records[0].Entry.Data[constants.StreamIDField])
+	}
+
+	// a restarted consumer resumes after the last message ingested
+	consumer, err = inputs.NewRedisStreamsConsumer(config)
+	assert.NoError(t, err)
+	_, err = consumer.Poll(ctx, reader, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{millis + "-1"}, reader.after)
+
+	// the checkpoint is moved deliberately through the admin apis
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.NoError(t, consumer.Start(cancelled))
+	assert.ErrorIs(t, inputs.ResetCheckpoint(constants.RedisStreamsInput, "app", "not-an-id", time.Time{}),
+		inputs.ErrInvalidPosition)
+	assert.ErrorIs(t, inputs.ResetCheckpoint(constants.RedisStreamsInput, "other", "5-0", time.Time{}),
+		inputs.ErrUnknownCheckpoint)
+	assert.NoError(t, inputs.ResetCheckpoint(constants.RedisStreamsInput, "app", "5-0", time.Time{}))
+	checkpoint := inputs.Checkpoints()[constants.RedisStreamsInput]["app"]
+	assert.Equal(t, "5-0", checkpoint.Position)
+	assert.Equal(t, 1, checkpoint.Generation)
+	_, err = consumer.Poll(ctx, reader, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"5-0"}, reader.after)
+}
@@ -0,0 +1,324 @@
+package inputs
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// SMTPConfig is the configuration of the optional smtp listener for appliances that can only send email alerts
+type SMTPConfig struct {
+	// Address is the address to listen on, e.g. :2525, the listener is disabled when empty
+	Address string `json:"address" mapstructure:"address"`
+	// Domain is the name the server greets with
+	Domain string `json:"domain" mapstructure:"domain"`
+	// Type is the type of the entries created from the emails
+	Type string `json:"type" mapstructure:"type"`
+	// MaxMessageBytes is the largest accepted email
+	MaxMessageBytes int64 `json:"maxMessageBytes" mapstructure:"maxMessageBytes"`
+	// AllowedNetworks are the cidr ranges allowed to connect, all are allowed when empty
+	AllowedNetworks []string `json:"allowedNetworks" mapstructure:"allowedNetworks"`
+}
+
+// SMTPServer is a minimal smtp server turning every received email into an entry.
+// It implements the subset of RFC 5321 that alerting appliances use and does not relay anything.
+type SMTPServer struct {
+	config   SMTPConfig
+	networks []*net.IPNet
+	listener net.Listener
+}
+
+// NewSMTPServer is used to create the server for the config
+func NewSMTPServer(config SMTPConfig) (*SMTPServer, error) {
+	if config.Domain == "" {
+		config.Domain = constants.DefaultSMTPDomain
+	}
+	if config.Type == "" {
+		config.Type = constants.DefaultSMTPEntryType
+	}
+	if config.MaxMessageBytes <= 0 {
+		config.MaxMessageBytes = constants.DefaultSMTPMaxMessageBytes
+	}
+	networks, err := parseNetworks(config.AllowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("smtp %w", err)
+	}
+	return &SMTPServer{config: config, networks: networks}, nil
+}
+
+// Start is used to listen and serve in the background until the context is done
+func (s *SMTPServer) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.config.Address)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Error(ctx).Err(err).Msg("error accepting smtp connection")
+				}
+				return
+			}
+			go s.serve(ctx, conn)
+		}
+	}()
+	return nil
+}
+
+// Addr is used to get the address the server listens on
+func (s *SMTPServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// serve is used to run one smtp session
+func (s *SMTPServer) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(code int, message string) error {
+		return text.PrintfLine("%d %s", code, message)
+	}
+	_ = conn.SetDeadline(time.Now().Add(constants.SMTPTimeoutInSeconds * time.Second))
+	if !allowed(s.networks, conn.RemoteAddr()) {
+		_ = reply(554, "access denied")
+		return
+	}
+	if reply(220, s.config.Domain+" ESMTP ready") != nil {
+		return
+	}
+	var from string
+	var to []string
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			err = reply(250, s.config.Domain)
+		case "EHLO":
+			err = text.PrintfLine("250-%s\r\n250-SIZE %d\r\n250 8BITMIME", s.config.Domain, s.config.MaxMessageBytes)
+		case "MAIL":
+			from, to = address(arg), nil
+			err = reply(250, "ok")
+		case "RCPT":
+			if len(to) >= constants.SMTPMaxRecipients {
+				err = reply(452, "too many recipients")
+				break
+			}
+			to = append(to, address(arg))
+			err = reply(250, "ok")
+		case "DATA":
+			if len(to) == 0 {
+				err = reply(503, "need RCPT first")
+				break
+			}
+			if err = reply(354, "end data with <CR><LF>.<CR><LF>"); err != nil {
+				return
+			}
+			err = s.receive(ctx, text, from, to, reply)
+			from, to = "", nil
+		case "RSET":
+			from, to = "", nil
+			err = reply(250, "ok")
+		case "NOOP":
+			err = reply(250, "ok")
+		case "QUIT":
+			_ = reply(221, "bye")
+			return
+		default:
+			err = reply(502, "command not implemented")
+		}
+		if err != nil {
+			return
+		}
+		_ = conn.SetDeadline(time.Now().Add(constants.SMTPTimeoutInSeconds * time.Second))
+	}
+}
+
+// receive is used to read the email after DATA and ingest it
+func (s *SMTPServer) receive(ctx context.Context, text *textproto.Conn, from string, to []string,
+	reply func(int, string) error) error {
+	data, err := io.ReadAll(io.LimitReader(text.DotReader(), s.config.MaxMessageBytes+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > s.config.MaxMessageBytes {
+		// the rest of the message is drained so that the session stays in sync
+		_, _ = io.Copy(io.Discard, text.DotReader())
+		return reply(552, "message too large")
+	}
+	entry, err := parseEmail(data, s.config.Type)
+	if err != nil {
+		return reply(554, "invalid message : "+err.Error())
+	}
+	entry.Data[constants.EmailEnvelopeFromField] = from
+	entry.Data[constants.EmailEnvelopeToField] = to
+	if err = ingest.Entry(ctx, &entry); err != nil {
+		return reply(554, "rejected : "+err.Error())
+	}
+	return reply(250, "ok queued")
+}
+
+// address is used to get the address out of a MAIL FROM or RCPT TO argument
+func address(arg string) string {
+	if _, value, ok := strings.Cut(arg, ":"); ok {
+		arg = value
+	}
+	arg = strings.TrimSpace(arg)
+	if end := strings.Index(arg, ">"); strings.HasPrefix(arg, "<") && end > 0 {
+		return arg[1:end]
+	}
+	if fields := strings.Fields(arg); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+// parseEmail is used to map the email onto an entry with the subject, the text body and the attachment metadata
+func parseEmail(data []byte, entryType string) (models.LogEntry, error) {
+	message, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return models.LogEntry{}, err
+	}
+	decoder := new(mime.WordDecoder)
+	header := func(name string) string {
+		value := message.Header.Get(name)
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			return decoded
+		}
+		return value
+	}
+	entry := models.LogEntry{Type: entryType, Data: map[string]interface{}{
+		constants.EmailFromField:    header("From"),
+		constants.EmailToField:      header("To"),
+		constants.EmailSubjectField: header("Subject"),
+		constants.EmailIDField:      message.Header.Get("Message-Id"),
+	}}
+	if date, err := message.Header.Date(); err == nil {
+		entry.Data[constants.TimeField] = date.UTC().Format(time.RFC3339Nano)
+	}
+	body := &emailBody{}
+	if err = body.read(textproto.MIMEHeader(message.Header), message.Body, 0); err != nil {
+		return models.LogEntry{}, err
+	}
+	entry.Data[constants.MessageField] = strings.TrimSpace(body.text())
+	if len(body.attachments) > 0 {
+		entry.Data[constants.EmailAttachmentsField] = body.attachments
+	}
+	return entry, nil
+}
+
+type emailBody struct {
+	plain       strings.Builder
+	html        strings.Builder
+	attachments []map[string]interface{}
+}
+
+func (b *emailBody) text() string {
+	if b.plain.Len() > 0 {
+		return b.plain.String()
+	}
+	return stripTags(b.html.String())
+}
+
+// read is used to collect the text parts and attachments of a possibly nested multipart body
+func (b *emailBody) read(header textproto.MIMEHeader, body io.Reader, depth int) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= constants.EmailMaxNesting {
+			return fmt.Errorf("multipart nesting is deeper than %d", constants.EmailMaxNesting)
+		}
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err = b.read(part.Header, part, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	content, err := io.ReadAll(decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	switch {
+	case disposition == "attachment" || filename != "" || !strings.HasPrefix(mediaType, "text/"):
+		b.attachments = append(b.attachments, map[string]interface{}{
+			"filename":    filename,
+			"contentType": mediaType,
+			"size":        len(content),
+		})
+	case mediaType == "text/html":
+		b.html.Write(content)
+	default:
+		b.plain.Write(content)
+	}
+	return nil
+}
+
+func decodeTransfer(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// the decoder skips the line breaks the content is wrapped with
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	default:
+		return body
+	}
+}
+
+// stripTags is used to get a rough text version of an html body
+func stripTags(html string) string {
+	var text strings.Builder
+	inTag := false
+	for _, r := range html {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>':
+			inTag = false
+			text.WriteRune(' ')
+		case !inTag:
+			text.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(text.String()), " ")
+}
@@ -0,0 +1,55 @@
+package inputs_test
+
+import (
+	"context"
+	"net/smtp"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/inputs"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSMTPServer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.Init(store.NewMemory(10))
+	server, err := inputs.NewSMTPServer(inputs.SMTPConfig{Address: "127.0.0.1:0", Type: "appliance-alert"})
+	assert.NoError(t, err)
+	assert.NoError(t, server.Start(ctx))
+
+	message := strings.ReplaceAll(`From: UPS <ups@example.com>
+To: alerts@example.com
+Subject: =?UTF-8?Q?Battery_low?=
+Date: Tue, 02 Jan 2024 03:04:05 +0000
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="b"
+
+--b
+Content-Type: text/plain; charset=utf-8
+
+Battery at 10%
+--b
+Content-Type: application/octet-stream
+Content-Disposition: attachment; filename="status.bin"
+Content-Transfer-Encoding: base64
+
+AAECAw==
+--b--
+`, "\n", "\r\n")
+	err = smtp.SendMail(server.Addr().String(), nil, "ups@example.com", []string{"alerts@example.com"},
+		[]byte(message))
+	assert.NoError(t, err)
+
+	records, err := store.Get().Query(ctx, store.Query{Type: "appliance-alert"})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		data := records[0].Entry.Data
+		assert.Equal(t, "Battery low", data["subject"])
+		assert.Equal(t, "Battery at 10%", data["message"])
+		assert.Equal(t, "2024-01-02T03:04:05Z", data["time"])
+		assert.Equal(t, []map[string]interface{}{{"filename": "status.bin",
+			"contentType": "application/octet-stream", "size": 4}}, data["attachments"])
+	}
+}
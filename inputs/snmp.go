@@ -0,0 +1,270 @@
+package inputs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// snmp pdu tags
+const (
+	snmpResponse = 0xa2
+	snmpTrapV1   = 0xa4
+	snmpInform   = 0xa6
+	snmpTrapV2   = 0xa7
+)
+
+// SNMPConfig is the configuration of the optional snmp trap listener
+type SNMPConfig struct {
+	// Address is the udp address to listen on, e.g. :162, the listener is disabled when empty
+	Address string `json:"address" mapstructure:"address"`
+	// Type is the type of the entries created from the traps
+	Type string `json:"type" mapstructure:"type"`
+	// Communities are the accepted community strings, all are accepted when empty
+	Communities []string `json:"communities" mapstructure:"communities"`
+	// AllowedNetworks are the cidr ranges allowed to send traps, all are allowed when empty
+	AllowedNetworks []string `json:"allowedNetworks" mapstructure:"allowedNetworks"`
+	// Mappings name the variables and traps by oid, the longest matching oid prefix wins
+	Mappings []OIDMapping `json:"mappings" mapstructure:"mappings"`
+}
+
+// OIDMapping names an oid and everything below it
+type OIDMapping struct {
+	OID   string `json:"oid" mapstructure:"oid"`
+	Field string `json:"field" mapstructure:"field"`
+}
+
+// SNMPReceiver turns snmp v1 and v2c traps and informs into entries.
+// Mapped variables become fields of the entry and the others are kept by oid under varbinds.
+type SNMPReceiver struct {
+	config   SNMPConfig
+	networks []*net.IPNet
+	mappings map[string]string
+	conn     net.PacketConn
+}
+
+// NewSNMPReceiver is used to create the receiver for the config
+func NewSNMPReceiver(config SNMPConfig) (*SNMPReceiver, error) {
+	if config.Type == "" {
+		config.Type = constants.DefaultSNMPEntryType
+	}
+	networks, err := parseNetworks(config.AllowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("snmp %w", err)
+	}
+	r := &SNMPReceiver{config: config, networks: networks, mappings: make(map[string]string)}
+	for _, mapping := range config.Mappings {
+		r.mappings[strings.Trim(mapping.OID, ".")] = mapping.Field
+	}
+	return r, nil
+}
+
+// Start is used to listen and receive in the background until the context is done
+func (r *SNMPReceiver) Start(ctx context.Context) error {
+	conn, err := net.ListenPacket("udp", r.config.Address)
+	if err != nil {
+		return err
+	}
+	r.conn = conn
+	go func() {
+		<-ctx.Done()
+		_ = conn.Close()
+	}()
+	go func() {
+		buffer := make([]byte, constants.MaxUDPPacketBytes)
+		for {
+			n, addr, err := conn.ReadFrom(buffer)
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Error(ctx).Err(err).Msg("error reading snmp trap")
+				}
+				return
+			}
+			if !allowed(r.networks, addr) {
+				continue
+			}
+			packet := append([]byte(nil), buffer[:n]...)
+			if err = r.receive(ctx, packet, addr); err != nil {
+				log.Warn(ctx).Err(err).Str(constants.AddressKey, addr.String()).Msg("dropped snmp trap")
+			}
+		}
+	}()
+	return nil
+}
+
+// Addr is used to get the address the receiver listens on
+func (r *SNMPReceiver) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// receive is used to ingest the trap in the packet and acknowledge informs
+func (r *SNMPReceiver) receive(ctx context.Context, packet []byte, addr net.Addr) error {
+	entry, pduOffset, err := r.parse(packet)
+	if err != nil {
+		return err
+	}
+	if ip, ok := addr.(*net.UDPAddr); ok {
+		entry.Data[constants.SNMPSourceField] = ip.IP.String()
+	}
+	if err = ingest.Entry(ctx, &entry); err != nil {
+		return err
+	}
+	if packet[pduOffset] == snmpInform {
+		// the response to an inform is the same message with the response pdu tag
+		packet[pduOffset] = snmpResponse
+		_, err = r.conn.WriteTo(packet, addr)
+	}
+	return err
+}
+
+// parse is used to decode the message and get the entry and the offset of the pdu in the packet
+func (r *SNMPReceiver) parse(packet []byte) (models.LogEntry, int, error) {
+	message, rest, err := readBER(packet)
+	if err != nil || message.tag != berSequence || len(rest) > 0 {
+		return models.LogEntry{}, 0, fmt.Errorf("invalid snmp message")
+	}
+	fields, err := message.children()
+	if err != nil || len(fields) != 3 {
+		return models.LogEntry{}, 0, fmt.Errorf("invalid snmp message")
+	}
+	version, err := fields[0].int()
+	if err != nil || (version != 0 && version != 1) {
+		return models.LogEntry{}, 0, fmt.Errorf("unsupported snmp version %d", version+1)
+	}
+	if !r.community(string(fields[1].content)) {
+		return models.LogEntry{}, 0, fmt.Errorf("unknown snmp community")
+	}
+	pdu := fields[2]
+	entry := models.LogEntry{Type: r.config.Type, Data: make(map[string]interface{})}
+	var varbinds berValue
+	switch {
+	case version == 0 && pdu.tag == snmpTrapV1:
+		entry.Data[constants.SNMPVersionField] = "v1"
+		varbinds, err = r.parseTrapV1(pdu, entry.Data)
+	case version == 1 && (pdu.tag == snmpTrapV2 || pdu.tag == snmpInform):
+		entry.Data[constants.SNMPVersionField] = "v2c"
+		var values []berValue
+		if values, err = pdu.children(); err == nil && len(values) != 4 {
+			err = fmt.Errorf("invalid snmp pdu")
+		}
+		if err == nil {
+			varbinds = values[3]
+		}
+	default:
+		return models.LogEntry{}, 0, fmt.Errorf("unsupported snmp pdu %#x", pdu.tag)
+	}
+	if err != nil {
+		return models.LogEntry{}, 0, err
+	}
+	if err = r.parseVarbinds(varbinds, entry.Data); err != nil {
+		return models.LogEntry{}, 0, err
+	}
+	return entry, len(message.raw) - len(pdu.raw), nil
+}
+
+// parseTrapV1 is used to read the v1 trap header and get the varbinds.
+// The trap oid is derived as in RFC 3584 so that v1 and v2c traps can be mapped the same way.
+func (r *SNMPReceiver) parseTrapV1(pdu berValue, data map[string]interface{}) (berValue, error) {
+	values, err := pdu.children()
+	if err != nil || len(values) != 6 {
+		return berValue{}, fmt.Errorf("invalid snmp v1 trap")
+	}
+	enterprise, err := values[0].oid()
+	if err != nil {
+		return berValue{}, err
+	}
+	generic, err := values[2].int()
+	if err != nil {
+		return berValue{}, err
+	}
+	specific, err := values[3].int()
+	if err != nil {
+		return berValue{}, err
+	}
+	trapOID := fmt.Sprintf("%s.%d", constants.SNMPGenericTrapsOID, generic+1)
+	if generic == constants.SNMPEnterpriseSpecificTrap {
+		trapOID = fmt.Sprintf("%s.0.%d", enterprise, specific)
+	}
+	data[constants.SNMPEnterpriseField] = enterprise
+	data[constants.SNMPAgentAddressField] = values[1].value()
+	data[constants.SNMPUptimeField] = values[4].value()
+	r.setTrap(trapOID, data)
+	return values[5], nil
+}
+
+// parseVarbinds is used to set the mapped variables as fields and keep the others under varbinds
+func (r *SNMPReceiver) parseVarbinds(varbinds berValue, data map[string]interface{}) error {
+	list, err := varbinds.children()
+	if err != nil {
+		return err
+	}
+	unmapped := make(map[string]interface{})
+	for _, varbind := range list {
+		pair, err := varbind.children()
+		if err != nil || len(pair) != 2 {
+			return fmt.Errorf("invalid snmp varbind")
+		}
+		oid, err := pair[0].oid()
+		if err != nil {
+			return err
+		}
+		switch oid {
+		case constants.SNMPUptimeOID:
+			data[constants.SNMPUptimeField] = pair[1].value()
+		case constants.SNMPTrapOID:
+			trapOID, _ := pair[1].oid()
+			r.setTrap(trapOID, data)
+		default:
+			if field, ok := r.lookup(oid); ok {
+				data[field] = pair[1].value()
+			} else {
+				unmapped[oid] = pair[1].value()
+			}
+		}
+	}
+	if len(unmapped) > 0 {
+		data[constants.SNMPVarbindsField] = unmapped
+	}
+	return nil
+}
+
+func (r *SNMPReceiver) setTrap(oid string, data map[string]interface{}) {
+	data[constants.SNMPTrapOIDField] = oid
+	if name, ok := r.lookup(oid); ok {
+		data[constants.SNMPTrapField] = name
+	}
+}
+
+// lookup is used to get the field of the longest mapped prefix of the oid
+func (r *SNMPReceiver) lookup(oid string) (string, bool) {
+	for prefix := oid; prefix != ""; {
+		if field, ok := r.mappings[prefix]; ok {
+			return field, true
+		}
+		i := strings.LastIndex(prefix, ".")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return "", false
+}
+
+func (r *SNMPReceiver) community(community string) bool {
+	if len(r.config.Communities) == 0 {
+		return true
+	}
+	for _, allowed := range r.config.Communities {
+		if allowed == community {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package inputs_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/inputs"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func tlv(tag byte, content ...[]byte) []byte {
+	var body []byte
+	for _, c := range content {
+		body = append(body, c...)
+	}
+	return append([]byte{tag, byte(len(body))}, body...)
+}
+
+func oid(ids ...byte) []byte {
+	return tlv(0x06, append([]byte{ids[0]*40 + ids[1]}, ids[2:]...))
+}
+
+func TestSNMPInform(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.Init(store.NewMemory(10))
+	receiver, err := inputs.NewSNMPReceiver(inputs.SNMPConfig{
+		Address:     "127.0.0.1:0",
+		Communities: []string{"public"},
+		Mappings: []inputs.OIDMapping{
+			{OID: "1.3.6.1.6.3.1.1.5.3", Field: "linkDown"},
+			{OID: "1.3.6.1.2.1.2.2.1.2", Field: "ifDescr"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, receiver.Start(ctx))
+
+	varbinds := tlv(0x30,
+		tlv(0x30, oid(1, 3, 6, 1, 2, 1, 1, 3, 0), tlv(0x43, []byte{0x01, 0x00})),
+		tlv(0x30, oid(1, 3, 6, 1, 6, 3, 1, 1, 4, 1, 0), oid(1, 3, 6, 1, 6, 3, 1, 1, 5, 3)),
+		tlv(0x30, oid(1, 3, 6, 1, 2, 1, 2, 2, 1, 2, 7), tlv(0x04, []byte("eth7"))),
+		tlv(0x30, oid(1, 3, 6, 1, 4, 1, 9), tlv(0x02, []byte{0xff})),
+	)
+	inform := tlv(0x30, tlv(0x02, []byte{1}), tlv(0x04, []byte("public")),
+		tlv(0xa6, tlv(0x02, []byte{42}), tlv(0x02, []byte{0}), tlv(0x02, []byte{0}), varbinds))
+
+	conn, err := net.Dial("udp", receiver.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(inform)
+	assert.NoError(t, err)
+	response := make([]byte, 512)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(response)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(0xa2), response[n-len(varbinds)-11])
+
+	records, err := store.Get().Query(ctx, store.Query{Type: "snmp-trap"})
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		data := records[0].Entry.Data
+		assert.Equal(t, "linkDown", data["trap"])
+		assert.Equal(t, "eth7", data["ifDescr"])
+		assert.Equal(t, uint64(256), data["uptime"])
+		assert.Equal(t, map[string]interface{}{"1.3.6.1.4.1.9": int64(-1)}, data["varbinds"])
+	}
+}
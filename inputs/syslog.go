@@ -0,0 +1,453 @@
+package inputs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/ingest"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+)
+
+// syslogFacilities are the names of the facilities of RFC 5424 by their code, they are the types of the entries
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp", "ntp",
+	"audit", "alert", "clock", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// syslogSeverities are the names of the severities of RFC 5424 by their code
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// syslogLevels are the levels of the entries by the code of the severity
+var syslogLevels = []string{
+	constants.FatalLevel, constants.FatalLevel, constants.FatalLevel, constants.ErrorLevel, constants.WarnLevel,
+	constants.InfoLevel, constants.InfoLevel, constants.DebugLevel,
+}
+
+// utf8BOM is the byte order mark starting the utf-8 messages
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// SyslogConfig is the configuration of the optional syslog listener for network appliances
+type SyslogConfig struct {
+	// UDPAddress is the udp address to listen on, e.g. :514, there is no udp listener when empty
+	UDPAddress string `json:"udpAddress" mapstructure:"udpAddress"`
+	// TCPAddress is the tcp address to listen on, e.g. :601, there is no tcp listener when empty
+	TCPAddress string `json:"tcpAddress" mapstructure:"tcpAddress"`
+	// MaxMessageBytes is the largest accepted message, the tcp connections sending larger ones are closed
+	MaxMessageBytes int `json:"maxMessageBytes" mapstructure:"maxMessageBytes"`
+	// AllowedNetworks are the cidr ranges allowed to send messages, all are allowed when empty
+	AllowedNetworks []string `json:"allowedNetworks" mapstructure:"allowedNetworks"`
+}
+
+// SyslogReceiver turns RFC 5424 syslog messages into entries of the type of their facility.
+// Messages are datagrams over udp, and framed by octet counting or by new lines over tcp as in RFC 6587.
+// The appliances sending a stack trace a line a message have the messages of a stream, the ones of the same
+// source, host, app and process, joined by the multiline rules of their type like the lines of the text inputs.
+type SyslogReceiver struct {
+	config   SyslogConfig
+	networks []*net.IPNet
+	conn     net.PacketConn
+	listener net.Listener
+	mu       sync.Mutex
+	// pending are the entries of the streams whose next message may continue them, by stream
+	pending map[string]*pendingSyslog
+}
+
+// pendingSyslog is an entry held until a message that does not continue it, or until the wait is over
+type pendingSyslog struct {
+	entry models.LogEntry
+	addr  net.Addr
+	lines int
+	timer *time.Timer
+}
+
+// NewSyslogReceiver is used to create the receiver for the config
+func NewSyslogReceiver(config SyslogConfig) (*SyslogReceiver, error) {
+	if config.MaxMessageBytes <= 0 {
+		config.MaxMessageBytes = constants.DefaultSyslogMaxMessageBytes
+	}
+	networks, err := parseNetworks(config.AllowedNetworks)
+	if err != nil {
+		return nil, fmt.Errorf("syslog %w", err)
+	}
+	return &SyslogReceiver{config: config, networks: networks, pending: make(map[string]*pendingSyslog)}, nil
+}
+
+// Start is used to listen and receive in the background until the context is done
+func (r *SyslogReceiver) Start(ctx context.Context) error {
+	if r.config.UDPAddress != "" {
+		conn, err := net.ListenPacket("udp", r.config.UDPAddress)
+		if err != nil {
+			return err
+		}
+		r.conn = conn
+		go func() {
+			<-ctx.Done()
+			_ = conn.Close()
+		}()
+		go r.receivePackets(ctx)
+	}
+	if r.config.TCPAddress != "" {
+		listener, err := net.Listen("tcp", r.config.TCPAddress)
+		if err != nil {
+			if r.conn != nil {
+				_ = r.conn.Close()
+			}
+			return err
+		}
+		r.listener = listener
+		go func() {
+			<-ctx.Done()
+			_ = listener.Close()
+		}()
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					if !errors.Is(err, net.ErrClosed) {
+						log.Error(ctx).Err(err).Msg("error accepting syslog connection")
+					}
+					return
+				}
+				go r.serve(ctx, conn)
+			}
+		}()
+	}
+	return nil
+}
+
+// UDPAddr is used to get the udp address the receiver listens on, nil without a udp listener
+func (r *SyslogReceiver) UDPAddr() net.Addr {
+	if r.conn == nil {
+		return nil
+	}
+	return r.conn.LocalAddr()
+}
+
+// TCPAddr is used to get the tcp address the receiver listens on, nil without a tcp listener
+func (r *SyslogReceiver) TCPAddr() net.Addr {
+	if r.listener == nil {
+		return nil
+	}
+	return r.listener.Addr()
+}
+
+// receivePackets is used to ingest the message of every datagram
+func (r *SyslogReceiver) receivePackets(ctx context.Context) {
+	buffer := make([]byte, constants.MaxUDPPacketBytes)
+	for {
+		n, addr, err := r.conn.ReadFrom(buffer)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Error(ctx).Err(err).Msg("error reading syslog message")
+			}
+			return
+		}
+		if !allowed(r.networks, addr) || n > r.config.MaxMessageBytes {
+			continue
+		}
+		r.receive(ctx, bytes.TrimRight(buffer[:n], "\r\n\x00"), addr)
+	}
+}
+
+// serve is used to ingest the messages of a tcp connection until it is closed or idle
+func (r *SyslogReceiver) serve(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+	if !allowed(r.networks, conn.RemoteAddr()) {
+		return
+	}
+	reader := bufio.NewReader(conn)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(constants.SyslogIdleTimeoutInSeconds * time.Second))
+		frame, err := r.readFrame(reader)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Warn(ctx).Err(err).Str(constants.AddressKey, conn.RemoteAddr().String()).
+					Msg("closed syslog connection")
+			}
+			return
+		}
+		if len(frame) > 0 {
+			r.receive(ctx, frame, conn.RemoteAddr())
+		}
+	}
+}
+
+// readFrame is used to read the next message of a tcp stream, framed by its length when it starts with a digit
+// and by a new line otherwise
+func (r *SyslogReceiver) readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+	if first[0] >= '0' && first[0] <= '9' {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			return nil, fmt.Errorf("invalid syslog frame length : %w", err)
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		if err != nil || size <= 0 {
+			return nil, fmt.Errorf("invalid syslog frame length %q", length)
+		}
+		if size > r.config.MaxMessageBytes {
+			return nil, fmt.Errorf("syslog message of %d bytes is over the limit of %d bytes", size,
+				r.config.MaxMessageBytes)
+		}
+		frame := make([]byte, size)
+		if _, err = io.ReadFull(reader, frame); err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(frame, "\r\n"), nil
+	}
+	var frame []byte
+	for {
+		line, err := reader.ReadSlice('\n')
+		frame = append(frame, line...)
+		if len(frame) > r.config.MaxMessageBytes {
+			return nil, fmt.Errorf("syslog message is over the limit of %d bytes", r.config.MaxMessageBytes)
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err != nil && (len(frame) == 0 || !errors.Is(err, io.EOF)) {
+			return nil, err
+		}
+		return bytes.TrimRight(frame, "\r\n\x00"), nil
+	}
+}
+
+// receive is used to ingest the message, or join it to the entry of its stream it continues, the invalid and
+// rejected ones are dropped
+func (r *SyslogReceiver) receive(ctx context.Context, message []byte, addr net.Addr) {
+	entry, err := parseSyslog(message)
+	if err != nil {
+		log.Warn(ctx).Err(err).Str(constants.AddressKey, addr.String()).Msg("dropped syslog message")
+		return
+	}
+	if host, _, splitErr := net.SplitHostPort(addr.String()); splitErr == nil {
+		entry.Data[constants.SyslogSourceField] = host
+	}
+	reassembler := pipeline.Get().Reassembler()
+	text, _ := entry.Data[constants.MessageField].(string)
+	stream := fmt.Sprint(entry.Type, "|", entry.Data[constants.SyslogSourceField], "|", entry.Data[constants.HostField],
+		"|", entry.Data[constants.SyslogAppNameField], "|", entry.Data[constants.SyslogProcIDField])
+	wait := constants.SyslogMultilineWaitInMillis * time.Millisecond
+
+	r.mu.Lock()
+	previous := r.pending[stream]
+	if previous != nil && reassembler.Continues(entry.Type, previous.lines, text) {
+		joined, _ := previous.entry.Data[constants.MessageField].(string)
+		previous.entry.Data[constants.MessageField] = joined + "\n" + text
+		previous.lines++
+		previous.timer.Reset(wait)
+		r.mu.Unlock()
+		return
+	}
+	if previous != nil {
+		previous.timer.Stop()
+		delete(r.pending, stream)
+	}
+	held := reassembler.Joins(entry.Type)
+	if held {
+		pending := &pendingSyslog{entry: entry, addr: addr, lines: 1}
+		pending.timer = time.AfterFunc(wait, func() { r.expire(ctx, stream, pending) })
+		r.pending[stream] = pending
+	}
+	r.mu.Unlock()
+
+	if previous != nil {
+		r.ingest(ctx, previous.entry, previous.addr)
+	}
+	if !held {
+		r.ingest(ctx, entry, addr)
+	}
+}
+
+// expire is used to ingest the entry of the stream once no message continued it for the wait
+func (r *SyslogReceiver) expire(ctx context.Context, stream string, pending *pendingSyslog) {
+	r.mu.Lock()
+	if r.pending[stream] != pending {
+		// a message that does not continue it already took it out
+		r.mu.Unlock()
+		return
+	}
+	delete(r.pending, stream)
+	r.mu.Unlock()
+	r.ingest(ctx, pending.entry, pending.addr)
+}
+
+// ingest is used to ingest the entry, the rejected ones are dropped
+func (r *SyslogReceiver) ingest(ctx context.Context, entry models.LogEntry, addr net.Addr) {
+	if err := ingest.Entry(ctx, &entry); err != nil {
+		log.Warn(ctx).Err(err).Str(constants.AddressKey, addr.String()).Msg("dropped syslog message")
+	}
+}
+
+// parseSyslog is used to map an RFC 5424 message onto an entry of the type of its facility, with the level of
+// its severity and the header fields, the structured data and the message as data
+func parseSyslog(message []byte) (models.LogEntry, error) {
+	p := &syslogParser{data: message}
+	priority, err := p.priority()
+	if err != nil {
+		return models.LogEntry{}, err
+	}
+	if version := p.field(); version != constants.SyslogVersion {
+		return models.LogEntry{}, fmt.Errorf("unsupported syslog version %q", version)
+	}
+	timestamp, hostname, appName, procID, msgID := p.field(), p.field(), p.field(), p.field(), p.field()
+	if p.err != nil {
+		return models.LogEntry{}, p.err
+	}
+	severity := priority % 8
+	entry := models.LogEntry{Type: syslogFacilities[priority/8], Level: syslogLevels[severity],
+		Data: map[string]interface{}{constants.SyslogSeverityField: syslogSeverities[severity]}}
+	if timestamp != constants.SyslogNilValue {
+		at, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return models.LogEntry{}, fmt.Errorf("invalid syslog timestamp %q", timestamp)
+		}
+		entry.Timestamp = &models.Timestamp{Time: at}
+	}
+	for field, value := range map[string]string{constants.HostField: hostname, constants.SyslogAppNameField: appName,
+		constants.SyslogProcIDField: procID, constants.SyslogMsgIDField: msgID} {
+		if value != constants.SyslogNilValue {
+			entry.Data[field] = value
+		}
+	}
+	structuredData, err := p.structuredData()
+	if err != nil {
+		return models.LogEntry{}, err
+	}
+	if len(structuredData) > 0 {
+		entry.Data[constants.SyslogStructuredDataField] = structuredData
+	}
+	if p.pos < len(p.data) {
+		if p.data[p.pos] != ' ' {
+			return models.LogEntry{}, fmt.Errorf("invalid syslog message after the structured data")
+		}
+		entry.Data[constants.MessageField] = string(bytes.TrimPrefix(p.data[p.pos+1:], utf8BOM))
+	}
+	return entry, nil
+}
+
+// syslogParser reads the parts of an RFC 5424 message in order
+type syslogParser struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+// priority is used to read the priority, the facility times eight plus the severity
+func (p *syslogParser) priority() (int, error) {
+	end := bytes.IndexByte(p.data, '>')
+	if len(p.data) == 0 || p.data[0] != '<' || end < 2 || end > 4 {
+		return 0, fmt.Errorf("invalid syslog priority")
+	}
+	priority, err := strconv.Atoi(string(p.data[1:end]))
+	if err != nil || priority < 0 || priority > constants.SyslogMaxPriority {
+		return 0, fmt.Errorf("invalid syslog priority %q", p.data[1:end])
+	}
+	p.pos = end + 1
+	return priority, nil
+}
+
+// field is used to read the next header field, up to the next space
+func (p *syslogParser) field() string {
+	if p.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(p.data[p.pos:], ' ')
+	if end <= 0 {
+		p.err = fmt.Errorf("invalid syslog header")
+		return ""
+	}
+	value := string(p.data[p.pos : p.pos+end])
+	p.pos += end + 1
+	return value
+}
+
+// structuredData is used to read the structured data elements as the maps of their parameters by their id,
+// the values of repeated parameters are kept in order in a list
+func (p *syslogParser) structuredData() (map[string]interface{}, error) {
+	if bytes.HasPrefix(p.data[p.pos:], []byte(constants.SyslogNilValue)) {
+		p.pos++
+		return nil, nil
+	}
+	elements := make(map[string]interface{})
+	for p.pos < len(p.data) && p.data[p.pos] == '[' {
+		p.pos++
+		id := p.name(" ]")
+		if id == "" {
+			return nil, fmt.Errorf("invalid syslog structured data id")
+		}
+		params := make(map[string]interface{})
+		for p.pos < len(p.data) && p.data[p.pos] == ' ' {
+			p.pos++
+			name := p.name("=")
+			if name == "" || !bytes.HasPrefix(p.data[p.pos:], []byte(`="`)) {
+				return nil, fmt.Errorf("invalid syslog structured data parameter of %s", id)
+			}
+			p.pos += 2
+			value, err := p.value()
+			if err != nil {
+				return nil, fmt.Errorf("invalid syslog structured data parameter %s of %s : %w", name, id, err)
+			}
+			switch previous := params[name].(type) {
+			case nil:
+				params[name] = value
+			case []interface{}:
+				params[name] = append(previous, value)
+			default:
+				params[name] = []interface{}{previous, value}
+			}
+		}
+		if p.pos >= len(p.data) || p.data[p.pos] != ']' {
+			return nil, fmt.Errorf("unterminated syslog structured data element %s", id)
+		}
+		p.pos++
+		elements[id] = params
+	}
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("invalid syslog structured data")
+	}
+	return elements, nil
+}
+
+// name is used to read a structured data id or parameter name, up to one of the delimiters
+func (p *syslogParser) name(delimiters string) string {
+	start := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] > ' ' && p.data[p.pos] != '"' &&
+		strings.IndexByte(delimiters, p.data[p.pos]) < 0 {
+		p.pos++
+	}
+	return string(p.data[start:p.pos])
+}
+
+// value is used to read a quoted parameter value, in which the quote, the backslash and the bracket are escaped
+func (p *syslogParser) value() (string, error) {
+	var value strings.Builder
+	for ; p.pos < len(p.data); p.pos++ {
+		switch c := p.data[p.pos]; {
+		case c == '"':
+			p.pos++
+			return value.String(), nil
+		case c == '\\' && p.pos+1 < len(p.data) && strings.IndexByte(`"\]`, p.data[p.pos+1]) >= 0:
+			p.pos++
+			value.WriteByte(p.data[p.pos])
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated value")
+}
@@ -0,0 +1,132 @@
+package inputs_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/inputs"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+// queryEventually is used to wait for the entries of the type to be ingested
+func queryEventually(t *testing.T, ctx context.Context, entryType string, count int) []store.Record {
+	var records []store.Record
+	assert.Eventually(t, func() bool {
+		var err error
+		records, err = store.Get().Query(ctx, store.Query{Type: entryType})
+		return err == nil && len(records) >= count
+	}, 5*time.Second, 10*time.Millisecond)
+	return records
+}
+
+func TestSyslogUDP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.Init(store.NewMemory(10))
+	receiver, err := inputs.NewSyslogReceiver(inputs.SyslogConfig{UDPAddress: "127.0.0.1:0"})
+	assert.NoError(t, err)
+	assert.NoError(t, receiver.Start(ctx))
+
+	conn, err := net.Dial("udp", receiver.UDPAddr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte(`<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog - ID47 ` +
+		`[exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][meta path="a\]b" path="c\"d"]` +
+		" \xef\xbb\xbfAn application event log entry...\n"))
+	assert.NoError(t, err)
+
+	records := queryEventually(t, ctx, "local4", 1)
+	if assert.Len(t, records, 1) {
+		entry := records[0].Entry
+		assert.Equal(t, "info", entry.Level)
+		assert.Equal(t, time.Date(2003, 10, 11, 22, 14, 15, 3e6, time.UTC), entry.Timestamp.UTC())
+		assert.Equal(t, "notice", entry.Data["severity"])
+		assert.Equal(t, "mymachine.example.com", entry.Data["host"])
+		assert.Equal(t, "evntslog", entry.Data["appName"])
+		assert.Equal(t, "ID47", entry.Data["msgId"])
+		assert.NotContains(t, entry.Data, "procId")
+		assert.Equal(t, "127.0.0.1", entry.Data["source"])
+		assert.Equal(t, "An application event log entry...", entry.Data["message"])
+		assert.Equal(t, map[string]interface{}{
+			"exampleSDID@32473": map[string]interface{}{"iut": "3", "eventSource": "Application", "eventID": "1011"},
+			"meta":              map[string]interface{}{"path": []interface{}{"a]b", `c"d`}},
+		}, entry.Data["structuredData"])
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.Init(store.NewMemory(10))
+	receiver, err := inputs.NewSyslogReceiver(inputs.SyslogConfig{TCPAddress: "127.0.0.1:0", MaxMessageBytes: 100})
+	assert.NoError(t, err)
+	assert.NoError(t, receiver.Start(ctx))
+
+	conn, err := net.Dial("tcp", receiver.TCPAddr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	counted := "<34>1 - router su 42 - - 'su root' failed on /dev/pts/8"
+	invalid := "<34>2 - router su 42 - - unsupported version"
+	_, err = fmt.Fprintf(conn, "%d %s%s\n<87>1 - router cron - - - job done\n", len(counted), counted, invalid)
+	assert.NoError(t, err)
+
+	records := queryEventually(t, ctx, "auth", 1)
+	if assert.Len(t, records, 1) {
+		entry := records[0].Entry
+		assert.Equal(t, "fatal", entry.Level)
+		assert.Equal(t, "42", entry.Data["procId"])
+		assert.Equal(t, "'su root' failed on /dev/pts/8", entry.Data["message"])
+		assert.NotContains(t, entry.Data, "structuredData")
+	}
+	records = queryEventually(t, ctx, "authpriv", 1)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "debug", records[0].Entry.Level)
+		assert.Equal(t, "job done", records[0].Entry.Data["message"])
+	}
+
+	// a frame over the limit closes the connection
+	_, err = fmt.Fprintf(conn, "101 ")
+	assert.NoError(t, err)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+func TestSyslogMultiline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store.Init(store.NewMemory(10))
+	assert.NoError(t, pipeline.Init(pipeline.Config{Multiline: []pipeline.MultilineRule{
+		{Type: "local4", Continuation: `^\s+at `}}}))
+	defer func() { _ = pipeline.Init(pipeline.Config{}) }()
+	receiver, err := inputs.NewSyslogReceiver(inputs.SyslogConfig{UDPAddress: "127.0.0.1:0"})
+	assert.NoError(t, err)
+	assert.NoError(t, receiver.Start(ctx))
+
+	conn, err := net.Dial("udp", receiver.UDPAddr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+	// the lines of a stack trace are sent a message each, the stream of another process is not joined
+	for _, message := range []string{"java.lang.IllegalStateException: closed", "  at Pool.get(Pool.java:42)",
+		"  at Main.main(Main.java:7)", "started"} {
+		_, err = fmt.Fprintf(conn, "<165>1 - appliance app 7 - - %s", message)
+		assert.NoError(t, err)
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, err = fmt.Fprintf(conn, "<165>1 - appliance app 8 - -   at Other.run(Other.java:1)")
+	assert.NoError(t, err)
+
+	records := queryEventually(t, ctx, "local4", 3)
+	messages := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		messages = append(messages, record.Entry.Data["message"])
+	}
+	assert.ElementsMatch(t, []interface{}{
+		"java.lang.IllegalStateException: closed\n  at Pool.get(Pool.java:42)\n  at Main.main(Main.java:7)",
+		"started", "  at Other.run(Other.java:1)"}, messages)
+}
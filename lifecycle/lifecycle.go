@@ -0,0 +1,299 @@
+// Package lifecycle sends the lifecycle and governance events of the service, such as a new log type being seen,
+// a spike of schema violations, a tenant exceeding its quota or the dead letter queue crossing its threshold, to
+// webhooks as CloudEvents, so platform automation can react without polling the admin apis.
+package lifecycle
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// Config is the configuration of the lifecycle events
+type Config struct {
+	// Webhooks receive the events they subscribe to
+	Webhooks []Webhook `json:"webhooks" mapstructure:"webhooks"`
+	// TimeoutInMillis is the timeout of a webhook call
+	TimeoutInMillis int `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+	// IntervalInSeconds is the minimum time between events of the same type and subject, e.g. the quota of a tenant
+	IntervalInSeconds int `json:"intervalInSeconds" mapstructure:"intervalInSeconds"`
+	// RedisURL is the set of the log types seen shared by the processes, so a new type is only announced once.
+	// Each process announces the types it sees first without it.
+	RedisURL string `json:"-" mapstructure:"redisUrl"`
+	// Key is the redis set of the log types seen
+	Key string `json:"key" mapstructure:"key"`
+	// SchemaViolationThreshold is the number of entries of a type rejected by its schema within the window that
+	// makes a spike, spikes are not reported without it
+	SchemaViolationThreshold int `json:"schemaViolationThreshold" mapstructure:"schemaViolationThreshold"`
+	// SchemaViolationWindowInSeconds is the window the violations are counted in
+	SchemaViolationWindowInSeconds int `json:"schemaViolationWindowInSeconds" mapstructure:"schemaViolationWindowInSeconds"`
+	// DeadLetterThreshold is the number of dead letters reported when crossed, it is not reported without it
+	DeadLetterThreshold int `json:"deadLetterThreshold" mapstructure:"deadLetterThreshold"`
+}
+
+// Webhook is an endpoint receiving structured mode CloudEvents
+type Webhook struct {
+	Name string `json:"name" mapstructure:"name"`
+	URL  string `json:"-" mapstructure:"url"`
+	// Events are the event types sent to the webhook, every event when empty
+	Events []string `json:"events" mapstructure:"events"`
+	// Secret signs the body, the hex hmac sha256 is sent in the X-Signature-256 header prefixed by sha256=
+	Secret string `json:"-" mapstructure:"secret"`
+}
+
+// violations is the count of the schema violations of a type in the current window
+type violations struct {
+	start    time.Time
+	count    int
+	reported bool
+}
+
+type emitter struct {
+	config     Config
+	redis      *redis.Client
+	mu         sync.Mutex
+	sent       map[string]time.Time
+	seen       map[string]bool
+	violations map[string]*violations
+	overDLQ    bool
+}
+
+var (
+	mu sync.RWMutex
+	e  *emitter
+)
+
+// Init is used to set up the webhooks, events are dropped until then
+func Init(config Config) error {
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultAlertTimeoutInMillis
+	}
+	if config.IntervalInSeconds <= 0 {
+		config.IntervalInSeconds = constants.DefaultAlertIntervalInSeconds
+	}
+	if config.SchemaViolationWindowInSeconds <= 0 {
+		config.SchemaViolationWindowInSeconds = constants.DefaultSchemaViolationWindowInSecs
+	}
+	if config.Key == "" {
+		config.Key = constants.DefaultSeenTypesKey
+	}
+	for _, webhook := range config.Webhooks {
+		if webhook.Name == "" || webhook.URL == "" {
+			return fmt.Errorf("lifecycle webhooks need a name and a url")
+		}
+	}
+	next := &emitter{config: config, sent: make(map[string]time.Time), seen: make(map[string]bool),
+		violations: make(map[string]*violations)}
+	if config.RedisURL != "" {
+		options, err := redis.ParseURL(config.RedisURL)
+		if err != nil {
+			return fmt.Errorf("invalid lifecycle redis url : %w", err)
+		}
+		next.redis = redis.NewClient(options)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	e = next
+	return nil
+}
+
+// get is used to get the emitter, nil when there are no webhooks
+func get() *emitter {
+	mu.RLock()
+	defer mu.RUnlock()
+	if e == nil || len(e.config.Webhooks) == 0 {
+		return nil
+	}
+	return e
+}
+
+// TypeSeen is used to announce the log type the first time an entry of it is stored
+func TypeSeen(ctx context.Context, logType string) {
+	em := get()
+	if em == nil {
+		return
+	}
+	em.mu.Lock()
+	known := em.seen[logType] || len(em.seen) >= constants.MaxSeenTypes
+	if !known {
+		em.seen[logType] = true
+	}
+	em.mu.Unlock()
+	if known {
+		return
+	}
+	if em.redis != nil {
+		added, err := em.redis.SAdd(ctx, em.config.Key, logType).Result()
+		if err != nil {
+			log.Warn(ctx).Err(err).Str(constants.TypeKey, logType).Msg("error recording log type seen")
+		}
+		if err != nil || added == 0 {
+			return
+		}
+	}
+	em.emit(ctx, constants.LogTypeSeenEvent, logType, map[string]interface{}{constants.TypeKey: logType})
+}
+
+// SchemaViolation is used to count an entry of the type rejected by its schema, a spike is reported once per
+// window when the violations reach the threshold
+func SchemaViolation(ctx context.Context, logType string, now time.Time) {
+	em := get()
+	if em == nil || em.config.SchemaViolationThreshold <= 0 {
+		return
+	}
+	window := time.Duration(em.config.SchemaViolationWindowInSeconds) * time.Second
+	em.mu.Lock()
+	v, ok := em.violations[logType]
+	if !ok || now.Sub(v.start) >= window {
+		if !ok && len(em.violations) >= constants.MaxSeenTypes {
+			em.mu.Unlock()
+			return
+		}
+		v = &violations{start: now}
+		em.violations[logType] = v
+	}
+	v.count++
+	spiked := !v.reported && v.count >= em.config.SchemaViolationThreshold
+	if spiked {
+		v.reported = true
+	}
+	count := v.count
+	em.mu.Unlock()
+	if spiked {
+		em.emit(ctx, constants.SchemaViolationSpikeEvent, logType, map[string]interface{}{
+			constants.TypeKey: logType, "violations": count, "windowInSeconds": em.config.SchemaViolationWindowInSeconds,
+		})
+	}
+}
+
+// QuotaExceeded is used to report an entry of the tenant rejected by its quotas
+func QuotaExceeded(ctx context.Context, tenant string, cause error) {
+	em := get()
+	if em == nil {
+		return
+	}
+	em.emit(ctx, constants.QuotaExceededEvent, tenant, map[string]interface{}{
+		constants.TenantKey: tenant, constants.ErrorKey: cause.Error(),
+	})
+}
+
+// DeadLetters is used to report the number of dead letters, the threshold is reported when crossed upwards and
+// again only once the letters went back below it
+func DeadLetters(ctx context.Context, count int) {
+	em := get()
+	if em == nil || em.config.DeadLetterThreshold <= 0 {
+		return
+	}
+	em.mu.Lock()
+	crossed := !em.overDLQ && count >= em.config.DeadLetterThreshold
+	em.overDLQ = count >= em.config.DeadLetterThreshold
+	em.mu.Unlock()
+	if crossed {
+		em.emit(ctx, constants.DeadLetterThresholdEvent, "", map[string]interface{}{
+			"letters": count, "threshold": em.config.DeadLetterThreshold,
+		})
+	}
+}
+
+// emit is used to send the event to the webhooks subscribed to it in the background, throttled by type and subject
+func (em *emitter) emit(ctx context.Context, eventType, subject string, data map[string]interface{}) {
+	if !em.allow(eventType + ":" + subject) {
+		return
+	}
+	now := time.Now()
+	event := formats.CloudEvent{
+		SpecVersion:     constants.CloudEventsSpecVersion,
+		ID:              uuid.NewString(),
+		Source:          constants.CloudEventsSource,
+		Type:            eventType,
+		Subject:         subject,
+		Time:            now.UTC().Format(time.RFC3339Nano),
+		DataContentType: constants.JSONMediaType,
+		Data:            data,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		log.Error(ctx).Err(err).Str(constants.EventKey, eventType).Msg("error encoding lifecycle event")
+		return
+	}
+	for _, webhook := range em.config.Webhooks {
+		if !webhook.subscribed(eventType) {
+			continue
+		}
+		go func(webhook Webhook) {
+			if err := em.post(webhook, body); err != nil {
+				log.Error(ctx).Err(err).Str(constants.WebhookKey, webhook.Name).Str(constants.EventKey, eventType).
+					Msg("error sending lifecycle event")
+			}
+		}(webhook)
+	}
+}
+
+// allow is used to check whether the key was not sent within the interval and record it
+func (em *emitter) allow(key string) bool {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	now := time.Now()
+	interval := time.Duration(em.config.IntervalInSeconds) * time.Second
+	if last, ok := em.sent[key]; ok && now.Sub(last) < interval {
+		return false
+	}
+	if len(em.sent) >= constants.MaxAlertKeys {
+		for k, last := range em.sent {
+			if now.Sub(last) >= interval {
+				delete(em.sent, k)
+			}
+		}
+	}
+	em.sent[key] = now
+	return true
+}
+
+// subscribed is used to check whether the webhook receives the events of the type
+func (w Webhook) subscribed(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// post is used to send the event to the webhook
+func (em *emitter) post(webhook Webhook, body []byte) error {
+	headers := map[string]string{"Content-Type": constants.CloudEventsMediaType}
+	if webhook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(webhook.Secret))
+		mac.Write(body)
+		headers[constants.SignatureHeader] = constants.SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+	}
+	response, err := httpclient.POSTWithTimeout(webhook.URL, headers, bytes.NewReader(body),
+		time.Duration(em.config.TimeoutInMillis)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("lifecycle webhook responded %d", response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package lifecycle_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/lifecycle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvents(t *testing.T) {
+	events := make(chan map[string]interface{}, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, constants.CloudEventsMediaType, r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		var event map[string]interface{}
+		assert.NoError(t, json.Unmarshal(body, &event))
+		events <- event
+	}))
+	defer server.Close()
+	assert.NoError(t, lifecycle.Init(lifecycle.Config{
+		Webhooks: []lifecycle.Webhook{{Name: "platform", URL: server.URL,
+			Events: []string{constants.LogTypeSeenEvent, constants.SchemaViolationSpikeEvent}}},
+		SchemaViolationThreshold: 2,
+	}))
+	receive := func() map[string]interface{} {
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(2 * time.Second):
+			t.Fatal("no event received")
+			return nil
+		}
+	}
+
+	ctx := context.Background()
+	lifecycle.TypeSeen(ctx, "order")
+	lifecycle.TypeSeen(ctx, "order")
+	event := receive()
+	assert.Equal(t, constants.LogTypeSeenEvent, event["type"])
+	assert.Equal(t, "order", event["subject"])
+	assert.Equal(t, constants.CloudEventsSpecVersion, event["specversion"])
+
+	// a spike is reported once the violations reach the threshold, and not for every violation after it
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		lifecycle.SchemaViolation(ctx, "payment", now)
+	}
+	event = receive()
+	assert.Equal(t, constants.SchemaViolationSpikeEvent, event["type"])
+	assert.Equal(t, float64(2), event["data"].(map[string]interface{})["violations"])
+
+	// the webhook is not subscribed to quota events
+	lifecycle.QuotaExceeded(ctx, "acme", assert.AnError)
+	select {
+	case event = <-events:
+		t.Fatalf("unexpected event %v", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
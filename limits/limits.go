@@ -0,0 +1,183 @@
+// Package limits guards the service against oversized payloads, as a single huge or deeply nested request can
+// take the memory of a whole pod. Bodies are limited while they are read, and the data of every entry is checked
+// before it is processed.
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+var (
+	// ErrBodyTooLarge is returned when reading a body over the size limit
+	ErrBodyTooLarge = errors.New("body is too large")
+	// ErrDataTooLarge is returned for entries whose data is over one of the limits
+	ErrDataTooLarge = errors.New("data is too large")
+)
+
+// Config is the configuration of the payload limits, the defaults apply to the ones that are 0
+type Config struct {
+	// MaxBodyBytes is the size of the largest body read
+	MaxBodyBytes int64 `json:"maxBodyBytes" mapstructure:"maxBodyBytes"`
+	// MaxBatchEntries is the number of entries of the largest batch
+	MaxBatchEntries int `json:"maxBatchEntries" mapstructure:"maxBatchEntries"`
+	// MaxDataDepth is how deep the objects and arrays of the data of an entry can be nested
+	MaxDataDepth int `json:"maxDataDepth" mapstructure:"maxDataDepth"`
+	// MaxDataKeys is the number of keys of the data of an entry, counting the keys of the nested objects
+	MaxDataKeys int `json:"maxDataKeys" mapstructure:"maxDataKeys"`
+	// MaxStringLength is the length of the longest string of an entry, in bytes
+	MaxStringLength int `json:"maxStringLength" mapstructure:"maxStringLength"`
+}
+
+// Limits checks the payloads against the limits of its config
+type Limits struct {
+	config Config
+}
+
+var l = New(Config{})
+
+// New is used to create the limits of the config
+func New(config Config) *Limits {
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = constants.DefaultMaxBodyBytes
+	}
+	if config.MaxBatchEntries <= 0 {
+		config.MaxBatchEntries = constants.MaxBatchEntries
+	}
+	if config.MaxDataDepth <= 0 {
+		config.MaxDataDepth = constants.DefaultMaxDataDepth
+	}
+	if config.MaxDataKeys <= 0 {
+		config.MaxDataKeys = constants.DefaultMaxDataKeys
+	}
+	if config.MaxStringLength <= 0 {
+		config.MaxStringLength = constants.DefaultMaxStringLength
+	}
+	return &Limits{config: config}
+}
+
+// Init is used to replace the default limits
+func Init(config Config) {
+	l = New(config)
+}
+
+// Get is used to get the default limits
+func Get() *Limits {
+	return l
+}
+
+// MaxBodyBytes is used to get the size of the largest body read
+func (l *Limits) MaxBodyBytes() int64 {
+	return l.config.MaxBodyBytes
+}
+
+// MaxBatchEntries is used to get the number of entries of the largest batch
+func (l *Limits) MaxBatchEntries() int {
+	return l.config.MaxBatchEntries
+}
+
+// Body is used to get the body failing with ErrBodyTooLarge once more than the limit is read from it
+func (l *Limits) Body(body io.ReadCloser) io.ReadCloser {
+	return &limitedBody{ReadCloser: body, limit: l.config.MaxBodyBytes, remaining: l.config.MaxBodyBytes}
+}
+
+// BodyError is used to get the error of a body of the size, nil when it is within the limit
+func (l *Limits) BodyError(size int64) error {
+	if size <= l.config.MaxBodyBytes {
+		return nil
+	}
+	return fmt.Errorf("%w, %d bytes is over the limit of %d bytes", ErrBodyTooLarge, size, l.config.MaxBodyBytes)
+}
+
+// Check is used to reject the entry when its data is nested too deep, has too many keys or too long strings
+func (l *Limits) Check(entry models.LogEntry) error {
+	if err := l.checkString(constants.TypeField, entry.Type); err != nil {
+		return err
+	}
+	for key, value := range entry.Labels {
+		if err := l.checkString(constants.LabelsField+"."+key, value); err != nil {
+			return err
+		}
+	}
+	keys := 0
+	return l.check(constants.DataField, entry.Data, 1, &keys)
+}
+
+// check is used to walk the value at the path and depth, counting the keys of its objects
+func (l *Limits) check(path string, value interface{}, depth int, keys *int) error {
+	switch value := value.(type) {
+	case string:
+		return l.checkString(path, value)
+	case map[string]interface{}:
+		if depth > l.config.MaxDataDepth {
+			return fmt.Errorf("%w, %s is nested deeper than the limit of %d levels", ErrDataTooLarge, path,
+				l.config.MaxDataDepth)
+		}
+		if *keys += len(value); *keys > l.config.MaxDataKeys {
+			return fmt.Errorf("%w, it has more than the limit of %d keys", ErrDataTooLarge, l.config.MaxDataKeys)
+		}
+		for key, nested := range value {
+			if err := l.checkString(join(path, key), key); err != nil {
+				return err
+			}
+			if err := l.check(join(path, key), nested, depth+1, keys); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if depth > l.config.MaxDataDepth {
+			return fmt.Errorf("%w, %s is nested deeper than the limit of %d levels", ErrDataTooLarge, path,
+				l.config.MaxDataDepth)
+		}
+		for i, nested := range value {
+			if err := l.check(fmt.Sprintf("%s[%d]", path, i), nested, depth+1, keys); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *Limits) checkString(path, value string) error {
+	if len(value) <= l.config.MaxStringLength {
+		return nil
+	}
+	return fmt.Errorf("%w, %s is %d bytes long, over the limit of %d bytes", ErrDataTooLarge, path,
+		len(value), l.config.MaxStringLength)
+}
+
+func join(path, key string) string {
+	return path + "." + key
+}
+
+// limitedBody is a body failing once more than the limit is read from it
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, b.err()
+	}
+	// one byte more than the limit is read, to tell a body of exactly the limit from a larger one
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n, b.remaining = int(b.remaining), -1
+		return n, b.err()
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) err() error {
+	return fmt.Errorf("%w, the limit is %d bytes", ErrBodyTooLarge, b.limit)
+}
@@ -0,0 +1,41 @@
+package limits_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/limits"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits(t *testing.T) {
+	l := limits.New(limits.Config{MaxBodyBytes: 10, MaxDataDepth: 2, MaxDataKeys: 3, MaxStringLength: 5})
+
+	body, err := io.ReadAll(l.Body(io.NopCloser(strings.NewReader("0123456789"))))
+	assert.NoError(t, err)
+	assert.Len(t, body, 10)
+	_, err = io.ReadAll(l.Body(io.NopCloser(strings.NewReader("0123456789a"))))
+	assert.ErrorIs(t, err, limits.ErrBodyTooLarge)
+	assert.ErrorIs(t, l.BodyError(11), limits.ErrBodyTooLarge)
+	assert.NoError(t, l.BodyError(-1))
+
+	entry := func(data map[string]interface{}) models.LogEntry {
+		return models.LogEntry{Type: "app", Data: data}
+	}
+	assert.NoError(t, l.Check(entry(map[string]interface{}{"a": map[string]interface{}{"b": "short"}})))
+	err = l.Check(entry(map[string]interface{}{"a": map[string]interface{}{"b": []interface{}{1}}}))
+	assert.ErrorIs(t, err, limits.ErrDataTooLarge)
+	assert.Contains(t, err.Error(), "data.a.b")
+	err = l.Check(entry(map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4}))
+	assert.ErrorIs(t, err, limits.ErrDataTooLarge)
+	err = l.Check(entry(map[string]interface{}{"a": []interface{}{"toolong"}}))
+	assert.ErrorIs(t, err, limits.ErrDataTooLarge)
+	assert.Contains(t, err.Error(), "data.a[0]")
+	assert.ErrorIs(t, l.Check(models.LogEntry{Type: "app", Labels: map[string]string{"k": "toolong"}}),
+		limits.ErrDataTooLarge)
+	assert.ErrorIs(t, l.Check(entry(map[string]interface{}{string(bytes.Repeat([]byte("k"), 6)): 1})),
+		limits.ErrDataTooLarge)
+}
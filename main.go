@@ -2,33 +2,134 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/angel-one/go-utils/log"
 	"github.com/angel-one/go-utils/middlewares"
-	"github.com/angel-one/nbu-logger-service/api"
+	"github.com/angel-one/nbu-logger-service/app"
 	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/angel-one/nbu-logger-service/schemas"
+	"github.com/angel-one/nbu-logger-service/utils/configs"
 	"github.com/angel-one/nbu-logger-service/utils/flags"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/pflag"
 )
 
 func main() {
 	//set up logger
 	startLogger()
-	// Start the HTTP server and listen on port
-	startRouter()
+	// read the command line
+	config := parseFlags()
+	// load the configurations
+	initConfigs(config)
+	// run the rules tests of the config repository instead of serving
+	if config.TestRules != "" {
+		os.Exit(runRulesTest(config.TestRules))
+	}
+	// wire the service and serve until it is signalled to stop
+	run(config)
 }
 
 func startLogger() {
 	log.InitLogger(log.Level(constants.InfoLevel))
 }
 
-func startRouter() {
+func parseFlags() flags.Config {
+	config, err := flags.Parse(os.Args[1:])
+	if errors.Is(err, pflag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(context.Background()).Err(err).Msg("invalid flags")
+	}
+	return config
+}
+
+func initConfigs(config flags.Config) {
+	configs.Init(config.BaseConfigPath)
+}
+
+func run(config flags.Config) {
+	ctx := context.Background()
+	application, err := app.New(app.Config{Env: config.Env, Port: config.Port, Mode: config.Mode}, app.Dependencies{
+		Configs:     configs.Get,
+		Middlewares: []gin.HandlerFunc{middlewares.Logger(middlewares.LoggerMiddlewareOptions{})},
+	})
+	if err != nil {
+		log.Fatal(ctx).Err(err).Msg("error wiring application")
+	}
+	if err = application.Start(ctx); err != nil {
+		log.Fatal(ctx).Err(err).Msg("error starting application")
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err = <-application.Done():
+		if err != nil {
+			log.Fatal(ctx).Err(err).Msg("error serving http api")
+		}
+	case <-signals:
+		log.Info(ctx).Msg("stopping application")
+	}
+	ctx, cancel := context.WithTimeout(ctx, constants.ShutdownTimeoutInSeconds*time.Second)
+	defer cancel()
+	if err = application.Stop(ctx); err != nil {
+		log.Error(ctx).Err(err).Msg("error stopping application")
+	}
+}
+
+// runRulesTest is used to run the suite against the pipeline config and the schemas and get the exit code,
+// 1 when a case fails and 2 when the suite, the rules or the schemas are invalid
+func runRulesTest(path string) int {
 	ctx := context.Background()
-	// get router
-	router := api.GetRouter(middlewares.Logger(middlewares.LoggerMiddlewareOptions{}))
-	// now start router
-	err := router.Run(fmt.Sprintf(":%d", flags.Port()))
+	file, err := os.Open(path)
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("error opening rules test")
+		return 2
+	}
+	defer file.Close()
+	test, err := pipeline.ReadRulesTest(file)
 	if err != nil {
-		log.Fatal(ctx).Err(err).Msg("error starting router")
+		log.Error(ctx).Err(err).Msg("error reading rules test")
+		return 2
+	}
+	var config pipeline.Config
+	if provider, err := configs.Get(constants.PipelineConfig); err == nil {
+		if err = provider.Unmarshal(&config); err != nil {
+			log.Error(ctx).Err(err).Msg("error parsing pipeline config")
+			return 2
+		}
+	}
+	// the samples are checked against the schemas of the schemas directory, as they are at ingestion
+	var schemasConfig schemas.Config
+	if provider, err := configs.Get(constants.SchemasConfig); err == nil {
+		if err = provider.Unmarshal(&schemasConfig); err != nil {
+			log.Error(ctx).Err(err).Msg("error parsing schemas config")
+			return 2
+		}
+	}
+	schemas.Register(registry.Get())
+	if err = schemas.Init(schemasConfig, registry.Get()); err != nil {
+		log.Error(ctx).Err(err).Msg("error loading schemas")
+		return 2
+	}
+	report, err := pipeline.RunRulesTest(ctx, config, test)
+	if err != nil {
+		log.Error(ctx).Err(err).Msg("invalid rules")
+		return 2
+	}
+	encoded, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(encoded))
+	if !report.Passed {
+		return 1
 	}
+	return 0
 }
@@ -0,0 +1,347 @@
+// Package metrics derives prometheus metrics from the entries matching configured rules, counting them or
+// observing a numeric field of them in histograms, so that dashboards do not have to query the log store.
+// The metrics are exposed in the prometheus text format, or in the OpenMetrics one with the traces of example
+// observations of the histograms as exemplars, and start over when the rules are replaced.
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// Config is the configuration of the metrics derived from the entries
+type Config struct {
+	Rules []Rule `json:"rules" mapstructure:"rules"`
+	// MaxSeries is the number of label combinations of the rules that do not set their own
+	MaxSeries int `json:"maxSeries" mapstructure:"maxSeries"`
+}
+
+// Rule derives a metric from the entries matching its filter
+type Rule struct {
+	// Name is the name of the metric, e.g. payments_failed_total
+	Name string `json:"name" mapstructure:"name"`
+	Help string `json:"help" mapstructure:"help"`
+	// Kind is counter or histogram
+	Kind string `json:"kind" mapstructure:"kind"`
+	// Filter is the filter expression of the entries the metric is derived from, every entry when empty
+	Filter string `json:"filter" mapstructure:"filter"`
+	// Field is the numeric field observed by a histogram, or added up by a counter instead of counting the
+	// entries. Entries without a numeric value of it are skipped.
+	Field string `json:"field" mapstructure:"field"`
+	// Buckets are the upper bounds of the buckets of a histogram, the prometheus defaults when empty
+	Buckets []float64 `json:"buckets" mapstructure:"buckets"`
+	// Labels are the labels of the metric and the fields of the entries they are taken from
+	Labels []Label `json:"labels" mapstructure:"labels"`
+	// MaxSeries caps the label combinations, the entries of further combinations are counted in a single
+	// series whose labels are all __overflow__
+	MaxSeries int `json:"maxSeries" mapstructure:"maxSeries"`
+}
+
+// Label is a label of a metric taken from a field of the entries
+type Label struct {
+	Name  string `json:"name" mapstructure:"name"`
+	Field string `json:"field" mapstructure:"field"`
+}
+
+// defaultBuckets are the default buckets of the prometheus clients
+var defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// helpEscaper escapes help texts for the text format
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// labelEscaper escapes label values for the text format, and help texts for the OpenMetrics one
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// series is a label combination of a metric
+type series struct {
+	values []string
+	// value is the value of a counter and the sum of a histogram
+	value float64
+	count uint64
+	// buckets are the counts of the observations up to each bound, not cumulated
+	buckets []uint64
+	// exemplars are the last observations of each bucket logged in a trace, the +Inf one last
+	exemplars []*exemplar
+}
+
+// exemplar is an observation of a histogram logged in a trace, linking the bucket to an example trace
+type exemplar struct {
+	traceID string
+	spanID  string
+	value   float64
+	at      time.Time
+}
+
+type metric struct {
+	rule   Rule
+	filter *filter.Filter
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// Registry holds the metrics of the rules
+type Registry struct {
+	metrics []*metric
+}
+
+var r = &Registry{}
+
+// New is used to create the metrics of the rules of the config
+func New(config Config) (*Registry, error) {
+	if config.MaxSeries <= 0 {
+		config.MaxSeries = constants.DefaultMetricMaxSeries
+	}
+	registry := &Registry{}
+	names := make(map[string]bool, len(config.Rules))
+	for _, rule := range config.Rules {
+		if !namePattern.MatchString(rule.Name) || names[rule.Name] {
+			return nil, fmt.Errorf("metric name %q is invalid or used twice", rule.Name)
+		}
+		names[rule.Name] = true
+		switch rule.Kind {
+		case constants.CounterMetric:
+		case constants.HistogramMetric:
+			if rule.Field == "" {
+				return nil, fmt.Errorf("histogram %s needs a field", rule.Name)
+			}
+			if len(rule.Buckets) == 0 {
+				rule.Buckets = defaultBuckets
+			}
+			if !sort.Float64sAreSorted(rule.Buckets) {
+				return nil, fmt.Errorf("buckets of histogram %s have to be sorted", rule.Name)
+			}
+		default:
+			return nil, fmt.Errorf("metric %s has to be a %s or a %s", rule.Name, constants.CounterMetric,
+				constants.HistogramMetric)
+		}
+		for _, label := range rule.Labels {
+			if !namePattern.MatchString(label.Name) || strings.HasPrefix(label.Name, "__") ||
+				label.Name == constants.BucketLabel || label.Field == "" {
+				return nil, fmt.Errorf("label %q of metric %s is invalid", label.Name, rule.Name)
+			}
+		}
+		if rule.MaxSeries <= 0 {
+			rule.MaxSeries = config.MaxSeries
+		}
+		f, err := filter.Parse(rule.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter of metric %s : %w", rule.Name, err)
+		}
+		registry.metrics = append(registry.metrics, &metric{rule: rule, filter: f, series: make(map[string]*series)})
+	}
+	return registry, nil
+}
+
+// Init is used to replace the default metrics with the ones of the config
+func Init(config Config) error {
+	registry, err := New(config)
+	if err != nil {
+		return err
+	}
+	r = registry
+	return nil
+}
+
+// Get is used to get the default metrics
+func Get() *Registry {
+	return r
+}
+
+// Observe is used to update the metrics of the rules the entry matches
+func (r *Registry) Observe(entry models.LogEntry) {
+	for _, m := range r.metrics {
+		if !m.filter.Match(entry) {
+			continue
+		}
+		value := 1.0
+		if m.rule.Field != "" {
+			field, ok := filter.Lookup(entry, m.rule.Field)
+			if value, ok = number(field, ok); !ok {
+				continue
+			}
+		}
+		values := make([]string, len(m.rule.Labels))
+		for i, label := range m.rule.Labels {
+			if field, ok := filter.Lookup(entry, label.Field); ok {
+				values[i] = fmt.Sprint(field)
+			}
+		}
+		var e *exemplar
+		if m.rule.Kind == constants.HistogramMetric && entry.TraceID != "" {
+			e = &exemplar{traceID: entry.TraceID, spanID: entry.SpanID, value: value}
+			if entry.Timestamp != nil {
+				e.at = entry.Timestamp.Time
+			}
+		}
+		m.observe(values, value, e)
+	}
+}
+
+func (m *metric) observe(values []string, value float64, e *exemplar) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := strings.Join(values, "\xff")
+	s, ok := m.series[key]
+	if !ok && len(m.series) >= m.rule.MaxSeries {
+		for i := range values {
+			values[i] = constants.OverflowLabelValue
+		}
+		key = strings.Join(values, "\xff")
+		s, ok = m.series[key]
+	}
+	if !ok {
+		s = &series{values: values}
+		if m.rule.Kind == constants.HistogramMetric {
+			s.buckets = make([]uint64, len(m.rule.Buckets))
+			s.exemplars = make([]*exemplar, len(m.rule.Buckets)+1)
+		}
+		m.series[key] = s
+	}
+	s.value += value
+	s.count++
+	if m.rule.Kind != constants.HistogramMetric {
+		return
+	}
+	bucket := sort.SearchFloat64s(m.rule.Buckets, value)
+	if bucket < len(s.buckets) {
+		s.buckets[bucket]++
+	}
+	if e != nil {
+		s.exemplars[bucket] = e
+	}
+}
+
+// Write is used to write the metrics in the prometheus text format
+func (r *Registry) Write(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	for _, m := range r.metrics {
+		m.write(b, false)
+	}
+	return b.Flush()
+}
+
+// WriteOpenMetrics is used to write the metrics in the OpenMetrics text format, with the exemplars of the
+// histogram buckets. The families of the counters are named without the _total suffix of their samples.
+func (r *Registry) WriteOpenMetrics(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	for _, m := range r.metrics {
+		m.write(b, true)
+	}
+	b.WriteString("# EOF\n")
+	return b.Flush()
+}
+
+func (m *metric) write(b *bufio.Writer, openMetrics bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	family, sample := m.rule.Name, m.rule.Name
+	if openMetrics && m.rule.Kind == constants.CounterMetric {
+		family = strings.TrimSuffix(family, constants.CounterSuffix)
+		sample = family + constants.CounterSuffix
+	}
+	if m.rule.Help != "" {
+		help := helpEscaper.Replace(m.rule.Help)
+		if openMetrics {
+			help = labelEscaper.Replace(m.rule.Help)
+		}
+		fmt.Fprintf(b, "# HELP %s %s\n", family, help)
+	}
+	fmt.Fprintf(b, "# TYPE %s %s\n", family, m.rule.Kind)
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := m.series[key]
+		if m.rule.Kind == constants.CounterMetric {
+			fmt.Fprintf(b, "%s%s %s\n", sample, m.labels(s.values, ""), format(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range m.rule.Buckets {
+			cumulative += s.buckets[i]
+			fmt.Fprintf(b, "%s_bucket%s %d", m.rule.Name, m.labels(s.values, format(bound)), cumulative)
+			writeExemplar(b, s.exemplars[i], openMetrics)
+		}
+		fmt.Fprintf(b, "%s_bucket%s %d", m.rule.Name, m.labels(s.values, "+Inf"), s.count)
+		writeExemplar(b, s.exemplars[len(m.rule.Buckets)], openMetrics)
+		fmt.Fprintf(b, "%s_sum%s %s\n", m.rule.Name, m.labels(s.values, ""), format(s.value))
+		fmt.Fprintf(b, "%s_count%s %d\n", m.rule.Name, m.labels(s.values, ""), s.count)
+	}
+}
+
+// labels is used to format the labels of the series, with the bucket bound of a histogram bucket
+func (m *metric) labels(values []string, bound string) string {
+	pairs := make([]string, 0, len(values)+1)
+	for i, label := range m.rule.Labels {
+		pairs = append(pairs, label.Name+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+	if bound != "" {
+		pairs = append(pairs, constants.BucketLabel+`="`+bound+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// writeExemplar is used to end the line of a bucket with its exemplar, which only the OpenMetrics format has
+func writeExemplar(b *bufio.Writer, e *exemplar, openMetrics bool) {
+	if openMetrics && e != nil {
+		fmt.Fprintf(b, ` # {%s="%s"`, constants.TraceIDLabel, labelEscaper.Replace(e.traceID))
+		if e.spanID != "" {
+			fmt.Fprintf(b, `,%s="%s"`, constants.SpanIDLabel, labelEscaper.Replace(e.spanID))
+		}
+		fmt.Fprintf(b, "} %s", format(e.value))
+		if !e.at.IsZero() {
+			fmt.Fprintf(b, " %s", strconv.FormatFloat(float64(e.at.UnixMicro())/1e6, 'f', -1, 64))
+		}
+	}
+	b.WriteByte('\n')
+}
+
+// format is used to format a sample value
+func format(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// number is used to get the value of a numeric field, numbers sent as strings included
+func number(value interface{}, ok bool) (float64, bool) {
+	if !ok {
+		return 0, false
+	}
+	switch value := value.(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case json.Number:
+		f, err := value.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
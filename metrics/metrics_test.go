@@ -0,0 +1,88 @@
+package metrics_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/metrics"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	registry, err := metrics.New(metrics.Config{Rules: []metrics.Rule{
+		{Name: "payments_failed_total", Help: "Failed payments", Kind: "counter", Filter: "type = payment and level = error",
+			Labels: []metrics.Label{{Name: "gateway", Field: "data.gateway"}}, MaxSeries: 2},
+		{Name: "payment_latency_seconds", Kind: "histogram", Filter: "type = payment", Field: "latency",
+			Buckets: []float64{0.1, 1}},
+	}})
+	assert.NoError(t, err)
+	payment := func(level, gateway string, latency interface{}) models.LogEntry {
+		return models.LogEntry{Type: "payment", Level: level,
+			Data: map[string]interface{}{"gateway": gateway, "latency": latency}}
+	}
+	registry.Observe(payment("error", "razorpay", 0.05))
+	registry.Observe(payment("error", "razorpay", 0.5))
+	registry.Observe(payment("error", "payu", "2"))
+	// a third gateway is over the cap of the series
+	registry.Observe(payment("error", "stripe", "slow"))
+	registry.Observe(payment("info", "stripe", 0.5))
+	registry.Observe(models.LogEntry{Type: "app", Level: "error"})
+
+	var b bytes.Buffer
+	assert.NoError(t, registry.Write(&b))
+	assert.Equal(t, `# HELP payments_failed_total Failed payments
+# TYPE payments_failed_total counter
+payments_failed_total{gateway="__overflow__"} 1
+payments_failed_total{gateway="payu"} 1
+payments_failed_total{gateway="razorpay"} 2
+# TYPE payment_latency_seconds histogram
+payment_latency_seconds_bucket{le="0.1"} 1
+payment_latency_seconds_bucket{le="1"} 3
+payment_latency_seconds_bucket{le="+Inf"} 4
+payment_latency_seconds_sum 3.05
+payment_latency_seconds_count 4
+`, b.String())
+
+	_, err = metrics.New(metrics.Config{Rules: []metrics.Rule{{Name: "latency", Kind: "histogram"}}})
+	assert.Error(t, err)
+	_, err = metrics.New(metrics.Config{Rules: []metrics.Rule{{Name: "bad-name", Kind: "counter"}}})
+	assert.Error(t, err)
+}
+
+func TestExemplars(t *testing.T) {
+	registry, err := metrics.New(metrics.Config{Rules: []metrics.Rule{
+		{Name: "payments_total", Help: `Payments "settled"`, Kind: "counter"},
+		{Name: "payment_latency_seconds", Kind: "histogram", Field: "latency", Buckets: []float64{0.1, 1}},
+	}})
+	assert.NoError(t, err)
+	at := &models.Timestamp{Time: time.Date(2026, 10, 16, 9, 30, 0, 250e6, time.UTC)}
+	registry.Observe(models.LogEntry{Type: "payment", Timestamp: at, TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanID: "00f067aa0ba902b7", Data: map[string]interface{}{"latency": 0.5}})
+	// the last observation of a bucket logged in a trace is its exemplar
+	registry.Observe(models.LogEntry{Type: "payment", Timestamp: at, TraceID: "0af7651916cd43dd8448eb211c80319c",
+		Data: map[string]interface{}{"latency": 0.7}})
+	registry.Observe(models.LogEntry{Type: "payment", Data: map[string]interface{}{"latency": 0.8}})
+	registry.Observe(models.LogEntry{Type: "payment", Data: map[string]interface{}{"latency": 0.05}})
+
+	var b bytes.Buffer
+	assert.NoError(t, registry.WriteOpenMetrics(&b))
+	assert.Equal(t, `# HELP payments Payments \"settled\"
+# TYPE payments counter
+payments_total 4
+# TYPE payment_latency_seconds histogram
+payment_latency_seconds_bucket{le="0.1"} 1
+payment_latency_seconds_bucket{le="1"} 4 # {trace_id="0af7651916cd43dd8448eb211c80319c"} 0.7 1792143000.25
+payment_latency_seconds_bucket{le="+Inf"} 4
+payment_latency_seconds_sum 2.05
+payment_latency_seconds_count 4
+# EOF
+`, b.String())
+
+	// the prometheus text format has no exemplars
+	b.Reset()
+	assert.NoError(t, registry.Write(&b))
+	assert.Contains(t, b.String(), "payment_latency_seconds_bucket{le=\"1\"} 4\n")
+	assert.Contains(t, b.String(), "payments_total 4\n")
+}
@@ -0,0 +1,12 @@
+package models
+
+// Envelope is the data of an entry encrypted by its client, the service never holds the key it was encrypted with
+type Envelope struct {
+	// KeyID identifies the key of the client the data was encrypted with
+	KeyID string `json:"keyId"`
+	// Algorithm is the cipher of the data, AES-256-GCM with the type of the entry as additional data
+	Algorithm string `json:"algorithm"`
+	// Nonce and Ciphertext are base64 encoded in json
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
@@ -1,6 +1,17 @@
 package models
 
 type LogEntry struct {
-	Type string `json:"type" binding:"required"`
-	Data map[string]interface{}
+	Type   string            `json:"type" binding:"required"`
+	Level  string            `json:"level,omitempty" binding:"omitempty,oneof=debug info warn error fatal"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// Timestamp is when the entry was logged, the time it was received when the client does not send one
+	Timestamp *Timestamp `json:"timestamp,omitempty"`
+	// ClientTimestamp is the timestamp the client sent when it was ahead of the server and had to be clamped
+	ClientTimestamp *Timestamp `json:"clientTimestamp,omitempty"`
+	Data            map[string]interface{}
+	// TraceID and SpanID are the W3C trace context the entry was logged in, to correlate it with its trace
+	TraceID string `json:"traceId,omitempty"`
+	SpanID  string `json:"spanId,omitempty"`
+	// Encrypted is the data of the entries the client encrypted, their Data only holds the server added fields
+	Encrypted *Envelope `json:"encrypted,omitempty"`
 }
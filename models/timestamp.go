@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Timestamp is a point in time accepted as an RFC3339 string or as epoch millis and written as RFC3339 in UTC
+type Timestamp struct {
+	time.Time
+}
+
+// ParseTimestamp is used to read a timestamp from an RFC3339 string or a number of epoch millis
+func ParseTimestamp(value interface{}) (Timestamp, error) {
+	switch v := value.(type) {
+	case string:
+		if at, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return Timestamp{at}, nil
+		}
+		if millis, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return Timestamp{time.UnixMilli(millis)}, nil
+		}
+		return Timestamp{}, fmt.Errorf("timestamp %q has to be RFC3339 or epoch millis", v)
+	case float64:
+		return Timestamp{time.UnixMilli(int64(v))}, nil
+	case json.Number:
+		millis, err := v.Int64()
+		if err != nil {
+			return Timestamp{}, fmt.Errorf("timestamp %s has to be epoch millis : %w", v, err)
+		}
+		return Timestamp{time.UnixMilli(millis)}, nil
+	}
+	return Timestamp{}, fmt.Errorf("timestamp of type %T has to be RFC3339 or epoch millis", value)
+}
+
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := ParseTimestamp(value)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.UTC().Format(time.RFC3339Nano))
+}
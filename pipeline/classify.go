@@ -0,0 +1,253 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+// Classifier tags an entry with categories such as timeout or auth-failure
+type Classifier interface {
+	// Classify is used to get the categories of the text of the entry
+	Classify(ctx context.Context, entry *models.LogEntry, text string) ([]string, error)
+}
+
+// ClassificationConfig is the configuration of the optional classification stage
+type ClassificationConfig struct {
+	// Field is the dotted path of the text to classify, message by default
+	Field string `json:"field" mapstructure:"field"`
+	// Keywords are the local keyword rules
+	Keywords []KeywordRule `json:"keywords" mapstructure:"keywords"`
+	// Endpoint is an optional external classification service
+	Endpoint EndpointClassifierConfig `json:"endpoint" mapstructure:"endpoint"`
+	// DetectScript enables detection of the dominant writing script as a cheap language hint
+	DetectScript bool `json:"detectScript" mapstructure:"detectScript"`
+}
+
+// KeywordRule assigns the category when any keyword or the pattern is found in the text
+type KeywordRule struct {
+	Category string   `json:"category" mapstructure:"category"`
+	Type     string   `json:"type" mapstructure:"type"`
+	Keywords []string `json:"keywords" mapstructure:"keywords"`
+	Pattern  string   `json:"pattern" mapstructure:"pattern"`
+}
+
+// EndpointClassifierConfig points to an external model that classifies the text.
+// The endpoint receives {"type": "...", "text": "..."} and responds with {"categories": ["..."]}.
+type EndpointClassifierConfig struct {
+	URL             string `json:"url" mapstructure:"url"`
+	TimeoutInMillis int    `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+}
+
+type classifyStage struct {
+	field        string
+	classifiers  []Classifier
+	detectScript bool
+}
+
+func newClassifyStage(config ClassificationConfig) (*classifyStage, error) {
+	s := &classifyStage{field: config.Field, detectScript: config.DetectScript}
+	if s.field == "" {
+		s.field = constants.MessageField
+	}
+	if len(config.Keywords) > 0 {
+		keywords, err := NewKeywordClassifier(config.Keywords)
+		if err != nil {
+			return nil, err
+		}
+		s.classifiers = append(s.classifiers, keywords)
+	}
+	if config.Endpoint.URL != "" {
+		s.classifiers = append(s.classifiers, NewEndpointClassifier(config.Endpoint))
+	}
+	return s, nil
+}
+
+func (s *classifyStage) Name() string {
+	return "classify"
+}
+
+func (s *classifyStage) Process(ctx context.Context, entry *models.LogEntry) error {
+	if len(s.classifiers) == 0 && !s.detectScript {
+		return nil
+	}
+	value, ok := getField(entry.Data, s.field)
+	if !ok {
+		return nil
+	}
+	text, ok := value.(string)
+	if !ok || text == "" {
+		return nil
+	}
+
+	if s.detectScript {
+		if script := dominantScript(text); script != "" {
+			SetMeta(entry, constants.MetaScriptKey, script)
+		}
+	}
+
+	seen := make(map[string]bool)
+	var categories []string
+	for _, classifier := range s.classifiers {
+		found, err := classifier.Classify(ctx, entry, text)
+		if err != nil {
+			// classification is best effort and never rejects an entry
+			log.Warn(ctx).Err(err).Msg("error classifying entry")
+			continue
+		}
+		for _, category := range found {
+			if !seen[category] {
+				seen[category] = true
+				categories = append(categories, category)
+			}
+		}
+	}
+	if len(categories) > 0 {
+		sort.Strings(categories)
+		SetMeta(entry, constants.MetaCategoriesKey, categories)
+	}
+	return nil
+}
+
+type keywordRule struct {
+	KeywordRule
+	keywords []string
+	pattern  *regexp.Regexp
+}
+
+// KeywordClassifier classifies using case insensitive keywords and regular expressions
+type KeywordClassifier struct {
+	rules []keywordRule
+}
+
+// NewKeywordClassifier is used to create a classifier from the keyword rules
+func NewKeywordClassifier(rules []KeywordRule) (*KeywordClassifier, error) {
+	c := &KeywordClassifier{}
+	for _, rule := range rules {
+		compiled := keywordRule{KeywordRule: rule}
+		for _, keyword := range rule.Keywords {
+			compiled.keywords = append(compiled.keywords, strings.ToLower(keyword))
+		}
+		if rule.Pattern != "" {
+			pattern, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("keyword rule for category %s : %w", rule.Category, err)
+			}
+			compiled.pattern = pattern
+		}
+		c.rules = append(c.rules, compiled)
+	}
+	return c, nil
+}
+
+// Classify is used to get the categories of all the matching rules
+func (c *KeywordClassifier) Classify(_ context.Context, entry *models.LogEntry, text string) ([]string, error) {
+	lower := strings.ToLower(text)
+	var categories []string
+	for _, rule := range c.rules {
+		if !matchesType(rule.Type, entry.Type) {
+			continue
+		}
+		if rule.pattern != nil && rule.pattern.MatchString(text) {
+			categories = append(categories, rule.Category)
+			continue
+		}
+		for _, keyword := range rule.keywords {
+			if strings.Contains(lower, keyword) {
+				categories = append(categories, rule.Category)
+				break
+			}
+		}
+	}
+	return categories, nil
+}
+
+// EndpointClassifier delegates classification to an external model endpoint
+type EndpointClassifier struct {
+	url     string
+	timeout time.Duration
+}
+
+// NewEndpointClassifier is used to create a classifier calling the configured endpoint
+func NewEndpointClassifier(config EndpointClassifierConfig) *EndpointClassifier {
+	timeout := time.Duration(config.TimeoutInMillis) * time.Millisecond
+	if timeout <= 0 {
+		timeout = constants.DefaultClassifierTimeoutInMillis * time.Millisecond
+	}
+	return &EndpointClassifier{url: config.URL, timeout: timeout}
+}
+
+// Classify is used to get the categories from the endpoint
+func (c *EndpointClassifier) Classify(_ context.Context, entry *models.LogEntry, text string) ([]string, error) {
+	body, err := json.Marshal(map[string]string{"type": entry.Type, "text": text})
+	if err != nil {
+		return nil, err
+	}
+	response, err := httpclient.POSTWithTimeout(c.url, map[string]string{"Content-Type": "application/json"},
+		bytes.NewReader(body), c.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.ExternalServiceFailureError, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: classifier responded with %d", constants.ExternalServiceFailureError,
+			response.StatusCode)
+	}
+	var result struct {
+		Categories []string `json:"categories"`
+	}
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Categories, nil
+}
+
+// commonScripts are checked before falling back to all the unicode scripts
+var commonScripts = []string{"Latin", "Devanagari", "Han", "Arabic", "Cyrillic", "Bengali", "Tamil", "Telugu",
+	"Gujarati", "Kannada", "Malayalam", "Gurmukhi", "Oriya"}
+
+// dominantScript is used to get the unicode script used by most of the letters in the text
+func dominantScript(text string) string {
+	counts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if name := scriptOf(r); name != "" {
+			counts[name]++
+		}
+	}
+	best, bestCount := "", 0
+	for name, count := range counts {
+		if count > bestCount || (count == bestCount && name < best) {
+			best, bestCount = name, count
+		}
+	}
+	return best
+}
+
+func scriptOf(r rune) string {
+	for _, name := range commonScripts {
+		if unicode.Is(unicode.Scripts[name], r) {
+			return name
+		}
+	}
+	for name, table := range unicode.Scripts {
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
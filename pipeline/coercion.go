@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// epochMillisThreshold is the smallest epoch value treated as milliseconds instead of seconds
+const epochMillisThreshold = 1e11
+
+// CoercionRule describes how a single field inside Data is converted to a well known type
+type CoercionRule struct {
+	// Type is the log type this rule applies to, empty or * means all types
+	Type string `json:"type" mapstructure:"type"`
+	// Field is the dotted path of the field inside Data
+	Field string `json:"field" mapstructure:"field"`
+	// To is the target type, one of int, float, bool, string or timestamp
+	To string `json:"to" mapstructure:"to"`
+	// OnError decides what happens when the value cannot be coerced, one of keep, drop or reject
+	OnError string `json:"onError" mapstructure:"onError"`
+}
+
+type coercionStage struct {
+	rules []CoercionRule
+}
+
+func newCoercionStage(rules []CoercionRule) *coercionStage {
+	return &coercionStage{rules: rules}
+}
+
+func (s *coercionStage) Name() string {
+	return "coercion"
+}
+
+func (s *coercionStage) Process(ctx context.Context, entry *models.LogEntry) error {
+	if entry.Data == nil {
+		return nil
+	}
+	for _, rule := range s.rules {
+		if !matchesType(rule.Type, entry.Type) {
+			continue
+		}
+		value, ok := getField(entry.Data, rule.Field)
+		if !ok || value == nil {
+			continue
+		}
+		coerced, err := coerce(value, rule.To)
+		if err == nil {
+			setField(entry.Data, rule.Field, coerced)
+			continue
+		}
+		switch rule.OnError {
+		case constants.CoercionOnErrorReject:
+			return fmt.Errorf("field %s : %w", rule.Field, err)
+		case constants.CoercionOnErrorDrop:
+			deleteField(entry.Data, rule.Field)
+		default:
+			log.Debug(ctx).Err(err).Str(constants.FieldKey, rule.Field).Msg("unable to coerce field, keeping original")
+		}
+	}
+	return nil
+}
+
+// coerce is used to convert the value to the target type
+func coerce(value interface{}, to string) (interface{}, error) {
+	switch to {
+	case constants.CoerceToInt:
+		return toInt(value)
+	case constants.CoerceToFloat:
+		return toFloat(value)
+	case constants.CoerceToBool:
+		return toBool(value)
+	case constants.CoerceToString:
+		return toString(value), nil
+	case constants.CoerceToTimestamp:
+		return toTimestamp(value)
+	default:
+		return nil, fmt.Errorf("unknown coercion target %s", to)
+	}
+}
+
+func toInt(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v is not a whole number", v)
+		}
+		return int64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		s := strings.TrimSpace(v)
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f != math.Trunc(f) {
+			return 0, fmt.Errorf("%q is not an integer", v)
+		}
+		return int64(f), nil
+	}
+	return 0, fmt.Errorf("%T cannot be converted to int", value)
+}
+
+func toFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("%q is not a number", v)
+		}
+		return f, nil
+	}
+	return 0, fmt.Errorf("%T cannot be converted to float", value)
+}
+
+func toBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		return v != 0, nil
+	case int64:
+		return v != 0, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "yes", "y", "1":
+			return true, nil
+		case "false", "no", "n", "0":
+			return false, nil
+		}
+		return false, fmt.Errorf("%q is not a boolean", v)
+	}
+	return false, fmt.Errorf("%T cannot be converted to bool", value)
+}
+
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// toTimestamp is used to convert epoch seconds, epoch millis or an RFC3339 string to an RFC3339 UTC string
+func toTimestamp(value interface{}) (string, error) {
+	if s, ok := value.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s)); err == nil {
+			return t.UTC().Format(time.RFC3339Nano), nil
+		}
+	}
+	epoch, err := toFloat(value)
+	if err != nil {
+		return "", fmt.Errorf("%v is neither an epoch nor an RFC3339 timestamp", value)
+	}
+	var t time.Time
+	if math.Abs(epoch) >= epochMillisThreshold {
+		t = time.UnixMilli(int64(epoch))
+	} else {
+		sec, frac := math.Modf(epoch)
+		t = time.Unix(int64(sec), int64(frac*float64(time.Second)))
+	}
+	return t.UTC().Format(time.RFC3339Nano), nil
+}
@@ -0,0 +1,46 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoercion(t *testing.T) {
+	p, _ := pipeline.New(pipeline.Config{
+		Coercions: []pipeline.CoercionRule{
+			{Type: "order", Field: "qty", To: constants.CoerceToInt},
+			{Field: "meta.active", To: constants.CoerceToBool},
+			{Field: "createdAt", To: constants.CoerceToTimestamp},
+			{Field: "price", To: constants.CoerceToFloat, OnError: constants.CoercionOnErrorDrop},
+		},
+	})
+	entry := models.LogEntry{
+		Type: "order",
+		Data: map[string]interface{}{
+			"qty":       "42",
+			"meta":      map[string]interface{}{"active": "true"},
+			"createdAt": float64(1700000000000),
+			"price":     "n/a",
+		},
+	}
+	assert.NoError(t, p.Process(context.Background(), &entry))
+	assert.Equal(t, int64(42), entry.Data["qty"])
+	assert.Equal(t, true, entry.Data["meta"].(map[string]interface{})["active"])
+	assert.Equal(t, "2023-11-14T22:13:20Z", entry.Data["createdAt"])
+	assert.NotContains(t, entry.Data, "price")
+}
+
+func TestCoercionReject(t *testing.T) {
+	p, _ := pipeline.New(pipeline.Config{
+		Coercions: []pipeline.CoercionRule{
+			{Field: "qty", To: constants.CoerceToInt, OnError: constants.CoercionOnErrorReject},
+		},
+	})
+	entry := models.LogEntry{Type: "order", Data: map[string]interface{}{"qty": "1.5"}}
+	assert.Error(t, p.Process(context.Background(), &entry))
+}
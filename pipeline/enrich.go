@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// EnrichmentConfig is the configuration of the fields the server adds to every entry, so that the queries can
+// tell which instance ingested an entry and for which client
+type EnrichmentConfig struct {
+	// Enabled adds the receipt time, the host and the pod of the instance, and the client ip and the api key
+	// name of the request to the reserved namespace of every entry
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// Hostname overrides the hostname of the instance, e.g. with the name of the edge it serves
+	Hostname string `json:"hostname" mapstructure:"hostname"`
+	// PodEnv is the environment variable holding the pod name, POD_NAME by default as set with the downward api
+	PodEnv string `json:"podEnv" mapstructure:"podEnv"`
+	// Labels are added to every entry, e.g. the region and the cluster of the instance, over the labels the
+	// producers set
+	Labels map[string]string `json:"labels" mapstructure:"labels"`
+	// Resolvers resolve the internal ids of the entries, such as branch codes, to the metadata displayed with
+	// them, so the entries can be read without looking the ids up in another system
+	Resolvers []ResolverConfig `json:"resolvers" mapstructure:"resolvers"`
+}
+
+// Client is the client of the request the entries came in
+type Client struct {
+	IP      string
+	KeyName string
+}
+
+type clientKey struct{}
+
+// WithClient is used to get a context whose entries came from the client
+func WithClient(ctx context.Context, client Client) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext is used to get the client the entries of the context came from
+func ClientFromContext(ctx context.Context) (Client, bool) {
+	client, ok := ctx.Value(clientKey{}).(Client)
+	return client, ok
+}
+
+// enrichStage adds the server side fields to the entries
+type enrichStage struct {
+	enabled bool
+	host    string
+	pod     string
+	labels  map[string]string
+}
+
+func newEnrichStage(config EnrichmentConfig) (*enrichStage, error) {
+	for key := range config.Labels {
+		if !labelKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("enrichment label key %q does not match %s", key, constants.LabelKeyPattern)
+		}
+	}
+	s := &enrichStage{enabled: config.Enabled, host: config.Hostname, labels: config.Labels}
+	if !config.Enabled {
+		return s, nil
+	}
+	if s.host == "" {
+		s.host, _ = os.Hostname()
+	}
+	if config.PodEnv == "" {
+		config.PodEnv = constants.DefaultPodNameEnv
+	}
+	s.pod = os.Getenv(config.PodEnv)
+	return s, nil
+}
+
+func (s *enrichStage) Name() string {
+	return "enrich"
+}
+
+func (s *enrichStage) Process(ctx context.Context, entry *models.LogEntry) error {
+	if len(s.labels) > 0 {
+		if entry.Labels == nil {
+			entry.Labels = make(map[string]string, len(s.labels))
+		}
+		for key, value := range s.labels {
+			entry.Labels[key] = value
+		}
+	}
+	if !s.enabled {
+		return nil
+	}
+	SetMeta(entry, constants.MetaReceivedAtKey, time.Now().UTC().Format(time.RFC3339Nano))
+	if s.host != "" {
+		SetMeta(entry, constants.MetaHostKey, s.host)
+	}
+	if s.pod != "" {
+		SetMeta(entry, constants.MetaPodKey, s.pod)
+	}
+	if client, ok := ClientFromContext(ctx); ok {
+		if client.IP != "" {
+			SetMeta(entry, constants.MetaClientIPKey, client.IP)
+		}
+		if client.KeyName != "" {
+			SetMeta(entry, constants.MetaAPIKeyKey, client.KeyName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnrichment(t *testing.T) {
+	t.Setenv("LOGGER_POD", "nbu-logger-7f9c")
+	p, err := pipeline.New(pipeline.Config{Enrichment: pipeline.EnrichmentConfig{Enabled: true, Hostname: "edge-1",
+		PodEnv: "LOGGER_POD", Labels: map[string]string{"region": "ap-south-1"}}})
+	assert.NoError(t, err)
+	ctx := pipeline.WithClient(context.Background(), pipeline.Client{IP: "10.1.2.3", KeyName: "mobile-app"})
+	// the meta sent by the client is moved out of the way of the server fields
+	entry := models.LogEntry{Type: "order", Labels: map[string]string{"region": "spoofed", "app": "ios"},
+		Data: map[string]interface{}{"_meta": map[string]interface{}{"host": "spoofed"}}}
+	assert.NoError(t, p.Process(ctx, &entry))
+	assert.Equal(t, map[string]string{"region": "ap-south-1", "app": "ios"}, entry.Labels)
+	for key, expected := range map[string]interface{}{
+		constants.MetaHostKey: "edge-1", constants.MetaPodKey: "nbu-logger-7f9c",
+		constants.MetaClientIPKey: "10.1.2.3", constants.MetaAPIKeyKey: "mobile-app",
+	} {
+		value, _ := pipeline.GetMeta(&entry, key)
+		assert.Equal(t, expected, value, key)
+	}
+	receivedAt, _ := pipeline.GetMeta(&entry, constants.MetaReceivedAtKey)
+	at, err := time.Parse(time.RFC3339Nano, receivedAt.(string))
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), at, time.Minute)
+
+	// only the static labels are added when disabled
+	p, err = pipeline.New(pipeline.Config{Enrichment: pipeline.EnrichmentConfig{
+		Labels: map[string]string{"region": "ap-south-1"}}})
+	assert.NoError(t, err)
+	entry = models.LogEntry{Type: "order"}
+	assert.NoError(t, p.Process(ctx, &entry))
+	assert.Equal(t, map[string]string{"region": "ap-south-1"}, entry.Labels)
+	assert.Nil(t, entry.Data)
+
+	_, err = pipeline.New(pipeline.Config{Enrichment: pipeline.EnrichmentConfig{
+		Labels: map[string]string{"edge instance": "1"}}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import "strings"
+
+// getField is used to get the value at the dotted path inside data
+func getField(data map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	current := data
+	for i, key := range keys {
+		value, ok := current[key]
+		if !ok {
+			return nil, false
+		}
+		if i == len(keys)-1 {
+			return value, true
+		}
+		current, ok = value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// setField is used to set the value at the dotted path inside data, creating the intermediate maps
+func setField(data map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	current := data
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			current[key] = next
+		}
+		current = next
+	}
+	current[keys[len(keys)-1]] = value
+}
+
+// deleteField is used to remove the value at the dotted path inside data
+func deleteField(data map[string]interface{}, path string) {
+	keys := strings.Split(path, ".")
+	current := data
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		current = next
+	}
+	delete(current, keys[len(keys)-1])
+}
+
+// matchesType is used to check whether a rule scoped to ruleType applies to the entry type
+func matchesType(ruleType, entryType string) bool {
+	return ruleType == "" || ruleType == "*" || ruleType == entryType
+}
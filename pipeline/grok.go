@@ -0,0 +1,210 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// grokReference matches %{PATTERN}, %{PATTERN:field} and %{PATTERN:field:type}
+var grokReference = regexp.MustCompile(`%{(\w+)(?::([\w.\-]+))?(?::(\w+))?}`)
+
+// baseGrokPatterns is the built-in library of patterns, custom patterns can reference them
+var baseGrokPatterns = map[string]string{
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"INT":               `[+-]?\d+`,
+	"NUMBER":            `[+-]?(?:\d+(?:\.\d*)?|\.\d+)`,
+	"BASE16NUM":         `(?:0[xX])?[0-9A-Fa-f]+`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:\d{1,3}\.){3}\d{1,3}`,
+	"IPV6":              `[0-9A-Fa-f:]*:[0-9A-Fa-f:.]+`,
+	"IP":                `%{IPV6}|%{IPV4}`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z\-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z\-]{0,62})*\.?\b`,
+	"IPORHOST":          `%{IP}|%{HOSTNAME}`,
+	"USER":              `[a-zA-Z0-9._\-]+`,
+	"EMAILADDRESS":      `[a-zA-Z0-9!#$%&'*+/=?^_{|}~\-.]+@%{HOSTNAME}`,
+	"PATH":              `(?:/[^\s?#]*)+`,
+	"URIPATHPARAM":      `/[^\s?#]*(?:\?[^\s#]*)?`,
+	"URI":               `[A-Za-z][A-Za-z0-9+.\-]*://\S+`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"LOGLEVEL":          `(?i:trace|debug|info|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|panic)`,
+	"YEAR":              `\d{4}`,
+	"MONTHNUM":          `0?[1-9]|1[0-2]`,
+	"MONTHDAY":          `0?[1-9]|[12]\d|3[01]`,
+	"HOUR":              `[01]?\d|2[0-3]`,
+	"MINUTE":            `[0-5]\d`,
+	"SECOND":            `[0-5]?\d(?:[.,]\d+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `Z|[+-]%{HOUR}(?::?%{MINUTE})?`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?(?:%{ISO8601_TIMEZONE})?`,
+	"JAVACLASS":         `(?:[a-zA-Z$_][a-zA-Z$_0-9]*\.)*[a-zA-Z$_][a-zA-Z$_0-9]*`,
+}
+
+// GrokConfig is the set of grok patterns and the rules using them
+type GrokConfig struct {
+	// Patterns are custom named patterns in addition to the built-in ones
+	Patterns map[string]string `json:"patterns" mapstructure:"patterns"`
+	Rules    []GrokRule        `json:"rules" mapstructure:"rules"`
+}
+
+// GrokRule extracts structured fields out of a free text field of a type
+type GrokRule struct {
+	// Type is the log type this rule applies to, empty or * means all types
+	Type string `json:"type" mapstructure:"type"`
+	// Field is the dotted path of the free text field, message by default
+	Field string `json:"field" mapstructure:"field"`
+	// Patterns are tried in order and the first match wins
+	Patterns []string `json:"patterns" mapstructure:"patterns"`
+	// Target is the dotted path under which extracted fields are set, Data itself by default
+	Target string `json:"target" mapstructure:"target"`
+}
+
+type grokCapture struct {
+	field string
+	to    string
+}
+
+type grokExpression struct {
+	regex    *regexp.Regexp
+	captures map[string]grokCapture
+}
+
+type grokRule struct {
+	GrokRule
+	expressions []grokExpression
+}
+
+type grokStage struct {
+	rules []grokRule
+}
+
+func newGrokStage(config GrokConfig) (*grokStage, error) {
+	// pattern names are matched case insensitively as config keys are not case preserving
+	library := make(map[string]string, len(baseGrokPatterns)+len(config.Patterns))
+	for name, pattern := range baseGrokPatterns {
+		library[name] = pattern
+	}
+	for name, pattern := range config.Patterns {
+		library[strings.ToUpper(name)] = pattern
+	}
+
+	s := &grokStage{}
+	for _, rule := range config.Rules {
+		if rule.Field == "" {
+			rule.Field = constants.MessageField
+		}
+		compiled := grokRule{GrokRule: rule}
+		for _, pattern := range rule.Patterns {
+			expression, err := compileGrok(pattern, library)
+			if err != nil {
+				return nil, fmt.Errorf("grok rule for type %s : %w", rule.Type, err)
+			}
+			compiled.expressions = append(compiled.expressions, expression)
+		}
+		s.rules = append(s.rules, compiled)
+	}
+	return s, nil
+}
+
+// compileGrok is used to expand the pattern references into a single regular expression
+func compileGrok(pattern string, library map[string]string) (grokExpression, error) {
+	expression := grokExpression{captures: make(map[string]grokCapture)}
+	expanded, err := expandGrok(pattern, library, expression.captures, 0)
+	if err != nil {
+		return expression, err
+	}
+	expression.regex, err = regexp.Compile(expanded)
+	if err != nil {
+		return expression, fmt.Errorf("pattern %q : %w", pattern, err)
+	}
+	return expression, nil
+}
+
+func expandGrok(pattern string, library map[string]string, captures map[string]grokCapture, depth int) (string, error) {
+	if depth > constants.MaxGrokNestingDepth {
+		return "", fmt.Errorf("pattern %q nests too deep", pattern)
+	}
+	var err error
+	expanded := grokReference.ReplaceAllStringFunc(pattern, func(reference string) string {
+		if err != nil {
+			return ""
+		}
+		parts := grokReference.FindStringSubmatch(reference)
+		definition, ok := library[strings.ToUpper(parts[1])]
+		if !ok {
+			err = fmt.Errorf("unknown grok pattern %s", parts[1])
+			return ""
+		}
+		var inner string
+		inner, err = expandGrok(definition, library, captures, depth+1)
+		if parts[2] == "" {
+			return "(?:" + inner + ")"
+		}
+		// group names cannot hold dotted paths, so the path is kept aside
+		group := fmt.Sprintf("g%d", len(captures))
+		captures[group] = grokCapture{field: parts[2], to: parts[3]}
+		return "(?P<" + group + ">" + inner + ")"
+	})
+	return expanded, err
+}
+
+func (s *grokStage) Name() string {
+	return "grok"
+}
+
+func (s *grokStage) Process(_ context.Context, entry *models.LogEntry) error {
+	for _, rule := range s.rules {
+		if !matchesType(rule.Type, entry.Type) || entry.Data == nil {
+			continue
+		}
+		value, ok := getField(entry.Data, rule.Field)
+		if !ok {
+			continue
+		}
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if !rule.extract(entry, text) {
+			SetMeta(entry, constants.MetaGrokFailureKey, rule.Field)
+		}
+	}
+	return nil
+}
+
+// extract is used to set the captured fields of the first matching expression, reporting whether any matched
+func (r *grokRule) extract(entry *models.LogEntry, text string) bool {
+	for _, expression := range r.expressions {
+		match := expression.regex.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		for i, group := range expression.regex.SubexpNames() {
+			capture, ok := expression.captures[group]
+			if !ok || match[i] == "" {
+				continue
+			}
+			var value interface{} = match[i]
+			if capture.to != "" {
+				if coerced, err := coerce(match[i], capture.to); err == nil {
+					value = coerced
+				}
+			}
+			path := capture.field
+			if r.Target != "" {
+				path = r.Target + "." + path
+			}
+			setField(entry.Data, path, value)
+		}
+		return true
+	}
+	return false
+}
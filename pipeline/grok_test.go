@@ -0,0 +1,39 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrok(t *testing.T) {
+	p, err := pipeline.New(pipeline.Config{
+		Grok: pipeline.GrokConfig{
+			Patterns: map[string]string{"method": `GET|POST|PUT|DELETE`},
+			Rules: []pipeline.GrokRule{{
+				Type:     "access",
+				Patterns: []string{`%{IP:client.ip} %{METHOD:method} %{URIPATHPARAM:path} %{INT:status:int}`},
+			}},
+		},
+	})
+	assert.NoError(t, err)
+	entry := models.LogEntry{
+		Type: "access",
+		Data: map[string]interface{}{"message": "10.0.0.1 GET /orders?id=1 200"},
+	}
+	assert.NoError(t, p.Process(context.Background(), &entry))
+	assert.Equal(t, "10.0.0.1", entry.Data["client"].(map[string]interface{})["ip"])
+	assert.Equal(t, "GET", entry.Data["method"])
+	assert.Equal(t, "/orders?id=1", entry.Data["path"])
+	assert.Equal(t, int64(200), entry.Data["status"])
+}
+
+func TestGrokUnknownPattern(t *testing.T) {
+	_, err := pipeline.New(pipeline.Config{
+		Grok: pipeline.GrokConfig{Rules: []pipeline.GrokRule{{Patterns: []string{`%{NOPE:x}`}}}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/angel-one/nbu-logger-service/schemas"
+	"gopkg.in/yaml.v3"
+)
+
+// RulesTest is a suite of sample entries run through a candidate rule set, so that rule changes in the
+// config repository can be covered by tests. As at ingestion, the data of the samples is checked against
+// the schema of their type before the rules run.
+type RulesTest struct {
+	// Rules replace the sections of the base config by name, e.g. grok or coercions, as rules resources do
+	Rules map[string]json.RawMessage `json:"rules"`
+	// Schemas replace the schemas of the types, the samples of other types are checked against the loaded schemas
+	Schemas map[string]json.RawMessage `json:"schemas"`
+	// Cases are the sample entries and their expected outputs
+	Cases []RulesTestCase `json:"cases"`
+}
+
+// RulesTestCase is a sample entry with its expected output.
+// The expected type has to match when set, while the expected labels and data only have to be present in the
+// output, so server added fields do not need to be spelled out.
+type RulesTestCase struct {
+	Name        string           `json:"name"`
+	Input       models.LogEntry  `json:"input"`
+	Expect      *models.LogEntry `json:"expect,omitempty"`
+	ExpectError string           `json:"expectError,omitempty"`
+}
+
+// RulesTestResult is the outcome of a case
+type RulesTestResult struct {
+	Name     string           `json:"name"`
+	Passed   bool             `json:"passed"`
+	Output   *models.LogEntry `json:"output,omitempty"`
+	Error    string           `json:"error,omitempty"`
+	Failures []string         `json:"failures,omitempty"`
+}
+
+// RulesTestReport is the outcome of a suite
+type RulesTestReport struct {
+	Passed  bool              `json:"passed"`
+	Results []RulesTestResult `json:"results"`
+}
+
+// ReadRulesTest is used to read a suite from yaml or json, as kept in the config repository
+func ReadRulesTest(r io.Reader) (RulesTest, error) {
+	var test RulesTest
+	var value interface{}
+	if err := yaml.NewDecoder(r).Decode(&value); err != nil {
+		return test, fmt.Errorf("invalid rules test : %w", err)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return test, fmt.Errorf("invalid rules test : %w", err)
+	}
+	if err = json.Unmarshal(encoded, &test); err != nil {
+		return test, fmt.Errorf("invalid rules test : %w", err)
+	}
+	return test, nil
+}
+
+// Applied is used to get the config of the default pipeline, the base config with the rules resources
+func Applied() Config {
+	return Get().config
+}
+
+// RunRulesTest is used to run the cases through a pipeline of the base config with the candidate rules
+// and schemas. An error is returned when the candidate rules or schemas are invalid.
+func RunRulesTest(ctx context.Context, base Config, test RulesTest) (RulesTestReport, error) {
+	resources := make([]registry.Resource, 0, len(test.Rules))
+	for section, spec := range test.Rules {
+		resources = append(resources, registry.Resource{ID: section, Spec: spec})
+	}
+	config, err := withRules(base, resources)
+	if err != nil {
+		return RulesTestReport{}, err
+	}
+	p, err := New(config)
+	if err != nil {
+		return RulesTestReport{}, err
+	}
+	candidates := make(map[string]*schemas.Schema, len(test.Schemas))
+	for entryType, spec := range test.Schemas {
+		if candidates[entryType], err = schemas.Compile(spec); err != nil {
+			return RulesTestReport{}, fmt.Errorf("invalid schema %s : %w", entryType, err)
+		}
+	}
+	for _, stage := range p.stages {
+		// samples are not traffic, so they are not reported or alerted on
+		if s, ok := stage.(*sensitiveStage); ok {
+			s.dryRun = true
+		}
+	}
+	report := RulesTestReport{Passed: true, Results: make([]RulesTestResult, 0, len(test.Cases))}
+	for i, c := range test.Cases {
+		result := RulesTestResult{Name: c.Name}
+		if result.Name == "" {
+			result.Name = fmt.Sprintf("case %d", i+1)
+		}
+		entry := copyEntry(c.Input)
+		if err = validateSchema(candidates, entry); err == nil {
+			err = p.Process(ctx, &entry)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Output = &entry
+		}
+		result.Failures = c.check(result)
+		result.Passed = len(result.Failures) == 0
+		report.Passed = report.Passed && result.Passed
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// validateSchema is used to check the data of the entry against the candidate schema of its type, or the loaded
+// one without a candidate
+func validateSchema(candidates map[string]*schemas.Schema, entry models.LogEntry) error {
+	if schema, ok := candidates[entry.Type]; ok {
+		return schema.ValidateEntry(entry)
+	}
+	return schemas.Validate(entry)
+}
+
+// check is used to get the differences between the result and the expectations of the case
+func (c RulesTestCase) check(result RulesTestResult) []string {
+	var failures []string
+	switch {
+	case c.ExpectError != "" && result.Error == "":
+		return []string{fmt.Sprintf("expected an error containing %q", c.ExpectError)}
+	case c.ExpectError != "" && !strings.Contains(result.Error, c.ExpectError):
+		return []string{fmt.Sprintf("expected an error containing %q, got %q", c.ExpectError, result.Error)}
+	case c.ExpectError == "" && result.Error != "":
+		return []string{"unexpected error " + result.Error}
+	case c.Expect == nil || result.Output == nil:
+		return nil
+	}
+	if c.Expect.Type != "" && c.Expect.Type != result.Output.Type {
+		failures = append(failures, fmt.Sprintf("type: expected %q, got %q", c.Expect.Type, result.Output.Type))
+	}
+	for key, value := range c.Expect.Labels {
+		if actual, ok := result.Output.Labels[key]; !ok || actual != value {
+			failures = append(failures, fmt.Sprintf("labels.%s: expected %q, got %q", key, value, actual))
+		}
+	}
+	expected, actual := normalize(c.Expect.Data), normalize(result.Output.Data)
+	failures = append(failures, subset("data", expected, actual)...)
+	return failures
+}
+
+// subset is used to get the paths where the expected value is not part of the actual one
+func subset(path string, expected, actual interface{}) []string {
+	expectedMap, ok := expected.(map[string]interface{})
+	if !ok {
+		if reflect.DeepEqual(expected, actual) {
+			return nil
+		}
+		encodedExpected, _ := json.Marshal(expected)
+		encodedActual, _ := json.Marshal(actual)
+		return []string{fmt.Sprintf("%s: expected %s, got %s", path, encodedExpected, encodedActual)}
+	}
+	actualMap, ok := actual.(map[string]interface{})
+	if !ok {
+		return []string{fmt.Sprintf("%s: expected an object", path)}
+	}
+	keys := make([]string, 0, len(expectedMap))
+	for key := range expectedMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var failures []string
+	for _, key := range keys {
+		value, ok := actualMap[key]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s.%s: missing", path, key))
+			continue
+		}
+		failures = append(failures, subset(path+"."+key, expectedMap[key], value)...)
+	}
+	return failures
+}
+
+// normalize is used to get the json form of the value, so that numbers and slices compare alike
+func normalize(value interface{}) interface{} {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized interface{}
+	if err = json.Unmarshal(encoded, &normalized); err != nil {
+		return value
+	}
+	return normalized
+}
+
+// copyEntry is used to get a deep copy of the entry, as stages change entries in place
+func copyEntry(entry models.LogEntry) models.LogEntry {
+	var copied models.LogEntry
+	encoded, err := json.Marshal(entry)
+	if err == nil && json.Unmarshal(encoded, &copied) == nil {
+		return copied
+	}
+	return entry
+}
@@ -0,0 +1,73 @@
+package pipeline_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunRulesTest(t *testing.T) {
+	test, err := pipeline.ReadRulesTest(strings.NewReader(`
+rules:
+  coercions:
+    - {type: order, field: qty, to: int, onError: reject}
+cases:
+  - name: quantity is coerced
+    input: {type: order, Data: {qty: "42", note: x}}
+    expect: {type: order, Data: {qty: 42}}
+  - name: bad quantity is rejected
+    input: {type: order, Data: {qty: many}}
+    expectError: qty
+  - name: wrong expectation
+    input: {type: order, Data: {qty: "7"}}
+    expect: {Data: {qty: 8, missing: true}}
+`))
+	assert.NoError(t, err)
+	report, err := pipeline.RunRulesTest(context.Background(), pipeline.Config{}, test)
+	assert.NoError(t, err)
+	assert.False(t, report.Passed)
+	if assert.Len(t, report.Results, 3) {
+		assert.True(t, report.Results[0].Passed)
+		assert.True(t, report.Results[1].Passed, report.Results[1].Failures)
+		assert.Equal(t, []string{"data.missing: missing", "data.qty: expected 8, got 7"}, report.Results[2].Failures)
+	}
+
+	_, err = pipeline.RunRulesTest(context.Background(), pipeline.Config{},
+		pipeline.RulesTest{Rules: map[string]json.RawMessage{"unknown": json.RawMessage(`{}`)}})
+	assert.Error(t, err)
+}
+
+func TestRunRulesTestSchemas(t *testing.T) {
+	test, err := pipeline.ReadRulesTest(strings.NewReader(`
+schemas:
+  order:
+    type: object
+    required: [qty]
+    properties:
+      qty: {type: string}
+rules:
+  coercions:
+    - {type: order, field: qty, to: int}
+cases:
+  - name: the schema is checked before the rules
+    input: {type: order, Data: {qty: "42"}}
+    expect: {Data: {qty: 42}}
+  - name: data not matching the schema is rejected
+    input: {type: order, Data: {note: x}}
+    expectError: qty
+  - name: types without a schema are not checked
+    input: {type: refund, Data: {note: x}}
+`))
+	assert.NoError(t, err)
+	report, err := pipeline.RunRulesTest(context.Background(), pipeline.Config{}, test)
+	assert.NoError(t, err)
+	assert.True(t, report.Passed, report.Results)
+
+	_, err = pipeline.RunRulesTest(context.Background(), pipeline.Config{},
+		pipeline.RulesTest{Schemas: map[string]json.RawMessage{"order": json.RawMessage(`{"type":"thing"}`)}})
+	assert.Error(t, err)
+}
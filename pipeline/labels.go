@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+var labelKeyRegex = regexp.MustCompile(constants.LabelKeyPattern)
+
+// LabelsConfig is the set of bounds enforced on entry labels
+type LabelsConfig struct {
+	MaxLabels      int `json:"maxLabels" mapstructure:"maxLabels"`
+	MaxKeyLength   int `json:"maxKeyLength" mapstructure:"maxKeyLength"`
+	MaxValueLength int `json:"maxValueLength" mapstructure:"maxValueLength"`
+}
+
+type labelsStage struct {
+	config LabelsConfig
+}
+
+func newLabelsStage(config LabelsConfig) *labelsStage {
+	if config.MaxLabels <= 0 {
+		config.MaxLabels = constants.DefaultMaxLabels
+	}
+	if config.MaxKeyLength <= 0 {
+		config.MaxKeyLength = constants.DefaultMaxLabelKeyLength
+	}
+	if config.MaxValueLength <= 0 {
+		config.MaxValueLength = constants.DefaultMaxLabelValueLength
+	}
+	return &labelsStage{config: config}
+}
+
+func (s *labelsStage) Name() string {
+	return "labels"
+}
+
+func (s *labelsStage) Process(_ context.Context, entry *models.LogEntry) error {
+	if len(entry.Labels) > s.config.MaxLabels {
+		return fmt.Errorf("%d labels provided, at most %d allowed", len(entry.Labels), s.config.MaxLabels)
+	}
+	for key, value := range entry.Labels {
+		if len(key) > s.config.MaxKeyLength {
+			return fmt.Errorf("label key %.16s... longer than %d characters", key, s.config.MaxKeyLength)
+		}
+		if !labelKeyRegex.MatchString(key) {
+			return fmt.Errorf("label key %q does not match %s", key, constants.LabelKeyPattern)
+		}
+		if len(value) > s.config.MaxValueLength {
+			return fmt.Errorf("label %s value longer than %d characters", key, s.config.MaxValueLength)
+		}
+	}
+	return nil
+}
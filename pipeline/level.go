@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// ErrBelowMinLevel is returned for entries dropped for their level, they are not an error of the producer
+var ErrBelowMinLevel = errors.New("entry is below the minimum level")
+
+// ErrSampledOut is returned for the entries dropped by sampling, they are not an error of the producer either
+var ErrSampledOut = errors.New("entry was sampled out")
+
+// levelOrder is the severity of the entry levels
+var levelOrder = map[string]int{
+	constants.DebugLevel: 0, constants.InfoLevel: 1, constants.WarnLevel: 2, constants.ErrorLevel: 3,
+	constants.FatalLevel: 4,
+}
+
+// levelStage sets the level of the entry from its data when it has none, drops the entries below the minimum
+// and samples the rest
+type levelStage struct {
+	minLevel string
+	sampler  *sampler
+}
+
+func newLevelStage(minLevel string, sampleRate float64, sampling []SamplingRule) (*levelStage, error) {
+	if _, ok := levelOrder[minLevel]; minLevel != "" && !ok {
+		return nil, fmt.Errorf("min level %q has to be one of debug, info, warn, error or fatal", minLevel)
+	}
+	entrySampler, err := newSampler(sampleRate, sampling)
+	if err != nil {
+		return nil, err
+	}
+	return &levelStage{minLevel: minLevel, sampler: entrySampler}, nil
+}
+
+func (s *levelStage) Name() string {
+	return "level"
+}
+
+func (s *levelStage) Process(ctx context.Context, entry *models.LogEntry) error {
+	if entry.Level == "" {
+		// formats and inputs keep the level of the producer in the data
+		if level, ok := entry.Data[constants.LevelField].(string); ok {
+			if _, known := levelOrder[strings.ToLower(level)]; known {
+				entry.Level = strings.ToLower(level)
+			}
+		}
+	}
+	if entry.Level == "" {
+		entry.Level = constants.InfoLevel
+	}
+	order, ok := levelOrder[entry.Level]
+	if !ok {
+		return fmt.Errorf("level %q has to be one of debug, info, warn, error or fatal", entry.Level)
+	}
+	if s.minLevel != "" && order < levelOrder[s.minLevel] {
+		return ErrBelowMinLevel
+	}
+	if !s.sampler.keep(ctx, entry) {
+		return ErrSampledOut
+	}
+	return nil
+}
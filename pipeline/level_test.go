@@ -0,0 +1,54 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevel(t *testing.T) {
+	ctx := context.Background()
+	p, err := pipeline.New(pipeline.Config{MinLevel: "info"})
+	assert.NoError(t, err)
+
+	entry := models.LogEntry{Type: "orders", Data: map[string]interface{}{"level": "WARN"}}
+	assert.NoError(t, p.Process(ctx, &entry))
+	assert.Equal(t, "warn", entry.Level)
+
+	entry = models.LogEntry{Type: "orders"}
+	assert.NoError(t, p.Process(ctx, &entry))
+	assert.Equal(t, "info", entry.Level)
+
+	entry = models.LogEntry{Type: "orders", Level: "debug"}
+	assert.ErrorIs(t, p.Process(ctx, &entry), pipeline.ErrBelowMinLevel)
+	entry = models.LogEntry{Type: "orders", Level: "verbose"}
+	assert.Error(t, p.Process(ctx, &entry))
+
+	_, err = pipeline.New(pipeline.Config{MinLevel: "trace"})
+	assert.Error(t, err)
+}
+
+func TestSampling(t *testing.T) {
+	ctx := context.Background()
+	p, err := pipeline.New(pipeline.Config{SampleRate: 0.1})
+	assert.NoError(t, err)
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		entry := models.LogEntry{Type: "orders", Level: "info"}
+		if err := p.Process(ctx, &entry); err == nil {
+			kept++
+		} else {
+			assert.ErrorIs(t, err, pipeline.ErrSampledOut)
+		}
+		entry = models.LogEntry{Type: "orders", Level: "error"}
+		assert.NoError(t, p.Process(ctx, &entry))
+	}
+	assert.InDelta(t, 100, kept, 60)
+
+	_, err = pipeline.New(pipeline.Config{SampleRate: 1.5})
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+// MultilineRule describes how raw text lines of a type are joined into a single message
+type MultilineRule struct {
+	// Type is the log type this rule applies to, empty or * means all types
+	Type string `json:"type" mapstructure:"type"`
+	// Continuation matches lines that belong to the previous message, e.g. ^\s+at  for java stack traces
+	Continuation string `json:"continuation" mapstructure:"continuation"`
+	// MaxLines caps the number of lines joined into one message
+	MaxLines int `json:"maxLines" mapstructure:"maxLines"`
+}
+
+type multilineRule struct {
+	MultilineRule
+	continuation *regexp.Regexp
+}
+
+// Reassembler joins continuation lines of text payloads into complete messages
+type Reassembler struct {
+	rules []multilineRule
+}
+
+func newReassembler(rules []MultilineRule) (*Reassembler, error) {
+	r := &Reassembler{}
+	for _, rule := range rules {
+		continuation, err := regexp.Compile(rule.Continuation)
+		if err != nil {
+			return nil, fmt.Errorf("multiline rule for type %s : %w", rule.Type, err)
+		}
+		if rule.MaxLines <= 0 {
+			rule.MaxLines = constants.DefaultMultilineMaxLines
+		}
+		r.rules = append(r.rules, multilineRule{MultilineRule: rule, continuation: continuation})
+	}
+	return r, nil
+}
+
+// Reassemble is used to group the raw lines of the type into messages.
+// Without a matching rule every non-empty line is a message of its own.
+func (r *Reassembler) Reassemble(entryType string, lines []string) []string {
+	rule := r.ruleFor(entryType)
+	var messages []string
+	var current []string
+	flush := func() {
+		if len(current) > 0 {
+			messages = append(messages, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if rule != nil && rule.continues(len(current), line) {
+			current = append(current, line)
+			continue
+		}
+		flush()
+		if strings.TrimSpace(line) != "" {
+			current = append(current, line)
+		}
+	}
+	flush()
+	return messages
+}
+
+// Joins is used to check whether the lines of the type can be joined, the streaming inputs hold the messages of
+// the types that have a rule until they know the next line does not continue them
+func (r *Reassembler) Joins(entryType string) bool {
+	return r.ruleFor(entryType) != nil
+}
+
+// Continues is used to check whether the line continues the message of the type joined from the lines so far
+func (r *Reassembler) Continues(entryType string, lines int, line string) bool {
+	rule := r.ruleFor(entryType)
+	return rule != nil && rule.continues(lines, strings.TrimRight(line, "\r"))
+}
+
+// continues is used to check whether the line continues a message of the lines so far
+func (rule *multilineRule) continues(lines int, line string) bool {
+	return lines > 0 && lines < rule.MaxLines && rule.continuation.MatchString(line)
+}
+
+func (r *Reassembler) ruleFor(entryType string) *multilineRule {
+	// exact type rules win over wildcard rules
+	var wildcard *multilineRule
+	for i := range r.rules {
+		switch r.rules[i].Type {
+		case entryType:
+			return &r.rules[i]
+		case "", "*":
+			if wildcard == nil {
+				wildcard = &r.rules[i]
+			}
+		}
+	}
+	return wildcard
+}
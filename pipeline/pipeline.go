@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// Stage is a single processing step that every log entry goes through before it is emitted
+type Stage interface {
+	// Name is used to identify the stage in errors and logs
+	Name() string
+	// Process is used to process the entry in place
+	Process(ctx context.Context, entry *models.LogEntry) error
+}
+
+// Config is the set of rules used to build the pipeline
+type Config struct {
+	// MinLevel drops the entries below the level, e.g. debug entries in production
+	MinLevel string         `json:"minLevel" mapstructure:"minLevel"`
+	Reserved ReservedConfig `json:"reserved" mapstructure:"reserved"`
+	Labels   LabelsConfig   `json:"labels" mapstructure:"labels"`
+	// Enrichment adds the server side fields to every entry
+	Enrichment EnrichmentConfig `json:"enrichment" mapstructure:"enrichment"`
+	Multiline  []MultilineRule  `json:"multiline" mapstructure:"multiline"`
+	Grok       GrokConfig       `json:"grok" mapstructure:"grok"`
+	Coercions  []CoercionRule   `json:"coercions" mapstructure:"coercions"`
+
+	// SampleRate is the fraction of the debug and info entries kept, e.g. 0.1 keeps one in ten, every entry is
+	// kept when it is 0. Warn and more severe entries are never sampled.
+	SampleRate float64 `json:"sampleRate" mapstructure:"sampleRate"`
+	// Sampling are the sampling rules of the log types, the first rule matching the type and the level of an
+	// entry applies instead of the sample rate. Requests with X-Force-Sample are never sampled out.
+	Sampling []SamplingRule `json:"sampling" mapstructure:"sampling"`
+
+	Sensitive      SensitiveConfig      `json:"sensitive" mapstructure:"sensitive"`
+	Classification ClassificationConfig `json:"classification" mapstructure:"classification"`
+}
+
+// Pipeline is an ordered set of stages
+type Pipeline struct {
+	config      Config
+	stages      []Stage
+	reassembler *Reassembler
+}
+
+var p atomic.Value
+
+func init() {
+	pipeline, _ := New(Config{})
+	p.Store(pipeline)
+}
+
+// New is used to build a new pipeline from the provided config
+func New(config Config) (*Pipeline, error) {
+	reassembler, err := newReassembler(config.Multiline)
+	if err != nil {
+		return nil, err
+	}
+	grok, err := newGrokStage(config.Grok)
+	if err != nil {
+		return nil, err
+	}
+	level, err := newLevelStage(config.MinLevel, config.SampleRate, config.Sampling)
+	if err != nil {
+		return nil, err
+	}
+	sensitive, err := newSensitiveStage(config.Sensitive)
+	if err != nil {
+		return nil, err
+	}
+	classify, err := newClassifyStage(config.Classification)
+	if err != nil {
+		return nil, err
+	}
+	enrich, err := newEnrichStage(config.Enrichment)
+	if err != nil {
+		return nil, err
+	}
+	resolve, err := newResolveStage(config.Enrichment.Resolvers)
+	if err != nil {
+		return nil, err
+	}
+	return &Pipeline{
+		config: config,
+		stages: []Stage{
+			// the reserved namespace has to be cleared before any stage adds server fields
+			newReservedStage(config.Reserved),
+			newLabelsStage(config.Labels),
+			// the server labels are not counted against the labels of the producers
+			enrich,
+			// extraction runs before coercion so that extracted fields can be coerced as well
+			grok,
+			newCoercionStage(config.Coercions),
+			// the level can come from extracted and coerced fields, and dropped entries skip the rest
+			level,
+			// ids are resolved once extracted and coerced, and only for the entries kept
+			resolve,
+			// detection runs on the extracted fields and before classification reads the message
+			sensitive,
+			classify,
+		},
+		reassembler: reassembler,
+	}, nil
+}
+
+// Init is used to initialize the default pipeline.
+// It is safe to call again at runtime, the previous pipeline is kept if the config is invalid.
+func Init(config Config) error {
+	pipeline, err := New(config)
+	if err != nil {
+		return err
+	}
+	p.Store(pipeline)
+	return nil
+}
+
+// Get is used to get the default pipeline
+func Get() *Pipeline {
+	return p.Load().(*Pipeline)
+}
+
+// StageError is returned for the entries a stage of the pipeline rejects
+type StageError struct {
+	Stage string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("%s stage error : %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Process is used to run the entry through all the stages in order
+func (p *Pipeline) Process(ctx context.Context, entry *models.LogEntry) error {
+	for _, stage := range p.stages {
+		if err := stage.Process(ctx, entry); err != nil {
+			return &StageError{Stage: stage.Name(), Err: err}
+		}
+	}
+	return nil
+}
+
+// Reassembler is used to get the reassembler for raw text inputs
+func (p *Pipeline) Reassembler() *Reassembler {
+	return p.reassembler
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// ReservedConfig is the configuration of the namespace reserved for server added fields
+type ReservedConfig struct {
+	// RenamePrefix is prepended to client fields that collide with the reserved namespace
+	RenamePrefix string `json:"renamePrefix" mapstructure:"renamePrefix"`
+}
+
+// reservedStage moves client fields out of the reserved namespace so that
+// server added fields never overwrite producer data and vice versa
+type reservedStage struct {
+	renamePrefix string
+}
+
+func newReservedStage(config ReservedConfig) *reservedStage {
+	prefix := config.RenamePrefix
+	if prefix == "" {
+		prefix = constants.MetaCollisionRenamePrefix
+	}
+	return &reservedStage{renamePrefix: prefix}
+}
+
+func (s *reservedStage) Name() string {
+	return "reserved"
+}
+
+func (s *reservedStage) Process(_ context.Context, entry *models.LogEntry) error {
+	if entry.Data == nil {
+		return nil
+	}
+	var colliding []string
+	for key := range entry.Data {
+		if IsReserved(key) {
+			colliding = append(colliding, key)
+		}
+	}
+	if len(colliding) == 0 {
+		return nil
+	}
+	// sort to keep the renaming deterministic when suffixes are needed
+	sort.Strings(colliding)
+	renamed := make(map[string]interface{}, len(colliding))
+	for _, key := range colliding {
+		target := s.renamePrefix + key
+		for i := 1; ; i++ {
+			if _, exists := entry.Data[target]; !exists {
+				break
+			}
+			target = fmt.Sprintf("%s%s_%d", s.renamePrefix, key, i)
+		}
+		entry.Data[target] = entry.Data[key]
+		delete(entry.Data, key)
+		renamed[key] = target
+	}
+	SetMeta(entry, constants.MetaRenamedFieldsKey, renamed)
+	return nil
+}
+
+// IsReserved is used to check whether a top level Data key belongs to the reserved namespace
+func IsReserved(key string) bool {
+	return key == constants.MetaNamespace || strings.HasPrefix(key, constants.MetaNamespace+".")
+}
+
+// SetMeta is used to set a server added field inside the reserved namespace of the entry
+func SetMeta(entry *models.LogEntry, key string, value interface{}) {
+	if entry.Data == nil {
+		entry.Data = make(map[string]interface{})
+	}
+	meta, ok := entry.Data[constants.MetaNamespace].(map[string]interface{})
+	if !ok {
+		meta = make(map[string]interface{})
+		entry.Data[constants.MetaNamespace] = meta
+	}
+	meta[key] = value
+}
+
+// GetMeta is used to get a server added field from the reserved namespace of the entry
+func GetMeta(entry *models.LogEntry, key string) (interface{}, bool) {
+	meta, ok := entry.Data[constants.MetaNamespace].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	value, ok := meta[key]
+	return value, ok
+}
@@ -0,0 +1,28 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReservedNamespaceCollision(t *testing.T) {
+	p, _ := pipeline.New(pipeline.Config{})
+	entry := models.LogEntry{
+		Type: "order",
+		Data: map[string]interface{}{
+			"_meta":       "from client",
+			"client_meta": "taken",
+		},
+	}
+	assert.NoError(t, p.Process(context.Background(), &entry))
+	assert.Equal(t, "from client", entry.Data["client_meta_1"])
+	assert.Equal(t, "taken", entry.Data["client_meta"])
+	renamed, ok := pipeline.GetMeta(&entry, constants.MetaRenamedFieldsKey)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"_meta": "client_meta_1"}, renamed)
+}
@@ -0,0 +1,280 @@
+package pipeline
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+// Resolver resolves an internal id, such as a branch code, an employee id or a product code, to the metadata
+// displayed with it. Unknown ids resolve to nil metadata.
+type Resolver interface {
+	Resolve(ctx context.Context, id string) (map[string]interface{}, error)
+}
+
+// ResolverConfig is a field of the entries whose ids are resolved to their metadata, kept in the reserved
+// namespace under resolved and the name of the resolver, e.g. _meta.resolved.branch.name
+type ResolverConfig struct {
+	// Name is the key of the metadata, e.g. branch
+	Name string `json:"name" mapstructure:"name"`
+	// Type limits the resolution to the entries of the type, every type when empty
+	Type string `json:"type" mapstructure:"type"`
+	// Field is the dotted path of the id in the data, e.g. branchCode
+	Field string `json:"field" mapstructure:"field"`
+	// Kind is the resolver, endpoint by default, static or one registered with RegisterResolver
+	Kind string `json:"kind" mapstructure:"kind"`
+	// URL is the lookup service of the endpoint resolver, {id} is replaced by the id, e.g.
+	// https://directory/branches/{id}. It responds with the json object of the metadata, or 404 for unknown ids.
+	URL string `json:"url" mapstructure:"url"`
+	// Headers are sent to the lookup service, e.g. its credentials
+	Headers         map[string]string `json:"-" mapstructure:"headers"`
+	TimeoutInMillis int               `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+	// Values are the metadata of the ids of the static resolver, for the small tables kept in the config
+	Values map[string]map[string]interface{} `json:"values" mapstructure:"values"`
+	// CacheSize is the number of ids whose metadata are kept, the least recently used are evicted
+	CacheSize int `json:"cacheSize" mapstructure:"cacheSize"`
+	// CacheTTLInSeconds is how long the metadata of an id are kept, unknown ids included so that they are not
+	// looked up for every entry. Failed lookups are kept for a few seconds only.
+	CacheTTLInSeconds int `json:"cacheTtlInSeconds" mapstructure:"cacheTtlInSeconds"`
+}
+
+var (
+	resolversMu sync.RWMutex
+	// resolverKinds are the factories of the resolvers by kind
+	resolverKinds = map[string]func(config ResolverConfig) (Resolver, error){
+		constants.EndpointResolver: func(config ResolverConfig) (Resolver, error) {
+			return NewEndpointResolver(config)
+		},
+		constants.StaticResolver: func(config ResolverConfig) (Resolver, error) {
+			return StaticResolver(config.Values), nil
+		},
+	}
+)
+
+// RegisterResolver is used to make a resolver kind available to the config, e.g. one reading a directory
+// through its client library
+func RegisterResolver(kind string, factory func(config ResolverConfig) (Resolver, error)) {
+	resolversMu.Lock()
+	defer resolversMu.Unlock()
+	resolverKinds[kind] = factory
+}
+
+// ResolverKinds is used to get the available resolver kinds
+func ResolverKinds() []string {
+	resolversMu.RLock()
+	defer resolversMu.RUnlock()
+	kinds := make([]string, 0, len(resolverKinds))
+	for kind := range resolverKinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// resolveStage adds the metadata of the ids of the entries
+type resolveStage struct {
+	resolvers []fieldResolver
+}
+
+// fieldResolver is the cached resolver of a field
+type fieldResolver struct {
+	ResolverConfig
+	resolver Resolver
+}
+
+func newResolveStage(configs []ResolverConfig) (*resolveStage, error) {
+	s := &resolveStage{}
+	names := make(map[string]bool, len(configs))
+	for _, config := range configs {
+		if config.Name == "" || config.Field == "" {
+			return nil, fmt.Errorf("resolver %q needs a name and a field", config.Name)
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("resolver %s is defined twice", config.Name)
+		}
+		names[config.Name] = true
+		if config.Kind == "" {
+			config.Kind = constants.EndpointResolver
+		}
+		resolversMu.RLock()
+		factory, ok := resolverKinds[config.Kind]
+		resolversMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("resolver %s has an unknown kind %s, available are %v", config.Name, config.Kind,
+				ResolverKinds())
+		}
+		resolver, err := factory(config)
+		if err != nil {
+			return nil, fmt.Errorf("resolver %s : %w", config.Name, err)
+		}
+		s.resolvers = append(s.resolvers, fieldResolver{ResolverConfig: config,
+			resolver: newCachedResolver(resolver, config.CacheSize, config.CacheTTLInSeconds)})
+	}
+	return s, nil
+}
+
+func (s *resolveStage) Name() string {
+	return "resolve"
+}
+
+func (s *resolveStage) Process(ctx context.Context, entry *models.LogEntry) error {
+	resolved := make(map[string]interface{})
+	for _, r := range s.resolvers {
+		if !matchesType(r.Type, entry.Type) {
+			continue
+		}
+		value, ok := getField(entry.Data, r.Field)
+		if !ok || value == nil {
+			continue
+		}
+		id := strings.TrimSpace(fmt.Sprint(value))
+		if id == "" {
+			continue
+		}
+		metadata, err := r.resolver.Resolve(ctx, id)
+		if err != nil {
+			// resolution is best effort and never rejects an entry
+			log.Warn(ctx).Err(err).Str(constants.FieldKey, r.Field).Msg("error resolving id")
+			continue
+		}
+		if metadata != nil {
+			// the cached metadata are shared by the entries of the id
+			copied := make(map[string]interface{}, len(metadata))
+			for key, value := range metadata {
+				copied[key] = value
+			}
+			resolved[r.Name] = copied
+		}
+	}
+	if len(resolved) > 0 {
+		SetMeta(entry, constants.MetaResolvedKey, resolved)
+	}
+	return nil
+}
+
+// StaticResolver resolves the ids with the metadata of the config
+type StaticResolver map[string]map[string]interface{}
+
+// Resolve is used to get the metadata of the id, nil when it is unknown
+func (r StaticResolver) Resolve(_ context.Context, id string) (map[string]interface{}, error) {
+	return r[id], nil
+}
+
+// EndpointResolver resolves the ids with a lookup service
+type EndpointResolver struct {
+	url     string
+	headers map[string]string
+	timeout time.Duration
+}
+
+// NewEndpointResolver is used to create a resolver calling the lookup service of the config
+func NewEndpointResolver(config ResolverConfig) (*EndpointResolver, error) {
+	if !strings.Contains(config.URL, constants.ResolverIDPlaceholder) {
+		return nil, fmt.Errorf("url %q has to contain %s", config.URL, constants.ResolverIDPlaceholder)
+	}
+	timeout := time.Duration(config.TimeoutInMillis) * time.Millisecond
+	if timeout <= 0 {
+		timeout = constants.DefaultResolverTimeoutInMillis * time.Millisecond
+	}
+	return &EndpointResolver{url: config.URL, headers: config.Headers, timeout: timeout}, nil
+}
+
+// Resolve is used to get the metadata of the id from the lookup service
+func (r *EndpointResolver) Resolve(_ context.Context, id string) (map[string]interface{}, error) {
+	response, err := httpclient.GETWithTimeout(strings.ReplaceAll(r.url, constants.ResolverIDPlaceholder,
+		url.PathEscape(id)), r.headers, r.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.ExternalServiceFailureError, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: lookup service responded with %d", constants.ExternalServiceFailureError,
+			response.StatusCode)
+	}
+	var metadata map[string]interface{}
+	if err = json.NewDecoder(response.Body).Decode(&metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// cachedResolver keeps the metadata of the recently resolved ids
+type cachedResolver struct {
+	resolver Resolver
+	size     int
+	ttl      time.Duration
+	mu       sync.Mutex
+	ids      map[string]*list.Element
+	// recent has the cached ids, the most recently used first
+	recent *list.List
+}
+
+type cachedMetadata struct {
+	id        string
+	metadata  map[string]interface{}
+	err       error
+	expiresAt time.Time
+}
+
+func newCachedResolver(resolver Resolver, size, ttlInSeconds int) *cachedResolver {
+	if size <= 0 {
+		size = constants.DefaultResolverCacheSize
+	}
+	if ttlInSeconds <= 0 {
+		ttlInSeconds = constants.DefaultResolverCacheTTLInSeconds
+	}
+	return &cachedResolver{resolver: resolver, size: size, ttl: time.Duration(ttlInSeconds) * time.Second,
+		ids: make(map[string]*list.Element), recent: list.New()}
+}
+
+// Resolve is used to get the cached metadata of the id, resolving it when it is not cached or expired
+func (c *cachedResolver) Resolve(ctx context.Context, id string) (map[string]interface{}, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if element, ok := c.ids[id]; ok {
+		cached := element.Value.(*cachedMetadata)
+		if now.Before(cached.expiresAt) {
+			c.recent.MoveToFront(element)
+			c.mu.Unlock()
+			return cached.metadata, cached.err
+		}
+	}
+	c.mu.Unlock()
+
+	metadata, err := c.resolver.Resolve(ctx, id)
+	ttl := c.ttl
+	if err != nil && ttl > constants.ResolverFailureCacheTTLInSeconds*time.Second {
+		// a failing lookup service is not called for every entry, and is tried again soon
+		ttl = constants.ResolverFailureCacheTTLInSeconds * time.Second
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached := &cachedMetadata{id: id, metadata: metadata, err: err, expiresAt: now.Add(ttl)}
+	if element, ok := c.ids[id]; ok {
+		element.Value = cached
+		c.recent.MoveToFront(element)
+	} else {
+		c.ids[id] = c.recent.PushFront(cached)
+	}
+	for c.recent.Len() > c.size {
+		oldest := c.recent.Back()
+		c.recent.Remove(oldest)
+		delete(c.ids, oldest.Value.(*cachedMetadata).id)
+	}
+	return metadata, err
+}
@@ -0,0 +1,74 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingResolver struct{}
+
+func (failingResolver) Resolve(context.Context, string) (map[string]interface{}, error) {
+	return nil, errors.New("directory is down")
+}
+
+func TestResolvers(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.URL.Path != "/branches/B 01" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"name":"Andheri","region":"west"}`)
+	}))
+	defer server.Close()
+	pipeline.RegisterResolver("failing", func(pipeline.ResolverConfig) (pipeline.Resolver, error) {
+		return failingResolver{}, nil
+	})
+
+	p, err := pipeline.New(pipeline.Config{Enrichment: pipeline.EnrichmentConfig{Resolvers: []pipeline.ResolverConfig{
+		{Name: "branch", Field: "order.branch", URL: server.URL + "/branches/{id}"},
+		{Name: "product", Kind: constants.StaticResolver, Type: "order", Field: "product",
+			Values: map[string]map[string]interface{}{"EQ": {"name": "Equity"}}},
+		{Name: "employee", Kind: "failing", Field: "employeeId"},
+	}}})
+	assert.NoError(t, err)
+	resolve := func(data map[string]interface{}) interface{} {
+		entry := models.LogEntry{Type: "order", Data: data}
+		assert.NoError(t, p.Process(context.Background(), &entry))
+		resolved, _ := pipeline.GetMeta(&entry, constants.MetaResolvedKey)
+		return resolved
+	}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, map[string]interface{}{
+			"branch":  map[string]interface{}{"name": "Andheri", "region": "west"},
+			"product": map[string]interface{}{"name": "Equity"},
+		}, resolve(map[string]interface{}{"order": map[string]interface{}{"branch": "B 01"}, "product": "EQ",
+			"employeeId": 42}))
+	}
+	// unknown ids are cached too and add no metadata
+	assert.Nil(t, resolve(map[string]interface{}{"order": map[string]interface{}{"branch": "B 02"}}))
+	assert.Nil(t, resolve(map[string]interface{}{"order": map[string]interface{}{"branch": "B 02"}}))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	for _, invalid := range [][]pipeline.ResolverConfig{
+		{{Name: "branch", Field: "branchCode", Kind: "ldap"}},
+		{{Name: "branch", Field: "branchCode", URL: server.URL + "/branches"}},
+		{{Name: "branch", Field: "branchCode", Kind: constants.StaticResolver},
+			{Name: "branch", Field: "branchId", Kind: constants.StaticResolver}},
+		{{Name: "branch", Kind: constants.StaticResolver}},
+	} {
+		_, err = pipeline.New(pipeline.Config{Enrichment: pipeline.EnrichmentConfig{Resolvers: invalid}})
+		assert.Error(t, err, invalid)
+	}
+}
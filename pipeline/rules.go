@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/registry"
+)
+
+var (
+	baseMu sync.Mutex
+	base   Config
+)
+
+// RegisterRules is used to register the rules resource kind on the registry.
+// Every rules resource replaces the config section named by its id, e.g. grok or coercions,
+// and the default pipeline is rebuilt whenever one of them changes.
+func RegisterRules(reg *registry.Registry) {
+	reg.RegisterKind(registry.Kind{
+		Name: constants.RulesResourceKind,
+		ValidateAll: func(resources []registry.Resource) error {
+			baseMu.Lock()
+			config := base
+			baseMu.Unlock()
+			config, err := withRules(config, resources)
+			if err != nil {
+				return err
+			}
+			_, err = New(config)
+			return err
+		},
+	})
+	reg.OnChange(constants.RulesResourceKind, func() {
+		if err := Reload(reg); err != nil {
+			log.Error(nil).Err(err).Msg("error reloading pipeline after rules change")
+		}
+	})
+}
+
+// SetBase is used to set the file based config the rules resources are applied on top of, and rebuild
+func SetBase(config Config, reg *registry.Registry) error {
+	baseMu.Lock()
+	base = config
+	baseMu.Unlock()
+	return Reload(reg)
+}
+
+// Reload is used to rebuild the default pipeline from the base config and the rules resources
+func Reload(reg *registry.Registry) error {
+	baseMu.Lock()
+	config := base
+	baseMu.Unlock()
+	resources, err := reg.List(constants.RulesResourceKind)
+	if err != nil {
+		return err
+	}
+	config, err = withRules(config, resources)
+	if err != nil {
+		return err
+	}
+	return Init(config)
+}
+
+// withRules is used to replace the config sections with the specs of the rules resources
+func withRules(config Config, resources []registry.Resource) (Config, error) {
+	if len(resources) == 0 {
+		return config, nil
+	}
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return config, err
+	}
+	sections := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(encoded, &sections); err != nil {
+		return config, err
+	}
+	for _, resource := range resources {
+		if _, ok := sections[resource.ID]; !ok {
+			return config, fmt.Errorf("unknown rules section %s", resource.ID)
+		}
+		sections[resource.ID] = resource.Spec
+	}
+	if encoded, err = json.Marshal(sections); err != nil {
+		return config, err
+	}
+	var result Config
+	if err = json.Unmarshal(encoded, &result); err != nil {
+		return config, fmt.Errorf("invalid rules : %w", err)
+	}
+	return result, nil
+}
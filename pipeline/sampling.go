@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// SamplingRule samples the entries of a log type, either keeping one in every N or a random fraction of them
+type SamplingRule struct {
+	// Type is the log type this rule applies to, empty or * means all types
+	Type string `json:"type" mapstructure:"type"`
+	// Levels are the levels sampled, debug and info when empty so that warnings and errors are kept
+	Levels []string `json:"levels" mapstructure:"levels"`
+	// OneIn keeps the first of every N entries, e.g. 100 keeps one in a hundred
+	OneIn uint64 `json:"oneIn" mapstructure:"oneIn"`
+	// Rate keeps each entry with the probability, e.g. 0.01 keeps about one in a hundred and 1 keeps them all
+	Rate float64 `json:"rate" mapstructure:"rate"`
+}
+
+type samplingRule struct {
+	SamplingRule
+	levels map[string]bool
+	// seen counts the entries the rule applied to, for the one in N sampling
+	seen uint64
+}
+
+// sampler decides which entries are kept, by the first rule matching their type and level or else by the
+// sample rate
+type sampler struct {
+	rate  float64
+	rules []*samplingRule
+}
+
+type forceSampleKey struct{}
+
+// WithForceSample is used to get a context whose entries are never sampled out, to debug a producer
+func WithForceSample(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceSampleKey{}, true)
+}
+
+// forceSampled is used to check whether the entries of the context are never sampled out
+func forceSampled(ctx context.Context) bool {
+	force, _ := ctx.Value(forceSampleKey{}).(bool)
+	return force
+}
+
+func newSampler(rate float64, rules []SamplingRule) (*sampler, error) {
+	if rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("sample rate %v has to be between 0 and 1", rate)
+	}
+	s := &sampler{rate: rate, rules: make([]*samplingRule, 0, len(rules))}
+	for _, rule := range rules {
+		if (rule.OneIn > 0) == (rule.Rate > 0) {
+			return nil, fmt.Errorf("sampling rule for type %s needs either oneIn or a rate", rule.Type)
+		}
+		if rule.Rate > 1 {
+			return nil, fmt.Errorf("sampling rule for type %s has a rate %v above 1", rule.Type, rule.Rate)
+		}
+		levels := rule.Levels
+		if len(levels) == 0 {
+			levels = []string{constants.DebugLevel, constants.InfoLevel}
+		}
+		compiled := &samplingRule{SamplingRule: rule, levels: make(map[string]bool, len(levels))}
+		for _, level := range levels {
+			if _, ok := levelOrder[level]; !ok {
+				return nil, fmt.Errorf("sampling rule for type %s has an unknown level %q", rule.Type, level)
+			}
+			compiled.levels[level] = true
+		}
+		s.rules = append(s.rules, compiled)
+	}
+	return s, nil
+}
+
+// keep is used to check whether the entry is kept, its level is set
+func (s *sampler) keep(ctx context.Context, entry *models.LogEntry) bool {
+	if forceSampled(ctx) {
+		return true
+	}
+	for _, rule := range s.rules {
+		if !matchesType(rule.Type, entry.Type) || !rule.levels[entry.Level] {
+			continue
+		}
+		if rule.OneIn > 0 {
+			return (atomic.AddUint64(&rule.seen, 1)-1)%rule.OneIn == 0
+		}
+		return rand.Float64() < rule.Rate
+	}
+	// warnings and errors are rare and matter, so they are always kept
+	return s.rate == 0 || levelOrder[entry.Level] >= levelOrder[constants.WarnLevel] || rand.Float64() < s.rate
+}
@@ -0,0 +1,47 @@
+package pipeline_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSamplingRules(t *testing.T) {
+	ctx := context.Background()
+	p, err := pipeline.New(pipeline.Config{SampleRate: 0.5, Sampling: []pipeline.SamplingRule{
+		{Type: "cache", Levels: []string{"debug"}, OneIn: 10},
+		{Type: "orders", Rate: 1},
+	}})
+	assert.NoError(t, err)
+	kept := func(ctx context.Context, logType, level string, n int) int {
+		count := 0
+		for i := 0; i < n; i++ {
+			entry := models.LogEntry{Type: logType, Level: level}
+			if err := p.Process(ctx, &entry); err == nil {
+				count++
+			} else {
+				assert.ErrorIs(t, err, pipeline.ErrSampledOut)
+			}
+		}
+		return count
+	}
+
+	assert.Equal(t, 10, kept(ctx, "cache", "debug", 100))
+	// the levels the rule does not list fall back to the sample rate
+	assert.InDelta(t, 500, kept(ctx, "cache", "info", 1000), 100)
+	assert.Equal(t, 100, kept(ctx, "orders", "debug", 100))
+	assert.Equal(t, 100, kept(ctx, "payments", "error", 100))
+	// the header override keeps every entry
+	assert.Equal(t, 100, kept(pipeline.WithForceSample(ctx), "cache", "debug", 100))
+
+	for _, rule := range []pipeline.SamplingRule{
+		{Type: "cache"}, {Type: "cache", OneIn: 2, Rate: 0.5}, {Type: "cache", Rate: 2},
+		{Type: "cache", OneIn: 2, Levels: []string{"trace"}},
+	} {
+		_, err = pipeline.New(pipeline.Config{Sampling: []pipeline.SamplingRule{rule}})
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,515 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/alerts"
+	"github.com/angel-one/nbu-logger-service/catalog"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/tenants"
+)
+
+// SensitiveConfig is the configuration of the optional stage detecting unredacted sensitive data
+type SensitiveConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// Action is what happens to entries with findings, flag, redact, quarantine or reject, redact by default
+	Action string `json:"action" mapstructure:"action"`
+	// Detectors are the built in detectors used, all of them by default
+	Detectors []string `json:"detectors" mapstructure:"detectors"`
+	// Patterns are additional detectors
+	Patterns []SensitivePattern `json:"patterns" mapstructure:"patterns"`
+	// Policies override the action by type, the first match wins
+	Policies []SensitivePolicy `json:"policies" mapstructure:"policies"`
+	// AllowFields are dotted paths never scanned, e.g. fields known to hold masked values
+	AllowFields []string `json:"allowFields" mapstructure:"allowFields"`
+	// Secrets is the handling of leaked credentials on top of the action
+	Secrets SecretsConfig `json:"secrets" mapstructure:"secrets"`
+	// MaxImpactPercent is the share of recent entries a new rule set may affect before applying it needs an override
+	MaxImpactPercent float64 `json:"maxImpactPercent" mapstructure:"maxImpactPercent"`
+}
+
+// SecretsConfig is the handling of credentials, i.e. the secret, jwt and entropy findings
+type SecretsConfig struct {
+	// MinEntropy is the shannon entropy in bits per character above which tokens are taken as credentials,
+	// zero disables the detection, around 4 catches generated keys while leaving hex digests and ids alone
+	MinEntropy float64 `json:"minEntropy" mapstructure:"minEntropy"`
+	// MinLength is the length from which tokens are checked for entropy
+	MinLength int `json:"minLength" mapstructure:"minLength"`
+	// Alert sends the findings, never the values, to the security channel
+	Alert bool `json:"alert" mapstructure:"alert"`
+	// Reject rejects entries with credentials whatever the action is
+	Reject bool `json:"reject" mapstructure:"reject"`
+}
+
+// SensitivePattern is a named regular expression detecting a kind of sensitive data
+type SensitivePattern struct {
+	Name    string `json:"name" mapstructure:"name"`
+	Pattern string `json:"pattern" mapstructure:"pattern"`
+}
+
+// SensitivePolicy is the action taken for the entries of a type
+type SensitivePolicy struct {
+	Type   string `json:"type" mapstructure:"type"`
+	Action string `json:"action" mapstructure:"action"`
+}
+
+// detector finds a kind of sensitive data, valid filters the matches of patterns prone to false positives
+type detector struct {
+	name    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+}
+
+// sensitiveDetectors are the built in detectors. Values of fields named like secrets are detected on the name.
+var sensitiveDetectors = []detector{
+	{name: constants.SensitivePAN, pattern: regexp.MustCompile(`\b[A-Z]{3}[PCHFATBLJG][A-Z][0-9]{4}[A-Z]\b`)},
+	{name: constants.SensitiveAadhaar, pattern: regexp.MustCompile(`\b[2-9][0-9]{3}[ -]?[0-9]{4}[ -]?[0-9]{4}\b`),
+		valid: verhoeff},
+	{name: constants.SensitiveCard, pattern: regexp.MustCompile(`\b[0-9](?:[ -]?[0-9]){12,18}\b`), valid: luhn},
+	{name: constants.SensitiveEmail, pattern: regexp.MustCompile(
+		`\b[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}\b`)},
+	{name: constants.SensitivePhone, pattern: regexp.MustCompile(`(?:\+91[ -]?)?\b[6-9][0-9]{9}\b`)},
+	{name: constants.SensitiveJWT, pattern: regexp.MustCompile(
+		`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]*`)},
+	{name: constants.SensitiveSecret, pattern: regexp.MustCompile(
+		`\bAKIA[0-9A-Z]{16}\b|-----BEGIN [A-Z ]*PRIVATE KEY-----|(?i:\bbearer\s+[A-Za-z0-9._~+/\-]{16,}=*)|` +
+			`\bgh[pousr]_[A-Za-z0-9]{36}\b|\bxox[abpr]-[A-Za-z0-9\-]{10,}|\b[rs]k_live_[A-Za-z0-9]{16,}\b`)},
+}
+
+// secretNames are the normalized field name parts whose string values are secrets
+var secretNames = []string{"password", "passwd", "secret", "token", "apikey", "authorization", "privatekey",
+	"credential"}
+
+type sensitiveStage struct {
+	enabled     bool
+	action      string
+	detectors   []detector
+	secretNames bool
+	policies    []SensitivePolicy
+	allow       map[string]bool
+	secrets     SecretsConfig
+	tokens      *regexp.Regexp
+	dryRun      bool
+}
+
+func newSensitiveStage(config SensitiveConfig) (*sensitiveStage, error) {
+	s := &sensitiveStage{enabled: config.Enabled, action: config.Action, allow: make(map[string]bool),
+		secrets: config.Secrets}
+	if s.secrets.MinEntropy > 0 {
+		if s.secrets.MinLength <= 0 {
+			s.secrets.MinLength = constants.DefaultSecretMinLength
+		}
+		s.tokens = regexp.MustCompile(fmt.Sprintf(`[A-Za-z0-9+/_\-=.]{%d,}`, s.secrets.MinLength))
+	}
+	if s.action == "" {
+		s.action = constants.SensitiveRedact
+	}
+	for _, policy := range append([]SensitivePolicy{{Action: s.action}}, config.Policies...) {
+		if !validSensitiveAction(policy.Action) {
+			return nil, fmt.Errorf("sensitive action %q has to be one of flag, redact, quarantine or reject",
+				policy.Action)
+		}
+	}
+	s.policies = config.Policies
+	if len(config.Detectors) == 0 {
+		s.detectors = append(s.detectors, sensitiveDetectors...)
+		s.secretNames = true
+	}
+	for _, name := range config.Detectors {
+		found := false
+		for _, d := range sensitiveDetectors {
+			if d.name == name {
+				s.detectors, found = append(s.detectors, d), true
+			}
+		}
+		if name == constants.SensitiveSecret {
+			s.secretNames = true
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown sensitive detector %s", name)
+		}
+	}
+	for _, p := range config.Patterns {
+		pattern, err := regexp.Compile(p.Pattern)
+		if err != nil || p.Name == "" {
+			return nil, fmt.Errorf("sensitive pattern %q needs a name and a valid pattern", p.Name)
+		}
+		s.detectors = append(s.detectors, detector{name: p.Name, pattern: pattern})
+	}
+	for _, field := range config.AllowFields {
+		s.allow[field] = true
+	}
+	return s, nil
+}
+
+func validSensitiveAction(action string) bool {
+	switch action {
+	case constants.SensitiveFlag, constants.SensitiveRedact, constants.SensitiveQuarantine, constants.SensitiveReject:
+		return true
+	}
+	return false
+}
+
+func (s *sensitiveStage) Name() string {
+	return "sensitive"
+}
+
+func (s *sensitiveStage) Process(ctx context.Context, entry *models.LogEntry) error {
+	if !s.enabled {
+		return nil
+	}
+	action, scan := s.scan(entry)
+	if len(scan.fields) == 0 {
+		return nil
+	}
+	kinds := scan.kindNames()
+	if !s.dryRun {
+		reportSensitive(ctx, entry.Type, kinds, scan.fields)
+	}
+	if credentials := credentialKinds(kinds); len(credentials) > 0 {
+		if s.secrets.Reject {
+			action = constants.SensitiveReject
+		}
+		if s.secrets.Alert && !s.dryRun {
+			tenant, _ := tenants.FromContext(ctx)
+			text := fmt.Sprintf("Credentials (%s) leaked in %s entries%s, fields %s, action %s",
+				strings.Join(credentials, ", "), entry.Type, tenantSuffix(tenant), strings.Join(scan.fields, ", "), action)
+			if owner, ok := catalog.OwnerOf(*entry); ok {
+				text = fmt.Sprintf("%s, owned by %s", text, owner)
+			}
+			alerts.Send(ctx, tenant+"/"+entry.Type, text)
+		}
+	}
+	switch action {
+	case constants.SensitiveReject:
+		return fmt.Errorf("unredacted %s in %s", strings.Join(kinds, ", "), strings.Join(scan.fields, ", "))
+	case constants.SensitiveQuarantine:
+		SetMeta(entry, constants.MetaQuarantinedTypeKey, entry.Type)
+		entry.Type = constants.QuarantineTypePrefix + entry.Type
+	}
+	SetMeta(entry, constants.MetaSensitiveKey, map[string]interface{}{
+		"kinds": kinds, "fields": scan.fields, "action": action,
+	})
+	return nil
+}
+
+// scan is used to get the action of the entry and its findings, redacting them when the action is redact
+func (s *sensitiveStage) scan(entry *models.LogEntry) (string, *sensitiveScan) {
+	action := s.action
+	for _, policy := range s.policies {
+		if matchesType(policy.Type, entry.Type) {
+			action = policy.Action
+			break
+		}
+	}
+	scan := &sensitiveScan{stage: s, redact: action == constants.SensitiveRedact, kinds: make(map[string]int)}
+	for key, value := range entry.Data {
+		if !IsReserved(key) {
+			entry.Data[key] = scan.value(key, key, value)
+		}
+	}
+	for key, value := range entry.Labels {
+		entry.Labels[key] = scan.value(constants.LabelsField+"."+key, key, value).(string)
+	}
+	sort.Strings(scan.fields)
+	return action, scan
+}
+
+// sensitiveScan walks the values of an entry collecting the findings and redacting them when asked to
+type sensitiveScan struct {
+	stage   *sensitiveStage
+	redact  bool
+	kinds   map[string]int
+	fields  []string
+	scanned int
+}
+
+// kindNames is used to get the sorted kinds found
+func (scan *sensitiveScan) kindNames() []string {
+	kinds := make([]string, 0, len(scan.kinds))
+	for kind := range scan.kinds {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+func (scan *sensitiveScan) value(path, name string, value interface{}) interface{} {
+	if scan.stage.allow[path] {
+		return value
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = scan.value(path+"."+key, key, child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = scan.value(path, name, child)
+		}
+	case string:
+		if v == "" {
+			return v
+		}
+		scan.scanned++
+		if scan.stage.secretNames && isSecretName(name) && !strings.HasPrefix(v, constants.RedactedPrefix) {
+			scan.found(path, constants.SensitiveSecret)
+			if scan.redact {
+				return redacted(constants.SensitiveSecret)
+			}
+			return v
+		}
+		return scan.text(path, v)
+	case float64:
+		// identifiers such as phone numbers are often sent as json numbers
+		scan.scanned++
+		if v == float64(int64(v)) {
+			text := strconv.FormatInt(int64(v), 10)
+			if replaced := scan.text(path, text); replaced != text {
+				return replaced
+			}
+		}
+	}
+	return value
+}
+
+func (scan *sensitiveScan) text(path, text string) string {
+	for _, d := range scan.stage.detectors {
+		d := d
+		text = d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if d.valid != nil && !d.valid(match) {
+				return match
+			}
+			scan.found(path, d.name)
+			if scan.redact {
+				return redacted(d.name)
+			}
+			return match
+		})
+	}
+	if scan.stage.tokens != nil {
+		text = scan.stage.tokens.ReplaceAllStringFunc(text, func(token string) string {
+			if !highEntropy(token, scan.stage.secrets.MinEntropy) {
+				return token
+			}
+			scan.found(path, constants.SensitiveEntropy)
+			if scan.redact {
+				return redacted(constants.SensitiveEntropy)
+			}
+			return token
+		})
+	}
+	return text
+}
+
+// highEntropy is used to check whether the token mixes letters and digits with at least the entropy in bits per
+// character, words and numbers on their own are never credentials
+func highEntropy(token string, minEntropy float64) bool {
+	counts := make(map[rune]int)
+	letters, numbers := false, false
+	for _, r := range token {
+		counts[r]++
+		letters = letters || unicode.IsLetter(r)
+		numbers = numbers || unicode.IsDigit(r)
+	}
+	if !letters || !numbers {
+		return false
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(len(token))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy >= minEntropy
+}
+
+// credentialKinds is used to get the kinds of the findings that are credentials
+func credentialKinds(kinds []string) []string {
+	var credentials []string
+	for _, kind := range kinds {
+		if kind == constants.SensitiveSecret || kind == constants.SensitiveJWT || kind == constants.SensitiveEntropy {
+			credentials = append(credentials, kind)
+		}
+	}
+	return credentials
+}
+
+func tenantSuffix(tenant string) string {
+	if tenant == "" {
+		return ""
+	}
+	return " of tenant " + tenant
+}
+
+func (scan *sensitiveScan) found(path, kind string) {
+	scan.kinds[kind]++
+	for _, field := range scan.fields {
+		if field == path {
+			return
+		}
+	}
+	scan.fields = append(scan.fields, path)
+}
+
+// Scrub is used to redact what the default detectors find in a body, the values of the secret named fields of
+// json bodies included
+func Scrub(body []byte) string {
+	stage, _ := newSensitiveStage(SensitiveConfig{Enabled: true})
+	scan := &sensitiveScan{stage: stage, redact: true, kinds: make(map[string]int)}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err == nil {
+		if scrubbed, err := json.Marshal(scan.value("", "", value)); err == nil {
+			return string(scrubbed)
+		}
+	}
+	return scan.text("", string(body))
+}
+
+func redacted(kind string) string {
+	return constants.RedactedPrefix + kind + "]"
+}
+
+// isSecretName is used to check whether a field name is one of the secret names, ignoring case, _ and -
+func isSecretName(name string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name))
+	for _, secret := range secretNames {
+		if strings.Contains(normalized, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func digits(match string) []int {
+	var result []int
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			result = append(result, int(r-'0'))
+		}
+	}
+	return result
+}
+
+// luhn is used to check the checksum of card numbers
+func luhn(match string) bool {
+	sum := 0
+	numbers := digits(match)
+	for i := range numbers {
+		n := numbers[len(numbers)-1-i]
+		if i%2 == 1 {
+			if n *= 2; n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return len(numbers) >= 13 && sum%10 == 0
+}
+
+var verhoeffD = [10][10]int{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, {1, 2, 3, 4, 0, 6, 7, 8, 9, 5}, {2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+	{3, 4, 0, 1, 2, 8, 9, 5, 6, 7}, {4, 0, 1, 2, 3, 9, 5, 6, 7, 8}, {5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+	{6, 5, 9, 8, 7, 1, 0, 4, 3, 2}, {7, 6, 5, 9, 8, 2, 1, 0, 4, 3}, {8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+	{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+}
+
+var verhoeffP = [8][10]int{
+	{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, {1, 5, 7, 6, 2, 8, 3, 0, 9, 4}, {5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+	{8, 9, 1, 6, 0, 4, 3, 5, 2, 7}, {9, 4, 5, 3, 1, 2, 6, 8, 7, 0}, {4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+	{2, 7, 9, 3, 8, 0, 6, 4, 1, 5}, {7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+}
+
+// verhoeff is used to check the checksum of aadhaar numbers
+func verhoeff(match string) bool {
+	c := 0
+	numbers := digits(match)
+	for i := range numbers {
+		c = verhoeffD[c][verhoeffP[i%8][numbers[len(numbers)-1-i]]]
+	}
+	return c == 0
+}
+
+// SensitiveProducer is the tally of the sensitive data sent by the producer of a type
+type SensitiveProducer struct {
+	Tenant   string           `json:"tenant,omitempty"`
+	Type     string           `json:"type"`
+	Entries  int64            `json:"entries"`
+	Kinds    map[string]int64 `json:"kinds"`
+	Fields   []string         `json:"fields"`
+	LastSeen time.Time        `json:"lastSeen"`
+}
+
+var (
+	sensitiveMu        sync.Mutex
+	sensitiveProducers = make(map[string]*SensitiveProducer)
+)
+
+// reportSensitive is used to tally the findings by the producer, the report outlives pipeline reloads
+func reportSensitive(ctx context.Context, entryType string, kinds, fields []string) {
+	tenant, _ := tenants.FromContext(ctx)
+	sensitiveMu.Lock()
+	defer sensitiveMu.Unlock()
+	key := tenant + "/" + entryType
+	producer, ok := sensitiveProducers[key]
+	if !ok {
+		if len(sensitiveProducers) >= constants.MaxSensitiveProducers {
+			return
+		}
+		producer = &SensitiveProducer{Tenant: tenant, Type: entryType, Kinds: make(map[string]int64)}
+		sensitiveProducers[key] = producer
+		log.Warn(ctx).Str(constants.TenantKey, tenant).Str(constants.TypeKey, entryType).
+			Strs(constants.FieldKey, fields).Msg("producer sends unredacted sensitive data")
+	}
+	producer.Entries++
+	producer.LastSeen = time.Now().UTC()
+	for _, kind := range kinds {
+		producer.Kinds[kind]++
+	}
+	for _, field := range fields {
+		if len(producer.Fields) >= constants.MaxSensitiveProducerFields {
+			break
+		}
+		known := false
+		for _, f := range producer.Fields {
+			known = known || f == field
+		}
+		if !known {
+			producer.Fields = append(producer.Fields, field)
+		}
+	}
+}
+
+// SensitiveReport is used to get the producers that sent sensitive data, the most offending first
+func SensitiveReport() []SensitiveProducer {
+	sensitiveMu.Lock()
+	defer sensitiveMu.Unlock()
+	report := make([]SensitiveProducer, 0, len(sensitiveProducers))
+	for _, producer := range sensitiveProducers {
+		copied := *producer
+		copied.Kinds = make(map[string]int64, len(producer.Kinds))
+		for kind, count := range producer.Kinds {
+			copied.Kinds[kind] = count
+		}
+		copied.Fields = append([]string(nil), producer.Fields...)
+		report = append(report, copied)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Entries != report[j].Entries {
+			return report[i].Entries > report[j].Entries
+		}
+		return report[i].Tenant+"/"+report[i].Type < report[j].Tenant+"/"+report[j].Type
+	})
+	return report
+}
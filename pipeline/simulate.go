@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/store"
+)
+
+// RedactionImpact is the share of recent traffic a sensitive rule set would act on
+type RedactionImpact struct {
+	// Sampled is the number of recent entries the rules were run against
+	Sampled int `json:"sampled"`
+	// Entries is the number of sampled entries with findings
+	Entries        int     `json:"entries"`
+	EntriesPercent float64 `json:"entriesPercent"`
+	// Fields is the number of values with findings out of the Scanned values
+	Fields        int            `json:"fields"`
+	Scanned       int            `json:"scanned"`
+	FieldsPercent float64        `json:"fieldsPercent"`
+	Kinds         map[string]int `json:"kinds"`
+	Actions       map[string]int `json:"actions"`
+	// MaxImpactPercent is the limit of the applied rules, Blocked is set when the impact is above it
+	MaxImpactPercent float64 `json:"maxImpactPercent"`
+	Blocked          bool    `json:"blocked"`
+}
+
+// SimulateSensitive is used to run the sensitive rules against copies of the recent entries in the store.
+// The stored entries went through the applied rules, so the impact is what the rules would add to them.
+func SimulateSensitive(ctx context.Context, config SensitiveConfig) (RedactionImpact, error) {
+	limit := Applied().Sensitive.MaxImpactPercent
+	if limit <= 0 {
+		limit = constants.DefaultRedactionMaxImpactPercent
+	}
+	impact := RedactionImpact{Kinds: make(map[string]int), Actions: make(map[string]int), MaxImpactPercent: limit}
+	stage, err := newSensitiveStage(config)
+	if err != nil || !stage.enabled {
+		return impact, err
+	}
+	records, err := store.Get().Query(ctx, store.Query{Limit: constants.RedactionSimulationSampleSize})
+	if err != nil {
+		return impact, err
+	}
+	for _, record := range records {
+		// the stage changes the entry in place, so it runs on a copy of the stored one
+		entry := copyEntry(record.Entry)
+		action, scan := stage.scan(&entry)
+		impact.Sampled++
+		impact.Scanned += scan.scanned
+		if len(scan.fields) == 0 {
+			continue
+		}
+		impact.Entries++
+		impact.Fields += len(scan.fields)
+		impact.Actions[action]++
+		for kind, count := range scan.kinds {
+			impact.Kinds[kind] += count
+		}
+	}
+	if impact.Sampled > 0 {
+		impact.EntriesPercent = 100 * float64(impact.Entries) / float64(impact.Sampled)
+	}
+	if impact.Scanned > 0 {
+		impact.FieldsPercent = 100 * float64(impact.Fields) / float64(impact.Scanned)
+	}
+	impact.Blocked = impact.EntriesPercent > limit || impact.FieldsPercent > limit
+	return impact, nil
+}
+
+// SimulateRules is used to get the impact of a rules resource before it is applied, nil for the sections
+// without a simulation. Only the sensitive section is simulated, as a bad pattern there silently
+// redacts payloads instead of failing entries.
+func SimulateRules(ctx context.Context, id string, spec json.RawMessage) (*RedactionImpact, error) {
+	if id != constants.SensitiveRulesID {
+		return nil, nil
+	}
+	var config SensitiveConfig
+	if err := json.Unmarshal(spec, &config); err != nil {
+		return nil, fmt.Errorf("invalid rules : %w", err)
+	}
+	impact, err := SimulateSensitive(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return &impact, nil
+}
@@ -0,0 +1,110 @@
+// Package privacy anonymizes the counts of the stats apis exposed to broader audiences, so that the behaviour of
+// a single user can not be inferred from them. Counts below the k threshold are suppressed, and with an epsilon
+// the counts are first noised with the laplace mechanism of differential privacy.
+package privacy
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+// Config is the configuration of the anonymized aggregation
+type Config struct {
+	// K is the count below which a bucket is suppressed
+	K int `json:"k" mapstructure:"k"`
+	// Epsilon is the privacy budget of the noise added to every count, smaller values add more noise and 0 adds
+	// none. Every request is noised afresh, so the budget holds for one request and the threshold for many.
+	Epsilon float64 `json:"epsilon" mapstructure:"epsilon"`
+	// Enforced anonymizes the counts of every stats request, only the ones asking for it are anonymized otherwise
+	Enforced bool `json:"enforced" mapstructure:"enforced"`
+}
+
+// Anonymizer suppresses and noises counts
+type Anonymizer struct {
+	config Config
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+var a, _ = New(Config{})
+
+// New is used to create the anonymizer of the config
+func New(config Config) (*Anonymizer, error) {
+	if config.K < 0 || config.Epsilon < 0 {
+		return nil, fmt.Errorf("k and epsilon of the anonymized aggregation can not be negative")
+	}
+	if config.K == 0 {
+		config.K = constants.DefaultAnonymityThreshold
+	}
+	return &Anonymizer{config: config, random: rand.New(rand.NewSource(time.Now().UnixNano()))}, nil
+}
+
+// Init is used to replace the default anonymizer
+func Init(config Config) error {
+	anonymizer, err := New(config)
+	if err != nil {
+		return err
+	}
+	a = anonymizer
+	return nil
+}
+
+// Get is used to get the default anonymizer
+func Get() *Anonymizer {
+	return a
+}
+
+// Enforced is used to check whether every stats request is anonymized
+func (a *Anonymizer) Enforced() bool {
+	return a.config.Enforced
+}
+
+// K is used to get the threshold below which counts are suppressed
+func (a *Anonymizer) K() int {
+	return a.config.K
+}
+
+// Count is used to get the noised count and whether it is kept, a count below the threshold once noised is not
+func (a *Anonymizer) Count(n int) (int, bool) {
+	if a.config.Epsilon > 0 {
+		n += int(math.Round(a.laplace(1 / a.config.Epsilon)))
+	}
+	if n < a.config.K {
+		return 0, false
+	}
+	return n, true
+}
+
+// Counts is used to get the kept counts of the buckets and the number of suppressed buckets
+func (a *Anonymizer) Counts(counts map[string]int) (map[string]int, int) {
+	kept := make(map[string]int, len(counts))
+	suppressed := 0
+	for key, n := range counts {
+		if n, ok := a.Count(n); ok {
+			kept[key] = n
+		} else {
+			suppressed++
+		}
+	}
+	return kept, suppressed
+}
+
+// laplace is used to draw from the laplace distribution of the scale, a count changes by one for a user so the
+// scale is 1/epsilon
+func (a *Anonymizer) laplace(scale float64) float64 {
+	a.mu.Lock()
+	u := a.random.Float64() - 0.5
+	for u == -0.5 {
+		u = a.random.Float64() - 0.5
+	}
+	a.mu.Unlock()
+	if u < 0 {
+		return scale * math.Log(1+2*u)
+	}
+	return -scale * math.Log(1-2*u)
+}
@@ -0,0 +1,32 @@
+package privacy_test
+
+import (
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/privacy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnonymizer(t *testing.T) {
+	anonymizer, err := privacy.New(privacy.Config{K: 5})
+	assert.NoError(t, err)
+	kept, suppressed := anonymizer.Counts(map[string]int{"login": 120, "refund": 2, "transfer": 5})
+	assert.Equal(t, map[string]int{"login": 120, "transfer": 5}, kept)
+	assert.Equal(t, 1, suppressed)
+
+	_, err = privacy.New(privacy.Config{Epsilon: -1})
+	assert.Error(t, err)
+
+	// the noise is centered on the count, with a scale of 1/epsilon
+	noised, err := privacy.New(privacy.Config{K: 1, Epsilon: 0.5})
+	assert.NoError(t, err)
+	sum, changed := 0, false
+	for i := 0; i < 2000; i++ {
+		n, ok := noised.Count(1000)
+		assert.True(t, ok)
+		sum += n
+		changed = changed || n != 1000
+	}
+	assert.True(t, changed)
+	assert.InDelta(t, 1000, float64(sum)/2000, 1)
+}
@@ -0,0 +1,402 @@
+// Package queue decouples ingestion from persistence: entries that went through the pipeline are enqueued
+// as asynq tasks in redis and workers store and emit them with retries.
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/routing"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/go-redis/redis/v8"
+	"github.com/hibiken/asynq"
+)
+
+// Config is the configuration of the ingestion queue, read from the jobs config
+type Config struct {
+	// RedisURL is the redis uri of the queue, e.g. redis://:password@host:6379/0
+	RedisURL string `json:"-" mapstructure:"redisUrl"`
+	// Workers is the number of entries persisted concurrently by a worker
+	Workers int `json:"numberOfWorkers" mapstructure:"numberOfWorkers"`
+	// RetentionInHours is how long persisted tasks are kept for inspection, zero deletes them right away
+	RetentionInHours int `json:"jobsRetentionTimeInHours" mapstructure:"jobsRetentionTimeInHours"`
+	// Queue is the name of the queue
+	Queue string `json:"queue" mapstructure:"queue"`
+	// MaxRetry is the number of times persisting an entry is retried
+	MaxRetry int `json:"maxRetry" mapstructure:"maxRetry"`
+	// MaxRetryByType overrides the max retry for the entries of the types, e.g. 20 for the audit entries a sink
+	// must not miss, the types are matched ignoring the case
+	MaxRetryByType map[string]int `json:"maxRetryByType" mapstructure:"maxRetryByType"`
+	// RetryBaseDelayInMillis is the delay of the first retry, doubled on every retry after
+	RetryBaseDelayInMillis int `json:"retryBaseDelayInMillis" mapstructure:"retryBaseDelayInMillis"`
+	// RetryMaxDelayInMillis caps the delay of the retries
+	RetryMaxDelayInMillis int `json:"retryMaxDelayInMillis" mapstructure:"retryMaxDelayInMillis"`
+	// RetryJitterRatio is the part of the delay taken off at random, so that the tasks failing together, e.g. on
+	// an outage of a sink, are not retried together
+	RetryJitterRatio float64 `json:"retryJitterRatio" mapstructure:"retryJitterRatio"`
+	// TenantQueues enqueues the entries of every tenant in a queue of its own, named queue:tenant, and the
+	// workers share out between the queues, so the backlog of a tenant does not hold up the others
+	TenantQueues bool `json:"tenantQueues" mapstructure:"tenantQueues"`
+}
+
+// Handler persists an entry taken off the queue, a returned error retries it
+type Handler func(ctx context.Context, entry models.LogEntry) error
+
+type queue struct {
+	config    Config
+	redis     asynq.RedisConnOpt
+	client    *asynq.Client
+	inspector *asynq.Inspector
+	// rdb is a connection of the queue redis to check that it is reachable
+	rdb     redis.UniversalClient
+	mu      sync.Mutex
+	server  *asynq.Server
+	handler Handler
+	// tenants are the registered tenants, served are the queues the running workers serve with their weights
+	tenants []string
+	served  map[string]int
+	// refresh asks for the queues to be discovered again, done is closed on Stop
+	refresh chan struct{}
+	done    chan struct{}
+}
+
+var q *queue
+
+// Init is used to connect the ingestion queue, entries are enqueued from then on
+func Init(config Config) error {
+	opt, err := asynq.ParseRedisURI(config.RedisURL)
+	if err != nil {
+		return fmt.Errorf("invalid queue redis url : %w", err)
+	}
+	if config.Workers <= 0 {
+		config.Workers = constants.DefaultQueueWorkers
+	}
+	if config.Queue == "" {
+		config.Queue = constants.DefaultIngestQueue
+	}
+	if config.MaxRetry <= 0 {
+		config.MaxRetry = constants.DefaultQueueMaxRetry
+	}
+	maxRetryByType := make(map[string]int, len(config.MaxRetryByType))
+	for logType, maxRetry := range config.MaxRetryByType {
+		if maxRetry < 0 {
+			return fmt.Errorf("max retry %d of type %s can not be negative", maxRetry, logType)
+		}
+		maxRetryByType[strings.ToLower(logType)] = maxRetry
+	}
+	config.MaxRetryByType = maxRetryByType
+	if config.RetryBaseDelayInMillis <= 0 {
+		config.RetryBaseDelayInMillis = constants.DefaultQueueRetryBaseDelayInMillis
+	}
+	if config.RetryMaxDelayInMillis <= 0 {
+		config.RetryMaxDelayInMillis = constants.DefaultQueueRetryMaxDelayInMillis
+	}
+	if config.RetryMaxDelayInMillis < config.RetryBaseDelayInMillis {
+		return fmt.Errorf("retry max delay %dms is less than the base delay %dms", config.RetryMaxDelayInMillis,
+			config.RetryBaseDelayInMillis)
+	}
+	if config.RetryJitterRatio == 0 {
+		config.RetryJitterRatio = constants.DefaultQueueRetryJitterRatio
+	}
+	if config.RetryJitterRatio < 0 || config.RetryJitterRatio > 1 {
+		return fmt.Errorf("retry jitter ratio %v has to be between 0 and 1", config.RetryJitterRatio)
+	}
+	rdb, _ := opt.MakeRedisClient().(redis.UniversalClient)
+	q = &queue{config: config, redis: opt, client: asynq.NewClient(opt), inspector: asynq.NewInspector(opt), rdb: rdb,
+		refresh: make(chan struct{}, 1), done: make(chan struct{})}
+	return nil
+}
+
+// Ping is used to check that the redis of the queue is reachable
+func Ping(ctx context.Context) error {
+	if q == nil || q.rdb == nil {
+		return fmt.Errorf("ingestion queue is not initialized")
+	}
+	return q.rdb.Ping(ctx).Err()
+}
+
+// Enabled is used to check whether entries are persisted through the queue
+func Enabled() bool {
+	return q != nil
+}
+
+// Enqueue is used to enqueue the entry to be persisted by a worker
+func Enqueue(ctx context.Context, entry models.LogEntry) error {
+	if q == nil {
+		return fmt.Errorf("ingestion queue is not initialized")
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tenant, _ := tenants.FromContext(ctx)
+	options := []asynq.Option{asynq.Queue(q.name(routing.Get().Queue(entry.Type), tenant)),
+		asynq.MaxRetry(q.maxRetry(entry.Type))}
+	if q.config.RetentionInHours > 0 {
+		options = append(options, asynq.Retention(time.Duration(q.config.RetentionInHours)*time.Hour))
+	}
+	_, err = q.client.Enqueue(asynq.NewTask(constants.IngestTaskType, payload), options...)
+	return err
+}
+
+// Backlog is used to get the number of entries waiting in the queues, the ones pending, scheduled or to be retried
+func Backlog() (int, error) {
+	if q == nil {
+		return 0, fmt.Errorf("ingestion queue is not initialized")
+	}
+	q.mu.Lock()
+	served := q.served
+	q.mu.Unlock()
+	if len(served) == 0 {
+		served = map[string]int{q.config.Queue: constants.DefaultTenantShare}
+	}
+	backlog := 0
+	for name := range served {
+		info, err := q.inspector.GetQueueInfo(name)
+		if errors.Is(err, asynq.ErrQueueNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error inspecting the ingestion queue %s : %w", name, err)
+		}
+		backlog += info.Pending + info.Scheduled + info.Retry
+	}
+	return backlog, nil
+}
+
+// MaxRetry is used to get the max retry of the entries of the type, zero when the queue is not initialized
+func MaxRetry(logType string) int {
+	if q == nil {
+		return 0
+	}
+	return q.maxRetry(logType)
+}
+
+// Backoff is used to get the delay of the nth retry with the retry policy of the queue
+func Backoff(n int) time.Duration {
+	if q == nil {
+		return 0
+	}
+	return RetryDelay(q.config)(n, nil, nil)
+}
+
+// maxRetry is used to get the max retry of the entries of the type
+func (q *queue) maxRetry(logType string) int {
+	if maxRetry, ok := q.config.MaxRetryByType[strings.ToLower(logType)]; ok {
+		return maxRetry
+	}
+	return q.config.MaxRetry
+}
+
+// RetryDelay is used to get the delay of the retries of the config instead of the asynq one, doubled from the
+// base delay on every retry up to the max delay, and shortened at random by up to the jitter ratio
+func RetryDelay(config Config) asynq.RetryDelayFunc {
+	return func(n int, _ error, _ *asynq.Task) time.Duration {
+		delay := math.Min(float64(config.RetryBaseDelayInMillis)*math.Pow(2, float64(n)),
+			float64(config.RetryMaxDelayInMillis))
+		delay *= 1 - config.RetryJitterRatio*rand.Float64()
+		return time.Duration(delay * float64(time.Millisecond))
+	}
+}
+
+// name is used to get the queue of the entries of the tenant, in the routed queue when it is not empty
+func (q *queue) name(routed, tenant string) string {
+	if routed == "" {
+		routed = q.config.Queue
+	}
+	if !q.config.TenantQueues || tenant == "" {
+		return routed
+	}
+	return routed + ":" + tenant
+}
+
+// Start is used to persist the queued entries in the background until Stop. With the tenant queues, the queues
+// served are discovered again on every change of the tenants and every interval.
+func Start(handler Handler) error {
+	if q == nil {
+		return fmt.Errorf("ingestion queue is not initialized")
+	}
+	q.mu.Lock()
+	q.handler = handler
+	q.mu.Unlock()
+	queues := q.queues()
+	server, err := q.serve(queues)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	q.server, q.served = server, queues
+	q.mu.Unlock()
+	if q.config.TenantQueues {
+		go q.watch()
+	}
+	return nil
+}
+
+// SetTenants is used to set the tenants whose queues are served along with the shared one. The workers are
+// restarted with the queues of the tenants in the background, as asynq serves a fixed set of queues.
+func SetTenants(ids []string) {
+	if q == nil || !q.config.TenantQueues {
+		return
+	}
+	q.mu.Lock()
+	q.tenants = append([]string{}, ids...)
+	q.mu.Unlock()
+	select {
+	case q.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// Stop is used to wait for the entries being persisted and stop the workers and the client
+func Stop() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	close(q.done)
+	server := q.server
+	q.server = nil
+	q.mu.Unlock()
+	if server != nil {
+		server.Shutdown()
+	}
+	if err := q.client.Close(); err != nil {
+		log.Warn(context.Background()).Err(err).Msg("error closing ingestion queue client")
+	}
+	if q.rdb != nil {
+		_ = q.rdb.Close()
+	}
+	q = nil
+}
+
+// watch is used to restart the workers whenever the queues to serve change, until Stop
+func (q *queue) watch() {
+	ticker := time.NewTicker(constants.QueueDiscoveryIntervalInSeconds * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-q.done:
+			return
+		case <-q.refresh:
+		case <-ticker.C:
+		}
+		q.restart()
+	}
+}
+
+// restart is used to serve the queues discovered when they changed. The workers of the new queues are started
+// before the running ones are drained, so that the running ones keep persisting when they fail to start.
+func (q *queue) restart() {
+	queues := q.queues()
+	q.mu.Lock()
+	unchanged := reflect.DeepEqual(queues, q.served)
+	q.mu.Unlock()
+	if unchanged {
+		return
+	}
+	server, err := q.serve(queues)
+	if err != nil {
+		log.Error(context.Background()).Err(err).Msg("error restarting ingestion workers, the running ones are kept")
+		return
+	}
+	q.mu.Lock()
+	previous := q.server
+	select {
+	case <-q.done:
+		// stopped meanwhile, the workers just started are not needed
+		previous = server
+	default:
+		q.server, q.served = server, queues
+	}
+	q.mu.Unlock()
+	if previous != nil {
+		previous.Shutdown()
+	}
+}
+
+// queues is used to get the queues to serve with their weights: the shared one, the ones of the registered tenants
+// and the ones of the tenants found in redis, so that the entries queued for a tenant this instance does not know
+// of yet, or no longer knows of, are persisted too. The queues are weighted by the shares of the tenants, so the
+// workers share out between the tenants.
+func (q *queue) queues() map[string]int {
+	queues := map[string]int{q.config.Queue: constants.DefaultTenantShare}
+	// the queues the types are routed to are served as the shared one, along with the ones of the tenants
+	routed := append([]string{""}, routing.Get().Queues()...)
+	q.mu.Lock()
+	for _, name := range routed {
+		if name != "" {
+			queues[name] = constants.DefaultTenantShare
+		}
+		for _, tenant := range q.tenants {
+			queues[q.name(name, tenant)] = tenants.Share(tenant)
+		}
+	}
+	q.mu.Unlock()
+	if q.config.TenantQueues {
+		found, err := q.inspector.Queues()
+		if err != nil {
+			log.Warn(context.Background()).Err(err).Msg("error discovering the tenant queues")
+		}
+		for _, name := range found {
+			for _, r := range routed {
+				if tenant := strings.TrimPrefix(name, q.name(r, "")+":"); tenant != name {
+					queues[name] = tenants.Share(tenant)
+				}
+			}
+		}
+	}
+	return queues
+}
+
+// serve is used to start workers persisting the entries of the queues by their weights
+func (q *queue) serve(queues map[string]int) (*asynq.Server, error) {
+	q.mu.Lock()
+	handler := q.handler
+	q.mu.Unlock()
+	server := asynq.NewServer(q.redis, asynq.Config{
+		Concurrency:    q.config.Workers,
+		Queues:         queues,
+		RetryDelayFunc: RetryDelay(q.config),
+		ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
+			retried, _ := asynq.GetRetryCount(ctx)
+			maxRetry, _ := asynq.GetMaxRetry(ctx)
+			if retried >= maxRetry || errors.Is(err, asynq.SkipRetry) {
+				log.Error(ctx).Err(err).Int(constants.RetriedKey, retried).
+					Msg("error persisting queued log entry, it is archived")
+				return
+			}
+			log.Warn(ctx).Err(err).Int(constants.RetriedKey, retried).Int(constants.MaxRetryKey, maxRetry).
+				Msg("error persisting queued log entry, it is retried")
+		}),
+	})
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(constants.IngestTaskType, TaskHandler(handler))
+	if err := server.Start(mux); err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// TaskHandler is used to get the asynq handler decoding the entries for the handler,
+// malformed payloads are never retried
+func TaskHandler(handler Handler) func(ctx context.Context, task *asynq.Task) error {
+	return func(ctx context.Context, task *asynq.Task) error {
+		var entry models.LogEntry
+		if err := json.Unmarshal(task.Payload(), &entry); err != nil {
+			return fmt.Errorf("invalid queued log entry : %v : %w", err, asynq.SkipRetry)
+		}
+		return handler(ctx, entry)
+	}
+}
@@ -0,0 +1,54 @@
+package queue_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/queue"
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaskHandler(t *testing.T) {
+	var persisted []models.LogEntry
+	handler := queue.TaskHandler(func(_ context.Context, entry models.LogEntry) error {
+		persisted = append(persisted, entry)
+		return nil
+	})
+	assert.NoError(t, handler(context.Background(), asynq.NewTask("logger:ingest",
+		[]byte(`{"type":"orders","Data":{"id":1}}`))))
+	if assert.Len(t, persisted, 1) {
+		assert.Equal(t, "orders", persisted[0].Type)
+	}
+	err := handler(context.Background(), asynq.NewTask("logger:ingest", []byte(`{`)))
+	assert.True(t, errors.Is(err, asynq.SkipRetry))
+	assert.False(t, queue.Enabled())
+	assert.Error(t, queue.Init(queue.Config{RedisURL: "http://not-redis"}))
+}
+
+func TestRetryDelay(t *testing.T) {
+	delay := queue.RetryDelay(queue.Config{RetryBaseDelayInMillis: 100, RetryMaxDelayInMillis: 1000,
+		RetryJitterRatio: 0.5})
+	for n, bounds := range map[int][2]time.Duration{0: {50, 100}, 3: {400, 800}, 10: {500, 1000}, 100: {500, 1000}} {
+		for i := 0; i < 20; i++ {
+			d := delay(n, errors.New("sink down"), nil)
+			assert.GreaterOrEqual(t, d, bounds[0]*time.Millisecond, n)
+			assert.LessOrEqual(t, d, bounds[1]*time.Millisecond, n)
+		}
+	}
+	assert.Equal(t, 800*time.Millisecond, queue.RetryDelay(queue.Config{RetryBaseDelayInMillis: 100,
+		RetryMaxDelayInMillis: 1000})(3, nil, nil))
+
+	for _, invalid := range []queue.Config{
+		{RetryJitterRatio: 2},
+		{RetryBaseDelayInMillis: 5000, RetryMaxDelayInMillis: 1000},
+		{MaxRetryByType: map[string]int{"audit": -1}},
+	} {
+		invalid.RedisURL = "redis://127.0.0.1:6379/0"
+		assert.Error(t, queue.Init(invalid))
+	}
+	assert.False(t, queue.Enabled())
+}
@@ -0,0 +1,246 @@
+// Package ratelimit keeps every client to its share of the service, so that a single high volume client
+// cannot starve the others. Clients are told apart by their api key, or their ip address without one.
+// Each client has a token bucket refilled at its sustained rate up to its burst, and an optional daily cap,
+// either of its own or of the tier, the plan, it is assigned to.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+// Config is the configuration of the per client rate limits
+type Config struct {
+	// RequestsPerSecond is the rate each client is refilled at, clients are not limited when 0
+	RequestsPerSecond float64 `json:"requestsPerSecond" mapstructure:"requestsPerSecond"`
+	// Burst is the number of requests a client can make at once, the requests per second rounded up by default
+	Burst int `json:"burst" mapstructure:"burst"`
+	// RequestsPerDay is the number of requests each client can make per utc day, clients are not capped when 0
+	RequestsPerDay int64 `json:"requestsPerDay" mapstructure:"requestsPerDay"`
+	// Tiers are the plans the clients are assigned to by name, e.g. free and enterprise
+	Tiers map[string]Tier `json:"tiers" mapstructure:"tiers"`
+	// DefaultTier is the tier of the clients without a limit of their own, the limits above apply without it
+	DefaultTier string `json:"defaultTier" mapstructure:"defaultTier"`
+	// Clients are the limits of specific clients, by their ip or by key: and the sha256 of their api key
+	Clients map[string]ClientLimit `json:"clients" mapstructure:"clients"`
+	// IdleTimeoutInSeconds is how long the bucket of a client that stopped sending is kept
+	IdleTimeoutInSeconds int `json:"idleTimeoutInSeconds" mapstructure:"idleTimeoutInSeconds"`
+}
+
+// Tier is a plan of burst, sustained rate and daily cap
+type Tier struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond" mapstructure:"requestsPerSecond"`
+	Burst             int     `json:"burst" mapstructure:"burst"`
+	RequestsPerDay    int64   `json:"requestsPerDay" mapstructure:"requestsPerDay"`
+}
+
+// ClientLimit is the limit of a specific client, the limit of its tier when it is assigned one
+type ClientLimit struct {
+	Tier              string  `json:"tier" mapstructure:"tier"`
+	RequestsPerSecond float64 `json:"requestsPerSecond" mapstructure:"requestsPerSecond"`
+	Burst             int     `json:"burst" mapstructure:"burst"`
+	RequestsPerDay    int64   `json:"requestsPerDay" mapstructure:"requestsPerDay"`
+}
+
+// Usage is the consumption of a client against its limits
+type Usage struct {
+	// Limited is false for the clients that are not limited, the other fields are empty then
+	Limited           bool    `json:"limited"`
+	Tier              string  `json:"tier,omitempty"`
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	Burst             int     `json:"burst,omitempty"`
+	// Remaining is the number of requests the client can make right away
+	Remaining int `json:"remaining"`
+	// RefillInSeconds is the wait until the bucket of the client is full again
+	RefillInSeconds int   `json:"refillInSeconds"`
+	RequestsPerDay  int64 `json:"requestsPerDay,omitempty"`
+	UsedToday       int64 `json:"usedToday"`
+	// RemainingToday is the number of requests left of the daily cap, -1 without one
+	RemainingToday int64 `json:"remainingToday"`
+	// ResetInSeconds is the wait until the daily cap is reset, at midnight utc
+	ResetInSeconds int `json:"resetInSeconds"`
+}
+
+// bucket is the token bucket and the daily count of a client
+type bucket struct {
+	limit  ClientLimit
+	tokens float64
+	last   time.Time
+	day    string
+	used   int64
+	seen   time.Time
+}
+
+// Limiter is a token bucket per client
+type Limiter struct {
+	mu      sync.Mutex
+	config  Config
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+var l = New(Config{})
+
+// New is used to create a limiter for the config
+func New(config Config) *Limiter {
+	if config.IdleTimeoutInSeconds <= 0 {
+		config.IdleTimeoutInSeconds = constants.DefaultRateLimitIdleTimeoutInSeconds
+	}
+	return &Limiter{config: config, buckets: make(map[string]*bucket), swept: time.Now()}
+}
+
+// Validate is used to check that the tiers the clients are assigned to exist
+func (c Config) Validate() error {
+	if _, ok := c.Tiers[c.DefaultTier]; c.DefaultTier != "" && !ok {
+		return fmt.Errorf("default tier %s is not defined", c.DefaultTier)
+	}
+	for client, limit := range c.Clients {
+		if _, ok := c.Tiers[limit.Tier]; limit.Tier != "" && !ok {
+			return fmt.Errorf("tier %s of client %s is not defined", limit.Tier, client)
+		}
+	}
+	return nil
+}
+
+// Init is used to replace the default limiter
+func Init(config Config) {
+	l = New(config)
+}
+
+// Get is used to get the default limiter
+func Get() *Limiter {
+	return l
+}
+
+// limitOf is used to get the limit of the client, with the limits of its tier
+func (l *Limiter) limitOf(client string) ClientLimit {
+	limit, ok := l.config.Clients[client]
+	if !ok {
+		limit = ClientLimit{Tier: l.config.DefaultTier, RequestsPerSecond: l.config.RequestsPerSecond,
+			Burst: l.config.Burst, RequestsPerDay: l.config.RequestsPerDay}
+	}
+	if tier, ok := l.config.Tiers[limit.Tier]; ok && limit.Tier != "" {
+		limit.RequestsPerSecond, limit.Burst, limit.RequestsPerDay = tier.RequestsPerSecond, tier.Burst,
+			tier.RequestsPerDay
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = int(math.Ceil(limit.RequestsPerSecond))
+	}
+	return limit
+}
+
+// Allow is used to take a token from the bucket of the client, when there is none the wait until there is
+// one is returned
+func (l *Limiter) Allow(client string) (bool, time.Duration) {
+	allowed, wait, _ := l.Take(client, time.Now())
+	return allowed, wait
+}
+
+// Take is used to take a token from the bucket of the client and count the request against its daily cap,
+// along with the usage of the client after it. When the request is not allowed the wait until it would be
+// is returned.
+func (l *Limiter) Take(client string, now time.Time) (bool, time.Duration, Usage) {
+	limit := l.limitOf(client)
+	if limit.RequestsPerSecond <= 0 && limit.RequestsPerDay <= 0 {
+		return true, 0, Usage{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+	b := l.bucketOf(client, limit, now)
+	if limit.RequestsPerDay > 0 && b.used >= limit.RequestsPerDay {
+		return false, untilMidnight(now), b.usage(now)
+	}
+	if limit.RequestsPerSecond > 0 {
+		if b.tokens < 1 {
+			wait := time.Duration((1 - b.tokens) / limit.RequestsPerSecond * float64(time.Second))
+			return false, wait, b.usage(now)
+		}
+		b.tokens--
+	}
+	b.used++
+	return true, 0, b.usage(now)
+}
+
+// Usage is used to get the usage of the client without counting a request
+func (l *Limiter) Usage(client string, now time.Time) Usage {
+	limit := l.limitOf(client)
+	if limit.RequestsPerSecond <= 0 && limit.RequestsPerDay <= 0 {
+		return Usage{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.bucketOf(client, limit, now).usage(now)
+}
+
+// bucketOf is used to get the bucket of the client refilled up to the time, a full one for a new client
+func (l *Limiter) bucketOf(client string, limit ClientLimit, now time.Time) *bucket {
+	b, ok := l.buckets[client]
+	if !ok || b.limit != limit {
+		// a client whose limit changed keeps its daily count
+		next := &bucket{limit: limit, tokens: float64(limit.Burst), last: now, day: day(now)}
+		if ok {
+			next.used = b.used
+		}
+		b = next
+		l.buckets[client] = b
+	}
+	b.seen = now
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed.Seconds()*limit.RequestsPerSecond)
+		b.last = now
+	}
+	if today := day(now); b.day != today {
+		b.day, b.used = today, 0
+	}
+	return b
+}
+
+// usage is used to get the usage of the bucket
+func (b *bucket) usage(now time.Time) Usage {
+	u := Usage{Limited: true, Tier: b.limit.Tier, RequestsPerSecond: b.limit.RequestsPerSecond,
+		Burst: b.limit.Burst, RequestsPerDay: b.limit.RequestsPerDay, UsedToday: b.used, RemainingToday: -1,
+		ResetInSeconds: int(math.Ceil(untilMidnight(now).Seconds()))}
+	u.Remaining = math.MaxInt32
+	if b.limit.RequestsPerSecond > 0 {
+		u.Remaining = int(math.Floor(b.tokens))
+		u.RefillInSeconds = int(math.Ceil((float64(b.limit.Burst) - b.tokens) / b.limit.RequestsPerSecond))
+	}
+	if b.limit.RequestsPerDay > 0 {
+		u.RemainingToday = b.limit.RequestsPerDay - b.used
+		if u.RemainingToday < int64(u.Remaining) {
+			u.Remaining = int(u.RemainingToday)
+		}
+	}
+	return u
+}
+
+// sweep is used to drop the buckets of the idle clients, at most once per idle timeout. Buckets counting
+// against a daily cap are kept until their day is over.
+func (l *Limiter) sweep(now time.Time) {
+	idle := time.Duration(l.config.IdleTimeoutInSeconds) * time.Second
+	if now.Sub(l.swept) < idle {
+		return
+	}
+	l.swept = now
+	today := day(now)
+	for client, b := range l.buckets {
+		if now.Sub(b.seen) > idle && (b.limit.RequestsPerDay <= 0 || b.day != today) {
+			delete(l.buckets, client)
+		}
+	}
+}
+
+// day is used to get the utc day of the time
+func day(now time.Time) string {
+	return now.UTC().Format("2006-01-02")
+}
+
+// untilMidnight is used to get the wait until the next utc day
+func untilMidnight(now time.Time) time.Duration {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+}
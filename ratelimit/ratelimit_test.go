@@ -0,0 +1,70 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/ratelimit"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllow(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{RequestsPerSecond: 1, Burst: 2,
+		Clients: map[string]ratelimit.ClientLimit{"10.0.0.9": {RequestsPerSecond: 0}}})
+
+	allowed, _ := limiter.Allow("10.0.0.1")
+	assert.True(t, allowed)
+	allowed, _ = limiter.Allow("10.0.0.1")
+	assert.True(t, allowed)
+	allowed, wait := limiter.Allow("10.0.0.1")
+	assert.False(t, allowed)
+	assert.InDelta(t, time.Second, wait, float64(100*time.Millisecond))
+
+	// the buckets are per client
+	allowed, _ = limiter.Allow("10.0.0.2")
+	assert.True(t, allowed)
+	// clients can be exempted
+	for i := 0; i < 5; i++ {
+		allowed, _ = limiter.Allow("10.0.0.9")
+		assert.True(t, allowed)
+	}
+}
+
+func TestTiers(t *testing.T) {
+	config := ratelimit.Config{
+		Tiers: map[string]ratelimit.Tier{
+			"free":       {RequestsPerSecond: 1, Burst: 2, RequestsPerDay: 3},
+			"enterprise": {RequestsPerSecond: 100},
+		},
+		DefaultTier: "free",
+		Clients:     map[string]ratelimit.ClientLimit{"key:acme": {Tier: "enterprise"}},
+	}
+	assert.NoError(t, config.Validate())
+	limiter := ratelimit.New(config)
+
+	now := time.Date(2026, 10, 16, 23, 0, 0, 0, time.UTC)
+	allowed, _, usage := limiter.Take("10.0.0.1", now)
+	assert.True(t, allowed)
+	assert.Equal(t, ratelimit.Usage{Limited: true, Tier: "free", RequestsPerSecond: 1, Burst: 2, Remaining: 1,
+		RefillInSeconds: 1, RequestsPerDay: 3, UsedToday: 1, RemainingToday: 2, ResetInSeconds: 3600}, usage)
+	allowed, _, _ = limiter.Take("10.0.0.1", now)
+	assert.True(t, allowed)
+	// the burst is used up, the bucket refills at the sustained rate
+	allowed, wait, _ := limiter.Take("10.0.0.1", now)
+	assert.False(t, allowed)
+	assert.Equal(t, time.Second, wait)
+	allowed, _, _ = limiter.Take("10.0.0.1", now.Add(time.Second))
+	assert.True(t, allowed)
+	// the daily cap is used up until midnight
+	allowed, wait, _ = limiter.Take("10.0.0.1", now.Add(time.Minute))
+	assert.False(t, allowed)
+	assert.Equal(t, 59*time.Minute, wait)
+	allowed, _, _ = limiter.Take("10.0.0.1", now.Add(time.Hour))
+	assert.True(t, allowed)
+
+	assert.Equal(t, "enterprise", limiter.Usage("key:acme", now).Tier)
+	assert.Equal(t, int64(0), limiter.Usage("key:acme", now).UsedToday)
+
+	config.Clients["key:other"] = ratelimit.ClientLimit{Tier: "gold"}
+	assert.Error(t, config.Validate())
+}
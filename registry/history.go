@@ -0,0 +1,231 @@
+package registry
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+// Change is a single applied change of an admin resource
+type Change struct {
+	Revision int64           `json:"revision"`
+	Action   string          `json:"action"`
+	Kind     string          `json:"kind"`
+	ID       string          `json:"id"`
+	Version  int64           `json:"version"`
+	Spec     json.RawMessage `json:"spec,omitempty"`
+	Author   string          `json:"author,omitempty"`
+	Time     time.Time       `json:"time"`
+}
+
+// HistoryQuery is the set of filters applied when reading the change history
+type HistoryQuery struct {
+	Kind string
+	ID   string
+	// Before only returns changes with a smaller revision, 0 means from the latest
+	Before int64
+	Limit  int
+}
+
+// Journal persists the changes so that the resources survive restarts
+type Journal interface {
+	// Append is used to durably record the changes before they are applied
+	Append(changes []Change) error
+	// Load is used to read all the recorded changes in order
+	Load() ([]Change, error)
+}
+
+// commit is used to journal and apply the changes, the lock has to be held
+func (r *Registry) commit(changes []Change) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	for i := range changes {
+		changes[i].Revision = r.revision + int64(i) + 1
+		changes[i].Time = now
+	}
+	if r.journal != nil {
+		if err := r.journal.Append(changes); err != nil {
+			return fmt.Errorf("error recording change : %w", err)
+		}
+	}
+	for _, change := range changes {
+		r.replay(change)
+	}
+	return nil
+}
+
+// replay is used to apply a recorded change to the in memory state, the lock has to be held
+func (r *Registry) replay(change Change) {
+	resources, ok := r.resources[change.Kind]
+	if !ok {
+		resources = make(map[string]Resource)
+		r.resources[change.Kind] = resources
+	}
+	if change.Action == constants.ChangeActionDelete {
+		delete(resources, change.ID)
+	} else {
+		resources[change.ID] = Resource{
+			Kind:      change.Kind,
+			ID:        change.ID,
+			Version:   change.Version,
+			Spec:      change.Spec,
+			UpdatedAt: change.Time,
+			UpdatedBy: change.Author,
+		}
+	}
+	r.revision = change.Revision
+	r.history = append(r.history, change)
+}
+
+// SetJournal is used to persist all further changes to the journal after restoring the recorded ones
+func (r *Registry) SetJournal(journal Journal) error {
+	changes, err := journal.Load()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	for _, change := range changes {
+		r.replay(change)
+	}
+	r.journal = journal
+	var listeners []func()
+	for _, kindListeners := range r.listeners {
+		listeners = append(listeners, kindListeners...)
+	}
+	r.mu.Unlock()
+
+	if len(changes) > 0 {
+		notify(listeners)
+	}
+	return nil
+}
+
+// Revision is used to get the revision of the latest change
+func (r *Registry) Revision() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.revision
+}
+
+// History is used to get the matching changes, newest first
+func (r *Registry) History(query HistoryQuery) []Change {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []Change
+	for i := len(r.history) - 1; i >= 0; i-- {
+		change := r.history[i]
+		if query.Before > 0 && change.Revision >= query.Before {
+			continue
+		}
+		if (query.Kind != "" && change.Kind != query.Kind) || (query.ID != "" && change.ID != query.ID) {
+			continue
+		}
+		result = append(result, change)
+		if query.Limit > 0 && len(result) == query.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// Rollback is used to restore all the resources to their state right after the revision.
+// The rollback is applied as new changes, so it shows up in the history and can itself be rolled back.
+func (r *Registry) Rollback(revision int64, author string) (int, error) {
+	r.mu.RLock()
+	if revision < 0 || revision > r.revision {
+		r.mu.RUnlock()
+		return 0, fmt.Errorf("%w: revision %d", ErrNotFound, revision)
+	}
+	state := make(map[string]map[string]Change)
+	for name := range r.kinds {
+		state[name] = make(map[string]Change)
+	}
+	for _, change := range r.history {
+		if change.Revision > revision {
+			break
+		}
+		if _, ok := state[change.Kind]; !ok {
+			continue
+		}
+		if change.Action == constants.ChangeActionDelete {
+			delete(state[change.Kind], change.ID)
+		} else {
+			state[change.Kind][change.ID] = change
+		}
+	}
+	r.mu.RUnlock()
+
+	desired := make(map[string][]Resource, len(state))
+	for name, changes := range state {
+		resources := make([]Resource, 0, len(changes))
+		for _, change := range changes {
+			resources = append(resources, Resource{Kind: name, ID: change.ID, Spec: change.Spec})
+		}
+		sort.Slice(resources, func(i, j int) bool { return resources[i].ID < resources[j].ID })
+		desired[name] = resources
+	}
+	return r.Apply(desired, fmt.Sprintf("%s (rollback to %d)", author, revision))
+}
+
+// FileJournal is a journal appending one json change per line to a local file
+type FileJournal struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileJournal is used to create a journal backed by the file at the path
+func NewFileJournal(path string) *FileJournal {
+	return &FileJournal{path: path}
+}
+
+// Append is used to write the changes and sync them to disk
+func (j *FileJournal) Append(changes []Change) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for _, change := range changes {
+		if err = encoder.Encode(change); err != nil {
+			return err
+		}
+	}
+	return file.Sync()
+}
+
+// Load is used to read all the changes in the file, a missing file means no changes
+func (j *FileJournal) Load() ([]Change, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	file, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var changes []Change
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), constants.MaxJournalLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		var change Change
+		if err = json.Unmarshal(scanner.Bytes(), &change); err != nil {
+			return nil, fmt.Errorf("journal %s line %d : %w", j.path, line, err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, scanner.Err()
+}
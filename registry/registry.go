@@ -0,0 +1,509 @@
+package registry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+var (
+	// ErrNotFound is returned when the resource does not exist
+	ErrNotFound = errors.New(constants.ResourceNotFoundError)
+	// ErrPreconditionFailed is returned when the resource changed since the caller last read it
+	ErrPreconditionFailed = errors.New(constants.PreconditionFailedError)
+	// ErrUnknownKind is returned for kinds that were never registered
+	ErrUnknownKind = errors.New(constants.UnknownResourceKindError)
+	// ErrPreconditionRequired is returned when an existing resource is changed without stating its version
+	ErrPreconditionRequired = errors.New(constants.PreconditionRequiredError)
+)
+
+// Precondition is the version of the resource the caller based its change on.
+// Changes to existing resources have to carry either the ETag or the version they were based on,
+// so that concurrent edits are rejected instead of silently overwriting each other.
+type Precondition struct {
+	// IfMatch is the ETag of the current resource, * matches any existing resource
+	IfMatch string
+	// IfNoneMatch set to * only allows creating the resource
+	IfNoneMatch string
+	// Version is the current version of the resource
+	Version int64
+	// Force skips the checks, it is meant for internal callers that own the resource
+	Force bool
+}
+
+// Resource is a single versioned admin resource such as a schema or a rule set
+type Resource struct {
+	Kind      string          `json:"kind"`
+	ID        string          `json:"id"`
+	Version   int64           `json:"version"`
+	Spec      json.RawMessage `json:"spec"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+	UpdatedBy string          `json:"updatedBy,omitempty"`
+}
+
+// ETag is used to get the entity tag identifying this version of the resource
+func (r Resource) ETag() string {
+	sum := sha256.Sum256(r.Spec)
+	return fmt.Sprintf(`"%d-%s"`, r.Version, hex.EncodeToString(sum[:8]))
+}
+
+// Kind describes a type of admin resource
+type Kind struct {
+	Name string
+	// Validate is used to reject invalid specs before they are applied
+	Validate func(id string, spec json.RawMessage) error
+	// ValidateAll is used to check all the resources of the kind as they would be after a change
+	ValidateAll func(resources []Resource) error
+	// Normalize is used to rewrite a spec before it is validated and stored, e.g. to hash secrets
+	Normalize func(id string, spec json.RawMessage) (json.RawMessage, error)
+}
+
+// normalize is used to get the canonical form of the spec, so that resending the same spec is not a change
+func (k Kind) normalize(id string, spec json.RawMessage) (json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(spec))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("%s %s : invalid json : %w", k.Name, id, err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("%s %s : invalid json : trailing data", k.Name, id)
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if k.Normalize == nil {
+		return canonical, nil
+	}
+	if canonical, err = k.Normalize(id, canonical); err != nil {
+		return nil, fmt.Errorf("%s %s : %w", k.Name, id, err)
+	}
+	return canonical, nil
+}
+
+// Registry holds the admin resources and notifies listeners when they change
+type Registry struct {
+	mu        sync.RWMutex
+	kinds     map[string]Kind
+	resources map[string]map[string]Resource
+	listeners map[string][]func()
+	revision  int64
+	history   []Change
+	journal   Journal
+}
+
+var r = New()
+
+// New is used to create an empty registry
+func New() *Registry {
+	return &Registry{
+		kinds:     make(map[string]Kind),
+		resources: make(map[string]map[string]Resource),
+		listeners: make(map[string][]func()),
+	}
+}
+
+// Get is used to get the default registry
+func Get() *Registry {
+	return r
+}
+
+// RegisterKind is used to add a kind of resource to the registry
+func (r *Registry) RegisterKind(kind Kind) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.kinds[kind.Name] = kind
+	if _, ok := r.resources[kind.Name]; !ok {
+		r.resources[kind.Name] = make(map[string]Resource)
+	}
+}
+
+// Kinds is used to get the names of the registered kinds
+func (r *Registry) Kinds() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	kinds := make([]string, 0, len(r.kinds))
+	for name := range r.kinds {
+		kinds = append(kinds, name)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// OnChange is used to register a listener called after any resource of the kind changes
+func (r *Registry) OnChange(kind string, listener func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.listeners[kind] = append(r.listeners[kind], listener)
+}
+
+// Validate is used to check the spec against the kind without applying it
+func (r *Registry) Validate(kind, id string, spec json.RawMessage) error {
+	r.mu.RLock()
+	k, ok := r.kinds[kind]
+	var candidate []Resource
+	if ok && k.ValidateAll != nil {
+		candidate = append(candidate, Resource{Kind: kind, ID: id, Spec: spec})
+		for _, resource := range r.resources[kind] {
+			if resource.ID != id {
+				candidate = append(candidate, resource)
+			}
+		}
+	}
+	r.mu.RUnlock()
+	if !ok {
+		return ErrUnknownKind
+	}
+	return k.validate(candidate, Resource{Kind: kind, ID: id, Spec: spec})
+}
+
+// validate is used to run the single resource validation on every changed resource and then the kind validation
+func (k Kind) validate(all []Resource, changed ...Resource) error {
+	if k.Validate != nil {
+		for _, resource := range changed {
+			if err := k.Validate(resource.ID, resource.Spec); err != nil {
+				return fmt.Errorf("%s %s : %w", k.Name, resource.ID, err)
+			}
+		}
+	}
+	if k.ValidateAll != nil {
+		sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+		return k.ValidateAll(all)
+	}
+	return nil
+}
+
+// Get is used to get the resource of the kind by id
+func (r *Registry) Get(kind, id string) (Resource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	resources, ok := r.resources[kind]
+	if !ok {
+		return Resource{}, ErrUnknownKind
+	}
+	resource, ok := resources[id]
+	if !ok {
+		return Resource{}, ErrNotFound
+	}
+	return resource, nil
+}
+
+// List is used to get all the resources of the kind ordered by id
+func (r *Registry) List(kind string) ([]Resource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	resources, ok := r.resources[kind]
+	if !ok {
+		return nil, ErrUnknownKind
+	}
+	list := make([]Resource, 0, len(resources))
+	for _, resource := range resources {
+		list = append(list, resource)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// ListPage is used to get a page of the resources of the kind ordered by id, starting after the given id.
+// The returned next id is empty on the last page.
+func (r *Registry) ListPage(kind, after string, limit int) ([]Resource, string, error) {
+	list, err := r.List(kind)
+	if err != nil {
+		return nil, "", err
+	}
+	start := sort.Search(len(list), func(i int) bool { return list[i].ID > after })
+	list = list[start:]
+	if limit <= 0 || len(list) <= limit {
+		return list, "", nil
+	}
+	return list[:limit], list[limit-1].ID, nil
+}
+
+// Put is used to create or update the resource.
+// Updates fail with ErrPreconditionRequired without a precondition and ErrPreconditionFailed on a stale one.
+// Putting the spec the resource already has is a no-op that returns the current version, so retries
+// and repeated applies are safe without a precondition.
+func (r *Registry) Put(kind, id string, spec json.RawMessage, precondition Precondition,
+	author string) (Resource, error) {
+	r.mu.RLock()
+	k, ok := r.kinds[kind]
+	r.mu.RUnlock()
+	if !ok {
+		return Resource{}, ErrUnknownKind
+	}
+	spec, err := k.normalize(id, spec)
+	if err != nil {
+		return Resource{}, err
+	}
+	if err = r.Validate(kind, id, spec); err != nil {
+		return Resource{}, err
+	}
+	r.mu.Lock()
+	current, exists := r.resources[kind][id]
+	if exists && bytes.Equal(current.Spec, spec) {
+		r.mu.Unlock()
+		return current, nil
+	}
+	if err = precondition.check(current, exists); err != nil {
+		r.mu.Unlock()
+		return Resource{}, err
+	}
+	action := constants.ChangeActionUpdate
+	if !exists {
+		action = constants.ChangeActionCreate
+	}
+	err = r.commit([]Change{{
+		Action:  action,
+		Kind:    kind,
+		ID:      id,
+		Version: current.Version + 1,
+		Spec:    spec,
+		Author:  author,
+	}})
+	resource := r.resources[kind][id]
+	listeners := r.listeners[kind]
+	r.mu.Unlock()
+	if err != nil {
+		return Resource{}, err
+	}
+
+	notify(listeners)
+	return resource, nil
+}
+
+// Create is used to create several new resources in one step, e.g. a tenant together with its keys.
+// Nothing is created if any resource is invalid or already exists.
+func (r *Registry) Create(resources []Resource, author string) ([]Resource, error) {
+	r.mu.RLock()
+	kinds := make(map[string]Kind)
+	for _, resource := range resources {
+		kind, ok := r.kinds[resource.Kind]
+		if !ok {
+			r.mu.RUnlock()
+			return nil, fmt.Errorf("%w %s", ErrUnknownKind, resource.Kind)
+		}
+		kinds[resource.Kind] = kind
+	}
+	r.mu.RUnlock()
+	created := make(map[string][]Resource)
+	for i := range resources {
+		spec, err := kinds[resources[i].Kind].normalize(resources[i].ID, resources[i].Spec)
+		if err != nil {
+			return nil, err
+		}
+		resources[i].Spec = spec
+		created[resources[i].Kind] = append(created[resources[i].Kind], resources[i])
+	}
+
+	r.mu.Lock()
+	changes := make([]Change, 0, len(resources))
+	var listeners []func()
+	for name, kindResources := range created {
+		all := append([]Resource(nil), kindResources...)
+		for _, existing := range r.resources[name] {
+			all = append(all, existing)
+		}
+		if err := kinds[name].validate(all, kindResources...); err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+		listeners = append(listeners, r.listeners[name]...)
+	}
+	seen := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		_, exists := r.resources[resource.Kind][resource.ID]
+		if exists || seen[resource.Kind+"/"+resource.ID] {
+			r.mu.Unlock()
+			return nil, fmt.Errorf("%w: %s %s already exists", ErrPreconditionFailed, resource.Kind, resource.ID)
+		}
+		seen[resource.Kind+"/"+resource.ID] = true
+		changes = append(changes, Change{
+			Action:  constants.ChangeActionCreate,
+			Kind:    resource.Kind,
+			ID:      resource.ID,
+			Version: 1,
+			Spec:    resource.Spec,
+			Author:  author,
+		})
+	}
+	err := r.commit(changes)
+	result := make([]Resource, 0, len(resources))
+	for _, resource := range resources {
+		result = append(result, r.resources[resource.Kind][resource.ID])
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	notify(listeners)
+	return result, nil
+}
+
+// Delete is used to remove the resource, the precondition behaves as in Put
+func (r *Registry) Delete(kind, id string, precondition Precondition, author string) error {
+	r.mu.Lock()
+	resources, ok := r.resources[kind]
+	if !ok {
+		r.mu.Unlock()
+		return ErrUnknownKind
+	}
+	current, exists := resources[id]
+	if !exists {
+		r.mu.Unlock()
+		return ErrNotFound
+	}
+	if err := precondition.check(current, exists); err != nil {
+		r.mu.Unlock()
+		return err
+	}
+	err := r.commit([]Change{{
+		Action:  constants.ChangeActionDelete,
+		Kind:    kind,
+		ID:      id,
+		Version: current.Version,
+		Author:  author,
+	}})
+	listeners := r.listeners[kind]
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	notify(listeners)
+	return nil
+}
+
+func (p Precondition) check(current Resource, exists bool) error {
+	switch {
+	case p.Force:
+		return nil
+	case p.IfNoneMatch == "*" && exists:
+		return ErrPreconditionFailed
+	case p.IfMatch == "*":
+		if !exists {
+			return ErrPreconditionFailed
+		}
+	case p.IfMatch != "":
+		if !exists || p.IfMatch != current.ETag() {
+			return ErrPreconditionFailed
+		}
+	case p.Version != 0:
+		if !exists || p.Version != current.Version {
+			return ErrPreconditionFailed
+		}
+	case exists:
+		return ErrPreconditionRequired
+	}
+	return nil
+}
+
+// Apply is used to declaratively replace all the resources of the given kinds in one step.
+// Everything is validated first and nothing is applied if any resource is invalid. Resources with
+// an unchanged spec keep their version and resources missing from the desired state are deleted.
+// It returns the number of created, updated or deleted resources.
+func (r *Registry) Apply(desired map[string][]Resource, author string) (int, error) {
+	r.mu.RLock()
+	kinds := make(map[string]Kind, len(desired))
+	for name := range desired {
+		kind, ok := r.kinds[name]
+		if !ok {
+			r.mu.RUnlock()
+			return 0, fmt.Errorf("%w %s", ErrUnknownKind, name)
+		}
+		kinds[name] = kind
+	}
+	r.mu.RUnlock()
+	normalized := make(map[string][]Resource, len(desired))
+	for name, resources := range desired {
+		normalized[name] = make([]Resource, 0, len(resources))
+		for _, resource := range resources {
+			spec, err := kinds[name].normalize(resource.ID, resource.Spec)
+			if err != nil {
+				return 0, err
+			}
+			resource.Spec = spec
+			normalized[name] = append(normalized[name], resource)
+		}
+	}
+	desired = normalized
+	for name, resources := range desired {
+		if err := kinds[name].validate(append([]Resource(nil), resources...), resources...); err != nil {
+			return 0, err
+		}
+	}
+
+	r.mu.Lock()
+	var changes []Change
+	var listeners []func()
+	for name, resources := range desired {
+		current := r.resources[name]
+		start := len(changes)
+		wanted := make(map[string]bool, len(resources))
+		for _, resource := range resources {
+			wanted[resource.ID] = true
+			existing, exists := current[resource.ID]
+			if exists && bytes.Equal(existing.Spec, resource.Spec) {
+				continue
+			}
+			action := constants.ChangeActionUpdate
+			if !exists {
+				action = constants.ChangeActionCreate
+			}
+			changes = append(changes, Change{
+				Action:  action,
+				Kind:    name,
+				ID:      resource.ID,
+				Version: existing.Version + 1,
+				Spec:    resource.Spec,
+				Author:  author,
+			})
+		}
+		for id, existing := range current {
+			if !wanted[id] {
+				changes = append(changes, Change{
+					Action:  constants.ChangeActionDelete,
+					Kind:    name,
+					ID:      id,
+					Version: existing.Version,
+					Author:  author,
+				})
+			}
+		}
+		if len(changes) > start {
+			listeners = append(listeners, r.listeners[name]...)
+		}
+	}
+	err := r.commit(changes)
+	r.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	notify(listeners)
+	return len(changes), nil
+}
+
+func notify(listeners []func()) {
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// ValidateObject is a validator accepting any json object spec
+func ValidateObject(_ string, spec json.RawMessage) error {
+	var object map[string]interface{}
+	if err := json.Unmarshal(spec, &object); err != nil || object == nil {
+		return fmt.Errorf("spec has to be a json object")
+	}
+	return nil
+}
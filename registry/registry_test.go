@@ -0,0 +1,82 @@
+package registry_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimisticConcurrency(t *testing.T) {
+	r := registry.New()
+	r.RegisterKind(registry.Kind{Name: "schemas", Validate: registry.ValidateObject})
+
+	created, err := r.Put("schemas", "order", json.RawMessage(`{"a":1}`), registry.Precondition{}, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), created.Version)
+
+	_, err = r.Put("schemas", "order", json.RawMessage(`{"a":2}`), registry.Precondition{}, "bob")
+	assert.ErrorIs(t, err, registry.ErrPreconditionRequired)
+
+	updated, err := r.Put("schemas", "order", json.RawMessage(`{"a":2}`),
+		registry.Precondition{IfMatch: created.ETag()}, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), updated.Version)
+
+	// alice is still editing the first version
+	_, err = r.Put("schemas", "order", json.RawMessage(`{"a":3}`), registry.Precondition{Version: 1}, "alice")
+	assert.ErrorIs(t, err, registry.ErrPreconditionFailed)
+
+	_, err = r.Put("schemas", "order", json.RawMessage(`{"a":3}`), registry.Precondition{IfNoneMatch: "*"}, "alice")
+	assert.ErrorIs(t, err, registry.ErrPreconditionFailed)
+
+	assert.NoError(t, r.Delete("schemas", "order", registry.Precondition{Version: 2}, "bob"))
+}
+
+func TestRollback(t *testing.T) {
+	r := registry.New()
+	r.RegisterKind(registry.Kind{Name: "types"})
+
+	_, _ = r.Put("types", "a", json.RawMessage(`{"v":1}`), registry.Precondition{}, "alice")
+	_, _ = r.Put("types", "a", json.RawMessage(`{"v":2}`), registry.Precondition{Version: 1}, "alice")
+	_, _ = r.Put("types", "b", json.RawMessage(`{"v":1}`), registry.Precondition{}, "alice")
+
+	changes, err := r.Rollback(1, "bob")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, changes)
+
+	resource, err := r.Get("types", "a")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"v":1}`, string(resource.Spec))
+	_, err = r.Get("types", "b")
+	assert.ErrorIs(t, err, registry.ErrNotFound)
+	assert.Len(t, r.History(registry.HistoryQuery{}), 5)
+}
+
+func TestIdempotentPut(t *testing.T) {
+	r := registry.New()
+	r.RegisterKind(registry.Kind{Name: "schemas", Validate: registry.ValidateObject})
+
+	created, err := r.Put("schemas", "order", json.RawMessage(`{"b":1,"a":2}`), registry.Precondition{}, "alice")
+	assert.NoError(t, err)
+
+	// the same spec formatted differently is not a change and needs no precondition
+	again, err := r.Put("schemas", "order", json.RawMessage(`{ "a": 2, "b": 1 }`), registry.Precondition{}, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, created.Version, again.Version)
+	assert.Equal(t, created.ETag(), again.ETag())
+	assert.Equal(t, int64(1), r.Revision())
+
+	for _, id := range []string{"payment", "refund"} {
+		_, _ = r.Put("schemas", id, json.RawMessage(`{}`), registry.Precondition{}, "alice")
+	}
+	page, next, err := r.ListPage("schemas", "", 2)
+	assert.NoError(t, err)
+	assert.Len(t, page, 2)
+	assert.Equal(t, "payment", next)
+	page, next, err = r.ListPage("schemas", next, 2)
+	assert.NoError(t, err)
+	assert.Len(t, page, 1)
+	assert.Empty(t, next)
+}
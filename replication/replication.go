@@ -0,0 +1,303 @@
+// Package replication keeps a warm standby of the entries an instance has not delivered yet in the object store
+// of a secondary region. Every instance periodically uploads a snapshot of its pending write ahead log entries
+// and of the dead letters, and when an instance or its region is lost, an instance of another region promotes
+// the snapshot of the lost one through the admin api, delivering its entries and adopting its dead letters.
+// Delivery is at least once, the entries delivered by the lost instance after its last snapshot are delivered
+// again.
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/dlq"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/objectstore"
+	"github.com/angel-one/nbu-logger-service/utils/sigv4"
+	"github.com/angel-one/nbu-logger-service/wal"
+)
+
+var (
+	// ErrDisabled is returned when replication is used without a bucket configured
+	ErrDisabled = errors.New(constants.ReplicationDisabledError)
+	// ErrPromoted is returned when the snapshot of the instance was already promoted
+	ErrPromoted = errors.New(constants.AlreadyPromotedError)
+	// ErrOwnSnapshot is returned when an instance is asked to promote its own snapshot
+	ErrOwnSnapshot = errors.New(constants.OwnSnapshotError)
+	// ErrNotFound is returned when the object store has no snapshot of the instance
+	ErrNotFound = objectstore.ErrNotFound
+)
+
+// Config is the configuration of the replication to the secondary region
+type Config struct {
+	// Endpoint is the url of the s3 compatible api, the one of the region when empty
+	Endpoint string `json:"endpoint" mapstructure:"endpoint"`
+	// Region is the secondary region the snapshots are kept in
+	Region string `json:"region" mapstructure:"region"`
+	// Bucket is the bucket the snapshots are kept in, replication is disabled when empty
+	Bucket string `json:"bucket" mapstructure:"bucket"`
+	// Prefix is the prefix of the keys of the snapshots
+	Prefix string `json:"prefix" mapstructure:"prefix"`
+	// Instance identifies the snapshot of the process, the hostname when empty. It has to be stable across
+	// restarts and unique across the regions.
+	Instance string `json:"instance" mapstructure:"instance"`
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials, the ones of the aws environment variables
+	// when empty
+	AccessKeyID     string `json:"-" mapstructure:"accessKeyId"`
+	SecretAccessKey string `json:"-" mapstructure:"secretAccessKey"`
+	SessionToken    string `json:"-" mapstructure:"sessionToken"`
+	// IntervalInSeconds is the interval between snapshots
+	IntervalInSeconds int `json:"intervalInSeconds" mapstructure:"intervalInSeconds"`
+	// MaxEntries is the maximum number of pending entries in a snapshot, the oldest are kept
+	MaxEntries int `json:"maxEntries" mapstructure:"maxEntries"`
+	// TimeoutInMillis is the time to wait for a request to the object store
+	TimeoutInMillis int `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+}
+
+// Snapshot is what an instance had not delivered yet when it was taken
+type Snapshot struct {
+	Instance    string            `json:"instance"`
+	TakenAt     time.Time         `json:"takenAt"`
+	Entries     []models.LogEntry `json:"entries"`
+	DeadLetters []dlq.Letter      `json:"deadLetters"`
+}
+
+// Promotion records the promotion of the snapshot of an instance, so that it is only promoted once
+type Promotion struct {
+	Instance        string    `json:"instance"`
+	SnapshotTakenAt time.Time `json:"snapshotTakenAt"`
+	PromotedAt      time.Time `json:"promotedAt"`
+	PromotedBy      string    `json:"promotedBy"`
+	Entries         int       `json:"entries"`
+	DeadLetters     int       `json:"deadLetters"`
+}
+
+// Status is the outcome of the latest snapshot of the process
+type Status struct {
+	Enabled          bool      `json:"enabled"`
+	Instance         string    `json:"instance,omitempty"`
+	LastReplicatedAt time.Time `json:"lastReplicatedAt,omitempty"`
+	Entries          int       `json:"entries"`
+	DeadLetters      int       `json:"deadLetters"`
+	LastError        string    `json:"lastError,omitempty"`
+}
+
+// Replicator uploads the snapshots of the process and promotes the ones of other instances
+type Replicator struct {
+	config  Config
+	store   *objectstore.S3
+	handler wal.Handler
+	mu      sync.Mutex
+	status  Status
+	// promoting serializes the promotions so that a snapshot is not promoted twice by concurrent requests
+	promoting sync.Mutex
+}
+
+var r = &Replicator{}
+
+// Init is used to initialize the default replicator, the promoted entries are delivered with the handler
+func Init(config Config, handler wal.Handler) error {
+	replicator, err := New(config, handler)
+	if err != nil {
+		return err
+	}
+	r = replicator
+	return nil
+}
+
+// Get is used to get the default replicator
+func Get() *Replicator {
+	return r
+}
+
+// New is used to create a replicator for the config, the promoted entries are delivered with the handler
+func New(config Config, handler wal.Handler) (*Replicator, error) {
+	if config.Bucket == "" {
+		return &Replicator{config: config}, nil
+	}
+	if config.Region == "" {
+		return nil, fmt.Errorf("replication region is required")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf(constants.DefaultObjectStoreEndpoint, config.Region)
+	}
+	if config.Prefix == "" {
+		config.Prefix = constants.DefaultReplicationPrefix
+	}
+	if config.Instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("error getting replication instance : %w", err)
+		}
+		config.Instance = hostname
+	}
+	if config.IntervalInSeconds <= 0 {
+		config.IntervalInSeconds = constants.DefaultReplicationIntervalInSeconds
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = constants.DefaultReplicationMaxEntries
+	}
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultSinkTimeoutInMillis
+	}
+	credentials := sigv4.Credentials{AccessKeyID: config.AccessKeyID, SecretAccessKey: config.SecretAccessKey,
+		SessionToken: config.SessionToken}
+	if credentials.AccessKeyID == "" {
+		credentials = sigv4.FromEnv()
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("replication has no aws credentials")
+	}
+	return &Replicator{
+		config: config,
+		store: objectstore.NewS3(config.Endpoint, config.Bucket, config.Region, credentials,
+			time.Duration(config.TimeoutInMillis)*time.Millisecond),
+		handler: handler,
+		status:  Status{Enabled: true, Instance: config.Instance},
+	}, nil
+}
+
+// Enabled is used to check whether a bucket is configured
+func (r *Replicator) Enabled() bool {
+	return r.store != nil
+}
+
+// Status is used to get the outcome of the latest snapshot
+func (r *Replicator) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Start is used to replicate in the background on every interval until the context is done
+func (r *Replicator) Start(ctx context.Context) {
+	if !r.Enabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(r.config.IntervalInSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			if err := r.Replicate(ctx); err != nil {
+				log.Error(ctx).Err(err).Msg("error replicating undelivered entries")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Replicate is used to upload the snapshot of the entries the process has not delivered yet
+func (r *Replicator) Replicate(ctx context.Context) error {
+	if !r.Enabled() {
+		return ErrDisabled
+	}
+	err := r.replicate(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.status.LastError = err.Error()
+		return err
+	}
+	r.status.LastError = ""
+	return nil
+}
+
+func (r *Replicator) replicate(ctx context.Context) error {
+	entries, err := wal.Pending(r.config.MaxEntries)
+	if err != nil {
+		return fmt.Errorf("error reading pending wal entries : %w", err)
+	}
+	letters, err := dlq.Get().List(ctx, dlq.Filter{})
+	if err != nil {
+		return fmt.Errorf("error listing dead letters : %w", err)
+	}
+	snapshot := Snapshot{Instance: r.config.Instance, TakenAt: time.Now().UTC(), Entries: entries,
+		DeadLetters: letters}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if err = r.store.Put(r.key(r.config.Instance, constants.ReplicationSnapshotObject), data); err != nil {
+		return fmt.Errorf("error uploading snapshot : %w", err)
+	}
+	r.mu.Lock()
+	r.status.LastReplicatedAt = snapshot.TakenAt
+	r.status.Entries = len(entries)
+	r.status.DeadLetters = len(letters)
+	r.mu.Unlock()
+	return nil
+}
+
+// Promote is used to take over the latest snapshot of the lost instance: its entries are delivered, the ones
+// failing to be are dead lettered, and its dead letters are added to the ones of the process. A snapshot is only
+// promoted once, the promotion is recorded next to it.
+func (r *Replicator) Promote(ctx context.Context, instance string) (Promotion, error) {
+	if !r.Enabled() {
+		return Promotion{}, ErrDisabled
+	}
+	if instance == r.config.Instance {
+		return Promotion{}, ErrOwnSnapshot
+	}
+	r.promoting.Lock()
+	defer r.promoting.Unlock()
+
+	marker := r.key(instance, constants.ReplicationPromotionObject)
+	if _, err := r.store.Get(marker); err == nil {
+		return Promotion{}, ErrPromoted
+	} else if !errors.Is(err, ErrNotFound) {
+		return Promotion{}, fmt.Errorf("error reading promotion : %w", err)
+	}
+	data, err := r.store.Get(r.key(instance, constants.ReplicationSnapshotObject))
+	if err != nil {
+		return Promotion{}, fmt.Errorf("error reading snapshot : %w", err)
+	}
+	var snapshot Snapshot
+	if err = json.Unmarshal(data, &snapshot); err != nil {
+		return Promotion{}, fmt.Errorf("invalid snapshot : %w", err)
+	}
+
+	for _, entry := range snapshot.Entries {
+		if err = r.handler(ctx, entry); err != nil {
+			dlq.Add(ctx, constants.ReplicationConfig, entry, err)
+		}
+	}
+	for _, letter := range snapshot.DeadLetters {
+		if err = dlq.Get().Add(ctx, letter); err != nil {
+			return Promotion{}, fmt.Errorf("error adding dead letter %s : %w", letter.ID, err)
+		}
+	}
+	promotion := Promotion{
+		Instance:        instance,
+		SnapshotTakenAt: snapshot.TakenAt,
+		PromotedAt:      time.Now().UTC(),
+		PromotedBy:      r.config.Instance,
+		Entries:         len(snapshot.Entries),
+		DeadLetters:     len(snapshot.DeadLetters),
+	}
+	if data, err = json.Marshal(promotion); err != nil {
+		return Promotion{}, err
+	}
+	if err = r.store.Put(marker, data); err != nil {
+		return Promotion{}, fmt.Errorf("error recording promotion : %w", err)
+	}
+	log.Info(ctx).Str(constants.InstanceKey, instance).Int("entries", promotion.Entries).
+		Int("deadLetters", promotion.DeadLetters).Msg("promoted replicated snapshot")
+	return promotion, nil
+}
+
+// key is used to get the key of the object of the instance
+func (r *Replicator) key(instance, object string) string {
+	return path.Join(r.config.Prefix, instance, object)
+}
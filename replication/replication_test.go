@@ -0,0 +1,140 @@
+package replication_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/dlq"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/replication"
+	"github.com/angel-one/nbu-logger-service/wal"
+	"github.com/stretchr/testify/assert"
+)
+
+// bucket is an s3 api keeping the objects in memory
+type bucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *bucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") ||
+		r.Header.Get("X-Amz-Content-Sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		b.objects[r.URL.Path], _ = io.ReadAll(r.Body)
+	case http.MethodGet:
+		object, ok := b.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(object)
+	}
+}
+
+func (b *bucket) has(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.objects[key]
+	return ok
+}
+
+func TestPromote(t *testing.T) {
+	ctx := context.Background()
+	store := &bucket{objects: make(map[string][]byte)}
+	server := httptest.NewServer(store)
+	defer server.Close()
+	config := func(instance string) replication.Config {
+		return replication.Config{Endpoint: server.URL, Region: "ap-south-2", Bucket: "standby", Instance: instance,
+			AccessKeyID: "key", SecretAccessKey: "secret"}
+	}
+
+	// the entries the wal could not deliver yet and the dead letters are replicated
+	assert.NoError(t, wal.Init(ctx, wal.Config{Dir: t.TempDir(), RetryIntervalInMillis: 5},
+		func(context.Context, models.LogEntry) error { return errors.New("redis is down") }))
+	defer wal.Stop()
+	assert.NoError(t, wal.Append(ctx, models.LogEntry{Type: "order"}))
+	assert.NoError(t, wal.Append(ctx, models.LogEntry{Type: "refund"}))
+	assert.NoError(t, dlq.Init(dlq.Config{}))
+	dlq.Add(ctx, "kafka", models.LogEntry{Type: "trade"}, errors.New("broker unavailable"))
+	primary, err := replication.New(config("mumbai-1"), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, primary.Replicate(ctx))
+	status := primary.Status()
+	assert.True(t, status.Enabled)
+	assert.Equal(t, 2, status.Entries)
+	assert.Equal(t, 1, status.DeadLetters)
+	assert.Empty(t, status.LastError)
+	assert.True(t, store.has("/standby/nbu-logger/mumbai-1/snapshot.json"))
+
+	// the standby delivers the entries of the lost instance and adopts its dead letters
+	assert.NoError(t, dlq.Init(dlq.Config{}))
+	var mu sync.Mutex
+	var delivered []string
+	standby, err := replication.New(config("hyderabad-1"), func(_ context.Context, entry models.LogEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if entry.Type == "refund" {
+			return errors.New("store is down")
+		}
+		delivered = append(delivered, entry.Type)
+		return nil
+	})
+	assert.NoError(t, err)
+	promotion, err := standby.Promote(ctx, "mumbai-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "mumbai-1", promotion.Instance)
+	assert.Equal(t, "hyderabad-1", promotion.PromotedBy)
+	assert.Equal(t, 2, promotion.Entries)
+	assert.Equal(t, 1, promotion.DeadLetters)
+	assert.WithinDuration(t, time.Now(), promotion.SnapshotTakenAt, time.Minute)
+	assert.Equal(t, []string{"order"}, delivered)
+	// the entries failing to be delivered are dead lettered along with the adopted letters
+	letters, err := dlq.Get().List(ctx, dlq.Filter{})
+	assert.NoError(t, err)
+	sinks := map[string]string{}
+	for _, letter := range letters {
+		sinks[letter.Entry.Type] = letter.Sink
+	}
+	assert.Equal(t, map[string]string{"trade": "kafka", "refund": "replication"}, sinks)
+	assert.True(t, store.has("/standby/nbu-logger/mumbai-1/promoted.json"))
+
+	// a snapshot is only promoted once
+	_, err = standby.Promote(ctx, "mumbai-1")
+	assert.ErrorIs(t, err, replication.ErrPromoted)
+	_, err = standby.Promote(ctx, "hyderabad-1")
+	assert.ErrorIs(t, err, replication.ErrOwnSnapshot)
+	_, err = standby.Promote(ctx, "chennai-1")
+	assert.ErrorIs(t, err, replication.ErrNotFound)
+
+	// the failures of the object store are reported in the status
+	denied, err := replication.New(replication.Config{Endpoint: server.URL, Region: "ap-south-2", Bucket: "standby",
+		Instance: "pune-1", AccessKeyID: "other", SecretAccessKey: "secret"}, nil)
+	assert.NoError(t, err)
+	assert.Error(t, denied.Replicate(ctx))
+	assert.Contains(t, denied.Status().LastError, "403")
+}
+
+func TestReplicationDisabled(t *testing.T) {
+	replicator, err := replication.New(replication.Config{}, nil)
+	assert.NoError(t, err)
+	assert.False(t, replicator.Status().Enabled)
+	assert.ErrorIs(t, replicator.Replicate(context.Background()), replication.ErrDisabled)
+	_, err = replicator.Promote(context.Background(), "mumbai-1")
+	assert.ErrorIs(t, err, replication.ErrDisabled)
+	_, err = replication.New(replication.Config{Bucket: "standby"}, nil)
+	assert.Error(t, err)
+}
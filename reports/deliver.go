@@ -0,0 +1,148 @@
+package reports
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+// deliver is used to post the summary of the result to the webhook and email the rendered report
+func deliver(_ context.Context, r *report, config SMTPConfig, result Result) error {
+	var errs []string
+	if r.Webhook != "" {
+		if err := post(r.Webhook, Summary(result), ""); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	recipients := r.Emails
+	if r.NotifyOwners {
+		var owned []string
+		recipients, owned = owners(r, config, result)
+		for _, channel := range owned {
+			if err := post(r.Webhook, Summary(ownedBy(result, channel)), channel); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(recipients) > 0 {
+		if err := email(r, config, result, recipients); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error delivering report %s : %s", r.Name, strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// owners is used to get the recipients of the report along with the emails of the teams owning its groups, and
+// the channels of the owners the groups are posted to
+func owners(r *report, config SMTPConfig, result Result) ([]string, []string) {
+	recipients := append([]string{}, r.Emails...)
+	var channels []string
+	seen := make(map[string]bool)
+	for _, g := range result.Groups {
+		if g.Owner == nil {
+			continue
+		}
+		if r.Webhook != "" && g.Owner.Channel != "" && !seen[g.Owner.Channel] {
+			seen[g.Owner.Channel] = true
+			channels = append(channels, g.Owner.Channel)
+		}
+		if config.Address != "" && config.From != "" && g.Owner.Email != "" && !seen[g.Owner.Email] {
+			seen[g.Owner.Email] = true
+			recipients = append(recipients, g.Owner.Email)
+		}
+	}
+	return recipients, channels
+}
+
+// ownedBy is used to get the result with the groups owned by the team of the channel only
+func ownedBy(result Result, channel string) Result {
+	owned := result
+	owned.Groups = nil
+	for _, g := range result.Groups {
+		if g.Owner != nil && g.Owner.Channel == channel {
+			owned.Groups = append(owned.Groups, g)
+		}
+	}
+	return owned
+}
+
+// post is used to send the text to a slack incoming webhook, in the channel when it is not empty
+func post(url, text, channel string) error {
+	payload := map[string]string{"text": text}
+	if channel != "" {
+		payload["channel"] = channel
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	response, err := httpclient.POSTWithTimeout(url, map[string]string{"Content-Type": constants.JSONMediaType},
+		bytes.NewReader(body), constants.DefaultReportTimeoutInMillis*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("report webhook responded %d", response.StatusCode)
+	}
+	return nil
+}
+
+// email is used to send the report, html reports are the body of the email and csv ones are attached to it
+func email(r *report, config SMTPConfig, result Result, recipients []string) error {
+	content, mediaType, err := render(r, result, r.Format)
+	if err != nil {
+		return err
+	}
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\nTo: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\n", config.From,
+		strings.Join(recipients, ", "), fmt.Sprintf("%s %s", r.Name, result.To.UTC().Format("2006-01-02 15:04 MST")))
+	if r.Format == constants.HTMLReportFormat {
+		fmt.Fprintf(&message, "Content-Type: %s; charset=utf-8\r\n\r\n", mediaType)
+		message.Write(content)
+	} else {
+		w := multipart.NewWriter(&message)
+		fmt.Fprintf(&message, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", w.Boundary())
+		text, err := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=utf-8"}})
+		if err != nil {
+			return err
+		}
+		_, _ = io.WriteString(text, Summary(result))
+		attachment, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {mediaType},
+			"Content-Disposition": {fmt.Sprintf("attachment; filename=%q", r.Name+".csv")},
+		})
+		if err != nil {
+			return err
+		}
+		_, _ = attachment.Write(content)
+		if err = w.Close(); err != nil {
+			return err
+		}
+	}
+	var auth smtp.Auth
+	if config.Username != "" {
+		host, _, _ := net.SplitHostPort(config.Address)
+		auth = smtp.PlainAuth("", config.Username, config.Password, host)
+	}
+	if err = smtp.SendMail(config.Address, auth, config.From, recipients, message.Bytes()); err != nil {
+		return fmt.Errorf("error emailing report : %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package reports
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+// defaultTemplate is the html of the reports without a template of their own
+const defaultTemplate = `<html><body>
+<h2>{{.Name}}</h2>
+<p>{{.Total}} entries from {{.From.Format "2006-01-02 15:04 MST"}} to {{.To.Format "2006-01-02 15:04 MST"}}</p>
+<table border="1" cellpadding="4" cellspacing="0">
+<tr><th>{{.GroupBy}}</th><th>count</th><th>first seen</th><th>last seen</th></tr>
+{{range .Groups}}<tr><td>{{.Value}}{{with .Owner}} ({{.}}){{end}}</td><td>{{.Count}}</td><td>{{.FirstSeen.Format "15:04:05"}}</td><td>{{.LastSeen.Format "15:04:05"}}</td></tr>
+{{else}}<tr><td colspan="4">nothing to report</td></tr>
+{{end}}</table>
+</body></html>`
+
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("report").Parse(text)
+}
+
+// render is used to render the result in the format and get its media type
+func render(r *report, result Result, format string) ([]byte, string, error) {
+	var b bytes.Buffer
+	switch format {
+	case constants.CSVReportFormat:
+		w := csv.NewWriter(&b)
+		_ = w.Write([]string{result.GroupBy, "count", "firstSeen", "lastSeen"})
+		for _, g := range result.Groups {
+			_ = w.Write([]string{g.Value, strconv.Itoa(g.Count), g.FirstSeen.UTC().Format(time.RFC3339),
+				g.LastSeen.UTC().Format(time.RFC3339)})
+		}
+		w.Flush()
+		return b.Bytes(), constants.CSVMediaType, w.Error()
+	case constants.HTMLReportFormat:
+		text := r.Template
+		if text == "" {
+			text = defaultTemplate
+		}
+		t, err := parseTemplate(text)
+		if err != nil {
+			return nil, "", err
+		}
+		if err = t.Execute(&b, result); err != nil {
+			return nil, "", fmt.Errorf("error rendering report %s : %w", r.Name, err)
+		}
+		return b.Bytes(), constants.HTMLMediaType, nil
+	}
+	return nil, "", fmt.Errorf("report format has to be %s or %s", constants.HTMLReportFormat,
+		constants.CSVReportFormat)
+}
+
+// summary is used to get the plain text of the result, as posted to slack
+func Summary(result Result) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s* : %d entries from %s to %s\n", result.Name, result.Total,
+		result.From.UTC().Format("2006-01-02 15:04"), result.To.UTC().Format("2006-01-02 15:04 MST"))
+	if len(result.Groups) == 0 {
+		b.WriteString("nothing to report")
+		return b.String()
+	}
+	for i, g := range result.Groups {
+		fmt.Fprintf(&b, "%d. %s : %d", i+1, g.Value, g.Count)
+		if g.Owner != nil {
+			fmt.Fprintf(&b, ", owned by %s", g.Owner)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
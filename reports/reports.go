@@ -0,0 +1,340 @@
+// Package reports builds scheduled reports out of the stored entries, a saved search aggregated by a field,
+// e.g. the top 10 new error groups of the day, and delivers them to slack and by email.
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/catalog"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/store"
+)
+
+// ErrUnknownReport is returned for the names of reports that are not configured
+var ErrUnknownReport = errors.New(constants.UnknownReportError)
+
+// Config is the configuration of the reports
+type Config struct {
+	// SMTP is the mail server the reports are emailed with
+	SMTP SMTPConfig `json:"smtp" mapstructure:"smtp"`
+	// Reports are the scheduled reports
+	Reports []Report `json:"reports" mapstructure:"reports"`
+}
+
+// SMTPConfig is the configuration of the mail server
+type SMTPConfig struct {
+	// Address is the host:port of the server
+	Address string `json:"address" mapstructure:"address"`
+	// Username and Password are the optional plain auth credentials
+	Username string `json:"username" mapstructure:"username"`
+	Password string `json:"-" mapstructure:"password"`
+	// From is the sender of the emails
+	From string `json:"from" mapstructure:"from"`
+}
+
+// Report is a saved search aggregated by a field, rendered and delivered on a schedule
+type Report struct {
+	Name string `json:"name" mapstructure:"name"`
+	// Filter is the saved search, the expression of the entries reported on, every entry when empty
+	Filter string `json:"filter" mapstructure:"filter"`
+	// Syntax is the syntax of the filter, the filter language by default, logql or lucene
+	Syntax string `json:"syntax" mapstructure:"syntax"`
+	// GroupBy is the field the entries are counted by, the type by default
+	GroupBy string `json:"groupBy" mapstructure:"groupBy"`
+	// Top is the number of groups reported, the largest first
+	Top int `json:"top" mapstructure:"top"`
+	// OnlyNew only reports the groups that had no entries in the window before
+	OnlyNew bool `json:"onlyNew" mapstructure:"onlyNew"`
+	// IntervalInMinutes is the time between the runs, they are aligned on midnight utc, daily by default
+	IntervalInMinutes int `json:"intervalInMinutes" mapstructure:"intervalInMinutes"`
+	// OffsetInMinutes shifts the runs, e.g. 540 with a daily interval runs every day at 09:00 utc
+	OffsetInMinutes int `json:"offsetInMinutes" mapstructure:"offsetInMinutes"`
+	// WindowInMinutes is the time up to the run reported on, the interval by default
+	WindowInMinutes int `json:"windowInMinutes" mapstructure:"windowInMinutes"`
+	// Format is how the report is emailed, html or csv
+	Format string `json:"format" mapstructure:"format"`
+	// Template is the optional html/template of the html report, it is executed with the Result
+	Template string `json:"template" mapstructure:"template"`
+	// Webhook is the slack incoming webhook the report is posted to
+	Webhook string `json:"-" mapstructure:"webhook"`
+	// Emails are the recipients of the report, e.g. the owning team
+	Emails []string `json:"emails" mapstructure:"emails"`
+	// NotifyOwners also posts the groups of each service to the channel of the team owning it, as found in the
+	// service catalog, through the webhook, which has to accept the channel field as mattermost and legacy slack
+	// webhooks do. The emails of the owning teams get the emailed reports too.
+	NotifyOwners bool `json:"notifyOwners" mapstructure:"notifyOwners"`
+}
+
+// Result is a built report
+type Result struct {
+	Name    string    `json:"name"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	GroupBy string    `json:"groupBy"`
+	// Total is the number of entries reported on, the ones of the groups left out included
+	Total  int     `json:"total"`
+	Groups []Group `json:"groups"`
+}
+
+// Group is the count of the entries of a value of the field grouped by
+type Group struct {
+	Value     string    `json:"value"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	// Owner is the team owning the value when it is a service of the service catalog, e.g. grouping by type
+	Owner *catalog.Owner `json:"owner,omitempty"`
+}
+
+// report is a report along with its parsed filter
+type report struct {
+	Report
+	filter *filter.Filter
+}
+
+var (
+	mu      sync.RWMutex
+	server  SMTPConfig
+	reports = map[string]*report{}
+)
+
+// Init is used to validate and set the reports, they only run once started
+func Init(config Config) error {
+	compiled := make(map[string]*report, len(config.Reports))
+	for _, r := range config.Reports {
+		c, err := compile(r, config.SMTP)
+		if err != nil {
+			return err
+		}
+		if _, ok := compiled[c.Name]; ok {
+			return fmt.Errorf("duplicate report %s", c.Name)
+		}
+		compiled[c.Name] = c
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	server, reports = config.SMTP, compiled
+	return nil
+}
+
+// compile is used to apply the defaults of the report and parse its filter
+func compile(r Report, server SMTPConfig) (*report, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("reports need a name")
+	}
+	if r.GroupBy == "" {
+		r.GroupBy = constants.TypeField
+	}
+	if r.Top <= 0 {
+		r.Top = constants.DefaultReportTop
+	}
+	if r.IntervalInMinutes <= 0 {
+		r.IntervalInMinutes = constants.DefaultReportIntervalInMinutes
+	}
+	if r.WindowInMinutes <= 0 {
+		r.WindowInMinutes = r.IntervalInMinutes
+	}
+	if r.Format == "" {
+		r.Format = constants.HTMLReportFormat
+	}
+	if r.Format != constants.HTMLReportFormat && r.Format != constants.CSVReportFormat {
+		return nil, fmt.Errorf("report %s format has to be %s or %s", r.Name, constants.HTMLReportFormat,
+			constants.CSVReportFormat)
+	}
+	if r.Webhook == "" && len(r.Emails) == 0 {
+		return nil, fmt.Errorf("report %s needs a webhook or emails to be delivered to", r.Name)
+	}
+	if r.NotifyOwners && r.Webhook == "" && (server.Address == "" || server.From == "") {
+		return nil, fmt.Errorf("report %s notifies the owners, it needs a webhook or the smtp address and from",
+			r.Name)
+	}
+	if len(r.Emails) > 0 && (server.Address == "" || server.From == "") {
+		return nil, fmt.Errorf("report %s is emailed, the smtp address and from are required", r.Name)
+	}
+	if r.Template != "" {
+		if _, err := parseTemplate(r.Template); err != nil {
+			return nil, fmt.Errorf("invalid template of report %s : %w", r.Name, err)
+		}
+	}
+	expression, err := filter.Translate(r.Syntax, r.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter of report %s : %w", r.Name, err)
+	}
+	f, err := filter.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter of report %s : %w", r.Name, err)
+	}
+	return &report{Report: r, filter: f}, nil
+}
+
+// NextRun is used to get the first run of the report after the time
+func (r Report) NextRun(after time.Time) time.Time {
+	interval := time.Duration(r.IntervalInMinutes) * time.Minute
+	offset := time.Duration(r.OffsetInMinutes) * time.Minute % interval
+	// truncating aligns on the zero time, which is midnight utc, so daily runs happen at the same time every day
+	next := after.UTC().Truncate(interval).Add(offset)
+	for !next.After(after) {
+		next = next.Add(interval)
+	}
+	return next
+}
+
+// Start is used to run every report on its schedule until the context is done
+func Start(ctx context.Context) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, r := range reports {
+		go func(r *report) {
+			for {
+				timer := time.NewTimer(time.Until(r.NextRun(time.Now())))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case now := <-timer.C:
+					if _, err := Run(ctx, r.Name, now); err != nil {
+						log.Error(ctx).Err(err).Str(constants.ReportKey, r.Name).Msg("error running report")
+					}
+				}
+			}
+		}(r)
+	}
+}
+
+// Run is used to build the report up to the time and deliver it
+func Run(ctx context.Context, name string, now time.Time) (Result, error) {
+	r, config, err := get(name)
+	if err != nil {
+		return Result{}, err
+	}
+	result, err := build(ctx, r, now)
+	if err != nil {
+		return result, err
+	}
+	return result, deliver(ctx, r, config, result)
+}
+
+// Build is used to build the report up to the time, without delivering it
+func Build(ctx context.Context, name string, now time.Time) (Result, error) {
+	r, _, err := get(name)
+	if err != nil {
+		return Result{}, err
+	}
+	return build(ctx, r, now)
+}
+
+// Preview is used to build the report up to the time and render it in the format, without delivering it
+func Preview(ctx context.Context, name, format string, now time.Time) ([]byte, string, error) {
+	r, _, err := get(name)
+	if err != nil {
+		return nil, "", err
+	}
+	result, err := build(ctx, r, now)
+	if err != nil {
+		return nil, "", err
+	}
+	if format == "" {
+		format = r.Format
+	}
+	return render(r, result, format)
+}
+
+// Names is used to get the names of the reports, sorted
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(reports))
+	for name := range reports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func get(name string) (*report, SMTPConfig, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	r, ok := reports[name]
+	if !ok {
+		return nil, server, fmt.Errorf("%w %s", ErrUnknownReport, name)
+	}
+	return r, server, nil
+}
+
+// build is used to count the entries of the window by their value of the field grouped by
+func build(ctx context.Context, r *report, now time.Time) (Result, error) {
+	window := time.Duration(r.WindowInMinutes) * time.Minute
+	result := Result{Name: r.Name, From: now.Add(-window), To: now, GroupBy: r.GroupBy, Groups: []Group{}}
+	records, err := store.Get().Query(ctx, store.Query{Filter: r.filter, Axis: store.EventTime, From: result.From,
+		To: result.To})
+	if err != nil {
+		return result, fmt.Errorf("error querying entries of report %s : %w", r.Name, err)
+	}
+	seen := make(map[string]bool)
+	if r.OnlyNew {
+		before, err := store.Get().Query(ctx, store.Query{Filter: r.filter, Axis: store.EventTime,
+			From: result.From.Add(-window), To: result.From})
+		if err != nil {
+			return result, fmt.Errorf("error querying entries of report %s : %w", r.Name, err)
+		}
+		for _, record := range before {
+			seen[groupOf(record, r.GroupBy)] = true
+		}
+	}
+	groups := make(map[string]*Group)
+	for _, record := range records {
+		result.Total++
+		value := groupOf(record, r.GroupBy)
+		if seen[value] {
+			continue
+		}
+		at := record.EventTime()
+		g, ok := groups[value]
+		if !ok {
+			g = &Group{Value: value, FirstSeen: at, LastSeen: at}
+			groups[value] = g
+		}
+		g.Count++
+		if at.Before(g.FirstSeen) {
+			g.FirstSeen = at
+		}
+		if at.After(g.LastSeen) {
+			g.LastSeen = at
+		}
+	}
+	for _, g := range groups {
+		result.Groups = append(result.Groups, *g)
+	}
+	sort.Slice(result.Groups, func(i, j int) bool {
+		if result.Groups[i].Count != result.Groups[j].Count {
+			return result.Groups[i].Count > result.Groups[j].Count
+		}
+		return result.Groups[i].Value < result.Groups[j].Value
+	})
+	if len(result.Groups) > r.Top {
+		result.Groups = result.Groups[:r.Top]
+	}
+	for i := range result.Groups {
+		if owner, ok := catalog.Lookup(result.Groups[i].Value); ok {
+			result.Groups[i].Owner = &owner
+		}
+	}
+	return result, nil
+}
+
+// groupOf is used to get the group of the record, entries without the field are grouped together
+func groupOf(record store.Record, field string) string {
+	value, ok := filter.Lookup(record.Entry, field)
+	if !ok || value == nil {
+		return constants.NoReportGroup
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
@@ -0,0 +1,70 @@
+package reports_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/reports"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	s := store.NewMemory(100)
+	store.Init(s)
+	add := func(group string, ago time.Duration, level string) {
+		at := models.Timestamp{Time: now.Add(-ago)}
+		_, _ = s.Add(ctx, models.LogEntry{Type: "payment", Level: level, Timestamp: &at,
+			Data: map[string]interface{}{"error": group}})
+	}
+	add("timeout", 30*time.Hour, "error")
+	add("timeout", time.Hour, "error")
+	add("declined", 2*time.Hour, "error")
+	add("declined", 3*time.Hour, "error")
+	add("refused", time.Hour, "error")
+	add("refused", time.Hour, "info")
+
+	var posted map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&posted)
+	}))
+	defer server.Close()
+	err := reports.Init(reports.Config{Reports: []reports.Report{{Name: "new-errors", Filter: "level = error",
+		GroupBy: "error", OnlyNew: true, Format: "csv", Webhook: server.URL}}})
+	assert.NoError(t, err)
+
+	result, err := reports.Run(ctx, "new-errors", now)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, result.Total)
+	if assert.Len(t, result.Groups, 2) {
+		assert.Equal(t, "declined", result.Groups[0].Value)
+		assert.Equal(t, 2, result.Groups[0].Count)
+		assert.Equal(t, "refused", result.Groups[1].Value)
+	}
+	assert.Contains(t, posted["text"], "1. declined : 2")
+
+	content, mediaType, err := reports.Preview(ctx, "new-errors", "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, "text/csv", mediaType)
+	assert.Contains(t, string(content), "error,count,firstSeen,lastSeen\ndeclined,2,")
+
+	_, err = reports.Run(ctx, "missing", now)
+	assert.ErrorIs(t, err, reports.ErrUnknownReport)
+	assert.Error(t, reports.Init(reports.Config{Reports: []reports.Report{{Name: "mail", Emails: []string{"a@b.c"}}}}))
+}
+
+func TestNextRun(t *testing.T) {
+	daily := reports.Report{IntervalInMinutes: 24 * 60, OffsetInMinutes: 9 * 60}
+	at := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 3, 6, 9, 0, 0, 0, time.UTC), daily.NextRun(at))
+	assert.Equal(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC), daily.NextRun(at.Add(-2*time.Hour)))
+	hourly := reports.Report{IntervalInMinutes: 60}
+	assert.Equal(t, time.Date(2024, 3, 5, 11, 0, 0, 0, time.UTC), hourly.NextRun(at))
+}
@@ -0,0 +1,154 @@
+// Package rollup counts the entries of every type in time buckets by the time they were logged. Entries from
+// offline devices arrive hours after they were logged, so they are counted in the bucket they were logged in
+// rather than the one they arrived in. A watermark trailing the newest entry tells which buckets are complete.
+package rollup
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// Config is the configuration of the rollup
+type Config struct {
+	// BucketInMinutes is the width of the buckets, an hour by default
+	BucketInMinutes int `json:"bucketInMinutes" mapstructure:"bucketInMinutes"`
+	// AllowedLatenessInMinutes is how far the watermark trails the newest entry, buckets before it are closed
+	AllowedLatenessInMinutes int `json:"allowedLatenessInMinutes" mapstructure:"allowedLatenessInMinutes"`
+	// RetentionInHours is how long buckets are kept, entries logged before are not counted
+	RetentionInHours int `json:"retentionInHours" mapstructure:"retentionInHours"`
+}
+
+// Bucket is the counts of the entries logged in a time bucket
+type Bucket struct {
+	Start time.Time `json:"start"`
+	// Counts are the counts by type
+	Counts map[string]int `json:"counts"`
+	// Late is the number of entries that arrived after the watermark passed the time they were logged at
+	Late int `json:"late"`
+	// Closed is set once the watermark passed the end of the bucket, it only changes with late entries then
+	Closed bool `json:"closed"`
+}
+
+// Rollup is the bucketed counts of the entries
+type Rollup struct {
+	mu        sync.RWMutex
+	config    Config
+	buckets   map[int64]*Bucket
+	newest    time.Time
+	watermark time.Time
+	tooLate   int
+}
+
+var r = New(Config{})
+
+// New is used to create an empty rollup for the config
+func New(config Config) *Rollup {
+	if config.BucketInMinutes <= 0 {
+		config.BucketInMinutes = constants.DefaultRollupBucketInMinutes
+	}
+	if config.AllowedLatenessInMinutes <= 0 {
+		config.AllowedLatenessInMinutes = constants.DefaultRollupAllowedLatenessInMinutes
+	}
+	if config.RetentionInHours <= 0 {
+		config.RetentionInHours = constants.DefaultRollupRetentionInHours
+	}
+	return &Rollup{config: config, buckets: make(map[int64]*Bucket)}
+}
+
+// Init is used to initialize the default rollup
+func Init(rollup *Rollup) {
+	r = rollup
+}
+
+// Get is used to get the default rollup
+func Get() *Rollup {
+	return r
+}
+
+// Add is used to count the entry in the bucket of the time it was logged, the time it was received without one
+func (r *Rollup) Add(entry models.LogEntry, receivedAt time.Time) {
+	at := receivedAt
+	if entry.Timestamp != nil {
+		at = entry.Timestamp.Time
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if at.Before(receivedAt.Add(-r.retention())) {
+		r.tooLate++
+		return
+	}
+	start := at.Truncate(r.width())
+	bucket, ok := r.buckets[start.UnixNano()]
+	if !ok {
+		bucket = &Bucket{Start: start, Counts: make(map[string]int)}
+		r.buckets[start.UnixNano()] = bucket
+	}
+	bucket.Counts[entry.Type]++
+	if at.Before(r.watermark) {
+		bucket.Late++
+	}
+	if at.After(r.newest) {
+		r.newest = at
+		r.watermark = at.Add(-time.Duration(r.config.AllowedLatenessInMinutes) * time.Minute)
+		r.expire(at)
+	}
+}
+
+// Buckets is used to get the buckets overlapping the range, oldest first. Zero times are unbounded and the
+// counts are only of the type when one is given.
+func (r *Rollup) Buckets(kind string, from, to time.Time) []Bucket {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	buckets := make([]Bucket, 0, len(r.buckets))
+	for _, bucket := range r.buckets {
+		if (!from.IsZero() && bucket.Start.Before(from.Truncate(r.width()))) ||
+			(!to.IsZero() && !bucket.Start.Before(to)) {
+			continue
+		}
+		counts := make(map[string]int, len(bucket.Counts))
+		for k, count := range bucket.Counts {
+			if kind == "" || k == kind {
+				counts[k] = count
+			}
+		}
+		buckets = append(buckets, Bucket{Start: bucket.Start, Counts: counts, Late: bucket.Late,
+			Closed: !bucket.Start.Add(r.width()).After(r.watermark)})
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+	return buckets
+}
+
+// Watermark is used to get the time the buckets before are complete but for late entries
+func (r *Rollup) Watermark() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.watermark
+}
+
+// TooLate is used to get the number of entries logged before the retention that were not counted
+func (r *Rollup) TooLate() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.tooLate
+}
+
+// expire is used to drop the buckets older than the retention
+func (r *Rollup) expire(now time.Time) {
+	for key, bucket := range r.buckets {
+		if bucket.Start.Add(r.width()).Before(now.Add(-r.retention())) {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+func (r *Rollup) width() time.Duration {
+	return time.Duration(r.config.BucketInMinutes) * time.Minute
+}
+
+func (r *Rollup) retention() time.Duration {
+	return time.Duration(r.config.RetentionInHours) * time.Hour
+}
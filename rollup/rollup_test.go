@@ -0,0 +1,38 @@
+package rollup_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/rollup"
+	"github.com/stretchr/testify/assert"
+)
+
+func at(t time.Time) *models.Timestamp {
+	return &models.Timestamp{Time: t}
+}
+
+func TestLateEntries(t *testing.T) {
+	r := rollup.New(rollup.Config{BucketInMinutes: 60, AllowedLatenessInMinutes: 30, RetentionInHours: 24})
+	now := time.Date(2024, 3, 5, 12, 10, 0, 0, time.UTC)
+	r.Add(models.LogEntry{Type: "app", Timestamp: at(now.Add(-2 * time.Hour))}, now.Add(-2*time.Hour))
+	r.Add(models.LogEntry{Type: "app"}, now)
+	// an offline device syncs what it logged three hours ago
+	r.Add(models.LogEntry{Type: "app", Timestamp: at(now.Add(-3 * time.Hour))}, now)
+	r.Add(models.LogEntry{Type: "app", Timestamp: at(now.Add(-48 * time.Hour))}, now)
+
+	assert.Equal(t, now.Add(-30*time.Minute), r.Watermark())
+	assert.Equal(t, 1, r.TooLate())
+	buckets := r.Buckets("", time.Time{}, time.Time{})
+	if assert.Len(t, buckets, 3) {
+		assert.Equal(t, time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC), buckets[0].Start)
+		assert.Equal(t, 1, buckets[0].Counts["app"])
+		assert.Equal(t, 1, buckets[0].Late)
+		assert.True(t, buckets[0].Closed)
+		assert.Equal(t, 0, buckets[1].Late)
+		assert.False(t, buckets[2].Closed)
+	}
+	assert.Len(t, r.Buckets("app", now.Add(-2*time.Hour), now), 2)
+	assert.Empty(t, r.Buckets("other", time.Time{}, time.Time{})[0].Counts)
+}
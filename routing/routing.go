@@ -0,0 +1,142 @@
+// Package routing maps the types of the entries onto their destinations, the sinks they are emitted to and the
+// queue they are persisted through, e.g. the payment.* entries to the payments topic only and the debug.* ones to
+// a file only. The entries of the types without a rule go to every sink and to the queue of the config.
+package routing
+
+import (
+	"fmt"
+	"path"
+	"sync"
+)
+
+// Config is the configuration of the routing
+type Config struct {
+	// Rules are matched in order, the first rule matching the type of an entry routes it
+	Rules []Rule `json:"rules" mapstructure:"rules"`
+}
+
+// Rule routes the entries of the types matching a glob
+type Rule struct {
+	// Type is the glob of the types, e.g. payment.* or debug.*, as path.Match matches them
+	Type string `json:"type" mapstructure:"type"`
+	// Sinks are the names of the sinks the entries are emitted to, every sink when empty
+	Sinks []string `json:"sinks" mapstructure:"sinks"`
+	// Queue is the ingestion queue the entries are persisted through, the one of the jobs config when empty
+	Queue string `json:"queue" mapstructure:"queue"`
+}
+
+// Router is the compiled routing
+type Router struct {
+	rules []rule
+}
+
+// rule is a rule with the set of its sinks
+type rule struct {
+	Rule
+	sinks map[string]bool
+}
+
+var (
+	mu     sync.RWMutex
+	router = &Router{}
+)
+
+// New is used to create the router of the config, checking the globs
+func New(config Config) (*Router, error) {
+	r := &Router{}
+	for _, c := range config.Rules {
+		if c.Type == "" {
+			return nil, fmt.Errorf("routing rules need a type")
+		}
+		if _, err := path.Match(c.Type, ""); err != nil {
+			return nil, fmt.Errorf("invalid type %q of routing rule : %w", c.Type, err)
+		}
+		if len(c.Sinks) == 0 && c.Queue == "" {
+			return nil, fmt.Errorf("routing rule of type %s needs sinks or a queue", c.Type)
+		}
+		compiled := rule{Rule: c, sinks: make(map[string]bool, len(c.Sinks))}
+		for _, sink := range c.Sinks {
+			compiled.sinks[sink] = true
+		}
+		r.rules = append(r.rules, compiled)
+	}
+	return r, nil
+}
+
+// Init is used to set the default router
+func Init(config Config) error {
+	r, err := New(config)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	router = r
+	return nil
+}
+
+// Get is used to get the default router
+func Get() *Router {
+	mu.RLock()
+	defer mu.RUnlock()
+	return router
+}
+
+// MatchType is used to check whether the type matches the glob, an empty glob or * matches every type
+func MatchType(glob, logType string) bool {
+	if glob == "" || glob == "*" || glob == logType {
+		return true
+	}
+	matched, _ := path.Match(glob, logType)
+	return matched
+}
+
+// match is used to get the first rule matching the type
+func (r *Router) match(logType string) (rule, bool) {
+	for _, rule := range r.rules {
+		if MatchType(rule.Type, logType) {
+			return rule, true
+		}
+	}
+	return rule{}, false
+}
+
+// Emits is used to check whether the entries of the type are emitted to the sink
+func (r *Router) Emits(logType, sink string) bool {
+	rule, ok := r.match(logType)
+	return !ok || len(rule.sinks) == 0 || rule.sinks[sink]
+}
+
+// Queue is used to get the queue of the entries of the type, empty for the queue of the config
+func (r *Router) Queue(logType string) string {
+	rule, _ := r.match(logType)
+	return rule.Queue
+}
+
+// Queues is used to get the queues of the rules, for the workers to serve them
+func (r *Router) Queues() []string {
+	var queues []string
+	seen := make(map[string]bool)
+	for _, rule := range r.rules {
+		if rule.Queue != "" && !seen[rule.Queue] {
+			seen[rule.Queue] = true
+			queues = append(queues, rule.Queue)
+		}
+	}
+	return queues
+}
+
+// Sinks is used to get the sinks named by the rules
+func (r *Router) Sinks() []string {
+	var sinks []string
+	seen := make(map[string]bool)
+	for _, rule := range r.rules {
+		for _, sink := range rule.Sinks {
+			if !seen[sink] {
+				seen[sink] = true
+				sinks = append(sinks, sink)
+			}
+		}
+	}
+	return sinks
+}
@@ -0,0 +1,74 @@
+package routing_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/routing"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingSink struct {
+	name  string
+	mu    sync.Mutex
+	types []string
+}
+
+func (s *recordingSink) Name() string {
+	return s.name
+}
+
+func (s *recordingSink) Send(_ context.Context, entry models.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.types = append(s.types, entry.Type)
+	return nil
+}
+
+func (s *recordingSink) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.types...)
+}
+
+func TestRouting(t *testing.T) {
+	assert.NoError(t, routing.Init(routing.Config{Rules: []routing.Rule{
+		{Type: "payment.refund", Sinks: []string{"audit", "payments"}},
+		{Type: "payment.*", Sinks: []string{"payments"}, Queue: "payments"},
+		{Type: "debug.*", Sinks: []string{"file"}},
+		{Type: "audit", Queue: "audit"},
+	}}))
+	router := routing.Get()
+	assert.True(t, router.Emits("payment.refund", "audit"))
+	assert.False(t, router.Emits("payment.capture", "audit"))
+	assert.True(t, router.Emits("order", "audit"))
+	assert.True(t, router.Emits("audit", "file"))
+	assert.Equal(t, "payments", router.Queue("payment.capture"))
+	assert.Equal(t, "", router.Queue("payment.refund"))
+	assert.Equal(t, "", router.Queue("order"))
+	assert.Equal(t, []string{"payments", "audit"}, router.Queues())
+	assert.Equal(t, []string{"audit", "payments", "file"}, router.Sinks())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	payments, file := &recordingSink{name: "payments"}, &recordingSink{name: "file"}
+	sinks.Add(ctx, payments, 0)
+	sinks.Add(ctx, file, 0)
+	for _, logType := range []string{"payment.capture", "debug.sql", "order"} {
+		sinks.Emit(ctx, models.LogEntry{Type: logType})
+	}
+	assert.Eventually(t, func() bool {
+		return len(payments.received()) == 2 && len(file.received()) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{"payment.capture", "order"}, payments.received())
+	assert.ElementsMatch(t, []string{"debug.sql", "order"}, file.received())
+
+	for _, invalid := range []routing.Rule{{Sinks: []string{"file"}}, {Type: "debug.[", Sinks: []string{"file"}},
+		{Type: "debug.*"}} {
+		assert.Error(t, routing.Init(routing.Config{Rules: []routing.Rule{invalid}}), invalid.Type)
+	}
+}
@@ -0,0 +1,361 @@
+package schemas
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// unsupported are the keywords rejected rather than ignored, ignoring them would accept what the schema rejects
+var unsupported = []string{"$ref", "allOf", "anyOf", "oneOf", "not", "if", "patternProperties", "dependencies"}
+
+// Schema is the subset of json schema the entries are validated with: type, enum, const, properties, required,
+// additionalProperties, items, the length, item count and range bounds, pattern and the date-time format
+type Schema struct {
+	Types                []string
+	Enum                 []interface{}
+	Const                *interface{}
+	Properties           map[string]*Schema
+	Required             []string
+	AdditionalProperties *Schema
+	NoAdditional         bool
+	Items                *Schema
+	Minimum              *float64
+	Maximum              *float64
+	ExclusiveMinimum     *float64
+	ExclusiveMaximum     *float64
+	MinLength            *int
+	MaxLength            *int
+	MinItems             *int
+	MaxItems             *int
+	Pattern              *regexp.Regexp
+	Format               string
+}
+
+// FieldError is a field of the data that does not match the schema
+type FieldError struct {
+	// Field is the path of the field, e.g. items[0].sku, empty for the data itself
+	Field string `json:"field"`
+	// Constraint is the keyword of the schema the field violates, e.g. required or maxLength
+	Constraint string `json:"constraint"`
+	Error      string `json:"error"`
+}
+
+// Compile is used to parse a json schema
+func Compile(spec json.RawMessage) (*Schema, error) {
+	decoder := json.NewDecoder(bytes.NewReader(spec))
+	decoder.UseNumber()
+	var raw interface{}
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid json : %w", err)
+	}
+	return compile(raw, "")
+}
+
+func compile(raw interface{}, path string) (*Schema, error) {
+	if allowed, ok := raw.(bool); ok {
+		if allowed {
+			return &Schema{}, nil
+		}
+		return nil, fmt.Errorf("%s: false schemas are only supported as additionalProperties", at(path))
+	}
+	object, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: schema has to be an object", at(path))
+	}
+	for _, keyword := range unsupported {
+		if _, ok := object[keyword]; ok {
+			return nil, fmt.Errorf("%s: %s is not supported", at(path), keyword)
+		}
+	}
+	s := &Schema{}
+	switch t := object["type"].(type) {
+	case nil:
+	case string:
+		s.Types = []string{t}
+	case []interface{}:
+		for _, item := range t {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: type has to be a string or strings", at(path))
+			}
+			s.Types = append(s.Types, name)
+		}
+	default:
+		return nil, fmt.Errorf("%s: type has to be a string or strings", at(path))
+	}
+	for _, name := range s.Types {
+		switch name {
+		case "object", "array", "string", "number", "integer", "boolean", "null":
+		default:
+			return nil, fmt.Errorf("%s: unknown type %s", at(path), name)
+		}
+	}
+	if enum, ok := object["enum"]; ok {
+		values, ok := enum.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: enum has to be an array", at(path))
+		}
+		s.Enum = values
+	}
+	if value, ok := object["const"]; ok {
+		s.Const = &value
+	}
+	if properties, ok := object["properties"]; ok {
+		fields, ok := properties.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: properties has to be an object", at(path))
+		}
+		s.Properties = make(map[string]*Schema, len(fields))
+		for name, field := range fields {
+			property, err := compile(field, join(path, name))
+			if err != nil {
+				return nil, err
+			}
+			s.Properties[name] = property
+		}
+	}
+	if required, ok := object["required"]; ok {
+		names, ok := required.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: required has to be an array of strings", at(path))
+		}
+		for _, name := range names {
+			field, ok := name.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: required has to be an array of strings", at(path))
+			}
+			s.Required = append(s.Required, field)
+		}
+	}
+	if additional, ok := object["additionalProperties"]; ok {
+		if allowed, ok := additional.(bool); ok {
+			s.NoAdditional = !allowed
+		} else {
+			schema, err := compile(additional, path)
+			if err != nil {
+				return nil, err
+			}
+			s.AdditionalProperties = schema
+		}
+	}
+	if items, ok := object["items"]; ok {
+		schema, err := compile(items, path+"[]")
+		if err != nil {
+			return nil, err
+		}
+		s.Items = schema
+	}
+	for keyword, bound := range map[string]**float64{"minimum": &s.Minimum, "maximum": &s.Maximum,
+		"exclusiveMinimum": &s.ExclusiveMinimum, "exclusiveMaximum": &s.ExclusiveMaximum} {
+		if value, ok := object[keyword]; ok {
+			number, ok := toFloat(value)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s has to be a number", at(path), keyword)
+			}
+			*bound = &number
+		}
+	}
+	for keyword, bound := range map[string]**int{"minLength": &s.MinLength, "maxLength": &s.MaxLength,
+		"minItems": &s.MinItems, "maxItems": &s.MaxItems} {
+		if value, ok := object[keyword]; ok {
+			number, ok := toFloat(value)
+			if !ok || number < 0 || number != math.Trunc(number) {
+				return nil, fmt.Errorf("%s: %s has to be a non negative integer", at(path), keyword)
+			}
+			n := int(number)
+			*bound = &n
+		}
+	}
+	if value, ok := object["pattern"]; ok {
+		pattern, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: pattern has to be a string", at(path))
+		}
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid pattern : %w", at(path), err)
+		}
+		s.Pattern = compiled
+	}
+	s.Format, _ = object["format"].(string)
+	return s, nil
+}
+
+// Validate is used to get the fields of the value that do not match the schema, sorted by field
+func (s *Schema) Validate(value interface{}) []FieldError {
+	var errors []FieldError
+	s.validate(value, "", &errors)
+	sort.SliceStable(errors, func(i, j int) bool { return errors[i].Field < errors[j].Field })
+	return errors
+}
+
+func (s *Schema) validate(value interface{}, path string, errors *[]FieldError) {
+	fail := func(constraint, format string, args ...interface{}) {
+		*errors = append(*errors, FieldError{Field: path, Constraint: constraint, Error: fmt.Sprintf(format, args...)})
+	}
+	if len(s.Types) > 0 && !s.hasType(value) {
+		fail("type", "has to be %s", strings.Join(s.Types, " or "))
+		return
+	}
+	if s.Const != nil && !equal(value, *s.Const) {
+		fail("const", "has to be %v", *s.Const)
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			found = found || equal(value, allowed)
+		}
+		if !found {
+			fail("enum", "has to be one of %v", s.Enum)
+		}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errors = append(*errors, FieldError{Field: join(path, name), Constraint: "required",
+					Error: "is required"})
+			}
+		}
+		for name, field := range v {
+			if property, ok := s.Properties[name]; ok {
+				property.validate(field, join(path, name), errors)
+			} else if s.NoAdditional {
+				*errors = append(*errors, FieldError{Field: join(path, name),
+					Constraint: "additionalProperties", Error: "is not allowed"})
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(field, join(path, name), errors)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("minItems", "has to have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("maxItems", "has to have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(item, path+"["+strconv.Itoa(i)+"]", errors)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(v)
+		if s.MinLength != nil && length < *s.MinLength {
+			fail("minLength", "has to be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("maxLength", "has to be at most %d characters", *s.MaxLength)
+		}
+		if s.Pattern != nil && !s.Pattern.MatchString(v) {
+			fail("pattern", "has to match %s", s.Pattern)
+		}
+		if s.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, v); err != nil {
+				fail("format", "has to be an RFC3339 date-time")
+			}
+		}
+	default:
+		number, ok := toFloat(value)
+		if !ok {
+			return
+		}
+		if s.Minimum != nil && number < *s.Minimum {
+			fail("minimum", "has to be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && number > *s.Maximum {
+			fail("maximum", "has to be at most %v", *s.Maximum)
+		}
+		if s.ExclusiveMinimum != nil && number <= *s.ExclusiveMinimum {
+			fail("exclusiveMinimum", "has to be greater than %v", *s.ExclusiveMinimum)
+		}
+		if s.ExclusiveMaximum != nil && number >= *s.ExclusiveMaximum {
+			fail("exclusiveMaximum", "has to be less than %v", *s.ExclusiveMaximum)
+		}
+	}
+}
+
+func (s *Schema) hasType(value interface{}) bool {
+	for _, name := range s.Types {
+		switch v := value.(type) {
+		case nil:
+			if name == "null" {
+				return true
+			}
+		case map[string]interface{}:
+			if name == "object" {
+				return true
+			}
+		case []interface{}:
+			if name == "array" {
+				return true
+			}
+		case string:
+			if name == "string" {
+				return true
+			}
+		case bool:
+			if name == "boolean" {
+				return true
+			}
+		default:
+			number, ok := toFloat(v)
+			if ok && (name == "number" || name == "integer" && number == math.Trunc(number)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// equal is used to compare json values, numbers by value whatever they were decoded as
+func equal(a, b interface{}) bool {
+	if x, ok := toFloat(a); ok {
+		y, ok := toFloat(b)
+		return ok && x == y
+	}
+	left, _ := json.Marshal(a)
+	right, _ := json.Marshal(b)
+	return bytes.Equal(left, right)
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func at(path string) string {
+	if path == "" {
+		return "schema"
+	}
+	return path
+}
@@ -0,0 +1,139 @@
+// Package schemas validates the data of the entries against the json schema of their type, so that producers
+// sending malformed telemetry find out when they send it rather than when it is queried. Schemas are loaded from
+// a directory of <type>.json files and managed as admin resources keyed by type, the latter taking precedence.
+package schemas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/registry"
+)
+
+// Config is the configuration of the schemas
+type Config struct {
+	// Directory holds the schemas as <type>.json files
+	Directory string `json:"directory" mapstructure:"directory"`
+}
+
+// ValidationError is returned for entries whose data does not match the schema of their type
+type ValidationError struct {
+	Type   string
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		if field.Field == "" {
+			fields = append(fields, field.Error)
+		} else {
+			fields = append(fields, field.Field+" "+field.Error)
+		}
+	}
+	return fmt.Sprintf("%s: data does not match the schema of %s : %s", constants.SchemaValidationError, e.Type,
+		strings.Join(fields, ", "))
+}
+
+var (
+	mu       sync.RWMutex
+	base     = map[string]*Schema{}
+	resolved = map[string]*Schema{}
+)
+
+// Register is used to register the schemas resource kind on the registry, the schemas are reloaded on changes
+func Register(reg *registry.Registry) {
+	reg.RegisterKind(registry.Kind{
+		Name: constants.SchemasResourceKind,
+		Validate: func(_ string, spec json.RawMessage) error {
+			_, err := Compile(spec)
+			return err
+		},
+	})
+	reg.OnChange(constants.SchemasResourceKind, func() {
+		if err := Reload(reg); err != nil {
+			log.Error(nil).Err(err).Msg("error reloading schemas")
+		}
+	})
+}
+
+// Init is used to load the schemas of the directory and apply the schema resources on top of them
+func Init(config Config, reg *registry.Registry) error {
+	schemas := make(map[string]*Schema)
+	if config.Directory != "" {
+		paths, err := filepath.Glob(filepath.Join(config.Directory, "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			spec, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("error reading schema %s : %w", path, err)
+			}
+			schema, err := Compile(spec)
+			if err != nil {
+				return fmt.Errorf("invalid schema %s : %w", path, err)
+			}
+			schemas[strings.TrimSuffix(filepath.Base(path), ".json")] = schema
+		}
+	}
+	mu.Lock()
+	base = schemas
+	mu.Unlock()
+	return Reload(reg)
+}
+
+// Reload is used to rebuild the schemas from the ones of the directory and the schema resources
+func Reload(reg *registry.Registry) error {
+	resources, err := reg.List(constants.SchemasResourceKind)
+	if err != nil {
+		return err
+	}
+	mu.RLock()
+	schemas := make(map[string]*Schema, len(base)+len(resources))
+	for kind, schema := range base {
+		schemas[kind] = schema
+	}
+	mu.RUnlock()
+	for _, resource := range resources {
+		schema, err := Compile(resource.Spec)
+		if err != nil {
+			return fmt.Errorf("invalid schema %s : %w", resource.ID, err)
+		}
+		schemas[resource.ID] = schema
+	}
+	mu.Lock()
+	resolved = schemas
+	mu.Unlock()
+	return nil
+}
+
+// Validate is used to check the data of the entry against the schema of its type, types without one are valid
+func Validate(entry models.LogEntry) error {
+	mu.RLock()
+	schema, ok := resolved[entry.Type]
+	mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return schema.ValidateEntry(entry)
+}
+
+// ValidateEntry is used to check the data of the entry against the schema, whatever the type of the entry
+func (s *Schema) ValidateEntry(entry models.LogEntry) error {
+	data := entry.Data
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	if fields := s.Validate(data); len(fields) > 0 {
+		return &ValidationError{Type: entry.Type, Fields: fields}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package schemas_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/angel-one/nbu-logger-service/schemas"
+	"github.com/stretchr/testify/assert"
+)
+
+const paymentSchema = `{
+	"type": "object",
+	"required": ["amount", "currency"],
+	"properties": {
+		"amount": {"type": "number", "exclusiveMinimum": 0},
+		"currency": {"enum": ["INR", "USD"]},
+		"items": {"type": "array", "items": {"type": "object", "required": ["sku"]}}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "payment.json"), []byte(paymentSchema), 0o600))
+	reg := registry.New()
+	schemas.Register(reg)
+	assert.NoError(t, schemas.Init(schemas.Config{Directory: dir}, reg))
+
+	var data map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":10,"currency":"INR","items":[{"sku":"a"}]}`), &data))
+	assert.NoError(t, schemas.Validate(models.LogEntry{Type: "payment", Data: data}))
+	assert.NoError(t, schemas.Validate(models.LogEntry{Type: "other"}))
+
+	assert.NoError(t, json.Unmarshal([]byte(`{"amount":-1,"currency":"EUR","items":[{}]}`), &data))
+	err := schemas.Validate(models.LogEntry{Type: "payment", Data: data})
+	var invalid *schemas.ValidationError
+	if assert.True(t, errors.As(err, &invalid)) {
+		assert.Equal(t, []schemas.FieldError{
+			{Field: "amount", Constraint: "exclusiveMinimum", Error: "has to be greater than 0"},
+			{Field: "currency", Constraint: "enum", Error: "has to be one of [INR USD]"},
+			{Field: "items[0].sku", Constraint: "required", Error: "is required"},
+		}, invalid.Fields)
+	}
+
+	// the admin resources take precedence over the directory
+	_, err = reg.Put("schemas", "payment", json.RawMessage(`{"type":"object"}`), registry.Precondition{}, "alice")
+	assert.NoError(t, err)
+	assert.NoError(t, schemas.Validate(models.LogEntry{Type: "payment", Data: data}))
+	_, err = reg.Put("schemas", "order", json.RawMessage(`{"$ref":"#/definitions/order"}`), registry.Precondition{},
+		"alice")
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+// Package sessions reconstructs what happened in a session, e.g. the visit of a user or the life of a device,
+// from the entries of every type logged with its id.
+package sessions
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/store"
+)
+
+// Session is the time ordered reconstruction of the entries of a session
+type Session struct {
+	ID string `json:"id"`
+	// Start and End are the times the first and the last entries were logged
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+	// Types are the types of the entries, sorted
+	Types   []string       `json:"types"`
+	Entries []store.Record `json:"entries"`
+	// Duplicates is the number of entries dropped as the same entry was stored before, e.g. when resent
+	Duplicates int `json:"duplicates"`
+	// Gaps are the silences longer than the gap between consecutive entries
+	Gaps []Gap `json:"gaps"`
+}
+
+// Gap is a silence between two consecutive entries of a session
+type Gap struct {
+	// AfterID and BeforeID are the ids of the records around the gap
+	AfterID           uint64    `json:"afterId"`
+	BeforeID          uint64    `json:"beforeId"`
+	From              time.Time `json:"from"`
+	To                time.Time `json:"to"`
+	DurationInSeconds float64   `json:"durationInSeconds"`
+}
+
+// Reconstruct is used to get the session of the id from the records that have it in one of the fields, ordered
+// by the time they were logged and without the duplicates. Silences longer than gap are annotated.
+func Reconstruct(id string, fields []string, records []store.Record, gap time.Duration) Session {
+	session := Session{ID: id, Types: []string{}, Entries: []store.Record{}, Gaps: []Gap{}}
+	var picked []store.Record
+	for _, record := range records {
+		if hasID(record, id, fields) {
+			picked = append(picked, record)
+		}
+	}
+	sort.Slice(picked, func(i, j int) bool {
+		ti, tj := picked[i].EventTime(), picked[j].EventTime()
+		if ti.Equal(tj) {
+			return picked[i].ID < picked[j].ID
+		}
+		return ti.Before(tj)
+	})
+	seen := make(map[string]bool, len(picked))
+	types := make(map[string]bool)
+	for _, record := range picked {
+		// the first one stored is kept, the entry being the same whatever the record
+		content, err := json.Marshal(record.Entry)
+		if err == nil && seen[string(content)] {
+			session.Duplicates++
+			continue
+		}
+		seen[string(content)] = true
+		if n := len(session.Entries); n > 0 {
+			previous := session.Entries[n-1]
+			if silence := record.EventTime().Sub(previous.EventTime()); silence > gap {
+				session.Gaps = append(session.Gaps, Gap{AfterID: previous.ID, BeforeID: record.ID,
+					From: previous.EventTime(), To: record.EventTime(), DurationInSeconds: silence.Seconds()})
+			}
+		}
+		if !types[record.Entry.Type] {
+			types[record.Entry.Type] = true
+			session.Types = append(session.Types, record.Entry.Type)
+		}
+		session.Entries = append(session.Entries, record)
+	}
+	sort.Strings(session.Types)
+	if n := len(session.Entries); n > 0 {
+		start, end := session.Entries[0].EventTime(), session.Entries[n-1].EventTime()
+		session.Start, session.End = &start, &end
+	}
+	return session
+}
+
+// hasID is used to check whether one of the fields of the record is the id
+func hasID(record store.Record, id string, fields []string) bool {
+	for _, field := range fields {
+		if value, ok := filter.Lookup(record.Entry, field); ok && value != nil && fmt.Sprint(value) == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package sessions_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sessions"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconstruct(t *testing.T) {
+	start := time.Date(2024, 3, 5, 9, 0, 0, 0, time.UTC)
+	record := func(id uint64, kind string, after time.Duration, data map[string]interface{}) store.Record {
+		at := models.Timestamp{Time: start.Add(after)}
+		return store.Record{ID: id, IngestedAt: start.Add(time.Hour),
+			Entry: models.LogEntry{Type: kind, Timestamp: &at, Data: data}}
+	}
+	records := []store.Record{
+		record(1, "payment", 2*time.Minute, map[string]interface{}{"sessionId": "s1", "step": "pay"}),
+		record(2, "page", 0, map[string]interface{}{"sessionId": "s1", "step": "open"}),
+		record(3, "page", 0, map[string]interface{}{"sessionId": "s1", "step": "open"}),
+		record(4, "device", 30*time.Minute, map[string]interface{}{"deviceId": "s1"}),
+		record(5, "page", time.Minute, map[string]interface{}{"sessionId": "s2"}),
+	}
+	session := sessions.Reconstruct("s1", []string{"sessionId", "deviceId"}, records, 5*time.Minute)
+
+	var ids []uint64
+	for _, r := range session.Entries {
+		ids = append(ids, r.ID)
+	}
+	assert.Equal(t, []uint64{2, 1, 4}, ids)
+	assert.Equal(t, 1, session.Duplicates)
+	assert.Equal(t, []string{"device", "page", "payment"}, session.Types)
+	if assert.Len(t, session.Gaps, 1) {
+		assert.Equal(t, uint64(1), session.Gaps[0].AfterID)
+		assert.Equal(t, uint64(4), session.Gaps[0].BeforeID)
+		assert.Equal(t, 28*60.0, session.Gaps[0].DurationInSeconds)
+	}
+	assert.Equal(t, start, *session.Start)
+	assert.Equal(t, start.Add(30*time.Minute), *session.End)
+}
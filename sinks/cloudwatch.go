@@ -0,0 +1,365 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+	"github.com/angel-one/nbu-logger-service/utils/sigv4"
+)
+
+// CloudWatchConfig is the configuration of a cloudwatch logs sink
+type CloudWatchConfig struct {
+	// Name identifies the sink
+	Name string `json:"name" mapstructure:"name"`
+	// Region is the aws region of the log group, e.g. ap-south-1
+	Region string `json:"region" mapstructure:"region"`
+	// Endpoint is the url of the cloudwatch logs api, the one of the region when empty
+	Endpoint string `json:"endpoint" mapstructure:"endpoint"`
+	// AccessKeyID, SecretAccessKey and SessionToken are the credentials, the ones of the aws environment variables
+	// when empty
+	AccessKeyID     string `json:"-" mapstructure:"accessKeyId"`
+	SecretAccessKey string `json:"-" mapstructure:"secretAccessKey"`
+	SessionToken    string `json:"-" mapstructure:"sessionToken"`
+	// LogGroup is the log group the entries are shipped to, it has to exist
+	LogGroup string `json:"logGroup" mapstructure:"logGroup"`
+	// LogStream is the log stream template, {type} and {level} are replaced by the ones of the entry. The streams
+	// are created when missing.
+	LogStream string `json:"logStream" mapstructure:"logStream"`
+	// BatchSize is the number of entries of a stream sent in a request, at most 10000
+	BatchSize int `json:"batchSize" mapstructure:"batchSize"`
+	// BufferSize is the number of entries queued for the sink before they are dropped
+	BufferSize int `json:"bufferSize" mapstructure:"bufferSize"`
+	// TimeoutInMillis is the time to wait for a request
+	TimeoutInMillis int `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+	// MaxRetries is the number of times the requests that were throttled or failed on the side of aws are sent again
+	MaxRetries int `json:"maxRetries" mapstructure:"maxRetries"`
+	// BackoffInMillis is the wait before the first retry, it doubles with every retry
+	BackoffInMillis int `json:"backoffInMillis" mapstructure:"backoffInMillis"`
+}
+
+// CloudWatch ships entries to the log streams of a cloudwatch logs group through the PutLogEvents api. The
+// entries are batched by stream within the limits of the api, 10000 events and 1MB a request, and the sequence
+// tokens of the streams are kept from one request to the next.
+type CloudWatch struct {
+	config      CloudWatchConfig
+	credentials sigv4.Credentials
+	pending     map[string]*cloudWatchBatch
+	// tokens are the next sequence tokens by stream
+	tokens map[string]string
+}
+
+// cloudWatchBatch are the events pending for a stream along with their size as counted by the api
+type cloudWatchBatch struct {
+	events []cloudWatchEvent
+	bytes  int
+}
+
+type cloudWatchEvent struct {
+	entry     models.LogEntry
+	timestamp int64
+	message   string
+}
+
+// cloudWatchError is an error the api responded with
+type cloudWatchError struct {
+	Status                int
+	Type                  string `json:"__type"`
+	Message               string `json:"message"`
+	ExpectedSequenceToken string `json:"expectedSequenceToken"`
+}
+
+func (e *cloudWatchError) Error() string {
+	return fmt.Sprintf("cloudwatch responded %d %s : %s", e.Status, e.Type, e.Message)
+}
+
+// throttled is used to check whether the request can be sent again later
+func (e *cloudWatchError) throttled() bool {
+	return e.Type == "ThrottlingException" || e.Type == "ServiceUnavailableException" ||
+		e.Status == http.StatusTooManyRequests || e.Status >= http.StatusInternalServerError
+}
+
+// NewCloudWatch is used to create the sink for the config
+func NewCloudWatch(config CloudWatchConfig) (*CloudWatch, error) {
+	if config.Name == "" || config.Region == "" || config.LogGroup == "" {
+		return nil, fmt.Errorf("cloudwatch sink name, region and log group are required")
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf(constants.DefaultCloudWatchEndpoint, config.Region)
+	}
+	if config.LogStream == "" {
+		config.LogStream = constants.DefaultCloudWatchLogStream
+	}
+	if config.BatchSize <= 0 || config.BatchSize > constants.MaxCloudWatchBatchEvents {
+		config.BatchSize = constants.MaxCloudWatchBatchEvents
+	}
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultSinkTimeoutInMillis
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = constants.DefaultCloudWatchMaxRetries
+	}
+	if config.BackoffInMillis <= 0 {
+		config.BackoffInMillis = constants.DefaultCloudWatchBackoffInMillis
+	}
+	credentials := sigv4.Credentials{AccessKeyID: config.AccessKeyID, SecretAccessKey: config.SecretAccessKey,
+		SessionToken: config.SessionToken}
+	if credentials.AccessKeyID == "" {
+		credentials = sigv4.FromEnv()
+	}
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("cloudwatch sink %s has no aws credentials", config.Name)
+	}
+	return &CloudWatch{config: config, credentials: credentials, pending: make(map[string]*cloudWatchBatch),
+		tokens: make(map[string]string)}, nil
+}
+
+func (c *CloudWatch) Name() string {
+	return c.config.Name
+}
+
+// Stream is used to get the log stream of the entry, without the characters stream names cannot have
+func (c *CloudWatch) Stream(entry models.LogEntry) string {
+	stream := strings.NewReplacer(":", "-", "*", "-").Replace(RoutingKey(c.config.LogStream, entry))
+	if len(stream) > constants.MaxCloudWatchLogStreamLength {
+		stream = stream[:constants.MaxCloudWatchLogStreamLength]
+	}
+	return stream
+}
+
+// Send is used to add the entry to the batch of its stream, the batch is sent first when the entry does not fit
+func (c *CloudWatch) Send(ctx context.Context, entry models.LogEntry) error {
+	message, err := json.Marshal(document(entry))
+	if err != nil {
+		return err
+	}
+	size := len(message) + constants.CloudWatchEventOverheadBytes
+	if size > constants.MaxCloudWatchEventBytes {
+		return fmt.Errorf("entry of %d bytes is over the %d bytes cloudwatch allows an event", size,
+			constants.MaxCloudWatchEventBytes)
+	}
+	stream := c.Stream(entry)
+	batch, ok := c.pending[stream]
+	if !ok {
+		batch = &cloudWatchBatch{}
+		c.pending[stream] = batch
+	}
+	if len(batch.events)+1 > c.config.BatchSize || batch.bytes+size > constants.MaxCloudWatchBatchBytes {
+		err = c.flushStream(ctx, stream)
+		batch = &cloudWatchBatch{}
+		c.pending[stream] = batch
+	}
+	batch.events = append(batch.events, cloudWatchEvent{entry: entry, timestamp: entryTime(entry).UnixMilli(),
+		message: string(message)})
+	batch.bytes += size
+	return err
+}
+
+// Flush is used to send the pending entries of every stream, the entries that could not be shipped are returned
+// in a DeliveryError
+func (c *CloudWatch) Flush(ctx context.Context) error {
+	streams := make([]string, 0, len(c.pending))
+	for stream := range c.pending {
+		streams = append(streams, stream)
+	}
+	sort.Strings(streams)
+	delivery := &DeliveryError{}
+	for _, stream := range streams {
+		var failed *DeliveryError
+		if err := c.flushStream(ctx, stream); errors.As(err, &failed) {
+			delivery.Entries = append(delivery.Entries, failed.Entries...)
+			delivery.Err = failed.Err
+		} else if err != nil {
+			return err
+		}
+	}
+	if len(delivery.Entries) > 0 {
+		return delivery
+	}
+	return nil
+}
+
+// flushStream is used to send the pending entries of the stream, in the order of their time and in requests
+// spanning a day at most as the api requires
+func (c *CloudWatch) flushStream(ctx context.Context, stream string) error {
+	batch := c.pending[stream]
+	delete(c.pending, stream)
+	if batch == nil || len(batch.events) == 0 {
+		return nil
+	}
+	events := batch.events
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].timestamp < events[j].timestamp
+	})
+	span := int64(constants.MaxCloudWatchBatchSpanInHours * time.Hour / time.Millisecond)
+	delivery := &DeliveryError{}
+	for start := 0; start < len(events); {
+		end := start + 1
+		for end < len(events) && events[end].timestamp-events[start].timestamp < span {
+			end++
+		}
+		failed, err := c.put(ctx, stream, events[start:end])
+		if err != nil {
+			delivery.Err = err
+		} else if len(failed) > 0 {
+			delivery.Err = fmt.Errorf("cloudwatch %s rejected %d entries out of the time range it accepts",
+				c.config.Name, len(failed))
+		}
+		for _, event := range failed {
+			delivery.Entries = append(delivery.Entries, event.entry)
+		}
+		start = end
+	}
+	if len(delivery.Entries) > 0 {
+		return delivery
+	}
+	return nil
+}
+
+// put is used to send the events to the stream and get the ones that were not shipped. The stream is created
+// when missing, the sequence token is corrected when stale, and throttled requests are sent again with
+// exponential backoff.
+func (c *CloudWatch) put(ctx context.Context, stream string, events []cloudWatchEvent) ([]cloudWatchEvent, error) {
+	backoff := time.Duration(c.config.BackoffInMillis) * time.Millisecond
+	created := false
+	for attempt := 0; ; attempt++ {
+		rejected, err := c.putLogEvents(stream, events)
+		if err == nil {
+			return rejected, nil
+		}
+		var apiErr *cloudWatchError
+		if errors.As(err, &apiErr) {
+			switch {
+			case apiErr.Type == "DataAlreadyAcceptedException":
+				// the events of a request that timed out were shipped after all
+				c.tokens[stream] = apiErr.ExpectedSequenceToken
+				return nil, nil
+			case apiErr.Type == "InvalidSequenceTokenException" && attempt < c.config.MaxRetries:
+				c.tokens[stream] = apiErr.ExpectedSequenceToken
+				continue
+			case apiErr.Type == "ResourceNotFoundException" && !created:
+				if err = c.createLogStream(stream); err != nil {
+					return events, err
+				}
+				created = true
+				delete(c.tokens, stream)
+				continue
+			case !apiErr.throttled():
+				return events, err
+			}
+		}
+		if attempt >= c.config.MaxRetries {
+			return events, fmt.Errorf("cloudwatch %s failed after %d retries : %w", c.config.Name, attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return events, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// putLogEvents is used to send the events to the stream once and get the ones the api rejected for their time
+func (c *CloudWatch) putLogEvents(stream string, events []cloudWatchEvent) ([]cloudWatchEvent, error) {
+	type logEvent struct {
+		Timestamp int64  `json:"timestamp"`
+		Message   string `json:"message"`
+	}
+	request := struct {
+		LogGroupName  string     `json:"logGroupName"`
+		LogStreamName string     `json:"logStreamName"`
+		LogEvents     []logEvent `json:"logEvents"`
+		SequenceToken string     `json:"sequenceToken,omitempty"`
+	}{LogGroupName: c.config.LogGroup, LogStreamName: stream, SequenceToken: c.tokens[stream]}
+	for _, event := range events {
+		request.LogEvents = append(request.LogEvents, logEvent{Timestamp: event.timestamp, Message: event.message})
+	}
+	var response struct {
+		NextSequenceToken     string `json:"nextSequenceToken"`
+		RejectedLogEventsInfo *struct {
+			TooNewLogEventStartIndex *int `json:"tooNewLogEventStartIndex"`
+			TooOldLogEventEndIndex   *int `json:"tooOldLogEventEndIndex"`
+			ExpiredLogEventEndIndex  *int `json:"expiredLogEventEndIndex"`
+		} `json:"rejectedLogEventsInfo"`
+	}
+	if err := c.call("PutLogEvents", request, &response); err != nil {
+		return nil, err
+	}
+	if response.NextSequenceToken != "" {
+		c.tokens[stream] = response.NextSequenceToken
+	}
+	info := response.RejectedLogEventsInfo
+	if info == nil {
+		return nil, nil
+	}
+	var rejected []cloudWatchEvent
+	for i, event := range events {
+		if (info.TooNewLogEventStartIndex != nil && i >= *info.TooNewLogEventStartIndex) ||
+			(info.TooOldLogEventEndIndex != nil && i <= *info.TooOldLogEventEndIndex) ||
+			(info.ExpiredLogEventEndIndex != nil && i <= *info.ExpiredLogEventEndIndex) {
+			rejected = append(rejected, event)
+		}
+	}
+	return rejected, nil
+}
+
+// createLogStream is used to create the stream in the log group, a stream created meanwhile is fine
+func (c *CloudWatch) createLogStream(stream string) error {
+	err := c.call("CreateLogStream", map[string]string{"logGroupName": c.config.LogGroup, "logStreamName": stream},
+		nil)
+	var apiErr *cloudWatchError
+	if errors.As(err, &apiErr) && apiErr.Type == "ResourceAlreadyExistsException" {
+		return nil
+	}
+	return err
+}
+
+// call is used to send the signed request of the action of the api and decode its response into the result
+func (c *CloudWatch) call(action string, request, result interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"Content-Type": constants.CloudWatchMediaType,
+		"X-Amz-Target": constants.CloudWatchTargetPrefix + action}
+	if err = sigv4.Sign(http.MethodPost, c.config.Endpoint, headers, body, c.credentials, c.config.Region,
+		constants.CloudWatchService, time.Now()); err != nil {
+		return err
+	}
+	response, err := httpclient.POSTWithTimeout(c.config.Endpoint, headers, bytes.NewReader(body),
+		time.Duration(c.config.TimeoutInMillis)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+	if response.StatusCode != http.StatusOK {
+		apiErr := &cloudWatchError{Status: response.StatusCode}
+		_ = json.Unmarshal(data, apiErr)
+		// the types come with the namespace of the service, e.g. com.amazonaws.logs#ThrottlingException
+		if _, name, ok := strings.Cut(apiErr.Type, "#"); ok {
+			apiErr.Type = name
+		}
+		return apiErr
+	}
+	if result == nil || len(data) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("cloudwatch invalid %s response : %w", action, err)
+	}
+	return nil
+}
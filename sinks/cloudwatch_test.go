@@ -0,0 +1,112 @@
+package sinks_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloudWatch(t *testing.T) {
+	type putRequest struct {
+		LogStreamName string `json:"logStreamName"`
+		SequenceToken string `json:"sequenceToken"`
+		LogEvents     []struct {
+			Timestamp int64  `json:"timestamp"`
+			Message   string `json:"message"`
+		} `json:"logEvents"`
+	}
+	var actions []string
+	var puts []putRequest
+	created := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"),
+			"AWS4-HMAC-SHA256 Credential=AKID/"), r.Header.Get("Authorization"))
+		action := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "Logs_20140328.")
+		actions = append(actions, action)
+		fail := func(kind, extra string) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"com.amazonaws.logs#` + kind + `","message":"failed"` + extra + `}`))
+		}
+		if action == "CreateLogStream" {
+			var request map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			created[request["logStreamName"]] = true
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		var request putRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		puts = append(puts, request)
+		switch {
+		case !created[request.LogStreamName]:
+			fail("ResourceNotFoundException", "")
+		case request.SequenceToken == "":
+			fail("InvalidSequenceTokenException", `,"expectedSequenceToken":"1"`)
+		case len(puts) == 3:
+			fail("ThrottlingException", "")
+		case len(puts) == 4:
+			_, _ = w.Write([]byte(`{"nextSequenceToken":"2","rejectedLogEventsInfo":{"tooOldLogEventEndIndex":0}}`))
+		default:
+			_, _ = w.Write([]byte(`{"nextSequenceToken":"3"}`))
+		}
+	}))
+	defer server.Close()
+
+	_, err := sinks.NewCloudWatch(sinks.CloudWatchConfig{Name: "cloudwatch", Region: "ap-south-1"})
+	assert.Error(t, err)
+	sink, err := sinks.NewCloudWatch(sinks.CloudWatchConfig{Name: "cloudwatch", Region: "ap-south-1",
+		Endpoint: server.URL, AccessKeyID: "AKID", SecretAccessKey: "secret", LogGroup: "compliance",
+		LogStream: "{type}:{level}", BatchSize: 2, BackoffInMillis: 1})
+	assert.NoError(t, err)
+	at := func(at string) *models.Timestamp {
+		parsed, _ := time.Parse(time.RFC3339, at)
+		return &models.Timestamp{Time: parsed}
+	}
+	late := models.LogEntry{Type: "payments", Level: "info", Timestamp: at("2024-03-05T10:00:01Z"),
+		Data: map[string]interface{}{"message": "late"}}
+	early := models.LogEntry{Type: "payments", Level: "info", Timestamp: at("2024-03-05T10:00:00Z"),
+		Data: map[string]interface{}{"message": "early"}}
+	assert.Equal(t, "payments-info", sink.Stream(late))
+
+	ctx := context.Background()
+	assert.NoError(t, sink.Send(ctx, late))
+	assert.NoError(t, sink.Send(ctx, early))
+	assert.Empty(t, actions)
+	// the batch is full, so it is sent before the third entry is added
+	err = sink.Send(ctx, models.LogEntry{Type: "payments", Level: "info"})
+	var delivery *sinks.DeliveryError
+	if assert.True(t, errors.As(err, &delivery)) && assert.Len(t, delivery.Entries, 1) {
+		// the api rejected the first event of the request as too old
+		assert.Equal(t, "early", delivery.Entries[0].Data["message"])
+	}
+	assert.Equal(t, []string{"PutLogEvents", "CreateLogStream", "PutLogEvents", "PutLogEvents", "PutLogEvents"},
+		actions)
+	assert.Contains(t, delivery.Error(), "rejected 1 entries")
+	if assert.Len(t, puts, 4) {
+		assert.Equal(t, "1", puts[3].SequenceToken)
+		if assert.Len(t, puts[3].LogEvents, 2) {
+			// the events are sent in the order of their time
+			assert.Contains(t, puts[3].LogEvents[0].Message, `"message":"early"`)
+			assert.Equal(t, int64(1709632800000), puts[3].LogEvents[0].Timestamp)
+		}
+	}
+
+	assert.NoError(t, sink.Flush(ctx))
+	if assert.Len(t, puts, 5) {
+		assert.Equal(t, "2", puts[4].SequenceToken)
+		assert.Len(t, puts[4].LogEvents, 1)
+	}
+
+	// the events over the size cloudwatch allows are not batched
+	assert.Error(t, sink.Send(ctx, models.LogEntry{Type: "payments",
+		Data: map[string]interface{}{"message": strings.Repeat("x", 300<<10)}}))
+}
@@ -0,0 +1,258 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+// ElasticsearchConfig is the configuration of an elasticsearch sink
+type ElasticsearchConfig struct {
+	// Name identifies the sink
+	Name string `json:"name" mapstructure:"name"`
+	// URL is the url of the cluster, e.g. http://elasticsearch:9200
+	URL string `json:"url" mapstructure:"url"`
+	// Username and Password are the optional basic auth credentials
+	Username string `json:"username" mapstructure:"username"`
+	Password string `json:"-" mapstructure:"password"`
+	// APIKey is the optional base64 encoded api key, it takes precedence over basic auth
+	APIKey string `json:"-" mapstructure:"apiKey"`
+	// Index is the index template, {type} and {level} are replaced by the ones of the entry and date patterns
+	// such as {yyyy.MM.dd} by the time of the entry
+	Index string `json:"index" mapstructure:"index"`
+	// BatchSize is the number of entries sent in a bulk request
+	BatchSize int `json:"batchSize" mapstructure:"batchSize"`
+	// BufferSize is the number of entries queued for the sink before they are dropped
+	BufferSize int `json:"bufferSize" mapstructure:"bufferSize"`
+	// TimeoutInMillis is the time to wait for a bulk request
+	TimeoutInMillis int `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+	// MaxRetries is the number of times entries rejected with 429 are sent again
+	MaxRetries int `json:"maxRetries" mapstructure:"maxRetries"`
+	// BackoffInMillis is the wait before the first retry, it doubles with every retry
+	BackoffInMillis int `json:"backoffInMillis" mapstructure:"backoffInMillis"`
+}
+
+var datePattern = regexp.MustCompile(`\{([yMdH][yMdH.\-_]*)\}`)
+
+// dateLayouts are the java style date tokens of the index templates in go layout
+var dateLayouts = strings.NewReplacer("yyyy", "2006", "MM", "01", "dd", "02", "HH", "15")
+
+// Elasticsearch indexes entries through the bulk api. Entries are buffered and sent when the batch is full and
+// on every flush, entries the cluster rejects with 429 are sent again with exponential backoff.
+type Elasticsearch struct {
+	config  ElasticsearchConfig
+	pending []bulkItem
+}
+
+// bulkItem is an entry along with its action and document lines
+type bulkItem struct {
+	entry models.LogEntry
+	lines []byte
+}
+
+// NewElasticsearch is used to create the sink for the config
+func NewElasticsearch(config ElasticsearchConfig) (*Elasticsearch, error) {
+	if config.Name == "" || config.URL == "" {
+		return nil, fmt.Errorf("elasticsearch sink name and url are required")
+	}
+	if config.Index == "" {
+		config.Index = constants.DefaultElasticsearchIndex
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = constants.DefaultElasticsearchBatchSize
+	}
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultSinkTimeoutInMillis
+	}
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	} else if config.MaxRetries == 0 {
+		config.MaxRetries = constants.DefaultElasticsearchMaxRetries
+	}
+	if config.BackoffInMillis <= 0 {
+		config.BackoffInMillis = constants.DefaultElasticsearchBackoffInMillis
+	}
+	return &Elasticsearch{config: config}, nil
+}
+
+func (e *Elasticsearch) Name() string {
+	return e.config.Name
+}
+
+// Index is used to get the index of the entry, date patterns use the time of the entry or now without one
+func (e *Elasticsearch) Index(entry models.LogEntry) string {
+	index := formatDates(RoutingKey(e.config.Index, entry), entryTime(entry))
+	// index names are lower case and cannot have spaces or slashes
+	return strings.ToLower(strings.NewReplacer("/", "-", " ", "-").Replace(index))
+}
+
+// entryTime is used to get the time of the entry in utc, now when it has none
+func entryTime(entry models.LogEntry) time.Time {
+	if entry.Timestamp != nil {
+		return entry.Timestamp.UTC()
+	}
+	if value, ok := entry.Data[constants.TimeField].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return parsed.UTC()
+		}
+	}
+	return time.Now().UTC()
+}
+
+// formatDates is used to replace the date patterns of the template such as {yyyy.MM.dd} with the time
+func formatDates(template string, at time.Time) string {
+	return datePattern.ReplaceAllStringFunc(template, func(pattern string) string {
+		return at.Format(dateLayouts.Replace(strings.Trim(pattern, "{}")))
+	})
+}
+
+// Send is used to add the entry to the batch, the batch is sent when full
+func (e *Elasticsearch) Send(ctx context.Context, entry models.LogEntry) error {
+	action, err := json.Marshal(map[string]interface{}{"create": map[string]string{"_index": e.Index(entry)}})
+	if err != nil {
+		return err
+	}
+	source, err := json.Marshal(document(entry))
+	if err != nil {
+		return err
+	}
+	e.pending = append(e.pending, bulkItem{entry: entry, lines: append(append(action, '\n'), append(source, '\n')...)})
+	if len(e.pending) < e.config.BatchSize {
+		return nil
+	}
+	return e.Flush(ctx)
+}
+
+// document is used to get the entry as the flat document the search engines index, with its data at the top
+func document(entry models.LogEntry) map[string]interface{} {
+	document := map[string]interface{}{constants.TypeField: entry.Type}
+	if entry.Level != "" {
+		document[constants.LevelField] = entry.Level
+	}
+	if entry.TraceID != "" {
+		document[constants.TraceIDField], document[constants.SpanIDField] = entry.TraceID, entry.SpanID
+	}
+	if entry.Encrypted != nil {
+		document[constants.EncryptedField] = entry.Encrypted
+	}
+	for key, value := range entry.Data {
+		if _, ok := document[key]; !ok {
+			document[key] = value
+		}
+	}
+	if entry.Timestamp != nil {
+		document["@timestamp"] = entry.Timestamp
+	} else if at, ok := entry.Data[constants.TimeField]; ok {
+		document["@timestamp"] = at
+	}
+	return document
+}
+
+// Flush is used to send the pending entries, the entries that could not be indexed are returned in a DeliveryError
+func (e *Elasticsearch) Flush(ctx context.Context) error {
+	if len(e.pending) == 0 {
+		return nil
+	}
+	items := e.pending
+	e.pending = nil
+	backoff := time.Duration(e.config.BackoffInMillis) * time.Millisecond
+	delivery := &DeliveryError{}
+	for attempt := 0; ; attempt++ {
+		rejected, failed, err := e.bulk(items)
+		for _, item := range failed {
+			delivery.Entries = append(delivery.Entries, item.entry)
+		}
+		if err != nil {
+			delivery.Err = err
+		}
+		if len(rejected) == 0 {
+			if len(delivery.Entries) == 0 {
+				return nil
+			}
+			return delivery
+		}
+		if attempt == e.config.MaxRetries {
+			for _, item := range rejected {
+				delivery.Entries = append(delivery.Entries, item.entry)
+			}
+			delivery.Err = fmt.Errorf("elasticsearch %s rejected %d entries with 429 after %d retries",
+				e.config.Name, len(rejected), attempt)
+			return delivery
+		}
+		items = rejected
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// bulk is used to send the items and get the ones rejected with 429, the cluster being too busy, and the ones
+// that failed for other reasons, which are not retried and are reported with the error.
+func (e *Elasticsearch) bulk(items []bulkItem) ([]bulkItem, []bulkItem, error) {
+	headers := map[string]string{"Content-Type": constants.NDJSONMediaType}
+	if e.config.APIKey != "" {
+		headers["Authorization"] = "ApiKey " + e.config.APIKey
+	} else if e.config.Username != "" {
+		headers["Authorization"] = "Basic " +
+			base64.StdEncoding.EncodeToString([]byte(e.config.Username+":"+e.config.Password))
+	}
+	body := make([]byte, 0, len(items)*len(items[0].lines))
+	for _, item := range items {
+		body = append(body, item.lines...)
+	}
+	response, err := httpclient.POSTWithTimeout(strings.TrimSuffix(e.config.URL, "/")+"/_bulk", headers,
+		bytes.NewReader(body), time.Duration(e.config.TimeoutInMillis)*time.Millisecond)
+	if err != nil {
+		return nil, items, err
+	}
+	defer response.Body.Close()
+	result, _ := io.ReadAll(io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+	if response.StatusCode == http.StatusTooManyRequests {
+		return items, nil, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, items, fmt.Errorf("elasticsearch responded %d : %s", response.StatusCode, result)
+	}
+	var bulk struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal(result, &bulk); err != nil {
+		return nil, items, fmt.Errorf("elasticsearch invalid bulk response : %w", err)
+	}
+	if !bulk.Errors {
+		return nil, nil, nil
+	}
+	var rejected, failed []bulkItem
+	for i, item := range bulk.Items {
+		if i >= len(items) {
+			break
+		}
+		for _, outcome := range item {
+			if outcome.Status == http.StatusTooManyRequests {
+				rejected = append(rejected, items[i])
+			} else if outcome.Status >= http.StatusMultipleChoices {
+				failed = append(failed, items[i])
+				err = fmt.Errorf("elasticsearch failed to index entry : %d %s", outcome.Status, outcome.Error)
+			}
+		}
+	}
+	return rejected, failed, err
+}
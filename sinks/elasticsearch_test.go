@@ -0,0 +1,60 @@
+package sinks_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestElasticsearchBulk(t *testing.T) {
+	var requests [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/_bulk", r.URL.Path)
+		user, password, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "elastic:secret", user+":"+password)
+		var lines []string
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		requests = append(requests, lines)
+		if len(requests) == 1 {
+			// the second entry is rejected because the cluster is too busy
+			_, _ = w.Write([]byte(`{"errors":true,"items":[{"create":{"status":201}},{"create":{"status":429}}]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errors":false,"items":[{"create":{"status":201}}]}`))
+	}))
+	defer server.Close()
+
+	sink, err := sinks.NewElasticsearch(sinks.ElasticsearchConfig{Name: "search", URL: server.URL,
+		Username: "elastic", Password: "secret", BatchSize: 2, BackoffInMillis: 1})
+	assert.NoError(t, err)
+	first := models.LogEntry{Type: "Payments", Data: map[string]interface{}{"time": "2024-03-05T10:00:00Z"}}
+	assert.Equal(t, "logs-payments-2024.03.05", sink.Index(first))
+
+	ctx := context.Background()
+	second := models.LogEntry{Type: "orders", Level: "warn", Data: map[string]interface{}{
+		"time": "2024-03-06T23:59:59+05:30", "message": "slow"}}
+	assert.NoError(t, sink.Send(ctx, first))
+	assert.Empty(t, requests)
+	assert.NoError(t, sink.Send(ctx, second))
+
+	if assert.Len(t, requests, 2) && assert.Len(t, requests[1], 2) {
+		assert.Len(t, requests[0], 4)
+		assert.JSONEq(t, `{"create":{"_index":"logs-orders-2024.03.06"}}`, requests[1][0])
+		var document map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(requests[1][1]), &document))
+		assert.Equal(t, "warn", document["level"])
+		assert.Equal(t, "slow", document["message"])
+		assert.Equal(t, "2024-03-06T23:59:59+05:30", document["@timestamp"])
+	}
+}
@@ -0,0 +1,180 @@
+package sinks
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/tenants"
+)
+
+// TenantStats are the counters of the entries of a tenant in the buffer of a sink, a growing oldest wait or
+// starved count tells a tenant is not getting its share
+type TenantStats struct {
+	Tenant string `json:"tenant"`
+	Share  int    `json:"share"`
+	Queued int    `json:"queued"`
+	Sent   int64  `json:"sent"`
+	// Dropped are the entries dropped while the buffer was full, the tenants the most over their share first
+	Dropped int64 `json:"dropped"`
+	// Starved are the entries sent after waiting longer than the starvation threshold
+	Starved            int64 `json:"starved"`
+	OldestWaitInMillis int64 `json:"oldestWaitInMillis"`
+	MaxWaitInMillis    int64 `json:"maxWaitInMillis"`
+}
+
+// fairQueue is the buffer of a sink, it keeps the entries of every tenant apart and hands them out by deficit
+// round robin weighted by the shares of the tenants, so the backlog of a tenant can not monopolize the sink.
+// When the buffer is full the oldest entry of the tenant the most over its share is dropped to make room.
+type fairQueue struct {
+	mu       sync.Mutex
+	capacity int
+	size     int
+	tenants  map[string]*tenantQueue
+	order    []string
+	next     int
+	// ready is signalled when entries are pushed
+	ready chan struct{}
+}
+
+type tenantQueue struct {
+	entries []queuedEntry
+	deficit int
+	stats   TenantStats
+}
+
+type queuedEntry struct {
+	entry models.LogEntry
+	at    time.Time
+}
+
+// starvationThreshold is the wait in nanoseconds past which an entry counts as starved
+var starvationThreshold = int64(constants.DefaultStarvationThresholdInMillis * time.Millisecond)
+
+func newFairQueue(capacity int) *fairQueue {
+	return &fairQueue{capacity: capacity, tenants: make(map[string]*tenantQueue), ready: make(chan struct{}, 1)}
+}
+
+// tenantOf is used to get the tenant the entry was ingested for, empty for the entries without one
+func tenantOf(entry *models.LogEntry) string {
+	tenant, _ := pipeline.GetMeta(entry, constants.MetaTenantKey)
+	id, _ := tenant.(string)
+	return id
+}
+
+// push is used to buffer the entry, it is dropped when the buffer is full and its tenant is the most over
+// its share
+func (f *fairQueue) push(entry models.LogEntry, now time.Time) error {
+	tenant := tenantOf(&entry)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tq, ok := f.tenants[tenant]
+	if !ok {
+		tq = &tenantQueue{stats: TenantStats{Tenant: tenant}}
+		f.tenants[tenant] = tq
+		f.order = append(f.order, tenant)
+	}
+	if f.size >= f.capacity {
+		// either way an entry is dropped and never delivered
+		atomic.AddUint64(&undelivered, 1)
+		victim := f.mostOverShare(tenant)
+		if victim == tenant {
+			tq.stats.Dropped++
+			return fmt.Errorf("buffer is full and tenant %q is over its share", tenant)
+		}
+		v := f.tenants[victim]
+		v.entries[0] = queuedEntry{}
+		v.entries = v.entries[1:]
+		v.stats.Dropped++
+		f.size--
+	}
+	tq.entries = append(tq.entries, queuedEntry{entry: entry, at: now})
+	f.size++
+	select {
+	case f.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// len is used to get the number of entries buffered
+func (f *fairQueue) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.size
+}
+
+// mostOverShare is used to get the tenant with the most entries for its share, counting the entry of the
+// tenant being pushed
+func (f *fairQueue) mostOverShare(pushing string) string {
+	most, mostLoad := pushing, float64(len(f.tenants[pushing].entries)+1)/float64(tenants.Share(pushing))
+	for tenant, tq := range f.tenants {
+		if load := float64(len(tq.entries)) / float64(tenants.Share(tenant)); len(tq.entries) > 0 && load > mostLoad {
+			most, mostLoad = tenant, load
+		}
+	}
+	return most
+}
+
+// pop is used to get the next entry, the tenants get as many entries through per round as their share
+func (f *fairQueue) pop(now time.Time) (models.LogEntry, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size == 0 {
+		return models.LogEntry{}, false
+	}
+	for {
+		tenant := f.order[f.next]
+		tq := f.tenants[tenant]
+		if len(tq.entries) == 0 {
+			tq.deficit = 0
+			f.next = (f.next + 1) % len(f.order)
+			continue
+		}
+		if tq.deficit <= 0 {
+			tq.deficit += tenants.Share(tenant)
+		}
+		head := tq.entries[0]
+		tq.entries[0] = queuedEntry{}
+		tq.entries = tq.entries[1:]
+		tq.deficit--
+		f.size--
+		if tq.deficit <= 0 || len(tq.entries) == 0 {
+			f.next = (f.next + 1) % len(f.order)
+		}
+		wait := now.Sub(head.at)
+		tq.stats.Sent++
+		if int64(wait) > atomic.LoadInt64(&starvationThreshold) {
+			tq.stats.Starved++
+		}
+		if millis := wait.Milliseconds(); millis > tq.stats.MaxWaitInMillis {
+			tq.stats.MaxWaitInMillis = millis
+		}
+		return head.entry, true
+	}
+}
+
+// stats is used to get the counters of the tenants, sorted by tenant
+func (f *fairQueue) stats(now time.Time) []TenantStats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stats := make([]TenantStats, 0, len(f.tenants))
+	for tenant, tq := range f.tenants {
+		s := tq.stats
+		s.Share = tenants.Share(tenant)
+		s.Queued = len(tq.entries)
+		if len(tq.entries) > 0 {
+			s.OldestWaitInMillis = now.Sub(tq.entries[0].at).Milliseconds()
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Tenant < stats[j].Tenant
+	})
+	return stats
+}
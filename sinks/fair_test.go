@@ -0,0 +1,77 @@
+package sinks_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/pipeline"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedSink holds the first entry until it is released, so that a backlog builds up
+type gatedSink struct {
+	started chan struct{}
+	release chan struct{}
+	sent    chan string
+}
+
+func (s *gatedSink) Name() string {
+	return "gated"
+}
+
+func (s *gatedSink) Send(_ context.Context, entry models.LogEntry) error {
+	if entry.Type == "first" {
+		close(s.started)
+		<-s.release
+	}
+	tenant, _ := pipeline.GetMeta(&entry, constants.MetaTenantKey)
+	s.sent <- tenant.(string)
+	return nil
+}
+
+func TestFairShare(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sink := &gatedSink{started: make(chan struct{}), release: make(chan struct{}), sent: make(chan string, 10)}
+	sinks.Add(ctx, sink, 0)
+	entryOf := func(tenant, logType string) models.LogEntry {
+		entry := models.LogEntry{Type: logType}
+		pipeline.SetMeta(&entry, constants.MetaTenantKey, tenant)
+		return entry
+	}
+
+	sinks.Emit(ctx, entryOf("noisy", "first"))
+	<-sink.started
+	for i := 0; i < 5; i++ {
+		sinks.Emit(ctx, entryOf("noisy", "app"))
+	}
+	sinks.Emit(ctx, entryOf("quiet", "app"))
+	sinks.Emit(ctx, entryOf("quiet", "app"))
+	close(sink.release)
+
+	var order []string
+	for i := 0; i < 8; i++ {
+		select {
+		case tenant := <-sink.sent:
+			order = append(order, tenant)
+		case <-time.After(2 * time.Second):
+			t.Fatal("entries were not sent")
+		}
+	}
+	// the quiet tenant does not wait for the backlog of the noisy one
+	assert.Equal(t, []string{"noisy", "noisy", "quiet", "noisy", "quiet", "noisy", "noisy", "noisy"}, order)
+
+	stats := sinks.FairShare()["gated"]
+	assert.Len(t, stats, 2)
+	assert.Equal(t, sinks.TenantStats{Tenant: "noisy", Share: 1, Sent: 6}, withoutWaits(stats[0]))
+	assert.Equal(t, sinks.TenantStats{Tenant: "quiet", Share: 1, Sent: 2}, withoutWaits(stats[1]))
+}
+
+func withoutWaits(stats sinks.TenantStats) sinks.TenantStats {
+	stats.MaxWaitInMillis, stats.OldestWaitInMillis = 0, 0
+	return stats
+}
@@ -0,0 +1,128 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// FileConfig is the configuration of a file sink
+type FileConfig struct {
+	// Name identifies the sink
+	Name string `json:"name" mapstructure:"name"`
+	// Path is the path template of the files, {type} and {level} are replaced by the ones of the entry and date
+	// patterns such as {yyyy-MM-dd} by the time of the entry, e.g. /var/log/nbu/{type}-{yyyy-MM-dd}.log
+	Path string `json:"path" mapstructure:"path"`
+	// Format is the line format, json entries by default, cloudevents, logfmt, cef or leef
+	Format string `json:"format" mapstructure:"format"`
+	// Template shapes the lines in the format
+	Template Template `json:"template" mapstructure:"template"`
+	// BufferSize is the number of entries queued for the sink before they are dropped
+	BufferSize int `json:"bufferSize" mapstructure:"bufferSize"`
+}
+
+// pathEscaper keeps the types and levels of the entries from leaving the directory of the path template
+var pathEscaper = strings.NewReplacer("/", "-", `\`, "-", "..", "--", "\x00", "")
+
+// File appends entries to files, one line per entry. Writes are buffered and flushed on every flush, and the
+// files nothing was written to since the previous flush are closed, e.g. the ones of the days that are over.
+type File struct {
+	config  FileConfig
+	encoder *encoder
+	files   map[string]*openFile
+}
+
+// openFile is a file entries are appended to
+type openFile struct {
+	file   *os.File
+	writer *bufio.Writer
+	// written is whether an entry was written since the previous flush
+	written bool
+}
+
+// NewFile is used to create the sink for the config, the files are opened on their first entry
+func NewFile(config FileConfig) (*File, error) {
+	if config.Name == "" || config.Path == "" {
+		return nil, fmt.Errorf("file sink name and path are required")
+	}
+	encoder, err := newEncoder(config.Format, config.Template)
+	if err != nil {
+		return nil, fmt.Errorf("file sink %s %w", config.Name, err)
+	}
+	return &File{config: config, encoder: encoder, files: make(map[string]*openFile)}, nil
+}
+
+func (f *File) Name() string {
+	return f.config.Name
+}
+
+// Path is used to get the path of the file of the entry
+func (f *File) Path(entry models.LogEntry) string {
+	path := strings.NewReplacer("{type}", pathEscaper.Replace(entry.Type),
+		"{level}", pathEscaper.Replace(level(entry))).Replace(f.config.Path)
+	return formatDates(path, entryTime(entry))
+}
+
+// Send is used to append the entry to its file
+func (f *File) Send(_ context.Context, entry models.LogEntry) error {
+	_, line, err := f.encoder.encode(entry)
+	if err != nil {
+		return err
+	}
+	path := f.Path(entry)
+	open, ok := f.files[path]
+	if !ok {
+		if err = os.MkdirAll(filepath.Dir(path), constants.FileDirPermissions); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, constants.FilePermissions)
+		if err != nil {
+			return err
+		}
+		open = &openFile{file: file, writer: bufio.NewWriter(file)}
+		f.files[path] = open
+	}
+	open.written = true
+	if _, err = open.writer.Write(line); err != nil {
+		return err
+	}
+	return open.writer.WriteByte('\n')
+}
+
+// Flush is used to write the buffered lines to the files and close the files nothing was written to since the
+// previous flush
+func (f *File) Flush(context.Context) error {
+	var errs []string
+	for path, open := range f.files {
+		if err := open.writer.Flush(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s : %s", path, err))
+		}
+		if open.written {
+			open.written = false
+			continue
+		}
+		if err := open.file.Close(); err != nil {
+			errs = append(errs, fmt.Sprintf("%s : %s", path, err))
+		}
+		delete(f.files, path)
+	}
+	if len(errs) > 0 {
+		return errors.New("error writing files " + strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// Close is used to write the buffered lines and close the files, once the sink was replaced
+func (f *File) Close() error {
+	for _, open := range f.files {
+		open.written = false
+	}
+	return f.Flush(context.Background())
+}
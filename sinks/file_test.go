@@ -0,0 +1,44 @@
+package sinks_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := sinks.NewFile(sinks.FileConfig{Name: "archive", Path: filepath.Join(dir, "{type}", "{yyyy-MM-dd}.log"),
+		Format: "logfmt", Template: sinks.Template{Fields: []sinks.TemplateField{{Name: "msg", Field: "data.message"}}}})
+	assert.NoError(t, err)
+	ctx := context.Background()
+	day := func(d int) *models.Timestamp {
+		return &models.Timestamp{Time: time.Date(2026, 10, d, 9, 30, 0, 0, time.UTC)}
+	}
+	assert.NoError(t, sink.Send(ctx, models.LogEntry{Type: "app", Timestamp: day(15),
+		Data: map[string]interface{}{"message": "first"}}))
+	assert.NoError(t, sink.Send(ctx, models.LogEntry{Type: "app", Timestamp: day(16),
+		Data: map[string]interface{}{"message": "second line"}}))
+	// the type can not leave the directory
+	assert.NoError(t, sink.Send(ctx, models.LogEntry{Type: "../..", Timestamp: day(16),
+		Data: map[string]interface{}{"message": "escaped"}}))
+	assert.NoError(t, sink.Flush(ctx))
+
+	content, err := os.ReadFile(filepath.Join(dir, "app", "2026-10-15.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, "msg=first\n", string(content))
+	assert.NoError(t, sink.Send(ctx, models.LogEntry{Type: "app", Timestamp: day(16),
+		Data: map[string]interface{}{"message": "third"}}))
+	assert.NoError(t, sink.Close())
+	content, err = os.ReadFile(filepath.Join(dir, "app", "2026-10-16.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, "msg=\"second line\"\nmsg=third\n", string(content))
+	_, err = os.Stat(filepath.Join(dir, "-----", "2026-10-16.log"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,218 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/routing"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+// PulsarConfig is the configuration of an apache pulsar sink
+type PulsarConfig struct {
+	// Name identifies the sink
+	Name string `json:"name" mapstructure:"name"`
+	// URL is the web service url of the cluster, e.g. http://pulsar:8080
+	URL string `json:"url" mapstructure:"url"`
+	// Token is the optional jwt the producer authenticates with
+	Token string `json:"-" mapstructure:"token"`
+	// Tenant and Namespace are where the topics are, unless a topic mapping names its own namespace
+	Tenant    string `json:"tenant" mapstructure:"tenant"`
+	Namespace string `json:"namespace" mapstructure:"namespace"`
+	// Topic is the topic template, {type} and {level} are replaced by the ones of the entry
+	Topic string `json:"topic" mapstructure:"topic"`
+	// Topics are the topics of specific types or globs of types, e.g. payment.*, they take precedence over the
+	// topic. The mapping of the type itself comes before the first glob matching it.
+	Topics []TopicMapping `json:"topics" mapstructure:"topics"`
+	// Key is the message key template, messages are batched by topic and key so that key_shared
+	// subscriptions keep the order of a key
+	Key string `json:"key" mapstructure:"key"`
+	// Format is the body format, json entries by default, cloudevents, logfmt, cef or leef
+	Format string `json:"format" mapstructure:"format"`
+	// Template shapes the body in the format
+	Template Template `json:"template" mapstructure:"template"`
+	// BatchSize is the number of messages of a topic and key sent at once
+	BatchSize int `json:"batchSize" mapstructure:"batchSize"`
+	// BufferSize is the number of entries queued for the sink before they are dropped
+	BufferSize int `json:"bufferSize" mapstructure:"bufferSize"`
+	// TimeoutInMillis is the time to wait for the cluster to store a batch
+	TimeoutInMillis int `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+}
+
+// TopicMapping is the topic template of a type or a glob of types, optionally in another tenant/namespace
+type TopicMapping struct {
+	Type      string `json:"type" mapstructure:"type"`
+	Topic     string `json:"topic" mapstructure:"topic"`
+	Namespace string `json:"namespace" mapstructure:"namespace"`
+}
+
+// pulsarMessage is a message of the rest producer api
+type pulsarMessage struct {
+	Key        string            `json:"key,omitempty"`
+	Payload    string            `json:"payload"`
+	Properties map[string]string `json:"properties,omitempty"`
+	EventTime  string            `json:"eventTime,omitempty"`
+}
+
+type pulsarBatch struct {
+	topic    string
+	messages []pulsarMessage
+	// entries are the entries of the messages, to report the ones that were not published
+	entries []models.LogEntry
+}
+
+// Pulsar produces entries through the rest producer api of the pulsar brokers, batching them by topic and key.
+// Batches are sent when full and on every flush.
+type Pulsar struct {
+	config  PulsarConfig
+	encoder *encoder
+	topics  map[string]TopicMapping
+	batches map[string]*pulsarBatch
+}
+
+// NewPulsar is used to create the sink for the config
+func NewPulsar(config PulsarConfig) (*Pulsar, error) {
+	if config.Name == "" || config.URL == "" || config.Tenant == "" || config.Namespace == "" {
+		return nil, fmt.Errorf("pulsar sink name, url, tenant and namespace are required")
+	}
+	encoder, err := newEncoder(config.Format, config.Template)
+	if err != nil {
+		return nil, fmt.Errorf("pulsar sink %s %w", config.Name, err)
+	}
+	if config.Topic == "" {
+		config.Topic = constants.DefaultPulsarTopic
+	}
+	if config.Key == "" {
+		config.Key = constants.DefaultPulsarKey
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = constants.DefaultPulsarBatchSize
+	}
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultSinkTimeoutInMillis
+	}
+	p := &Pulsar{config: config, encoder: encoder, topics: make(map[string]TopicMapping), batches: make(map[string]*pulsarBatch)}
+	for _, mapping := range config.Topics {
+		p.topics[mapping.Type] = mapping
+	}
+	return p, nil
+}
+
+func (p *Pulsar) Name() string {
+	return p.config.Name
+}
+
+// Topic is used to get the persistent topic of the entry
+func (p *Pulsar) Topic(entry models.LogEntry) string {
+	template, namespace := p.config.Topic, p.config.Tenant+"/"+p.config.Namespace
+	mapping, ok := p.topics[entry.Type]
+	for i := 0; !ok && i < len(p.config.Topics); i++ {
+		mapping = p.config.Topics[i]
+		ok = mapping.Type != "" && routing.MatchType(mapping.Type, entry.Type)
+	}
+	if ok {
+		template = mapping.Topic
+		if mapping.Namespace != "" {
+			namespace = mapping.Namespace
+			if !strings.Contains(namespace, "/") {
+				namespace = p.config.Tenant + "/" + namespace
+			}
+		}
+	}
+	topic := strings.NewReplacer("/", "-", " ", "-").Replace(RoutingKey(template, entry))
+	return namespace + "/" + topic
+}
+
+// Send is used to add the entry to the batch of its topic and key, the batch is sent when full
+func (p *Pulsar) Send(ctx context.Context, entry models.LogEntry) error {
+	_, body, err := p.encoder.encode(entry)
+	if err != nil {
+		return err
+	}
+	topic, key := p.Topic(entry), RoutingKey(p.config.Key, entry)
+	batch, ok := p.batches[topic+"\x00"+key]
+	if !ok {
+		batch = &pulsarBatch{topic: topic}
+		p.batches[topic+"\x00"+key] = batch
+	}
+	message := pulsarMessage{Key: key, Payload: base64.StdEncoding.EncodeToString(body),
+		Properties: map[string]string{constants.TypeField: entry.Type}}
+	if entry.Timestamp != nil {
+		message.EventTime = entry.Timestamp.UTC().Format(time.RFC3339Nano)
+	} else if at, ok := entry.Data[constants.TimeField].(string); ok {
+		message.EventTime = at
+	}
+	batch.messages = append(batch.messages, message)
+	batch.entries = append(batch.entries, entry)
+	if len(batch.messages) < p.config.BatchSize {
+		return nil
+	}
+	delete(p.batches, topic+"\x00"+key)
+	if failed, err := p.send(ctx, batch); err != nil {
+		return &DeliveryError{Entries: failed, Err: err}
+	}
+	return nil
+}
+
+// Flush is used to send all the pending batches
+func (p *Pulsar) Flush(ctx context.Context) error {
+	delivery := &DeliveryError{}
+	for id, batch := range p.batches {
+		delete(p.batches, id)
+		if failed, err := p.send(ctx, batch); err != nil {
+			delivery.Entries, delivery.Err = append(delivery.Entries, failed...), err
+		}
+	}
+	if delivery.Err != nil {
+		return delivery
+	}
+	return nil
+}
+
+// send is used to publish the batch and get the entries that were not published
+func (p *Pulsar) send(ctx context.Context, batch *pulsarBatch) ([]models.LogEntry, error) {
+	body, err := json.Marshal(map[string]interface{}{"producerName": p.config.Name, "messages": batch.messages})
+	if err != nil {
+		return batch.entries, err
+	}
+	headers := map[string]string{"Content-Type": constants.JSONMediaType}
+	if p.config.Token != "" {
+		headers["Authorization"] = "Bearer " + p.config.Token
+	}
+	response, err := httpclient.POSTWithTimeout(strings.TrimSuffix(p.config.URL, "/")+"/topics/persistent/"+batch.topic,
+		headers, bytes.NewReader(body), time.Duration(p.config.TimeoutInMillis)*time.Millisecond)
+	if err != nil {
+		return batch.entries, err
+	}
+	defer response.Body.Close()
+	result, _ := io.ReadAll(io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+	if response.StatusCode != http.StatusOK {
+		return batch.entries, fmt.Errorf("pulsar responded %d for %s : %s", response.StatusCode, batch.topic, result)
+	}
+	var published struct {
+		Results []struct {
+			ErrorCode int    `json:"errorCode"`
+			Error     string `json:"error"`
+		} `json:"messagePublishResults"`
+	}
+	if err = json.Unmarshal(result, &published); err != nil {
+		return batch.entries, fmt.Errorf("pulsar invalid response for %s : %w", batch.topic, err)
+	}
+	var failed []models.LogEntry
+	for i, r := range published.Results {
+		if r.ErrorCode != 0 && i < len(batch.entries) {
+			failed = append(failed, batch.entries[i])
+			err = fmt.Errorf("pulsar failed to publish to %s : %d %s", batch.topic, r.ErrorCode, r.Error)
+		}
+	}
+	return failed, err
+}
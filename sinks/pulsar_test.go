@@ -0,0 +1,53 @@
+package sinks_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPulsarBatches(t *testing.T) {
+	type request struct {
+		path     string
+		messages []map[string]interface{}
+	}
+	requests := make(chan request, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []map[string]interface{} `json:"messages"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		requests <- request{path: r.URL.Path, messages: body.Messages}
+		_, _ = w.Write([]byte(`{"messagePublishResults":[{"errorCode":0}]}`))
+	}))
+	defer server.Close()
+
+	sink, err := sinks.NewPulsar(sinks.PulsarConfig{
+		Name: "platform", URL: server.URL, Token: "token", Tenant: "acme", Namespace: "logs", BatchSize: 2,
+		Topics: []sinks.TopicMapping{{Type: "audit", Topic: "audit-{level}", Namespace: "compliance"}},
+	})
+	assert.NoError(t, err)
+	ctx := context.Background()
+	app := models.LogEntry{Type: "app", Data: map[string]interface{}{"level": "info"}}
+	audit := models.LogEntry{Type: "audit", Data: map[string]interface{}{"level": "warn"}}
+	assert.NoError(t, sink.Send(ctx, app))
+	assert.NoError(t, sink.Send(ctx, audit))
+	assert.NoError(t, sink.Send(ctx, app))
+
+	sent := <-requests
+	assert.Equal(t, "/topics/persistent/acme/logs/app", sent.path)
+	if assert.Len(t, sent.messages, 2) {
+		assert.Equal(t, "app", sent.messages[0]["key"])
+	}
+	assert.NoError(t, sink.Flush(ctx))
+	sent = <-requests
+	assert.Equal(t, "/topics/persistent/acme/compliance/audit-warn", sent.path)
+	assert.Len(t, sent.messages, 1)
+}
@@ -0,0 +1,596 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/dlq"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/routing"
+	"github.com/angel-one/nbu-logger-service/tracing"
+)
+
+// Sink emits processed entries to an external system
+type Sink interface {
+	// Name is used to identify the sink in routing, errors and logs
+	Name() string
+	// Send is used to emit the entry, it is only called from the goroutine of the sink
+	Send(ctx context.Context, entry models.LogEntry) error
+}
+
+// Flusher is implemented by sinks that batch entries, Flush is called periodically from the goroutine of the sink
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// DeliveryError is returned by the sinks batching entries for the entries of the batch they failed to deliver,
+// as they are not the entry being sent
+type DeliveryError struct {
+	Entries []models.LogEntry
+	Err     error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("%d entries were not delivered : %s", len(e.Entries), e.Err)
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// Config is the configuration of the sinks entries are emitted to
+type Config struct {
+	AMQP   []AMQPConfig   `json:"amqp" mapstructure:"amqp"`
+	NATS   []NATSConfig   `json:"nats" mapstructure:"nats"`
+	Pulsar []PulsarConfig `json:"pulsar" mapstructure:"pulsar"`
+	// Elasticsearch are the clusters entries are indexed in
+	Elasticsearch []ElasticsearchConfig `json:"elasticsearch" mapstructure:"elasticsearch"`
+	// CloudWatch are the cloudwatch logs groups entries are shipped to
+	CloudWatch []CloudWatchConfig `json:"cloudWatch" mapstructure:"cloudWatch"`
+	// Webhooks are the http endpoints entries are posted to
+	Webhooks []WebhookConfig `json:"webhooks" mapstructure:"webhooks"`
+	// Files are the files entries are appended to
+	Files []FileConfig `json:"files" mapstructure:"files"`
+	// Routes narrow the entries emitted to the sinks, sinks without a route get every entry
+	Routes []RouteConfig `json:"routes" mapstructure:"routes"`
+	// StarvationThresholdInMillis is the wait in the buffer of a sink past which an entry counts as starved
+	StarvationThresholdInMillis int `json:"starvationThresholdInMillis" mapstructure:"starvationThresholdInMillis"`
+}
+
+// RouteConfig is the routing rule of a sink
+type RouteConfig struct {
+	// Sink is the name of the sink
+	Sink string `json:"sink" mapstructure:"sink"`
+	// Filter is the filter expression of the entries emitted to the sink
+	Filter string `json:"filter" mapstructure:"filter"`
+}
+
+// RetryPolicy is how the entries a sink failed to send are sent again from its goroutine before they are dead
+// lettered. The sinks batching entries retry their batches themselves.
+type RetryPolicy struct {
+	// MaxRetry is used to get the number of retries of the entries of the type
+	MaxRetry func(logType string) int
+	// Delay is used to get the delay of the nth retry, counted from 0
+	Delay func(n int) time.Duration
+}
+
+// retry is an entry waiting to be sent again
+type retry struct {
+	entry   models.LogEntry
+	attempt int
+	at      time.Time
+	err     error
+}
+
+// queued is a sink with the queue decoupling it from ingestion
+type queued struct {
+	sink  Sink
+	queue *fairQueue
+	route *filter.Filter
+	// configured sinks are created from the config and replaced when it is reloaded
+	configured bool
+	// stop is closed to stop the sink once it was replaced
+	stop chan struct{}
+	// latency is the moving average of the durations of the deliveries in nanoseconds, it is only written from
+	// the goroutine of the sink
+	latency int64
+	// failures are the sends and flushes that failed since the last one that succeeded
+	failures  int64
+	lastError atomic.Value
+	// sent is whether entries were sent since the previous flush, only flushes of sent entries tell how long
+	// delivering takes and that the sink recovered. It is only used from the goroutine of the sink.
+	sent bool
+	// retries are the entries waiting to be sent again, they are only used from the goroutine of the sink
+	retries []retry
+}
+
+// Buffer is the fill of the buffer of a sink and how long it takes to deliver
+type Buffer struct {
+	Sink     string `json:"sink"`
+	Queued   int    `json:"queued"`
+	Capacity int    `json:"capacity"`
+	// LatencyInMillis is the moving average of the time the sends, or the flushes of batching sinks, take
+	LatencyInMillis float64 `json:"latencyInMillis"`
+}
+
+// SinkHealth is the state of a sink, for the readiness of the process
+type SinkHealth struct {
+	Name     string `json:"name"`
+	Queued   int    `json:"queued"`
+	Capacity int    `json:"capacity"`
+	// ConsecutiveFailures are the sends and flushes that failed since the last one that succeeded
+	ConsecutiveFailures int64  `json:"consecutiveFailures"`
+	LastError           string `json:"lastError,omitempty"`
+}
+
+var (
+	mu    sync.RWMutex
+	sinks []*queued
+	// undelivered counts the entries dropped on a full buffer or failing to be sent since the start
+	undelivered uint64
+	// policy is the retry policy of the sends, entries are dead lettered right away without one
+	policy RetryPolicy
+)
+
+// Start is used to create the configured sinks and emit to them until the context is done
+func Start(ctx context.Context, config Config) error {
+	return Reload(ctx, config)
+}
+
+// Reload is used to replace the configured sinks with the sinks of the config, e.g. when its file changed.
+// The sinks of the config are all created before any is replaced, so an invalid config keeps the running sinks.
+// The replaced sinks send the entries left in their buffer before they stop.
+func Reload(ctx context.Context, config Config) error {
+	created, err := create(config)
+	if err != nil {
+		return err
+	}
+	routes := make(map[string]*filter.Filter, len(config.Routes))
+	for _, route := range config.Routes {
+		f, err := filter.Parse(route.Filter)
+		if err != nil {
+			return fmt.Errorf("invalid route of sink %s : %w", route.Sink, err)
+		}
+		routes[route.Sink] = f
+	}
+	threshold := time.Duration(constants.DefaultStarvationThresholdInMillis) * time.Millisecond
+	if config.StarvationThresholdInMillis > 0 {
+		threshold = time.Duration(config.StarvationThresholdInMillis) * time.Millisecond
+	}
+	atomic.StoreInt64(&starvationThreshold, int64(threshold))
+	mu.Lock()
+	next := make([]*queued, 0, len(sinks)+len(created))
+	var replaced []*queued
+	for _, q := range sinks {
+		if q.configured {
+			replaced = append(replaced, q)
+		} else {
+			next = append(next, q)
+		}
+	}
+	next = append(next, created...)
+	for name := range routes {
+		if !started(next, name) {
+			mu.Unlock()
+			return fmt.Errorf("sink %s of the route is not started", name)
+		}
+	}
+	for _, q := range next {
+		if f, ok := routes[q.sink.Name()]; ok {
+			q.route = f
+		} else if q.configured {
+			q.route = nil
+		}
+	}
+	sinks = next
+	mu.Unlock()
+	for _, q := range created {
+		run(ctx, q)
+	}
+	for _, q := range replaced {
+		close(q.stop)
+	}
+	return nil
+}
+
+// create is used to create the sinks of the config
+func create(config Config) ([]*queued, error) {
+	var created []*queued
+	add := func(sink Sink, err error, bufferSize int) error {
+		if err != nil {
+			return err
+		}
+		created = append(created, newQueued(sink, bufferSize, true))
+		return nil
+	}
+	for _, c := range config.AMQP {
+		sink, err := NewAMQP(c)
+		if err = add(sink, err, c.BufferSize); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range config.NATS {
+		sink, err := NewNATS(c)
+		if err = add(sink, err, c.BufferSize); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range config.Pulsar {
+		sink, err := NewPulsar(c)
+		if err = add(sink, err, c.BufferSize); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range config.Elasticsearch {
+		sink, err := NewElasticsearch(c)
+		if err = add(sink, err, c.BufferSize); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range config.CloudWatch {
+		sink, err := NewCloudWatch(c)
+		if err = add(sink, err, c.BufferSize); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range config.Webhooks {
+		sink, err := NewWebhook(c)
+		if err = add(sink, err, c.BufferSize); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range config.Files {
+		sink, err := NewFile(c)
+		if err = add(sink, err, c.BufferSize); err != nil {
+			return nil, err
+		}
+	}
+	return created, nil
+}
+
+// started is used to check whether the sink of the name is among the sinks
+func started(sinks []*queued, name string) bool {
+	for _, q := range sinks {
+		if q.sink.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func newQueued(sink Sink, bufferSize int, configured bool) *queued {
+	if bufferSize <= 0 {
+		bufferSize = constants.DefaultSinkBufferSize
+	}
+	return &queued{sink: sink, queue: newFairQueue(bufferSize), configured: configured,
+		stop: make(chan struct{})}
+}
+
+// SetRetryPolicy is used to retry the entries the sinks failed to send with the policy
+func SetRetryPolicy(p RetryPolicy) {
+	mu.Lock()
+	policy = p
+	mu.Unlock()
+}
+
+// Route is used to only emit the entries matching the filter to the sink
+func Route(name string, f *filter.Filter) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, q := range sinks {
+		if q.sink.Name() == name {
+			q.route = f
+			return nil
+		}
+	}
+	return fmt.Errorf("sink %s of the route is not started", name)
+}
+
+// Add is used to emit to the sink from its own goroutine, entries are dropped while its buffer is full
+func Add(ctx context.Context, sink Sink, bufferSize int) {
+	q := newQueued(sink, bufferSize, false)
+	mu.Lock()
+	sinks = append(sinks, q)
+	mu.Unlock()
+	run(ctx, q)
+}
+
+// run is used to send the entries queued for the sink from its own goroutine until the context is done or the
+// sink is stopped
+func run(ctx context.Context, q *queued) {
+	sink := q.sink
+	go func() {
+		flusher, batching := sink.(Flusher)
+		ticker := time.NewTicker(constants.SinkFlushIntervalInMillis * time.Millisecond)
+		defer ticker.Stop()
+		if !batching {
+			ticker.Stop()
+		}
+		// due fires when the earliest retry is due, it is nil while nothing waits to be sent again
+		var timer *time.Timer
+		var due <-chan time.Time
+		schedule := func() {
+			if timer != nil {
+				timer.Stop()
+			}
+			due = nil
+			if next, ok := q.nextRetry(); ok {
+				timer = time.NewTimer(time.Until(next))
+				due = timer.C
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-q.stop:
+				drain(ctx, q)
+				return
+			case <-q.queue.ready:
+				for entry, ok := q.queue.pop(time.Now()); ok; entry, ok = q.queue.pop(time.Now()) {
+					q.send(ctx, entry, 0, batching)
+				}
+				schedule()
+			case <-due:
+				q.resend(ctx, time.Now())
+				schedule()
+			case <-ticker.C:
+				q.flush(ctx, flusher)
+			}
+		}
+	}()
+	log.Info(ctx).Str(constants.SinkKey, sink.Name()).Msg("sink started")
+}
+
+// observe is used to add the duration of a delivery to the moving average of the latency of the sink
+func (q *queued) observe(d time.Duration) {
+	latency := atomic.LoadInt64(&q.latency)
+	if latency == 0 {
+		atomic.StoreInt64(&q.latency, int64(d))
+		return
+	}
+	atomic.StoreInt64(&q.latency, latency+int64(constants.SinkLatencyWeight*float64(int64(d)-latency)))
+}
+
+// drain is used to send the entries left in the buffer of a stopped sink and release it
+func drain(ctx context.Context, q *queued) {
+	// nothing is queued for the sink once it was replaced, so the buffer only shrinks
+	flusher, batching := q.sink.(Flusher)
+	for entry, ok := q.queue.pop(time.Now()); ok; entry, ok = q.queue.pop(time.Now()) {
+		q.send(ctx, entry, 0, batching)
+	}
+	// the entries waiting to be sent again are kept as dead letters, to be requeued once the sink is back
+	for _, r := range q.retries {
+		deadLetter(ctx, q.sink.Name(), &r.entry, r.err)
+	}
+	q.retries = nil
+	if batching {
+		q.flush(ctx, flusher)
+	}
+	if closer, ok := q.sink.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Warn(ctx).Err(err).Str(constants.SinkKey, q.sink.Name()).Msg("error closing sink")
+		}
+	}
+	log.Info(ctx).Str(constants.SinkKey, q.sink.Name()).Msg("sink stopped")
+}
+
+// send is used to send the entry to the sink, the attempt is the number of times it was sent before. Batching
+// sinks only buffer it so how long delivering it takes is told by the flush.
+func (q *queued) send(ctx context.Context, entry models.LogEntry, attempt int, batching bool) {
+	start := time.Now()
+	// the write is a span of the trace the entry was logged in
+	ctx, span := tracing.StartFromEntry(ctx, entry, constants.SinkSpanPrefix+q.sink.Name(), tracing.KindClient)
+	span.SetAttribute(constants.SinkKey, q.sink.Name())
+	err := q.sink.Send(ctx, entry)
+	span.End(err)
+	// batching sinks only buffer the entry, whether they deliver it is told by the flush
+	if err != nil || !batching {
+		q.record(err)
+	}
+	if !batching {
+		q.observe(time.Since(start))
+	}
+	q.sent = true
+	if err == nil {
+		return
+	}
+	if !batching && q.retry(entry, attempt, err, time.Now()) {
+		log.Warn(ctx).Err(err).Str(constants.SinkKey, q.sink.Name()).Int(constants.RetriedKey, attempt).
+			Msg("error emitting entry, it is retried")
+		return
+	}
+	log.Error(ctx).Err(err).Str(constants.SinkKey, q.sink.Name()).Int(constants.RetriedKey, attempt).
+		Msg("error emitting entry")
+	deadLetter(ctx, q.sink.Name(), &entry, err)
+}
+
+// retry is used to schedule the entry to be sent again after the delay of the policy, it is false when the
+// entry is out of retries or too many entries already wait to be sent again
+func (q *queued) retry(entry models.LogEntry, attempt int, err error, now time.Time) bool {
+	mu.RLock()
+	p := policy
+	mu.RUnlock()
+	if p.MaxRetry == nil || p.Delay == nil || attempt >= p.MaxRetry(entry.Type) {
+		return false
+	}
+	if len(q.retries) >= q.queue.capacity {
+		return false
+	}
+	q.retries = append(q.retries, retry{entry: entry, attempt: attempt + 1, at: now.Add(p.Delay(attempt)), err: err})
+	return true
+}
+
+// nextRetry is used to get when the earliest retry is due
+func (q *queued) nextRetry() (time.Time, bool) {
+	var next time.Time
+	for _, r := range q.retries {
+		if next.IsZero() || r.at.Before(next) {
+			next = r.at
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// resend is used to send the entries whose retry is due, the ones failing again are scheduled again
+func (q *queued) resend(ctx context.Context, now time.Time) {
+	pending := q.retries
+	q.retries = nil
+	for _, r := range pending {
+		if r.at.After(now) {
+			q.retries = append(q.retries, r)
+			continue
+		}
+		q.send(ctx, r.entry, r.attempt, false)
+	}
+}
+
+// flush is used to deliver the entries buffered by the batching sink
+func (q *queued) flush(ctx context.Context, flusher Flusher) {
+	start := time.Now()
+	err := flusher.Flush(ctx)
+	if err != nil || q.sent {
+		q.record(err)
+	}
+	if err != nil {
+		log.Error(ctx).Err(err).Str(constants.SinkKey, q.sink.Name()).Msg("error flushing entries")
+		deadLetter(ctx, q.sink.Name(), nil, err)
+	}
+	if q.sent {
+		q.observe(time.Since(start))
+	}
+	q.sent = false
+}
+
+// record is used to count the consecutive failures of the sink
+func (q *queued) record(err error) {
+	if err == nil {
+		atomic.StoreInt64(&q.failures, 0)
+		return
+	}
+	atomic.AddInt64(&q.failures, 1)
+	q.lastError.Store(err.Error())
+}
+
+// Emit is used to queue the entry for every sink its type is routed to without waiting for them
+func Emit(ctx context.Context, entry models.LogEntry) {
+	router := routing.Get()
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, q := range sinks {
+		if !router.Emits(entry.Type, q.sink.Name()) || !q.route.Match(entry) {
+			continue
+		}
+		if err := q.queue.push(entry, time.Now()); err != nil {
+			log.Warn(ctx).Err(err).Str(constants.SinkKey, q.sink.Name()).Msg("sink buffer is full, dropped entry")
+		}
+	}
+}
+
+// deadLetter is used to count and move the entries the sink failed to deliver to the dead letter queue, the ones
+// of the batch for a DeliveryError and the entry that was sent otherwise
+func deadLetter(ctx context.Context, name string, entry *models.LogEntry, err error) {
+	var delivery *DeliveryError
+	if errors.As(err, &delivery) {
+		atomic.AddUint64(&undelivered, uint64(len(delivery.Entries)))
+		for _, failed := range delivery.Entries {
+			dlq.Add(ctx, name, failed, delivery.Err)
+		}
+		return
+	}
+	if entry != nil {
+		atomic.AddUint64(&undelivered, 1)
+		dlq.Add(ctx, name, *entry, err)
+	}
+}
+
+// Requeue is used to queue the entry for the sink again, e.g. a dead letter once the sink recovered
+func Requeue(ctx context.Context, name string, entry models.LogEntry) error {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, q := range sinks {
+		if q.sink.Name() != name {
+			continue
+		}
+		if err := q.queue.push(entry, time.Now()); err != nil {
+			return fmt.Errorf("sink %s %w", name, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("sink %s is not started", name)
+}
+
+// Names is used to get the names of the started sinks
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(sinks))
+	for _, q := range sinks {
+		names = append(names, q.sink.Name())
+	}
+	return names
+}
+
+// Buffers is used to get the fill of the buffers and the latency of the started sinks
+func Buffers() []Buffer {
+	mu.RLock()
+	defer mu.RUnlock()
+	buffers := make([]Buffer, 0, len(sinks))
+	for _, q := range sinks {
+		buffers = append(buffers, Buffer{Sink: q.sink.Name(), Queued: q.queue.len(), Capacity: q.queue.capacity,
+			LatencyInMillis: float64(atomic.LoadInt64(&q.latency)) / float64(time.Millisecond)})
+	}
+	return buffers
+}
+
+// Undelivered is used to get the number of entries dropped on a full buffer or failing to be sent since the start
+func Undelivered() uint64 {
+	return atomic.LoadUint64(&undelivered)
+}
+
+// Health is used to get the state of the started sinks
+func Health() []SinkHealth {
+	mu.RLock()
+	defer mu.RUnlock()
+	health := make([]SinkHealth, 0, len(sinks))
+	for _, q := range sinks {
+		h := SinkHealth{Name: q.sink.Name(), Queued: q.queue.len(), Capacity: q.queue.capacity,
+			ConsecutiveFailures: atomic.LoadInt64(&q.failures)}
+		if h.ConsecutiveFailures > 0 {
+			h.LastError, _ = q.lastError.Load().(string)
+		}
+		health = append(health, h)
+	}
+	return health
+}
+
+// FairShare is used to get the counters of the tenants in the buffer of every sink by its name
+func FairShare() map[string][]TenantStats {
+	mu.RLock()
+	defer mu.RUnlock()
+	now := time.Now()
+	stats := make(map[string][]TenantStats, len(sinks))
+	for _, q := range sinks {
+		stats[q.sink.Name()] = q.queue.stats(now)
+	}
+	return stats
+}
+
+// level is used to get the level of the entry for routing, entries without one are routed as unknown
+func level(entry models.LogEntry) string {
+	if entry.Level != "" {
+		return entry.Level
+	}
+	if value, ok := entry.Data[constants.LevelField]; ok {
+		return fmt.Sprint(value)
+	}
+	return constants.UnknownLevel
+}
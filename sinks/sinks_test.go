@@ -0,0 +1,125 @@
+package sinks_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/dlq"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowSink takes a while to send and fails the entries of the failing type
+type slowSink struct{}
+
+func (s *slowSink) Name() string {
+	return "slow"
+}
+
+func (s *slowSink) Send(_ context.Context, entry models.LogEntry) error {
+	time.Sleep(5 * time.Millisecond)
+	if entry.Type == "failing" {
+		return errors.New("connection reset")
+	}
+	return nil
+}
+
+func TestBuffers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sinks.Add(ctx, &slowSink{}, 10)
+	undelivered := sinks.Undelivered()
+
+	sinks.Emit(ctx, models.LogEntry{Type: "order"})
+	sinks.Emit(ctx, models.LogEntry{Type: "failing"})
+	assert.Eventually(t, func() bool {
+		return sinks.Undelivered() == undelivered+1
+	}, 2*time.Second, 10*time.Millisecond)
+	// the sinks of the other tests are started too
+	var slow *sinks.Buffer
+	buffers := sinks.Buffers()
+	for i := range buffers {
+		if buffers[i].Sink == "slow" {
+			slow = &buffers[i]
+		}
+	}
+	if assert.NotNil(t, slow) {
+		assert.Equal(t, 10, slow.Capacity)
+		assert.GreaterOrEqual(t, slow.LatencyInMillis, 4.0)
+	}
+}
+
+// flakySink fails the first sends of the entries of every type
+type flakySink struct {
+	mu       sync.Mutex
+	failures int
+	sends    map[string]int
+	sent     map[string]bool
+}
+
+func (s *flakySink) Name() string {
+	return "flaky"
+}
+
+func (s *flakySink) Send(_ context.Context, entry models.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sends[entry.Type]++
+	if s.sends[entry.Type] <= s.failures {
+		return errors.New("connection reset")
+	}
+	s.sent[entry.Type] = true
+	return nil
+}
+
+func (s *flakySink) state(logType string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sends[logType], s.sent[logType]
+}
+
+func TestRetry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, dlq.Init(dlq.Config{}))
+	sinks.SetRetryPolicy(sinks.RetryPolicy{
+		MaxRetry: func(logType string) int {
+			if logType == "audit" {
+				return 3
+			}
+			return 1
+		},
+		Delay: func(n int) time.Duration {
+			return time.Duration(n+1) * 5 * time.Millisecond
+		},
+	})
+	defer sinks.SetRetryPolicy(sinks.RetryPolicy{})
+	sink := &flakySink{failures: 3, sends: make(map[string]int), sent: make(map[string]bool)}
+	sinks.Add(ctx, sink, 10)
+
+	// the entries of the types with enough retries make it through the outage
+	sinks.Emit(ctx, models.LogEntry{Type: "audit"})
+	sinks.Emit(ctx, models.LogEntry{Type: "app"})
+	assert.Eventually(t, func() bool {
+		_, sent := sink.state("audit")
+		return sent
+	}, time.Second, 5*time.Millisecond)
+	sends, _ := sink.state("audit")
+	assert.Equal(t, 4, sends)
+
+	// and the others are dead lettered once out of retries
+	var letters []dlq.Letter
+	assert.Eventually(t, func() bool {
+		letters, _ = dlq.Get().List(ctx, dlq.Filter{Sink: "flaky"})
+		return len(letters) == 1
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "app", letters[0].Entry.Type)
+	assert.Equal(t, "connection reset", letters[0].Error)
+	sends, sent := sink.state("app")
+	assert.Equal(t, 2, sends)
+	assert.False(t, sent)
+}
@@ -0,0 +1,474 @@
+package sinks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/formats"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/buildinfo"
+	"github.com/google/uuid"
+)
+
+// Template shapes the output of a sink, so that consumers such as SIEMs get the fields they expect
+type Template struct {
+	// Fields are the fields of the output and the fields of the entries they are taken from, e.g. msg from
+	// data.message. Dots in the names nest the json objects. The output has every field of the entry when empty.
+	Fields []TemplateField `json:"fields" mapstructure:"fields"`
+	// Vendor, Product and Version identify the device in the cef and leef headers
+	Vendor  string `json:"vendor" mapstructure:"vendor"`
+	Product string `json:"product" mapstructure:"product"`
+	Version string `json:"version" mapstructure:"version"`
+	// EventID is the field of the cef signature id and the leef event id, the type by default
+	EventID string `json:"eventId" mapstructure:"eventId"`
+	// EventName is the field of the cef name, the message by default or else the type
+	EventName string `json:"eventName" mapstructure:"eventName"`
+	// Severities override the cef and leef severities of the levels, from 0 to 10 and from 1 to 10 in leef,
+	// e.g. warn: 5
+	Severities map[string]int `json:"severities" mapstructure:"severities"`
+	// SeverityField is the field the entries carry their own severity in, a number or a level. The severity of
+	// the level of the entry is used when they do not.
+	SeverityField string `json:"severityField" mapstructure:"severityField"`
+}
+
+// TemplateField is a field of the output taken from a field of the entries
+type TemplateField struct {
+	Name  string `json:"name" mapstructure:"name"`
+	Field string `json:"field" mapstructure:"field"`
+	// Label is the label of a cef custom field, e.g. Account for cs1, sent as cs1Label
+	Label string `json:"label" mapstructure:"label"`
+}
+
+// severities are the cef and leef severities of the levels
+var severities = map[string]int{
+	constants.DebugLevel: 1, constants.InfoLevel: 3, constants.WarnLevel: 6, constants.ErrorLevel: 8,
+	constants.FatalLevel: 10,
+}
+
+// extensionKey matches the keys of the cef extension and of the leef attributes
+var extensionKey = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// customCEFKey matches the custom keys of the cef extension, which arcsight only maps with their labels
+var customCEFKey = regexp.MustCompile(`^(cs[1-6]|cn[1-3]|cfp[1-4]|c6a[1-4]|deviceCustomDate[12]|flexString[12]|` +
+	`flexNumber[12]|flexDate1)$`)
+
+// cefHeaderEscaper escapes the header fields of cef and leef, which can not span lines
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r", " ", "\n", " ")
+
+// cefEscaper escapes the values of the cef extension
+var cefEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, "\r", `\r`, "\n", `\n`)
+
+// leefEscaper escapes the values of the leef attributes, which are separated by tabs
+var leefEscaper = strings.NewReplacer("\t", `\t`, "\r", `\r`, "\n", `\n`)
+
+// keyReplacer replaces the characters keys of the key value formats can not have
+var keyReplacer = strings.NewReplacer(" ", "_", "=", "_", `"`, "_", "\t", "_", "\n", "_")
+
+// encoder encodes the entries of a sink in its format and template
+type encoder struct {
+	format   string
+	template Template
+	// severities are the severities of the levels, the ones of the template over the default ones
+	severities map[string]int
+}
+
+// newEncoder is used to create the encoder of the format, json entries when empty
+func newEncoder(format string, template Template) (*encoder, error) {
+	switch format {
+	case "", constants.JSONFormat, constants.LogfmtFormat, constants.CEFFormat, constants.LEEFFormat:
+	case constants.CloudEventsFormat:
+		if len(template.Fields) > 0 {
+			return nil, fmt.Errorf("%s format can not have template fields", format)
+		}
+	default:
+		return nil, fmt.Errorf("format %q has to be %s, %s, %s, %s or %s", format, constants.JSONFormat,
+			constants.CloudEventsFormat, constants.LogfmtFormat, constants.CEFFormat, constants.LEEFFormat)
+	}
+	if template.Vendor == "" {
+		template.Vendor = constants.DefaultTemplateVendor
+	}
+	if template.Product == "" {
+		template.Product = constants.DefaultTemplateProduct
+	}
+	if template.Version == "" {
+		template.Version = buildinfo.Get().Version
+	}
+	if template.Version == "" {
+		template.Version = constants.DefaultTemplateVersion
+	}
+	if err := template.validate(format); err != nil {
+		return nil, err
+	}
+	e := &encoder{format: format, template: template, severities: make(map[string]int, len(severities))}
+	for level, severity := range severities {
+		e.severities[level] = severity
+	}
+	for level, severity := range template.Severities {
+		e.severities[strings.ToLower(level)] = severity
+	}
+	return e, nil
+}
+
+// validate is used to check the fields of the template and, in cef and leef, that the events are compliant: the
+// keys are ones the formats allow, the custom cef keys have labels, the severities are in range and the header
+// fields fit in their cef lengths
+func (t Template) validate(format string) error {
+	siem := format == constants.CEFFormat || format == constants.LEEFFormat
+	for _, field := range t.Fields {
+		if field.Name == "" || field.Field == "" {
+			return fmt.Errorf("template field %q needs a name and a field", field.Name)
+		}
+		if field.Label != "" && format != constants.CEFFormat {
+			return fmt.Errorf("template field %s can only have a label in %s", field.Name, constants.CEFFormat)
+		}
+		if !siem {
+			continue
+		}
+		if !extensionKey.MatchString(field.Name) {
+			return fmt.Errorf("template field %q is not a %s key", field.Name, format)
+		}
+		if format == constants.CEFFormat && customCEFKey.MatchString(field.Name) && field.Label == "" {
+			return fmt.Errorf("custom %s field %s needs a label", format, field.Name)
+		}
+	}
+	min := minSeverity(format)
+	for level, severity := range t.Severities {
+		if severity < min || severity > constants.MaxSeverity {
+			return fmt.Errorf("severity %d of level %s has to be between %d and %d", severity, level, min,
+				constants.MaxSeverity)
+		}
+	}
+	if format != constants.CEFFormat {
+		return nil
+	}
+	for _, header := range []struct {
+		name  string
+		value string
+		max   int
+	}{
+		{name: "vendor", value: t.Vendor, max: constants.MaxCEFDeviceLength},
+		{name: "product", value: t.Product, max: constants.MaxCEFDeviceLength},
+		{name: "version", value: t.Version, max: constants.MaxCEFVersionLength},
+	} {
+		if length := len([]rune(header.value)); length > header.max {
+			return fmt.Errorf("%s %s has %d characters, more than the %d of %s", header.name, header.value, length,
+				header.max, format)
+		}
+	}
+	return nil
+}
+
+// minSeverity is used to get the lowest severity of the format, leef severities start at 1
+func minSeverity(format string) int {
+	if format == constants.LEEFFormat {
+		return constants.MinLEEFSeverity
+	}
+	return constants.MinSeverity
+}
+
+// lines is used to check whether the entries are encoded as single lines of text
+func (e *encoder) lines() bool {
+	return e.format == constants.LogfmtFormat || e.format == constants.CEFFormat || e.format == constants.LEEFFormat
+}
+
+// mediaType is used to get the media type of the encoded entries
+func (e *encoder) mediaType() string {
+	switch {
+	case e.format == constants.CloudEventsFormat:
+		return constants.CloudEventsMediaType
+	case e.lines():
+		return constants.TextMediaType
+	}
+	return constants.JSONMediaType
+}
+
+// encode is used to get the media type and the body of the entry
+func (e *encoder) encode(entry models.LogEntry) (string, []byte, error) {
+	switch e.format {
+	case constants.CloudEventsFormat:
+		body, err := json.Marshal(formats.NewCloudEvent(entry, uuid.NewString(), time.Now()))
+		return e.mediaType(), body, err
+	case constants.LogfmtFormat:
+		return e.mediaType(), e.logfmt(entry), nil
+	case constants.CEFFormat:
+		return e.mediaType(), e.cef(entry), nil
+	case constants.LEEFFormat:
+		return e.mediaType(), e.leef(entry), nil
+	}
+	if len(e.template.Fields) == 0 {
+		body, err := json.Marshal(entry)
+		return e.mediaType(), body, err
+	}
+	shaped := make(map[string]interface{}, len(e.template.Fields))
+	for _, field := range e.template.Fields {
+		value, ok := lookupField(entry, field.Field)
+		if !ok {
+			continue
+		}
+		object, path := shaped, strings.Split(field.Name, ".")
+		for _, key := range path[:len(path)-1] {
+			child, ok := object[key].(map[string]interface{})
+			if !ok {
+				child = make(map[string]interface{})
+				object[key] = child
+			}
+			object = child
+		}
+		object[path[len(path)-1]] = value
+	}
+	body, err := json.Marshal(shaped)
+	return e.mediaType(), body, err
+}
+
+// pair is a key and the text of its value
+type pair struct {
+	key   string
+	value string
+}
+
+// pairs are used to get the fields of the template, or else every field of the entry with the nested data
+// flattened into dotted keys
+func (e *encoder) pairs(entry models.LogEntry) []pair {
+	var pairs []pair
+	if len(e.template.Fields) > 0 {
+		for _, field := range e.template.Fields {
+			if value, ok := lookupField(entry, field.Field); ok {
+				pairs = append(pairs, pair{key: keyReplacer.Replace(field.Name), value: text(value)})
+				if field.Label != "" {
+					pairs = append(pairs, pair{key: field.Name + constants.CEFLabelSuffix, value: field.Label})
+				}
+			}
+		}
+		return pairs
+	}
+	if entry.Timestamp != nil {
+		pairs = append(pairs, pair{key: constants.TimeField, value: entry.Timestamp.UTC().Format(time.RFC3339Nano)})
+	}
+	pairs = append(pairs, pair{key: constants.TypeField, value: entry.Type})
+	if entry.Level != "" {
+		pairs = append(pairs, pair{key: constants.LevelField, value: entry.Level})
+	}
+	if entry.TraceID != "" {
+		pairs = append(pairs, pair{key: constants.TraceIDField, value: entry.TraceID},
+			pair{key: constants.SpanIDField, value: entry.SpanID})
+	}
+	labels := make([]string, 0, len(entry.Labels))
+	for name := range entry.Labels {
+		labels = append(labels, name)
+	}
+	sort.Strings(labels)
+	for _, name := range labels {
+		pairs = append(pairs, pair{key: keyReplacer.Replace(constants.LabelsField + "." + name),
+			value: entry.Labels[name]})
+	}
+	return flatten(pairs, "", entry.Data)
+}
+
+// flatten is used to add the fields of the object in the order of their keys, nested objects under dotted keys
+func flatten(pairs []pair, prefix string, object map[string]interface{}) []pair {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if child, ok := object[key].(map[string]interface{}); ok && len(child) > 0 {
+			pairs = flatten(pairs, prefix+key+".", child)
+			continue
+		}
+		pairs = append(pairs, pair{key: keyReplacer.Replace(prefix + key), value: text(object[key])})
+	}
+	return pairs
+}
+
+// logfmt is used to encode the entry as a line of key=value pairs, quoting the values that need it
+func (e *encoder) logfmt(entry models.LogEntry) []byte {
+	var b bytes.Buffer
+	for i, p := range e.pairs(entry) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.key)
+		b.WriteByte('=')
+		if p.value == "" || strings.ContainsAny(p.value, " =\"\\") || strconv.Quote(p.value) != `"`+p.value+`"` {
+			b.WriteString(strconv.Quote(p.value))
+		} else {
+			b.WriteString(p.value)
+		}
+	}
+	return b.Bytes()
+}
+
+// cef is used to encode the entry as an ArcSight common event format line
+func (e *encoder) cef(entry models.LogEntry) []byte {
+	var b bytes.Buffer
+	b.WriteString(constants.CEFVersion)
+	name := entry.Type
+	if value, ok := lookupField(entry, constants.MessageField); ok {
+		name = text(value)
+	}
+	if e.template.EventName != "" {
+		if value, ok := lookupField(entry, e.template.EventName); ok && text(value) != "" {
+			name = text(value)
+		}
+	}
+	eventID := truncate(e.header(entry, e.template.EventID), constants.MaxCEFEventIDLength)
+	for _, field := range []string{e.template.Vendor, e.template.Product, e.template.Version, eventID,
+		truncate(name, constants.MaxCEFNameLength), strconv.Itoa(e.severity(entry))} {
+		b.WriteByte('|')
+		b.WriteString(cefHeaderEscaper.Replace(field))
+	}
+	b.WriteByte('|')
+	pairs := e.pairs(entry)
+	if len(e.template.Fields) == 0 {
+		// the time and the message have standard keys, the other fields are custom ones
+		if entry.Timestamp != nil {
+			pairs[0] = pair{key: constants.CEFTimeKey, value: strconv.FormatInt(entry.Timestamp.UnixMilli(), 10)}
+		}
+		for i := range pairs {
+			if pairs[i].key == constants.MessageField {
+				pairs[i].key = constants.CEFMessageKey
+			}
+		}
+	} else if entry.Timestamp != nil && !hasKey(pairs, constants.CEFTimeKey) {
+		// the receipt time is mandatory to arcsight, it is kept unless the template maps its own
+		pairs = append([]pair{{key: constants.CEFTimeKey, value: strconv.FormatInt(entry.Timestamp.UnixMilli(), 10)}},
+			pairs...)
+	}
+	for i, p := range pairs {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(p.key)
+		b.WriteByte('=')
+		b.WriteString(cefEscaper.Replace(p.value))
+	}
+	return b.Bytes()
+}
+
+// leef is used to encode the entry as an IBM QRadar log event extended format line
+func (e *encoder) leef(entry models.LogEntry) []byte {
+	var b bytes.Buffer
+	b.WriteString(constants.LEEFVersion)
+	for _, field := range []string{e.template.Vendor, e.template.Product, e.template.Version,
+		e.header(entry, e.template.EventID)} {
+		b.WriteByte('|')
+		b.WriteString(cefHeaderEscaper.Replace(field))
+	}
+	b.WriteByte('|')
+	// the severity and the time are kept unless the template maps its own
+	own := e.pairs(entry)
+	var pairs []pair
+	if !hasKey(own, constants.LEEFSeverityKey) {
+		pairs = append(pairs, pair{key: constants.LEEFSeverityKey, value: strconv.Itoa(e.severity(entry))})
+	}
+	if entry.Timestamp != nil && !hasKey(own, constants.LEEFTimeKey) {
+		pairs = append(pairs, pair{key: constants.LEEFTimeKey, value: entry.Timestamp.UTC().Format(constants.LEEFTimeLayout)},
+			pair{key: constants.LEEFTimeFormatKey, value: constants.LEEFTimeFormat})
+	}
+	if len(e.template.Fields) == 0 && entry.Timestamp != nil {
+		// the time of the entry is the devTime
+		own = own[1:]
+	}
+	pairs = append(pairs, own...)
+	for i, p := range pairs {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		b.WriteString(p.key)
+		b.WriteByte('=')
+		b.WriteString(leefEscaper.Replace(p.value))
+	}
+	return b.Bytes()
+}
+
+// header is used to get the text of the field of a header, the type when there is no field or the entry does
+// not have it, as the headers are mandatory
+func (e *encoder) header(entry models.LogEntry, field string) string {
+	if field != "" {
+		if value, ok := lookupField(entry, field); ok && text(value) != "" {
+			return text(value)
+		}
+	}
+	return entry.Type
+}
+
+// severity is used to get the cef and leef severity of the entry, the one it carries in the severity field or
+// else the one of its level
+func (e *encoder) severity(entry models.LogEntry) int {
+	if e.template.SeverityField != "" {
+		if value, ok := lookupField(entry, e.template.SeverityField); ok {
+			if number, err := strconv.ParseFloat(text(value), 64); err == nil {
+				severity := int(math.Round(number))
+				if min := minSeverity(e.format); severity < min {
+					return min
+				}
+				if severity > constants.MaxSeverity {
+					return constants.MaxSeverity
+				}
+				return severity
+			}
+			if s, ok := e.severities[strings.ToLower(text(value))]; ok {
+				return s
+			}
+		}
+	}
+	if s, ok := e.severities[strings.ToLower(level(entry))]; ok {
+		return s
+	}
+	return constants.UnknownSeverity
+}
+
+// hasKey is used to check whether a key is among the pairs
+func hasKey(pairs []pair, key string) bool {
+	for _, p := range pairs {
+		if p.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate is used to cut the text to the characters a header field can have
+func truncate(s string, max int) string {
+	if runes := []rune(s); len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
+
+// lookupField is used to get the value of a field of the entry, its timestamp included
+func lookupField(entry models.LogEntry, field string) (interface{}, bool) {
+	if field == constants.TimestampField {
+		if entry.Timestamp == nil {
+			return nil, false
+		}
+		return entry.Timestamp.UTC().Format(time.RFC3339Nano), true
+	}
+	return filter.Lookup(entry, field)
+}
+
+// text is used to get the text of a value, objects and lists as json
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		body, err := json.Marshal(v)
+		if err == nil {
+			return string(body)
+		}
+	}
+	return fmt.Sprint(value)
+}
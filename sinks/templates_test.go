@@ -0,0 +1,73 @@
+package sinks_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSIEMTemplates(t *testing.T) {
+	at := &models.Timestamp{Time: time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)}
+	login := models.LogEntry{Type: "login", Level: "warn", Timestamp: at, Labels: map[string]string{"env": "prod"},
+		Data: map[string]interface{}{"user": "ravi", "ip": "10.0.0.7", "action": "blocked"}}
+	escalation := models.LogEntry{Type: "sudo", Level: "info", Timestamp: at,
+		Data: map[string]interface{}{"user": "root", "severity": 9.4, "rule": "R-17"}}
+	template := sinks.Template{Vendor: "Angel One", Product: "nbu", Version: "3", EventID: "data.rule",
+		SeverityField: "data.severity", Severities: map[string]int{"WARN": 4}}
+
+	template.Fields = []sinks.TemplateField{{Name: "suser", Field: "data.user"}, {Name: "src", Field: "data.ip"},
+		{Name: "act", Field: "data.action"}, {Name: "cs1", Field: "labels.env", Label: "Environment"}}
+	assert.Equal(t, []string{
+		"CEF:0|Angel One|nbu|3|login|login|4|rt=1792143000000 suser=ravi src=10.0.0.7 act=blocked cs1=prod " +
+			"cs1Label=Environment",
+		"CEF:0|Angel One|nbu|3|R-17|sudo|9|rt=1792143000000 suser=root",
+	}, write(t, "cef", template, login, escalation))
+
+	template.Fields = []sinks.TemplateField{{Name: "usrName", Field: "data.user"}, {Name: "src", Field: "data.ip"}}
+	assert.Equal(t, []string{
+		"LEEF:1.0|Angel One|nbu|3|login|sev=4\tdevTime=Oct 16 2026 09:30:00.000 UTC\t" +
+			"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS zzz\tusrName=ravi\tsrc=10.0.0.7",
+		"LEEF:1.0|Angel One|nbu|3|R-17|sev=9\tdevTime=Oct 16 2026 09:30:00.000 UTC\t" +
+			"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS zzz\tusrName=root",
+	}, write(t, "leef", template, login, escalation))
+
+	for format, invalid := range map[string]sinks.Template{
+		"cef":  {Fields: []sinks.TemplateField{{Name: "cs2", Field: "data.user"}}},
+		"leef": {Fields: []sinks.TemplateField{{Name: "user name", Field: "data.user"}}},
+		"json": {Fields: []sinks.TemplateField{{Name: "user", Field: "data.user", Label: "User"}}},
+	} {
+		_, err := sinks.NewFile(sinks.FileConfig{Name: "siem", Path: "siem.log", Format: format, Template: invalid})
+		assert.Error(t, err, format)
+	}
+	for format, invalid := range map[string]sinks.Template{
+		"cef":  {Severities: map[string]int{"fatal": 11}},
+		"leef": {Severities: map[string]int{"debug": 0}},
+	} {
+		_, err := sinks.NewFile(sinks.FileConfig{Name: "siem", Path: "siem.log", Format: format, Template: invalid})
+		assert.Error(t, err, format)
+	}
+	_, err := sinks.NewFile(sinks.FileConfig{Name: "siem", Path: "siem.log", Format: "cef",
+		Template: sinks.Template{Vendor: strings.Repeat("v", 64)}})
+	assert.Error(t, err)
+}
+
+// write is used to get the lines of the entries in the format and template
+func write(t *testing.T, format string, template sinks.Template, entries ...models.LogEntry) []string {
+	path := filepath.Join(t.TempDir(), "siem.log")
+	sink, err := sinks.NewFile(sinks.FileConfig{Name: "siem", Path: path, Format: format, Template: template})
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		assert.NoError(t, sink.Send(context.Background(), entry))
+	}
+	assert.NoError(t, sink.Close())
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
@@ -0,0 +1,126 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+// WebhookConfig is the configuration of a webhook sink
+type WebhookConfig struct {
+	// Name identifies the sink
+	Name string `json:"name" mapstructure:"name"`
+	// URL is the url the entries are posted to
+	URL string `json:"-" mapstructure:"url"`
+	// Headers are added to the requests, e.g. the authorization of the consumer
+	Headers map[string]string `json:"-" mapstructure:"headers"`
+	// Format is the body format, json entries by default, cloudevents, logfmt, cef or leef
+	Format string `json:"format" mapstructure:"format"`
+	// Template shapes the body in the format
+	Template Template `json:"template" mapstructure:"template"`
+	// BatchSize is the number of entries posted at once, as a json array or as lines of text. A batch size of 1
+	// posts every entry on its own.
+	BatchSize int `json:"batchSize" mapstructure:"batchSize"`
+	// BufferSize is the number of entries queued for the sink before they are dropped
+	BufferSize int `json:"bufferSize" mapstructure:"bufferSize"`
+	// TimeoutInMillis is the time to wait for a request
+	TimeoutInMillis int `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+}
+
+// Webhook posts entries to an http endpoint. Entries are buffered and posted when the batch is full and on
+// every flush, the entries of a failed request are moved to the dead letter queue.
+type Webhook struct {
+	config  WebhookConfig
+	encoder *encoder
+	pending []models.LogEntry
+	bodies  [][]byte
+}
+
+// NewWebhook is used to create the sink for the config
+func NewWebhook(config WebhookConfig) (*Webhook, error) {
+	if config.Name == "" || config.URL == "" {
+		return nil, fmt.Errorf("webhook sink name and url are required")
+	}
+	encoder, err := newEncoder(config.Format, config.Template)
+	if err != nil {
+		return nil, fmt.Errorf("webhook sink %s %w", config.Name, err)
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = constants.DefaultWebhookBatchSize
+	}
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultSinkTimeoutInMillis
+	}
+	return &Webhook{config: config, encoder: encoder}, nil
+}
+
+func (w *Webhook) Name() string {
+	return w.config.Name
+}
+
+// Send is used to add the entry to the batch, the batch is posted when full
+func (w *Webhook) Send(ctx context.Context, entry models.LogEntry) error {
+	_, body, err := w.encoder.encode(entry)
+	if err != nil {
+		return err
+	}
+	w.pending = append(w.pending, entry)
+	w.bodies = append(w.bodies, body)
+	if len(w.pending) < w.config.BatchSize {
+		return nil
+	}
+	return w.Flush(ctx)
+}
+
+// Flush is used to post the pending entries, the entries of a failed request are returned in a DeliveryError
+func (w *Webhook) Flush(ctx context.Context) error {
+	if len(w.pending) == 0 {
+		return nil
+	}
+	entries, bodies := w.pending, w.bodies
+	w.pending, w.bodies = nil, nil
+	if err := w.post(bodies); err != nil {
+		return &DeliveryError{Entries: entries, Err: err}
+	}
+	return nil
+}
+
+// post is used to send the bodies of the entries in a request, lines of text or a json array, or a single body
+// when the batch size is 1
+func (w *Webhook) post(bodies [][]byte) error {
+	var body []byte
+	contentType := w.encoder.mediaType()
+	switch {
+	case w.config.BatchSize == 1:
+		body = bodies[0]
+	case w.encoder.lines():
+		body = append(bytes.Join(bodies, []byte{'\n'}), '\n')
+	default:
+		body = append(append([]byte{'['}, bytes.Join(bodies, []byte{','})...), ']')
+		if w.config.Format == constants.CloudEventsFormat {
+			contentType = constants.CloudEventsBatchMediaType
+		}
+	}
+	headers := map[string]string{"Content-Type": contentType}
+	for name, value := range w.config.Headers {
+		headers[name] = value
+	}
+	response, err := httpclient.POSTWithTimeout(w.config.URL, headers, bytes.NewReader(body),
+		time.Duration(w.config.TimeoutInMillis)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		result, _ := io.ReadAll(io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+		return fmt.Errorf("webhook %s responded %d : %s", w.config.Name, response.StatusCode, result)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package sinks_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/sinks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhookFormats(t *testing.T) {
+	type request struct {
+		contentType string
+		auth        string
+		body        string
+	}
+	requests := make(chan request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{contentType: r.Header.Get("Content-Type"), auth: r.Header.Get("Authorization"),
+			body: string(body)}
+	}))
+	defer server.Close()
+
+	at := &models.Timestamp{Time: time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC)}
+	entry := models.LogEntry{Type: "login", Level: "warn", Timestamp: at, Labels: map[string]string{"env": "prod"},
+		Data: map[string]interface{}{"message": "failed | login", "user": map[string]interface{}{"name": "a=b c"}}}
+	for format, expected := range map[string]string{
+		"logfmt": `time=2026-10-16T09:30:00Z type=login level=warn labels.env=prod message="failed | login" ` +
+			`user.name="a=b c"` + "\n",
+		"cef": `CEF:0|Angel One|siem|2|login|failed \| login|6|rt=1792143000000 type=login level=warn ` +
+			`labels.env=prod msg=failed | login user.name=a\=b c` + "\n",
+		"leef": "LEEF:1.0|Angel One|siem|2|login|sev=6\tdevTime=Oct 16 2026 09:30:00.000 UTC\t" +
+			"devTimeFormat=MMM dd yyyy HH:mm:ss.SSS zzz\ttype=login\tlevel=warn\tlabels.env=prod\t" +
+			"message=failed | login\tuser.name=a=b c\n",
+	} {
+		sink, err := sinks.NewWebhook(sinks.WebhookConfig{Name: "siem", URL: server.URL, Format: format,
+			Headers: map[string]string{"Authorization": "Bearer token"}, BatchSize: 2,
+			Template: sinks.Template{Product: "siem", Version: "2"}})
+		assert.NoError(t, err)
+		assert.NoError(t, sink.Send(context.Background(), entry))
+		assert.NoError(t, sink.Flush(context.Background()))
+		sent := <-requests
+		assert.Equal(t, "text/plain; charset=utf-8", sent.contentType)
+		assert.Equal(t, "Bearer token", sent.auth)
+		assert.Equal(t, expected, sent.body, format)
+	}
+
+	// a json shape posted as an array of the batch
+	sink, err := sinks.NewWebhook(sinks.WebhookConfig{Name: "legacy", URL: server.URL, BatchSize: 2,
+		Template: sinks.Template{Fields: []sinks.TemplateField{
+			{Name: "@timestamp", Field: "timestamp"}, {Name: "event.kind", Field: "type"},
+			{Name: "event.user", Field: "data.user.name"}, {Name: "missing", Field: "data.missing"},
+		}}})
+	assert.NoError(t, err)
+	assert.NoError(t, sink.Send(context.Background(), entry))
+	assert.NoError(t, sink.Send(context.Background(), entry))
+	sent := <-requests
+	assert.Equal(t, "application/json", sent.contentType)
+	shaped := `{"@timestamp":"2026-10-16T09:30:00Z","event":{"kind":"login","user":"a=b c"}}`
+	assert.Equal(t, "["+shaped+","+shaped+"]", sent.body)
+
+	_, err = sinks.NewWebhook(sinks.WebhookConfig{Name: "siem", URL: server.URL, Format: "syslog"})
+	assert.Error(t, err)
+}
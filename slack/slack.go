@@ -0,0 +1,344 @@
+// Package slack serves the slash commands and the interactive messages of the slack app of the service, so the
+// on-call can run the saved searches and the reports, acknowledge the alerts and silence their rules from slack
+// during incidents. The requests are verified with the signing secret of the app.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/alerts"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/reports"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+var (
+	// ErrDisabled is returned when the slack app is not configured
+	ErrDisabled = errors.New(constants.SlackDisabledError)
+	// ErrInvalidSignature is returned for the requests that are not signed by slack, or signed too long ago
+	ErrInvalidSignature = errors.New(constants.InvalidSlackSignatureError)
+)
+
+// Config is the configuration of the slack app
+type Config struct {
+	// SigningSecret is the signing secret of the app, the app is disabled without it
+	SigningSecret string `json:"-" mapstructure:"signingSecret"`
+	// TeamID is the workspace the commands are accepted from, any workspace the app is installed in when empty
+	TeamID string `json:"teamId" mapstructure:"teamId"`
+	// Tenant is the tenant whose entries the searches see, the entries of every tenant when empty
+	Tenant string `json:"tenant" mapstructure:"tenant"`
+	// Searches are the saved searches by name
+	Searches map[string]Search `json:"searches" mapstructure:"searches"`
+	// WindowInMinutes is the time searched back
+	WindowInMinutes int `json:"windowInMinutes" mapstructure:"windowInMinutes"`
+	// MaxResults is the number of entries a search responds with, the latest ones
+	MaxResults int `json:"maxResults" mapstructure:"maxResults"`
+}
+
+// Search is a saved search
+type Search struct {
+	// Filter is the expression of the entries searched for
+	Filter string `json:"filter" mapstructure:"filter"`
+	// Syntax is the syntax of the filter, the filter language by default, logql or lucene
+	Syntax string `json:"syntax" mapstructure:"syntax"`
+}
+
+// Command is a slash command, e.g. /logs search payment-errors
+type Command struct {
+	TeamID   string
+	UserName string
+	// Text is what follows the command
+	Text string
+}
+
+// Response is the message responding to a command or an action
+type Response struct {
+	// ResponseType is ephemeral for the messages only the user sees, in_channel for the ones the channel sees
+	ResponseType    string `json:"response_type"`
+	Text            string `json:"text"`
+	ReplaceOriginal bool   `json:"replace_original"`
+}
+
+// action is the payload of an interactive message, with the buttons clicked
+type action struct {
+	Team struct {
+		ID string `json:"id"`
+	} `json:"team"`
+	User struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string `json:"response_url"`
+}
+
+// app is the configured slack app along with its parsed saved searches
+type app struct {
+	config   Config
+	searches map[string]*filter.Filter
+}
+
+var (
+	mu sync.RWMutex
+	a  = &app{}
+)
+
+// Init is used to validate and set the config of the slack app
+func Init(config Config) error {
+	if config.WindowInMinutes <= 0 {
+		config.WindowInMinutes = constants.DefaultSlackWindowInMinutes
+	}
+	if config.MaxResults <= 0 {
+		config.MaxResults = constants.DefaultSlackMaxResults
+	}
+	next := &app{config: config, searches: make(map[string]*filter.Filter, len(config.Searches))}
+	for name, search := range config.Searches {
+		f, err := parse(search.Syntax, search.Filter)
+		if err != nil {
+			return fmt.Errorf("invalid saved search %s : %w", name, err)
+		}
+		next.searches[name] = f
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	a = next
+	return nil
+}
+
+func get() *app {
+	mu.RLock()
+	defer mu.RUnlock()
+	return a
+}
+
+// Verify is used to check that the body was signed by slack with the signing secret lately, as in the
+// X-Slack-Signature and X-Slack-Request-Timestamp headers
+func Verify(body []byte, timestamp, signature string, now time.Time) error {
+	secret := get().config.SigningSecret
+	if secret == "" {
+		return ErrDisabled
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	// old requests are rejected so that a request that leaked can not be replayed
+	maxAge := constants.MaxSlackRequestAgeInSeconds * time.Second
+	if age := now.Sub(time.Unix(seconds, 0)); age > maxAge || age < -maxAge {
+		return ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, constants.SlackSignaturePrefix))
+	if err != nil || !strings.HasPrefix(signature, constants.SlackSignaturePrefix) {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(constants.SlackSignatureVersion + ":" + timestamp + ":"))
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Tenant is used to get the tenant whose entries the searches see, empty for the entries of every tenant
+func Tenant() string {
+	return get().config.Tenant
+}
+
+// Run is used to run the command and get the response, the errors are responded to the user
+func Run(ctx context.Context, command Command, now time.Time) Response {
+	s := get()
+	if s.config.TeamID != "" && command.TeamID != s.config.TeamID {
+		return ephemeral("this workspace is not allowed to run the commands")
+	}
+	name, args := split(command.Text)
+	switch strings.ToLower(name) {
+	case constants.SlackSearchCommand:
+		return s.search(ctx, args, now)
+	case constants.SlackReportCommand:
+		result, err := reports.Build(ctx, args, now)
+		if err != nil {
+			return ephemeral(err.Error())
+		}
+		return ephemeral(reports.Summary(result))
+	case constants.SlackAckCommand:
+		return ack(args, command.UserName)
+	case constants.SlackSilenceCommand:
+		rule, duration := split(args)
+		return silence(rule, duration, command.UserName)
+	case constants.SlackUnsilenceCommand:
+		if err := alerts.Unsilence(args); err != nil {
+			return ephemeral(err.Error())
+		}
+		return inChannel(fmt.Sprintf("rule %s was unsilenced by %s", args, command.UserName))
+	case constants.SlackSilencesCommand:
+		silences := alerts.Silences()
+		if len(silences) == 0 {
+			return ephemeral("no rule is silenced")
+		}
+		var b strings.Builder
+		for _, s := range silences {
+			fmt.Fprintf(&b, "%s until %s by %s\n", s.Rule, s.Until.UTC().Format(time.RFC3339), s.By)
+		}
+		return ephemeral(strings.TrimSuffix(b.String(), "\n"))
+	}
+	return ephemeral(s.usage())
+}
+
+// Act is used to handle the buttons of the alerts clicked, and get the response along with where to send it
+func Act(payload []byte) (Response, string, error) {
+	var clicked action
+	if err := json.Unmarshal(payload, &clicked); err != nil {
+		return Response{}, "", fmt.Errorf("invalid action payload : %w", err)
+	}
+	s := get()
+	if s.config.TeamID != "" && clicked.Team.ID != s.config.TeamID {
+		return ephemeral("this workspace is not allowed to run the commands"), clicked.ResponseURL, nil
+	}
+	for _, a := range clicked.Actions {
+		switch a.ActionID {
+		case constants.AckAlertAction:
+			return ack(a.Value, clicked.User.Username), clicked.ResponseURL, nil
+		case constants.SilenceRuleAction:
+			return silence(a.Value, constants.DefaultSilenceDuration, clicked.User.Username), clicked.ResponseURL,
+				nil
+		}
+	}
+	return Response{}, "", fmt.Errorf("no known action in the payload")
+}
+
+// Respond is used to send the response to the response url of an action
+func Respond(url string, response Response) error {
+	body, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	r, err := httpclient.POSTWithTimeout(url, map[string]string{"Content-Type": constants.JSONMediaType},
+		bytes.NewReader(body), constants.DefaultSlackTimeoutInMillis*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(r.Body, constants.MaxSinkResponseBytes))
+	if r.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("slack responded %d", r.StatusCode)
+	}
+	return nil
+}
+
+// search is used to get the latest entries of the saved search, or of the filter expression
+func (s *app) search(ctx context.Context, args string, now time.Time) Response {
+	if args == "" {
+		return ephemeral("search needs the name of a saved search or a filter expression")
+	}
+	f, ok := s.searches[args]
+	if !ok {
+		var err error
+		if f, err = parse("", args); err != nil {
+			return ephemeral(fmt.Sprintf("%s is neither a saved search nor a valid filter : %s", args, err))
+		}
+	}
+	window := time.Duration(s.config.WindowInMinutes) * time.Minute
+	records, err := store.Get().Query(ctx, store.Query{Filter: f, Tenant: tenants.Scope(ctx), Axis: store.EventTime,
+		From: now.Add(-window), To: now, Limit: s.config.MaxResults})
+	if err != nil {
+		return ephemeral(fmt.Sprintf("error searching entries : %s", err))
+	}
+	if len(records) == 0 {
+		return ephemeral(fmt.Sprintf("no entries of %s in the last %s", args, window))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "latest %d entries of %s in the last %s\n", len(records), args, window)
+	for _, record := range records {
+		entry := record.Entry
+		fmt.Fprintf(&b, "`%s` %s %s", record.EventTime().UTC().Format(time.RFC3339), entry.Level, entry.Type)
+		if message, ok := entry.Data[constants.MessageField]; ok {
+			fmt.Fprintf(&b, " : %v", message)
+		}
+		b.WriteString("\n")
+	}
+	return ephemeral(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// usage is used to get the help of the commands along with the saved searches
+func (s *app) usage() string {
+	searches := make([]string, 0, len(s.searches))
+	for name := range s.searches {
+		searches = append(searches, name)
+	}
+	sort.Strings(searches)
+	return fmt.Sprintf("commands:\n"+
+		"search <saved search | filter> : the latest entries, saved searches are %s\n"+
+		"report <name> : the report up to now, reports are %s\n"+
+		"ack <alert id> : acknowledge an alert\n"+
+		"silence <rule> [duration] : silence a rule, for %s by default\n"+
+		"unsilence <rule> : let a silenced rule alert again\n"+
+		"silences : the silenced rules", strings.Join(searches, ", "), strings.Join(reports.Names(), ", "),
+		constants.DefaultSilenceDuration)
+}
+
+func ack(id, by string) Response {
+	alert, err := alerts.Ack(id, by)
+	if err != nil {
+		return ephemeral(err.Error())
+	}
+	return inChannel(fmt.Sprintf("alert %s was acknowledged by %s : %s", alert.ID, by, alert.Text))
+}
+
+func silence(rule, duration, by string) Response {
+	if duration == "" {
+		duration = constants.DefaultSilenceDuration
+	}
+	d, err := time.ParseDuration(duration)
+	if err != nil {
+		return ephemeral(fmt.Sprintf("invalid duration %s, e.g. 30m or 2h", duration))
+	}
+	silenced, err := alerts.SilenceRule(rule, d, by)
+	if err != nil {
+		return ephemeral(err.Error())
+	}
+	return inChannel(fmt.Sprintf("rule %s was silenced by %s until %s", rule, by,
+		silenced.Until.UTC().Format(time.RFC3339)))
+}
+
+// parse is used to parse the filter expression in the syntax
+func parse(syntax, expression string) (*filter.Filter, error) {
+	translated, err := filter.Translate(syntax, expression)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Parse(translated)
+}
+
+// split is used to get the first word of the text and the rest of it
+func split(text string) (string, string) {
+	first, rest, _ := strings.Cut(strings.TrimSpace(text), " ")
+	return first, strings.TrimSpace(rest)
+}
+
+func ephemeral(text string) Response {
+	return Response{ResponseType: constants.SlackEphemeral, Text: text}
+}
+
+func inChannel(text string) Response {
+	return Response{ResponseType: constants.SlackInChannel, Text: text}
+}
@@ -0,0 +1,108 @@
+package slack_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/alerts"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/slack"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	assert.ErrorIs(t, slack.Verify([]byte("text=help"), "1", "v0=00", time.Now()), slack.ErrDisabled)
+	assert.NoError(t, slack.Init(slack.Config{SigningSecret: "secret"}))
+	now := time.Now()
+	body := []byte("team_id=T1&user_name=oncall&text=help")
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	signature := "v0=" + hex.EncodeToString(mac.Sum(nil))
+
+	assert.NoError(t, slack.Verify(body, timestamp, signature, now))
+	assert.ErrorIs(t, slack.Verify(append(body, '!'), timestamp, signature, now), slack.ErrInvalidSignature)
+	// a request replayed later is rejected
+	assert.ErrorIs(t, slack.Verify(body, timestamp, signature, now.Add(10*time.Minute)), slack.ErrInvalidSignature)
+	assert.ErrorIs(t, slack.Verify(body, timestamp, signature[3:], now), slack.ErrInvalidSignature)
+}
+
+func TestCommands(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	s := store.NewMemory(100)
+	store.Init(s)
+	at := models.Timestamp{Time: now.Add(-time.Minute)}
+	_, _ = s.Add(ctx, models.LogEntry{Type: "payment", Level: "error", Timestamp: &at,
+		Data: map[string]interface{}{"message": "card declined"}})
+	_, _ = s.Add(ctx, models.LogEntry{Type: "order", Level: "info", Timestamp: &at})
+
+	posted := make(chan map[string]interface{}, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		select {
+		case posted <- body:
+		default:
+		}
+	}))
+	defer webhook.Close()
+	assert.NoError(t, alerts.Init(alerts.Config{URL: webhook.URL, Interactive: true,
+		Rules: []alerts.Rule{{Name: "payment-errors", Filter: "type = payment and level = error"}}}))
+	assert.NoError(t, slack.Init(slack.Config{SigningSecret: "secret", TeamID: "T1",
+		Searches: map[string]slack.Search{"payment-errors": {Filter: `{type="payment"} |= "declined"`, Syntax: "logql"}}}))
+	run := func(text string) slack.Response {
+		return slack.Run(ctx, slack.Command{TeamID: "T1", UserName: "oncall", Text: text}, now)
+	}
+
+	response := run("search payment-errors")
+	assert.Equal(t, "ephemeral", response.ResponseType)
+	assert.Contains(t, response.Text, "error payment : card declined")
+	assert.NotContains(t, response.Text, "order")
+	assert.Contains(t, run("search level = info").Text, "info order")
+	assert.Contains(t, run("search level =").Text, "neither a saved search nor a valid filter")
+	assert.Contains(t, run("").Text, "saved searches are payment-errors")
+	assert.Contains(t, slack.Run(ctx, slack.Command{TeamID: "T2", Text: "help"}, now).Text, "not allowed")
+	// the searches scoped to a tenant only see its entries
+	scoped := tenants.WithScope(ctx, "acme")
+	assert.Contains(t, slack.Run(scoped, slack.Command{TeamID: "T1", Text: "search level = info"}, now).Text, "no entries")
+
+	// the alerts carry the buttons acknowledging them
+	alerts.Evaluate(ctx, models.LogEntry{Type: "payment", Level: "error"})
+	var alert map[string]interface{}
+	select {
+	case alert = <-posted:
+	case <-time.After(time.Second):
+		t.Fatal("the alert was not posted")
+	}
+	blocks := alert["blocks"].([]interface{})
+	button := blocks[2].(map[string]interface{})["elements"].([]interface{})[0].(map[string]interface{})
+	payload, _ := json.Marshal(map[string]interface{}{"team": map[string]string{"id": "T1"},
+		"user":    map[string]string{"username": "oncall"},
+		"actions": []map[string]interface{}{{"action_id": button["action_id"], "value": button["value"]}}})
+	response, _, err := slack.Act(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, "in_channel", response.ResponseType)
+	assert.Contains(t, response.Text, "acknowledged by oncall")
+	assert.Contains(t, run("ack missing").Text, "unknown alert")
+
+	response = run("silence payment-errors 30m")
+	assert.Equal(t, "in_channel", response.ResponseType)
+	assert.Contains(t, response.Text, "silenced by oncall")
+	assert.Contains(t, run("silences").Text, "payment-errors until")
+	assert.Contains(t, run("silence unknown").Text, "unknown alert rule")
+	assert.Contains(t, run("silence payment-errors soon").Text, "invalid duration")
+	assert.Contains(t, run("unsilence payment-errors").Text, "unsilenced")
+	assert.Equal(t, "no rule is silenced", run("silences").Text)
+}
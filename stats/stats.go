@@ -0,0 +1,373 @@
+// Package stats takes snapshots of what the process is doing for a quick triage without grafana: the entries and
+// bytes ingested a second, the latency and the buffer of every sink, the backlog of the ingestion queue, the
+// goroutines and the tenants sending the most. Every instance publishes its snapshots to redis, so that the ones of
+// all the instances can be aggregated from any of them. Without redis the cluster is the process alone.
+package stats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/queue"
+	"github.com/angel-one/nbu-logger-service/sinks"
+)
+
+// Config is the configuration of the snapshots
+type Config struct {
+	// RedisURL is the redis the snapshots are published to, they are only known to the process without it
+	RedisURL string `json:"-" mapstructure:"redisUrl"`
+	// Key is the redis hash of the snapshots by instance
+	Key string `json:"key" mapstructure:"key"`
+	// Instance identifies the snapshots of the process, the hostname when empty
+	Instance string `json:"instance" mapstructure:"instance"`
+	// IntervalInSeconds is the time between snapshots, the rates are averaged over it
+	IntervalInSeconds int `json:"intervalInSeconds" mapstructure:"intervalInSeconds"`
+	// TopTalkers is the number of tenants sending the most kept in a snapshot
+	TopTalkers int `json:"topTalkers" mapstructure:"topTalkers"`
+}
+
+// Sink is the state of the buffer and the latency of a sink
+type Sink struct {
+	Name     string `json:"name"`
+	Queued   int    `json:"queued"`
+	Capacity int    `json:"capacity"`
+	// Occupancy is the fill of the buffer, from 0 to 1
+	Occupancy       float64 `json:"occupancy"`
+	LatencyInMillis float64 `json:"latencyInMillis"`
+}
+
+// Queue is the backlog of the ingestion queue, shared by the instances
+type Queue struct {
+	Enabled bool `json:"enabled"`
+	// Backlog are the entries waiting for a worker, pending, scheduled or to be retried
+	Backlog int `json:"backlog"`
+}
+
+// Talker is what a tenant sent over an interval, the entries without a tenant are counted under an empty one
+type Talker struct {
+	Tenant           string  `json:"tenant"`
+	EntriesPerSecond float64 `json:"entriesPerSecond"`
+	BytesPerSecond   float64 `json:"bytesPerSecond"`
+}
+
+// Snapshot is what an instance was doing over an interval. The entries are the accepted ones, the bytes are the
+// ones of the bodies of the ingestion requests of the http api.
+type Snapshot struct {
+	Instance         string    `json:"instance,omitempty"`
+	TakenAt          time.Time `json:"takenAt"`
+	EntriesPerSecond float64   `json:"entriesPerSecond"`
+	BytesPerSecond   float64   `json:"bytesPerSecond"`
+	Goroutines       int       `json:"goroutines"`
+	Sinks            []Sink    `json:"sinks"`
+	Queue            Queue     `json:"queue"`
+	TopTalkers       []Talker  `json:"topTalkers"`
+}
+
+// Cluster is the aggregate of the latest snapshots of the instances: the rates, the goroutines and the buffers are
+// summed, while the occupancies and the latencies are the ones of the worst instance and the backlog of the shared
+// queue is the latest one
+type Cluster struct {
+	// Instances are the instances whose snapshots were aggregated
+	Instances []string `json:"instances"`
+	Snapshot
+}
+
+// usage is what a tenant sent since the latest snapshot
+type usage struct {
+	entries int64
+	bytes   int64
+}
+
+// Collector counts what the process ingests and takes the snapshots
+type Collector struct {
+	config Config
+	store  Store
+	mu     sync.Mutex
+	// since is the start of the interval of the next snapshot
+	since   time.Time
+	entries int64
+	bytes   int64
+	tenants map[string]*usage
+	latest  Snapshot
+}
+
+var c = New(Config{}, NewMemory(0))
+
+// Init is used to set up the default collector, publishing to redis when configured
+func Init(config Config) error {
+	if config.Key == "" {
+		config.Key = constants.DefaultStatsKey
+	}
+	if config.Instance == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("error getting stats instance : %w", err)
+		}
+		config.Instance = hostname
+	}
+	collector := New(config, nil)
+	s := Store(NewMemory(collector.ttl()))
+	if config.RedisURL != "" {
+		r, err := NewRedis(config.RedisURL, config.Key, collector.ttl())
+		if err != nil {
+			return err
+		}
+		s = r
+	}
+	collector.store = s
+	c = collector
+	return nil
+}
+
+// Get is used to get the default collector
+func Get() *Collector {
+	return c
+}
+
+// New is used to create a collector for the config publishing to the store
+func New(config Config, store Store) *Collector {
+	if config.IntervalInSeconds <= 0 {
+		config.IntervalInSeconds = constants.DefaultStatsIntervalInSeconds
+	}
+	if config.TopTalkers <= 0 {
+		config.TopTalkers = constants.DefaultStatsTopTalkers
+	}
+	return &Collector{config: config, store: store, since: time.Now(), tenants: make(map[string]*usage)}
+}
+
+// Accepted is used to count an entry of the tenant accepted for delivery
+func (c *Collector) Accepted(tenant string) {
+	c.add(tenant, 1, 0)
+}
+
+// Received is used to count the bytes of an ingestion request of the tenant
+func (c *Collector) Received(tenant string, bytes int64) {
+	c.add(tenant, 0, bytes)
+}
+
+func (c *Collector) add(tenant string, entries, bytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries += entries
+	c.bytes += bytes
+	u, ok := c.tenants[tenant]
+	if !ok {
+		if len(c.tenants) >= constants.MaxStatsTenants {
+			tenant = constants.OverflowLabelValue
+		}
+		if u, ok = c.tenants[tenant]; !ok {
+			u = &usage{}
+			c.tenants[tenant] = u
+		}
+	}
+	u.entries += entries
+	u.bytes += bytes
+}
+
+// Take is used to take the snapshot of the interval ending now and start the next one
+func (c *Collector) Take(now time.Time) Snapshot {
+	c.mu.Lock()
+	since, entries, bytes, tenants := c.since, c.entries, c.bytes, c.tenants
+	c.since, c.entries, c.bytes, c.tenants = now, 0, 0, make(map[string]*usage)
+	c.mu.Unlock()
+
+	snapshot := c.snapshot(now, now.Sub(since).Seconds(), entries, bytes, tenants)
+	c.mu.Lock()
+	c.latest = snapshot
+	c.mu.Unlock()
+	return snapshot
+}
+
+// Latest is used to get the latest snapshot without taking one, so that reading it does not cut the interval
+// being counted short. Before the first snapshot, the interval so far is returned.
+func (c *Collector) Latest() Snapshot {
+	c.mu.Lock()
+	latest := c.latest
+	if !latest.TakenAt.IsZero() {
+		c.mu.Unlock()
+		return latest
+	}
+	now := time.Now()
+	elapsed, entries, bytes := now.Sub(c.since).Seconds(), c.entries, c.bytes
+	tenants := make(map[string]*usage, len(c.tenants))
+	for tenant, u := range c.tenants {
+		tenants[tenant] = &usage{entries: u.entries, bytes: u.bytes}
+	}
+	c.mu.Unlock()
+	return c.snapshot(now, elapsed, entries, bytes, tenants)
+}
+
+// Publish is used to take the snapshot of the interval ending now and publish it to the other instances
+func (c *Collector) Publish(ctx context.Context, now time.Time) error {
+	if err := c.store.Put(ctx, c.Take(now)); err != nil {
+		return fmt.Errorf("error publishing stats : %w", err)
+	}
+	return nil
+}
+
+// Start is used to publish a snapshot on every interval until the context is done
+func (c *Collector) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(time.Duration(c.config.IntervalInSeconds) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if err := c.Publish(ctx, now); err != nil {
+					log.Warn(ctx).Err(err).Msg("error publishing stats snapshot")
+				}
+			}
+		}
+	}()
+}
+
+// Cluster is used to aggregate the latest snapshots of the instances, the latest one of the process stands in for
+// its published one until there is one
+func (c *Collector) Cluster(ctx context.Context) (Cluster, error) {
+	snapshots, err := c.store.List(ctx)
+	if err != nil {
+		return Cluster{}, fmt.Errorf("error listing stats : %w", err)
+	}
+	published := false
+	for _, snapshot := range snapshots {
+		if snapshot.Instance == c.config.Instance {
+			published = true
+		}
+	}
+	if !published {
+		snapshots = append(snapshots, c.Latest())
+	}
+	return aggregate(snapshots, c.config.TopTalkers), nil
+}
+
+// snapshot is used to build the snapshot of the counts of an interval of the elapsed seconds ending now
+func (c *Collector) snapshot(now time.Time, elapsed float64, entries, bytes int64,
+	tenants map[string]*usage) Snapshot {
+	snapshot := Snapshot{
+		Instance:   c.config.Instance,
+		TakenAt:    now.UTC(),
+		Goroutines: runtime.NumGoroutine(),
+		Sinks:      sinkStats(),
+		Queue:      queueStats(),
+		TopTalkers: make([]Talker, 0, len(tenants)),
+	}
+	if elapsed > 0 {
+		snapshot.EntriesPerSecond = float64(entries) / elapsed
+		snapshot.BytesPerSecond = float64(bytes) / elapsed
+		for tenant, u := range tenants {
+			snapshot.TopTalkers = append(snapshot.TopTalkers, Talker{Tenant: tenant,
+				EntriesPerSecond: float64(u.entries) / elapsed, BytesPerSecond: float64(u.bytes) / elapsed})
+		}
+	}
+	snapshot.TopTalkers = top(snapshot.TopTalkers, c.config.TopTalkers)
+	return snapshot
+}
+
+// ttl is used to get how long the snapshot of an instance is kept, missing a few intervals means it is gone
+func (c *Collector) ttl() time.Duration {
+	return time.Duration(constants.StatsStaleIntervals*c.config.IntervalInSeconds) * time.Second
+}
+
+// aggregate is used to sum the snapshots of the instances
+func aggregate(snapshots []Snapshot, topTalkers int) Cluster {
+	cluster := Cluster{Instances: make([]string, 0, len(snapshots))}
+	cluster.Sinks = make([]Sink, 0)
+	sinksByName := make(map[string]int)
+	talkers := make(map[string]*Talker)
+	var queueAt time.Time
+	for _, snapshot := range snapshots {
+		cluster.Instances = append(cluster.Instances, snapshot.Instance)
+		if snapshot.TakenAt.After(cluster.TakenAt) {
+			cluster.TakenAt = snapshot.TakenAt
+		}
+		cluster.EntriesPerSecond += snapshot.EntriesPerSecond
+		cluster.BytesPerSecond += snapshot.BytesPerSecond
+		cluster.Goroutines += snapshot.Goroutines
+		for _, sink := range snapshot.Sinks {
+			i, ok := sinksByName[sink.Name]
+			if !ok {
+				sinksByName[sink.Name] = len(cluster.Sinks)
+				cluster.Sinks = append(cluster.Sinks, sink)
+				continue
+			}
+			merged := &cluster.Sinks[i]
+			merged.Queued += sink.Queued
+			merged.Capacity += sink.Capacity
+			merged.Occupancy = max(merged.Occupancy, sink.Occupancy)
+			merged.LatencyInMillis = max(merged.LatencyInMillis, sink.LatencyInMillis)
+		}
+		// the queue is shared, so every instance sees the same backlog and the latest one is kept
+		if snapshot.Queue.Enabled && snapshot.TakenAt.After(queueAt) {
+			cluster.Queue, queueAt = snapshot.Queue, snapshot.TakenAt
+		}
+		for _, talker := range snapshot.TopTalkers {
+			t, ok := talkers[talker.Tenant]
+			if !ok {
+				t = &Talker{Tenant: talker.Tenant}
+				talkers[talker.Tenant] = t
+			}
+			t.EntriesPerSecond += talker.EntriesPerSecond
+			t.BytesPerSecond += talker.BytesPerSecond
+		}
+	}
+	sort.Strings(cluster.Instances)
+	cluster.TopTalkers = make([]Talker, 0, len(talkers))
+	for _, talker := range talkers {
+		cluster.TopTalkers = append(cluster.TopTalkers, *talker)
+	}
+	cluster.TopTalkers = top(cluster.TopTalkers, topTalkers)
+	return cluster
+}
+
+// top is used to keep the n tenants sending the most entries, then the most bytes
+func top(talkers []Talker, n int) []Talker {
+	sort.Slice(talkers, func(i, j int) bool {
+		if talkers[i].EntriesPerSecond != talkers[j].EntriesPerSecond {
+			return talkers[i].EntriesPerSecond > talkers[j].EntriesPerSecond
+		}
+		if talkers[i].BytesPerSecond != talkers[j].BytesPerSecond {
+			return talkers[i].BytesPerSecond > talkers[j].BytesPerSecond
+		}
+		return talkers[i].Tenant < talkers[j].Tenant
+	})
+	if len(talkers) > n {
+		talkers = talkers[:n]
+	}
+	return talkers
+}
+
+// sinkStats is used to get the buffer and the latency of every sink
+func sinkStats() []Sink {
+	buffers := sinks.Buffers()
+	stats := make([]Sink, 0, len(buffers))
+	for _, b := range buffers {
+		sink := Sink{Name: b.Sink, Queued: b.Queued, Capacity: b.Capacity, LatencyInMillis: b.LatencyInMillis}
+		if b.Capacity > 0 {
+			sink.Occupancy = float64(b.Queued) / float64(b.Capacity)
+		}
+		stats = append(stats, sink)
+	}
+	return stats
+}
+
+// queueStats is used to get the backlog of the ingestion queue, it is left out when redis can not be read
+func queueStats() Queue {
+	if !queue.Enabled() {
+		return Queue{}
+	}
+	backlog, err := queue.Backlog()
+	if err != nil {
+		log.Warn(context.Background()).Err(err).Msg("error reading the ingestion queue backlog")
+		return Queue{}
+	}
+	return Queue{Enabled: true, Backlog: backlog}
+}
@@ -0,0 +1,89 @@
+package stats_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshots(t *testing.T) {
+	ctx := context.Background()
+	store := stats.NewMemory(30 * time.Second)
+	first := stats.New(stats.Config{Instance: "mumbai-1", TopTalkers: 2}, store)
+	second := stats.New(stats.Config{Instance: "mumbai-2", TopTalkers: 2}, store)
+
+	// reading the latest snapshot before the first one does not cut the interval short
+	first.Accepted("tenant-a")
+	peek := first.Latest()
+	assert.Equal(t, "mumbai-1", peek.Instance)
+	assert.Equal(t, "tenant-a", peek.TopTalkers[0].Tenant)
+	assert.Equal(t, "tenant-a", first.Latest().TopTalkers[0].Tenant)
+
+	// the rates are averaged over the interval since the previous snapshot
+	start := time.Now()
+	first.Take(start)
+	for i := 0; i < 30; i++ {
+		first.Accepted("tenant-a")
+	}
+	for i := 0; i < 10; i++ {
+		first.Accepted("tenant-b")
+	}
+	first.Accepted("")
+	first.Received("tenant-a", 3000)
+	first.Received("tenant-b", 1000)
+	snapshot := first.Take(start.Add(10 * time.Second))
+	assert.Equal(t, "mumbai-1", snapshot.Instance)
+	assert.InDelta(t, 4.1, snapshot.EntriesPerSecond, 0.001)
+	assert.InDelta(t, 400, snapshot.BytesPerSecond, 0.001)
+	assert.Positive(t, snapshot.Goroutines)
+	assert.Equal(t, []stats.Talker{
+		{Tenant: "tenant-a", EntriesPerSecond: 3, BytesPerSecond: 300},
+		{Tenant: "tenant-b", EntriesPerSecond: 1, BytesPerSecond: 100},
+	}, snapshot.TopTalkers)
+	assert.Equal(t, snapshot, first.Latest())
+
+	// the next interval starts empty
+	empty := first.Take(start.Add(20 * time.Second))
+	assert.Zero(t, empty.EntriesPerSecond)
+	assert.Empty(t, empty.TopTalkers)
+
+	// the snapshot of the process stands in for the published one until there is one
+	cluster, err := first.Cluster(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mumbai-1"}, cluster.Instances)
+
+	// the snapshots of the instances are summed, the tenants across them
+	now := time.Now()
+	second.Take(now.Add(-10 * time.Second))
+	for i := 0; i < 20; i++ {
+		second.Accepted("tenant-b")
+	}
+	second.Accepted("tenant-c")
+	assert.NoError(t, second.Publish(ctx, now))
+	first.Take(now.Add(-10 * time.Second))
+	for i := 0; i < 30; i++ {
+		first.Accepted("tenant-a")
+	}
+	first.Received("tenant-a", 5000)
+	assert.NoError(t, first.Publish(ctx, now))
+	cluster, err = second.Cluster(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mumbai-1", "mumbai-2"}, cluster.Instances)
+	assert.InDelta(t, 5.1, cluster.EntriesPerSecond, 0.001)
+	assert.InDelta(t, 500, cluster.BytesPerSecond, 0.001)
+	assert.Equal(t, []stats.Talker{
+		{Tenant: "tenant-a", EntriesPerSecond: 3, BytesPerSecond: 500},
+		{Tenant: "tenant-b", EntriesPerSecond: 2},
+	}, cluster.TopTalkers)
+
+	// the snapshots of the instances that stopped publishing are dropped
+	assert.NoError(t, store.Put(ctx, stats.Snapshot{Instance: "mumbai-3", TakenAt: now.Add(-time.Minute),
+		EntriesPerSecond: 100}))
+	cluster, err = second.Cluster(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mumbai-1", "mumbai-2"}, cluster.Instances)
+	assert.InDelta(t, 5.1, cluster.EntriesPerSecond, 0.001)
+}
@@ -0,0 +1,114 @@
+package stats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Store keeps the latest snapshot of every instance until it is stale
+type Store interface {
+	// Put is used to replace the snapshot of its instance
+	Put(ctx context.Context, snapshot Snapshot) error
+	// List is used to get the snapshots that are not stale
+	List(ctx context.Context) ([]Snapshot, error)
+}
+
+// Memory keeps the snapshots in the process, for a single instance
+type Memory struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	snapshots map[string]Snapshot
+}
+
+// NewMemory is used to create an empty in memory store whose snapshots are stale after the ttl, never when it
+// is 0
+func NewMemory(ttl time.Duration) *Memory {
+	return &Memory{ttl: ttl, snapshots: make(map[string]Snapshot)}
+}
+
+func (m *Memory) Put(_ context.Context, snapshot Snapshot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.snapshots[snapshot.Instance] = snapshot
+	return nil
+}
+
+func (m *Memory) List(_ context.Context) ([]Snapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	snapshots := make([]Snapshot, 0, len(m.snapshots))
+	for instance, snapshot := range m.snapshots {
+		if stale(snapshot, m.ttl, now) {
+			delete(m.snapshots, instance)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// Redis keeps the snapshots in a redis hash by instance, shared by the instances
+type Redis struct {
+	client *redis.Client
+	key    string
+	ttl    time.Duration
+}
+
+// NewRedis is used to create a store of the snapshots in the hash of the key
+func NewRedis(url, key string, ttl time.Duration) (*Redis, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid stats redis url : %w", err)
+	}
+	return &Redis{client: redis.NewClient(options), key: key, ttl: ttl}, nil
+}
+
+// Put is used to set the snapshot of the instance, the hash expires once every instance stopped publishing
+func (r *Redis) Put(ctx context.Context, snapshot Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, r.key, snapshot.Instance, data)
+		pipe.Expire(ctx, r.key, r.ttl)
+		return nil
+	})
+	return err
+}
+
+// List is used to get the snapshots that are not stale, the stale ones of the instances that are gone are dropped
+func (r *Redis) List(ctx context.Context) ([]Snapshot, error) {
+	values, err := r.client.HGetAll(ctx, r.key).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	snapshots := make([]Snapshot, 0, len(values))
+	var gone []string
+	for instance, value := range values {
+		var snapshot Snapshot
+		if err = json.Unmarshal([]byte(value), &snapshot); err != nil || stale(snapshot, r.ttl, now) {
+			gone = append(gone, instance)
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	if len(gone) > 0 {
+		if err = r.client.HDel(ctx, r.key, gone...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return snapshots, nil
+}
+
+// stale is used to check whether the snapshot is older than the ttl
+func stale(snapshot Snapshot, ttl time.Duration, now time.Time) bool {
+	return ttl > 0 && now.Sub(snapshot.TakenAt) > ttl
+}
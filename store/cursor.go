@@ -0,0 +1,67 @@
+package store
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cursor is the position of the last record of a page, the next page starts after it
+type Cursor struct {
+	// At is the time of the record on the time axis of the query
+	At time.Time
+	// ID breaks the ties between the records of the same time
+	ID uint64
+}
+
+// NextCursor is used to get the cursor after the last record of the page, nil when the page is not full as there
+// is nothing after it
+func NextCursor(records []Record, query Query) *Cursor {
+	if query.Limit <= 0 || len(records) < query.Limit {
+		return nil
+	}
+	last := records[len(records)-1]
+	return &Cursor{At: at(last, query.Axis), ID: last.ID}
+}
+
+// ParseCursor is used to parse the token of a cursor
+func ParseCursor(token string) (*Cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor : %w", err)
+	}
+	parts := strings.Split(string(decoded), ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor time : %w", err)
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor id : %w", err)
+	}
+	return &Cursor{At: time.Unix(0, nanos), ID: id}, nil
+}
+
+// String is used to get the opaque token of the cursor
+func (c Cursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.At.UnixNano(), c.ID)))
+}
+
+// precedes is used to check whether the record comes after the cursor, newest first
+func (c Cursor) precedes(record Record, axis TimeAxis) bool {
+	t := at(record, axis)
+	return t.Before(c.At) || t.Equal(c.At) && record.ID < c.ID
+}
+
+// at is used to get the time of the record on the axis
+func at(record Record, axis TimeAxis) time.Time {
+	if axis == EventTime {
+		return record.EventTime()
+	}
+	return record.IngestedAt
+}
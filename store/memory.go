@@ -0,0 +1,413 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+type idSet map[uint64]struct{}
+
+// Memory is a bounded in-memory store that evicts the oldest records once full
+type Memory struct {
+	mu       sync.RWMutex
+	capacity int
+	nextID   uint64
+	order    []uint64
+	records  map[uint64]Record
+	types    map[string]idSet
+	labels   map[string]map[string]idSet
+}
+
+// NewMemory is used to create a new in-memory store, non positive capacity means the default capacity
+func NewMemory(capacity int) *Memory {
+	if capacity <= 0 {
+		capacity = constants.DefaultStoreCapacity
+	}
+	return &Memory{
+		capacity: capacity,
+		records:  make(map[uint64]Record),
+		types:    make(map[string]idSet),
+		labels:   make(map[string]map[string]idSet),
+	}
+}
+
+// Add is used to store the entry, evicting the oldest record if the store is full
+func (m *Memory) Add(_ context.Context, entry models.LogEntry) (Record, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.order) >= m.capacity {
+		m.evict(m.order[0])
+		m.order = m.order[1:]
+	}
+
+	m.nextID++
+	record := Record{ID: m.nextID, IngestedAt: time.Now(), Entry: entry}
+	m.records[record.ID] = record
+	m.order = append(m.order, record.ID)
+	addToIndex(m.types, entry.Type, record.ID)
+	for key, value := range entry.Labels {
+		values, ok := m.labels[key]
+		if !ok {
+			values = make(map[string]idSet)
+			m.labels[key] = values
+		}
+		addToIndex(values, value, record.ID)
+	}
+	return record, nil
+}
+
+// Query is used to get the matching records, newest first by the time axis of the query
+func (m *Memory) Query(_ context.Context, query Query) ([]Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// use the smallest index as the candidate set and verify the rest against it
+	var candidates idSet
+	narrowed := false
+	narrow := func(ids idSet) {
+		if !narrowed || len(ids) < len(candidates) {
+			candidates = ids
+			narrowed = true
+		}
+	}
+	if len(query.IDs) > 0 {
+		ids := make(idSet, len(query.IDs))
+		for _, id := range query.IDs {
+			if _, ok := m.records[id]; ok {
+				ids[id] = struct{}{}
+			}
+		}
+		narrow(ids)
+	}
+	if query.Type != "" {
+		narrow(m.types[query.Type])
+	}
+	for key, value := range query.Labels {
+		narrow(m.labels[key][value])
+	}
+
+	var records []Record
+	if !narrowed {
+		for _, id := range m.order {
+			if matches(m.records[id], query) {
+				records = append(records, m.records[id])
+			}
+		}
+	} else {
+		for id := range candidates {
+			if matches(m.records[id], query) {
+				records = append(records, m.records[id])
+			}
+		}
+	}
+	return newestFirst(records, query), nil
+}
+
+// Around is used to get the records surrounding the record, oldest first on both sides
+func (m *Memory) Around(_ context.Context, record Record, surrounding Surrounding) ([]Record, []Record, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	candidates := m.order
+	for _, field := range surrounding.Fields {
+		if field == constants.TypeField {
+			candidates = candidates[:0:0]
+			for id := range m.types[record.Entry.Type] {
+				candidates = append(candidates, id)
+			}
+			break
+		}
+	}
+	stream := make([]Record, 0, len(candidates))
+	for _, id := range candidates {
+		stream = append(stream, m.records[id])
+	}
+	before, after := surround(stream, record, surrounding)
+	return before, after, nil
+}
+
+// sameStream is used to check whether the records have the same values of the fields, missing on both included.
+// The streams never span tenants, so the entries surrounding a hit are of the tenant of the hit.
+func sameStream(a, b Record, fields []string) bool {
+	if tenantOf(a) != tenantOf(b) {
+		return false
+	}
+	for _, field := range fields {
+		x, okX := filter.Lookup(a.Entry, field)
+		y, okY := filter.Lookup(b.Entry, field)
+		if okX != okY || okX && fmt.Sprint(x) != fmt.Sprint(y) {
+			return false
+		}
+	}
+	return true
+}
+
+// Stats is used to get the aggregates of the matching records
+func (m *Memory) Stats(ctx context.Context, query Query) (Stats, error) {
+	query.Limit = 0
+	records, err := m.Query(ctx, query)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats := Stats{Total: len(records), ByType: make(map[string]int)}
+	for _, record := range records {
+		stats.ByType[record.Entry.Type]++
+	}
+	return stats, nil
+}
+
+// Delete is used to soft delete the matching records
+func (m *Memory) Delete(ctx context.Context, query Query) (int, error) {
+	query.Deleted = false
+	now := time.Now()
+	return m.update(ctx, query, &now)
+}
+
+// Restore is used to restore the matching soft deleted records
+func (m *Memory) Restore(ctx context.Context, query Query) (int, error) {
+	query.Deleted = true
+	return m.update(ctx, query, nil)
+}
+
+// update is used to set when the matching records were deleted
+func (m *Memory) update(ctx context.Context, query Query, deletedAt *time.Time) (int, error) {
+	query.Limit = 0
+	records, err := m.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	updated := 0
+	for _, record := range records {
+		// the record may have been evicted or changed since it was matched
+		if current, ok := m.records[record.ID]; ok && (current.DeletedAt != nil) == query.Deleted {
+			current.DeletedAt = deletedAt
+			m.records[record.ID] = current
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// Purge is used to remove the records soft deleted before the time
+func (m *Memory) Purge(_ context.Context, deletedBefore time.Time) (int, error) {
+	return m.remove(func(record Record) bool {
+		return record.DeletedAt != nil && record.DeletedAt.Before(deletedBefore)
+	}), nil
+}
+
+// Expire is used to remove the records the retention policy expired, soft deleted or not
+func (m *Memory) Expire(_ context.Context, retention Retention, now time.Time) (int, error) {
+	return m.remove(func(record Record) bool {
+		return retention.Expired(record, now)
+	}), nil
+}
+
+// remove is used to evict the records the function picks and get how many were evicted
+func (m *Memory) remove(picked func(record Record) bool) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order := m.order[:0]
+	removed := 0
+	for _, id := range m.order {
+		if picked(m.records[id]) {
+			m.evict(id)
+			removed++
+			continue
+		}
+		order = append(order, id)
+	}
+	m.order = order
+	return removed
+}
+
+func (m *Memory) evict(id uint64) {
+	record, ok := m.records[id]
+	if !ok {
+		return
+	}
+	delete(m.records, id)
+	removeFromIndex(m.types, record.Entry.Type, id)
+	for key, value := range record.Entry.Labels {
+		removeFromIndex(m.labels[key], value, id)
+		if len(m.labels[key]) == 0 {
+			delete(m.labels, key)
+		}
+	}
+}
+
+func matches(record Record, query Query) bool {
+	entry := record.Entry
+	if (record.DeletedAt != nil) != query.Deleted {
+		return false
+	}
+	if !query.Filter.Match(entry) {
+		return false
+	}
+	if !query.From.IsZero() || !query.To.IsZero() {
+		at := record.IngestedAt
+		if query.Axis == EventTime {
+			at = record.EventTime()
+		}
+		if (!query.From.IsZero() && at.Before(query.From)) || (!query.To.IsZero() && !at.Before(query.To)) {
+			return false
+		}
+	}
+	if query.Type != "" && entry.Type != query.Type {
+		return false
+	}
+	if query.Tenant != "" && tenantOf(record) != query.Tenant {
+		return false
+	}
+	if query.Level != "" && entry.Level != query.Level {
+		return false
+	}
+	if query.Cursor != nil && !query.Cursor.precedes(record, query.Axis) {
+		return false
+	}
+	if query.Text != "" && !containsText(entry.Data, strings.ToLower(query.Text)) {
+		return false
+	}
+	for key, value := range query.Labels {
+		if actual, ok := entry.Labels[key]; !ok || actual != value {
+			return false
+		}
+	}
+	return true
+}
+
+func addToIndex(index map[string]idSet, key string, id uint64) {
+	ids, ok := index[key]
+	if !ok {
+		ids = make(idSet)
+		index[key] = ids
+	}
+	ids[id] = struct{}{}
+}
+
+func removeFromIndex(index map[string]idSet, key string, id uint64) {
+	ids, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(index, key)
+	}
+}
+
+// Matches is used to check whether the record matches the query, for the records read from elsewhere than a
+// store, e.g. the archives
+func Matches(record Record, query Query) bool {
+	return matches(record, query)
+}
+
+// Select is used to get the records matching the query, newest first by its time axis and within its limit
+func Select(records []Record, query Query) []Record {
+	selected := make([]Record, 0, len(records))
+	for _, record := range records {
+		if matches(record, query) {
+			selected = append(selected, record)
+		}
+	}
+	return newestFirst(selected, query)
+}
+
+// newestFirst is used to order the records newest first on the time axis of the query and apply its limit
+func newestFirst(records []Record, query Query) []Record {
+	if query.Axis == EventTime {
+		// entries arrive out of order, so the newest logged ones are not the newest stored ones
+		sort.Slice(records, func(i, j int) bool {
+			ti, tj := records[i].EventTime(), records[j].EventTime()
+			if ti.Equal(tj) {
+				return records[i].ID > records[j].ID
+			}
+			return ti.After(tj)
+		})
+	} else {
+		sort.Slice(records, func(i, j int) bool { return records[i].ID > records[j].ID })
+	}
+	if query.Limit > 0 && query.Limit < len(records) {
+		records = records[:query.Limit]
+	}
+	if records == nil {
+		return []Record{}
+	}
+	return records
+}
+
+// surround is used to get the records of the candidates in the stream of the record logged before and after it
+func surround(candidates []Record, record Record, surrounding Surrounding) ([]Record, []Record) {
+	stream := make([]Record, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate.DeletedAt == nil && (candidate.ID == record.ID ||
+			sameStream(candidate, record, surrounding.Fields)) {
+			stream = append(stream, candidate)
+		}
+	}
+	sort.Slice(stream, func(i, j int) bool {
+		ti, tj := at(stream[i], surrounding.Axis), at(stream[j], surrounding.Axis)
+		if ti.Equal(tj) {
+			return stream[i].ID < stream[j].ID
+		}
+		return ti.Before(tj)
+	})
+	t := at(record, surrounding.Axis)
+	position := sort.Search(len(stream), func(i int) bool {
+		ti := at(stream[i], surrounding.Axis)
+		return ti.After(t) || ti.Equal(t) && stream[i].ID >= record.ID
+	})
+	start, end := position-surrounding.Before, position+1+surrounding.After
+	if start < 0 {
+		start = 0
+	}
+	if end > len(stream) {
+		end = len(stream)
+	}
+	before := append([]Record{}, stream[start:position]...)
+	after := []Record{}
+	if position+1 < end {
+		after = append(after, stream[position+1:end]...)
+	}
+	return before, after
+}
+
+// tenantOf is used to get the tenant the entry of the record was ingested for, empty when it has none
+func tenantOf(record Record) string {
+	meta, _ := record.Entry.Data[constants.MetaNamespace].(map[string]interface{})
+	tenant, _ := meta[constants.MetaTenantKey].(string)
+	return tenant
+}
+
+// containsText is used to check whether a key or a value of the data contains the lower case text
+func containsText(value interface{}, text string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range v {
+			if strings.Contains(strings.ToLower(key), text) || containsText(nested, text) {
+				return true
+			}
+		}
+		return false
+	case []interface{}:
+		for _, nested := range v {
+			if containsText(nested, text) {
+				return true
+			}
+		}
+		return false
+	case nil:
+		return false
+	}
+	return strings.Contains(strings.ToLower(fmt.Sprint(value)), text)
+}
@@ -0,0 +1,175 @@
+package store_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryLabelQuery(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory(2)
+	_, _ = m.Add(ctx, models.LogEntry{Type: "a", Labels: map[string]string{"env": "prod"}})
+	_, _ = m.Add(ctx, models.LogEntry{Type: "b", Labels: map[string]string{"env": "prod"}})
+	_, _ = m.Add(ctx, models.LogEntry{Type: "a", Labels: map[string]string{"env": "uat"}})
+
+	records, err := m.Query(ctx, store.Query{Labels: map[string]string{"env": "prod"}})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "b", records[0].Entry.Type)
+
+	records, err = m.Query(ctx, store.Query{Type: "a", Labels: map[string]string{"env": "prod"}})
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+
+	records, err = m.Query(ctx, store.Query{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, uint64(3), records[0].ID)
+}
+
+func TestMemorySoftDelete(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory(10)
+	_, _ = m.Add(ctx, models.LogEntry{Type: "test"})
+	_, _ = m.Add(ctx, models.LogEntry{Type: "test"})
+	_, _ = m.Add(ctx, models.LogEntry{Type: "order"})
+
+	deleted, err := m.Delete(ctx, store.Query{Type: "test"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	records, _ := m.Query(ctx, store.Query{})
+	assert.Len(t, records, 1)
+	records, _ = m.Query(ctx, store.Query{Deleted: true})
+	assert.Len(t, records, 2)
+
+	restored, err := m.Restore(ctx, store.Query{IDs: []uint64{1}})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, restored)
+	records, _ = m.Query(ctx, store.Query{Type: "test"})
+	assert.Len(t, records, 1)
+	assert.Nil(t, records[0].DeletedAt)
+
+	purged, err := m.Purge(ctx, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	restored, _ = m.Restore(ctx, store.Query{IDs: []uint64{2}})
+	assert.Zero(t, restored)
+	stats, _ := m.Stats(ctx, store.Query{})
+	assert.Equal(t, 2, stats.Total)
+}
+
+func TestMemoryRetentionExemptions(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory(10)
+	_, _ = m.Add(ctx, models.LogEntry{Type: "payment", Level: "error"})
+	_, _ = m.Add(ctx, models.LogEntry{Type: "payment", Level: "info"})
+	_, _ = m.Add(ctx, models.LogEntry{Type: "order", Level: "error"})
+	_, _ = m.Add(ctx, models.LogEntry{Type: "order", Level: "info", Labels: map[string]string{"audit": "true"}})
+
+	retention := store.Retention{MaxAgeInHours: 24, Exemptions: []store.Exemption{
+		{Name: "payment errors", Type: "payment", Level: "error"},
+		{Name: "audit", Labels: map[string]string{"audit": "true"}, MaxAgeInHours: 72},
+	}}
+	assert.NoError(t, retention.Validate())
+	assert.Error(t, store.Retention{Exemptions: []store.Exemption{{Name: "all"}}}.Validate())
+
+	expired, err := m.Expire(ctx, retention, time.Now().Add(48*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, expired)
+	records, _ := m.Query(ctx, store.Query{})
+	assert.Len(t, records, 2)
+
+	expired, _ = m.Expire(ctx, retention, time.Now().Add(24*365*time.Hour))
+	assert.Equal(t, 1, expired)
+	records, _ = m.Query(ctx, store.Query{})
+	assert.Len(t, records, 1)
+	assert.Equal(t, "payment", records[0].Entry.Type)
+}
+
+func TestMemoryTimeAxis(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory(10)
+	now := time.Now()
+	_, _ = m.Add(ctx, models.LogEntry{Type: "a", Timestamp: &models.Timestamp{Time: now.Add(-time.Minute)}})
+	// a mobile entry logged hours ago arrives late
+	_, _ = m.Add(ctx, models.LogEntry{Type: "a", Timestamp: &models.Timestamp{Time: now.Add(-3 * time.Hour)}})
+
+	records, _ := m.Query(ctx, store.Query{From: now.Add(-time.Hour)})
+	assert.Len(t, records, 2)
+	assert.Equal(t, uint64(2), records[0].ID)
+
+	records, _ = m.Query(ctx, store.Query{Axis: store.EventTime, From: now.Add(-time.Hour)})
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, uint64(1), records[0].ID)
+	}
+	records, _ = m.Query(ctx, store.Query{Type: "a", Axis: store.EventTime, To: now.Add(-time.Hour)})
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, uint64(2), records[0].ID)
+	}
+	records, _ = m.Query(ctx, store.Query{Axis: store.EventTime})
+	assert.Equal(t, uint64(1), records[0].ID)
+}
+
+func TestMemoryAround(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory(10)
+	session := func(id string, step float64) models.LogEntry {
+		return models.LogEntry{Type: "checkout", Data: map[string]interface{}{"sessionId": id, "step": step}}
+	}
+	_, _ = m.Add(ctx, session("s1", 1))
+	_, _ = m.Add(ctx, session("s2", 1))
+	_, _ = m.Add(ctx, session("s1", 2))
+	hit, _ := m.Add(ctx, session("s1", 3))
+	_, _ = m.Add(ctx, models.LogEntry{Type: "payment"})
+	_, _ = m.Add(ctx, session("s1", 4))
+
+	surrounding := store.Surrounding{Fields: []string{"type", "data.sessionId"}, Before: 5, After: 5}
+	before, after, err := m.Around(ctx, hit, surrounding)
+	assert.NoError(t, err)
+	if assert.Len(t, before, 2) && assert.Len(t, after, 1) {
+		assert.Equal(t, 1.0, before[0].Entry.Data["step"])
+		assert.Equal(t, 2.0, before[1].Entry.Data["step"])
+		assert.Equal(t, 4.0, after[0].Entry.Data["step"])
+	}
+	surrounding.Fields, surrounding.Before, surrounding.After = []string{"type"}, 1, 1
+	before, after, _ = m.Around(ctx, hit, surrounding)
+	assert.Equal(t, uint64(3), before[0].ID)
+	assert.Equal(t, uint64(6), after[0].ID)
+}
+
+func TestMemoryCursorPages(t *testing.T) {
+	ctx := context.Background()
+	m := store.NewMemory(10)
+	for i := 0; i < 5; i++ {
+		_, _ = m.Add(ctx, models.LogEntry{Type: "order", Level: "error",
+			Data: map[string]interface{}{"reason": "Payment Declined", "attempt": float64(i)}})
+	}
+	_, _ = m.Add(ctx, models.LogEntry{Type: "order", Level: "info", Data: map[string]interface{}{"reason": "declined"}})
+
+	query := store.Query{Level: "error", Text: "declined", Limit: 2}
+	var ids []uint64
+	for page := 0; page < 5; page++ {
+		records, err := m.Query(ctx, query)
+		assert.NoError(t, err)
+		for _, record := range records {
+			ids = append(ids, record.ID)
+		}
+		cursor := store.NextCursor(records, query)
+		if cursor == nil {
+			break
+		}
+		query.Cursor, err = store.ParseCursor(cursor.String())
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []uint64{5, 4, 3, 2, 1}, ids)
+
+	records, _ := m.Query(ctx, store.Query{Text: "attempt"})
+	assert.Len(t, records, 5)
+	_, err := store.ParseCursor("not a cursor")
+	assert.Error(t, err)
+}
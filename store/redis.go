@@ -0,0 +1,223 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis is a bounded store shared by the processes, the api and the workers see the same records. The records
+// are kept in a hash by id and their ids in a sorted set, the oldest are evicted once the store is full.
+// Records are filtered by the process, the store is meant for the recent entries and not for long term search.
+type Redis struct {
+	client   *redis.Client
+	key      string
+	capacity int
+}
+
+// NewRedis is used to create a store keeping up to capacity records under the key in the redis of the url
+func NewRedis(url, key string, capacity int) (*Redis, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid store redis url : %w", err)
+	}
+	return &Redis{client: redis.NewClient(options), key: key, capacity: capacity}, nil
+}
+
+// Ping is used to check that redis is reachable
+func (r *Redis) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *Redis) recordsKey() string {
+	return r.key + ":records"
+}
+
+func (r *Redis) idsKey() string {
+	return r.key + ":ids"
+}
+
+func (r *Redis) sequenceKey() string {
+	return r.key + ":sequence"
+}
+
+// Add is used to store the entry, evicting the oldest records if the store is full
+func (r *Redis) Add(ctx context.Context, entry models.LogEntry) (Record, error) {
+	id, err := r.client.Incr(ctx, r.sequenceKey()).Result()
+	if err != nil {
+		return Record{}, err
+	}
+	record := Record{ID: uint64(id), IngestedAt: time.Now(), Entry: entry}
+	if err = r.save(ctx, record); err != nil {
+		return Record{}, err
+	}
+	stored, err := r.client.ZCard(ctx, r.idsKey()).Result()
+	if err != nil || stored <= int64(r.capacity) {
+		return record, err
+	}
+	evicted, err := r.client.ZPopMin(ctx, r.idsKey(), stored-int64(r.capacity)).Result()
+	if err == nil && len(evicted) > 0 {
+		fields := make([]string, 0, len(evicted))
+		for _, member := range evicted {
+			fields = append(fields, fmt.Sprint(member.Member))
+		}
+		err = r.client.HDel(ctx, r.recordsKey(), fields...).Err()
+	}
+	return record, err
+}
+
+// save is used to write the record
+func (r *Redis) save(ctx context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		id := strconv.FormatUint(record.ID, 10)
+		pipe.HSet(ctx, r.recordsKey(), id, data)
+		pipe.ZAdd(ctx, r.idsKey(), &redis.Z{Score: float64(record.ID), Member: id})
+		return nil
+	})
+	return err
+}
+
+// records is used to get the stored records picked by the function
+func (r *Redis) records(ctx context.Context, picked func(record Record) bool) ([]Record, error) {
+	values, err := r.client.HVals(ctx, r.recordsKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for _, value := range values {
+		var record Record
+		if err = json.Unmarshal([]byte(value), &record); err != nil {
+			log.Warn(ctx).Err(err).Msg("skipped invalid stored record")
+			continue
+		}
+		if picked(record) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// Query is used to get the matching records, newest first by the time axis of the query
+func (r *Redis) Query(ctx context.Context, query Query) ([]Record, error) {
+	ids := make(map[uint64]bool, len(query.IDs))
+	for _, id := range query.IDs {
+		ids[id] = true
+	}
+	records, err := r.records(ctx, func(record Record) bool {
+		return (len(ids) == 0 || ids[record.ID]) && matches(record, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newestFirst(records, query), nil
+}
+
+// Around is used to get the records surrounding the record, oldest first on both sides
+func (r *Redis) Around(ctx context.Context, record Record, surrounding Surrounding) ([]Record, []Record, error) {
+	candidates, err := r.records(ctx, func(Record) bool { return true })
+	if err != nil {
+		return nil, nil, err
+	}
+	before, after := surround(candidates, record, surrounding)
+	return before, after, nil
+}
+
+// Stats is used to get the aggregates of the matching records
+func (r *Redis) Stats(ctx context.Context, query Query) (Stats, error) {
+	query.Limit = 0
+	records, err := r.Query(ctx, query)
+	if err != nil {
+		return Stats{}, err
+	}
+	stats := Stats{Total: len(records), ByType: make(map[string]int)}
+	for _, record := range records {
+		stats.ByType[record.Entry.Type]++
+	}
+	return stats, nil
+}
+
+// Delete is used to soft delete the matching records
+func (r *Redis) Delete(ctx context.Context, query Query) (int, error) {
+	query.Deleted = false
+	now := time.Now()
+	return r.update(ctx, query, &now)
+}
+
+// Restore is used to restore the matching soft deleted records
+func (r *Redis) Restore(ctx context.Context, query Query) (int, error) {
+	query.Deleted = true
+	return r.update(ctx, query, nil)
+}
+
+// update is used to set when the matching records were deleted
+func (r *Redis) update(ctx context.Context, query Query, deletedAt *time.Time) (int, error) {
+	query.Limit = 0
+	records, err := r.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, record := range records {
+		// the record may have been evicted since it was matched
+		exists, err := r.client.HExists(ctx, r.recordsKey(), strconv.FormatUint(record.ID, 10)).Result()
+		if err != nil {
+			return updated, err
+		}
+		if !exists {
+			continue
+		}
+		record.DeletedAt = deletedAt
+		if err = r.save(ctx, record); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	return updated, nil
+}
+
+// Purge is used to remove the records soft deleted before the time
+func (r *Redis) Purge(ctx context.Context, deletedBefore time.Time) (int, error) {
+	return r.remove(ctx, func(record Record) bool {
+		return record.DeletedAt != nil && record.DeletedAt.Before(deletedBefore)
+	})
+}
+
+// Expire is used to remove the records the retention policy expired, soft deleted or not
+func (r *Redis) Expire(ctx context.Context, retention Retention, now time.Time) (int, error) {
+	return r.remove(ctx, func(record Record) bool {
+		return retention.Expired(record, now)
+	})
+}
+
+// remove is used to remove the records the function picks and get how many were removed
+func (r *Redis) remove(ctx context.Context, picked func(record Record) bool) (int, error) {
+	records, err := r.records(ctx, picked)
+	if err != nil || len(records) == 0 {
+		return 0, err
+	}
+	ids := make([]string, 0, len(records))
+	members := make([]interface{}, 0, len(records))
+	for _, record := range records {
+		id := strconv.FormatUint(record.ID, 10)
+		ids, members = append(ids, id), append(members, id)
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, r.recordsKey(), ids...)
+		pipe.ZRem(ctx, r.idsKey(), members...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
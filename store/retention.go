@@ -0,0 +1,79 @@
+package store
+
+import (
+	"fmt"
+	"time"
+)
+
+// Retention is the policy records are purged by once they are old enough
+type Retention struct {
+	// MaxAgeInHours is how long records are kept after they were ingested, 0 keeps them until evicted
+	MaxAgeInHours int `json:"maxAgeInHours" mapstructure:"maxAgeInHours"`
+	// Exemptions keep the records they match longer than the policy, e.g. the errors of payments for audits
+	Exemptions []Exemption `json:"exemptions" mapstructure:"exemptions"`
+}
+
+// Exemption is a filter of records kept longer than the retention policy
+type Exemption struct {
+	// Name identifies the exemption
+	Name string `json:"name" mapstructure:"name"`
+	// Type is the exact log type to match, empty matches all
+	Type string `json:"type" mapstructure:"type"`
+	// Level is the exact level to match, empty matches all
+	Level string `json:"level" mapstructure:"level"`
+	// Labels are matched exactly, all of them have to be present on the entry
+	Labels map[string]string `json:"labels" mapstructure:"labels"`
+	// MaxAgeInHours is how long the matching records are kept, 0 keeps them forever
+	MaxAgeInHours int `json:"maxAgeInHours" mapstructure:"maxAgeInHours"`
+}
+
+// Validate is used to check that every exemption filters records, an exemption without filters would keep all
+func (r Retention) Validate() error {
+	if r.MaxAgeInHours < 0 {
+		return fmt.Errorf("retention max age can not be negative")
+	}
+	for i, exemption := range r.Exemptions {
+		if exemption.Type == "" && exemption.Level == "" && len(exemption.Labels) == 0 {
+			return fmt.Errorf("retention exemption %d %s needs a type, level or labels", i, exemption.Name)
+		}
+		if exemption.MaxAgeInHours < 0 {
+			return fmt.Errorf("retention exemption %d %s max age can not be negative", i, exemption.Name)
+		}
+	}
+	return nil
+}
+
+// Expired is used to check whether the record is older than the policy, or than the longest exemption it matches
+func (r Retention) Expired(record Record, now time.Time) bool {
+	if r.MaxAgeInHours <= 0 {
+		return false
+	}
+	maxAge := r.MaxAgeInHours
+	for _, exemption := range r.Exemptions {
+		if !exemption.matches(record) {
+			continue
+		}
+		if exemption.MaxAgeInHours == 0 {
+			return false
+		}
+		if exemption.MaxAgeInHours > maxAge {
+			maxAge = exemption.MaxAgeInHours
+		}
+	}
+	return now.Sub(record.IngestedAt) > time.Duration(maxAge)*time.Hour
+}
+
+func (e Exemption) matches(record Record) bool {
+	if e.Type != "" && record.Entry.Type != e.Type {
+		return false
+	}
+	if e.Level != "" && record.Entry.Level != e.Level {
+		return false
+	}
+	for key, value := range e.Labels {
+		if record.Entry.Labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/filter"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// Record is a stored log entry along with its storage metadata
+type Record struct {
+	ID         uint64          `json:"id"`
+	IngestedAt time.Time       `json:"ingestedAt"`
+	Entry      models.LogEntry `json:"entry"`
+	// DeletedAt is when the record was soft deleted, it is hidden from queries until restored or purged
+	DeletedAt *time.Time `json:"deletedAt,omitempty"`
+}
+
+// EventTime is used to get when the entry was logged, the ingestion time for entries stored without a timestamp
+func (r Record) EventTime() time.Time {
+	if r.Entry.Timestamp != nil {
+		return r.Entry.Timestamp.Time
+	}
+	return r.IngestedAt
+}
+
+// TimeAxis is the time records are filtered and ordered by
+type TimeAxis string
+
+const (
+	// IngestionTime is when the record was stored, late entries are found by when they arrived
+	IngestionTime TimeAxis = "ingestion"
+	// EventTime is when the entry was logged, late entries are found among the ones logged around them
+	EventTime TimeAxis = "event"
+)
+
+// Query is the set of filters applied when reading stored entries
+type Query struct {
+	// Type is the exact log type to match, empty matches all
+	Type string
+	// Level is the exact level to match, empty matches all
+	Level string
+	// Text is looked for in the data of the entries case insensitively, in the values and the keys
+	Text string
+	// Labels are matched exactly, all of them have to be present on the entry
+	Labels map[string]string
+	// Filter is the filter expression the entries have to match, nil matches all
+	Filter *filter.Filter
+	// IDs are the ids of the records to match, empty matches all
+	IDs []uint64
+	// Deleted only matches the soft deleted records, they are not matched otherwise
+	Deleted bool
+	// Tenant only matches the entries ingested for the tenant, empty matches the entries of every tenant
+	Tenant string
+	// Axis is the time From and To apply to and the records are ordered by, the ingestion time when empty
+	Axis TimeAxis
+	// From and To bound the time of the records, From included and To excluded, zero times are unbounded
+	From time.Time
+	To   time.Time
+	// Cursor only matches the records after it in the order of the query, nil matches from the newest
+	Cursor *Cursor
+	// Limit is the maximum number of records returned
+	Limit int
+}
+
+// Surrounding selects the records around a record, the ones of the same stream logged just before and after it
+type Surrounding struct {
+	// Fields are the fields the records of the stream have the same values of, e.g. type and data.sessionId
+	Fields []string
+	// Axis is the time the records are ordered by, the ingestion time when empty
+	Axis TimeAxis
+	// Before and After are the number of records returned on each side of the record
+	Before int
+	After  int
+}
+
+// Stats is the aggregate view of the matching records
+type Stats struct {
+	Total  int            `json:"total"`
+	ByType map[string]int `json:"byType"`
+}
+
+// Store is a short term store of recently ingested entries used for querying
+type Store interface {
+	// Add is used to store the entry
+	Add(ctx context.Context, entry models.LogEntry) (Record, error)
+	// Query is used to get the matching records, newest first by the time axis of the query
+	Query(ctx context.Context, query Query) ([]Record, error)
+	// Around is used to get the records surrounding the record, oldest first on both sides
+	Around(ctx context.Context, record Record, surrounding Surrounding) ([]Record, []Record, error)
+	// Stats is used to get the aggregates of the matching records, the limit is ignored
+	Stats(ctx context.Context, query Query) (Stats, error)
+	// Delete is used to soft delete the matching records and get how many were deleted, the limit is ignored
+	Delete(ctx context.Context, query Query) (int, error)
+	// Restore is used to restore the matching soft deleted records and get how many were restored
+	Restore(ctx context.Context, query Query) (int, error)
+	// Purge is used to remove the records soft deleted before the time for good
+	Purge(ctx context.Context, deletedBefore time.Time) (int, error)
+	// Expire is used to remove the records the retention policy expired by the time
+	Expire(ctx context.Context, retention Retention, now time.Time) (int, error)
+}
+
+var s Store = NewMemory(0)
+
+// Init is used to initialize the default store
+func Init(store Store) {
+	s = store
+}
+
+// Get is used to get the default store
+func Get() Store {
+	return s
+}
@@ -0,0 +1,194 @@
+package tenants
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+	"github.com/google/uuid"
+)
+
+// OnboardingConfig is the set of defaults applied to newly onboarded tenants
+type OnboardingConfig struct {
+	// DefaultKeys is the number of api keys provisioned when the request does not ask for a number
+	DefaultKeys    int     `json:"defaultKeys" mapstructure:"defaultKeys"`
+	DefaultQuotas  Quotas  `json:"defaultQuotas" mapstructure:"defaultQuotas"`
+	DefaultRouting Routing `json:"defaultRouting" mapstructure:"defaultRouting"`
+	// IngestURL is the base url clients send their entries to, it is returned in the bundle
+	IngestURL string `json:"ingestURL" mapstructure:"ingestURL"`
+	// Provisioners are the downstream resources that can be created on onboarding, by name
+	Provisioners map[string]ProvisionerConfig `json:"provisioners" mapstructure:"provisioners"`
+}
+
+// ProvisionerConfig points to an endpoint that creates a downstream resource such as a kafka topic or an index.
+// The endpoint receives {"tenant": "...", "name": "..."} and its json response is returned in the bundle.
+type ProvisionerConfig struct {
+	URL             string `json:"url" mapstructure:"url"`
+	TimeoutInMillis int    `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+}
+
+// OnboardingRequest is the tenant to onboard, quotas and routing fall back to the configured defaults
+type OnboardingRequest struct {
+	ID        string   `json:"id" binding:"required"`
+	Name      string   `json:"name" binding:"required"`
+	Keys      int      `json:"keys"`
+	Quotas    *Quotas  `json:"quotas"`
+	Routing   *Routing `json:"routing"`
+	Provision []string `json:"provision"`
+}
+
+// Bundle is everything a new tenant needs to start sending entries.
+// It is the only time the key secrets are returned.
+type Bundle struct {
+	Tenant       registry.Resource `json:"tenant"`
+	Keys         []IssuedKey       `json:"keys"`
+	IngestURL    string            `json:"ingestURL,omitempty"`
+	Provisioning []Provisioned     `json:"provisioning,omitempty"`
+}
+
+// IssuedKey is a provisioned api key with its plain secret
+type IssuedKey struct {
+	ID     string `json:"id"`
+	Secret string `json:"secret"`
+}
+
+// Provisioned is the outcome of creating one downstream resource
+type Provisioned struct {
+	Name   string          `json:"name"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+var (
+	configMu sync.RWMutex
+	config   = OnboardingConfig{DefaultKeys: 1}
+)
+
+// Init is used to set the onboarding defaults
+func Init(c OnboardingConfig) {
+	if c.DefaultKeys <= 0 {
+		c.DefaultKeys = 1
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	config = c
+}
+
+// Onboard is used to create the tenant and its keys in one step and then provision the requested
+// downstream resources. The tenant and keys are created atomically, while provisioning failures do not
+// undo them and are reported in the bundle instead.
+func Onboard(reg *registry.Registry, request OnboardingRequest, author string) (Bundle, error) {
+	configMu.RLock()
+	c := config
+	configMu.RUnlock()
+	for _, name := range request.Provision {
+		if _, ok := c.Provisioners[name]; !ok {
+			return Bundle{}, fmt.Errorf("unknown provisioner %s", name)
+		}
+	}
+	if request.Keys <= 0 {
+		request.Keys = c.DefaultKeys
+	}
+	if request.Keys > constants.MaxOnboardingKeys {
+		return Bundle{}, fmt.Errorf("at most %d keys can be provisioned", constants.MaxOnboardingKeys)
+	}
+	tenant := Tenant{Name: request.Name, Quotas: request.Quotas, Routing: request.Routing}
+	if tenant.Quotas == nil {
+		tenant.Quotas = &c.DefaultQuotas
+	}
+	if tenant.Routing == nil {
+		tenant.Routing = &c.DefaultRouting
+	}
+	spec, err := json.Marshal(tenant)
+	if err != nil {
+		return Bundle{}, err
+	}
+	resources := []registry.Resource{{Kind: constants.TenantsResourceKind, ID: request.ID, Spec: spec}}
+	issued := make([]IssuedKey, 0, request.Keys)
+	for i := 0; i < request.Keys; i++ {
+		secret, err := newSecret()
+		if err != nil {
+			return Bundle{}, err
+		}
+		key := IssuedKey{ID: uuid.NewString(), Secret: secret}
+		spec, err = json.Marshal(Key{Name: fmt.Sprintf("%s-%d", request.ID, i+1), Tenant: request.ID,
+			SecretHash: HashSecret(secret)})
+		if err != nil {
+			return Bundle{}, err
+		}
+		resources = append(resources, registry.Resource{Kind: constants.KeysResourceKind, ID: key.ID, Spec: spec})
+		issued = append(issued, key)
+	}
+	created, err := reg.Create(resources, author)
+	if err != nil {
+		return Bundle{}, err
+	}
+
+	bundle := Bundle{Tenant: created[0], Keys: issued, IngestURL: c.IngestURL}
+	names := append([]string(nil), request.Provision...)
+	sort.Strings(names)
+	for _, name := range names {
+		result, err := provision(c.Provisioners[name], request.ID, name)
+		provisioned := Provisioned{Name: name, Result: result}
+		if err != nil {
+			log.Error(nil).Err(err).Str(constants.TenantKey, request.ID).Str(constants.ProvisionerKey, name).
+				Msg("error provisioning tenant resource")
+			provisioned.Error = err.Error()
+		}
+		bundle.Provisioning = append(bundle.Provisioning, provisioned)
+	}
+	return bundle, nil
+}
+
+// newSecret is used to generate a random api key secret
+func newSecret() (string, error) {
+	secret := make([]byte, constants.APIKeySecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// provision is used to call the provisioner endpoint for the tenant
+func provision(provisioner ProvisionerConfig, tenant, name string) (json.RawMessage, error) {
+	timeout := time.Duration(provisioner.TimeoutInMillis) * time.Millisecond
+	if timeout <= 0 {
+		timeout = constants.DefaultProvisionerTimeoutInMillis * time.Millisecond
+	}
+	body, err := json.Marshal(map[string]string{"tenant": tenant, "name": name})
+	if err != nil {
+		return nil, err
+	}
+	response, err := httpclient.POSTWithTimeout(provisioner.URL, map[string]string{"Content-Type": "application/json"},
+		bytes.NewReader(body), timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", constants.ExternalServiceFailureError, err)
+	}
+	defer response.Body.Close()
+	result, err := io.ReadAll(io.LimitReader(response.Body, constants.MaxProvisionerResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("%s: provisioner responded with %d", constants.ExternalServiceFailureError,
+			response.StatusCode)
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	if !json.Valid(result) {
+		return nil, fmt.Errorf("%s: provisioner responded with invalid json", constants.ExternalServiceFailureError)
+	}
+	return result, nil
+}
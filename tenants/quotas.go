@@ -0,0 +1,187 @@
+package tenants
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/go-redis/redis/v8"
+	"golang.org/x/time/rate"
+)
+
+// ErrQuotaExceeded is returned for the entries of a tenant over its quotas
+var ErrQuotaExceeded = errors.New(constants.QuotaExceededError)
+
+// QuotasConfig is the configuration of the counters of the daily quotas
+type QuotasConfig struct {
+	// RedisURL is the counter store shared by the processes, each process counts on its own without it
+	RedisURL string `json:"-" mapstructure:"redisUrl"`
+	// Prefix namespaces the counters in the store
+	Prefix string `json:"prefix" mapstructure:"prefix"`
+}
+
+// Counter counts the entries of the tenants by day
+type Counter interface {
+	// Add is used to count the entries and get the count of the day
+	Add(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error)
+	// Count is used to get the count of the day
+	Count(ctx context.Context, key string) (int64, error)
+}
+
+var (
+	quotasConfig         = QuotasConfig{Prefix: constants.DefaultQuotaPrefix}
+	counter      Counter = NewMemoryCounter()
+	limitersMu   sync.Mutex
+	limiters     = map[string]*tenantLimiter{}
+)
+
+// tenantLimiter is the rate limit of a tenant along with the quota it was made for
+type tenantLimiter struct {
+	quotas  Quotas
+	limiter *rate.Limiter
+}
+
+// InitQuotas is used to set up the counters of the daily quotas
+func InitQuotas(c QuotasConfig) error {
+	if c.Prefix == "" {
+		c.Prefix = constants.DefaultQuotaPrefix
+	}
+	cnt := Counter(NewMemoryCounter())
+	if c.RedisURL != "" {
+		options, err := redis.ParseURL(c.RedisURL)
+		if err != nil {
+			return fmt.Errorf("invalid quotas redis url : %w", err)
+		}
+		cnt = &RedisCounter{client: redis.NewClient(options)}
+	}
+	quotasConfig, counter = c, cnt
+	return nil
+}
+
+// Consume is used to count an entry of the tenant against its quotas, ErrQuotaExceeded is returned when the
+// tenant is over its rate or over its daily quota. The counters failing must not lose entries, so the entry
+// is accepted then.
+func Consume(ctx context.Context, tenant string, now time.Time) error {
+	quotas, ok := index.Load().(routes).quotas[tenant]
+	if !ok {
+		return nil
+	}
+	if quotas.EntriesPerSecond > 0 && !limiterOf(tenant, quotas).AllowN(now, 1) {
+		return fmt.Errorf("%w, %v entries per second", ErrQuotaExceeded, quotas.EntriesPerSecond)
+	}
+	if quotas.EntriesPerDay <= 0 {
+		return nil
+	}
+	count, err := counter.Add(ctx, dayKey(tenant, now), 1, constants.QuotaCounterTTLInHours*time.Hour)
+	if err != nil {
+		log.Warn(ctx).Err(err).Str(constants.TenantKey, tenant).Msg("error counting entry, accepting it")
+		return nil
+	}
+	if count > quotas.EntriesPerDay {
+		return fmt.Errorf("%w, %d entries per day", ErrQuotaExceeded, quotas.EntriesPerDay)
+	}
+	return nil
+}
+
+// Exhausted is used to check whether the tenant used up its daily quota, along with the wait until it is reset
+func Exhausted(ctx context.Context, tenant string, now time.Time) (bool, time.Duration) {
+	quotas, ok := index.Load().(routes).quotas[tenant]
+	if !ok || quotas.EntriesPerDay <= 0 {
+		return false, 0
+	}
+	count, err := counter.Count(ctx, dayKey(tenant, now))
+	if err != nil || count < quotas.EntriesPerDay {
+		return false, 0
+	}
+	day := now.UTC().Truncate(24 * time.Hour)
+	return true, day.Add(24 * time.Hour).Sub(now)
+}
+
+// dayKey is used to get the counter of the tenant for the utc day of the time
+func dayKey(tenant string, now time.Time) string {
+	return quotasConfig.Prefix + tenant + ":" + now.UTC().Format("2006-01-02")
+}
+
+// limiterOf is used to get the rate limiter of the tenant, a new one when its quotas changed
+func limiterOf(tenant string, quotas Quotas) *rate.Limiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	if l, ok := limiters[tenant]; ok && l.quotas == quotas {
+		return l.limiter
+	}
+	burst := quotas.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(quotas.EntriesPerSecond))
+	}
+	l := &tenantLimiter{quotas: quotas, limiter: rate.NewLimiter(rate.Limit(quotas.EntriesPerSecond), burst)}
+	limiters[tenant] = l
+	return l.limiter
+}
+
+// RedisCounter is the counter store of a redis shared by the processes
+type RedisCounter struct {
+	client *redis.Client
+}
+
+// Add is used to increment the counter, it expires after the ttl
+func (r *RedisCounter) Add(ctx context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	var count *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		count = pipe.IncrBy(ctx, key, n)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
+}
+
+// Count is used to get the counter, 0 when it does not exist
+func (r *RedisCounter) Count(ctx context.Context, key string) (int64, error) {
+	count, err := r.client.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return count, err
+}
+
+// MemoryCounter is the counter store of a single process
+type MemoryCounter struct {
+	mu       sync.Mutex
+	counts   map[string]int64
+	expireAt map[string]time.Time
+}
+
+// NewMemoryCounter is used to create an empty in memory counter store
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{counts: make(map[string]int64), expireAt: make(map[string]time.Time)}
+}
+
+// Add is used to increment the counter, the expired counters are dropped on the way
+func (m *MemoryCounter) Add(_ context.Context, key string, n int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for k, at := range m.expireAt {
+		if now.After(at) {
+			delete(m.counts, k)
+			delete(m.expireAt, k)
+		}
+	}
+	m.counts[key] += n
+	m.expireAt[key] = now.Add(ttl)
+	return m.counts[key], nil
+}
+
+// Count is used to get the counter, 0 when it does not exist
+func (m *MemoryCounter) Count(_ context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counts[key], nil
+}
@@ -0,0 +1,38 @@
+package tenants_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/registry"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDailyQuota(t *testing.T) {
+	reg := registry.New()
+	tenants.Register(reg)
+	assert.NoError(t, tenants.InitQuotas(tenants.QuotasConfig{}))
+	_, err := reg.Put(constants.TenantsResourceKind, "acme",
+		json.RawMessage(`{"name":"Acme","quotas":{"entriesPerDay":2}}`), registry.Precondition{}, "alice")
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Date(2026, 10, 16, 22, 0, 0, 0, time.UTC)
+	assert.NoError(t, tenants.Consume(ctx, "acme", now))
+	exhausted, _ := tenants.Exhausted(ctx, "acme", now)
+	assert.False(t, exhausted)
+	assert.NoError(t, tenants.Consume(ctx, "acme", now))
+
+	exhausted, wait := tenants.Exhausted(ctx, "acme", now)
+	assert.True(t, exhausted)
+	assert.Equal(t, 2*time.Hour, wait)
+	assert.ErrorIs(t, tenants.Consume(ctx, "acme", now), tenants.ErrQuotaExceeded)
+
+	// the quota is reset at midnight utc and tenants without quotas are not limited
+	assert.NoError(t, tenants.Consume(ctx, "acme", now.Add(2*time.Hour)))
+	assert.NoError(t, tenants.Consume(ctx, "other", now))
+}
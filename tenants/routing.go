@@ -0,0 +1,229 @@
+package tenants
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/registry"
+)
+
+type contextKey struct{}
+
+type keyNameContextKey struct{}
+
+type scopeContextKey struct{}
+
+// routes maps the hosts, path prefixes and api keys of the tenants to their ids
+type routes struct {
+	hosts    map[string]string
+	prefixes map[string]string
+	disabled map[string]bool
+	// keyNames and keys map the secret hashes of the enabled api keys to their names and to their tenants
+	keyNames map[string]string
+	keys     map[string]string
+	quotas   map[string]Quotas
+	shares   map[string]int
+	ids      []string
+}
+
+var (
+	index         atomic.Value
+	pathPrefixRex = regexp.MustCompile(constants.TenantPathPrefixPattern)
+)
+
+func init() {
+	index.Store(routes{})
+}
+
+// WithTenant is used to get a context carrying the tenant the request belongs to
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenant)
+}
+
+// FromContext is used to get the tenant the request belongs to
+func FromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	tenant, ok := ctx.Value(contextKey{}).(string)
+	return tenant, ok
+}
+
+// WithKeyName is used to get a context carrying the name of the api key the request was sent with
+func WithKeyName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, keyNameContextKey{}, name)
+}
+
+// KeyNameFromContext is used to get the name of the api key the request was sent with
+func KeyNameFromContext(ctx context.Context) (string, bool) {
+	if ctx == nil {
+		return "", false
+	}
+	name, ok := ctx.Value(keyNameContextKey{}).(string)
+	return name, ok
+}
+
+// WithScope is used to get a context carrying the tenant whose entries the reads of the request see, empty for
+// the entries of every tenant
+func WithScope(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, scopeContextKey{}, tenant)
+}
+
+// Scope is used to get the tenant whose entries the reads of the request see, empty for the entries of every tenant
+func Scope(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenant, _ := ctx.Value(scopeContextKey{}).(string)
+	return tenant
+}
+
+// ByHost is used to get the tenant owning the host, the port is ignored
+func ByHost(host string) (string, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	tenant, ok := index.Load().(routes).hosts[strings.ToLower(host)]
+	return tenant, ok
+}
+
+// ByPathPrefix is used to get the tenant owning the first segment of the path and the rest of the path
+func ByPathPrefix(path string) (string, string, bool) {
+	segments := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	tenant, ok := index.Load().(routes).prefixes[segments[0]]
+	if !ok || len(segments) < 2 {
+		return "", "", false
+	}
+	return tenant, "/" + segments[1], true
+}
+
+// KeyName is used to get the name of the enabled api key of the secret
+func KeyName(secret string) (string, bool) {
+	name, ok := index.Load().(routes).keyNames[HashSecret(secret)]
+	return name, ok
+}
+
+// ByKey is used to get the tenant of the api key secret
+func ByKey(secret string) (string, bool) {
+	tenant, ok := index.Load().(routes).keys[HashSecret(secret)]
+	return tenant, ok
+}
+
+// Exists is used to check whether the tenant is registered
+func Exists(tenant string) bool {
+	_, ok := index.Load().(routes).quotas[tenant]
+	return ok
+}
+
+// IDs is used to get the ids of the registered tenants, sorted
+func IDs() []string {
+	return append([]string{}, index.Load().(routes).ids...)
+}
+
+// Share is used to get the share of the tenant, the default share for the entries without a tenant
+func Share(tenant string) int {
+	if share, ok := index.Load().(routes).shares[tenant]; ok {
+		return share
+	}
+	return constants.DefaultTenantShare
+}
+
+// Disabled is used to check whether the tenant is disabled and may not ingest
+func Disabled(tenant string) bool {
+	return index.Load().(routes).disabled[tenant]
+}
+
+// validateRoutes is used to check that no two tenants claim the same host or path prefix
+func validateRoutes(resources []registry.Resource) error {
+	_, err := buildRoutes(resources)
+	return err
+}
+
+// reloadRoutes is used to rebuild the routes from the tenants and the keys in the registry
+func reloadRoutes(reg *registry.Registry) {
+	resources, err := reg.List(constants.TenantsResourceKind)
+	if err == nil {
+		var r routes
+		if r, err = buildRoutes(resources); err == nil {
+			if resources, err = reg.List(constants.KeysResourceKind); err == nil {
+				r.keyNames, r.keys, err = buildKeys(resources)
+			}
+		}
+		if err == nil {
+			index.Store(r)
+			return
+		}
+	}
+	log.Error(nil).Err(err).Msg("error reloading tenant routes")
+}
+
+// buildKeys is used to map the secret hashes of the enabled keys to their names, the ids of the resources of the
+// keys without one, and to their tenants
+func buildKeys(resources []registry.Resource) (map[string]string, map[string]string, error) {
+	names := make(map[string]string, len(resources))
+	keys := make(map[string]string, len(resources))
+	for _, resource := range resources {
+		var key Key
+		if err := json.Unmarshal(resource.Spec, &key); err != nil {
+			return nil, nil, err
+		}
+		if !key.Disabled {
+			names[key.SecretHash] = key.Name
+			if key.Name == "" {
+				names[key.SecretHash] = resource.ID
+			}
+			keys[key.SecretHash] = key.Tenant
+		}
+	}
+	return names, keys, nil
+}
+
+func buildRoutes(resources []registry.Resource) (routes, error) {
+	r := routes{hosts: make(map[string]string), prefixes: make(map[string]string), disabled: make(map[string]bool),
+		quotas: make(map[string]Quotas), shares: make(map[string]int)}
+	for _, resource := range resources {
+		var tenant Tenant
+		if err := json.Unmarshal(resource.Spec, &tenant); err != nil {
+			return r, err
+		}
+		r.ids = append(r.ids, resource.ID)
+		r.quotas[resource.ID] = Quotas{}
+		if tenant.Quotas != nil {
+			r.quotas[resource.ID] = *tenant.Quotas
+		}
+		if tenant.Share > 0 {
+			r.shares[resource.ID] = tenant.Share
+		}
+		if tenant.Disabled {
+			r.disabled[resource.ID] = true
+		}
+		for _, host := range tenant.Hosts {
+			host = strings.ToLower(host)
+			if owner, ok := r.hosts[host]; ok {
+				return r, fmt.Errorf("host %s is already used by tenant %s", host, owner)
+			}
+			r.hosts[host] = resource.ID
+		}
+		if tenant.PathPrefix == "" {
+			continue
+		}
+		if !pathPrefixRex.MatchString(tenant.PathPrefix) {
+			return r, fmt.Errorf("path prefix %s of tenant %s has to match %s", tenant.PathPrefix, resource.ID,
+				constants.TenantPathPrefixPattern)
+		}
+		if owner, ok := r.prefixes[tenant.PathPrefix]; ok {
+			return r, fmt.Errorf("path prefix %s is already used by tenant %s", tenant.PathPrefix, owner)
+		}
+		r.prefixes[tenant.PathPrefix] = resource.ID
+	}
+	sort.Strings(r.ids)
+	return r, nil
+}
@@ -0,0 +1,121 @@
+package tenants
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/registry"
+)
+
+// Tenant is the spec of a tenant resource, the resource id is the tenant id
+type Tenant struct {
+	Name     string   `json:"name"`
+	Disabled bool     `json:"disabled,omitempty"`
+	Quotas   *Quotas  `json:"quotas,omitempty"`
+	Routing  *Routing `json:"routing,omitempty"`
+	// Hosts are the Host headers resolved to the tenant, e.g. logs.tenant-a.internal
+	Hosts []string `json:"hosts,omitempty"`
+	// PathPrefix is the first path segment resolved to the tenant, e.g. tenant-a for /tenant-a/logger
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// Share is the weight of the tenant when the workers and the sinks share out their capacity, 1 by default,
+	// e.g. a tenant with a share of 3 gets three entries through for every entry of a tenant with the default
+	Share int `json:"share,omitempty"`
+}
+
+// Quotas are the ingestion limits of a tenant, zero means unlimited
+type Quotas struct {
+	EntriesPerSecond float64 `json:"entriesPerSecond,omitempty" mapstructure:"entriesPerSecond"`
+	Burst            int     `json:"burst,omitempty" mapstructure:"burst"`
+	EntriesPerDay    int64   `json:"entriesPerDay,omitempty" mapstructure:"entriesPerDay"`
+}
+
+// Routing is where the entries of a tenant are delivered
+type Routing struct {
+	Sinks []string `json:"sinks,omitempty" mapstructure:"sinks"`
+}
+
+// Key is the spec of an api key resource.
+// The secret is only accepted on writes, it is stored and returned as its sha256 hash.
+type Key struct {
+	Name       string `json:"name,omitempty"`
+	Tenant     string `json:"tenant"`
+	Secret     string `json:"secret,omitempty"`
+	SecretHash string `json:"secretHash"`
+	Disabled   bool   `json:"disabled,omitempty"`
+}
+
+// Register is used to register the tenants and keys resource kinds on the registry
+func Register(reg *registry.Registry) {
+	reg.RegisterKind(registry.Kind{
+		Name: constants.TenantsResourceKind,
+		Validate: func(_ string, spec json.RawMessage) error {
+			var tenant Tenant
+			if err := decode(spec, &tenant); err != nil {
+				return err
+			}
+			if tenant.Name == "" {
+				return fmt.Errorf("name is required")
+			}
+			if q := tenant.Quotas; q != nil && (q.EntriesPerSecond < 0 || q.Burst < 0 || q.EntriesPerDay < 0) {
+				return fmt.Errorf("quotas can not be negative")
+			}
+			if tenant.Share < 0 {
+				return fmt.Errorf("share can not be negative")
+			}
+			return nil
+		},
+		ValidateAll: validateRoutes,
+	})
+	reg.OnChange(constants.TenantsResourceKind, func() { reloadRoutes(reg) })
+	reg.RegisterKind(registry.Kind{
+		Name:      constants.KeysResourceKind,
+		Normalize: normalizeKey,
+		Validate: func(_ string, spec json.RawMessage) error {
+			var key Key
+			if err := decode(spec, &key); err != nil {
+				return err
+			}
+			if key.Tenant == "" {
+				return fmt.Errorf("tenant is required")
+			}
+			if hash, err := hex.DecodeString(key.SecretHash); err != nil || len(hash) != sha256.Size {
+				return fmt.Errorf("secret or a hex sha256 secretHash is required")
+			}
+			return nil
+		},
+	})
+	reg.OnChange(constants.KeysResourceKind, func() { reloadRoutes(reg) })
+}
+
+// HashSecret is used to get the hash an api key secret is stored as
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeKey is used to replace a plain secret with its hash before the key is stored
+func normalizeKey(_ string, spec json.RawMessage) (json.RawMessage, error) {
+	var key Key
+	if err := decode(spec, &key); err != nil {
+		return nil, err
+	}
+	if key.Secret != "" {
+		key.SecretHash = HashSecret(key.Secret)
+		key.Secret = ""
+	}
+	return json.Marshal(key)
+}
+
+// decode is used to strictly decode a spec, so that misspelled fields are reported instead of ignored
+func decode(spec json.RawMessage, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(spec))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(value); err != nil {
+		return fmt.Errorf("invalid spec : %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+)
+
+// Config is the configuration of the export of the spans
+type Config struct {
+	// Endpoint is the base url of the OTLP/HTTP receiver of the collector, e.g. http://collector:4318, the spans
+	// are not exported without it but the trace context is still attached to the entries
+	Endpoint string `json:"-" mapstructure:"endpoint"`
+	// Headers are sent with every export, e.g. the authorization of a hosted collector
+	Headers map[string]string `json:"-" mapstructure:"headers"`
+	// ServiceName is the service.name resource attribute of the spans
+	ServiceName string `json:"serviceName" mapstructure:"serviceName"`
+	// BatchSize is the maximum number of spans per export
+	BatchSize int `json:"batchSize" mapstructure:"batchSize"`
+	// QueueSize is the number of spans kept waiting for an export, spans are dropped past it
+	QueueSize int `json:"queueSize" mapstructure:"queueSize"`
+	// FlushIntervalInMillis is the maximum time a span waits for an export
+	FlushIntervalInMillis int `json:"flushIntervalInMillis" mapstructure:"flushIntervalInMillis"`
+	// TimeoutInMillis is the timeout of an export
+	TimeoutInMillis int `json:"timeoutInMillis" mapstructure:"timeoutInMillis"`
+}
+
+// otlpSpan is a span in the OTLP/JSON encoding, with hex ids and the times as strings of unix nanos
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	TraceState        string          `json:"traceState,omitempty"`
+	Name              string          `json:"name"`
+	Kind              Kind            `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+// otlpStatus is the status of a span, the code is 0 when unset and 2 on error
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type exporter struct {
+	config Config
+	spans  chan otlpSpan
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+var (
+	mu sync.RWMutex
+	x  *exporter
+)
+
+// Init is used to start the export of the spans, the exporter of a previous call is flushed and stopped.
+// Spans are dropped until then and without an endpoint.
+func Init(ctx context.Context, config Config) error {
+	if config.ServiceName == "" {
+		config.ServiceName = constants.TracingServiceName
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = constants.DefaultTracingBatchSize
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = constants.DefaultTracingQueueSize
+	}
+	if config.FlushIntervalInMillis <= 0 {
+		config.FlushIntervalInMillis = constants.DefaultTracingFlushIntervalInMillis
+	}
+	if config.TimeoutInMillis <= 0 {
+		config.TimeoutInMillis = constants.DefaultTracingTimeoutInMillis
+	}
+	var next *exporter
+	if config.Endpoint != "" {
+		if !strings.HasPrefix(config.Endpoint, "http://") && !strings.HasPrefix(config.Endpoint, "https://") {
+			return fmt.Errorf("tracing endpoint %s has to be an http url", config.Endpoint)
+		}
+		next = &exporter{config: config, spans: make(chan otlpSpan, config.QueueSize),
+			stop: make(chan struct{}), done: make(chan struct{})}
+		go next.run(ctx)
+	}
+	mu.Lock()
+	previous := x
+	x = next
+	mu.Unlock()
+	if previous != nil {
+		close(previous.stop)
+		<-previous.done
+	}
+	return nil
+}
+
+// export is used to queue the ended span for export, it is dropped when the queue is full
+func export(s *Span, end time.Time, err error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if x == nil {
+		return
+	}
+	span := otlpSpan{
+		TraceID:           s.context.TraceID,
+		SpanID:            s.context.SpanID,
+		ParentSpanID:      s.parentID,
+		TraceState:        s.context.State,
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(end.UnixNano(), 10),
+		Attributes:        attributes(s.attributes),
+	}
+	if err != nil {
+		span.Status = otlpStatus{Code: 2, Message: err.Error()}
+	}
+	select {
+	case x.spans <- span:
+	default:
+	}
+}
+
+// run is used to export the spans in batches, when the batch is full or the flush interval elapsed
+func (e *exporter) run(ctx context.Context) {
+	defer close(e.done)
+	ticker := time.NewTicker(time.Duration(e.config.FlushIntervalInMillis) * time.Millisecond)
+	defer ticker.Stop()
+	batch := make([]otlpSpan, 0, e.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.post(batch); err != nil {
+			log.Error(ctx).Err(err).Int("spans", len(batch)).Msg("error exporting spans")
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case span := <-e.spans:
+			if batch = append(batch, span); len(batch) >= e.config.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			e.drain(&batch)
+			flush()
+			return
+		case <-ctx.Done():
+			e.drain(&batch)
+			flush()
+			return
+		}
+	}
+}
+
+// drain is used to move the queued spans to the batch, up to its size
+func (e *exporter) drain(batch *[]otlpSpan) {
+	for len(*batch) < e.config.BatchSize {
+		select {
+		case span := <-e.spans:
+			*batch = append(*batch, span)
+		default:
+			return
+		}
+	}
+}
+
+// post is used to send the spans to the collector as an OTLP/JSON export request
+func (e *exporter) post(spans []otlpSpan) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": attributes(map[string]interface{}{"service.name": e.config.ServiceName}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": constants.TracingScopeName},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding spans : %w", err)
+	}
+	headers := map[string]string{"Content-Type": constants.JSONMediaType}
+	for key, value := range e.config.Headers {
+		headers[key] = value
+	}
+	response, err := httpclient.POSTWithTimeout(strings.TrimSuffix(e.config.Endpoint, "/")+constants.OTLPTracesPath,
+		headers, bytes.NewReader(body), time.Duration(e.config.TimeoutInMillis)*time.Millisecond)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("collector responded %d", response.StatusCode)
+	}
+	return nil
+}
+
+// attributes is used to encode the attributes as OTLP key values, sorted by key
+func attributes(values map[string]interface{}) []otlpAttribute {
+	encoded := make([]otlpAttribute, 0, len(values))
+	for key, value := range values {
+		var v map[string]interface{}
+		switch typed := value.(type) {
+		case bool:
+			v = map[string]interface{}{"boolValue": typed}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(typed)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(typed, 10)}
+		case float64:
+			v = map[string]interface{}{"doubleValue": typed}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(typed)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: key, Value: v})
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		return encoded[i].Key < encoded[j].Key
+	})
+	return encoded
+}
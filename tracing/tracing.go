@@ -0,0 +1,171 @@
+// Package tracing propagates the W3C trace context of the requests to the entries they carry, so that logs can
+// be correlated to traces, and records the spans of the ingestion and of the sink writes. The spans are exported
+// to an OpenTelemetry collector over OTLP/HTTP when one is configured.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+)
+
+// Kind is the OpenTelemetry kind of a span
+type Kind int
+
+// The kinds of the spans of the service
+const (
+	KindInternal Kind = 1
+	KindServer   Kind = 2
+	KindClient   Kind = 3
+)
+
+// FlagSampled is the trace flag of the traces that are recorded
+const FlagSampled byte = 1
+
+var traceparentRex = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$`)
+
+// SpanContext identifies a span of a trace, as carried by the traceparent and tracestate headers
+type SpanContext struct {
+	TraceID string
+	SpanID  string
+	Flags   byte
+	State   string
+}
+
+// Valid is used to check whether the ids are set and not all zeros, as the spec requires
+func (s SpanContext) Valid() bool {
+	return len(s.TraceID) == 32 && len(s.SpanID) == 16 && strings.Trim(s.TraceID, "0") != "" &&
+		strings.Trim(s.SpanID, "0") != ""
+}
+
+// Sampled is used to check whether the trace is recorded
+func (s SpanContext) Sampled() bool {
+	return s.Flags&FlagSampled != 0
+}
+
+// Traceparent is used to get the traceparent header of the span
+func (s SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", s.TraceID, s.SpanID, s.Flags)
+}
+
+// Extract is used to read the span context of the caller from the traceparent and tracestate headers
+func Extract(header http.Header) (SpanContext, bool) {
+	match := traceparentRex.FindStringSubmatch(strings.TrimSpace(header.Get(constants.TraceparentHeader)))
+	// version ff is invalid, later versions may only add fields after the flags
+	if match == nil || match[1] == "ff" {
+		return SpanContext{}, false
+	}
+	flags, _ := hex.DecodeString(match[4])
+	s := SpanContext{TraceID: match[2], SpanID: match[3], Flags: flags[0],
+		State: strings.Join(header.Values(constants.TracestateHeader), ",")}
+	return s, s.Valid()
+}
+
+// Inject is used to write the span context to the traceparent and tracestate headers
+func Inject(header http.Header, s SpanContext) {
+	header.Set(constants.TraceparentHeader, s.Traceparent())
+	if s.State != "" {
+		header.Set(constants.TracestateHeader, s.State)
+	}
+}
+
+type remoteKey struct{}
+
+type spanKey struct{}
+
+// ContextWithRemote is used to get a context continuing the trace of the caller
+func ContextWithRemote(ctx context.Context, remote SpanContext) context.Context {
+	return context.WithValue(ctx, remoteKey{}, remote)
+}
+
+// FromContext is used to get the span of the context, the one of the caller when no span was started
+func FromContext(ctx context.Context) (SpanContext, bool) {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok {
+		return span.context, true
+	}
+	remote, ok := ctx.Value(remoteKey{}).(SpanContext)
+	return remote, ok
+}
+
+// Span is an operation of a trace, it is exported when it ends
+type Span struct {
+	context    SpanContext
+	parentID   string
+	name       string
+	kind       Kind
+	start      time.Time
+	attributes map[string]interface{}
+}
+
+// Start is used to start a span, a child of the span of the context or the root of a new trace without one
+func Start(ctx context.Context, name string, kind Kind) (context.Context, *Span) {
+	span := &Span{name: name, kind: kind, start: time.Now(), attributes: make(map[string]interface{})}
+	if parent, ok := FromContext(ctx); ok && parent.Valid() {
+		span.context = SpanContext{TraceID: parent.TraceID, Flags: parent.Flags, State: parent.State}
+		span.parentID = parent.SpanID
+	} else {
+		span.context = SpanContext{TraceID: randomID(16), Flags: FlagSampled}
+	}
+	span.context.SpanID = randomID(8)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// StartFromEntry is used to start a span in the trace the entry was logged in, nothing is started for the entries
+// without a trace
+func StartFromEntry(ctx context.Context, entry models.LogEntry, name string, kind Kind) (context.Context, *Span) {
+	if entry.TraceID == "" || entry.SpanID == "" {
+		return ctx, nil
+	}
+	return Start(ContextWithRemote(ctx, SpanContext{TraceID: entry.TraceID, SpanID: entry.SpanID,
+		Flags: FlagSampled}), name, kind)
+}
+
+// Context is used to get the span context of the span
+func (s *Span) Context() SpanContext {
+	return s.context
+}
+
+// SetAttribute is used to describe the span, the values are strings, integers or booleans
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s != nil {
+		s.attributes[key] = value
+	}
+}
+
+// End is used to end the span, it failed when the error is not nil. Nil spans are ignored.
+func (s *Span) End(err error) {
+	if s == nil || !s.context.Sampled() {
+		return
+	}
+	export(s, time.Now(), err)
+}
+
+// Annotate is used to attach the trace of the context to the entry, the span of the caller when the request came
+// with a traceparent so the entry is correlated to the operation that logged it. The trace the entry carries wins.
+func Annotate(ctx context.Context, entry *models.LogEntry) {
+	if entry.TraceID != "" {
+		return
+	}
+	s, ok := ctx.Value(remoteKey{}).(SpanContext)
+	if !ok || !s.Valid() {
+		if s, ok = FromContext(ctx); !ok {
+			return
+		}
+	}
+	entry.TraceID, entry.SpanID = s.TraceID, s.SpanID
+}
+
+// randomID is used to get a random hex id of the number of bytes
+func randomID(n int) string {
+	id := make([]byte, n)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
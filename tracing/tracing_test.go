@@ -0,0 +1,88 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/tracing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	header := http.Header{}
+	header.Set(constants.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(constants.TracestateHeader, "congo=t61rcWkgMzE")
+	remote, ok := tracing.Extract(header)
+	assert.True(t, ok)
+	assert.Equal(t, tracing.SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7",
+		Flags: tracing.FlagSampled, State: "congo=t61rcWkgMzE"}, remote)
+
+	for _, invalid := range []string{
+		"", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+	} {
+		header.Set(constants.TraceparentHeader, invalid)
+		_, ok = tracing.Extract(header)
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestExport(t *testing.T) {
+	requests := make(chan map[string]interface{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, constants.OTLPTracesPath, r.URL.Path)
+		body, _ := io.ReadAll(r.Body)
+		var request map[string]interface{}
+		assert.NoError(t, json.Unmarshal(body, &request))
+		requests <- request
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, tracing.Init(ctx, tracing.Config{Endpoint: server.URL, BatchSize: 2}))
+
+	remote := tracing.SpanContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7",
+		Flags: tracing.FlagSampled}
+	requestCtx, serverSpan := tracing.Start(tracing.ContextWithRemote(ctx, remote), "POST /v1/logger",
+		tracing.KindServer)
+	// the entry is correlated to the span of the caller that logged it
+	var entry models.LogEntry
+	tracing.Annotate(requestCtx, &entry)
+	assert.Equal(t, remote.TraceID, entry.TraceID)
+	assert.Equal(t, remote.SpanID, entry.SpanID)
+
+	_, sinkSpan := tracing.StartFromEntry(ctx, entry, "sink http", tracing.KindClient)
+	sinkSpan.SetAttribute(constants.SinkKey, "http")
+	sinkSpan.End(assert.AnError)
+	serverSpan.End(nil)
+
+	var request map[string]interface{}
+	select {
+	case request = <-requests:
+	case <-time.After(2 * time.Second):
+		t.Fatal("spans were not exported")
+	}
+	resource := request["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	spans := resource["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	assert.Len(t, spans, 2)
+	sink, srv := spans[0].(map[string]interface{}), spans[1].(map[string]interface{})
+	assert.Equal(t, remote.TraceID, sink["traceId"])
+	assert.Equal(t, remote.SpanID, sink["parentSpanId"])
+	assert.Equal(t, float64(tracing.KindClient), sink["kind"])
+	assert.Equal(t, float64(2), sink["status"].(map[string]interface{})["code"])
+	assert.Equal(t, remote.SpanID, srv["parentSpanId"])
+	assert.Equal(t, "POST /v1/logger", srv["name"])
+
+	// entries without a trace start no span
+	_, span := tracing.StartFromEntry(ctx, models.LogEntry{}, "sink http", tracing.KindClient)
+	assert.Nil(t, span)
+}
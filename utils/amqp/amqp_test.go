@@ -0,0 +1,136 @@
+package amqp_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/utils/amqp"
+	"github.com/stretchr/testify/assert"
+)
+
+// broker is a fake broker speaking just enough of the protocol for one connection
+type broker struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (b *broker) write(kind byte, channel uint16, payload []byte) {
+	frame := []byte{kind, byte(channel >> 8), byte(channel), 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(frame[3:], uint32(len(payload)))
+	_, err := b.conn.Write(append(append(frame, payload...), 0xce))
+	assert.NoError(b.t, err)
+}
+
+func (b *broker) method(channel, class, id uint16, args ...byte) {
+	b.write(1, channel, append([]byte{byte(class >> 8), byte(class), byte(id >> 8), byte(id)}, args...))
+}
+
+// read is used to read the next frame and get its type and payload
+func (b *broker) read() (byte, []byte) {
+	header := make([]byte, 7)
+	_, err := io.ReadFull(b.reader, header)
+	assert.NoError(b.t, err)
+	payload := make([]byte, binary.BigEndian.Uint32(header[3:])+1)
+	_, err = io.ReadFull(b.reader, payload)
+	assert.NoError(b.t, err)
+	return header[0], payload[:len(payload)-1]
+}
+
+// expect is used to read method frames, skipping heartbeats, until the class and method
+func (b *broker) expect(class, id uint16) []byte {
+	for {
+		kind, payload := b.read()
+		if kind != 1 {
+			continue
+		}
+		assert.Equal(b.t, []uint16{class, id},
+			[]uint16{binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])})
+		return payload[4:]
+	}
+}
+
+func shortstr(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func longstr(s string) []byte {
+	return append([]byte{0, 0, 0, byte(len(s))}, s...)
+}
+
+func TestPublishAndConsume(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	published := make(chan []byte, 1)
+	acked := make(chan uint64, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		b := &broker{t: t, conn: conn, reader: bufio.NewReader(conn)}
+		header := make([]byte, 8)
+		_, _ = io.ReadFull(b.reader, header)
+		assert.Equal(t, "AMQP\x00\x00\x09\x01", string(header))
+		b.method(0, 10, 10, append(append([]byte{0, 9, 0, 0, 0, 0}, longstr("PLAIN AMQPLAIN")...),
+			longstr("en_US")...)...)
+		startOk := b.expect(10, 11)
+		assert.True(t, bytes.Contains(startOk, []byte("\x00user\x00secret")))
+		b.method(0, 10, 30, 0, 0, 0, 2, 0, 0, 0, 10)
+		b.expect(10, 31)
+		assert.Equal(t, shortstr("logs"), b.expect(10, 40)[:5])
+		b.method(0, 10, 41, 0)
+		b.expect(20, 10)
+		b.method(1, 20, 11, longstr("")...)
+
+		b.expect(85, 10)
+		b.method(1, 85, 11)
+		b.expect(60, 40)
+		_, contentHeader := b.read()
+		_, body := b.read()
+		assert.Equal(t, uint64(len(body)), binary.BigEndian.Uint64(contentHeader[4:]))
+		published <- body
+		b.method(1, 60, 80, 0, 0, 0, 0, 0, 0, 0, 1, 0)
+
+		b.expect(60, 10)
+		b.method(1, 60, 11)
+		b.expect(60, 20)
+		b.method(1, 60, 21, shortstr("ctag")...)
+		deliver := append(shortstr("ctag"), 0, 0, 0, 0, 0, 0, 0, 7, 0)
+		deliver = append(append(deliver, shortstr("logs")...), shortstr("app.error")...)
+		b.method(1, 60, 60, deliver...)
+		properties := append([]byte{0, 60, 0, 0, 0, 0, 0, 0, 0, 0, 0, 5, 0x80, 0}, shortstr("text/plain")...)
+		b.write(2, 1, properties)
+		b.write(3, 1, []byte("hello"))
+		ack := b.expect(60, 80)
+		acked <- binary.BigEndian.Uint64(ack)
+		b.expect(10, 50)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := amqp.Dial(ctx, "amqp://user:secret@"+listener.Addr().String()+"/logs")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, conn.Publish(ctx, "logs", "app.error", "application/json", []byte(`{"type":"app"}`)))
+	assert.Equal(t, `{"type":"app"}`, string(<-published))
+
+	deliveries, err := conn.Consume("logs", 10)
+	assert.NoError(t, err)
+	delivery := <-deliveries
+	assert.Equal(t, "hello", string(delivery.Body))
+	assert.Equal(t, "text/plain", delivery.ContentType)
+	assert.Equal(t, "app.error", delivery.RoutingKey)
+	assert.NoError(t, conn.Ack(delivery.Tag))
+	assert.Equal(t, uint64(7), <-acked)
+	assert.NoError(t, conn.Close())
+}
@@ -0,0 +1,225 @@
+package amqp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// frame types
+const (
+	frameMethod    = 1
+	frameHeader    = 2
+	frameBody      = 3
+	frameHeartbeat = 8
+	frameEnd       = 0xce
+)
+
+// class and method ids of the methods used by the client
+const (
+	classConnection = 10
+	classChannel    = 20
+	classBasic      = 60
+	classConfirm    = 85
+
+	methodConnectionStart   = 10
+	methodConnectionStartOk = 11
+	methodConnectionTune    = 30
+	methodConnectionTuneOk  = 31
+	methodConnectionOpen    = 40
+	methodConnectionOpenOk  = 41
+	methodConnectionClose   = 50
+	methodConnectionCloseOk = 51
+
+	methodChannelOpen    = 10
+	methodChannelOpenOk  = 11
+	methodChannelClose   = 40
+	methodChannelCloseOk = 41
+
+	methodBasicQos       = 10
+	methodBasicQosOk     = 11
+	methodBasicConsume   = 20
+	methodBasicConsumeOk = 21
+	methodBasicPublish   = 40
+	methodBasicDeliver   = 60
+	methodBasicAck       = 80
+	methodBasicNack      = 120
+
+	methodConfirmSelect   = 10
+	methodConfirmSelectOk = 11
+)
+
+// property flags of the basic content header
+const (
+	propertyContentType  = 0x8000
+	propertyDeliveryMode = 0x1000
+)
+
+var errFrameEnd = errors.New("amqp frame end missing")
+
+type frame struct {
+	kind    byte
+	channel uint16
+	payload []byte
+}
+
+func readFrame(r *bufio.Reader, maxSize uint32) (frame, error) {
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return frame{}, err
+	}
+	size := binary.BigEndian.Uint32(header[3:])
+	if maxSize > 0 && size > maxSize {
+		return frame{}, fmt.Errorf("amqp frame of %d bytes is larger than %d", size, maxSize)
+	}
+	payload := make([]byte, size+1)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return frame{}, err
+	}
+	if payload[size] != frameEnd {
+		return frame{}, errFrameEnd
+	}
+	return frame{kind: header[0], channel: binary.BigEndian.Uint16(header[1:]), payload: payload[:size]}, nil
+}
+
+func (f frame) write(w io.Writer) error {
+	buffer := make([]byte, 7, 8+len(f.payload))
+	buffer[0] = f.kind
+	binary.BigEndian.PutUint16(buffer[1:], f.channel)
+	binary.BigEndian.PutUint32(buffer[3:], uint32(len(f.payload)))
+	buffer = append(append(buffer, f.payload...), frameEnd)
+	_, err := w.Write(buffer)
+	return err
+}
+
+// method is a decoded method frame
+type method struct {
+	class, id uint16
+	args      *reader
+}
+
+func (m method) is(class, id uint16) bool {
+	return m.class == class && m.id == id
+}
+
+func parseMethod(payload []byte) (method, error) {
+	if len(payload) < 4 {
+		return method{}, fmt.Errorf("amqp method frame is truncated")
+	}
+	return method{
+		class: binary.BigEndian.Uint16(payload),
+		id:    binary.BigEndian.Uint16(payload[2:]),
+		args:  &reader{data: payload[4:]},
+	}, nil
+}
+
+// writer encodes method arguments
+type writer struct {
+	bytes.Buffer
+}
+
+func newMethod(class, id uint16) *writer {
+	w := &writer{}
+	w.short(class)
+	w.short(id)
+	return w
+}
+
+func (w *writer) octet(v byte) *writer {
+	w.WriteByte(v)
+	return w
+}
+
+func (w *writer) short(v uint16) *writer {
+	_ = binary.Write(w, binary.BigEndian, v)
+	return w
+}
+
+func (w *writer) long(v uint32) *writer {
+	_ = binary.Write(w, binary.BigEndian, v)
+	return w
+}
+
+func (w *writer) longlong(v uint64) *writer {
+	_ = binary.Write(w, binary.BigEndian, v)
+	return w
+}
+
+func (w *writer) shortstr(v string) *writer {
+	if len(v) > 255 {
+		v = v[:255]
+	}
+	w.WriteByte(byte(len(v)))
+	w.WriteString(v)
+	return w
+}
+
+func (w *writer) longstr(v string) *writer {
+	w.long(uint32(len(v)))
+	w.WriteString(v)
+	return w
+}
+
+// table writes a field table of string values, which is all the client sends
+func (w *writer) table(values map[string]string) *writer {
+	var table writer
+	for key, value := range values {
+		table.shortstr(key)
+		table.octet('S')
+		table.longstr(value)
+	}
+	w.long(uint32(table.Len()))
+	w.Write(table.Bytes())
+	return w
+}
+
+// reader decodes method arguments, the first error sticks
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if len(r.data) < n {
+		r.err = fmt.Errorf("amqp method arguments are truncated")
+		return make([]byte, n)
+	}
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *reader) octet() byte {
+	return r.take(1)[0]
+}
+
+func (r *reader) short() uint16 {
+	return binary.BigEndian.Uint16(r.take(2))
+}
+
+func (r *reader) long() uint32 {
+	return binary.BigEndian.Uint32(r.take(4))
+}
+
+func (r *reader) longlong() uint64 {
+	return binary.BigEndian.Uint64(r.take(8))
+}
+
+func (r *reader) shortstr() string {
+	return string(r.take(int(r.octet())))
+}
+
+func (r *reader) longstr() string {
+	return string(r.take(int(r.long())))
+}
+
+// skipTable skips a field table, the client does not need the server tables
+func (r *reader) skipTable() {
+	r.take(int(r.long()))
+}
@@ -0,0 +1,21 @@
+package buildinfo_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/utils/buildinfo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	buildinfo.Version, buildinfo.Commit, buildinfo.BuildTime = "1.2.0", "abc123", "2024-03-05T10:00:00Z"
+	defer func() {
+		buildinfo.Version, buildinfo.Commit, buildinfo.BuildTime = "", "", ""
+	}()
+	info := buildinfo.Get()
+	assert.Equal(t, "1.2.0", info.Version)
+	assert.Equal(t, "abc123", info.Commit)
+	assert.Equal(t, "2024-03-05T10:00:00Z", info.BuildTime)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+}
@@ -1,8 +1,13 @@
 package configs
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"github.com/angel-one/nbu-logger-service/constants"
 	"github.com/spf13/viper"
+	"os"
+	"strings"
 	"sync"
 )
 
@@ -38,13 +43,17 @@ func Get(name string) (*viper.Viper, error) {
 	provider := viper.New()
 	provider.SetConfigName(name)
 	provider.AddConfigPath(baseConfigPath)
+	// the environment overrides the keys of the file, e.g. NBU_LOGGER_PIPELINE_MINLEVEL for minLevel
+	provider.SetEnvPrefix(constants.EnvPrefix + "_" + name)
+	provider.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	provider.AutomaticEnv()
 	err := provider.ReadInConfig()
 	if err != nil {
 		// config not found or some other parsing errors
 		return nil, fmt.Errorf("config %s error : %v", name, err.Error())
 	}
 
-	// add a watcher for this provider
+	// add a watcher for this provider, the components reload their tunables in its OnConfigChange
 	provider.WatchConfig()
 
 	// successfully found config, store it for future use
@@ -52,3 +61,24 @@ func Get(name string) (*viper.Viper, error) {
 
 	return provider, nil
 }
+
+// Checksums is used to get the sha256 of the files of the loaded configurations by their name,
+// so that processes running with different configurations can be told apart
+func Checksums() map[string]string {
+	checksums := make(map[string]string)
+	if p == nil {
+		return checksums
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for name, provider := range p.providers {
+		content, err := os.ReadFile(provider.ConfigFileUsed())
+		if err != nil {
+			checksums[name] = ""
+			continue
+		}
+		sum := sha256.Sum256(content)
+		checksums[name] = hex.EncodeToString(sum[:])
+	}
+	return checksums
+}
@@ -1,32 +1,73 @@
 package flags
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/angel-one/nbu-logger-service/constants"
 	flag "github.com/spf13/pflag"
 )
 
-var (
-	env            = flag.String(constants.EnvKey, constants.EnvDefaultValue, constants.EnvUsage)
-	port           = flag.Int(constants.PortKey, constants.PortDefaultValue, constants.PortUsage)
-	baseConfigPath = flag.String(constants.BaseConfigPathKey, constants.BaseConfigPathDefaultValue,
-		constants.BaseConfigPathUsage)
-)
-
-func init() {
-	flag.Parse()
+// Config is the configuration of the process given on the command line
+type Config struct {
+	// Env is the application.yml runtime environment
+	Env string
+	// Port is the application.yml port number where the process will be started
+	Port int
+	// BaseConfigPath is the path that holds the configuration files
+	BaseConfigPath string
+	// Mode is the role of the process when entries go through the queue, api, worker or both when empty
+	Mode string
+	// TestRules is the rules test suite to run instead of starting the service
+	TestRules string
 }
 
-// Env is the application.yml runtime environment
-func Env() string {
-	return *env
+// Parse is used to read and validate the flags in the arguments, without the program name
+func Parse(arguments []string) (Config, error) {
+	var config Config
+	set := flag.NewFlagSet(constants.ApplicationName, flag.ContinueOnError)
+	set.StringVar(&config.Env, constants.EnvKey, constants.EnvDefaultValue, constants.EnvUsage)
+	set.IntVar(&config.Port, constants.PortKey, constants.PortDefaultValue, constants.PortUsage)
+	set.StringVar(&config.BaseConfigPath, constants.BaseConfigPathKey, constants.BaseConfigPathDefaultValue,
+		constants.BaseConfigPathUsage)
+	set.StringVar(&config.Mode, constants.ModeKey, "", constants.ModeUsage)
+	set.StringVar(&config.TestRules, constants.TestRulesKey, "", constants.TestRulesUsage)
+	if err := set.Parse(arguments); err != nil {
+		return config, err
+	}
+	// the flags not given on the command line are read from the environment, e.g. NBU_LOGGER_BASE_CONFIG_PATH
+	var err error
+	set.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(EnvName(f.Name))
+		if err != nil || f.Changed || !ok {
+			return
+		}
+		if e := set.Set(f.Name, value); e != nil {
+			err = fmt.Errorf("invalid %s : %w", EnvName(f.Name), e)
+		}
+	})
+	if err != nil {
+		return config, err
+	}
+	return config, config.Validate()
 }
 
-// Port is the application.yml port number where the process will be started
-func Port() int {
-	return *port
+// EnvName is used to get the environment variable of the flag
+func EnvName(name string) string {
+	return constants.EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
 }
 
-// BaseConfigPath is the path that holds the configuration files
-func BaseConfigPath() string {
-	return *baseConfigPath
+// Validate is used to check the values of the flags
+func (c Config) Validate() error {
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("%s %d is not a valid port", constants.PortKey, c.Port)
+	}
+	if c.Mode != "" && c.Mode != constants.APIMode && c.Mode != constants.WorkerMode {
+		return fmt.Errorf("%s has to be %s or %s", constants.ModeKey, constants.APIMode, constants.WorkerMode)
+	}
+	if c.BaseConfigPath == "" {
+		return fmt.Errorf("%s is required", constants.BaseConfigPathKey)
+	}
+	return nil
 }
@@ -8,14 +8,39 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func TestPort(t *testing.T) {
-	assert.Equal(t, constants.PortDefaultValue, flags.Port())
+func TestParseDefaults(t *testing.T) {
+	config, err := flags.Parse(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.PortDefaultValue, config.Port)
+	assert.Equal(t, constants.EnvDefaultValue, config.Env)
+	assert.Equal(t, constants.BaseConfigPathDefaultValue, config.BaseConfigPath)
+	assert.Empty(t, config.Mode)
 }
 
-func TestEnv(t *testing.T) {
-	assert.Equal(t, constants.EnvDefaultValue, flags.Env())
+func TestParse(t *testing.T) {
+	config, err := flags.Parse([]string{"--port", "9090", "--env=prod", "--mode", constants.WorkerMode})
+	assert.NoError(t, err)
+	assert.Equal(t, flags.Config{Env: "prod", Port: 9090, BaseConfigPath: constants.BaseConfigPathDefaultValue,
+		Mode: constants.WorkerMode}, config)
+
+	_, err = flags.Parse([]string{"--mode", "both"})
+	assert.Error(t, err)
+	_, err = flags.Parse([]string{"--port", "70000"})
+	assert.Error(t, err)
+	_, err = flags.Parse([]string{"--unknown"})
+	assert.Error(t, err)
 }
 
-func TestBaseConfigPath(t *testing.T) {
-	assert.Equal(t, constants.BaseConfigPathDefaultValue, flags.BaseConfigPath())
+func TestParseEnv(t *testing.T) {
+	t.Setenv("NBU_LOGGER_PORT", "9191")
+	t.Setenv("NBU_LOGGER_BASE_CONFIG_PATH", "/etc/logger")
+	config, err := flags.Parse([]string{"--port", "9090"})
+	assert.NoError(t, err)
+	// the command line wins over the environment
+	assert.Equal(t, 9090, config.Port)
+	assert.Equal(t, "/etc/logger", config.BaseConfigPath)
+
+	t.Setenv("NBU_LOGGER_PORT", "http")
+	_, err = flags.Parse(nil)
+	assert.Error(t, err)
 }
@@ -0,0 +1,85 @@
+// Package grpcwire implements the grpc framing over http2 the grpc endpoints of the service are served with,
+// so that they do not need generated code or a grpc runtime.
+package grpcwire
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+// the grpc status codes the endpoints respond with
+const (
+	OK                = 0
+	InvalidArgument   = 3
+	ResourceExhausted = 8
+	Unimplemented     = 12
+	Internal          = 13
+	Unavailable       = 14
+)
+
+// ReadMessage is used to read one length prefixed grpc message, decompressing it when flagged
+func ReadMessage(r io.Reader, encoding string) ([]byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("truncated grpc message")
+		}
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > constants.MaxGRPCMessageBytes {
+		return nil, fmt.Errorf("grpc message is larger than %d bytes", constants.MaxGRPCMessageBytes)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("truncated grpc message")
+	}
+	if header[0] == 0 {
+		return data, nil
+	}
+	if encoding != "gzip" {
+		return nil, fmt.Errorf("unsupported grpc encoding %q", encoding)
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	data, err = io.ReadAll(io.LimitReader(reader, constants.MaxGRPCMessageBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > constants.MaxGRPCMessageBytes {
+		return nil, fmt.Errorf("grpc message is larger than %d bytes", constants.MaxGRPCMessageBytes)
+	}
+	return data, nil
+}
+
+// WriteMessage is used to write one uncompressed length prefixed grpc message
+func WriteMessage(w io.Writer, data []byte) error {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	if _, err := w.Write(append(header, data...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Prepare is used to announce the grpc response and its status trailers, before anything is written
+func Prepare(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", constants.GRPCMediaType)
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+}
+
+// Finish is used to end the response with the status, after the messages are written
+func Finish(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(status))
+	w.Header().Set("Grpc-Message", message)
+}
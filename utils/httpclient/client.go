@@ -40,7 +40,8 @@ type Config struct {
 	Timeout time.Duration `json:"timeout"`
 }
 
-var client *http.Client
+// client defaults to the standard client until Init is called
+var client = http.DefaultClient
 
 func Init(config Config) error {
 	log.Info(nil).Interface(constants.HTTPConfigKey, config).Msg("initializing http client")
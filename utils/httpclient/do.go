@@ -54,6 +54,14 @@ func doWithTimeoutAndRetries(request *http.Request, timeout time.Duration, retry
 	}
 	ctx := request.Context()
 	for attempt := 0; attempt <= retryCount; attempt++ {
+		// rewind the body for retries
+		if attempt > 0 && request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			request.Body = body
+		}
 		// do request
 		response, err := client.Do(request)
 		if ctx.Err() != nil {
@@ -0,0 +1,27 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// PATCH is used to make a patch request with the provided details
+func PATCH(url string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	return PATCHWithTimeout(url, headers, body, 0)
+}
+
+// PATCHWithTimeout is used to make a patch request with the provided details
+// 0 timeout means default timeout will be used
+func PATCHWithTimeout(url string, headers map[string]string, body io.Reader,
+	timeout time.Duration) (*http.Response, error) {
+	// create a request
+	request, err := getRequest(http.MethodPatch, url, headers, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request %w", err)
+	}
+
+	// patches are not retried as they are not always idempotent
+	return doWithTimeoutAndRetries(request, timeout, 0, 0, 0)
+}
@@ -0,0 +1,35 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// POST is used to make a post request with the provided details
+func POST(url string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	return POSTWithTimeout(url, headers, body, 0)
+}
+
+// POSTWithTimeout is used to make a post request with the provided details
+// 0 timeout means default timeout will be used
+func POSTWithTimeout(url string, headers map[string]string, body io.Reader,
+	timeout time.Duration) (*http.Response, error) {
+	return POSTWithTimeoutAndRetries(url, headers, body, timeout, 0, 0, 0)
+}
+
+// POSTWithTimeoutAndRetries is used to make a post request with the provided details
+// 0 timeout means default timeout will be used
+// the body has to support being read again for retries, so prefer bytes or strings readers
+func POSTWithTimeoutAndRetries(url string, headers map[string]string, body io.Reader, timeout time.Duration,
+	retryCount int, retryWaitTime time.Duration, retryMaxWaitTime time.Duration) (*http.Response, error) {
+	// create a request
+	request, err := getRequest(http.MethodPost, url, headers, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request %w", err)
+	}
+
+	// now time to execute with retry and backoff
+	return doWithTimeoutAndRetries(request, timeout, retryCount, retryWaitTime, retryMaxWaitTime)
+}
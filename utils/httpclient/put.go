@@ -0,0 +1,26 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// PUT is used to make a put request with the provided details
+func PUT(url string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	return PUTWithTimeout(url, headers, body, 0)
+}
+
+// PUTWithTimeout is used to make a put request with the provided details
+// 0 timeout means default timeout will be used
+func PUTWithTimeout(url string, headers map[string]string, body io.Reader,
+	timeout time.Duration) (*http.Response, error) {
+	// create a request
+	request, err := getRequest(http.MethodPut, url, headers, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request %w", err)
+	}
+
+	return doWithTimeoutAndRetries(request, timeout, 0, 0, 0)
+}
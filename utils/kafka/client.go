@@ -0,0 +1,108 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+)
+
+// Client is used to reach the brokers of a cluster, it keeps a connection to each broker it talked to.
+// It is not safe for concurrent use.
+type Client struct {
+	bootstrap    []string
+	tlsConfig    *tls.Config
+	brokers      map[int32]Broker
+	conns        map[int32]*Conn
+	coordinators map[string]int32
+	seed         *Conn
+}
+
+// NewClient is used to create a client for the cluster of the bootstrap brokers, given as host:port
+func NewClient(bootstrap []string, tlsConfig *tls.Config) *Client {
+	return &Client{bootstrap: bootstrap, tlsConfig: tlsConfig, brokers: make(map[int32]Broker),
+		conns: make(map[int32]*Conn), coordinators: make(map[string]int32)}
+}
+
+// Metadata is used to get the partitions of the topics, and to learn the brokers leading them
+func (c *Client) Metadata(ctx context.Context, topics []string) (Metadata, error) {
+	if c.seed == nil {
+		var errs []error
+		for _, address := range c.bootstrap {
+			conn, err := Dial(ctx, address, c.tlsConfig)
+			if err == nil {
+				c.seed = conn
+				break
+			}
+			errs = append(errs, err)
+		}
+		if c.seed == nil {
+			return Metadata{}, fmt.Errorf("no bootstrap broker is reachable : %w", errors.Join(errs...))
+		}
+	}
+	metadata, err := c.seed.Metadata(ctx, topics)
+	if err != nil {
+		_ = c.seed.Close()
+		c.seed = nil
+		return Metadata{}, err
+	}
+	for _, broker := range metadata.Brokers {
+		c.brokers[broker.ID] = broker
+	}
+	return metadata, nil
+}
+
+// Broker is used to get the connection to the broker, connecting to it when needed
+func (c *Client) Broker(ctx context.Context, id int32) (*Conn, error) {
+	if conn, ok := c.conns[id]; ok {
+		return conn, nil
+	}
+	broker, ok := c.brokers[id]
+	if !ok {
+		return nil, fmt.Errorf("kafka broker %d is unknown", id)
+	}
+	conn, err := Dial(ctx, broker.Address(), c.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	c.conns[id] = conn
+	return conn, nil
+}
+
+// Coordinator is used to get the connection to the broker coordinating the group, which is looked up once
+func (c *Client) Coordinator(ctx context.Context, group string) (*Conn, error) {
+	if id, ok := c.coordinators[group]; ok {
+		return c.Broker(ctx, id)
+	}
+	if c.seed == nil {
+		if _, err := c.Metadata(ctx, nil); err != nil {
+			return nil, err
+		}
+	}
+	broker, err := c.seed.FindCoordinator(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	c.brokers[broker.ID] = broker
+	c.coordinators[group] = broker.ID
+	return c.Broker(ctx, broker.ID)
+}
+
+// Drop is used to close the connection to the broker after it failed, the next call reconnects
+func (c *Client) Drop(id int32) {
+	if conn, ok := c.conns[id]; ok {
+		_ = conn.Close()
+		delete(c.conns, id)
+	}
+}
+
+// Close is used to close the connections
+func (c *Client) Close() {
+	for id := range c.conns {
+		c.Drop(id)
+	}
+	if c.seed != nil {
+		_ = c.seed.Close()
+		c.seed = nil
+	}
+}
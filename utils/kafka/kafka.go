@@ -0,0 +1,392 @@
+// Package kafka is a minimal Kafka client covering the metadata, offset and fetch calls a consumer needs.
+// The offsets of a group are committed without joining it, so a group is meant to be consumed by one client.
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	clientID        = "nbu-logger-service"
+	dialTimeout     = 10 * time.Second
+	requestTimeout  = 30 * time.Second
+	maxResponseSize = 64 << 20
+)
+
+// api keys and the versions used, which are the oldest ones current brokers still serve
+const (
+	apiFetch           = 1
+	apiListOffsets     = 2
+	apiMetadata        = 3
+	apiOffsetCommit    = 8
+	apiOffsetFetch     = 9
+	apiFindCoordinator = 10
+
+	fetchVersion           = 4
+	listOffsetsVersion     = 1
+	metadataVersion        = 1
+	offsetCommitVersion    = 2
+	offsetFetchVersion     = 1
+	findCoordinatorVersion = 0
+)
+
+// Earliest and Latest are the times of ListOffsets asking for the first and the next offset of a partition
+const (
+	Earliest int64 = -2
+	Latest   int64 = -1
+)
+
+// Error is an error code returned by a broker
+type Error int16
+
+// the error codes the consumers handle
+const (
+	ErrOffsetOutOfRange          Error = 1
+	ErrUnknownTopicOrPartition   Error = 3
+	ErrLeaderNotAvailable        Error = 5
+	ErrNotLeaderForPartition     Error = 6
+	ErrCoordinatorLoadInProgress Error = 14
+	ErrCoordinatorNotAvailable   Error = 15
+	ErrNotCoordinator            Error = 16
+)
+
+var errorNames = map[Error]string{
+	ErrOffsetOutOfRange:          "offset out of range",
+	ErrUnknownTopicOrPartition:   "unknown topic or partition",
+	ErrLeaderNotAvailable:        "leader not available",
+	ErrNotLeaderForPartition:     "not leader for partition",
+	ErrCoordinatorLoadInProgress: "coordinator load in progress",
+	ErrCoordinatorNotAvailable:   "coordinator not available",
+	ErrNotCoordinator:            "not coordinator",
+}
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return "kafka " + name
+	}
+	return fmt.Sprintf("kafka error %d", int16(e))
+}
+
+// Broker is a broker of the cluster
+type Broker struct {
+	ID   int32
+	Host string
+	Port int32
+}
+
+// Address is used to get the host:port of the broker
+func (b Broker) Address() string {
+	return net.JoinHostPort(b.Host, strconv.Itoa(int(b.Port)))
+}
+
+// Partition is a partition of a topic with the id of the broker leading it, -1 when it has no leader
+type Partition struct {
+	Topic  string
+	ID     int32
+	Leader int32
+}
+
+// Metadata is the brokers of the cluster and the partitions of the requested topics
+type Metadata struct {
+	Brokers    []Broker
+	Partitions []Partition
+}
+
+// Offsets are offsets by topic and partition
+type Offsets map[string]map[int32]int64
+
+// Set is used to set the offset of the partition
+func (o Offsets) Set(topic string, partition int32, offset int64) {
+	if o[topic] == nil {
+		o[topic] = make(map[int32]int64)
+	}
+	o[topic][partition] = offset
+}
+
+// FetchResult is the outcome of fetching a partition, Err is set when the broker failed it
+type FetchResult struct {
+	Topic         string
+	Partition     int32
+	HighWatermark int64
+	Records       []Record
+	Err           error
+}
+
+// Conn is a connection to a broker, requests on it are serialized
+type Conn struct {
+	conn        net.Conn
+	mu          sync.Mutex
+	correlation int32
+}
+
+// Dial is used to connect to the broker at host:port, over tls when a config is given
+func Dial(ctx context.Context, address string, tlsConfig *tls.Config) (*Conn, error) {
+	conn, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		config := tlsConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName, _, _ = net.SplitHostPort(address)
+		}
+		secure := tls.Client(conn, config)
+		if err = secure.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn = secure
+	}
+	return &Conn{conn: conn}, nil
+}
+
+// Close is used to close the connection
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// roundTrip is used to send a request and read its response, waiting for the broker at most the extra time
+func (c *Conn) roundTrip(ctx context.Context, key, version int16, body *writer, wait time.Duration) (*reader,
+	error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deadline := time.Now().Add(requestTimeout + wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	c.correlation++
+	var request writer
+	request.int32(0).int16(key).int16(version).int32(c.correlation).string(clientID)
+	request.Write(body.Bytes())
+	data := request.Bytes()
+	binary.BigEndian.PutUint32(data, uint32(len(data)-4))
+	if _, err := c.conn.Write(data); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header)
+	if size < 4 || size > maxResponseSize {
+		return nil, fmt.Errorf("kafka response of %d bytes is out of bounds", size)
+	}
+	if correlation := int32(binary.BigEndian.Uint32(header[4:])); correlation != c.correlation {
+		return nil, fmt.Errorf("kafka response %d does not match the request %d", correlation, c.correlation)
+	}
+	payload := make([]byte, size-4)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return nil, err
+	}
+	return &reader{data: payload}, nil
+}
+
+// Metadata is used to get the brokers and the partitions of the topics
+func (c *Conn) Metadata(ctx context.Context, topics []string) (Metadata, error) {
+	body := &writer{}
+	body.arrayLen(len(topics))
+	for _, topic := range topics {
+		body.string(topic)
+	}
+	r, err := c.roundTrip(ctx, apiMetadata, metadataVersion, body, 0)
+	if err != nil {
+		return Metadata{}, err
+	}
+	var metadata Metadata
+	for i, n := 0, r.arrayLen(); i < n; i++ {
+		broker := Broker{ID: r.int32(), Host: r.string(), Port: r.int32()}
+		r.nullString() // rack
+		metadata.Brokers = append(metadata.Brokers, broker)
+	}
+	r.int32() // controller id
+	for i, n := 0, r.arrayLen(); i < n; i++ {
+		code, topic := Error(r.int16()), r.string()
+		r.bool() // internal
+		if code != 0 && r.err == nil {
+			return Metadata{}, fmt.Errorf("topic %s : %w", topic, code)
+		}
+		for j, m := 0, r.arrayLen(); j < m; j++ {
+			r.int16() // partition error, a partition without a leader has -1 as its leader
+			partition := Partition{Topic: topic, ID: r.int32(), Leader: r.int32()}
+			for k, replicas := 0, r.arrayLen(); k < replicas; k++ {
+				r.int32()
+			}
+			for k, isr := 0, r.arrayLen(); k < isr; k++ {
+				r.int32()
+			}
+			metadata.Partitions = append(metadata.Partitions, partition)
+		}
+	}
+	return metadata, r.err
+}
+
+// ListOffsets is used to get the offsets of the partitions at the times, Earliest, Latest or the unix millis of
+// the first record written from then, -1 when there is none
+func (c *Conn) ListOffsets(ctx context.Context, times Offsets) (Offsets, error) {
+	body := &writer{}
+	body.int32(-1).arrayLen(len(times))
+	for topic, partitions := range times {
+		body.string(topic).arrayLen(len(partitions))
+		for partition, at := range partitions {
+			body.int32(partition).int64(at)
+		}
+	}
+	r, err := c.roundTrip(ctx, apiListOffsets, listOffsetsVersion, body, 0)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make(Offsets)
+	for i, n := 0, r.arrayLen(); i < n; i++ {
+		topic := r.string()
+		for j, m := 0, r.arrayLen(); j < m; j++ {
+			partition, code := r.int32(), Error(r.int16())
+			r.int64() // timestamp
+			offset := r.int64()
+			if code != 0 && r.err == nil {
+				return nil, fmt.Errorf("offsets of %s/%d : %w", topic, partition, code)
+			}
+			offsets.Set(topic, partition, offset)
+		}
+	}
+	return offsets, r.err
+}
+
+// Fetch is used to read the records of the partitions from their offsets, the broker answers once it has
+// some records or after the wait
+func (c *Conn) Fetch(ctx context.Context, offsets Offsets, wait time.Duration, maxBytes int32) ([]FetchResult,
+	error) {
+	body := &writer{}
+	body.int32(-1).int32(int32(wait / time.Millisecond)).int32(1).int32(maxBytes)
+	// only the committed records of transactions are read
+	body.int8(1).arrayLen(len(offsets))
+	for topic, partitions := range offsets {
+		body.string(topic).arrayLen(len(partitions))
+		for partition, offset := range partitions {
+			body.int32(partition).int64(offset).int32(maxBytes)
+		}
+	}
+	r, err := c.roundTrip(ctx, apiFetch, fetchVersion, body, wait)
+	if err != nil {
+		return nil, err
+	}
+	r.int32() // throttle time
+	var results []FetchResult
+	for i, n := 0, r.arrayLen(); i < n; i++ {
+		topic := r.string()
+		for j, m := 0, r.arrayLen(); j < m; j++ {
+			result := FetchResult{Topic: topic, Partition: r.int32()}
+			code := Error(r.int16())
+			result.HighWatermark = r.int64()
+			r.int64() // last stable offset
+			for k, aborted := 0, r.arrayLen(); k < aborted; k++ {
+				r.int64()
+				r.int64()
+			}
+			records := r.bytes()
+			if r.err != nil {
+				return nil, r.err
+			}
+			if code != 0 {
+				result.Err = code
+			} else if result.Records, err = decodeRecords(topic, result.Partition, records); err != nil {
+				result.Err = err
+			}
+			// the batches are whole, so they can start before the offset asked for
+			from := offsets[topic][result.Partition]
+			for len(result.Records) > 0 && result.Records[0].Offset < from {
+				result.Records = result.Records[1:]
+			}
+			results = append(results, result)
+		}
+	}
+	return results, r.err
+}
+
+// FindCoordinator is used to get the broker coordinating the group
+func (c *Conn) FindCoordinator(ctx context.Context, group string) (Broker, error) {
+	r, err := c.roundTrip(ctx, apiFindCoordinator, findCoordinatorVersion, (&writer{}).string(group), 0)
+	if err != nil {
+		return Broker{}, err
+	}
+	code := Error(r.int16())
+	broker := Broker{ID: r.int32(), Host: r.string(), Port: r.int32()}
+	if r.err != nil {
+		return Broker{}, r.err
+	}
+	if code != 0 {
+		return Broker{}, fmt.Errorf("coordinator of %s : %w", group, code)
+	}
+	return broker, nil
+}
+
+// OffsetFetch is used to get the offsets the group committed for the partitions, -1 when there is none
+func (c *Conn) OffsetFetch(ctx context.Context, group string, partitions map[string][]int32) (Offsets, error) {
+	body := &writer{}
+	body.string(group).arrayLen(len(partitions))
+	for topic, ids := range partitions {
+		body.string(topic).arrayLen(len(ids))
+		for _, id := range ids {
+			body.int32(id)
+		}
+	}
+	r, err := c.roundTrip(ctx, apiOffsetFetch, offsetFetchVersion, body, 0)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make(Offsets)
+	for i, n := 0, r.arrayLen(); i < n; i++ {
+		topic := r.string()
+		for j, m := 0, r.arrayLen(); j < m; j++ {
+			partition, offset := r.int32(), r.int64()
+			r.nullString() // metadata
+			if code := Error(r.int16()); code != 0 && r.err == nil {
+				return nil, fmt.Errorf("committed offset of %s/%d : %w", topic, partition, code)
+			}
+			offsets.Set(topic, partition, offset)
+		}
+	}
+	return offsets, r.err
+}
+
+// OffsetCommit is used to commit the offsets of the group, the offset of a partition being the next one to read
+func (c *Conn) OffsetCommit(ctx context.Context, group string, offsets Offsets) error {
+	body := &writer{}
+	// the group is not joined, which brokers accept as the commit of a standalone consumer
+	body.string(group).int32(-1).string("").int64(-1).arrayLen(len(offsets))
+	for topic, partitions := range offsets {
+		body.string(topic).arrayLen(len(partitions))
+		for partition, offset := range partitions {
+			body.int32(partition).int64(offset).nullString()
+		}
+	}
+	r, err := c.roundTrip(ctx, apiOffsetCommit, offsetCommitVersion, body, 0)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i, n := 0, r.arrayLen(); i < n; i++ {
+		topic := r.string()
+		for j, m := 0, r.arrayLen(); j < m; j++ {
+			partition := r.int32()
+			if code := Error(r.int16()); code != 0 {
+				errs = append(errs, fmt.Errorf("commit of %s/%d : %w", topic, partition, code))
+			}
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+	return errors.Join(errs...)
+}
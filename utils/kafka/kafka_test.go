@@ -0,0 +1,308 @@
+package kafka_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/utils/kafka"
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+const topic = "dbserver.inventory.orders"
+
+// buffer encodes the responses of the fake broker
+type buffer struct {
+	bytes.Buffer
+}
+
+func (b *buffer) int(size int, v int64) *buffer {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, uint64(v))
+	b.Write(data[8-size:])
+	return b
+}
+
+func (b *buffer) string(s string) *buffer {
+	b.int(2, int64(len(s)))
+	b.WriteString(s)
+	return b
+}
+
+func (b *buffer) varint(v int64) *buffer {
+	b.Write(binary.AppendVarint(nil, v))
+	return b
+}
+
+func (b *buffer) varBytes(data []byte) *buffer {
+	if data == nil {
+		return b.varint(-1)
+	}
+	b.varint(int64(len(data)))
+	b.Write(data)
+	return b
+}
+
+// request is the part of a request body the fake broker reads
+type request struct {
+	data []byte
+}
+
+func (r *request) take(n int) []byte {
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *request) int16() int16 {
+	return int16(binary.BigEndian.Uint16(r.take(2)))
+}
+
+func (r *request) int32() int32 {
+	return int32(binary.BigEndian.Uint32(r.take(4)))
+}
+
+func (r *request) int64() int64 {
+	return int64(binary.BigEndian.Uint64(r.take(8)))
+}
+
+func (r *request) string() string {
+	return string(r.take(int(r.int16())))
+}
+
+// batch is used to encode a record batch of the values from the base offset, compressed with the codec
+func batch(t *testing.T, baseOffset int64, codec int16, values ...string) []byte {
+	records := &buffer{}
+	for i, value := range values {
+		record := &buffer{}
+		record.int(1, 0).varint(0).varint(int64(i)).varBytes(nil).varBytes([]byte(value))
+		record.varint(1).varBytes([]byte("content-type")).varBytes([]byte("application/json"))
+		records.varint(int64(record.Len()))
+		records.Write(record.Bytes())
+	}
+	payload := records.Bytes()
+	switch codec {
+	case 1:
+		var compressed bytes.Buffer
+		writer := gzip.NewWriter(&compressed)
+		_, err := writer.Write(payload)
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Close())
+		payload = compressed.Bytes()
+	case 2:
+		payload = s2.EncodeSnappy(nil, payload)
+	case 3:
+		// a frame with a single block of literals, without checksums
+		frame := &buffer{}
+		frame.Write([]byte{0x04, 0x22, 0x4d, 0x18, 0x40, 0x40, 0xc0})
+		block := &buffer{}
+		length := len(payload)
+		if length < 15 {
+			block.int(1, int64(length<<4))
+		} else {
+			block.int(1, 0xf0)
+			for length -= 15; length >= 255; length -= 255 {
+				block.int(1, 255)
+			}
+			block.int(1, int64(length))
+		}
+		block.Write(payload)
+		size := make([]byte, 4)
+		binary.LittleEndian.PutUint32(size, uint32(block.Len()))
+		frame.Write(size)
+		frame.Write(block.Bytes())
+		frame.Write([]byte{0, 0, 0, 0})
+		payload = frame.Bytes()
+	case 4:
+		encoder, err := zstd.NewWriter(nil)
+		assert.NoError(t, err)
+		payload = encoder.EncodeAll(payload, nil)
+	}
+	body := &buffer{}
+	body.int(2, int64(codec)).int(4, int64(len(values)-1)).int(8, 1700000000000).int(8, 1700000000000)
+	body.int(8, -1).int(2, -1).int(4, -1).int(4, int64(len(values)))
+	body.Write(payload)
+	data := &buffer{}
+	data.int(8, baseOffset).int(4, int64(body.Len()+9)).int(4, 0).int(1, 2)
+	data.int(4, int64(crc32.Checksum(body.Bytes(), crc32.MakeTable(crc32.Castagnoli))))
+	data.Write(body.Bytes())
+	return data.Bytes()
+}
+
+// broker is a fake broker of a single partition, serving the calls of a consumer
+type broker struct {
+	t         *testing.T
+	host      string
+	port      int32
+	records   []byte
+	mu        sync.Mutex
+	committed int64
+	fetched   []int64
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		r := &request{data: make([]byte, binary.BigEndian.Uint32(header))}
+		if _, err := io.ReadFull(conn, r.data); err != nil {
+			return
+		}
+		key, _, correlation := r.int16(), r.int16(), r.int32()
+		r.string()
+		response := &buffer{}
+		response.int(4, 0).int(4, int64(correlation))
+		b.respond(key, r, response)
+		data := response.Bytes()
+		binary.BigEndian.PutUint32(data, uint32(len(data)-4))
+		if _, err := conn.Write(data); err != nil {
+			return
+		}
+	}
+}
+
+func (b *broker) respond(key int16, r *request, response *buffer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch key {
+	case 3:
+		response.int(4, 1).int(4, 1).string(b.host).int(4, int64(b.port)).int(2, -1)
+		response.int(4, 1).int(4, 1).int(2, 0).string(topic).int(1, 0)
+		response.int(4, 1).int(2, 0).int(4, 0).int(4, 1).int(4, 1).int(4, 1).int(4, 1).int(4, 1)
+	case 10:
+		response.int(2, 0).int(4, 1).string(b.host).int(4, int64(b.port))
+	case 9:
+		response.int(4, 1).string(topic).int(4, 1).int(4, 0).int(8, b.committed).int(2, -1).int(2, 0)
+	case 2:
+		response.int(4, 1).string(topic).int(4, 1).int(4, 0).int(2, 0).int(8, -1).int(8, 0)
+	case 1:
+		r.take(17)
+		r.int32()
+		r.string()
+		r.int32()
+		r.int32()
+		b.fetched = append(b.fetched, r.int64())
+		response.int(4, 0).int(4, 1).string(topic).int(4, 1).int(4, 0).int(2, 0).int(8, 5).int(8, 5).int(4, 0)
+		response.int(4, int64(len(b.records)))
+		response.Write(b.records)
+	case 8:
+		r.string()
+		r.int32()
+		r.string()
+		r.int64()
+		r.int32()
+		r.string()
+		r.int32()
+		r.int32()
+		b.committed = r.int64()
+		response.int(4, 1).string(topic).int(4, 1).int(4, 0).int(2, 0)
+	default:
+		b.t.Errorf("unexpected api key %d", key)
+	}
+}
+
+func start(t *testing.T, records []byte) (*broker, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	b := &broker{t: t, host: host, port: int32(portNumber), records: records, committed: -1}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b, listener.Addr().String()
+}
+
+func TestConsume(t *testing.T) {
+	var records []byte
+	records = append(records, batch(t, 0, 0, `{"id":0}`, `{"id":1}`)...)
+	records = append(records, batch(t, 2, 1, `{"id":2}`)...)
+	records = append(records, batch(t, 3, 2, `{"id":3}`)...)
+	records = append(records, batch(t, 4, 3, `{"id":4}`)...)
+	records = append(records, batch(t, 5, 4, `{"id":5}`)...)
+	// a batch cut at the size limit is left for the next fetch
+	records = append(records, batch(t, 6, 0, `{"id":6}`)[:30]...)
+	b, address := start(t, records)
+	ctx := context.Background()
+
+	client := kafka.NewClient([]string{"127.0.0.1:1", address}, nil)
+	defer client.Close()
+	metadata, err := client.Metadata(ctx, []string{topic})
+	assert.NoError(t, err)
+	assert.Equal(t, []kafka.Partition{{Topic: topic, ID: 0, Leader: 1}}, metadata.Partitions)
+
+	coordinator, err := client.Coordinator(ctx, "group")
+	assert.NoError(t, err)
+	committed, err := coordinator.OffsetFetch(ctx, "group", map[string][]int32{topic: {0}})
+	assert.NoError(t, err)
+	assert.Equal(t, kafka.Offsets{topic: {0: -1}}, committed)
+
+	conn, err := client.Broker(ctx, 1)
+	assert.NoError(t, err)
+	earliest, err := conn.ListOffsets(ctx, kafka.Offsets{topic: {0: kafka.Earliest}})
+	assert.NoError(t, err)
+	assert.Equal(t, kafka.Offsets{topic: {0: 0}}, earliest)
+
+	// the records before the offset asked for are dropped from the first batch
+	results, err := conn.Fetch(ctx, kafka.Offsets{topic: {0: 1}}, 0, 1<<20)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, int64(5), results[0].HighWatermark)
+		var values []string
+		for i, record := range results[0].Records {
+			assert.Equal(t, int64(i+1), record.Offset)
+			assert.Nil(t, record.Key)
+			assert.Equal(t, []kafka.Header{{Key: "content-type", Value: []byte("application/json")}}, record.Headers)
+			assert.Equal(t, int64(1700000000000), record.Time.UnixMilli())
+			values = append(values, string(record.Value))
+		}
+		assert.Equal(t, []string{`{"id":1}`, `{"id":2}`, `{"id":3}`, `{"id":4}`, `{"id":5}`}, values)
+	}
+	assert.Equal(t, []int64{1}, b.fetched)
+
+	assert.NoError(t, coordinator.OffsetCommit(ctx, "group", kafka.Offsets{topic: {0: 6}}))
+	b.mu.Lock()
+	assert.Equal(t, int64(6), b.committed)
+	b.mu.Unlock()
+}
+
+func TestCorruptBatch(t *testing.T) {
+	records := batch(t, 0, 0, `{"id":0}`)
+	records[len(records)-1] ^= 0xff
+	_, address := start(t, records)
+	ctx := context.Background()
+	conn, err := kafka.Dial(ctx, address, nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	results, err := conn.Fetch(ctx, kafka.Offsets{topic: {0: 0}}, 0, 1<<20)
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.ErrorContains(t, results[0].Err, "crc")
+		assert.Empty(t, results[0].Records)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	assert.EqualError(t, kafka.ErrOffsetOutOfRange, "kafka offset out of range")
+	assert.EqualError(t, kafka.Error(42), "kafka error 42")
+}
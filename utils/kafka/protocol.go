@@ -0,0 +1,149 @@
+package kafka
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// writer encodes request bodies
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) int8(v int8) *writer {
+	w.WriteByte(byte(v))
+	return w
+}
+
+func (w *writer) int16(v int16) *writer {
+	_ = binary.Write(w, binary.BigEndian, v)
+	return w
+}
+
+func (w *writer) int32(v int32) *writer {
+	_ = binary.Write(w, binary.BigEndian, v)
+	return w
+}
+
+func (w *writer) int64(v int64) *writer {
+	_ = binary.Write(w, binary.BigEndian, v)
+	return w
+}
+
+func (w *writer) string(v string) *writer {
+	w.int16(int16(len(v)))
+	w.WriteString(v)
+	return w
+}
+
+// nullString writes a null string, which the requests use for the unset metadata
+func (w *writer) nullString() *writer {
+	return w.int16(-1)
+}
+
+func (w *writer) arrayLen(n int) *writer {
+	return w.int32(int32(n))
+}
+
+// reader decodes response bodies, the first error sticks
+type reader struct {
+	data []byte
+	err  error
+}
+
+// take is used to read n bytes, once failed it returns zeros, no more than the 8 bytes the numbers need
+func (r *reader) take(n int) []byte {
+	switch {
+	case r.err != nil:
+	case n < 0:
+		r.err = fmt.Errorf("kafka response has a negative length")
+	case len(r.data) < n:
+		r.err = fmt.Errorf("kafka response is truncated")
+	}
+	if r.err != nil {
+		return make([]byte, min(max(n, 0), 8))
+	}
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *reader) int8() int8 {
+	return int8(r.take(1)[0])
+}
+
+func (r *reader) int16() int16 {
+	return int16(binary.BigEndian.Uint16(r.take(2)))
+}
+
+func (r *reader) int32() int32 {
+	return int32(binary.BigEndian.Uint32(r.take(4)))
+}
+
+func (r *reader) int64() int64 {
+	return int64(binary.BigEndian.Uint64(r.take(8)))
+}
+
+func (r *reader) bool() bool {
+	return r.int8() != 0
+}
+
+func (r *reader) string() string {
+	return string(r.take(int(r.int16())))
+}
+
+// nullString reads a string that can be null, which is read as empty
+func (r *reader) nullString() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+// bytes reads bytes that can be null, which are read as nil
+func (r *reader) bytes() []byte {
+	n := r.int32()
+	if n < 0 {
+		return nil
+	}
+	return r.take(int(n))
+}
+
+// arrayLen reads the length of an array, a null array is read as empty
+func (r *reader) arrayLen() int {
+	n := r.int32()
+	if n < 0 || r.err != nil {
+		return 0
+	}
+	// every element takes at least a byte, which bounds the allocations of a corrupt length
+	if int(n) > len(r.data) {
+		r.err = fmt.Errorf("kafka response array of %d elements is truncated", n)
+		return 0
+	}
+	return int(n)
+}
+
+// varint reads a zigzag encoded varint of the record format
+func (r *reader) varint() int64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(r.data)
+	if n <= 0 {
+		r.err = fmt.Errorf("kafka record has an invalid varint")
+		return 0
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+// varBytes reads bytes prefixed with their varint length, -1 being null
+func (r *reader) varBytes() []byte {
+	n := r.varint()
+	if n < 0 {
+		return nil
+	}
+	return r.take(int(n))
+}
@@ -0,0 +1,257 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// record batch layout, the v2 message format
+const (
+	batchHeaderSize   = 61
+	batchLengthOffset = 8
+	batchMagicOffset  = 16
+	batchCRCOffset    = 21
+	recordsMagic      = 2
+
+	attributeCompression   = 0x07
+	attributeLogAppendTime = 0x08
+	attributeControl       = 0x20
+)
+
+// compression codecs of the batch attributes
+const (
+	codecNone   = 0
+	codecGzip   = 1
+	codecSnappy = 2
+	codecLZ4    = 3
+	codecZstd   = 4
+)
+
+var (
+	crcTable     = crc32.MakeTable(crc32.Castagnoli)
+	xerialHeader = []byte{0x82, 'S', 'N', 'A', 'P', 'P', 'Y', 0}
+	lz4Magic     = []byte{0x04, 0x22, 0x4d, 0x18}
+	zstdDecoder  *zstd.Decoder
+)
+
+func init() {
+	// a nil reader builds a decoder for DecodeAll, which is safe for concurrent use
+	zstdDecoder, _ = zstd.NewReader(nil)
+}
+
+// Header is a header of a record
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Record is a record of a partition, Key and Value are nil when they are null
+type Record struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Time      time.Time
+	Key       []byte
+	Value     []byte
+	Headers   []Header
+}
+
+// decodeRecords is used to decode the record batches of a fetched partition. Control batches of transactions
+// are skipped, and so is the last batch when the broker cut it at the size limit.
+func decodeRecords(topic string, partition int32, data []byte) ([]Record, error) {
+	var records []Record
+	for len(data) >= batchMagicOffset+1 {
+		length := int(int32(binary.BigEndian.Uint32(data[batchLengthOffset:])))
+		size := batchLengthOffset + 4 + length
+		if length < batchHeaderSize-batchLengthOffset-4 || size > len(data) {
+			break
+		}
+		batch := data[:size]
+		data = data[size:]
+		if magic := batch[batchMagicOffset]; magic != recordsMagic {
+			return records, fmt.Errorf("kafka message format v%d is not supported", magic)
+		}
+		if crc32.Checksum(batch[batchCRCOffset:], crcTable) != binary.BigEndian.Uint32(batch[batchCRCOffset-4:]) {
+			return records, fmt.Errorf("kafka record batch of %s/%d fails its crc", topic, partition)
+		}
+		r := &reader{data: batch}
+		baseOffset := r.int64()
+		r.take(9) // length, leader epoch and magic
+		r.int32() // crc
+		attributes := r.int16()
+		r.int32() // last offset delta
+		firstTime, maxTime := r.int64(), r.int64()
+		r.take(14) // producer id, epoch and base sequence
+		count := r.int32()
+		if attributes&attributeControl != 0 {
+			continue
+		}
+		payload, err := decompress(int(attributes&attributeCompression), r.data)
+		if err != nil {
+			return records, fmt.Errorf("kafka record batch of %s/%d : %w", topic, partition, err)
+		}
+		r = &reader{data: payload}
+		for i := int32(0); i < count && r.err == nil; i++ {
+			record := &reader{data: r.take(int(r.varint()))}
+			record.int8() // attributes
+			timeDelta := record.varint()
+			offsetDelta := record.varint()
+			decoded := Record{Topic: topic, Partition: partition, Offset: baseOffset + offsetDelta,
+				Key: record.varBytes(), Value: record.varBytes()}
+			for j, headers := int64(0), record.varint(); j < headers && record.err == nil; j++ {
+				decoded.Headers = append(decoded.Headers, Header{Key: string(record.varBytes()),
+					Value: record.varBytes()})
+			}
+			at := firstTime + timeDelta
+			if attributes&attributeLogAppendTime != 0 {
+				at = maxTime
+			}
+			decoded.Time = time.UnixMilli(at).UTC()
+			if record.err != nil {
+				return records, fmt.Errorf("kafka record of %s/%d : %w", topic, partition, record.err)
+			}
+			records = append(records, decoded)
+		}
+		if r.err != nil {
+			return records, fmt.Errorf("kafka record batch of %s/%d : %w", topic, partition, r.err)
+		}
+	}
+	return records, nil
+}
+
+// decompress is used to get the records of a batch compressed with the codec
+func decompress(codec int, data []byte) ([]byte, error) {
+	switch codec {
+	case codecNone:
+		return data, nil
+	case codecGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(reader)
+	case codecSnappy:
+		return decodeSnappy(data)
+	case codecLZ4:
+		return decodeLZ4Frame(data)
+	case codecZstd:
+		return zstdDecoder.DecodeAll(data, nil)
+	}
+	return nil, fmt.Errorf("unknown compression codec %d", codec)
+}
+
+// decodeSnappy is used to decode a snappy block, or the blocks of the xerial framing the java clients use
+func decodeSnappy(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, xerialHeader) {
+		return s2.Decode(nil, data)
+	}
+	// the header is followed by its version and its compatible version
+	r := &reader{data: data[len(xerialHeader)+8:]}
+	var decoded []byte
+	for len(r.data) > 0 && r.err == nil {
+		block, err := s2.Decode(nil, r.take(int(r.int32())))
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, block...)
+	}
+	return decoded, r.err
+}
+
+// decodeLZ4Frame is used to decode an lz4 frame. The blocks are decoded into one buffer,
+// so that the blocks linked to the previous ones find their matches.
+func decodeLZ4Frame(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, lz4Magic) || len(data) < 7 {
+		return nil, errors.New("lz4 frame magic is missing")
+	}
+	flags := data[4]
+	headerSize := 7 // magic, flags, block descriptor and header checksum
+	if flags&0x08 != 0 {
+		headerSize += 8 // content size
+	}
+	if flags&0x01 != 0 {
+		headerSize += 4 // dictionary id
+	}
+	blockChecksums := flags&0x10 != 0
+	r := &reader{data: data[min(headerSize, len(data)):]}
+	var decoded []byte
+	for r.err == nil {
+		size := binary.LittleEndian.Uint32(r.take(4))
+		if size == 0 || r.err != nil {
+			break
+		}
+		block := r.take(int(size &^ (1 << 31)))
+		if blockChecksums {
+			r.take(4)
+		}
+		if r.err != nil {
+			break
+		}
+		if size&(1<<31) != 0 {
+			decoded = append(decoded, block...)
+			continue
+		}
+		var err error
+		if decoded, err = decodeLZ4Block(decoded, block); err != nil {
+			return nil, err
+		}
+	}
+	return decoded, r.err
+}
+
+// decodeLZ4Block is used to append an lz4 block to dst, whose content the matches can reference
+func decodeLZ4Block(dst, src []byte) ([]byte, error) {
+	errCorrupt := errors.New("lz4 block is corrupt")
+	length := func(i int, n int) (int, int, bool) {
+		if n != 15 {
+			return i, n, true
+		}
+		for i < len(src) {
+			n += int(src[i])
+			i++
+			if src[i-1] != 255 {
+				return i, n, true
+			}
+		}
+		return i, n, false
+	}
+	for i := 0; i < len(src); {
+		token := int(src[i])
+		i++
+		var literals int
+		var ok bool
+		if i, literals, ok = length(i, token>>4); !ok || i+literals > len(src) {
+			return nil, errCorrupt
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+		if i == len(src) {
+			// the last sequence only has literals
+			return dst, nil
+		}
+		if i+2 > len(src) {
+			return nil, errCorrupt
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		var match int
+		if i, match, ok = length(i, token&0x0f); !ok || offset == 0 || offset > len(dst) {
+			return nil, errCorrupt
+		}
+		// matches can overlap what they copy, so they are copied a byte at a time
+		from := len(dst) - offset
+		for j := 0; j < match+4; j++ {
+			dst = append(dst, dst[from+j])
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,233 @@
+// Package logging routes the logs of the service itself. The records of the zerolog logger every package logs
+// through are handed to a pluggable backend and, optionally, ingested like any other log entry.
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	utilsConstants "github.com/angel-one/go-utils/constants"
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/rs/zerolog"
+	zerologger "github.com/rs/zerolog/log"
+)
+
+// Config is the configuration of the logs of the service
+type Config struct {
+	// Backend is the name of the backend the records are written with, zerolog by default
+	Backend string `json:"backend" mapstructure:"backend"`
+	// SelfIngest sends the records through the pipeline and the sinks as entries
+	SelfIngest SelfIngestConfig `json:"selfIngest" mapstructure:"selfIngest"`
+}
+
+// SelfIngestConfig is the configuration of the ingestion of the logs of the service
+type SelfIngestConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+	// Type is the type of the entries, logger.internal by default
+	Type string `json:"type" mapstructure:"type"`
+	// MinLevel is the lowest level ingested, warn by default
+	MinLevel string `json:"minLevel" mapstructure:"minLevel"`
+	// BufferSize is the number of records waiting to be ingested before they are dropped
+	BufferSize int `json:"bufferSize" mapstructure:"bufferSize"`
+}
+
+// Record is a log record of the service
+type Record struct {
+	Level   string
+	Message string
+	// Fields are the fields of the record, including the level and the message
+	Fields map[string]interface{}
+	// Raw is the record as encoded by zerolog
+	Raw []byte
+}
+
+// Backend writes the records, the adapters of other logging libraries such as zap or slog implement it
+type Backend interface {
+	Write(record Record) error
+}
+
+// BackendFunc is used to use a function as a backend
+type BackendFunc func(record Record) error
+
+// Write is used to write the record with the function
+func (f BackendFunc) Write(record Record) error {
+	return f(record)
+}
+
+// Ingest is used to ingest an entry made of a record
+type Ingest func(ctx context.Context, entry *models.LogEntry) error
+
+var (
+	mu       sync.RWMutex
+	backends = map[string]func() Backend{
+		constants.ZerologBackend: func() Backend { return writerBackend{out: os.Stderr} },
+		constants.ConsoleBackend: func() Backend {
+			return writerBackend{out: zerolog.ConsoleWriter{Out: os.Stderr}}
+		},
+		constants.ZapBackend:  func() Backend { return NewZapBackend(os.Stderr) },
+		constants.SlogBackend: func() Backend { return NewSlogBackend(os.Stderr) },
+	}
+)
+
+// Register is used to make a backend available by name
+func Register(name string, factory func() Backend) {
+	mu.Lock()
+	defer mu.Unlock()
+	backends[name] = factory
+}
+
+// Backends is used to get the names of the available backends
+func Backends() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Init is used to write the records with the configured backend and ingest them with the function when enabled,
+// records are ingested until the context is done
+func Init(ctx context.Context, config Config, ingest Ingest) error {
+	if config.Backend == "" {
+		config.Backend = constants.ZerologBackend
+	}
+	mu.RLock()
+	factory, ok := backends[config.Backend]
+	mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown logging backend %s, available are %v", config.Backend, Backends())
+	}
+	w := &writer{backend: factory()}
+	if config.SelfIngest.Enabled {
+		if err := w.selfIngest(ctx, config.SelfIngest, ingest); err != nil {
+			return err
+		}
+	}
+	zerologger.Logger = zerologger.Logger.Output(w)
+	return nil
+}
+
+// writerBackend writes the records as encoded by zerolog
+type writerBackend struct {
+	out io.Writer
+}
+
+func (b writerBackend) Write(record Record) error {
+	_, err := b.out.Write(record.Raw)
+	return err
+}
+
+type writer struct {
+	backend  Backend
+	ingest   Ingest
+	records  chan Record
+	minLevel zerolog.Level
+	kind     string
+}
+
+// Write is used to hand a record encoded by zerolog to the backend and queue it for ingestion
+func (w *writer) Write(p []byte) (int, error) {
+	record := Record{Raw: p}
+	if err := json.Unmarshal(p, &record.Fields); err != nil {
+		// not a record of ours, it is still written as is
+		record.Fields = nil
+	}
+	record.Level, _ = record.Fields[zerolog.LevelFieldName].(string)
+	record.Message, _ = record.Fields[zerolog.MessageFieldName].(string)
+	if err := w.backend.Write(record); err != nil {
+		return 0, err
+	}
+	if w.records != nil && w.ingestible(record) {
+		select {
+		case w.records <- Record{Level: record.Level, Message: record.Message, Fields: record.Fields}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// ingestible is used to check whether the record is ingested. The records of ingested entries are not logs of
+// the service, and records logged while ingesting a record or about the sinks would feed the entries back
+// into themselves.
+func (w *writer) ingestible(record Record) bool {
+	if record.Fields == nil {
+		return false
+	}
+	if level, err := zerolog.ParseLevel(record.Level); err != nil || level < w.minLevel {
+		return false
+	}
+	if record.Fields[utilsConstants.IDLogParam] == constants.SelfIngestID || record.Fields[constants.IngestedKey] == true {
+		return false
+	}
+	_, sink := record.Fields[constants.SinkKey]
+	return !sink
+}
+
+func (w *writer) selfIngest(ctx context.Context, config SelfIngestConfig, ingest Ingest) error {
+	if ingest == nil {
+		return fmt.Errorf("self ingestion needs the ingestion")
+	}
+	if config.Type == "" {
+		config.Type = constants.DefaultSelfIngestType
+	}
+	if config.MinLevel == "" {
+		config.MinLevel = constants.WarnLevel
+	}
+	minLevel, err := zerolog.ParseLevel(config.MinLevel)
+	if err != nil {
+		return fmt.Errorf("invalid self ingestion min level %s : %w", config.MinLevel, err)
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = constants.DefaultSelfIngestBufferSize
+	}
+	w.ingest, w.minLevel, w.kind = ingest, minLevel, config.Type
+	w.records = make(chan Record, config.BufferSize)
+	// whatever is logged while ingesting carries the id, so it is never ingested again
+	ctx = context.WithValue(ctx, utilsConstants.IDLogParam, constants.SelfIngestID)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case record := <-w.records:
+				entry := Entry(w.kind, record)
+				if err := w.ingest(ctx, &entry); err != nil {
+					log.Debug(ctx).Err(err).Msg("error ingesting own log record")
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Entry is used to get the entry of the record
+func Entry(kind string, record Record) models.LogEntry {
+	data := make(map[string]interface{}, len(record.Fields))
+	for key, value := range record.Fields {
+		if key != zerolog.LevelFieldName {
+			data[key] = value
+		}
+	}
+	return models.LogEntry{Type: kind, Level: entryLevel(record.Level), Data: data}
+}
+
+// entryLevel is used to map the zerolog level onto the levels of the entries
+func entryLevel(level string) string {
+	switch level {
+	case constants.TraceLevel:
+		return constants.DebugLevel
+	case constants.PanicLevel:
+		return constants.FatalLevel
+	}
+	return level
+}
@@ -0,0 +1,85 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfIngest(t *testing.T) {
+	records := make(chan logging.Record, 10)
+	logging.Register("capture", func() logging.Backend {
+		return logging.BackendFunc(func(record logging.Record) error {
+			records <- record
+			return nil
+		})
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.Error(t, logging.Init(ctx, logging.Config{Backend: "logrus"}, nil))
+
+	entries := make(chan models.LogEntry, 10)
+	assert.NoError(t, logging.Init(ctx, logging.Config{Backend: "capture",
+		SelfIngest: logging.SelfIngestConfig{Enabled: true}}, func(ctx context.Context, entry *models.LogEntry) error {
+		// logged while ingesting, it must not be ingested again
+		log.Error(ctx).Msg("ingesting")
+		entries <- *entry
+		return nil
+	}))
+
+	log.Info(ctx).Msg("below the min level")
+	log.Warn(ctx).Str(constants.SinkKey, "search").Msg("about a sink")
+	log.Warn(ctx).Bool(constants.IngestedKey, true).Msg("an ingested entry")
+	log.Warn(ctx).Str(constants.FieldKey, "disk").Msg("almost full")
+
+	select {
+	case entry := <-entries:
+		assert.Equal(t, constants.DefaultSelfIngestType, entry.Type)
+		assert.Equal(t, constants.WarnLevel, entry.Level)
+		assert.Equal(t, "almost full", entry.Data["message"])
+		assert.Equal(t, "disk", entry.Data[constants.FieldKey])
+	case <-time.After(time.Second):
+		t.Fatal("record was not ingested")
+	}
+	select {
+	case entry := <-entries:
+		t.Fatalf("unexpected entry %v", entry.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Len(t, records, 5)
+}
+
+func TestBackends(t *testing.T) {
+	assert.Subset(t, logging.Backends(), []string{constants.ConsoleBackend, constants.SlogBackend,
+		constants.ZapBackend, constants.ZerologBackend})
+
+	record := logging.Record{Level: constants.WarnLevel, Message: "almost full", Fields: map[string]interface{}{
+		"level": constants.WarnLevel, "message": "almost full", "time": "2024-01-01T00:00:00Z", "field": "disk"}}
+	for name, backend := range map[string]func(out *bytes.Buffer) logging.Backend{
+		constants.ZapBackend:  func(out *bytes.Buffer) logging.Backend { return logging.NewZapBackend(out) },
+		constants.SlogBackend: func(out *bytes.Buffer) logging.Backend { return logging.NewSlogBackend(out) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			assert.NoError(t, backend(&out).Write(record))
+			var written map[string]interface{}
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &written))
+			assert.Equal(t, "disk", written["field"])
+			assert.Equal(t, "almost full", written["msg"])
+			assert.Contains(t, []interface{}{"warn", "WARN"}, written["level"])
+
+			out.Reset()
+			assert.NoError(t, backend(&out).Write(logging.Record{Raw: []byte("not json\n")}))
+			assert.NoError(t, json.Unmarshal(out.Bytes(), &written))
+			assert.Equal(t, "not json", written["msg"])
+		})
+	}
+}
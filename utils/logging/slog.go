@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+)
+
+// slogLevels maps the zerolog levels onto the levels of slog, the ones slog does not have are spaced like its own
+var slogLevels = map[string]slog.Level{
+	constants.TraceLevel: slog.LevelDebug - 4,
+	constants.DebugLevel: slog.LevelDebug,
+	constants.InfoLevel:  slog.LevelInfo,
+	constants.WarnLevel:  slog.LevelWarn,
+	constants.ErrorLevel: slog.LevelError,
+	constants.FatalLevel: slog.LevelError + 4,
+	constants.PanicLevel: slog.LevelError + 8,
+}
+
+// slogBackend writes the records through a slog handler
+type slogBackend struct {
+	handler slog.Handler
+}
+
+// NewSlogBackend is used to create a backend writing the records with the json handler of slog
+func NewSlogBackend(out io.Writer) Backend {
+	return slogBackend{handler: slog.NewJSONHandler(out, &slog.HandlerOptions{Level: slogLevels[constants.TraceLevel]})}
+}
+
+// Write is used to write the record with its fields as attributes
+func (b slogBackend) Write(record Record) error {
+	level, ok := slogLevels[record.Level]
+	if !ok {
+		level = slog.LevelInfo
+	}
+	ctx := context.Background()
+	if !b.handler.Enabled(ctx, level) {
+		return nil
+	}
+	message := record.Message
+	if record.Fields == nil {
+		message = strings.TrimSpace(string(record.Raw))
+	}
+	r := slog.NewRecord(time.Now(), level, message, 0)
+	for _, key := range recordKeys(record) {
+		r.AddAttrs(slog.Any(key, record.Fields[key]))
+	}
+	return b.handler.Handle(ctx, r)
+}
@@ -0,0 +1,58 @@
+package logging
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// zapBackend writes the records through a zap core
+type zapBackend struct {
+	core zapcore.Core
+}
+
+// NewZapBackend is used to create a backend writing the records with the production json encoder of zap
+func NewZapBackend(out io.Writer) Backend {
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	return zapBackend{core: zapcore.NewCore(encoder, zapcore.Lock(zapcore.AddSync(out)), zapcore.DebugLevel)}
+}
+
+// Write is used to write the record with the core directly, so that fatal and panic records do not exit again
+func (b zapBackend) Write(record Record) error {
+	level, err := zapcore.ParseLevel(record.Level)
+	if err != nil {
+		level = zapcore.DebugLevel
+	}
+	if !b.core.Enabled(level) {
+		return nil
+	}
+	entry := zapcore.Entry{Level: level, Time: time.Now(), Message: record.Message}
+	if record.Fields == nil {
+		entry.Message = strings.TrimSpace(string(record.Raw))
+	}
+	fields := make([]zapcore.Field, 0, len(record.Fields))
+	for _, key := range recordKeys(record) {
+		fields = append(fields, zap.Any(key, record.Fields[key]))
+	}
+	return b.core.Write(entry, fields)
+}
+
+// recordKeys is used to get the sorted keys of the fields of the record other than the ones every backend writes
+// on its own
+func recordKeys(record Record) []string {
+	keys := make([]string, 0, len(record.Fields))
+	for key := range record.Fields {
+		switch key {
+		case zerolog.LevelFieldName, zerolog.MessageFieldName, zerolog.TimestampFieldName:
+		default:
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,125 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// jsError is the error of a jetstream api response
+type jsError struct {
+	Error *struct {
+		Code        int    `json:"code"`
+		Description string `json:"description"`
+	} `json:"error"`
+}
+
+func (e jsError) err() error {
+	if e.Error == nil {
+		return nil
+	}
+	return fmt.Errorf("jetstream %d %s", e.Error.Code, e.Error.Description)
+}
+
+// PublishAck is the acknowledgement of a message stored by jetstream
+type PublishAck struct {
+	Stream   string `json:"stream"`
+	Sequence uint64 `json:"seq"`
+}
+
+// JSPublish is used to publish the data and wait for a stream to store it
+func (c *Conn) JSPublish(ctx context.Context, subject string, data []byte) (PublishAck, error) {
+	msg, err := c.Request(ctx, subject, data)
+	if err == ErrNoResponders {
+		return PublishAck{}, fmt.Errorf("jetstream no stream is bound to %s", subject)
+	}
+	if err != nil {
+		return PublishAck{}, err
+	}
+	var response struct {
+		jsError
+		PublishAck
+	}
+	if err = json.Unmarshal(msg.Data, &response); err != nil {
+		return PublishAck{}, fmt.Errorf("jetstream invalid publish ack : %w", err)
+	}
+	if err = response.err(); err != nil {
+		return PublishAck{}, err
+	}
+	return response.PublishAck, nil
+}
+
+// EnsureConsumer is used to create the durable pull consumer of the stream, or update it when it exists
+func (c *Conn) EnsureConsumer(ctx context.Context, stream, durable, filterSubject string) error {
+	request, _ := json.Marshal(map[string]interface{}{
+		"stream_name": stream,
+		"config": map[string]interface{}{
+			"durable_name":   durable,
+			"ack_policy":     "explicit",
+			"deliver_policy": "all",
+			"filter_subject": filterSubject,
+		},
+	})
+	msg, err := c.Request(ctx, fmt.Sprintf("$JS.API.CONSUMER.DURABLE.CREATE.%s.%s", stream, durable), request)
+	if err == ErrNoResponders {
+		return fmt.Errorf("jetstream is not enabled")
+	}
+	if err != nil {
+		return err
+	}
+	var response jsError
+	if err = json.Unmarshal(msg.Data, &response); err != nil {
+		return fmt.Errorf("jetstream invalid consumer response : %w", err)
+	}
+	return response.err()
+}
+
+// Fetch is used to pull up to batch messages of the durable consumer, waiting at most expires for them
+func (c *Conn) Fetch(ctx context.Context, stream, durable string, batch int, expires time.Duration) ([]Msg, error) {
+	inbox := NewInbox()
+	sid, messages, err := c.Subscribe(inbox, batch+1)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = c.Unsubscribe(sid) }()
+	request, _ := json.Marshal(map[string]interface{}{"batch": batch, "expires": expires.Nanoseconds()})
+	if err = c.Publish(fmt.Sprintf("$JS.API.CONSUMER.MSG.NEXT.%s.%s", stream, durable), inbox, request); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(expires + time.Second)
+	defer timer.Stop()
+	var fetched []Msg
+	for len(fetched) < batch {
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return fetched, c.Err()
+			}
+			switch msg.Status {
+			case "":
+				fetched = append(fetched, msg)
+			case "404", "408", "409":
+				// no messages, the request expired or the consumer is at its limits
+				return fetched, nil
+			default:
+				return fetched, fmt.Errorf("jetstream fetch status %s", msg.Status)
+			}
+		case <-timer.C:
+			return fetched, nil
+		case <-ctx.Done():
+			return fetched, ctx.Err()
+		}
+	}
+	return fetched, nil
+}
+
+// Ack is used to acknowledge a jetstream message
+func (c *Conn) Ack(msg Msg) error {
+	return c.Publish(msg.Reply, "", []byte("+ACK"))
+}
+
+// Term is used to stop the redelivery of a jetstream message that can never be processed
+func (c *Conn) Term(msg Msg) error {
+	return c.Publish(msg.Reply, "", []byte("+TERM"))
+}
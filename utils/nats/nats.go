@@ -0,0 +1,387 @@
+// Package nats is a minimal NATS client covering publish, subscribe and request,
+// with the JetStream publish and pull consumer calls the inputs and sinks need.
+package nats
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	dialTimeout   = 10 * time.Second
+	pingInterval  = 30 * time.Second
+	maxControlLen = 4096
+	inboxPrefix   = "_INBOX."
+)
+
+// ErrClosed is returned when the connection is closed
+var ErrClosed = errors.New("nats connection closed")
+
+// ErrNoResponders is returned when nobody is subscribed to the subject of a request
+var ErrNoResponders = errors.New("nats no responders")
+
+// Msg is a received message, Status is set for the status messages of the server, e.g. 404 or 408
+type Msg struct {
+	Subject string
+	Reply   string
+	Status  string
+	Data    []byte
+}
+
+// Conn is a connection to a nats server
+type Conn struct {
+	conn      net.Conn
+	reader    *bufio.Reader
+	writeMu   sync.Mutex
+	subsMu    sync.Mutex
+	subs      map[string]chan Msg
+	nextSID   int
+	inbox     string
+	inboxSID  string
+	requests  map[string]chan Msg
+	done      chan struct{}
+	closeOnce sync.Once
+	err       error
+}
+
+type serverInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+// Dial is used to connect to a nats:// or tls:// url, credentials are taken from the url user info,
+// where a user without a password is a token
+func Dial(ctx context.Context, rawURL string) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "nats" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("nats url scheme has to be nats or tls")
+	}
+	address := u.Host
+	if u.Port() == "" {
+		address = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	conn, err := (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(dialTimeout))
+	reader := bufio.NewReader(conn)
+	line, err := readLine(reader)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	var info serverInfo
+	if op, arg, _ := strings.Cut(line, " "); op != "INFO" || json.Unmarshal([]byte(arg), &info) != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("nats server did not send its info")
+	}
+	if u.Scheme == "tls" || info.TLSRequired {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12})
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		conn, reader = tlsConn, bufio.NewReader(tlsConn)
+	}
+	options := map[string]interface{}{
+		"verbose": false, "pedantic": false, "headers": true, "no_responders": true,
+		"lang": "go", "version": "1.0.0", "protocol": 1, "name": "nbu-logger-service",
+	}
+	if u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			options["user"], options["pass"] = u.User.Username(), password
+		} else {
+			options["auth_token"] = u.User.Username()
+		}
+	}
+	connect, _ := json.Marshal(options)
+	if _, err = fmt.Fprintf(conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	for {
+		if line, err = readLine(reader); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		if strings.HasPrefix(line, "-ERR") {
+			_ = conn.Close()
+			return nil, fmt.Errorf("nats %s", line)
+		}
+		if line == "PONG" {
+			break
+		}
+	}
+	_ = conn.SetDeadline(time.Time{})
+	c := &Conn{
+		conn:     conn,
+		reader:   reader,
+		subs:     make(map[string]chan Msg),
+		requests: make(map[string]chan Msg),
+		inbox:    NewInbox(),
+		done:     make(chan struct{}),
+	}
+	go c.readLoop()
+	go c.pingLoop()
+	return c, nil
+}
+
+// NewInbox is used to get a unique reply subject
+func NewInbox() string {
+	id := make([]byte, 12)
+	_, _ = rand.Read(id)
+	return inboxPrefix + hex.EncodeToString(id)
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) || len(line) > maxControlLen {
+		return "", fmt.Errorf("nats control line is too long")
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (c *Conn) write(format string, args ...interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := fmt.Fprintf(c.conn, format, args...)
+	return err
+}
+
+// Publish is used to publish the data with an optional reply subject
+func (c *Conn) Publish(subject, reply string, data []byte) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if reply != "" {
+		reply += " "
+	}
+	if _, err := fmt.Fprintf(c.conn, "PUB %s %s%d\r\n", subject, reply, len(data)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(append(append([]byte(nil), data...), '\r', '\n'))
+	return err
+}
+
+// Subscribe is used to receive the messages of the subject, the channel is closed on unsubscribe or close
+func (c *Conn) Subscribe(subject string, buffer int) (string, <-chan Msg, error) {
+	c.subsMu.Lock()
+	c.nextSID++
+	sid := strconv.Itoa(c.nextSID)
+	messages := make(chan Msg, buffer)
+	c.subs[sid] = messages
+	c.subsMu.Unlock()
+	if err := c.write("SUB %s %s\r\n", subject, sid); err != nil {
+		return "", nil, err
+	}
+	return sid, messages, nil
+}
+
+// Unsubscribe is used to stop a subscription
+func (c *Conn) Unsubscribe(sid string) error {
+	c.subsMu.Lock()
+	if messages, ok := c.subs[sid]; ok {
+		delete(c.subs, sid)
+		close(messages)
+	}
+	c.subsMu.Unlock()
+	return c.write("UNSUB %s\r\n", sid)
+}
+
+// Request is used to publish the data and wait for the first reply
+func (c *Conn) Request(ctx context.Context, subject string, data []byte) (Msg, error) {
+	c.subsMu.Lock()
+	if c.inboxSID == "" {
+		c.nextSID++
+		c.inboxSID = strconv.Itoa(c.nextSID)
+		if err := c.write("SUB %s.* %s\r\n", c.inbox, c.inboxSID); err != nil {
+			c.subsMu.Unlock()
+			return Msg{}, err
+		}
+	}
+	token := NewInbox()[len(inboxPrefix):]
+	reply := make(chan Msg, 1)
+	c.requests[token] = reply
+	c.subsMu.Unlock()
+	defer func() {
+		c.subsMu.Lock()
+		delete(c.requests, token)
+		c.subsMu.Unlock()
+	}()
+	if err := c.Publish(subject, c.inbox+"."+token, data); err != nil {
+		return Msg{}, err
+	}
+	select {
+	case msg := <-reply:
+		if msg.Status == "503" {
+			return msg, ErrNoResponders
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return Msg{}, ctx.Err()
+	case <-c.done:
+		return Msg{}, c.Err()
+	}
+}
+
+// readLoop is used to dispatch the server operations until the connection fails or is closed
+func (c *Conn) readLoop() {
+	for {
+		line, err := readLine(c.reader)
+		if err != nil {
+			c.shutdown(err)
+			return
+		}
+		op, args, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(op) {
+		case "MSG", "HMSG":
+			msg, sid, err := c.readMsg(strings.ToUpper(op) == "HMSG", strings.Fields(args))
+			if err != nil {
+				c.shutdown(err)
+				return
+			}
+			c.dispatch(sid, msg)
+		case "PING":
+			if err = c.write("PONG\r\n"); err != nil {
+				c.shutdown(err)
+				return
+			}
+		case "-ERR":
+			c.shutdown(fmt.Errorf("nats %s", line))
+			return
+		}
+	}
+}
+
+// readMsg is used to read the payload of a MSG or HMSG, keeping only the status of the headers
+func (c *Conn) readMsg(headers bool, fields []string) (Msg, string, error) {
+	if len(fields) < 3 || (headers && len(fields) < 4) {
+		return Msg{}, "", fmt.Errorf("nats invalid message")
+	}
+	msg := Msg{Subject: fields[0]}
+	sid := fields[1]
+	sizes := fields[2:]
+	if (headers && len(sizes) == 3) || (!headers && len(sizes) == 2) {
+		msg.Reply, sizes = sizes[0], sizes[1:]
+	}
+	total, err := strconv.Atoi(sizes[len(sizes)-1])
+	if err != nil || total < 0 {
+		return Msg{}, "", fmt.Errorf("nats invalid message size")
+	}
+	headerSize := 0
+	if headers {
+		if headerSize, err = strconv.Atoi(sizes[0]); err != nil || headerSize > total {
+			return Msg{}, "", fmt.Errorf("nats invalid header size")
+		}
+	}
+	payload := make([]byte, total+2)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return Msg{}, "", err
+	}
+	if headerSize > 0 {
+		// the first header line is NATS/1.0 followed by the optional status
+		statusLine, _, _ := strings.Cut(string(payload[:headerSize]), "\r\n")
+		if parts := strings.Fields(statusLine); len(parts) > 1 {
+			msg.Status = parts[1]
+		}
+	}
+	msg.Data = payload[headerSize:total]
+	return msg, sid, nil
+}
+
+func (c *Conn) dispatch(sid string, msg Msg) {
+	c.subsMu.Lock()
+	defer c.subsMu.Unlock()
+	if sid == c.inboxSID {
+		if reply, ok := c.requests[strings.TrimPrefix(msg.Subject, c.inbox+".")]; ok {
+			select {
+			case reply <- msg:
+			default:
+			}
+		}
+		return
+	}
+	if messages, ok := c.subs[sid]; ok {
+		select {
+		case messages <- msg:
+		default:
+			// slow subscribers lose messages like with the server, jetstream redelivers unacked ones
+		}
+	}
+}
+
+func (c *Conn) pingLoop() {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.write("PING\r\n"); err != nil {
+				c.shutdown(err)
+				return
+			}
+		}
+	}
+}
+
+func (c *Conn) shutdown(err error) {
+	c.closeOnce.Do(func() {
+		c.err = err
+		close(c.done)
+		_ = c.conn.Close()
+		c.subsMu.Lock()
+		for sid, messages := range c.subs {
+			delete(c.subs, sid)
+			close(messages)
+		}
+		c.subsMu.Unlock()
+	})
+}
+
+// Done is closed when the connection is closed
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err is used to get the reason the connection closed
+func (c *Conn) Err() error {
+	select {
+	case <-c.done:
+		if c.err == nil {
+			return ErrClosed
+		}
+		return c.err
+	default:
+		return nil
+	}
+}
+
+// Close is used to close the connection
+func (c *Conn) Close() error {
+	c.shutdown(ErrClosed)
+	return nil
+}
@@ -0,0 +1,122 @@
+package nats_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/utils/nats"
+	"github.com/stretchr/testify/assert"
+)
+
+// server is a fake jetstream server for one connection
+type server struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	subs   map[string]string
+}
+
+// sid is used to get the subscription of the subject, supporting the request inbox wildcard
+func (s *server) sid(subject string) string {
+	for pattern, sid := range s.subs {
+		if pattern == subject || (strings.HasSuffix(pattern, ".*") && strings.HasPrefix(subject,
+			strings.TrimSuffix(pattern, "*"))) {
+			return sid
+		}
+	}
+	return ""
+}
+
+// next is used to read operations until a publish, keeping track of the subscriptions
+func (s *server) next() (subject, reply string, data []byte) {
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return "", "", nil
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "SUB":
+			s.subs[fields[1]] = fields[2]
+		case "PUB":
+			size, _ := strconv.Atoi(fields[len(fields)-1])
+			data = make([]byte, size+2)
+			_, _ = io.ReadFull(s.reader, data)
+			if len(fields) == 4 {
+				reply = fields[2]
+			}
+			return fields[1], reply, data[:size]
+		}
+	}
+}
+
+func (s *server) send(subject, reply, data string) {
+	if reply != "" {
+		reply += " "
+	}
+	_, _ = fmt.Fprintf(s.conn, "MSG %s %s %s%d\r\n%s\r\n", subject, s.sid(subject), reply, len(data), data)
+}
+
+func TestJetStream(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	acks := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		s := &server{conn: conn, reader: bufio.NewReader(conn), subs: make(map[string]string)}
+		_, _ = fmt.Fprint(conn, "INFO {\"server_id\":\"test\",\"headers\":true}\r\n")
+		connect, _ := s.reader.ReadString('\n')
+		assert.Contains(t, connect, `"auth_token":"secret"`)
+		_, _ = s.reader.ReadString('\n')
+		_, _ = fmt.Fprint(conn, "PONG\r\n")
+
+		subject, reply, data := s.next()
+		assert.Equal(t, "logs.app.error", subject)
+		assert.Equal(t, `{"type":"app"}`, string(data))
+		s.send(reply, "", `{"stream":"LOGS","seq":1}`)
+
+		subject, reply, _ = s.next()
+		assert.Equal(t, "$JS.API.CONSUMER.DURABLE.CREATE.LOGS.svc", subject)
+		s.send(reply, "", `{"name":"svc"}`)
+
+		subject, reply, _ = s.next()
+		assert.Equal(t, "$JS.API.CONSUMER.MSG.NEXT.LOGS.svc", subject)
+		s.send(reply, "$JS.ACK.LOGS.svc.1.1.1.0.0", "hello")
+		status := "NATS/1.0 404 No Messages\r\n\r\n"
+		_, _ = fmt.Fprintf(conn, "HMSG %s %s %d %d\r\n%s\r\n", reply, s.sid(reply), len(status), len(status), status)
+
+		subject, _, data = s.next()
+		acks <- subject + " " + string(data)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := nats.Dial(ctx, "nats://secret@"+listener.Addr().String())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	ack, err := conn.JSPublish(ctx, "logs.app.error", []byte(`{"type":"app"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, nats.PublishAck{Stream: "LOGS", Sequence: 1}, ack)
+
+	assert.NoError(t, conn.EnsureConsumer(ctx, "LOGS", "svc", "logs.>"))
+	messages, err := conn.Fetch(ctx, "LOGS", "svc", 10, time.Second)
+	assert.NoError(t, err)
+	if assert.Len(t, messages, 1) {
+		assert.Equal(t, "hello", string(messages[0].Data))
+		assert.NoError(t, conn.Ack(messages[0]))
+		assert.Equal(t, "$JS.ACK.LOGS.svc.1.1.1.0.0 +ACK", <-acks)
+	}
+}
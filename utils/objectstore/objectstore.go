@@ -0,0 +1,144 @@
+// Package objectstore reads and writes the objects of a bucket of an s3 compatible api, with requests signed by
+// sigv4 so there is no dependency on the aws sdk for it.
+package objectstore
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/utils/httpclient"
+	"github.com/angel-one/nbu-logger-service/utils/sigv4"
+)
+
+// ErrNotFound is returned when the object does not exist
+var ErrNotFound = errors.New(constants.ObjectNotFoundError)
+
+// S3 is the object store of a bucket of an s3 compatible api, addressed path style as {endpoint}/{bucket}/{key}
+type S3 struct {
+	endpoint    string
+	bucket      string
+	region      string
+	credentials sigv4.Credentials
+	timeout     time.Duration
+}
+
+// Object is an object of the bucket, as it is listed
+type Object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// listing is a page of the objects of the bucket, as the ListObjectsV2 api answers it
+type listing struct {
+	Contents              []Object `xml:"Contents"`
+	IsTruncated           bool     `xml:"IsTruncated"`
+	NextContinuationToken string   `xml:"NextContinuationToken"`
+}
+
+// NewS3 is used to create the object store of the bucket
+func NewS3(endpoint, bucket, region string, credentials sigv4.Credentials, timeout time.Duration) *S3 {
+	return &S3{endpoint: strings.TrimSuffix(endpoint, "/"), bucket: bucket, region: region,
+		credentials: credentials, timeout: timeout}
+}
+
+// Put is used to write the json object of the key
+func (s *S3) Put(key string, data []byte) error {
+	response, err := s.do(http.MethodPut, s.url(key), data)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return s.error(response)
+	}
+	return nil
+}
+
+// Get is used to read the object of the key, ErrNotFound when there is none
+func (s *S3) Get(key string) ([]byte, error) {
+	response, err := s.do(http.MethodGet, s.url(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	switch response.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(response.Body)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, s.error(response)
+	}
+}
+
+// List is used to get the objects whose keys start with the prefix, in the order of their keys
+func (s *S3) List(prefix string) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		rawURL := fmt.Sprintf("%s/%s?%s", s.endpoint, s.bucket, strings.ReplaceAll(query.Encode(), "+", "%20"))
+		response, err := s.do(http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		if response.StatusCode != http.StatusOK {
+			err = s.error(response)
+			response.Body.Close()
+			return nil, err
+		}
+		var page listing
+		err = xml.NewDecoder(response.Body).Decode(&page)
+		response.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid listing of bucket %s : %w", s.bucket, err)
+		}
+		objects = append(objects, page.Contents...)
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// url is used to get the url of the object of the key
+func (s *S3) url(key string) string {
+	return fmt.Sprintf("%s/%s/%s", s.endpoint, s.bucket, key)
+}
+
+// do is used to send the signed request of the url
+func (s *S3) do(method, rawURL string, body []byte) (*http.Response, error) {
+	digest := sha256.Sum256(body)
+	headers := map[string]string{"X-Amz-Content-Sha256": hex.EncodeToString(digest[:])}
+	if method == http.MethodPut {
+		headers["Content-Type"] = "application/json"
+	}
+	if err := sigv4.Sign(method, rawURL, headers, body, s.credentials, s.region, constants.ObjectStoreService,
+		time.Now()); err != nil {
+		return nil, err
+	}
+	if method == http.MethodPut {
+		return httpclient.PUTWithTimeout(rawURL, headers, bytes.NewReader(body), s.timeout)
+	}
+	return httpclient.GETWithTimeout(rawURL, headers, s.timeout)
+}
+
+// error is used to get the error of the unexpected response
+func (s *S3) error(response *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(response.Body, constants.MaxSinkResponseBytes))
+	return fmt.Errorf("object store responded %d : %s", response.StatusCode, strings.TrimSpace(string(data)))
+}
@@ -0,0 +1,257 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/utils/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// maxPageBytes is the largest page decompressed
+const maxPageBytes = maxValues * 16
+
+var zstdReader, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxPageBytes))
+
+// decompress is used to decompress the page compressed with the codec, of the size once decompressed
+func decompress(codec int64, data []byte, size int) ([]byte, error) {
+	if size < 0 || size > maxPageBytes {
+		return nil, ErrCorrupt
+	}
+	switch Codec(codec) {
+	case Uncompressed:
+		return data, nil
+	case Snappy:
+		if n, err := snappy.DecodedLen(data); err != nil || n != size {
+			return nil, ErrCorrupt
+		}
+		return snappy.Decode(data)
+	case Gzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w : %s", ErrCorrupt, err)
+		}
+		defer r.Close()
+		page, err := io.ReadAll(io.LimitReader(r, int64(size)+1))
+		if err != nil || len(page) != size {
+			return nil, ErrCorrupt
+		}
+		return page, nil
+	case Zstd:
+		page, err := zstdReader.DecodeAll(data, make([]byte, 0, size))
+		if err != nil || len(page) != size {
+			return nil, ErrCorrupt
+		}
+		return page, nil
+	}
+	return nil, fmt.Errorf("parquet: unsupported codec %d", codec)
+}
+
+// decodeHybrid is used to read the n values of the width of the rle and bit packed hybrid encoding, the one of
+// the definition levels and of the indices of the dictionaries
+func decodeHybrid(data []byte, width, n int) ([]uint32, error) {
+	if width < 0 || width > 32 {
+		return nil, ErrCorrupt
+	}
+	values := make([]uint32, 0, n)
+	pos := 0
+	for len(values) < n {
+		header, k := binary.Uvarint(data[pos:])
+		if k <= 0 {
+			return nil, ErrCorrupt
+		}
+		pos += k
+		if header&1 == 0 {
+			// a run of a value repeated, the value in the bytes its width fits in
+			size := (width + 7) / 8
+			if pos+size > len(data) {
+				return nil, ErrCorrupt
+			}
+			var v uint32
+			for i := 0; i < size; i++ {
+				v |= uint32(data[pos+i]) << (8 * i)
+			}
+			pos += size
+			count := header >> 1
+			if left := uint64(n - len(values)); count > left {
+				count = left
+			}
+			for i := uint64(0); i < count; i++ {
+				values = append(values, v)
+			}
+			continue
+		}
+		// groups of 8 values packed from the least significant bit
+		groups := header >> 1
+		if width > 0 && groups > uint64((len(data)-pos)/width) {
+			return nil, ErrCorrupt
+		}
+		count := groups * 8
+		if left := uint64(n - len(values)); count > left {
+			count = left
+		}
+		for i := 0; i < int(count); i++ {
+			var v uint32
+			for b := 0; b < width; b++ {
+				bit := i*width + b
+				v |= uint32(data[pos+bit/8]>>(bit%8)&1) << b
+			}
+			values = append(values, v)
+		}
+		pos += int(groups) * width
+	}
+	return values, nil
+}
+
+// decodePlain is used to read the n plain encoded values of the column
+func decodePlain(c *column, data []byte, n int) ([]interface{}, error) {
+	size := 0
+	switch c.physical {
+	case booleanType:
+		if (n+7)/8 > len(data) {
+			return nil, ErrCorrupt
+		}
+	case int32Type, floatType:
+		size = 4
+	case int64Type, doubleType:
+		size = 8
+	case int96Type:
+		size = 12
+	case byteArrayType:
+		if n > len(data)/4 {
+			return nil, ErrCorrupt
+		}
+	case fixedLenByteArrayType:
+		if size = c.typeLength; size <= 0 {
+			return nil, ErrCorrupt
+		}
+	default:
+		return nil, fmt.Errorf("parquet: unsupported type %d", c.physical)
+	}
+	if size > 0 && n > len(data)/size {
+		return nil, ErrCorrupt
+	}
+	values := make([]interface{}, 0, n)
+	pos := 0
+	for i := 0; i < n; i++ {
+		switch c.physical {
+		case booleanType:
+			values = append(values, data[i/8]>>(i%8)&1 == 1)
+		case int32Type:
+			values = append(values, c.integer(int64(int32(binary.LittleEndian.Uint32(data[pos:])))))
+		case int64Type:
+			values = append(values, c.integer(int64(binary.LittleEndian.Uint64(data[pos:]))))
+		case int96Type:
+			nanos := int64(binary.LittleEndian.Uint64(data[pos:]))
+			days := int64(int32(binary.LittleEndian.Uint32(data[pos+8:])))
+			values = append(values, time.Unix((days-julianUnixEpoch)*86400, nanos).UTC())
+		case floatType:
+			values = append(values, float64(math.Float32frombits(binary.LittleEndian.Uint32(data[pos:]))))
+		case doubleType:
+			values = append(values, math.Float64frombits(binary.LittleEndian.Uint64(data[pos:])))
+		case byteArrayType:
+			if pos+4 > len(data) {
+				return nil, ErrCorrupt
+			}
+			length := int(binary.LittleEndian.Uint32(data[pos:]))
+			if pos += 4; length > len(data)-pos {
+				return nil, ErrCorrupt
+			}
+			values = append(values, string(data[pos:pos+length]))
+			pos += length
+		case fixedLenByteArrayType:
+			values = append(values, string(data[pos:pos+size]))
+		}
+		pos += size
+	}
+	return values, nil
+}
+
+// integer is used to get the value of the integer of the column, the time of the timestamps and dates
+func (c *column) integer(v int64) interface{} {
+	if c.Kind != Timestamp {
+		return v
+	}
+	switch c.unit {
+	case 24 * time.Hour:
+		return time.Unix(v*86400, 0).UTC()
+	case time.Microsecond:
+		return time.UnixMicro(v).UTC()
+	case time.Nanosecond:
+		return time.Unix(0, v).UTC()
+	default:
+		return time.UnixMilli(v).UTC()
+	}
+}
+
+// decodeValues is used to add the n values of the page encoded with the encoding to the values, the nulls of
+// the definition levels as nil
+func decodeValues(values []interface{}, c *column, encoding int64, data []byte, n int, levels []uint32,
+	dictionary []interface{}) ([]interface{}, error) {
+	present := n
+	if levels != nil {
+		present = 0
+		for _, level := range levels {
+			if level > 0 {
+				present++
+			}
+		}
+	}
+	var decoded []interface{}
+	var err error
+	switch {
+	case present == 0:
+	case encoding == plainEncoding:
+		decoded, err = decodePlain(c, data, present)
+	case encoding == plainDictionaryEncoding || encoding == rleDictionaryEncoding:
+		if dictionary == nil {
+			return nil, fmt.Errorf("parquet: dictionary page missing")
+		}
+		if len(data) == 0 {
+			return nil, ErrCorrupt
+		}
+		indices, err := decodeHybrid(data[1:], int(data[0]), present)
+		if err != nil {
+			return nil, err
+		}
+		decoded = make([]interface{}, present)
+		for i, index := range indices {
+			if int(index) >= len(dictionary) {
+				return nil, ErrCorrupt
+			}
+			decoded[i] = dictionary[index]
+		}
+	case encoding == rleEncoding && c.physical == booleanType:
+		if len(data) < 4 || int64(binary.LittleEndian.Uint32(data)) > int64(len(data)-4) {
+			return nil, ErrCorrupt
+		}
+		bits, err := decodeHybrid(data[4:4+binary.LittleEndian.Uint32(data)], 1, present)
+		if err != nil {
+			return nil, err
+		}
+		decoded = make([]interface{}, present)
+		for i, bit := range bits {
+			decoded[i] = bit == 1
+		}
+	default:
+		return nil, fmt.Errorf("parquet: unsupported encoding %d", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+	j := 0
+	for i := 0; i < n; i++ {
+		if levels != nil && levels[i] == 0 {
+			values = append(values, nil)
+			continue
+		}
+		values = append(values, decoded[j])
+		j++
+	}
+	return values, nil
+}
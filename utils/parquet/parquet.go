@@ -0,0 +1,421 @@
+// Package parquet reads parquet files, e.g. the archives of the entries exported to a bucket, without a columnar
+// engine and the cgo it needs. The flat columns of the files are read, plain or dictionary encoded, uncompressed
+// or compressed with snappy, gzip or zstd, and the nested and repeated columns are skipped. Writing is limited to
+// a single row group, for the archives of the tests and of the tools.
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalid is returned when the data is not a parquet file
+	ErrInvalid = errors.New("parquet: not a parquet file")
+	// ErrCorrupt is returned when the file is not valid, e.g. truncated
+	ErrCorrupt = errors.New("parquet: corrupt file")
+)
+
+// Kind is the kind of the values of a column, as they are read. The strings are string, the integers int64, the
+// floating points float64, the booleans bool and the timestamps and dates time.Time in UTC.
+type Kind int
+
+// the kinds of the values
+const (
+	String Kind = iota
+	Int64
+	Double
+	Boolean
+	Timestamp
+)
+
+// Column is a flat column of a file
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// the physical types of the values
+const (
+	booleanType = iota
+	int32Type
+	int64Type
+	int96Type
+	floatType
+	doubleType
+	byteArrayType
+	fixedLenByteArrayType
+)
+
+// the repetitions, converted types, encodings, codecs and page types of the format
+const (
+	required = 0
+	optional = 1
+	repeated = 2
+
+	utf8Converted            = 0
+	dateConverted            = 6
+	timestampMillisConverted = 9
+	timestampMicrosConverted = 10
+
+	plainEncoding           = 0
+	plainDictionaryEncoding = 2
+	rleEncoding             = 3
+	rleDictionaryEncoding   = 8
+
+	dataPage       = 0
+	dictionaryPage = 2
+	dataPageV2     = 3
+
+	magic = "PAR1"
+	// julianUnixEpoch is the julian day of the unix epoch, the days of the int96 timestamps are julian
+	julianUnixEpoch = 2440588
+	// maxValues is the most values of a column of a row group read, as the counts of a file are not trusted
+	maxValues = 1 << 26
+)
+
+// column is a column of the file along with how its values are stored
+type column struct {
+	Column
+	physical   int64
+	typeLength int
+	optional   bool
+	// unit is the unit of the integers of the timestamps, a day for the dates
+	unit time.Duration
+}
+
+// File is a parquet file read in memory
+type File struct {
+	data    []byte
+	columns []column
+	// byPath are the columns by the path of their chunks
+	byPath map[string]*column
+	groups []fields
+}
+
+// RowGroup is a group of the rows of a file
+type RowGroup struct {
+	Rows int64
+	// Min and Max are the bounds of the values of the columns by name, the columns without statistics are missing
+	Min, Max map[string]interface{}
+}
+
+// Open is used to read the footer of the file, the pages of its columns are read when its rows are
+func Open(data []byte) (*File, error) {
+	if len(data) < 12 || string(data[:4]) != magic || string(data[len(data)-4:]) != magic {
+		return nil, ErrInvalid
+	}
+	length := int64(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	if length <= 0 || length > int64(len(data)-12) {
+		return nil, ErrCorrupt
+	}
+	d := decoder{data: data[len(data)-8-int(length) : len(data)-8]}
+	footer, err := d.readStruct()
+	if err != nil {
+		return nil, err
+	}
+	f := &File{data: data, byPath: make(map[string]*column)}
+	if f.columns, err = readSchema(footer.list(2)); err != nil {
+		return nil, err
+	}
+	for i := range f.columns {
+		f.byPath[f.columns[i].Name] = &f.columns[i]
+	}
+	for _, group := range footer.list(4) {
+		g, ok := group.(fields)
+		if !ok {
+			return nil, ErrCorrupt
+		}
+		f.groups = append(f.groups, g)
+	}
+	return f, nil
+}
+
+// readSchema is used to get the flat columns of the elements of the schema, depth first from its root
+func readSchema(elements []interface{}) ([]column, error) {
+	if len(elements) == 0 {
+		return nil, ErrCorrupt
+	}
+	schema := make([]fields, len(elements))
+	for i, element := range elements {
+		var ok bool
+		if schema[i], ok = element.(fields); !ok {
+			return nil, ErrCorrupt
+		}
+	}
+	var columns []column
+	i := 1
+	for n := int64(0); n < schema[0].int(5); n++ {
+		if i >= len(schema) {
+			return nil, ErrCorrupt
+		}
+		element := schema[i]
+		if element.int(5) > 0 || element.int(3) == repeated {
+			var err error
+			if i, err = skip(schema, i); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		columns = append(columns, newColumn(element))
+		i++
+	}
+	return columns, nil
+}
+
+// skip is used to get the index of the element after the element and its children
+func skip(schema []fields, i int) (int, error) {
+	children := schema[i].int(5)
+	i++
+	for n := int64(0); n < children; n++ {
+		if i >= len(schema) {
+			return 0, ErrCorrupt
+		}
+		var err error
+		if i, err = skip(schema, i); err != nil {
+			return 0, err
+		}
+	}
+	return i, nil
+}
+
+// newColumn is used to get the column of the leaf element of the schema
+func newColumn(element fields) column {
+	c := column{Column: Column{Name: string(element.bytes(4))}, physical: element.int(1),
+		typeLength: int(element.int(2)), optional: element.int(3) == optional}
+	logical, converted := element.structure(10), int64(-1)
+	if element.has(6) {
+		converted = element.int(6)
+	}
+	switch c.physical {
+	case booleanType:
+		c.Kind = Boolean
+	case int32Type:
+		c.Kind = Int64
+		if logical.has(6) || converted == dateConverted {
+			c.Kind, c.unit = Timestamp, 24*time.Hour
+		}
+	case int64Type:
+		c.Kind = Int64
+		if timestamp := logical.structure(8); timestamp != nil {
+			c.Kind, c.unit = Timestamp, time.Millisecond
+			switch unit := timestamp.structure(2); {
+			case unit.has(2):
+				c.unit = time.Microsecond
+			case unit.has(3):
+				c.unit = time.Nanosecond
+			}
+		} else if converted == timestampMillisConverted {
+			c.Kind, c.unit = Timestamp, time.Millisecond
+		} else if converted == timestampMicrosConverted {
+			c.Kind, c.unit = Timestamp, time.Microsecond
+		}
+	case int96Type:
+		c.Kind = Timestamp
+	case floatType, doubleType:
+		c.Kind = Double
+	default:
+		c.Kind = String
+	}
+	return c
+}
+
+// Columns is used to get the flat columns of the file, in the order of its schema
+func (f *File) Columns() []Column {
+	columns := make([]Column, len(f.columns))
+	for i, c := range f.columns {
+		columns[i] = c.Column
+	}
+	return columns
+}
+
+// RowGroups is used to get the row groups of the file, with the statistics of their columns
+func (f *File) RowGroups() []RowGroup {
+	groups := make([]RowGroup, len(f.groups))
+	for i, g := range f.groups {
+		groups[i] = RowGroup{Rows: g.int(3), Min: make(map[string]interface{}), Max: make(map[string]interface{})}
+		for _, chunk := range g.list(1) {
+			meta, c := f.chunk(chunk)
+			if c == nil {
+				continue
+			}
+			statistics, min, max := meta.structure(12), int16(6), int16(5)
+			if !statistics.has(min) && c.Kind != String {
+				// the deprecated bounds are only sorted right for the signed values
+				min, max = 2, 1
+			}
+			if v, ok := statistic(c, statistics, min); ok {
+				groups[i].Min[c.Name] = v
+			}
+			if v, ok := statistic(c, statistics, max); ok {
+				groups[i].Max[c.Name] = v
+			}
+		}
+	}
+	return groups
+}
+
+// statistic is used to read the bound of the statistics, the plain encoded value without the length of the
+// byte arrays
+func statistic(c *column, statistics fields, id int16) (interface{}, bool) {
+	raw, ok := statistics[id].([]byte)
+	if !ok {
+		return nil, false
+	}
+	if c.physical == byteArrayType || c.physical == fixedLenByteArrayType {
+		return string(raw), true
+	}
+	values, err := decodePlain(c, raw, 1)
+	if err != nil || len(values) != 1 {
+		return nil, false
+	}
+	return values[0], true
+}
+
+// Rows is used to read the rows of the row group, the values of the flat columns by name with the nulls missing
+func (f *File) Rows(group int) ([]map[string]interface{}, error) {
+	if group < 0 || group >= len(f.groups) {
+		return nil, fmt.Errorf("parquet: no row group %d", group)
+	}
+	g := f.groups[group]
+	count := g.int(3)
+	if count < 0 || count > maxValues {
+		return nil, ErrCorrupt
+	}
+	rows := make([]map[string]interface{}, count)
+	for i := range rows {
+		rows[i] = make(map[string]interface{}, len(f.columns))
+	}
+	for _, chunk := range g.list(1) {
+		meta, c := f.chunk(chunk)
+		if c == nil {
+			continue
+		}
+		values, err := f.read(c, meta)
+		if err != nil {
+			return nil, fmt.Errorf("error reading column %s : %w", c.Name, err)
+		}
+		if int64(len(values)) != count {
+			return nil, ErrCorrupt
+		}
+		for i, value := range values {
+			if value != nil {
+				rows[i][c.Name] = value
+			}
+		}
+	}
+	return rows, nil
+}
+
+// chunk is used to get the metadata of the column chunk and its column, nil for the chunks of skipped columns
+func (f *File) chunk(chunk interface{}) (fields, *column) {
+	c, _ := chunk.(fields)
+	meta := c.structure(3)
+	var path []string
+	for _, part := range meta.list(3) {
+		name, _ := part.([]byte)
+		path = append(path, string(name))
+	}
+	return meta, f.byPath[strings.Join(path, ".")]
+}
+
+// read is used to read the values of the column chunk, nil for the nulls
+func (f *File) read(c *column, meta fields) ([]interface{}, error) {
+	codec, count := meta.int(4), meta.int(5)
+	start, size := meta.int(9), meta.int(7)
+	if offset := meta.int(11); meta.has(11) && offset > 0 && offset < start {
+		start = offset
+	}
+	if count < 0 || count > maxValues || start < 0 || size < 0 || start+size > int64(len(f.data)) {
+		return nil, ErrCorrupt
+	}
+	d := decoder{data: f.data[start : start+size]}
+	values := make([]interface{}, 0, count)
+	var dictionary []interface{}
+	for int64(len(values)) < count {
+		header, err := d.readStruct()
+		if err != nil {
+			return nil, err
+		}
+		length, uncompressed := header.int(3), header.int(2)
+		if length < 0 || length > int64(len(d.data)-d.pos) || uncompressed < 0 || uncompressed > maxValues*16 {
+			return nil, ErrCorrupt
+		}
+		page := d.data[d.pos : d.pos+int(length)]
+		d.pos += int(length)
+		switch header.int(1) {
+		case dictionaryPage:
+			h := header.structure(7)
+			data, err := decompress(codec, page, int(uncompressed))
+			if err != nil {
+				return nil, err
+			}
+			if h.int(1) < 0 || h.int(1) > maxValues {
+				return nil, ErrCorrupt
+			}
+			if dictionary, err = decodePlain(c, data, int(h.int(1))); err != nil {
+				return nil, err
+			}
+		case dataPage:
+			h := header.structure(5)
+			data, err := decompress(codec, page, int(uncompressed))
+			if err != nil {
+				return nil, err
+			}
+			n := h.int(1)
+			if n < 0 || n > count-int64(len(values)) {
+				return nil, ErrCorrupt
+			}
+			var levels []uint32
+			if c.optional {
+				if h.int(3) != rleEncoding {
+					return nil, fmt.Errorf("parquet: unsupported definition level encoding %d", h.int(3))
+				}
+				if len(data) < 4 {
+					return nil, ErrCorrupt
+				}
+				size := int64(binary.LittleEndian.Uint32(data))
+				if size > int64(len(data)-4) {
+					return nil, ErrCorrupt
+				}
+				if levels, err = decodeHybrid(data[4:4+size], 1, int(n)); err != nil {
+					return nil, err
+				}
+				data = data[4+size:]
+			}
+			if values, err = decodeValues(values, c, h.int(2), data, int(n), levels, dictionary); err != nil {
+				return nil, err
+			}
+		case dataPageV2:
+			h := header.structure(8)
+			n, definitions, repetitions := h.int(1), h.int(5), h.int(6)
+			if n < 0 || n > count-int64(len(values)) || definitions < 0 || repetitions < 0 ||
+				definitions+repetitions > int64(len(page)) {
+				return nil, ErrCorrupt
+			}
+			data := page[definitions+repetitions:]
+			if h.bool(7, true) {
+				if data, err = decompress(codec, data, int(uncompressed-definitions-repetitions)); err != nil {
+					return nil, err
+				}
+			}
+			var levels []uint32
+			if c.optional {
+				levels, err = decodeHybrid(page[repetitions:repetitions+definitions], 1, int(n))
+				if err != nil {
+					return nil, err
+				}
+			}
+			if values, err = decodeValues(values, c, h.int(4), data, int(n), levels, dictionary); err != nil {
+				return nil, err
+			}
+		}
+		if d.pos >= len(d.data) && int64(len(values)) < count {
+			return nil, ErrCorrupt
+		}
+	}
+	return values, nil
+}
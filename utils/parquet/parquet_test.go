@@ -0,0 +1,80 @@
+package parquet_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/utils/parquet"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoundTrip(t *testing.T) {
+	start := time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)
+	columns := []parquet.Column{
+		{Name: "timestamp", Kind: parquet.Timestamp},
+		{Name: "type", Kind: parquet.String},
+		{Name: "attempts", Kind: parquet.Int64},
+		{Name: "amount", Kind: parquet.Double},
+		{Name: "retried", Kind: parquet.Boolean},
+	}
+	var rows []map[string]interface{}
+	for i := 0; i < 20; i++ {
+		row := map[string]interface{}{"timestamp": start.Add(time.Duration(i) * time.Minute),
+			"type": []string{"payment", "refund", "order"}[i%3], "attempts": int64(i), "retried": i%2 == 0}
+		// every fourth row has no amount
+		if i%4 != 0 {
+			row["amount"] = float64(i) / 2
+		}
+		rows = append(rows, row)
+	}
+
+	for _, codec := range []parquet.Codec{parquet.Uncompressed, parquet.Snappy, parquet.Gzip, parquet.Zstd} {
+		var b bytes.Buffer
+		assert.NoError(t, parquet.Write(&b, columns, rows, codec))
+		file, err := parquet.Open(b.Bytes())
+		assert.NoError(t, err)
+		assert.Equal(t, columns, file.Columns())
+
+		groups := file.RowGroups()
+		assert.Len(t, groups, 1)
+		assert.Equal(t, int64(20), groups[0].Rows)
+		assert.Equal(t, start, groups[0].Min["timestamp"])
+		assert.Equal(t, start.Add(19*time.Minute), groups[0].Max["timestamp"])
+		assert.Equal(t, "order", groups[0].Min["type"])
+		assert.Equal(t, "refund", groups[0].Max["type"])
+		assert.Equal(t, 0.5, groups[0].Min["amount"])
+		assert.NotContains(t, groups[0].Min, "retried")
+
+		read, err := file.Rows(0)
+		assert.NoError(t, err)
+		assert.Equal(t, rows, read)
+		_, err = file.Rows(1)
+		assert.Error(t, err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	var b bytes.Buffer
+	assert.NoError(t, parquet.Write(&b, []parquet.Column{{Name: "type", Kind: parquet.String}},
+		[]map[string]interface{}{{"type": "payment"}}, parquet.Uncompressed))
+	data := b.Bytes()
+
+	_, err := parquet.Open([]byte("not a parquet file"))
+	assert.ErrorIs(t, err, parquet.ErrInvalid)
+	// a footer longer than the file
+	corrupt := append([]byte{}, data...)
+	corrupt[len(corrupt)-8] = 0xff
+	_, err = parquet.Open(corrupt)
+	assert.ErrorIs(t, err, parquet.ErrCorrupt)
+	// a page header overwritten
+	corrupt = append([]byte{}, data...)
+	copy(corrupt[4:], bytes.Repeat([]byte{0xff}, 8))
+	file, err := parquet.Open(corrupt)
+	assert.NoError(t, err)
+	_, err = file.Rows(0)
+	assert.Error(t, err)
+	// invalid values are not written
+	assert.Error(t, parquet.Write(&b, []parquet.Column{{Name: "attempts", Kind: parquet.Int64}},
+		[]map[string]interface{}{{"attempts": "one"}}, parquet.Uncompressed))
+}
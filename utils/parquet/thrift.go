@@ -0,0 +1,308 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// the types of the thrift compact protocol, the footer and the page headers are thrift structs
+const (
+	typeStop   = 0
+	typeTrue   = 1
+	typeFalse  = 2
+	typeByte   = 3
+	typeI16    = 4
+	typeI32    = 5
+	typeI64    = 6
+	typeDouble = 7
+	typeBinary = 8
+	typeList   = 9
+	typeSet    = 10
+	typeMap    = 11
+	typeStruct = 12
+
+	// maxDepth is the deepest nesting of structs read, the structs of parquet are a few levels deep
+	maxDepth = 16
+)
+
+// fields is a thrift struct read without its definition, its values by field id. The integers are int64, the
+// binaries []byte, the lists []interface{} and the structs fields.
+type fields map[int16]interface{}
+
+func (f fields) int(id int16) int64 {
+	v, _ := f[id].(int64)
+	return v
+}
+
+func (f fields) has(id int16) bool {
+	_, ok := f[id]
+	return ok
+}
+
+func (f fields) bytes(id int16) []byte {
+	v, _ := f[id].([]byte)
+	return v
+}
+
+func (f fields) list(id int16) []interface{} {
+	v, _ := f[id].([]interface{})
+	return v
+}
+
+func (f fields) structure(id int16) fields {
+	v, _ := f[id].(fields)
+	return v
+}
+
+func (f fields) bool(id int16, fallback bool) bool {
+	if v, ok := f[id].(bool); ok {
+		return v
+	}
+	return fallback
+}
+
+// decoder reads thrift structs in the compact protocol
+type decoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, ErrCorrupt
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *decoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		return 0, ErrCorrupt
+	}
+	d.pos += n
+	return v, nil
+}
+
+func (d *decoder) varint() (int64, error) {
+	v, err := d.uvarint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+// readStruct is used to read the struct at the position, its fields of unknown types are an error
+func (d *decoder) readStruct() (fields, error) {
+	if d.depth++; d.depth > maxDepth {
+		return nil, ErrCorrupt
+	}
+	defer func() { d.depth-- }()
+	f := fields{}
+	var id int16
+	for {
+		header, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		if header == typeStop {
+			return f, nil
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := d.varint()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		switch t := header & 0x0f; t {
+		case typeTrue:
+			f[id] = true
+		case typeFalse:
+			f[id] = false
+		default:
+			if f[id], err = d.value(t); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// value is used to read the value of the type at the position, the maps are read past and dropped
+func (d *decoder) value(t byte) (interface{}, error) {
+	switch t {
+	case typeTrue, typeFalse:
+		// the booleans of the lists are a byte each
+		b, err := d.byte()
+		return b == typeTrue, err
+	case typeByte:
+		b, err := d.byte()
+		return int64(int8(b)), err
+	case typeI16, typeI32, typeI64:
+		return d.varint()
+	case typeDouble:
+		if len(d.data)-d.pos < 8 {
+			return nil, ErrCorrupt
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(d.data[d.pos:]))
+		d.pos += 8
+		return v, nil
+	case typeBinary:
+		n, err := d.uvarint()
+		if err != nil || n > uint64(len(d.data)-d.pos) {
+			return nil, ErrCorrupt
+		}
+		v := d.data[d.pos : d.pos+int(n)]
+		d.pos += int(n)
+		return v, nil
+	case typeList, typeSet:
+		header, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, elem := uint64(header>>4), header&0x0f
+		if size == 15 {
+			if size, err = d.uvarint(); err != nil {
+				return nil, err
+			}
+		}
+		// every element is a byte at least
+		if size > uint64(len(d.data)-d.pos) {
+			return nil, ErrCorrupt
+		}
+		list := make([]interface{}, 0, size)
+		for i := uint64(0); i < size; i++ {
+			v, err := d.value(elem)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case typeMap:
+		size, err := d.uvarint()
+		if err != nil || size > uint64(len(d.data)-d.pos) {
+			return nil, ErrCorrupt
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		types, err := d.byte()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint64(0); i < size; i++ {
+			if _, err = d.value(types >> 4); err != nil {
+				return nil, err
+			}
+			if _, err = d.value(types & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case typeStruct:
+		return d.readStruct()
+	}
+	return nil, ErrCorrupt
+}
+
+// field is a field of a struct written, the values are bool, int32, int64, string, []byte, list and []field for
+// the structs
+type field struct {
+	id    int16
+	value interface{}
+}
+
+// list is a list written, of elements of the type
+type list struct {
+	elem   byte
+	values []interface{}
+}
+
+// encoder writes thrift structs in the compact protocol
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) uvarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	e.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (e *encoder) varint(v int64) {
+	e.uvarint(uint64(v<<1) ^ uint64(v>>63))
+}
+
+// writeStruct is used to write the fields, in the order of their ids
+func (e *encoder) writeStruct(fields []field) {
+	var last int16
+	for _, f := range fields {
+		t := typeOf(f.value)
+		if v, ok := f.value.(bool); ok && !v {
+			t = typeFalse
+		}
+		if delta := f.id - last; delta > 0 && delta <= 15 {
+			e.WriteByte(byte(delta)<<4 | t)
+		} else {
+			e.WriteByte(t)
+			e.varint(int64(f.id))
+		}
+		last = f.id
+		if t != typeTrue && t != typeFalse {
+			e.value(f.value)
+		}
+	}
+	e.WriteByte(typeStop)
+}
+
+func (e *encoder) value(v interface{}) {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			e.WriteByte(typeTrue)
+		} else {
+			e.WriteByte(typeFalse)
+		}
+	case int32:
+		e.varint(int64(v))
+	case int64:
+		e.varint(v)
+	case string:
+		e.uvarint(uint64(len(v)))
+		e.WriteString(v)
+	case []byte:
+		e.uvarint(uint64(len(v)))
+		e.Write(v)
+	case list:
+		if len(v.values) < 15 {
+			e.WriteByte(byte(len(v.values))<<4 | v.elem)
+		} else {
+			e.WriteByte(0xf0 | v.elem)
+			e.uvarint(uint64(len(v.values)))
+		}
+		for _, value := range v.values {
+			e.value(value)
+		}
+	case []field:
+		e.writeStruct(v)
+	}
+}
+
+func typeOf(v interface{}) byte {
+	switch v.(type) {
+	case bool:
+		return typeTrue
+	case int32:
+		return typeI32
+	case int64:
+		return typeI64
+	case string, []byte:
+		return typeBinary
+	case list:
+		return typeList
+	default:
+		return typeStruct
+	}
+}
@@ -0,0 +1,317 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is the compression of the pages of a file
+type Codec int64
+
+// the codecs of the pages
+const (
+	Uncompressed Codec = 0
+	Snappy       Codec = 1
+	Gzip         Codec = 2
+	Zstd         Codec = 6
+)
+
+// createdBy is the writer the files written tell they were created by
+const createdBy = "nbu-logger-service"
+
+var zstdWriter, _ = zstd.NewWriter(nil)
+
+// Write is used to write the rows as a file of a single row group, of the optional columns and with its pages
+// compressed with the codec. The values missing from a row are nulls. The strings are dictionary encoded and the
+// other values plain encoded, the timestamps in millis, and the bounds of the values of every column but the
+// booleans are written as its statistics.
+func Write(w io.Writer, columns []Column, rows []map[string]interface{}, codec Codec) error {
+	var file bytes.Buffer
+	file.WriteString(magic)
+	schema := []interface{}{[]field{{4, "schema"}, {5, int32(len(columns))}}}
+	chunks := make([]interface{}, 0, len(columns))
+	for _, c := range columns {
+		schema = append(schema, schemaElement(c))
+		chunk, err := writeColumn(&file, c, rows, codec)
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, chunk)
+	}
+	group := []field{{1, list{typeStruct, chunks}}, {2, int64(file.Len() - len(magic))}, {3, int64(len(rows))}}
+	var footer encoder
+	footer.writeStruct([]field{
+		{1, int32(1)},
+		{2, list{typeStruct, schema}},
+		{3, int64(len(rows))},
+		{4, list{typeStruct, []interface{}{group}}},
+		{6, createdBy},
+	})
+	file.Write(footer.Bytes())
+	_ = binary.Write(&file, binary.LittleEndian, uint32(footer.Len()))
+	file.WriteString(magic)
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// schemaElement is used to get the element of the schema of the column
+func schemaElement(c Column) []field {
+	element := []field{{1, int32(physicalOf(c.Kind))}, {3, int32(optional)}, {4, c.Name}}
+	switch c.Kind {
+	case String:
+		element = append(element, field{6, int32(utf8Converted)}, field{10, []field{{1, []field{}}}})
+	case Timestamp:
+		unit := []field{{1, []field{}}}
+		element = append(element, field{6, int32(timestampMillisConverted)},
+			field{10, []field{{8, []field{{1, true}, {2, unit}}}}})
+	}
+	return element
+}
+
+func physicalOf(kind Kind) int {
+	switch kind {
+	case String:
+		return byteArrayType
+	case Double:
+		return doubleType
+	case Boolean:
+		return booleanType
+	default:
+		return int64Type
+	}
+}
+
+// writeColumn is used to write the pages of the column of the rows to the file and get its column chunk
+func writeColumn(file *bytes.Buffer, c Column, rows []map[string]interface{}, codec Codec) ([]field, error) {
+	definitions := make([]byte, (len(rows)+7)/8)
+	var present []interface{}
+	for i, row := range rows {
+		v, ok := row[c.Name]
+		if !ok || v == nil {
+			continue
+		}
+		value, err := normalize(c, v)
+		if err != nil {
+			return nil, err
+		}
+		definitions[i/8] |= 1 << (i % 8)
+		present = append(present, value)
+	}
+	var levels encoder
+	levels.uvarint(uint64(len(definitions))<<1 | 1)
+	levels.Write(definitions)
+
+	start := int64(file.Len())
+	var uncompressed, compressed int64
+	meta := []field{{1, int32(physicalOf(c.Kind))}}
+	encoding, data := plainEncoding, encodePlain(c, present)
+	var dictionaryOffset []field
+	if c.Kind == String {
+		// the strings are written once in the dictionary and their rows are indices into it
+		index := make(map[interface{}]int)
+		var dictionary []interface{}
+		indices := make([]int, len(present))
+		for i, v := range present {
+			j, ok := index[v]
+			if !ok {
+				j = len(dictionary)
+				index[v] = j
+				dictionary = append(dictionary, v)
+			}
+			indices[i] = j
+		}
+		header := field{7, []field{{1, int32(len(dictionary))}, {2, int32(plainEncoding)}}}
+		u, w, err := writePage(file, dictionaryPage, header, encodePlain(c, dictionary), codec)
+		if err != nil {
+			return nil, err
+		}
+		uncompressed, compressed = uncompressed+u, compressed+w
+		dictionaryOffset = []field{{11, start}}
+		encoding, data = rleDictionaryEncoding, encodeIndices(indices, len(dictionary))
+	}
+	dataOffset := int64(file.Len())
+	page := binary.LittleEndian.AppendUint32(nil, uint32(levels.Len()))
+	page = append(append(page, levels.Bytes()...), data...)
+	header := field{5, []field{{1, int32(len(rows))}, {2, int32(encoding)}, {3, int32(rleEncoding)},
+		{4, int32(rleEncoding)}}}
+	u, w, err := writePage(file, dataPage, header, page, codec)
+	if err != nil {
+		return nil, err
+	}
+	uncompressed, compressed = uncompressed+u, compressed+w
+
+	meta = append(meta,
+		field{2, list{typeI32, []interface{}{int32(encoding), int32(rleEncoding), int32(plainEncoding)}}},
+		field{3, list{typeBinary, []interface{}{c.Name}}},
+		field{4, int32(codec)},
+		field{5, int64(len(rows))},
+		field{6, uncompressed},
+		field{7, compressed},
+		field{9, dataOffset})
+	meta = append(meta, dictionaryOffset...)
+	if min, max, ok := bounds(c, present); ok {
+		meta = append(meta, field{12, []field{{5, max}, {6, min}}})
+	}
+	return []field{{2, start}, {3, meta}}, nil
+}
+
+// writePage is used to write the page with its header and get its sizes uncompressed and compressed
+func writePage(file *bytes.Buffer, kind int, header field, page []byte, codec Codec) (int64, int64, error) {
+	compressed, err := compress(codec, page)
+	if err != nil {
+		return 0, 0, err
+	}
+	var h encoder
+	h.writeStruct([]field{{1, int32(kind)}, {2, int32(len(page))}, {3, int32(len(compressed))}, header})
+	file.Write(h.Bytes())
+	file.Write(compressed)
+	return int64(h.Len() + len(page)), int64(h.Len() + len(compressed)), nil
+}
+
+func compress(codec Codec, page []byte) ([]byte, error) {
+	switch codec {
+	case Uncompressed:
+		return page, nil
+	case Snappy:
+		return s2.EncodeSnappy(nil, page), nil
+	case Gzip:
+		var b bytes.Buffer
+		w := gzip.NewWriter(&b)
+		if _, err := w.Write(page); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case Zstd:
+		return zstdWriter.EncodeAll(page, nil), nil
+	}
+	return nil, fmt.Errorf("parquet: unsupported codec %d", codec)
+}
+
+// normalize is used to get the value as it is encoded, the strings as string, the booleans as bool, the floating
+// points as float64 and the integers and timestamps as int64
+func normalize(c Column, v interface{}) (interface{}, error) {
+	switch c.Kind {
+	case String:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case Int64:
+		switch v := v.(type) {
+		case int:
+			return int64(v), nil
+		case int32:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		}
+	case Double:
+		switch v := v.(type) {
+		case float32:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		}
+	case Boolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	case Timestamp:
+		if t, ok := v.(time.Time); ok {
+			return t.UnixMilli(), nil
+		}
+	}
+	return nil, fmt.Errorf("parquet: invalid value %v of column %s", v, c.Name)
+}
+
+// encodePlain is used to get the plain encoding of the normalized values of the column
+func encodePlain(c Column, values []interface{}) []byte {
+	var b bytes.Buffer
+	if c.Kind == Boolean {
+		packed := make([]byte, (len(values)+7)/8)
+		for i, v := range values {
+			if v.(bool) {
+				packed[i/8] |= 1 << (i % 8)
+			}
+		}
+		return packed
+	}
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			_ = binary.Write(&b, binary.LittleEndian, uint32(len(v)))
+			b.WriteString(v)
+		case int64:
+			_ = binary.Write(&b, binary.LittleEndian, v)
+		case float64:
+			_ = binary.Write(&b, binary.LittleEndian, math.Float64bits(v))
+		}
+	}
+	return b.Bytes()
+}
+
+// encodeIndices is used to get the indices into the dictionary of the size bit packed, after their width
+func encodeIndices(indices []int, size int) []byte {
+	width := 0
+	if size > 1 {
+		width = bits.Len(uint(size - 1))
+	}
+	groups := (len(indices) + 7) / 8
+	var e encoder
+	e.WriteByte(byte(width))
+	e.uvarint(uint64(groups)<<1 | 1)
+	packed := make([]byte, groups*width)
+	for i, index := range indices {
+		for b := 0; b < width; b++ {
+			if bit := i*width + b; index>>b&1 == 1 {
+				packed[bit/8] |= 1 << (bit % 8)
+			}
+		}
+	}
+	e.Write(packed)
+	return e.Bytes()
+}
+
+// bounds is used to get the plain encoded least and greatest of the normalized values, the byte arrays without
+// their length
+func bounds(c Column, values []interface{}) ([]byte, []byte, bool) {
+	if c.Kind == Boolean || len(values) == 0 {
+		return nil, nil, false
+	}
+	min, max := values[0], values[0]
+	for _, v := range values[1:] {
+		if less(v, min) {
+			min = v
+		}
+		if less(max, v) {
+			max = v
+		}
+	}
+	if c.Kind == String {
+		return []byte(min.(string)), []byte(max.(string)), true
+	}
+	return encodePlain(c, []interface{}{min}), encodePlain(c, []interface{}{max}), true
+}
+
+func less(a, b interface{}) bool {
+	switch a := a.(type) {
+	case string:
+		return a < b.(string)
+	case int64:
+		return a < b.(int64)
+	case float64:
+		return a < b.(float64)
+	}
+	return false
+}
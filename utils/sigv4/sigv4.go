@@ -0,0 +1,113 @@
+// Package sigv4 signs the requests to the aws apis with the signature version 4, so that shipping to aws services
+// does not depend on the aws sdk.
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	dateLayout = "20060102"
+	timeLayout = "20060102T150405Z"
+	terminator = "aws4_request"
+)
+
+// Credentials are the access keys requests are signed with
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the token of the temporary credentials, e.g. the ones of an assumed role
+	SessionToken string
+}
+
+// FromEnv is used to get the credentials of the standard aws environment variables
+func FromEnv() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Sign is used to add the X-Amz-Date, X-Amz-Security-Token and Authorization headers signing the request to the
+// headers, every header of the headers is signed along with the host of the url
+func Sign(method, rawURL string, headers map[string]string, body []byte, credentials Credentials, region,
+	service string, now time.Time) error {
+	if credentials.AccessKeyID == "" || credentials.SecretAccessKey == "" {
+		return fmt.Errorf("aws credentials are required")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url to sign : %w", err)
+	}
+	now = now.UTC()
+	headers["X-Amz-Date"] = now.Format(timeLayout)
+	if credentials.SessionToken != "" {
+		headers["X-Amz-Security-Token"] = credentials.SessionToken
+	}
+
+	signed := map[string]string{"host": u.Host}
+	for name, value := range headers {
+		signed[strings.ToLower(name)] = strings.Join(strings.Fields(value), " ")
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + signed[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payload := sha256.Sum256(body)
+	canonical := strings.Join([]string{method, path, canonicalQuery(u.Query()), canonicalHeaders.String(),
+		signedHeaders, hex.EncodeToString(payload[:])}, "\n")
+	hashed := sha256.Sum256([]byte(canonical))
+
+	scope := strings.Join([]string{now.Format(dateLayout), region, service, terminator}, "/")
+	toSign := strings.Join([]string{algorithm, now.Format(timeLayout), scope, hex.EncodeToString(hashed[:])}, "\n")
+	key := []byte("AWS4" + credentials.SecretAccessKey)
+	for _, part := range []string{now.Format(dateLayout), region, service, terminator} {
+		key = sum(key, part)
+	}
+	headers["Authorization"] = fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", algorithm,
+		credentials.AccessKeyID, scope, signedHeaders, hex.EncodeToString(sum(key, toSign)))
+	return nil
+}
+
+// canonicalQuery is used to get the query sorted by name and value, with the spaces encoded as %20
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, escape(name)+"="+escape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func sum(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,33 @@
+package sigv4_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/utils/sigv4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// the get-vanilla case of the signature version 4 test suite of aws
+	credentials := sigv4.Credentials{AccessKeyID: "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	headers := map[string]string{}
+	at := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	assert.NoError(t, sigv4.Sign("GET", "https://example.amazonaws.com/", headers, nil, credentials, "us-east-1",
+		"service", at))
+	assert.Equal(t, "20150830T123600Z", headers["X-Amz-Date"])
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, "+
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31", headers["Authorization"])
+
+	credentials.SessionToken = "token"
+	headers = map[string]string{}
+	assert.NoError(t, sigv4.Sign("GET", "https://example.amazonaws.com/", headers, nil, credentials, "us-east-1",
+		"service", at))
+	assert.Equal(t, "token", headers["X-Amz-Security-Token"])
+	assert.Contains(t, headers["Authorization"], "SignedHeaders=host;x-amz-date;x-amz-security-token,")
+
+	assert.Error(t, sigv4.Sign("GET", "https://example.amazonaws.com/", map[string]string{}, nil,
+		sigv4.Credentials{}, "us-east-1", "service", at))
+}
@@ -0,0 +1,102 @@
+// Package snappy decodes the snappy block format, the compression of the protobuf push requests of loki
+// clients such as promtail. Only decoding is needed, so there is no dependency for it.
+package snappy
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrCorrupt is returned when the block is not a valid snappy block
+var ErrCorrupt = errors.New("snappy: corrupt input")
+
+// the kinds of the elements of a block, in the low bits of their tag
+const (
+	literal = 0
+	copy1   = 1
+	copy2   = 2
+	copy4   = 3
+)
+
+// DecodedLen is used to get the length of the decoded block, read from its header
+func DecodedLen(src []byte) (int, error) {
+	length, _, err := decodedLen(src)
+	return length, err
+}
+
+func decodedLen(src []byte) (int, int, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > 0xffffffff {
+		return 0, 0, ErrCorrupt
+	}
+	return int(length), n, nil
+}
+
+// Decode is used to decode the block, check its DecodedLen first when the length has to be bounded
+func Decode(src []byte) ([]byte, error) {
+	length, s, err := decodedLen(src)
+	if err != nil {
+		return nil, err
+	}
+	dst := make([]byte, 0, length)
+	for s < len(src) {
+		tag := src[s]
+		var size, offset int
+		switch tag & 0x03 {
+		case literal:
+			size = int(tag >> 2)
+			s++
+			// longer literals have their size minus one in the 1 to 4 bytes after the tag
+			if size >= 60 {
+				extra := size - 59
+				if s+extra > len(src) {
+					return nil, ErrCorrupt
+				}
+				size = 0
+				for i := 0; i < extra; i++ {
+					size |= int(src[s+i]) << (8 * i)
+				}
+				s += extra
+			}
+			size++
+			if size <= 0 || s+size > len(src) || len(dst)+size > length {
+				return nil, ErrCorrupt
+			}
+			dst = append(dst, src[s:s+size]...)
+			s += size
+			continue
+		case copy1:
+			if s+2 > len(src) {
+				return nil, ErrCorrupt
+			}
+			size = 4 + int(tag>>2)&0x07
+			offset = int(tag&0xe0)<<3 | int(src[s+1])
+			s += 2
+		case copy2:
+			if s+3 > len(src) {
+				return nil, ErrCorrupt
+			}
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[s+1:]))
+			s += 3
+		case copy4:
+			if s+5 > len(src) {
+				return nil, ErrCorrupt
+			}
+			size = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[s+1:]))
+			s += 5
+		}
+		if offset <= 0 || offset > len(dst) || len(dst)+size > length {
+			return nil, ErrCorrupt
+		}
+		// the copies can overlap what they append, so they are appended byte by byte
+		for i := 0; i < size; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if len(dst) != length {
+		return nil, ErrCorrupt
+	}
+	return dst, nil
+}
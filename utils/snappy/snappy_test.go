@@ -0,0 +1,38 @@
+package snappy_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/utils/snappy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	// a literal abc and a copy of 9 bytes 3 bytes back, overlapping what it appends
+	block := []byte{12, 2 << 2, 'a', 'b', 'c', (9-4)<<2 | 1, 3}
+	length, err := snappy.DecodedLen(block)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, length)
+	decoded, err := snappy.Decode(block)
+	assert.NoError(t, err)
+	assert.Equal(t, "abcabcabcabc", string(decoded))
+
+	// a literal of 100 bytes has its size in the byte after the tag, and a copy with a 2 byte offset
+	long := bytes.Repeat([]byte("x"), 100)
+	block = append([]byte{104, 60 << 2, 99}, long...)
+	block = append(block, (4-1)<<2|2, 100, 0)
+	decoded, err = snappy.Decode(block)
+	assert.NoError(t, err)
+	assert.Equal(t, string(long)+"xxxx", string(decoded))
+
+	for _, corrupt := range [][]byte{
+		{},
+		{5, 2 << 2, 'a', 'b', 'c'},
+		{12, 2 << 2, 'a', 'b', 'c', (9-4)<<2 | 1, 4},
+		{3, 2 << 2, 'a'},
+	} {
+		_, err = snappy.Decode(corrupt)
+		assert.ErrorIs(t, err, snappy.ErrCorrupt, corrupt)
+	}
+}
@@ -0,0 +1,167 @@
+// Package violations keeps count of the entries rejected by validation, grouped by the api key that sent them,
+// their type and the constraint they violated, so that the producers of bad payloads are chased rather than
+// found out about by the analysts downstream. The groups are browsable through the admin api and counted in a
+// labeled metric.
+package violations
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/tenants"
+)
+
+// Config is the configuration of the validation failures kept
+type Config struct {
+	// MaxGroups is the number of groups kept, the failures of further groups are counted in a single group whose
+	// fields are all __overflow__
+	MaxGroups int `json:"maxGroups" mapstructure:"maxGroups"`
+}
+
+// Group are the failures of the entries of a type sent with an api key that violated a constraint
+type Group struct {
+	APIKey      string    `json:"apiKey"`
+	Type        string    `json:"type"`
+	Constraint  string    `json:"constraint"`
+	Count       int64     `json:"count"`
+	FirstSeenAt time.Time `json:"firstSeenAt"`
+	LastSeenAt  time.Time `json:"lastSeenAt"`
+	// LastError is the error of the latest failure, telling the field and the value expected
+	LastError string `json:"lastError"`
+}
+
+// Filter selects groups, empty fields match all
+type Filter struct {
+	APIKey     string
+	Type       string
+	Constraint string
+	// Limit is the maximum number of groups returned, 0 means no limit
+	Limit int
+}
+
+type key struct {
+	apiKey, logType, constraint string
+}
+
+// Tracker keeps the groups of the validation failures
+type Tracker struct {
+	config Config
+	mu     sync.Mutex
+	groups map[key]*Group
+}
+
+var (
+	t           = New(Config{})
+	labelEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+)
+
+// New is used to create a tracker for the config
+func New(config Config) *Tracker {
+	if config.MaxGroups <= 0 {
+		config.MaxGroups = constants.DefaultMaxViolationGroups
+	}
+	return &Tracker{config: config, groups: make(map[key]*Group)}
+}
+
+// Init is used to replace the default tracker
+func Init(config Config) {
+	t = New(config)
+}
+
+// Get is used to get the default tracker
+func Get() *Tracker {
+	return t
+}
+
+// Record is used to count the entry of the type rejected for violating the constraint, against the api key of
+// the request it came with
+func (t *Tracker) Record(ctx context.Context, logType, constraint string, cause error) {
+	apiKey := constants.AnonymousAPIKey
+	if name, ok := tenants.KeyNameFromContext(ctx); ok {
+		apiKey = name
+	}
+	if len(logType) > constants.MaxViolationTypeLength {
+		logType = logType[:constants.MaxViolationTypeLength]
+	}
+
+	now := time.Now().UTC()
+	k := key{apiKey: apiKey, logType: logType, constraint: constraint}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	group, ok := t.groups[k]
+	if !ok && len(t.groups) >= t.config.MaxGroups {
+		k = key{apiKey: constants.OverflowLabelValue, logType: constants.OverflowLabelValue,
+			constraint: constants.OverflowLabelValue}
+		group, ok = t.groups[k]
+	}
+	if !ok {
+		group = &Group{APIKey: k.apiKey, Type: k.logType, Constraint: k.constraint, FirstSeenAt: now}
+		t.groups[k] = group
+	}
+	group.Count++
+	group.LastSeenAt = now
+	group.LastError = cause.Error()
+}
+
+// List is used to get the matching groups, the ones with the most failures first
+func (t *Tracker) List(filter Filter) []Group {
+	t.mu.Lock()
+	groups := make([]Group, 0, len(t.groups))
+	for _, group := range t.groups {
+		if filter.matches(*group) {
+			groups = append(groups, *group)
+		}
+	}
+	t.mu.Unlock()
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].LastSeenAt.After(groups[j].LastSeenAt)
+	})
+	if filter.Limit > 0 && len(groups) > filter.Limit {
+		groups = groups[:filter.Limit]
+	}
+	return groups
+}
+
+// WriteMetrics is used to write the counts of the groups as a labeled counter in the prometheus text format, or
+// in the OpenMetrics one whose counter families are named without the _total suffix of their samples
+func (t *Tracker) WriteMetrics(w io.Writer, openMetrics bool) error {
+	groups := t.List(Filter{})
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].APIKey != groups[j].APIKey {
+			return groups[i].APIKey < groups[j].APIKey
+		}
+		if groups[i].Type != groups[j].Type {
+			return groups[i].Type < groups[j].Type
+		}
+		return groups[i].Constraint < groups[j].Constraint
+	})
+	family := constants.ValidationFailuresMetric
+	if openMetrics {
+		family = strings.TrimSuffix(family, constants.CounterSuffix)
+	}
+	b := bufio.NewWriter(w)
+	_, _ = fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s counter\n", family, constants.ValidationFailuresHelp, family)
+	for _, group := range groups {
+		_, _ = fmt.Fprintf(b, "%s{%s=\"%s\",%s=\"%s\",%s=\"%s\"} %d\n", constants.ValidationFailuresMetric,
+			constants.APIKeyLabel, labelEscape.Replace(group.APIKey), constants.TypeLabel,
+			labelEscape.Replace(group.Type), constants.ConstraintLabel, labelEscape.Replace(group.Constraint),
+			group.Count)
+	}
+	return b.Flush()
+}
+
+// matches is used to check whether the group is selected by the filter
+func (f Filter) matches(group Group) bool {
+	return (f.APIKey == "" || f.APIKey == group.APIKey) && (f.Type == "" || f.Type == group.Type) &&
+		(f.Constraint == "" || f.Constraint == group.Constraint)
+}
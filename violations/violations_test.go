@@ -0,0 +1,62 @@
+package violations_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/tenants"
+	"github.com/angel-one/nbu-logger-service/violations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := violations.New(violations.Config{MaxGroups: 3})
+	mobile := tenants.WithKeyName(context.Background(), "mobile-app")
+	web := context.Background()
+	tracker.Record(mobile, "payment", "pipeline.coercion", errors.New("amount is not a number"))
+	tracker.Record(mobile, "payment", "pipeline.coercion", errors.New("quantity is not an integer"))
+	tracker.Record(mobile, "payment", "pipeline.labels", errors.New("20 labels provided"))
+	tracker.Record(web, "payment", "pipeline.coercion", errors.New("amount is not a number"))
+
+	// the failures are grouped by api key, type and constraint, the requests without a known key are anonymous
+	groups := tracker.List(violations.Filter{})
+	if assert.Len(t, groups, 3) {
+		assert.Equal(t, "mobile-app", groups[0].APIKey)
+		assert.Equal(t, "payment", groups[0].Type)
+		assert.Equal(t, "pipeline.coercion", groups[0].Constraint)
+		assert.Equal(t, int64(2), groups[0].Count)
+		assert.Equal(t, "quantity is not an integer", groups[0].LastError)
+		assert.False(t, groups[0].LastSeenAt.Before(groups[0].FirstSeenAt))
+	}
+	groups = tracker.List(violations.Filter{APIKey: constants.AnonymousAPIKey})
+	if assert.Len(t, groups, 1) {
+		assert.Equal(t, "pipeline.coercion", groups[0].Constraint)
+	}
+	assert.Len(t, tracker.List(violations.Filter{Constraint: "pipeline.coercion"}), 2)
+	assert.Len(t, tracker.List(violations.Filter{Type: "order"}), 0)
+	assert.Len(t, tracker.List(violations.Filter{Limit: 1}), 1)
+
+	// the failures of the groups beyond the maximum are counted together
+	tracker.Record(mobile, "order", "pipeline.grok", errors.New("no pattern matches"))
+	tracker.Record(web, "refund", "pipeline.labels", errors.New("label key \"a b\" does not match"))
+	groups = tracker.List(violations.Filter{Type: constants.OverflowLabelValue})
+	if assert.Len(t, groups, 1) {
+		assert.Equal(t, int64(2), groups[0].Count)
+	}
+
+	// and every group is a series of the metric
+	var b bytes.Buffer
+	assert.NoError(t, tracker.WriteMetrics(&b, false))
+	assert.Contains(t, b.String(), "# TYPE "+constants.ValidationFailuresMetric+" counter\n")
+	assert.Contains(t, b.String(), constants.ValidationFailuresMetric+
+		`{api_key="mobile-app",type="payment",constraint="pipeline.coercion"} 2`+"\n")
+	assert.Contains(t, b.String(), constants.ValidationFailuresMetric+
+		`{api_key="__overflow__",type="__overflow__",constraint="__overflow__"} 2`+"\n")
+
+	b.Reset()
+	assert.NoError(t, tracker.WriteMetrics(&b, true))
+	assert.Contains(t, b.String(), "# TYPE logger_validation_failures counter\n")
+}
@@ -0,0 +1,609 @@
+// Package wal is the optional write ahead log of the accepted entries. Entries are appended to segment files on
+// the local disk before they are acknowledged, and a replayer hands them on to the queue or persists them in the
+// background, retrying until it succeeds, so that they survive a crash of the process and outages of redis or
+// the store. Segments are deleted once every entry in them was handed on. Entries are delivered at least once,
+// a crash before the replay position is recorded delivers the last ones again.
+package wal
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angel-one/go-utils/log"
+	"github.com/angel-one/nbu-logger-service/constants"
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/utils/files"
+)
+
+// Config is the configuration of the write ahead log
+type Config struct {
+	// Dir is the directory of the segments, the log is disabled when empty
+	Dir string `json:"dir" mapstructure:"dir"`
+	// SegmentSizeInBytes is the size past which a new segment is started
+	SegmentSizeInBytes int64 `json:"segmentSizeInBytes" mapstructure:"segmentSizeInBytes"`
+	// MaxSizeInBytes is the size of the segments past which entries are rejected, unbounded when 0
+	MaxSizeInBytes int64 `json:"maxSizeInBytes" mapstructure:"maxSizeInBytes"`
+	// SyncIntervalInMillis is the time between syncs of the segments to the disk, every entry is synced before
+	// it is acknowledged when 0. An interval trades the entries of the last interval on a power loss for
+	// throughput, a crash of the process alone loses nothing either way.
+	SyncIntervalInMillis int `json:"syncIntervalInMillis" mapstructure:"syncIntervalInMillis"`
+	// RetryIntervalInMillis is the wait before delivering an entry again when it failed
+	RetryIntervalInMillis int `json:"retryIntervalInMillis" mapstructure:"retryIntervalInMillis"`
+}
+
+// Handler is used to deliver an entry of the log, it is delivered again when it fails
+type Handler func(ctx context.Context, entry models.LogEntry) error
+
+// Stats are the counters of the write ahead log
+type Stats struct {
+	Segments    int   `json:"segments"`
+	SizeInBytes int64 `json:"sizeInBytes"`
+	// MaxSizeInBytes is the size past which entries are rejected, unbounded when 0
+	MaxSizeInBytes int64 `json:"maxSizeInBytes"`
+	Appended       int64 `json:"appended"`
+	Replayed       int64 `json:"replayed"`
+	Failures       int64 `json:"failures"`
+}
+
+// ErrFull is returned when appending to a log over its maximum size, the entries are not accepted
+var ErrFull = errors.New("write ahead log is full")
+
+// position is the position of the next entry to replay
+type position struct {
+	Segment uint64 `json:"segment"`
+	Offset  int64  `json:"offset"`
+}
+
+type writeAheadLog struct {
+	config  Config
+	handler Handler
+	mu      sync.Mutex
+	closed  bool
+	active  *os.File
+	// segment is the sequence of the active segment and written its size
+	segment uint64
+	written int64
+	// sizes are the sizes of the segments by sequence
+	sizes map[uint64]int64
+	// replayed is the position of the next entry to deliver
+	replayed position
+	dirty    bool
+	appended chan struct{}
+	stats    Stats
+	cancel   context.CancelFunc
+	done     sync.WaitGroup
+}
+
+var (
+	mu sync.RWMutex
+	w  *writeAheadLog
+)
+
+// Init is used to open the log in the directory and replay the entries left in it with the handler, the log of
+// a previous call is stopped first
+func Init(ctx context.Context, config Config, handler Handler) error {
+	Stop()
+	if config.Dir == "" {
+		return nil
+	}
+	if config.SegmentSizeInBytes <= 0 {
+		config.SegmentSizeInBytes = constants.DefaultWALSegmentSizeInBytes
+	}
+	if config.RetryIntervalInMillis <= 0 {
+		config.RetryIntervalInMillis = constants.DefaultWALRetryIntervalInMillis
+	}
+	if err := os.MkdirAll(config.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating wal directory : %w", err)
+	}
+	next := &writeAheadLog{config: config, handler: handler, sizes: make(map[uint64]int64),
+		appended: make(chan struct{}, 1)}
+	from, err := next.open()
+	if err != nil {
+		return err
+	}
+	next.replayed = from
+	ctx, next.cancel = context.WithCancel(ctx)
+	next.done.Add(1)
+	go next.replay(ctx, from)
+	if config.SyncIntervalInMillis > 0 {
+		next.done.Add(1)
+		go next.syncEvery(ctx, time.Duration(config.SyncIntervalInMillis)*time.Millisecond)
+	}
+	mu.Lock()
+	w = next
+	mu.Unlock()
+	return nil
+}
+
+// Enabled is used to check whether the entries are written to the log before they are acknowledged
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return w != nil
+}
+
+// Append is used to write the entry to the log, it is replayed once it is on the disk
+func Append(_ context.Context, entry models.LogEntry) error {
+	mu.RLock()
+	l := w
+	mu.RUnlock()
+	if l == nil {
+		return errors.New("write ahead log is not initialized")
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return l.append(record(payload), false)
+}
+
+// AppendDurable is used to write the entry to the log and sync it to the disk before returning, whatever the
+// sync interval, for the entries that must survive a power loss once acknowledged
+func AppendDurable(_ context.Context, entry models.LogEntry) error {
+	mu.RLock()
+	l := w
+	mu.RUnlock()
+	if l == nil {
+		return errors.New("write ahead log is not initialized")
+	}
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return l.append(record(payload), true)
+}
+
+// GetStats is used to get the counters of the log, empty when it is disabled
+func GetStats() Stats {
+	mu.RLock()
+	l := w
+	mu.RUnlock()
+	if l == nil {
+		return Stats{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := l.stats
+	stats.Segments = len(l.sizes)
+	stats.MaxSizeInBytes = l.config.MaxSizeInBytes
+	for _, size := range l.sizes {
+		stats.SizeInBytes += size
+	}
+	return stats
+}
+
+// Pending is used to get the entries that were not delivered yet, oldest first and at most limit of them when
+// the limit is positive, e.g. to copy them to another region. Nothing is pending when the log is disabled.
+func Pending(limit int) ([]models.LogEntry, error) {
+	mu.RLock()
+	l := w
+	mu.RUnlock()
+	if l == nil {
+		return nil, nil
+	}
+	l.mu.Lock()
+	from := l.replayed
+	sizes := make(map[uint64]int64, len(l.sizes))
+	segments := make([]uint64, 0, len(l.sizes))
+	for segment, size := range l.sizes {
+		if segment >= from.Segment {
+			sizes[segment] = size
+			segments = append(segments, segment)
+		}
+	}
+	l.mu.Unlock()
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i] < segments[j]
+	})
+
+	var entries []models.LogEntry
+	for _, segment := range segments {
+		var offset int64
+		if segment == from.Segment {
+			offset = from.Offset
+		}
+		file, err := os.Open(l.path(segment))
+		if os.IsNotExist(err) {
+			// delivered in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for offset < sizes[segment] && (limit <= 0 || len(entries) < limit) {
+			payload, err := readRecord(file, offset, sizes[segment])
+			if err != nil {
+				// the replay skips the rest of a corrupted segment too
+				break
+			}
+			offset += int64(constants.WALHeaderSize + len(payload))
+			var entry models.LogEntry
+			if err = json.Unmarshal(payload, &entry); err == nil {
+				entries = append(entries, entry)
+			}
+		}
+		_ = file.Close()
+		if limit > 0 && len(entries) >= limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// Stop is used to stop replaying and close the log, the entries not replayed yet are replayed on the next Init
+func Stop() {
+	mu.Lock()
+	l := w
+	w = nil
+	mu.Unlock()
+	if l == nil {
+		return
+	}
+	l.cancel()
+	l.done.Wait()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	if err := l.active.Sync(); err != nil {
+		log.Error(context.Background()).Err(err).Msg("error syncing wal segment")
+	}
+	_ = l.active.Close()
+}
+
+// record is used to frame the payload with its length and checksum
+func record(payload []byte) []byte {
+	framed := make([]byte, constants.WALHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(framed, uint32(len(payload)))
+	binary.BigEndian.PutUint32(framed[4:], crc32.ChecksumIEEE(payload))
+	copy(framed[constants.WALHeaderSize:], payload)
+	return framed
+}
+
+// open is used to find the segments, repair the tail of the last one and open it for appending, and get the
+// position the replay starts from
+func (l *writeAheadLog) open() (position, error) {
+	segments, err := l.segments()
+	if err != nil {
+		return position{}, err
+	}
+	var from position
+	data, err := os.ReadFile(filepath.Join(l.config.Dir, constants.WALCheckpointFile))
+	if err != nil && !os.IsNotExist(err) {
+		return position{}, err
+	}
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &from); err != nil {
+			return position{}, fmt.Errorf("wal checkpoint : %w", err)
+		}
+	}
+	var kept []uint64
+	for _, segment := range segments {
+		if segment < from.Segment {
+			// replayed before the process stopped, the deletion did not happen
+			_ = os.Remove(l.path(segment))
+			continue
+		}
+		info, err := os.Stat(l.path(segment))
+		if err != nil {
+			return position{}, err
+		}
+		l.sizes[segment] = info.Size()
+		kept = append(kept, segment)
+	}
+	if len(kept) == 0 {
+		l.segment = from.Segment
+		if l.segment == 0 {
+			l.segment = 1
+		}
+		return position{Segment: l.segment}, l.create()
+	}
+	l.segment = kept[len(kept)-1]
+	if _, ok := l.sizes[from.Segment]; !ok {
+		from = position{Segment: kept[0]}
+	}
+	// a crash while appending leaves a torn record at the end of the last segment
+	valid, err := validLength(l.path(l.segment))
+	if err != nil {
+		return position{}, err
+	}
+	if valid < l.sizes[l.segment] {
+		log.Warn(context.Background()).Int64("truncatedBytes", l.sizes[l.segment]-valid).
+			Msg("truncating torn wal record")
+		if err = os.Truncate(l.path(l.segment), valid); err != nil {
+			return position{}, err
+		}
+		l.sizes[l.segment] = valid
+	}
+	if from.Segment == l.segment && from.Offset > valid {
+		from.Offset = valid
+	}
+	l.active, err = os.OpenFile(l.path(l.segment), os.O_WRONLY|os.O_APPEND, 0o644)
+	l.written = l.sizes[l.segment]
+	return from, err
+}
+
+// segments is used to get the sequences of the segments in the directory in order
+func (l *writeAheadLog) segments() ([]uint64, error) {
+	dirEntries, err := os.ReadDir(l.config.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []uint64
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if !strings.HasSuffix(name, constants.WALSegmentSuffix) {
+			continue
+		}
+		sequence, err := strconv.ParseUint(strings.TrimSuffix(name, constants.WALSegmentSuffix), 10, 64)
+		if err == nil {
+			segments = append(segments, sequence)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i] < segments[j]
+	})
+	return segments, nil
+}
+
+// path is used to get the file of the segment
+func (l *writeAheadLog) path(segment uint64) string {
+	return filepath.Join(l.config.Dir, fmt.Sprintf("%020d%s", segment, constants.WALSegmentSuffix))
+}
+
+// create is used to start the segment, it is the active one
+func (l *writeAheadLog) create() error {
+	active, err := os.OpenFile(l.path(l.segment), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	l.active, l.written, l.sizes[l.segment] = active, 0, 0
+	return nil
+}
+
+// append is used to write the record to the active segment, starting a new one when it is full
+func (l *writeAheadLog) append(framed []byte, sync bool) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return errors.New("write ahead log is closed")
+	}
+	if l.config.MaxSizeInBytes > 0 {
+		var size int64
+		for _, s := range l.sizes {
+			size += s
+		}
+		if size+int64(len(framed)) > l.config.MaxSizeInBytes {
+			return ErrFull
+		}
+	}
+	if l.written > 0 && l.written+int64(len(framed)) > l.config.SegmentSizeInBytes {
+		if err := l.active.Sync(); err != nil {
+			return err
+		}
+		if err := l.active.Close(); err != nil {
+			return err
+		}
+		l.segment++
+		if err := l.create(); err != nil {
+			return err
+		}
+	}
+	if _, err := l.active.Write(framed); err != nil {
+		return err
+	}
+	if sync || l.config.SyncIntervalInMillis <= 0 {
+		if err := l.active.Sync(); err != nil {
+			return err
+		}
+		// the records appended since the last sync are on the disk too
+		l.dirty = false
+	} else {
+		l.dirty = true
+	}
+	l.written += int64(len(framed))
+	l.sizes[l.segment] = l.written
+	l.stats.Appended++
+	select {
+	case l.appended <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// syncEvery is used to sync the appended records to the disk at the interval
+func (l *writeAheadLog) syncEvery(ctx context.Context, interval time.Duration) {
+	defer l.done.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if l.dirty {
+				if err := l.active.Sync(); err != nil {
+					log.Error(ctx).Err(err).Msg("error syncing wal segment")
+				}
+				l.dirty = false
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+// limit is used to get the readable length of the segment, the records written so far, and whether no more
+// records are written to it
+func (l *writeAheadLog) limit(segment uint64) (int64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sizes[segment], segment < l.segment
+}
+
+// replay is used to deliver the records from the position on, waiting for new ones once caught up
+func (l *writeAheadLog) replay(ctx context.Context, from position) {
+	defer l.done.Done()
+	var file *os.File
+	defer func() {
+		if file != nil {
+			_ = file.Close()
+		}
+	}()
+	replayed := 0
+	for ctx.Err() == nil {
+		size, sealed := l.limit(from.Segment)
+		if from.Offset >= size {
+			if !sealed {
+				// caught up, the position is recorded before waiting for new entries
+				if replayed > 0 {
+					l.checkpoint(ctx, from)
+					replayed = 0
+				}
+				select {
+				case <-ctx.Done():
+				case <-l.appended:
+				}
+				continue
+			}
+			if file != nil {
+				_ = file.Close()
+				file = nil
+			}
+			from = l.advance(ctx, from)
+			replayed = 0
+			continue
+		}
+		if file == nil {
+			var err error
+			if file, err = os.Open(l.path(from.Segment)); err != nil {
+				log.Error(ctx).Err(err).Msg("error opening wal segment")
+				l.wait(ctx)
+				continue
+			}
+		}
+		payload, err := readRecord(file, from.Offset, size)
+		if err != nil {
+			// the rest of a corrupted segment can not be framed again, so it is skipped
+			log.Error(ctx).Err(err).Uint64("segment", from.Segment).Int64("offset", from.Offset).
+				Msg("skipping corrupted wal segment")
+			from.Offset = size
+			l.mu.Lock()
+			l.replayed = from
+			l.mu.Unlock()
+			continue
+		}
+		var entry models.LogEntry
+		if err = json.Unmarshal(payload, &entry); err != nil {
+			log.Error(ctx).Err(err).Msg("skipping undecodable wal entry")
+		} else if err = l.handler(ctx, entry); err != nil {
+			l.mu.Lock()
+			l.stats.Failures++
+			l.mu.Unlock()
+			log.Warn(ctx).Err(err).Msg("error delivering wal entry, retrying")
+			l.wait(ctx)
+			continue
+		}
+		from.Offset += int64(constants.WALHeaderSize + len(payload))
+		l.mu.Lock()
+		l.stats.Replayed++
+		l.replayed = from
+		l.mu.Unlock()
+		if replayed++; replayed >= constants.WALCheckpointEvery {
+			l.checkpoint(ctx, from)
+			replayed = 0
+		}
+	}
+	// the entries replayed since the last checkpoint are not replayed again by the next process
+	if replayed > 0 {
+		l.checkpoint(context.Background(), from)
+	}
+}
+
+// advance is used to move the replay past the sealed segment and delete it
+func (l *writeAheadLog) advance(ctx context.Context, from position) position {
+	next := position{Segment: from.Segment + 1}
+	if !l.checkpoint(ctx, next) {
+		l.wait(ctx)
+		return from
+	}
+	if err := os.Remove(l.path(from.Segment)); err != nil && !os.IsNotExist(err) {
+		log.Warn(ctx).Err(err).Msg("error deleting replayed wal segment")
+	}
+	l.mu.Lock()
+	delete(l.sizes, from.Segment)
+	l.replayed = next
+	l.mu.Unlock()
+	return next
+}
+
+// checkpoint is used to record the replay position
+func (l *writeAheadLog) checkpoint(ctx context.Context, at position) bool {
+	data, _ := json.Marshal(at)
+	if err := files.WriteAtomic(filepath.Join(l.config.Dir, constants.WALCheckpointFile), data); err != nil {
+		log.Error(ctx).Err(err).Msg("error recording wal position")
+		return false
+	}
+	return true
+}
+
+// wait is used to wait for the retry interval or the end of the context
+func (l *writeAheadLog) wait(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Duration(l.config.RetryIntervalInMillis) * time.Millisecond):
+	}
+}
+
+// readRecord is used to read the payload of the record at the offset, within the readable size
+func readRecord(file *os.File, offset, size int64) ([]byte, error) {
+	header := make([]byte, constants.WALHeaderSize)
+	if size-offset < constants.WALHeaderSize {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if _, err := file.ReadAt(header, offset); err != nil {
+		return nil, err
+	}
+	length := int64(binary.BigEndian.Uint32(header))
+	if size-offset-constants.WALHeaderSize < length {
+		return nil, io.ErrUnexpectedEOF
+	}
+	payload := make([]byte, length)
+	if _, err := file.ReadAt(payload, offset+constants.WALHeaderSize); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		return nil, fmt.Errorf("wal record checksum mismatch")
+	}
+	return payload, nil
+}
+
+// validLength is used to get the length of the whole records at the start of the segment
+func validLength(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	var offset int64
+	for int64(len(data))-offset >= constants.WALHeaderSize {
+		length := int64(binary.BigEndian.Uint32(data[offset:]))
+		end := offset + constants.WALHeaderSize + length
+		if end > int64(len(data)) ||
+			crc32.ChecksumIEEE(data[offset+constants.WALHeaderSize:end]) != binary.BigEndian.Uint32(data[offset+4:]) {
+			break
+		}
+		offset = end
+	}
+	return offset, nil
+}
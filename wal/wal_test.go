@@ -0,0 +1,148 @@
+package wal_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angel-one/nbu-logger-service/models"
+	"github.com/angel-one/nbu-logger-service/wal"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder delivers the entries in order, failing the first attempts
+type recorder struct {
+	mu        sync.Mutex
+	failures  int
+	delivered []string
+}
+
+func (r *recorder) handle(_ context.Context, entry models.LogEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		return errors.New("queue is down")
+	}
+	r.delivered = append(r.delivered, entry.Type)
+	return nil
+}
+
+func (r *recorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.delivered)
+}
+
+func segments(t *testing.T, dir string) []string {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	assert.NoError(t, err)
+	return matches
+}
+
+func TestReplay(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	r := &recorder{failures: 2}
+	assert.NoError(t, wal.Init(ctx, wal.Config{Dir: dir, SegmentSizeInBytes: 200, RetryIntervalInMillis: 5}, r.handle))
+	defer wal.Stop()
+	assert.True(t, wal.Enabled())
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, wal.Append(ctx, models.LogEntry{Type: strconv.Itoa(i)}))
+	}
+	assert.Eventually(t, func() bool { return r.count() == 10 }, 5*time.Second, 5*time.Millisecond)
+	expected := []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}
+	assert.Equal(t, expected, r.delivered)
+	stats := wal.GetStats()
+	assert.Equal(t, int64(10), stats.Appended)
+	assert.Equal(t, int64(2), stats.Failures)
+	// the replayed segments are deleted, only the active one is kept
+	assert.Eventually(t, func() bool { return len(segments(t, dir)) == 1 }, 5*time.Second, 5*time.Millisecond)
+	wal.Stop()
+	assert.False(t, wal.Enabled())
+
+	// nothing is replayed again by the next process
+	again := &recorder{}
+	assert.NoError(t, wal.Init(ctx, wal.Config{Dir: dir}, again.handle))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, again.count())
+}
+
+func TestTornTail(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	down := &recorder{failures: 1 << 30}
+	assert.NoError(t, wal.Init(ctx, wal.Config{Dir: dir, RetryIntervalInMillis: 1000}, down.handle))
+	assert.NoError(t, wal.Append(ctx, models.LogEntry{Type: "first"}))
+	assert.NoError(t, wal.Append(ctx, models.LogEntry{Type: "second"}))
+	wal.Stop()
+
+	// a crash in the middle of an append leaves part of a record
+	files := segments(t, dir)
+	assert.Len(t, files, 1)
+	file, err := os.OpenFile(files[0], os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.NoError(t, err)
+	_, err = file.Write([]byte{0, 0, 1, 0, 7, 7})
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	r := &recorder{}
+	assert.NoError(t, wal.Init(ctx, wal.Config{Dir: dir}, r.handle))
+	defer wal.Stop()
+	assert.NoError(t, wal.Append(ctx, models.LogEntry{Type: "third"}))
+	assert.Eventually(t, func() bool { return r.count() == 3 }, 5*time.Second, 5*time.Millisecond)
+	assert.Equal(t, []string{"first", "second", "third"}, r.delivered)
+}
+
+func TestFull(t *testing.T) {
+	ctx := context.Background()
+	down := &recorder{failures: 1 << 30}
+	assert.NoError(t, wal.Init(ctx, wal.Config{Dir: t.TempDir(), MaxSizeInBytes: 100, RetryIntervalInMillis: 1000},
+		down.handle))
+	defer wal.Stop()
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = wal.Append(ctx, models.LogEntry{Type: "app"})
+	}
+	assert.ErrorIs(t, err, wal.ErrFull)
+}
+
+func TestPending(t *testing.T) {
+	ctx := context.Background()
+	r := &recorder{failures: 1 << 30}
+	assert.NoError(t, wal.Init(ctx, wal.Config{Dir: t.TempDir(), SegmentSizeInBytes: 100, RetryIntervalInMillis: 5},
+		r.handle))
+	defer wal.Stop()
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, wal.Append(ctx, models.LogEntry{Type: strconv.Itoa(i)}))
+	}
+	types := func(entries []models.LogEntry) []string {
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.Type)
+		}
+		return result
+	}
+
+	// the entries of every segment are pending while they can not be delivered
+	pending, err := wal.Pending(0)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0", "1", "2", "3", "4", "5"}, types(pending))
+	assert.Greater(t, wal.GetStats().Segments, 1)
+	pending, err = wal.Pending(4)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"0", "1", "2", "3"}, types(pending))
+
+	r.mu.Lock()
+	r.failures = 0
+	r.mu.Unlock()
+	assert.Eventually(t, func() bool { return r.count() == 6 }, 5*time.Second, 5*time.Millisecond)
+	pending, err = wal.Pending(0)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}